@@ -0,0 +1,64 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterDiskConfigSpec defines the desired state of ClusterDiskConfig
+type ClusterDiskConfigSpec struct {
+	// NamespaceSelector is a selector which must be true for the namespace to get the config.
+	// Missing selector matches no namespaces, empty selector matches all namespaces.
+	// A DiskConfig with the same name in the namespace takes precedence over the cluster config.
+	//+kubebuilder:validation:Optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty" yaml:"namespaceSelector,omitempty"`
+
+	DiskConfigSpec `json:",inline" yaml:",inline"`
+}
+
+// ClusterDiskConfigStatus defines the observed state of ClusterDiskConfig
+type ClusterDiskConfigStatus struct {
+	// Namespaces is the list of namespaces the config has been applied to.
+	Namespaces []string `json:"namespaces,omitempty" yaml:"namespaces,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:subresource:status
+
+// ClusterDiskConfig is the Schema for the clusterdiskconfigs API
+type ClusterDiskConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterDiskConfigSpec   `json:"spec,omitempty"`
+	Status ClusterDiskConfigStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterDiskConfigList contains a list of ClusterDiskConfig
+type ClusterDiskConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterDiskConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterDiskConfig{}, &ClusterDiskConfigList{})
+}
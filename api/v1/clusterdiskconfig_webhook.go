@@ -0,0 +1,102 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// SetupWebhookWithManager sets up the webhook with the Manager.
+func (r *ClusterDiskConfig) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	if diskConfigWebhookDependencies == nil {
+		return errors.New("dependencies are missing")
+	}
+
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-discoblocks-ondat-io-v1-clusterdiskconfig,mutating=true,failurePolicy=fail,sideEffects=None,groups=discoblocks.ondat.io,resources=clusterdiskconfigs,verbs=create,versions=v1,name=mclusterdiskconfig.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &ClusterDiskConfig{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type,
+// rendered configs get the StorageClass of the cluster config
+func (r *ClusterDiskConfig) Default() {
+	defaultStorageClassName(&r.Spec.DiskConfigSpec, diskConfigLog.WithValues("cdc_name", r.Name))
+}
+
+//+kubebuilder:webhook:path=/validate-discoblocks-ondat-io-v1-clusterdiskconfig,mutating=false,failurePolicy=fail,sideEffects=None,groups=discoblocks.ondat.io,resources=clusterdiskconfigs,verbs=create;update,versions=v1,name=validateclusterdiskconfig.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &ClusterDiskConfig{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterDiskConfig) ValidateCreate() error {
+	return r.validate(nil)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterDiskConfig) ValidateUpdate(old runtime.Object) error {
+	return r.validate(old)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterDiskConfig) ValidateDelete() error {
+	return nil
+}
+
+// validate runs the same validation as DiskConfig, rendered configs must be accepted in every namespace
+func (r *ClusterDiskConfig) validate(old runtime.Object) error {
+	if r.Spec.NamespaceSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(r.Spec.NamespaceSelector); err != nil {
+			return fmt.Errorf("invalid namespace selector: %w", err)
+		}
+	}
+
+	var oldDC runtime.Object
+	if old != nil {
+		oldCDC, ok := old.(*ClusterDiskConfig)
+		if !ok {
+			err := errors.New("invalid old object")
+			diskConfigLog.Error(err, "this should not happen", "cdc_name", r.Name)
+			return err
+		}
+
+		oldDC = &DiskConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: oldCDC.Name,
+			},
+			Spec: oldCDC.Spec.DiskConfigSpec,
+		}
+	}
+
+	dc := DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: r.Name,
+		},
+		Spec: r.Spec.DiskConfigSpec,
+	}
+
+	return dc.validate(oldDC)
+}
@@ -17,9 +17,15 @@ limitations under the License.
 package v1
 
 import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -30,10 +36,15 @@ type DiskConfigSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// StorageClassName is the of the StorageClass required by the config.
+	// StorageClassName is the of the StorageClass required by the config. Empty selects the default StorageClass of the cluster on creation.
 	//+kubebuilder:validation:Optional
 	StorageClassName string `json:"storageClassName,omitempty" yaml:"storageClassName,omitempty"`
 
+	// VolumeAttributesClassName is the name of the VolumeAttributesClass of the disks, requires VolumeAttributesClass support of the cluster.
+	// Changing it updates performance parameters of existing disks without resize.
+	//+kubebuilder:validation:Optional
+	VolumeAttributesClassName string `json:"volumeAttributesClassName,omitempty" yaml:"volumeAttributesClassName,omitempty"`
+
 	// Capacity represents the desired capacity of the underlying volume.
 	//+kubebuilder:default:="1Gi"
 	//+kubebuilder:validation:Optional
@@ -46,6 +57,12 @@ type DiskConfigSpec struct {
 	//+kubebuilder:validation:Optional
 	MountPointPattern string `json:"mountPointPattern,omitempty" yaml:"mountPointPattern,omitempty"`
 
+	// MountPropagation is the mount propagation of the disk in the containers, Bidirectional requires privileged containers.
+	// Metrics sidecars always mount without propagation.
+	//+kubebuilder:validation:Enum=None;HostToContainer;Bidirectional
+	//+kubebuilder:validation:Optional
+	MountPropagation *corev1.MountPropagationMode `json:"mountPropagation,omitempty" yaml:"mountPropagation,omitempty"`
+
 	// AccessModes contains the desired access modes the volume should have.
 	// More info: https://kubernetes.io/docs/concepts/storage/persistent-volumes#access-modes-1
 	//+kubebuilder:default:={"ReadWriteOnce"}
@@ -57,26 +74,168 @@ type DiskConfigSpec struct {
 	//+kubebuilder:validation:Optional
 	AvailabilityMode AvailabilityMode `json:"availabilityMode,omitempty" yaml:"availabilityMode,omitempty"`
 
+	// ProvisionMode defines when disks are provisioned. Lazy creates disks at Pod admission, Eager creates disks of the running
+	// Pods when the config is applied, so they are bound by the time Pods are recreated. Eager requires ReadWriteSame or ReadWriteDaemon.
+	//+kubebuilder:default:="Lazy"
+	//+kubebuilder:validation:Optional
+	ProvisionMode ProvisionMode `json:"provisionMode,omitempty" yaml:"provisionMode,omitempty"`
+
+	// MetricsSource defines where disk usage is observed. Sidecar injects metrics sidecars into Pods and matches mount points,
+	// Kubelet reads kubelet_volume_stats_* metrics of the node by PVC name, the CSI driver has to implement NodeGetVolumeStats.
+	//+kubebuilder:default:="Sidecar"
+	//+kubebuilder:validation:Optional
+	MetricsSource MetricsSource `json:"metricsSource,omitempty" yaml:"metricsSource,omitempty"`
+
+	// PrivilegedMetrics runs the metrics sidecar privileged with read-only /var/lib/kubelet of the host, so it reports
+	// global mounts of volumes too. Needed only if the driver mounts the volume outside of the container. Ignored by Kubelet source.
+	//+kubebuilder:validation:Optional
+	PrivilegedMetrics bool `json:"privilegedMetrics,omitempty" yaml:"privilegedMetrics,omitempty"`
+
+	// DevicePathStrategy selects how the driver resolves the device of the volume on the host, empty selects the default of the driver.
+	// NVMeSerial matches the serial of the NVMe controller, ByID resolves the /dev/disk/by-id symlink,
+	// VolumeAttachment uses the device path of attachment metadata. Drivers may support a subset of the strategies.
+	//+kubebuilder:validation:Enum=NVMeSerial;ByID;VolumeAttachment
+	//+kubebuilder:validation:Optional
+	DevicePathStrategy DevicePathStrategy `json:"devicePathStrategy,omitempty" yaml:"devicePathStrategy,omitempty"`
+
 	// NodeSelector is a selector which must be true for the disk to fit on a node. Selector which must match a node’s labels for the disk to be provisioned on that node.
 	//+kubebuilder:validation:Optional
 	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty" yaml:"nodeSelector,omitempty"`
 
-	// PodSelector is a selector which must be true for the pod to attach disk.
+	// PodSelector is a selector which must be true for the pod to attach disk. Empty selector matches no pods.
 	//+kubebuilder:validation:Required
 	PodSelector map[string]string `json:"podSelector" yaml:"podSelector"`
 
+	// PVCAnnotations are added to the PVCs created by the config, for example to select disks by backup tools.
+	//+kubebuilder:validation:Optional
+	PVCAnnotations map[string]string `json:"pvcAnnotations,omitempty" yaml:"pvcAnnotations,omitempty"`
+
+	// PodVolumesAnnotations are keys of Pod annotations listing volume names, like backup.velero.io/backup-volumes.
+	// Names of the attached disks are appended to the comma separated value.
+	//+kubebuilder:validation:Optional
+	PodVolumesAnnotations []string `json:"podVolumesAnnotations,omitempty" yaml:"podVolumesAnnotations,omitempty"`
+
+	// MountEnv contains extra environment variables of mount and resize Jobs, available in pre-mount and pre-resize commands of the driver.
+	// Secrets and ConfigMaps are resolved in the namespace of the DiskConfig. Names must start with DISCOBLOCKS_USER_.
+	//+kubebuilder:validation:Optional
+	MountEnv []corev1.EnvVar `json:"mountEnv,omitempty" yaml:"mountEnv,omitempty"`
+
+	// Disks customizes disks of the family by index, for example a small xfs WAL disk next to a large ext4 data disk.
+	// Requires initialNumberOfDisks 1 and maximumNumberOfDisks of the number of disks, first disk is created at admission,
+	// others are added once the Pod runs. Disks scale independently by their own policy.
+	//+kubebuilder:validation:MaxItems:=150
+	//+kubebuilder:validation:Optional
+	Disks []DiskSpec `json:"disks,omitempty" yaml:"disks,omitempty"`
+
 	// Policy contains the disk scale policies.
 	Policy Policy `json:"policy,omitempty" yaml:"policy,omitempty"`
 }
 
+// DiskSpec defines a disk of the family with its own file-system, mount point, capacity and policy
+type DiskSpec struct {
+	// Index is the position of the disk in the family, 0 is the first disk.
+	//+kubebuilder:validation:Minimum:=0
+	//+kubebuilder:validation:Maximum:=149
+	//+kubebuilder:validation:Required
+	Index uint8 `json:"index" yaml:"index"`
+
+	// FileSystem of the disk, empty uses the file-system of the StorageClass. First disk is formatted by the CSI driver,
+	// so it has to match the StorageClass, others are formatted by Discoblocks, which is not supported if the driver manages the file-system.
+	//+kubebuilder:validation:Enum=ext3;ext4;xfs;btrfs
+	//+kubebuilder:validation:Optional
+	FileSystem string `json:"fileSystem,omitempty" yaml:"fileSystem,omitempty"`
+
+	// MountPoint of the disk, empty renders MountPointPattern with the index.
+	//+kubebuilder:validation:Pattern:="^/(.*)"
+	//+kubebuilder:validation:Optional
+	MountPoint string `json:"mountPoint,omitempty" yaml:"mountPoint,omitempty"`
+
+	// Capacity of the disk at creation, empty uses capacity of the config.
+	//+kubebuilder:validation:Optional
+	Capacity *resource.Quantity `json:"capacity,omitempty" yaml:"capacity,omitempty"`
+
+	// Policy overrides scale policy of the config for the disk.
+	//+kubebuilder:validation:Optional
+	Policy *DiskPolicy `json:"policy,omitempty" yaml:"policy,omitempty"`
+}
+
+// DiskPolicy overrides scale policy of a disk, empty fields use the policy of the config
+type DiskPolicy struct {
+	// UpscaleTriggerPercentage defines the disk fullness percentage for disk expansion. Range: (0,100]
+	//+kubebuilder:validation:XIntOrString
+	//+kubebuilder:validation:Pattern:=`^([0-9]*[1-9][0-9]*(\.[0-9]+)?|[0-9]+\.[0-9]*[1-9][0-9]*)%?$`
+	//+kubebuilder:validation:Optional
+	UpscaleTriggerPercentage *intstr.IntOrString `json:"upscaleTriggerPercentage,omitempty" yaml:"upscaleTriggerPercentage,omitempty"`
+
+	// ExtendCapacity represents the capacity to extend with.
+	//+kubebuilder:validation:Optional
+	ExtendCapacity *resource.Quantity `json:"extendCapacity,omitempty" yaml:"extendCapacity,omitempty"`
+
+	// MaximumCapacityOfDisk defines maximum capacity of the disk.
+	//+kubebuilder:validation:Optional
+	MaximumCapacityOfDisk *resource.Quantity `json:"maximumCapacityOfDisk,omitempty" yaml:"maximumCapacityOfDisk,omitempty"`
+}
+
+// GetDisk returns the disk of the index, nil if the disk isn't customized
+func (s *DiskConfigSpec) GetDisk(index int) *DiskSpec {
+	for i := range s.Disks {
+		if int(s.Disks[i].Index) == index {
+			return &s.Disks[i]
+		}
+	}
+
+	return nil
+}
+
+// GetDiskCapacity returns capacity of the disk of the index at creation
+func (s *DiskConfigSpec) GetDiskCapacity(index int) resource.Quantity {
+	if disk := s.GetDisk(index); disk != nil && disk.Capacity != nil {
+		return disk.Capacity.DeepCopy()
+	}
+
+	return s.Capacity.DeepCopy()
+}
+
+// GetDiskPolicy returns policy of the config with overrides of the disk of the index
+func (s *DiskConfigSpec) GetDiskPolicy(index int) Policy {
+	policy := *s.Policy.DeepCopy()
+
+	disk := s.GetDisk(index)
+	if disk == nil || disk.Policy == nil {
+		return policy
+	}
+
+	if disk.Policy.UpscaleTriggerPercentage != nil {
+		policy.UpscaleTriggerPercentage = *disk.Policy.UpscaleTriggerPercentage
+	}
+	if disk.Policy.ExtendCapacity != nil {
+		policy.ExtendCapacity = disk.Policy.ExtendCapacity.DeepCopy()
+	}
+	if disk.Policy.MaximumCapacityOfDisk != nil {
+		policy.MaximumCapacityOfDisk = disk.Policy.MaximumCapacityOfDisk.DeepCopy()
+	}
+
+	return policy
+}
+
 // Policy defines disk resize policies.
 type Policy struct {
 	// UpscaleTriggerPercentage defines the disk fullness percentage for disk expansion.
+	// Decimals are allowed as string, for example "92.5". Range: (0,100]
 	//+kubebuilder:default:=80
-	//+kubebuilder:validation:Minimum:=50
-	//+kubebuilder:validation:Maximum:=100
+	//+kubebuilder:validation:XIntOrString
+	//+kubebuilder:validation:Pattern:=`^([0-9]*[1-9][0-9]*(\.[0-9]+)?|[0-9]+\.[0-9]*[1-9][0-9]*)%?$`
 	//+kubebuilder:validation:Optional
-	UpscaleTriggerPercentage uint8 `json:"upscaleTriggerPercentage,omitempty" yaml:"upscaleTriggerPercentage,omitempty"`
+	UpscaleTriggerPercentage intstr.IntOrString `json:"upscaleTriggerPercentage,omitempty" yaml:"upscaleTriggerPercentage,omitempty"`
+
+	// DownscaleTriggerPercentage defines the disk fullness percentage below which disks are downscale candidates,
+	// usage between downscale and upscale triggers is a dead band without action. Disks are not downscaled yet.
+	// Default is upscale trigger minus 10.
+	// Decimals are allowed as string, for example "62.5". Range: [0,upscaleTriggerPercentage)
+	//+kubebuilder:validation:XIntOrString
+	//+kubebuilder:validation:Pattern:=`^[0-9]+(\.[0-9]+)?%?$`
+	//+kubebuilder:validation:Optional
+	DownscaleTriggerPercentage *intstr.IntOrString `json:"downscaleTriggerPercentage,omitempty" yaml:"downscaleTriggerPercentage,omitempty"`
 
 	// MaximumCapacityOfDisks defines maximum capacity of a disk.
 	//+kubebuilder:default:="1000Gi"
@@ -90,22 +249,159 @@ type Policy struct {
 	//+kubebuilder:validation:Optional
 	MaximumNumberOfDisks uint8 `json:"maximumNumberOfDisks,omitempty" yaml:"maximumNumberOfDisks,omitempty"`
 
+	// InitialNumberOfDisks defines number of disks provisioned at Pod admission.
+	//+kubebuilder:default:=1
+	//+kubebuilder:validation:Minimum:=1
+	//+kubebuilder:validation:Maximum:=150
+	//+kubebuilder:validation:Optional
+	InitialNumberOfDisks uint8 `json:"initialNumberOfDisks,omitempty" yaml:"initialNumberOfDisks,omitempty"`
+
 	// ExtendCapacity represents the capacity to extend with.
 	//+kubebuilder:default:="1Gi"
 	//+kubebuilder:validation:Optional
 	ExtendCapacity resource.Quantity `json:"extendCapacity,omitempty" yaml:"extendCapacity,omitempty"`
 
+	// MaximumStepSize caps the capacity added by a single resize. Zero means no limit.
+	//+kubebuilder:validation:Optional
+	MaximumStepSize resource.Quantity `json:"maximumStepSize,omitempty" yaml:"maximumStepSize,omitempty"`
+
+	// MinimumCapacityOfDisk is the floor of computed capacities, disks never grow to less than this. Zero means no floor.
+	//+kubebuilder:validation:Optional
+	MinimumCapacityOfDisk resource.Quantity `json:"minimumCapacityOfDisk,omitempty" yaml:"minimumCapacityOfDisk,omitempty"`
+
+	// PoolSize is the number of disks provisioned ahead of Pods, first disk of a new Pod is claimed from the pool.
+	// Only ReadWriteOnce availability mode is supported. Zero disables the pool.
+	//+kubebuilder:validation:Minimum:=0
+	//+kubebuilder:validation:Maximum:=50
+	//+kubebuilder:validation:Optional
+	PoolSize uint8 `json:"poolSize,omitempty" yaml:"poolSize,omitempty"`
+
+	// PoolTTL is the lifetime of unclaimed pooled disks, expired ones are reclaimed and replaced.
+	//+kubebuilder:default:="24h"
+	//+kubebuilder:validation:Optional
+	PoolTTL metav1.Duration `json:"poolTTL,omitempty" yaml:"poolTTL,omitempty"`
+
 	// CoolDown defines temporary pause of scaling. Minimum: 10s
 	//+kubebuilder:default:="5m"
 	//+kubebuilder:validation:Optional
 	CoolDown metav1.Duration `json:"coolDown,omitempty" yaml:"coolDown,omitempty"`
 
+	// InitialGracePeriod suppresses resizes of a disk until its PVC and the Pod exist for this duration,
+	// usage may read high during startup. Zero disables the grace period.
+	//+kubebuilder:validation:Optional
+	InitialGracePeriod metav1.Duration `json:"initialGracePeriod,omitempty" yaml:"initialGracePeriod,omitempty"`
+
+	// DetachGracePeriod keeps disks no Pod uses for this duration, so a restarting Pod gets its disks back.
+	// Disks detached for longer are deleted if reclaim policy of their volumes is Delete. Zero keeps disks forever.
+	// Requires ReadWriteSame or ReadWriteDaemon, disks of ReadWriteOnce are never reused.
+	//+kubebuilder:validation:Optional
+	DetachGracePeriod metav1.Duration `json:"detachGracePeriod,omitempty" yaml:"detachGracePeriod,omitempty"`
+
+	// PredictionHorizon resizes a disk if the linear trend of its samples predicts it fills within this duration,
+	// even below upscale trigger percentage. Zero falls back to the default horizon of the operator.
+	//+kubebuilder:validation:Optional
+	PredictionHorizon metav1.Duration `json:"predictionHorizon,omitempty" yaml:"predictionHorizon,omitempty"`
+
+	// PreResizeHook is executed in a container of the Pod before file-system resize, resize is aborted on failure.
+	//+kubebuilder:validation:Optional
+	PreResizeHook *ResizeHook `json:"preResizeHook,omitempty" yaml:"preResizeHook,omitempty"`
+
+	// PostResizeHook is executed in a container of the Pod after file-system resize, even if resize has failed.
+	//+kubebuilder:validation:Optional
+	PostResizeHook *ResizeHook `json:"postResizeHook,omitempty" yaml:"postResizeHook,omitempty"`
+
+	// ResizeRolloutPercentage limits resizes to a stable subset of PVCs, membership is decided by PVC UID and grows with the percentage.
+	//+kubebuilder:default:=100
+	//+kubebuilder:validation:Minimum:=1
+	//+kubebuilder:validation:Maximum:=100
+	//+kubebuilder:validation:Optional
+	ResizeRolloutPercentage uint8 `json:"resizeRolloutPercentage,omitempty" yaml:"resizeRolloutPercentage,omitempty"`
+
+	// ResizeRolloutStepPercentage raises the rollout percentage in every rollout window since the last change of the config.
+	// Zero keeps the rollout percentage static.
+	//+kubebuilder:validation:Maximum:=100
+	//+kubebuilder:validation:Optional
+	ResizeRolloutStepPercentage uint8 `json:"resizeRolloutStepPercentage,omitempty" yaml:"resizeRolloutStepPercentage,omitempty"`
+
+	// ResizeRolloutWindow is the period of rollout steps.
+	//+kubebuilder:default:="1h"
+	//+kubebuilder:validation:Optional
+	ResizeRolloutWindow metav1.Duration `json:"resizeRolloutWindow,omitempty" yaml:"resizeRolloutWindow,omitempty"`
+
 	// Pause disables autoscaling of disks.
 	//+kubebuilder:default:=false
 	//+kubebuilder:validation:Optional
 	Pause bool `json:"pause,omitempty" yaml:"pause,omitempty"`
 }
 
+// downscaleHeadroom is the default dead band below upscale trigger in percentage
+const downscaleHeadroom = 10
+
+// GetUpscaleTriggerPercentage parses UpscaleTriggerPercentage and validates its range
+func (p *Policy) GetUpscaleTriggerPercentage() (float64, error) {
+	trigger, err := parsePercentage(p.UpscaleTriggerPercentage)
+	if err != nil {
+		return 0, fmt.Errorf("invalid upscale trigger percentage %s: %w", p.UpscaleTriggerPercentage.StrVal, err)
+	}
+
+	const hundred = 100
+	if trigger <= 0 || trigger > hundred {
+		return 0, fmt.Errorf("invalid upscale trigger percentage %s, must be in (0,100]", p.UpscaleTriggerPercentage.String())
+	}
+
+	return trigger, nil
+}
+
+// GetDownscaleTriggerPercentage parses DownscaleTriggerPercentage and validates it is below upscale trigger
+func (p *Policy) GetDownscaleTriggerPercentage() (float64, error) {
+	upscale, err := p.GetUpscaleTriggerPercentage()
+	if err != nil {
+		return 0, err
+	}
+
+	if p.DownscaleTriggerPercentage == nil {
+		return math.Max(upscale-downscaleHeadroom, 0), nil
+	}
+
+	trigger, err := parsePercentage(*p.DownscaleTriggerPercentage)
+	if err != nil {
+		return 0, fmt.Errorf("invalid downscale trigger percentage %s: %w", p.DownscaleTriggerPercentage.StrVal, err)
+	}
+
+	if trigger < 0 || trigger >= upscale {
+		return 0, fmt.Errorf("invalid downscale trigger percentage %s, must be in [0,%g)", p.DownscaleTriggerPercentage.String(), upscale)
+	}
+
+	return trigger, nil
+}
+
+func parsePercentage(value intstr.IntOrString) (float64, error) {
+	if value.Type == intstr.String {
+		return strconv.ParseFloat(strings.TrimSuffix(value.StrVal, "%"), 64)
+	}
+
+	return float64(value.IntVal), nil
+}
+
+// ResizeHook defines a command executed in a container of the Pod around file-system resize.
+type ResizeHook struct {
+	// Container is the name of the container, first container of the Pod if empty.
+	//+kubebuilder:validation:Optional
+	Container string `json:"container,omitempty" yaml:"container,omitempty"`
+
+	// Command is executed by sh in the container.
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength:=1
+	Command string `json:"command" yaml:"command"`
+
+	// TimeoutSeconds limits execution of the command.
+	//+kubebuilder:default:=60
+	//+kubebuilder:validation:Minimum:=1
+	//+kubebuilder:validation:Maximum:=3600
+	//+kubebuilder:validation:Optional
+	TimeoutSeconds uint32 `json:"timeoutSeconds,omitempty" yaml:"timeoutSeconds,omitempty"`
+}
+
 // DiskConfigStatus defines the observed state of DiskConfig
 type DiskConfigStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
@@ -113,6 +409,33 @@ type DiskConfigStatus struct {
 
 	// Conditions is a list of status of all the disks.
 	Conditions []metav1.Condition `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+
+	// Resizes is the outcome of the last resize per PVC.
+	Resizes map[string]ResizeStatus `json:"resizes,omitempty" yaml:"resizes,omitempty"`
+
+	// Rollout is the progress of the gradual resize rollout.
+	Rollout *RolloutStatus `json:"rollout,omitempty" yaml:"rollout,omitempty"`
+}
+
+// ResizeStatus defines the outcome of the last resize of a PVC
+type ResizeStatus struct {
+	// Succeeded is true if the last resize was successful.
+	Succeeded bool `json:"succeeded" yaml:"succeeded"`
+
+	// Attempts is the number of consecutive failed resizes.
+	Attempts uint32 `json:"attempts,omitempty" yaml:"attempts,omitempty"`
+
+	// LastAttemptTime is the time of the last resize.
+	LastAttemptTime metav1.Time `json:"lastAttemptTime" yaml:"lastAttemptTime"`
+}
+
+// RolloutStatus defines the start of a gradual resize rollout
+type RolloutStatus struct {
+	// Generation is the generation of the config the rollout has started with.
+	Generation int64 `json:"generation" yaml:"generation"`
+
+	// StartTime is the time the rollout has started.
+	StartTime metav1.Time `json:"startTime" yaml:"startTime"`
 }
 
 // +kubebuilder:validation:Enum=ReadWriteSame;ReadWriteOnce;ReadWriteDaemon
@@ -124,6 +447,31 @@ const (
 	ReadWriteDaemon AvailabilityMode = "ReadWriteDaemon"
 )
 
+// +kubebuilder:validation:Enum=Lazy;Eager
+type ProvisionMode string
+
+const (
+	ProvisionModeLazy  ProvisionMode = "Lazy"
+	ProvisionModeEager ProvisionMode = "Eager"
+)
+
+// +kubebuilder:validation:Enum=Sidecar;Kubelet
+type MetricsSource string
+
+const (
+	MetricsSourceSidecar MetricsSource = "Sidecar"
+	MetricsSourceKubelet MetricsSource = "Kubelet"
+)
+
+// +kubebuilder:validation:Enum=NVMeSerial;ByID;VolumeAttachment
+type DevicePathStrategy string
+
+const (
+	DevicePathStrategyNVMeSerial       DevicePathStrategy = "NVMeSerial"
+	DevicePathStrategyByID             DevicePathStrategy = "ByID"
+	DevicePathStrategyVolumeAttachment DevicePathStrategy = "VolumeAttachment"
+)
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 
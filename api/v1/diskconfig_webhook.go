@@ -19,18 +19,26 @@ package v1
 import (
 	"errors"
 	"fmt"
+	"path"
+	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/ondat/discoblocks/pkg/drivers"
 	"github.com/ondat/discoblocks/pkg/metrics"
 	"golang.org/x/net/context"
+	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
@@ -51,7 +59,84 @@ func (r *DiskConfig) SetupWebhookWithManager(mgr ctrl.Manager) error {
 		Complete()
 }
 
-//+kubebuilder:webhook:path=/validate-discoblocks-ondat-io-v1-diskconfig,mutating=false,failurePolicy=fail,sideEffects=None,groups=discoblocks.ondat.io,resources=diskconfigs,verbs=create;update,versions=v1,name=validatediskconfig.kb.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/mutate-discoblocks-ondat-io-v1-diskconfig,mutating=true,failurePolicy=fail,sideEffects=None,groups=discoblocks.ondat.io,resources=diskconfigs,verbs=create,versions=v1,name=mdiskconfig.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &DiskConfig{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *DiskConfig) Default() {
+	defaultStorageClassName(&r.Spec, diskConfigLog.WithValues("dc_name", r.Name, "namespace", r.Namespace))
+}
+
+// Annotations of the cluster default StorageClass
+const (
+	DefaultStorageClassAnnotation     = "storageclass.kubernetes.io/is-default-class"
+	BetaDefaultStorageClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+// IsDefaultStorageClass checks whether the StorageClass is annotated as the default of the cluster
+func IsDefaultStorageClass(sc *storagev1.StorageClass) bool {
+	return sc.Annotations[DefaultStorageClassAnnotation] == "true" || sc.Annotations[BetaDefaultStorageClassAnnotation] == "true"
+}
+
+// defaultStorageClassName sets StorageClass name of the spec to the cluster default if it is unset,
+// validation rejects the spec if there is no default
+func defaultStorageClassName(spec *DiskConfigSpec, logger logr.Logger) {
+	if spec.StorageClassName != "" || diskConfigWebhookDependencies == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	logger.Info("Resolve default StorageClass...")
+
+	name, err := resolveDefaultStorageClass(ctx, diskConfigWebhookDependencies.client)
+	if err != nil {
+		logger.Info("Unable to resolve default StorageClass", "error", err.Error())
+		return
+	}
+
+	logger.Info("Default StorageClass resolved", "sc_name", name)
+
+	spec.StorageClassName = name
+}
+
+// resolveDefaultStorageClass returns name of the default StorageClass of the cluster, the newest one wins if there are more.
+// Resize needs volume expansion, so not expandable default is an error.
+func resolveDefaultStorageClass(ctx context.Context, kubeClient client.Client) (string, error) {
+	scList := storagev1.StorageClassList{}
+	if err := kubeClient.List(ctx, &scList); err != nil {
+		metrics.NewError("StorageClass", "", "", "Kube API", "list")
+
+		return "", fmt.Errorf("unable to list StorageClasses: %w", err)
+	}
+
+	var defaultSC *storagev1.StorageClass
+	for i := range scList.Items {
+		sc := &scList.Items[i]
+		if !IsDefaultStorageClass(sc) {
+			continue
+		}
+
+		if defaultSC == nil || sc.CreationTimestamp.After(defaultSC.CreationTimestamp.Time) ||
+			(sc.CreationTimestamp.Equal(&defaultSC.CreationTimestamp) && sc.Name < defaultSC.Name) {
+			defaultSC = sc
+		}
+	}
+
+	if defaultSC == nil {
+		return "", errors.New("default StorageClass not found")
+	}
+
+	if defaultSC.AllowVolumeExpansion == nil || !*defaultSC.AllowVolumeExpansion {
+		return "", fmt.Errorf("default StorageClass doesn't allow volume expansion: %s", defaultSC.Name)
+	}
+
+	return defaultSC.Name, nil
+}
+
+//+kubebuilder:webhook:path=/validate-discoblocks-ondat-io-v1-diskconfig,mutating=false,failurePolicy=fail,sideEffects=None,groups=discoblocks.ondat.io,resources=diskconfigs,verbs=create;update;delete,versions=v1,name=validatediskconfig.kb.io,admissionReviewVersions=v1
 
 var _ webhook.Validator = &DiskConfig{}
 
@@ -73,7 +158,13 @@ func (r *DiskConfig) validate(old runtime.Object) error {
 
 	if r.Spec.StorageClassName == "" {
 		logger.Info("StorageClass name is invalid")
-		return errors.New("invalid StorageClass name")
+		return errors.New("invalid StorageClass name, no expandable default StorageClass found")
+	}
+
+	// Configs admitted before the check keep their empty selector, so they can still be updated and deleted
+	if oldDC, ok := old.(*DiskConfig); len(r.Spec.PodSelector) == 0 && (!ok || len(oldDC.Spec.PodSelector) != 0) {
+		logger.Info("Pod selector is empty")
+		return errors.New("invalid pod selector, empty selector matches no pods")
 	}
 
 	if r.Spec.Policy.MaximumCapacityOfDisk.CmpInt64(0) != 0 && r.Spec.Policy.MaximumCapacityOfDisk.Cmp(r.Spec.Capacity) == -1 {
@@ -81,11 +172,104 @@ func (r *DiskConfig) validate(old runtime.Object) error {
 		return errors.New("invalid new capacity, more then max")
 	}
 
-	if err := validateMountPattern(r.Spec.MountPointPattern); err != nil {
-		logger.Info("Invalid mount pattern", "error", err.Error())
+	mountPointPrefixes := []string{}
+	if diskConfigWebhookDependencies != nil {
+		mountPointPrefixes = diskConfigWebhookDependencies.mountPointPrefixes
+	}
+
+	// Mount points admitted before a check keep their pattern, so they can still be updated
+	var oldSpec *DiskConfigSpec
+	if oldDC, ok := old.(*DiskConfig); ok {
+		oldSpec = &oldDC.Spec
+	}
+
+	if oldSpec == nil || oldSpec.MountPointPattern != r.Spec.MountPointPattern {
+		if err := validateMountPattern(r.Spec.MountPointPattern, mountPointPrefixes); err != nil {
+			logger.Info("Invalid mount pattern", "error", err.Error())
+			return err
+		}
+	}
+
+	if r.Spec.Policy.MaximumNumberOfDisks > 0 && r.Spec.Policy.InitialNumberOfDisks > r.Spec.Policy.MaximumNumberOfDisks {
+		logger.Info("Initial number of disks is more then max")
+		return errors.New("invalid initial number of disks, more then max")
+	}
+
+	if r.Spec.VolumeAttributesClassName != "" {
+		if errs := validation.IsDNS1123Subdomain(r.Spec.VolumeAttributesClassName); len(errs) != 0 {
+			logger.Info("VolumeAttributesClass name is invalid", "errors", errs)
+			return fmt.Errorf("invalid VolumeAttributesClass name: %s", strings.Join(errs, ", "))
+		}
+	}
+
+	if err := validateAnnotationKeys(r.Spec.PVCAnnotations, r.Spec.PodVolumesAnnotations); err != nil {
+		logger.Info("Invalid annotations", "error", err.Error())
+		return err
+	}
+
+	if err := r.validateResizeHooks(old); err != nil {
+		logger.Info("Invalid resize hooks", "error", err.Error())
+		return err
+	}
+
+	if err := ValidateMountEnv(r.Spec.MountEnv); err != nil {
+		logger.Info("Invalid mount env", "error", err.Error())
+		return err
+	}
+
+	if _, err := r.Spec.Policy.GetUpscaleTriggerPercentage(); err != nil {
+		logger.Info("Invalid upscale trigger percentage", "error", err.Error())
+		return err
+	}
+
+	if _, err := r.Spec.Policy.GetDownscaleTriggerPercentage(); err != nil {
+		logger.Info("Invalid downscale trigger percentage", "error", err.Error())
+		return err
+	}
+
+	if err := validateDisks(&r.Spec, oldSpec, mountPointPrefixes); err != nil {
+		logger.Info("Invalid disks", "error", err.Error())
 		return err
 	}
 
+	if r.Spec.Policy.MaximumStepSize.Sign() < 0 {
+		logger.Info("Maximum step size is negative")
+		return errors.New("invalid maximum step size, must not be negative")
+	}
+
+	if r.Spec.Policy.MinimumCapacityOfDisk.Sign() < 0 {
+		logger.Info("Minimum capacity is negative")
+		return errors.New("invalid minimum capacity, must not be negative")
+	}
+
+	// Capacity is not more then max, so minimum capacity below capacity is below max too
+	if r.Spec.Policy.MinimumCapacityOfDisk.Cmp(r.Spec.Capacity) == 1 {
+		logger.Info("Minimum capacity is more then capacity")
+		return errors.New("invalid minimum capacity, more then capacity")
+	}
+
+	if r.Spec.Policy.PoolSize > 0 {
+		if r.Spec.AvailabilityMode != ReadWriteOnce {
+			logger.Info("Pool is supported only by ReadWriteOnce")
+			return errors.New("invalid pool size, pool is supported only by ReadWriteOnce availability mode")
+		}
+
+		if r.Spec.Policy.PoolTTL.Duration < time.Minute {
+			logger.Info("Pool TTL is less then a minute")
+			return errors.New("invalid pool TTL, minimum is 1 minute")
+		}
+	}
+
+	if r.Spec.ProvisionMode == ProvisionModeEager && r.Spec.AvailabilityMode == ReadWriteOnce {
+		logger.Info("Eager provisioning is not supported by ReadWriteOnce")
+		return errors.New("invalid provision mode, disks of ReadWriteOnce are unique per Pod, use pool size to provision them ahead")
+	}
+
+	if r.Spec.Policy.DetachGracePeriod.Duration > 0 && r.Spec.AvailabilityMode == ReadWriteOnce {
+		logger.Info("Detach grace period is not supported by ReadWriteOnce")
+		return errors.New("invalid detach grace period, disks of ReadWriteOnce are unique per Pod and never reused by a new Pod")
+	}
+
 	const ten = 10
 	if r.Spec.Policy.CoolDown.Duration < ten*time.Second {
 		err := fmt.Errorf("minimum cool down is %d seconds", ten)
@@ -93,6 +277,26 @@ func (r *DiskConfig) validate(old runtime.Object) error {
 		return err
 	}
 
+	if r.Spec.Policy.DetachGracePeriod.Duration < 0 {
+		logger.Info("Detach grace period is negative")
+		return errors.New("invalid detach grace period, must not be negative")
+	}
+
+	if r.Spec.Policy.PredictionHorizon.Duration < 0 {
+		logger.Info("Prediction horizon is negative")
+		return errors.New("invalid prediction horizon, must not be negative")
+	}
+
+	if r.Spec.Policy.InitialGracePeriod.Duration < 0 {
+		logger.Info("Initial grace period is negative")
+		return errors.New("invalid initial grace period, must not be negative")
+	}
+
+	if r.Spec.Policy.ResizeRolloutStepPercentage != 0 && r.Spec.Policy.ResizeRolloutWindow.Duration <= 0 {
+		logger.Info("Resize rollout window is not positive")
+		return errors.New("invalid resize rollout window, must be positive with rollout step")
+	}
+
 	if old != nil {
 		oldDC, ok := old.(*DiskConfig)
 		if !ok {
@@ -141,8 +345,8 @@ func (r *DiskConfig) validate(old runtime.Object) error {
 	logger = logger.WithValues("provisioner", sc.Provisioner)
 
 	if _, ok := diskConfigWebhookDependencies.provisioners[sc.Provisioner]; !ok {
-		logger.Info("Provisioner not supported")
-		return errors.New("provisioner not supported")
+		logger.Info("Provisioner not supported or not managed")
+		return fmt.Errorf("provisioner not supported or not managed: %s", sc.Provisioner)
 	}
 
 	driver := drivers.GetDriver(sc.Provisioner)
@@ -164,15 +368,220 @@ func (r *DiskConfig) validate(old runtime.Object) error {
 		return fmt.Errorf("invalid StorageClass: %w", err)
 	}
 
+	if len(r.Spec.Disks) != 0 {
+		fsManaged, err := driver.IsFileSystemManaged()
+		if err != nil {
+			metrics.NewError("CSI", sc.Name, "", sc.Provisioner, "IsFileSystemManaged")
+
+			logger.Error(err, "Failed to call driver", "method", "IsFileSystemManaged")
+			return fmt.Errorf("failed to call driver: %w", err)
+		}
+
+		if err := validateDiskFileSystems(r.Spec.Disks, sc.Parameters[fsTypeParameter], fsManaged); err != nil {
+			logger.Info("Invalid file-system of disks", "error", err.Error())
+			return fmt.Errorf("%w of provisioner %s", err, sc.Provisioner)
+		}
+	}
+
+	supportedModes, err := driver.GetSupportedAccessModes()
+	if err != nil {
+		metrics.NewError("CSI", sc.Name, "", sc.Provisioner, "GetSupportedAccessModes")
+
+		logger.Error(err, "Failed to call driver", "method", "GetSupportedAccessModes")
+		return fmt.Errorf("failed to call driver: %w", err)
+	}
+
+	if err := validateAccessModes(r.Spec.AccessModes, supportedModes); err != nil {
+		logger.Info("Invalid access modes", "error", err.Error())
+		return fmt.Errorf("%w of provisioner %s", err, sc.Provisioner)
+	}
+
+	if r.Spec.DevicePathStrategy != "" {
+		supportedStrategies, err := driver.GetSupportedDevicePathStrategies()
+		if err != nil {
+			metrics.NewError("CSI", sc.Name, "", sc.Provisioner, "GetSupportedDevicePathStrategies")
+
+			logger.Error(err, "Failed to call driver", "method", "GetSupportedDevicePathStrategies")
+			return fmt.Errorf("failed to call driver: %w", err)
+		}
+
+		if err := validateDevicePathStrategy(r.Spec.DevicePathStrategy, supportedStrategies); err != nil {
+			logger.Info("Invalid device path strategy", "error", err.Error())
+			return fmt.Errorf("%w of provisioner %s", err, sc.Provisioner)
+		}
+	}
+
 	return nil
 }
 
+// ForceDeleteAnnotation allows deletion of DiskConfig while its disks are in use
+const ForceDeleteAnnotation = "discoblocks.ondat.io/force-delete"
+
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
 func (r *DiskConfig) ValidateDelete() error {
+	if diskConfigWebhookDependencies == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	return r.validateDelete(ctx, diskConfigWebhookDependencies.client, diskConfigWebhookDependencies.configLabel, diskConfigWebhookDependencies.parentLabel, diskConfigWebhookDependencies.clusterConfigLabel)
+}
+
+// validateDelete denies deletion while managed PVCs are mounted by Pods, data of them would be stranded
+func (r *DiskConfig) validateDelete(ctx context.Context, kubeClient client.Client, configLabel, parentLabel, clusterConfigLabel string) error {
+	logger := diskConfigLog.WithValues("dc_name", r.Name, "namespace", r.Namespace)
+
+	logger.Info("Validate delete...")
+	defer logger.Info("Validated")
+
+	if r.Annotations[ForceDeleteAnnotation] == "true" {
+		logger.Info("Forced deletion")
+		return nil
+	}
+
+	// Rendered configs are deleted by the operator on namespace unselect and by garbage collection of their ClusterDiskConfig
+	if r.Labels[clusterConfigLabel] != "" {
+		logger.Info("Deletion of rendered config")
+		return nil
+	}
+
+	pvcList := corev1.PersistentVolumeClaimList{}
+	if err := kubeClient.List(ctx, &pvcList, client.InNamespace(r.Namespace), client.MatchingLabels{configLabel: r.Name}); err != nil {
+		metrics.NewError("PersistentVolumeClaim", "", r.Namespace, "Kube API", "list")
+
+		logger.Error(err, "Unable to list PVCs")
+		return fmt.Errorf("unable to list PVCs: %w", err)
+	}
+
+	if len(pvcList.Items) == 0 {
+		return nil
+	}
+
+	podList := corev1.PodList{}
+	if err := kubeClient.List(ctx, &podList, client.InNamespace(r.Namespace)); err != nil {
+		metrics.NewError("Pod", "", r.Namespace, "Kube API", "list")
+
+		logger.Error(err, "Unable to list Pods")
+		return fmt.Errorf("unable to list Pods: %w", err)
+	}
+
+	mountedClaims := map[string]bool{}
+	for i := range podList.Items {
+		if podList.Items[i].Status.Phase == corev1.PodSucceeded || podList.Items[i].Status.Phase == corev1.PodFailed {
+			continue
+		}
+
+		for _, volume := range podList.Items[i].Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil {
+				mountedClaims[volume.PersistentVolumeClaim.ClaimName] = true
+			}
+		}
+	}
+
+	inUse := []string{}
+	for i := range pvcList.Items {
+		pvc := &pvcList.Items[i]
+
+		// Additional disks are mounted by host Jobs, they are in use while their parent is mounted
+		if pvc.Status.Phase != corev1.ClaimBound || !mountedClaims[pvc.Name] && !mountedClaims[pvc.Labels[parentLabel]] {
+			continue
+		}
+
+		pv := corev1.PersistentVolume{}
+		if err := kubeClient.Get(ctx, types.NamespacedName{Name: pvc.Spec.VolumeName}, &pv); err != nil {
+			metrics.NewError("PersistentVolume", pvc.Spec.VolumeName, "", "Kube API", "get")
+
+			logger.Error(err, "Unable to fetch PV", "pv_name", pvc.Spec.VolumeName)
+			return fmt.Errorf("unable to fetch PV %s: %w", pvc.Spec.VolumeName, err)
+		}
+
+		if pv.Spec.PersistentVolumeReclaimPolicy == corev1.PersistentVolumeReclaimRetain {
+			continue
+		}
+
+		inUse = append(inUse, pvc.Name)
+	}
+
+	if len(inUse) != 0 {
+		sort.Strings(inUse)
+
+		logger.Info("Disks are in use", "pvcs", inUse)
+		return fmt.Errorf("disks are in use: %s, delete Pods first, set reclaim policy to Retain or annotate with %s=true", strings.Join(inUse, ", "), ForceDeleteAnnotation)
+	}
+
+	return nil
+}
+
+// deniedMountPoints are critical paths of containers, disks must not be mounted over them
+var deniedMountPoints = map[string]bool{
+	"/": true, "/bin": true, "/boot": true, "/dev": true, "/etc": true, "/home": true, "/lib": true, "/lib64": true, "/opt": true,
+	"/proc": true, "/root": true, "/run": true, "/sbin": true, "/srv": true, "/sys": true, "/tmp": true, "/usr": true, "/var": true,
+}
+
+// deniedMountPointTrees are critical paths of containers, disks must not be mounted under them
+var deniedMountPointTrees = []string{"/dev", "/etc", "/opt/discoblocks", "/proc", "/sys"}
+
+// validateResizeHooks denies resize hooks out of allowed namespaces, unchanged hooks of existing configs are left to the runtime check
+func (r *DiskConfig) validateResizeHooks(old runtime.Object) error {
+	if r.Spec.Policy.PreResizeHook == nil && r.Spec.Policy.PostResizeHook == nil || IsResizeHookAllowed(r.Namespace) {
+		return nil
+	}
+
+	if oldDC, ok := old.(*DiskConfig); ok &&
+		reflect.DeepEqual(oldDC.Spec.Policy.PreResizeHook, r.Spec.Policy.PreResizeHook) &&
+		reflect.DeepEqual(oldDC.Spec.Policy.PostResizeHook, r.Spec.Policy.PostResizeHook) {
+		return nil
+	}
+
+	return fmt.Errorf("resize hooks are not allowed in namespace %s", r.Namespace)
+}
+
+// validateAnnotationKeys checks keys of PVC annotations and Pod volumes annotations
+func validateAnnotationKeys(pvcAnnotations map[string]string, podVolumesAnnotations []string) error {
+	keys := append([]string{}, podVolumesAnnotations...)
+	for key := range pvcAnnotations {
+		keys = append(keys, key)
+	}
+
+	for _, key := range keys {
+		if errs := validation.IsQualifiedName(strings.ToLower(key)); len(errs) != 0 {
+			return fmt.Errorf("invalid annotation key %s: %s", key, strings.Join(errs, ", "))
+		}
+	}
+
 	return nil
 }
 
-func validateMountPattern(pattern string) error {
+// MountEnvPrefix is the required prefix of extra environment variables of mount and resize Jobs,
+// Jobs run privileged shells, so variables changing their behaviour (BASH_ENV, IFS, LD_PRELOAD, ...) must not be set
+const MountEnvPrefix = "DISCOBLOCKS_USER_"
+
+// ValidateMountEnv checks names of extra environment variables of mount and resize Jobs
+func ValidateMountEnv(env []corev1.EnvVar) error {
+	names := map[string]bool{}
+	for i := range env {
+		name := env[i].Name
+
+		if errs := validation.IsEnvVarName(name); len(errs) != 0 {
+			return fmt.Errorf("invalid mount env name %s: %s", name, strings.Join(errs, ", "))
+		}
+
+		if !strings.HasPrefix(name, MountEnvPrefix) || name == MountEnvPrefix {
+			return fmt.Errorf("invalid mount env name %s: must start with %s", name, MountEnvPrefix)
+		}
+
+		if names[name] {
+			return fmt.Errorf("invalid mount env, duplicated name: %s", name)
+		}
+		names[name] = true
+	}
+
+	return nil
+}
+
+func validateMountPattern(pattern string, allowedPrefixes []string) error {
 	if strings.Count(pattern, "%d") > 1 {
 		return errors.New("invalid mount pattern, only one %d allowed")
 	}
@@ -181,5 +590,187 @@ func validateMountPattern(pattern string) error {
 		return errors.New("invalid mount pattern, contains reserved characters")
 	}
 
+	if pattern == "" {
+		return nil
+	}
+
+	mountPoint := path.Clean(strings.ReplaceAll(pattern, "%d", "0"))
+
+	if deniedMountPoints[mountPoint] {
+		return fmt.Errorf("invalid mount pattern, critical path: %s", mountPoint)
+	}
+
+	for _, tree := range deniedMountPointTrees {
+		if strings.HasPrefix(mountPoint, tree+"/") {
+			return fmt.Errorf("invalid mount pattern, under critical path: %s", tree)
+		}
+	}
+
+	if len(allowedPrefixes) == 0 {
+		return nil
+	}
+
+	for _, prefix := range allowedPrefixes {
+		if strings.HasPrefix(mountPoint, strings.TrimSuffix(path.Clean(prefix), "/")+"/") {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid mount pattern, not under allowed prefixes: %s", strings.Join(allowedPrefixes, ", "))
+}
+
+// validateDisks checks disks are a complete family with distinct mount points and valid policies,
+// mount points of disks unchanged since the old spec are not checked against the critical paths and prefixes
+func validateDisks(spec, oldSpec *DiskConfigSpec, mountPointPrefixes []string) error {
+	if len(spec.Disks) == 0 {
+		return nil
+	}
+
+	if spec.Policy.InitialNumberOfDisks > 1 {
+		return errors.New("invalid initial number of disks, must be 1 with disks, others are added once the Pod runs")
+	}
+
+	if int(spec.Policy.MaximumNumberOfDisks) != len(spec.Disks) {
+		return fmt.Errorf("invalid maximum number of disks, must be the number of disks: %d", len(spec.Disks))
+	}
+
+	indexes := map[int]bool{}
+	mountPoints := map[string]int{}
+	for i := range spec.Disks {
+		index := int(spec.Disks[i].Index)
+
+		if index >= len(spec.Disks) {
+			return fmt.Errorf("invalid index of disk %d, must be below the number of disks: %d", index, len(spec.Disks))
+		} else if indexes[index] {
+			return fmt.Errorf("invalid index of disk %d, index is not unique", index)
+		}
+		indexes[index] = true
+
+		policy := spec.GetDiskPolicy(index)
+		if _, err := policy.GetDownscaleTriggerPercentage(); err != nil {
+			return fmt.Errorf("invalid policy of disk %d: %w", index, err)
+		}
+
+		capacity := spec.GetDiskCapacity(index)
+		if policy.MaximumCapacityOfDisk.CmpInt64(0) != 0 && policy.MaximumCapacityOfDisk.Cmp(capacity) == -1 {
+			return fmt.Errorf("invalid capacity of disk %d, more then max", index)
+		} else if policy.MinimumCapacityOfDisk.Cmp(capacity) == 1 {
+			return fmt.Errorf("invalid capacity of disk %d, less then min", index)
+		}
+
+		mountPoint := spec.Disks[i].MountPoint
+		if mountPoint != "" {
+			if strings.Contains(mountPoint, "%") {
+				return fmt.Errorf("invalid mount point of disk %d, %% is not allowed", index)
+			}
+
+			var oldDisk *DiskSpec
+			if oldSpec != nil {
+				oldDisk = oldSpec.GetDisk(index)
+			}
+
+			if oldDisk == nil || oldDisk.MountPoint != mountPoint {
+				if err := validateMountPattern(mountPoint, mountPointPrefixes); err != nil {
+					return fmt.Errorf("invalid mount point of disk %d: %w", index, err)
+				}
+			}
+		} else if spec.MountPointPattern != "" {
+			mountPoint = renderMountPoint(spec.MountPointPattern, index)
+		} else {
+			// Default pattern renders distinct mount points under its own directory
+			continue
+		}
+
+		mountPoint = path.Clean(mountPoint)
+		if other, ok := mountPoints[mountPoint]; ok {
+			return fmt.Errorf("invalid mount point of disk %d, disk %d has the same: %s", index, other, mountPoint)
+		}
+		mountPoints[mountPoint] = index
+	}
+
+	return nil
+}
+
+// renderMountPoint renders mount point pattern of the disk like the operator does
+func renderMountPoint(pattern string, index int) string {
+	if index != 0 && !strings.Contains(pattern, "%d") {
+		pattern += "-%d"
+	}
+
+	return strings.ReplaceAll(pattern, "%d", strconv.Itoa(index))
+}
+
+// fsTypeParameter is the StorageClass parameter of file-system type
+const fsTypeParameter = "csi.storage.k8s.io/fstype"
+
+// defaultFileSystem is the file-system of CSI volumes if StorageClass doesn't set it
+const defaultFileSystem = "ext4"
+
+// validateDiskFileSystems checks file-systems of disks against the StorageClass and the driver.
+// First disk is formatted by the CSI driver, others are formatted by Discoblocks if the driver doesn't manage file-systems.
+func validateDiskFileSystems(disks []DiskSpec, scFileSystem string, fsManaged bool) error {
+	if scFileSystem == "" {
+		scFileSystem = defaultFileSystem
+	}
+
+	for i := range disks {
+		if disks[i].FileSystem == "" {
+			continue
+		}
+
+		if disks[i].Index == 0 {
+			if !strings.EqualFold(disks[i].FileSystem, scFileSystem) {
+				return fmt.Errorf("invalid file-system of the first disk %s, StorageClass formats %q", disks[i].FileSystem, scFileSystem)
+			}
+		} else if fsManaged {
+			return fmt.Errorf("invalid file-system of disk %d, file-system is managed by the driver", disks[i].Index)
+		}
+	}
+
+	return nil
+}
+
+// validateDevicePathStrategy checks the strategy is known and supported by the driver, empty strategy selects the default of the driver
+func validateDevicePathStrategy(strategy DevicePathStrategy, supported []string) error {
+	switch strategy {
+	case "":
+		return nil
+	case DevicePathStrategyNVMeSerial, DevicePathStrategyByID, DevicePathStrategyVolumeAttachment:
+	default:
+		return fmt.Errorf("device path strategy %s is unknown", strategy)
+	}
+
+	for _, s := range supported {
+		if s == string(strategy) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("device path strategy %s is not supported, supported strategies: %v", strategy, supported)
+}
+
+func validateAccessModes(requested, supported []corev1.PersistentVolumeAccessMode) error {
+	if len(requested) == 0 {
+		requested = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	}
+
+	unsupported := []string{}
+	for _, r := range requested {
+		found := false
+		for _, s := range supported {
+			if found = r == s; found {
+				break
+			}
+		}
+
+		if !found {
+			unsupported = append(unsupported, string(r))
+		}
+	}
+
+	if len(unsupported) != 0 {
+		return fmt.Errorf("access modes %s are not supported, supported modes: %v", strings.Join(unsupported, ", "), supported)
+	}
+
 	return nil
 }
@@ -5,19 +5,49 @@ import "sigs.k8s.io/controller-runtime/pkg/client"
 var diskConfigWebhookDependencies *diskConfigWebhookDeps
 
 type diskConfigWebhookDeps struct {
-	client       client.Client
-	provisioners map[string]bool
+	client             client.Client
+	configLabel        string
+	parentLabel        string
+	clusterConfigLabel string
+	provisioners       map[string]bool
+	mountPointPrefixes []string
 }
 
-// InitDiskConfigWebhookDeps configures dependencies for webhook
-func InitDiskConfigWebhookDeps(kubeClient client.Client, provisioners []string) {
+// InitDiskConfigWebhookDeps configures dependencies for webhook, empty mount point prefixes allow any non critical path.
+// Label keys of config and parent PVC names select the managed PVCs of a config, cluster config label marks rendered configs.
+func InitDiskConfigWebhookDeps(kubeClient client.Client, configLabel, parentLabel, clusterConfigLabel string, provisioners, mountPointPrefixes []string) {
 	provisionersMap := map[string]bool{}
 	for _, p := range provisioners {
 		provisionersMap[p] = true
 	}
 
 	diskConfigWebhookDependencies = &diskConfigWebhookDeps{
-		client:       kubeClient,
-		provisioners: provisionersMap,
+		client:             kubeClient,
+		configLabel:        configLabel,
+		parentLabel:        parentLabel,
+		clusterConfigLabel: clusterConfigLabel,
+		provisioners:       provisionersMap,
+		mountPointPrefixes: mountPointPrefixes,
 	}
 }
+
+// resizeHookNamespaces allows resize hooks in these namespaces, hooks are disabled if it is empty
+var resizeHookNamespaces = map[string]bool{}
+
+// SetResizeHookNamespaces allows resize hooks in the namespaces, hooks run as root in namespaces of the container,
+// so only namespaces of trusted config editors should be listed. Empty list disables hooks.
+func SetResizeHookNamespaces(namespaces []string) {
+	allowed := map[string]bool{}
+	for _, ns := range namespaces {
+		if ns != "" {
+			allowed[ns] = true
+		}
+	}
+
+	resizeHookNamespaces = allowed
+}
+
+// IsResizeHookAllowed checks whether resize hooks are allowed in the namespace
+func IsResizeHookAllowed(namespace string) bool {
+	return resizeHookNamespaces[namespace]
+}
@@ -0,0 +1,1382 @@
+package v1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidateCapacity(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		capacity string
+		max      string
+	}{
+		"capacity above max": {
+			capacity: "2Gi",
+			max:      "1Gi",
+		},
+		"capacity far above max": {
+			capacity: "1Ti",
+			max:      "1Gi",
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			spec := DiskConfigSpec{
+				StorageClassName: "sc",
+				PodSelector:      map[string]string{"app": "nginx"},
+				Capacity:         resource.MustParse(c.capacity),
+				Policy: Policy{
+					MaximumCapacityOfDisk: resource.MustParse(c.max),
+				},
+			}
+
+			dc := DiskConfig{Spec: spec}
+			assert.NotNil(t, dc.ValidateCreate(), "DiskConfig capacity above max accepted")
+
+			cdc := ClusterDiskConfig{Spec: ClusterDiskConfigSpec{DiskConfigSpec: spec}}
+			assert.NotNil(t, cdc.ValidateCreate(), "ClusterDiskConfig capacity above max accepted")
+		})
+	}
+}
+
+func TestValidateMinimumCapacity(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		capacity      string
+		min           string
+		max           string
+		expectedError bool
+	}{
+		"below capacity": {
+			capacity: "5Gi",
+			min:      "2Gi",
+			max:      "10Gi",
+		},
+		"same as capacity": {
+			capacity: "5Gi",
+			min:      "5Gi",
+			max:      "10Gi",
+		},
+		"above capacity": {
+			capacity:      "5Gi",
+			min:           "6Gi",
+			max:           "10Gi",
+			expectedError: true,
+		},
+		"above max": {
+			capacity:      "20Gi",
+			min:           "15Gi",
+			max:           "10Gi",
+			expectedError: true,
+		},
+		"negative": {
+			capacity:      "5Gi",
+			min:           "-1Gi",
+			max:           "10Gi",
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			dc := DiskConfig{
+				Spec: DiskConfigSpec{
+					StorageClassName: "sc",
+					PodSelector:      map[string]string{"app": "nginx"},
+					Capacity:         resource.MustParse(c.capacity),
+					Policy: Policy{
+						UpscaleTriggerPercentage: intstr.FromInt(80),
+						MaximumCapacityOfDisk:    resource.MustParse(c.max),
+						MinimumCapacityOfDisk:    resource.MustParse(c.min),
+					},
+				},
+			}
+
+			err := dc.ValidateCreate()
+			if c.expectedError {
+				assert.NotNil(t, err, "invalid minimum capacity accepted")
+			} else if err != nil {
+				assert.NotContains(t, err.Error(), "minimum capacity", "valid minimum capacity rejected")
+			}
+		})
+	}
+}
+
+func TestValidatePool(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		mode          AvailabilityMode
+		size          uint8
+		ttl           time.Duration
+		expectedError bool
+	}{
+		"disabled": {
+			mode: ReadWriteSame,
+		},
+		"ReadWriteOnce": {
+			mode: ReadWriteOnce,
+			size: 3,
+			ttl:  time.Hour,
+		},
+		"ReadWriteSame": {
+			mode:          ReadWriteSame,
+			size:          3,
+			ttl:           time.Hour,
+			expectedError: true,
+		},
+		"ReadWriteDaemon": {
+			mode:          ReadWriteDaemon,
+			size:          3,
+			ttl:           time.Hour,
+			expectedError: true,
+		},
+		"short TTL": {
+			mode:          ReadWriteOnce,
+			size:          3,
+			ttl:           time.Second,
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			dc := DiskConfig{
+				Spec: DiskConfigSpec{
+					StorageClassName: "sc",
+					PodSelector:      map[string]string{"app": "nginx"},
+					Capacity:         resource.MustParse("1Gi"),
+					AvailabilityMode: c.mode,
+					Policy: Policy{
+						UpscaleTriggerPercentage: intstr.FromInt(80),
+						MaximumCapacityOfDisk:    resource.MustParse("10Gi"),
+						PoolSize:                 c.size,
+						PoolTTL:                  metav1.Duration{Duration: c.ttl},
+					},
+				},
+			}
+
+			err := dc.ValidateCreate()
+			if c.expectedError {
+				assert.NotNil(t, err, "invalid pool accepted")
+			} else if err != nil {
+				assert.NotContains(t, err.Error(), "pool", "valid pool rejected")
+			}
+		})
+	}
+}
+
+func TestValidateProvisionMode(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		provisionMode ProvisionMode
+		mode          AvailabilityMode
+		expectedError bool
+	}{
+		"lazy": {
+			provisionMode: ProvisionModeLazy,
+			mode:          ReadWriteOnce,
+		},
+		"eager ReadWriteSame": {
+			provisionMode: ProvisionModeEager,
+			mode:          ReadWriteSame,
+		},
+		"eager ReadWriteDaemon": {
+			provisionMode: ProvisionModeEager,
+			mode:          ReadWriteDaemon,
+		},
+		"eager ReadWriteOnce": {
+			provisionMode: ProvisionModeEager,
+			mode:          ReadWriteOnce,
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			dc := DiskConfig{
+				Spec: DiskConfigSpec{
+					StorageClassName: "sc",
+					PodSelector:      map[string]string{"app": "nginx"},
+					Capacity:         resource.MustParse("1Gi"),
+					AvailabilityMode: c.mode,
+					ProvisionMode:    c.provisionMode,
+					Policy: Policy{
+						UpscaleTriggerPercentage: intstr.FromInt(80),
+						MaximumCapacityOfDisk:    resource.MustParse("10Gi"),
+					},
+				},
+			}
+
+			err := dc.ValidateCreate()
+			if c.expectedError {
+				assert.NotNil(t, err, "invalid provision mode accepted")
+			} else if err != nil {
+				assert.NotContains(t, err.Error(), "provision mode", "valid provision mode rejected")
+			}
+		})
+	}
+}
+
+func TestValidateDetachGracePeriod(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		grace         time.Duration
+		mode          AvailabilityMode
+		expectedError bool
+	}{
+		"disabled ReadWriteOnce": {
+			mode: ReadWriteOnce,
+		},
+		"ReadWriteSame": {
+			grace: time.Minute,
+			mode:  ReadWriteSame,
+		},
+		"ReadWriteDaemon": {
+			grace: time.Minute,
+			mode:  ReadWriteDaemon,
+		},
+		"ReadWriteOnce": {
+			grace:         time.Minute,
+			mode:          ReadWriteOnce,
+			expectedError: true,
+		},
+		"negative": {
+			grace:         -time.Minute,
+			mode:          ReadWriteSame,
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			dc := DiskConfig{
+				Spec: DiskConfigSpec{
+					StorageClassName: "sc",
+					PodSelector:      map[string]string{"app": "nginx"},
+					Capacity:         resource.MustParse("1Gi"),
+					AvailabilityMode: c.mode,
+					Policy: Policy{
+						UpscaleTriggerPercentage: intstr.FromInt(80),
+						MaximumCapacityOfDisk:    resource.MustParse("10Gi"),
+						DetachGracePeriod:        metav1.Duration{Duration: c.grace},
+					},
+				},
+			}
+
+			err := dc.ValidateCreate()
+			if c.expectedError {
+				assert.NotNil(t, err, "invalid detach grace period accepted")
+			} else if err != nil {
+				assert.NotContains(t, err.Error(), "detach grace period", "valid detach grace period rejected")
+			}
+		})
+	}
+}
+
+func TestValidateResizeRollout(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		step          uint8
+		window        time.Duration
+		expectedError bool
+	}{
+		"static": {},
+		"stepped": {
+			step:   10,
+			window: time.Hour,
+		},
+		"without window": {
+			step:          10,
+			expectedError: true,
+		},
+		"negative window": {
+			step:          10,
+			window:        -time.Hour,
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			dc := DiskConfig{
+				Spec: DiskConfigSpec{
+					StorageClassName: "sc",
+					PodSelector:      map[string]string{"app": "nginx"},
+					Capacity:         resource.MustParse("1Gi"),
+					AvailabilityMode: ReadWriteSame,
+					Policy: Policy{
+						UpscaleTriggerPercentage:    intstr.FromInt(80),
+						MaximumCapacityOfDisk:       resource.MustParse("10Gi"),
+						CoolDown:                    metav1.Duration{Duration: time.Minute},
+						ResizeRolloutPercentage:     10,
+						ResizeRolloutStepPercentage: c.step,
+						ResizeRolloutWindow:         metav1.Duration{Duration: c.window},
+					},
+				},
+			}
+
+			err := dc.ValidateCreate()
+			if c.expectedError {
+				if assert.NotNil(t, err, "invalid resize rollout accepted") {
+					assert.Contains(t, err.Error(), "resize rollout window", "invalid error")
+				}
+			} else if err != nil {
+				assert.NotContains(t, err.Error(), "resize rollout window", "valid resize rollout rejected")
+			}
+		})
+	}
+}
+
+func TestValidateDelete(t *testing.T) {
+	t.Parallel()
+
+	pvc := func(name, parent, pvName string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{"discoblocks": "config", "discoblocks-parent": parent},
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				VolumeName: pvName,
+			},
+			Status: corev1.PersistentVolumeClaimStatus{
+				Phase: corev1.ClaimBound,
+			},
+		}
+	}
+
+	pv := func(name string, policy corev1.PersistentVolumeReclaimPolicy) *corev1.PersistentVolume {
+		return &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Spec: corev1.PersistentVolumeSpec{
+				PersistentVolumeReclaimPolicy: policy,
+			},
+		}
+	}
+
+	pod := func(phase corev1.PodPhase, claimName string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pod",
+				Namespace: "default",
+			},
+			Spec: corev1.PodSpec{
+				Volumes: []corev1.Volume{{
+					Name: "disk",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+					},
+				}},
+			},
+			Status: corev1.PodStatus{
+				Phase: phase,
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		objects       []client.Object
+		force         bool
+		rendered      bool
+		expectedError string
+	}{
+		"no disks": {},
+		"unused disk": {
+			objects: []client.Object{pvc("pvc-0", "pvc-0", "pv-0"), pv("pv-0", corev1.PersistentVolumeReclaimDelete)},
+		},
+		"in use": {
+			objects:       []client.Object{pvc("pvc-0", "pvc-0", "pv-0"), pv("pv-0", corev1.PersistentVolumeReclaimDelete), pod(corev1.PodRunning, "pvc-0")},
+			expectedError: "disks are in use: pvc-0,",
+		},
+		"additional disk in use": {
+			objects: []client.Object{
+				pvc("pvc-0", "pvc-0", "pv-0"), pv("pv-0", corev1.PersistentVolumeReclaimRetain),
+				pvc("pvc-1", "pvc-0", "pv-1"), pv("pv-1", corev1.PersistentVolumeReclaimDelete),
+				pod(corev1.PodRunning, "pvc-0"),
+			},
+			expectedError: "disks are in use: pvc-1,",
+		},
+		"finished Pod": {
+			objects: []client.Object{pvc("pvc-0", "pvc-0", "pv-0"), pv("pv-0", corev1.PersistentVolumeReclaimDelete), pod(corev1.PodSucceeded, "pvc-0")},
+		},
+		"retained": {
+			objects: []client.Object{pvc("pvc-0", "pvc-0", "pv-0"), pv("pv-0", corev1.PersistentVolumeReclaimRetain), pod(corev1.PodRunning, "pvc-0")},
+		},
+		"forced": {
+			objects: []client.Object{pvc("pvc-0", "pvc-0", "pv-0"), pv("pv-0", corev1.PersistentVolumeReclaimDelete), pod(corev1.PodRunning, "pvc-0")},
+			force:   true,
+		},
+		"rendered by cluster config": {
+			objects:  []client.Object{pvc("pvc-0", "pvc-0", "pv-0"), pv("pv-0", corev1.PersistentVolumeReclaimDelete), pod(corev1.PodRunning, "pvc-0")},
+			rendered: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			dc := DiskConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "config",
+					Namespace: "default",
+				},
+			}
+			if c.force {
+				dc.Annotations = map[string]string{ForceDeleteAnnotation: "true"}
+			}
+			if c.rendered {
+				dc.Labels = map[string]string{"discoblocks/cluster-config": "config"}
+			}
+
+			kubeClient := fake.NewClientBuilder().WithObjects(c.objects...).Build()
+
+			err := dc.validateDelete(context.Background(), kubeClient, "discoblocks", "discoblocks-parent", "discoblocks/cluster-config")
+			if c.expectedError == "" {
+				assert.Nil(t, err, "valid deletion denied")
+			} else if assert.NotNil(t, err, "invalid deletion allowed") {
+				assert.Contains(t, err.Error(), c.expectedError, "invalid in-use PVCs")
+			}
+		})
+	}
+}
+
+func TestValidateAccessModes(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		requested     []corev1.PersistentVolumeAccessMode
+		supported     []corev1.PersistentVolumeAccessMode
+		expectedError bool
+	}{
+		"default": {
+			supported: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+		},
+		"compatible": {
+			requested: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce, corev1.ReadWriteMany},
+			supported: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce, corev1.ReadWriteMany},
+		},
+		"incompatible": {
+			requested:     []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+			supported:     []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			expectedError: true,
+		},
+		"partially compatible": {
+			requested:     []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce, corev1.ReadOnlyMany},
+			supported:     []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			expectedError: true,
+		},
+		"default not supported": {
+			supported:     []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateAccessModes(c.requested, c.supported)
+
+			assert.Equal(t, c.expectedError, err != nil, "invalid validation")
+		})
+	}
+}
+
+func TestValidateDevicePathStrategy(t *testing.T) {
+	t.Parallel()
+
+	ebsStrategies := []string{"NVMeSerial", "ByID", "VolumeAttachment"}
+
+	cases := map[string]struct {
+		strategy      DevicePathStrategy
+		supported     []string
+		expectedError bool
+	}{
+		"default": {
+			supported: []string{},
+		},
+		"supported": {
+			strategy:  DevicePathStrategyByID,
+			supported: ebsStrategies,
+		},
+		"not supported": {
+			strategy:      DevicePathStrategyVolumeAttachment,
+			supported:     []string{},
+			expectedError: true,
+		},
+		"unknown": {
+			strategy:      DevicePathStrategy("ByLabel"),
+			supported:     append(ebsStrategies, "ByLabel"),
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateDevicePathStrategy(c.strategy, c.supported)
+
+			assert.Equal(t, c.expectedError, err != nil, "invalid validation")
+		})
+	}
+}
+
+func TestValidateInitialNumberOfDisks(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		initial uint8
+		max     uint8
+	}{
+		"initial above max": {
+			initial: 3,
+			max:     2,
+		},
+		"initial far above max": {
+			initial: 150,
+			max:     1,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			dc := DiskConfig{
+				Spec: DiskConfigSpec{
+					StorageClassName: "sc",
+					PodSelector:      map[string]string{"app": "nginx"},
+					Capacity:         resource.MustParse("1Gi"),
+					Policy: Policy{
+						MaximumCapacityOfDisk: resource.MustParse("2Gi"),
+						MaximumNumberOfDisks:  c.max,
+						InitialNumberOfDisks:  c.initial,
+					},
+				},
+			}
+
+			assert.NotNil(t, dc.ValidateCreate(), "DiskConfig initial number of disks above max accepted")
+		})
+	}
+}
+
+func TestValidateMountPattern(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		pattern         string
+		allowedPrefixes []string
+		expectedError   bool
+	}{
+		"default": {
+			pattern: "",
+		},
+		"root": {
+			pattern:       "/",
+			expectedError: true,
+		},
+		"etc": {
+			pattern:       "/etc",
+			expectedError: true,
+		},
+		"under etc": {
+			pattern:       "/etc/data-%d",
+			expectedError: true,
+		},
+		"traversal": {
+			pattern:       "/data//../var/",
+			expectedError: true,
+		},
+		"under proc": {
+			pattern:       "/proc/data",
+			expectedError: true,
+		},
+		"data": {
+			pattern: "/data",
+		},
+		"data with index": {
+			pattern: "/data/disk-%d",
+		},
+		"under var": {
+			pattern: "/var/lib/data",
+		},
+		"allowed prefix": {
+			pattern:         "/data/disk-%d",
+			allowedPrefixes: []string{"/media", "/data/"},
+		},
+		"prefix itself": {
+			pattern:         "/data",
+			allowedPrefixes: []string{"/data"},
+			expectedError:   true,
+		},
+		"not allowed prefix": {
+			pattern:         "/srv/data",
+			allowedPrefixes: []string{"/data"},
+			expectedError:   true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateMountPattern(c.pattern, c.allowedPrefixes)
+			assert.Equal(t, c.expectedError, err != nil, "invalid mount pattern validation")
+		})
+	}
+}
+
+func TestValidatePodSelector(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]map[string]string{
+		"nil selector":   nil,
+		"empty selector": {},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			dc := DiskConfig{
+				Spec: DiskConfigSpec{
+					StorageClassName: "sc",
+					PodSelector:      c,
+				},
+			}
+
+			assert.NotNil(t, dc.ValidateCreate(), "DiskConfig empty pod selector accepted")
+
+			selected := DiskConfig{
+				Spec: DiskConfigSpec{
+					StorageClassName: "sc",
+					PodSelector:      map[string]string{"app": "nginx"},
+				},
+			}
+
+			assert.NotNil(t, dc.ValidateUpdate(&selected), "DiskConfig pod selector emptied")
+
+			err := dc.ValidateUpdate(dc.DeepCopy())
+			if err != nil {
+				assert.NotContains(t, err.Error(), "pod selector", "DiskConfig with existing empty pod selector rejected")
+			}
+		})
+	}
+}
+
+func TestValidateExistingMountPattern(t *testing.T) {
+	t.Parallel()
+
+	dc := DiskConfig{
+		Spec: DiskConfigSpec{
+			StorageClassName:  "sc",
+			MountPointPattern: "/etc/data-%d",
+			PodSelector:       map[string]string{"app": "nginx"},
+		},
+	}
+
+	err := dc.ValidateCreate()
+	if assert.NotNil(t, err, "DiskConfig critical mount pattern accepted") {
+		assert.Contains(t, err.Error(), "mount pattern", "invalid error")
+	}
+
+	err = dc.ValidateUpdate(dc.DeepCopy())
+	if err != nil {
+		assert.NotContains(t, err.Error(), "mount pattern", "DiskConfig with existing mount pattern rejected")
+	}
+}
+
+func TestGetUpscaleTriggerPercentage(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		trigger       intstr.IntOrString
+		expectedError bool
+		expected      float64
+	}{
+		"integer": {
+			trigger:  intstr.FromInt(80),
+			expected: 80,
+		},
+		"integer string": {
+			trigger:  intstr.FromString("80"),
+			expected: 80,
+		},
+		"fractional": {
+			trigger:  intstr.FromString("92.5"),
+			expected: 92.5,
+		},
+		"fractional with percent sign": {
+			trigger:  intstr.FromString("99.95%"),
+			expected: 99.95,
+		},
+		"maximum": {
+			trigger:  intstr.FromInt(100),
+			expected: 100,
+		},
+		"zero": {
+			trigger:       intstr.FromInt(0),
+			expectedError: true,
+		},
+		"above maximum": {
+			trigger:       intstr.FromString("100.5"),
+			expectedError: true,
+		},
+		"invalid": {
+			trigger:       intstr.FromString("high"),
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			policy := Policy{UpscaleTriggerPercentage: c.trigger}
+
+			trigger, err := policy.GetUpscaleTriggerPercentage()
+			if c.expectedError {
+				assert.NotNil(t, err, "invalid trigger accepted")
+
+				dc := DiskConfig{
+					Spec: DiskConfigSpec{
+						StorageClassName: "sc",
+						PodSelector:      map[string]string{"app": "nginx"},
+						Policy:           policy,
+					},
+				}
+				assert.NotNil(t, dc.ValidateCreate(), "DiskConfig invalid trigger accepted")
+				return
+			}
+
+			assert.Nil(t, err, "valid trigger rejected")
+			assert.Equal(t, c.expected, trigger, "invalid trigger")
+		})
+	}
+}
+
+func TestValidateZeroUpscaleTrigger(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		trigger     intstr.IntOrString
+		diskTrigger *intstr.IntOrString
+	}{
+		"integer": {
+			trigger: intstr.FromInt(0),
+		},
+		"string": {
+			trigger: intstr.FromString("0"),
+		},
+		"fractional with percent sign": {
+			trigger: intstr.FromString("0.0%"),
+		},
+		"unset": {
+			trigger: intstr.IntOrString{},
+		},
+		"disk": {
+			trigger:     intstr.FromInt(80),
+			diskTrigger: &intstr.IntOrString{},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			spec := DiskConfigSpec{
+				StorageClassName: "sc",
+				PodSelector:      map[string]string{"app": "nginx"},
+				Policy: Policy{
+					UpscaleTriggerPercentage: c.trigger,
+				},
+			}
+			if c.diskTrigger != nil {
+				spec.Policy.MaximumNumberOfDisks = 1
+				spec.Disks = []DiskSpec{{Policy: &DiskPolicy{UpscaleTriggerPercentage: c.diskTrigger}}}
+			}
+
+			dc := DiskConfig{Spec: spec}
+			err := dc.ValidateCreate()
+			if assert.NotNil(t, err, "DiskConfig zero trigger accepted") {
+				assert.Contains(t, err.Error(), "invalid upscale trigger percentage", "DiskConfig rejected by other reason")
+			}
+
+			cdc := ClusterDiskConfig{Spec: ClusterDiskConfigSpec{DiskConfigSpec: spec}}
+			err = cdc.ValidateCreate()
+			if assert.NotNil(t, err, "ClusterDiskConfig zero trigger accepted") {
+				assert.Contains(t, err.Error(), "invalid upscale trigger percentage", "ClusterDiskConfig rejected by other reason")
+			}
+		})
+	}
+}
+
+func TestGetDownscaleTriggerPercentage(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		upscale       intstr.IntOrString
+		downscale     *intstr.IntOrString
+		expectedError bool
+		expected      float64
+	}{
+		"default": {
+			upscale:  intstr.FromInt(80),
+			expected: 70,
+		},
+		"default of low upscale": {
+			upscale:  intstr.FromInt(5),
+			expected: 0,
+		},
+		"integer": {
+			upscale:   intstr.FromInt(80),
+			downscale: &intstr.IntOrString{Type: intstr.Int, IntVal: 50},
+			expected:  50,
+		},
+		"fractional": {
+			upscale:   intstr.FromString("92.5"),
+			downscale: &intstr.IntOrString{Type: intstr.String, StrVal: "62.5%"},
+			expected:  62.5,
+		},
+		"zero": {
+			upscale:   intstr.FromInt(80),
+			downscale: &intstr.IntOrString{Type: intstr.Int, IntVal: 0},
+			expected:  0,
+		},
+		"equal to upscale": {
+			upscale:       intstr.FromInt(80),
+			downscale:     &intstr.IntOrString{Type: intstr.Int, IntVal: 80},
+			expectedError: true,
+		},
+		"above upscale": {
+			upscale:       intstr.FromInt(80),
+			downscale:     &intstr.IntOrString{Type: intstr.String, StrVal: "90"},
+			expectedError: true,
+		},
+		"invalid": {
+			upscale:       intstr.FromInt(80),
+			downscale:     &intstr.IntOrString{Type: intstr.String, StrVal: "low"},
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			policy := Policy{UpscaleTriggerPercentage: c.upscale, DownscaleTriggerPercentage: c.downscale}
+
+			trigger, err := policy.GetDownscaleTriggerPercentage()
+			if c.expectedError {
+				assert.NotNil(t, err, "invalid trigger accepted")
+
+				dc := DiskConfig{
+					Spec: DiskConfigSpec{
+						StorageClassName: "sc",
+						PodSelector:      map[string]string{"app": "nginx"},
+						Policy:           policy,
+					},
+				}
+				assert.NotNil(t, dc.ValidateCreate(), "DiskConfig invalid trigger accepted")
+				return
+			}
+
+			assert.Nil(t, err, "valid trigger rejected")
+			assert.Equal(t, c.expected, trigger, "invalid trigger")
+		})
+	}
+}
+
+func TestGetDiskPolicy(t *testing.T) {
+	t.Parallel()
+
+	xfsTrigger := intstr.FromInt(90)
+	xfsExtend := resource.MustParse("5Gi")
+	ext4Trigger := intstr.FromString("50")
+
+	spec := DiskConfigSpec{
+		Capacity: resource.MustParse("1Gi"),
+		Policy: Policy{
+			UpscaleTriggerPercentage: intstr.FromInt(80),
+			ExtendCapacity:           resource.MustParse("1Gi"),
+			MaximumCapacityOfDisk:    resource.MustParse("100Gi"),
+		},
+		Disks: []DiskSpec{
+			{
+				Index:      0,
+				FileSystem: "xfs",
+				Policy: &DiskPolicy{
+					UpscaleTriggerPercentage: &xfsTrigger,
+					ExtendCapacity:           &xfsExtend,
+				},
+			},
+			{
+				Index:      1,
+				FileSystem: "ext4",
+				Capacity:   resourcePtr("10Gi"),
+				Policy: &DiskPolicy{
+					UpscaleTriggerPercentage: &ext4Trigger,
+				},
+			},
+		},
+	}
+
+	cases := map[string]struct {
+		index            int
+		used             float64
+		expectedScale    bool
+		expectedExtend   string
+		expectedCapacity string
+	}{
+		"xfs below own trigger": {
+			index:            0,
+			used:             85,
+			expectedScale:    false,
+			expectedExtend:   "5Gi",
+			expectedCapacity: "1Gi",
+		},
+		"xfs above own trigger": {
+			index:            0,
+			used:             95,
+			expectedScale:    true,
+			expectedExtend:   "5Gi",
+			expectedCapacity: "1Gi",
+		},
+		"ext4 above own trigger": {
+			index:            1,
+			used:             60,
+			expectedScale:    true,
+			expectedExtend:   "1Gi",
+			expectedCapacity: "10Gi",
+		},
+		"not listed falls back": {
+			index:            2,
+			used:             60,
+			expectedScale:    false,
+			expectedExtend:   "1Gi",
+			expectedCapacity: "1Gi",
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			policy := spec.GetDiskPolicy(c.index)
+
+			trigger, err := policy.GetUpscaleTriggerPercentage()
+			assert.Nil(t, err, "valid trigger rejected")
+			assert.Equal(t, c.expectedScale, c.used >= trigger, "invalid scale decision")
+			assert.Equal(t, c.expectedExtend, policy.ExtendCapacity.String(), "invalid extend capacity")
+
+			capacity := spec.GetDiskCapacity(c.index)
+			assert.Equal(t, c.expectedCapacity, capacity.String(), "invalid capacity")
+		})
+	}
+
+	assert.Equal(t, intstr.FromInt(80), spec.Policy.UpscaleTriggerPercentage, "policy of the config modified")
+}
+
+func TestValidateDisks(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		pattern       string
+		initial       uint8
+		max           uint8
+		disks         []DiskSpec
+		oldDisks      []DiskSpec
+		expectedError bool
+	}{
+		"default pattern": {
+			initial: 1,
+			max:     2,
+			disks:   []DiskSpec{{Index: 0, FileSystem: "xfs"}, {Index: 1, FileSystem: "ext4"}},
+		},
+		"own mount points": {
+			pattern: "/data",
+			initial: 1,
+			max:     2,
+			disks:   []DiskSpec{{Index: 0}, {Index: 1, MountPoint: "/logs"}},
+		},
+		"more initial disks": {
+			initial:       2,
+			max:           2,
+			disks:         []DiskSpec{{Index: 0}, {Index: 1}},
+			expectedError: true,
+		},
+		"max differs": {
+			initial:       1,
+			max:           3,
+			disks:         []DiskSpec{{Index: 0}, {Index: 1}},
+			expectedError: true,
+		},
+		"index out of range": {
+			initial:       1,
+			max:           2,
+			disks:         []DiskSpec{{Index: 0}, {Index: 2}},
+			expectedError: true,
+		},
+		"duplicated index": {
+			initial:       1,
+			max:           2,
+			disks:         []DiskSpec{{Index: 1}, {Index: 1}},
+			expectedError: true,
+		},
+		"same mount point": {
+			pattern:       "/data",
+			initial:       1,
+			max:           2,
+			disks:         []DiskSpec{{Index: 0}, {Index: 1, MountPoint: "/data"}},
+			expectedError: true,
+		},
+		"pattern in mount point": {
+			initial:       1,
+			max:           2,
+			disks:         []DiskSpec{{Index: 0}, {Index: 1, MountPoint: "/data-%d"}},
+			expectedError: true,
+		},
+		"critical mount point": {
+			initial:       1,
+			max:           2,
+			disks:         []DiskSpec{{Index: 0}, {Index: 1, MountPoint: "/etc/logs"}},
+			expectedError: true,
+		},
+		"unchanged critical mount point": {
+			initial:  1,
+			max:      2,
+			disks:    []DiskSpec{{Index: 0}, {Index: 1, MountPoint: "/etc/logs"}},
+			oldDisks: []DiskSpec{{Index: 0}, {Index: 1, MountPoint: "/etc/logs"}},
+		},
+		"changed critical mount point": {
+			initial:       1,
+			max:           2,
+			disks:         []DiskSpec{{Index: 0}, {Index: 1, MountPoint: "/etc/logs"}},
+			oldDisks:      []DiskSpec{{Index: 0}, {Index: 1, MountPoint: "/logs"}},
+			expectedError: true,
+		},
+		"capacity above max": {
+			initial:       1,
+			max:           2,
+			disks:         []DiskSpec{{Index: 0}, {Index: 1, Capacity: resourcePtr("2Ti")}},
+			expectedError: true,
+		},
+		"capacity below min": {
+			initial:       1,
+			max:           2,
+			disks:         []DiskSpec{{Index: 0}, {Index: 1, Capacity: resourcePtr("100Mi")}},
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			spec := DiskConfigSpec{
+				Capacity:          resource.MustParse("1Gi"),
+				MountPointPattern: c.pattern,
+				Policy: Policy{
+					UpscaleTriggerPercentage: intstr.FromInt(80),
+					MaximumCapacityOfDisk:    resource.MustParse("1Ti"),
+					MinimumCapacityOfDisk:    resource.MustParse("500Mi"),
+					InitialNumberOfDisks:     c.initial,
+					MaximumNumberOfDisks:     c.max,
+				},
+				Disks: c.disks,
+			}
+
+			var oldSpec *DiskConfigSpec
+			if c.oldDisks != nil {
+				oldSpec = &DiskConfigSpec{Disks: c.oldDisks}
+			}
+
+			err := validateDisks(&spec, oldSpec, nil)
+			if c.expectedError {
+				assert.NotNil(t, err, "invalid disks accepted")
+			} else {
+				assert.Nil(t, err, "valid disks rejected")
+			}
+		})
+	}
+}
+
+func TestValidateDiskFileSystems(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		disks         []DiskSpec
+		scFileSystem  string
+		fsManaged     bool
+		expectedError bool
+	}{
+		"mixed file-systems": {
+			disks:        []DiskSpec{{Index: 0, FileSystem: "ext4"}, {Index: 1, FileSystem: "xfs"}},
+			scFileSystem: "EXT4",
+		},
+		"default file-system of StorageClass": {
+			disks: []DiskSpec{{Index: 0, FileSystem: "ext4"}, {Index: 1, FileSystem: "xfs"}},
+		},
+		"first disk differs from default": {
+			disks:         []DiskSpec{{Index: 0, FileSystem: "xfs"}},
+			expectedError: true,
+		},
+		"first disk differs": {
+			disks:         []DiskSpec{{Index: 0, FileSystem: "xfs"}},
+			scFileSystem:  "ext4",
+			expectedError: true,
+		},
+		"managed by driver": {
+			disks:         []DiskSpec{{Index: 0}, {Index: 1, FileSystem: "xfs"}},
+			scFileSystem:  "ext4",
+			fsManaged:     true,
+			expectedError: true,
+		},
+		"inherited with managed driver": {
+			disks:        []DiskSpec{{Index: 0}, {Index: 1}},
+			scFileSystem: "ext4",
+			fsManaged:    true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateDiskFileSystems(c.disks, c.scFileSystem, c.fsManaged)
+			if c.expectedError {
+				assert.NotNil(t, err, "invalid file-systems accepted")
+			} else {
+				assert.Nil(t, err, "valid file-systems rejected")
+			}
+		})
+	}
+}
+
+func resourcePtr(quantity string) *resource.Quantity {
+	q := resource.MustParse(quantity)
+	return &q
+}
+
+func TestResolveDefaultStorageClass(t *testing.T) {
+	t.Parallel()
+
+	expandable := true
+	notExpandable := false
+
+	sc := func(name string, created time.Time, annotation string, expansion *bool) *storagev1.StorageClass {
+		sc := storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Provisioner:          "ebs.csi.aws.com",
+			AllowVolumeExpansion: expansion,
+		}
+		if annotation != "" {
+			sc.Annotations = map[string]string{annotation: "true"}
+		}
+
+		return &sc
+	}
+
+	now := time.Now().Truncate(time.Second)
+
+	cases := map[string]struct {
+		objects       []client.Object
+		expectedName  string
+		expectedError bool
+	}{
+		"default": {
+			objects: []client.Object{
+				sc("other", now, "", &expandable),
+				sc("default", now, DefaultStorageClassAnnotation, &expandable),
+			},
+			expectedName: "default",
+		},
+		"beta default": {
+			objects:      []client.Object{sc("default", now, BetaDefaultStorageClassAnnotation, &expandable)},
+			expectedName: "default",
+		},
+		"newest default wins": {
+			objects: []client.Object{
+				sc("old", now.Add(-time.Hour), DefaultStorageClassAnnotation, &expandable),
+				sc("new", now, DefaultStorageClassAnnotation, &expandable),
+			},
+			expectedName: "new",
+		},
+		"no default": {
+			objects:       []client.Object{sc("other", now, "", &expandable)},
+			expectedError: true,
+		},
+		"not expandable default": {
+			objects:       []client.Object{sc("default", now, DefaultStorageClassAnnotation, &notExpandable)},
+			expectedError: true,
+		},
+		"expansion unset": {
+			objects:       []client.Object{sc("default", now, DefaultStorageClassAnnotation, nil)},
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			kubeClient := fake.NewClientBuilder().WithObjects(c.objects...).Build()
+
+			name, err := resolveDefaultStorageClass(context.Background(), kubeClient)
+			assert.Equal(t, c.expectedError, err != nil, "invalid error")
+			assert.Equal(t, c.expectedName, name, "invalid StorageClass")
+		})
+	}
+}
+
+func TestDefaultStorageClassName(t *testing.T) {
+	expandable := true
+
+	kubeClient := fake.NewClientBuilder().WithObjects(&storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "default",
+			Annotations: map[string]string{DefaultStorageClassAnnotation: "true"},
+		},
+		Provisioner:          "ebs.csi.aws.com",
+		AllowVolumeExpansion: &expandable,
+	}).Build()
+
+	deps := diskConfigWebhookDependencies
+	t.Cleanup(func() {
+		diskConfigWebhookDependencies = deps
+	})
+	InitDiskConfigWebhookDeps(kubeClient, "discoblocks", "discoblocks-parent", "discoblocks/cluster-config", []string{"ebs.csi.aws.com"}, nil)
+
+	spec := DiskConfigSpec{}
+	defaultStorageClassName(&spec, logr.Discard())
+	assert.Equal(t, "default", spec.StorageClassName, "unset StorageClass not resolved")
+
+	spec = DiskConfigSpec{StorageClassName: "custom"}
+	defaultStorageClassName(&spec, logr.Discard())
+	assert.Equal(t, "custom", spec.StorageClassName, "StorageClass overridden")
+}
+
+func TestValidateResizeHooks(t *testing.T) {
+	SetResizeHookNamespaces([]string{"trusted"})
+
+	hook := &ResizeHook{Command: "sync"}
+
+	cases := map[string]struct {
+		namespace     string
+		hook          *ResizeHook
+		oldHook       *ResizeHook
+		update        bool
+		expectedError bool
+	}{
+		"no hook": {
+			namespace: "default",
+		},
+		"allowed namespace": {
+			namespace: "trusted",
+			hook:      hook,
+		},
+		"denied namespace": {
+			namespace:     "default",
+			hook:          hook,
+			expectedError: true,
+		},
+		"unchanged hook of denied namespace": {
+			namespace: "default",
+			hook:      hook,
+			oldHook:   hook,
+			update:    true,
+		},
+		"changed hook of denied namespace": {
+			namespace:     "default",
+			hook:          &ResizeHook{Command: "rm -rf /"},
+			oldHook:       hook,
+			update:        true,
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			dc := DiskConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: c.namespace,
+				},
+				Spec: DiskConfigSpec{
+					Policy: Policy{
+						PreResizeHook: c.hook,
+					},
+				},
+			}
+
+			var old runtime.Object
+			if c.update {
+				oldDC := dc.DeepCopy()
+				oldDC.Spec.Policy.PreResizeHook = c.oldHook
+				old = oldDC
+			}
+
+			err := dc.validateResizeHooks(old)
+			assert.Equal(t, c.expectedError, err != nil, "invalid validation")
+		})
+	}
+}
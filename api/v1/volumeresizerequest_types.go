@@ -0,0 +1,110 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VolumeResizeRequestPhase is the phase of a resize request
+// +kubebuilder:validation:Enum=Pending;Applied;Completed;Failed
+type VolumeResizeRequestPhase string
+
+const (
+	// VolumeResizeRequestPending means the resize has been decided but not applied yet
+	VolumeResizeRequestPending VolumeResizeRequestPhase = "Pending"
+	// VolumeResizeRequestApplied means the PVC has been updated, volume expansion is in progress
+	VolumeResizeRequestApplied VolumeResizeRequestPhase = "Applied"
+	// VolumeResizeRequestCompleted means the volume has reached the requested capacity
+	VolumeResizeRequestCompleted VolumeResizeRequestPhase = "Completed"
+	// VolumeResizeRequestFailed means the resize couldn't be applied
+	VolumeResizeRequestFailed VolumeResizeRequestPhase = "Failed"
+)
+
+// VolumeResizeRequestSpec defines the resize decided by volume monitor
+type VolumeResizeRequestSpec struct {
+	// PVCName is the name of the PVC to resize.
+	PVCName string `json:"pvcName" yaml:"pvcName"`
+
+	// ConfigName is the name of the DiskConfig of the PVC.
+	ConfigName string `json:"configName" yaml:"configName"`
+
+	// PodName is the name of the Pod using the PVC.
+	//+kubebuilder:validation:Optional
+	PodName string `json:"podName,omitempty" yaml:"podName,omitempty"`
+
+	// NodeName is the name of the node of the Pod at the time of decision, informational only,
+	// file-system of additional disks is grown on the actual node of the Pod.
+	//+kubebuilder:validation:Optional
+	NodeName string `json:"nodeName,omitempty" yaml:"nodeName,omitempty"`
+
+	// OldCapacity is the capacity of the PVC at the time of decision.
+	//+kubebuilder:validation:Optional
+	OldCapacity resource.Quantity `json:"oldCapacity,omitempty" yaml:"oldCapacity,omitempty"`
+
+	// Capacity is the target capacity of the PVC.
+	Capacity resource.Quantity `json:"capacity" yaml:"capacity"`
+
+	// Reason describes the decision of volume monitor.
+	//+kubebuilder:validation:Optional
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// VolumeResizeRequestStatus defines the observed state of VolumeResizeRequest
+type VolumeResizeRequestStatus struct {
+	// Phase is the phase of the request, empty means Pending.
+	Phase VolumeResizeRequestPhase `json:"phase,omitempty" yaml:"phase,omitempty"`
+
+	// Message describes the last transition.
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+
+	// AppliedTime is the time of the PVC update.
+	AppliedTime *metav1.Time `json:"appliedTime,omitempty" yaml:"appliedTime,omitempty"`
+
+	// CompletionTime is the time the volume reached the requested capacity.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty" yaml:"completionTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="PVC",type=string,JSONPath=`.spec.pvcName`
+//+kubebuilder:printcolumn:name="Capacity",type=string,JSONPath=`.spec.capacity`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// VolumeResizeRequest is the Schema for the volumeresizerequests API
+type VolumeResizeRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VolumeResizeRequestSpec   `json:"spec,omitempty"`
+	Status VolumeResizeRequestStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// VolumeResizeRequestList contains a list of VolumeResizeRequest
+type VolumeResizeRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VolumeResizeRequest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VolumeResizeRequest{}, &VolumeResizeRequestList{})
+}
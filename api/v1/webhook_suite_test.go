@@ -101,7 +101,7 @@ var _ = BeforeSuite(func() {
 	})
 	Expect(err).NotTo(HaveOccurred())
 
-	InitDiskConfigWebhookDeps(mgr.GetClient(), []string{})
+	InitDiskConfigWebhookDeps(mgr.GetClient(), "discoblocks", "discoblocks-parent", "discoblocks/cluster-config", []string{}, []string{})
 
 	err = (&DiskConfig{}).SetupWebhookWithManager(mgr)
 	Expect(err).NotTo(HaveOccurred())
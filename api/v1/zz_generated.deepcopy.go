@@ -25,8 +25,109 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterDiskConfig) DeepCopyInto(out *ClusterDiskConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterDiskConfig.
+func (in *ClusterDiskConfig) DeepCopy() *ClusterDiskConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterDiskConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterDiskConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterDiskConfigList) DeepCopyInto(out *ClusterDiskConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterDiskConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterDiskConfigList.
+func (in *ClusterDiskConfigList) DeepCopy() *ClusterDiskConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterDiskConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterDiskConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterDiskConfigSpec) DeepCopyInto(out *ClusterDiskConfigSpec) {
+	*out = *in
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	in.DiskConfigSpec.DeepCopyInto(&out.DiskConfigSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterDiskConfigSpec.
+func (in *ClusterDiskConfigSpec) DeepCopy() *ClusterDiskConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterDiskConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterDiskConfigStatus) DeepCopyInto(out *ClusterDiskConfigStatus) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterDiskConfigStatus.
+func (in *ClusterDiskConfigStatus) DeepCopy() *ClusterDiskConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterDiskConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DiskConfig) DeepCopyInto(out *DiskConfig) {
 	*out = *in
@@ -90,6 +191,11 @@ func (in *DiskConfigList) DeepCopyObject() runtime.Object {
 func (in *DiskConfigSpec) DeepCopyInto(out *DiskConfigSpec) {
 	*out = *in
 	out.Capacity = in.Capacity.DeepCopy()
+	if in.MountPropagation != nil {
+		in, out := &in.MountPropagation, &out.MountPropagation
+		*out = new(corev1.MountPropagationMode)
+		**out = **in
+	}
 	if in.AccessModes != nil {
 		in, out := &in.AccessModes, &out.AccessModes
 		*out = make([]corev1.PersistentVolumeAccessMode, len(*in))
@@ -107,6 +213,32 @@ func (in *DiskConfigSpec) DeepCopyInto(out *DiskConfigSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.PVCAnnotations != nil {
+		in, out := &in.PVCAnnotations, &out.PVCAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PodVolumesAnnotations != nil {
+		in, out := &in.PodVolumesAnnotations, &out.PodVolumesAnnotations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MountEnv != nil {
+		in, out := &in.MountEnv, &out.MountEnv
+		*out = make([]corev1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Disks != nil {
+		in, out := &in.Disks, &out.Disks
+		*out = make([]DiskSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	in.Policy.DeepCopyInto(&out.Policy)
 }
 
@@ -130,6 +262,18 @@ func (in *DiskConfigStatus) DeepCopyInto(out *DiskConfigStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Resizes != nil {
+		in, out := &in.Resizes, &out.Resizes
+		*out = make(map[string]ResizeStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(RolloutStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskConfigStatus.
@@ -142,12 +286,90 @@ func (in *DiskConfigStatus) DeepCopy() *DiskConfigStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskPolicy) DeepCopyInto(out *DiskPolicy) {
+	*out = *in
+	if in.UpscaleTriggerPercentage != nil {
+		in, out := &in.UpscaleTriggerPercentage, &out.UpscaleTriggerPercentage
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.ExtendCapacity != nil {
+		in, out := &in.ExtendCapacity, &out.ExtendCapacity
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.MaximumCapacityOfDisk != nil {
+		in, out := &in.MaximumCapacityOfDisk, &out.MaximumCapacityOfDisk
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskPolicy.
+func (in *DiskPolicy) DeepCopy() *DiskPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskSpec) DeepCopyInto(out *DiskSpec) {
+	*out = *in
+	if in.Capacity != nil {
+		in, out := &in.Capacity, &out.Capacity
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Policy != nil {
+		in, out := &in.Policy, &out.Policy
+		*out = new(DiskPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskSpec.
+func (in *DiskSpec) DeepCopy() *DiskSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Policy) DeepCopyInto(out *Policy) {
 	*out = *in
+	out.UpscaleTriggerPercentage = in.UpscaleTriggerPercentage
+	if in.DownscaleTriggerPercentage != nil {
+		in, out := &in.DownscaleTriggerPercentage, &out.DownscaleTriggerPercentage
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
 	out.MaximumCapacityOfDisk = in.MaximumCapacityOfDisk.DeepCopy()
 	out.ExtendCapacity = in.ExtendCapacity.DeepCopy()
+	out.MaximumStepSize = in.MaximumStepSize.DeepCopy()
+	out.MinimumCapacityOfDisk = in.MinimumCapacityOfDisk.DeepCopy()
+	out.PoolTTL = in.PoolTTL
 	out.CoolDown = in.CoolDown
+	out.InitialGracePeriod = in.InitialGracePeriod
+	out.DetachGracePeriod = in.DetachGracePeriod
+	out.PredictionHorizon = in.PredictionHorizon
+	if in.PreResizeHook != nil {
+		in, out := &in.PreResizeHook, &out.PreResizeHook
+		*out = new(ResizeHook)
+		**out = **in
+	}
+	if in.PostResizeHook != nil {
+		in, out := &in.PostResizeHook, &out.PostResizeHook
+		*out = new(ResizeHook)
+		**out = **in
+	}
+	out.ResizeRolloutWindow = in.ResizeRolloutWindow
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Policy.
@@ -159,3 +381,149 @@ func (in *Policy) DeepCopy() *Policy {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResizeHook) DeepCopyInto(out *ResizeHook) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResizeHook.
+func (in *ResizeHook) DeepCopy() *ResizeHook {
+	if in == nil {
+		return nil
+	}
+	out := new(ResizeHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResizeStatus) DeepCopyInto(out *ResizeStatus) {
+	*out = *in
+	in.LastAttemptTime.DeepCopyInto(&out.LastAttemptTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResizeStatus.
+func (in *ResizeStatus) DeepCopy() *ResizeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ResizeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutStatus) DeepCopyInto(out *RolloutStatus) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutStatus.
+func (in *RolloutStatus) DeepCopy() *RolloutStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeResizeRequest) DeepCopyInto(out *VolumeResizeRequest) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeResizeRequest.
+func (in *VolumeResizeRequest) DeepCopy() *VolumeResizeRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeResizeRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VolumeResizeRequest) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeResizeRequestList) DeepCopyInto(out *VolumeResizeRequestList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VolumeResizeRequest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeResizeRequestList.
+func (in *VolumeResizeRequestList) DeepCopy() *VolumeResizeRequestList {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeResizeRequestList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VolumeResizeRequestList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeResizeRequestSpec) DeepCopyInto(out *VolumeResizeRequestSpec) {
+	*out = *in
+	out.OldCapacity = in.OldCapacity.DeepCopy()
+	out.Capacity = in.Capacity.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeResizeRequestSpec.
+func (in *VolumeResizeRequestSpec) DeepCopy() *VolumeResizeRequestSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeResizeRequestSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeResizeRequestStatus) DeepCopyInto(out *VolumeResizeRequestStatus) {
+	*out = *in
+	if in.AppliedTime != nil {
+		in, out := &in.AppliedTime, &out.AppliedTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeResizeRequestStatus.
+func (in *VolumeResizeRequestStatus) DeepCopy() *VolumeResizeRequestStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeResizeRequestStatus)
+	in.DeepCopyInto(out)
+	return out
+}
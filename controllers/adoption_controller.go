@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"github.com/ondat/discoblocks/pkg/metrics"
+	"github.com/ondat/discoblocks/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// AdoptionReconciler adopts existing PVCs into DiskConfig by label
+type AdoptionReconciler struct {
+	EventService utils.EventService
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// Reconcile adopts PVC labeled by adopt label into the DiskConfig of the label value.
+// Adopted PVC becomes the first disk of a new group, so monitor and scaling starts on next run.
+func (r *AdoptionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithName("AdoptionReconciler").WithValues("req_name", req.Name, "namespace", req.Namespace)
+
+	lock, unlock := controllerSemaphore()
+	if !lock {
+		logger.Info("Another operation is on going, event needs to be resceduled")
+		return ctrl.Result{Requeue: true}, nil
+	}
+	defer unlock()
+
+	defer pvcLock.Lock(req.NamespacedName.String())()
+
+	logger.Info("Reconciling...")
+	defer logger.Info("Reconciled")
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	pvc := corev1.PersistentVolumeClaim{}
+	if err := r.Get(ctx, req.NamespacedName, &pvc); err != nil {
+		if !apierrors.IsNotFound(err) {
+			metrics.NewError("PersistentVolumeClaim", req.Name, req.Namespace, "Kube API", "get")
+
+			return ctrl.Result{}, fmt.Errorf("unable to fetch PVC: %w", err)
+		}
+
+		logger.Info("PVC not found")
+
+		return ctrl.Result{}, nil
+	}
+
+	configName := pvc.Labels[utils.AdoptLabel()]
+	if configName == "" {
+		return ctrl.Result{}, nil
+	}
+	logger = logger.WithValues("dc_name", configName)
+
+	logger.Info("Fetch DiskConfig...")
+
+	config := discoblocksondatiov1.DiskConfig{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: pvc.Namespace, Name: configName}, &config); err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("DiskConfig not found")
+			return ctrl.Result{RequeueAfter: time.Minute}, nil
+		}
+
+		metrics.NewError("DiskConfig", configName, pvc.Namespace, "Kube API", "get")
+
+		return ctrl.Result{}, fmt.Errorf("unable to fetch DiskConfig: %w", err)
+	}
+
+	logger.Info("Fetch StorageClass...")
+
+	sc := storagev1.StorageClass{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: config.Spec.StorageClassName}, &sc); err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("StorageClass not found", "sc_name", config.Spec.StorageClassName)
+			return ctrl.Result{RequeueAfter: time.Minute}, nil
+		}
+
+		metrics.NewError("StorageClass", config.Spec.StorageClassName, "", "Kube API", "get")
+
+		return ctrl.Result{}, fmt.Errorf("unable to fetch StorageClass: %w", err)
+	}
+
+	if err := utils.ValidateAdoption(&pvc, &config, &sc); err != nil {
+		logger.Info("PVC is not compatible", "error", err.Error())
+
+		if err := r.EventService.SendWarning(pvc.Namespace, "Discoblocks", "PVC Adoption", fmt.Sprintf("Failed to adopt %s into %s", pvc.Name, config.Name), err.Error(), &pvc, &config); err != nil {
+			metrics.NewError("Event", "", "", "Kube API", "create")
+
+			logger.Error(err, "Failed to create event")
+		}
+
+		return ctrl.Result{}, nil
+	}
+
+	if pvc.Labels == nil {
+		pvc.Labels = map[string]string{}
+	}
+	pvc.Labels[utils.ConfigLabel()] = config.Name
+	delete(pvc.Labels, utils.AdoptLabel())
+
+	controllerutil.AddFinalizer(&pvc, utils.RenderFinalizer(config.Name))
+
+	logger.Info("Adopt PVC...")
+
+	if err := r.Client.Update(ctx, &pvc); err != nil {
+		metrics.NewError("PersistentVolumeClaim", pvc.Name, pvc.Namespace, "Kube API", "update")
+
+		return ctrl.Result{}, fmt.Errorf("unable to adopt PVC: %w", err)
+	}
+
+	recorded := false
+	for i := range config.Status.Conditions {
+		if config.Status.Conditions[i].Reason == pvcConditionReason && config.Status.Conditions[i].Message == pvc.Name {
+			recorded = true
+			break
+		}
+	}
+
+	if !recorded {
+		config.Status.Conditions = append(config.Status.Conditions, renderPVCCondition(&pvc))
+
+		logger.Info("Update DiskConfig status...")
+
+		if err := r.Client.Status().Update(ctx, &config); err != nil {
+			metrics.NewError("DiskConfig", config.Name, config.Namespace, "Kube API", "update")
+
+			logger.Info("Unable to update DiskConfig status", "error", err.Error())
+		}
+	}
+
+	if err := r.EventService.SendNormal(pvc.Namespace, "Discoblocks", "PVC Adoption", fmt.Sprintf("Adopted %s into %s", pvc.Name, config.Name), "Operation finished: PVC adopted", &pvc, &config); err != nil {
+		metrics.NewError("Event", "", "", "Kube API", "create")
+
+		logger.Error(err, "Failed to create event")
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AdoptionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("adoption").
+		For(&corev1.PersistentVolumeClaim{}).
+		WithEventFilter(adoptionEventFilter{logger: mgr.GetLogger().WithName("AdoptionReconciler")}).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: 1,
+		}).
+		Complete(r)
+}
+
+type adoptionEventFilter struct {
+	logger logr.Logger
+}
+
+func (ef adoptionEventFilter) Create(e event.CreateEvent) bool {
+	return e.Object.GetLabels()[utils.AdoptLabel()] != ""
+}
+
+func (ef adoptionEventFilter) Delete(_ event.DeleteEvent) bool {
+	return false
+}
+
+func (ef adoptionEventFilter) Update(e event.UpdateEvent) bool {
+	newObj, ok := e.ObjectNew.(*corev1.PersistentVolumeClaim)
+	if !ok {
+		ef.logger.Error(errors.New("unsupported type"), "Unable to cast new object")
+		return false
+	}
+
+	return newObj.Labels[utils.AdoptLabel()] != ""
+}
+
+func (ef adoptionEventFilter) Generic(_ event.GenericEvent) bool {
+	return false
+}
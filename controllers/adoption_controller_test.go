@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"github.com/ondat/discoblocks/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestReconcileAdoption(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	cases := map[string]struct {
+		scName          string
+		expandable      bool
+		phase           corev1.PersistentVolumeClaimPhase
+		expectedAdopted bool
+	}{
+		"adopted": {
+			scName:          "sc",
+			expandable:      true,
+			phase:           corev1.ClaimBound,
+			expectedAdopted: true,
+		},
+		"not expandable": {
+			scName: "sc",
+			phase:  corev1.ClaimBound,
+		},
+		"other StorageClass": {
+			scName:     "other",
+			expandable: true,
+			phase:      corev1.ClaimBound,
+		},
+		"not bound": {
+			scName:     "sc",
+			expandable: true,
+			phase:      corev1.ClaimPending,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			pvc := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pvc",
+					Namespace: "default",
+					Labels:    map[string]string{utils.AdoptLabel(): "config"},
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: &c.scName,
+				},
+				Status: corev1.PersistentVolumeClaimStatus{
+					Phase: c.phase,
+				},
+			}
+			sc := storagev1.StorageClass{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "sc",
+				},
+				Provisioner:          "ebs.csi.aws.com",
+				AllowVolumeExpansion: &c.expandable,
+			}
+			config := discoblocksondatiov1.DiskConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "config",
+					Namespace: "default",
+				},
+				Spec: discoblocksondatiov1.DiskConfigSpec{
+					StorageClassName: "sc",
+				},
+			}
+
+			kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&pvc, &sc, &config).Build()
+
+			r := AdoptionReconciler{
+				EventService: utils.NewEventService("controller", kubeClient),
+				Client:       kubeClient,
+			}
+
+			_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}})
+			require.Nil(t, err, "unexpected error")
+
+			adopted := corev1.PersistentVolumeClaim{}
+			require.Nil(t, kubeClient.Get(context.Background(), client.ObjectKeyFromObject(&pvc), &adopted), "unable to fetch PVC")
+
+			actConfig := discoblocksondatiov1.DiskConfig{}
+			require.Nil(t, kubeClient.Get(context.Background(), client.ObjectKeyFromObject(&config), &actConfig), "unable to fetch DiskConfig")
+
+			if !c.expectedAdopted {
+				assert.Equal(t, "config", adopted.Labels[utils.AdoptLabel()], "adopt label removed")
+				assert.Empty(t, adopted.Labels[utils.ConfigLabel()], "incompatible PVC adopted")
+				assert.Empty(t, actConfig.Status.Conditions, "incompatible PVC recorded")
+				return
+			}
+
+			assert.NotContains(t, adopted.Labels, utils.AdoptLabel(), "adopt label not removed")
+			assert.Equal(t, "config", adopted.Labels[utils.ConfigLabel()], "invalid config label")
+			assert.True(t, controllerutil.ContainsFinalizer(&adopted, utils.RenderFinalizer("config")), "finalizer missing")
+
+			require.Len(t, actConfig.Status.Conditions, 1, "PVC not recorded")
+			assert.Equal(t, "pvc", actConfig.Status.Conditions[0].Message, "invalid condition")
+			assert.Equal(t, metav1.ConditionTrue, actConfig.Status.Conditions[0].Status, "invalid condition status")
+		})
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/ondat/discoblocks/pkg/metrics"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"github.com/ondat/discoblocks/pkg/utils"
+)
+
+// ClusterDiskConfigReconciler reconciles a ClusterDiskConfig object
+type ClusterDiskConfigReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// Reconcile renders DiskConfig of ClusterDiskConfig to every selected namespace
+func (r *ClusterDiskConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithName("ClusterDiskConfigReconciler").WithValues("req_name", req.Name)
+
+	logger.Info("Reconciling...")
+	defer logger.Info("Reconciled")
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	config := discoblocksondatiov1.ClusterDiskConfig{}
+	if err := r.Get(ctx, req.NamespacedName, &config); err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("ClusterDiskConfig not found, rendered DiskConfigs are garbage collected")
+			return ctrl.Result{}, nil
+		}
+
+		metrics.NewError("ClusterDiskConfig", req.Name, "", "Kube API", "get")
+
+		return ctrl.Result{}, fmt.Errorf("unable to fetch ClusterDiskConfig: %w", err)
+	} else if config.DeletionTimestamp != nil {
+		logger.Info("ClusterDiskConfig delete in progress")
+		return ctrl.Result{}, nil
+	}
+
+	logger.Info("Fetch Namespaces...")
+
+	namespaces := corev1.NamespaceList{}
+	if err := r.List(ctx, &namespaces); err != nil {
+		metrics.NewError("Namespace", "", "", "Kube API", "list")
+
+		return ctrl.Result{}, fmt.Errorf("unable to list Namespaces: %w", err)
+	}
+
+	applied := []string{}
+	for i := range namespaces.Items {
+		namespace := namespaces.Items[i]
+
+		logger := logger.WithValues("namespace", namespace.Name)
+
+		if namespace.DeletionTimestamp != nil {
+			continue
+		}
+
+		selected, err := utils.IsNamespaceSelected(&config, &namespace)
+		if err != nil {
+			logger.Error(err, "Unable to select namespace")
+			return ctrl.Result{}, nil
+		}
+
+		logger.Info("Fetch DiskConfig...")
+
+		existing := discoblocksondatiov1.DiskConfig{}
+		err = r.Get(ctx, types.NamespacedName{Namespace: namespace.Name, Name: config.Name}, &existing)
+		if err != nil && !apierrors.IsNotFound(err) {
+			metrics.NewError("DiskConfig", config.Name, namespace.Name, "Kube API", "get")
+
+			return ctrl.Result{}, fmt.Errorf("unable to fetch DiskConfig: %w", err)
+		}
+		found := err == nil
+
+		switch {
+		case found && !utils.IsRenderedByClusterConfig(&existing, config.Name):
+			logger.Info("Namespaced DiskConfig takes precedence")
+			continue
+		case !selected && found:
+			logger.Info("Delete DiskConfig of unselected namespace...")
+
+			if err := r.Delete(ctx, &existing); err != nil && !apierrors.IsNotFound(err) {
+				metrics.NewError("DiskConfig", config.Name, namespace.Name, "Kube API", "delete")
+
+				return ctrl.Result{}, fmt.Errorf("unable to delete DiskConfig: %w", err)
+			}
+			continue
+		case !selected:
+			continue
+		}
+
+		rendered := utils.RenderClusterDiskConfig(&config, namespace.Name)
+
+		if !found {
+			logger.Info("Create DiskConfig...")
+
+			if err := r.Create(ctx, rendered); err != nil && !apierrors.IsAlreadyExists(err) {
+				metrics.NewError("DiskConfig", config.Name, namespace.Name, "Kube API", "create")
+
+				logger.Info("Failed to create DiskConfig", "error", err.Error())
+				return ctrl.Result{}, fmt.Errorf("unable to create DiskConfig: %w", err)
+			}
+		} else if !reflect.DeepEqual(existing.Spec, rendered.Spec) {
+			logger.Info("Update DiskConfig...")
+
+			existing.Spec = rendered.Spec
+			if err := r.Update(ctx, &existing); err != nil {
+				metrics.NewError("DiskConfig", config.Name, namespace.Name, "Kube API", "update")
+
+				logger.Info("Failed to update DiskConfig", "error", err.Error())
+				return ctrl.Result{}, fmt.Errorf("unable to update DiskConfig: %w", err)
+			}
+		}
+
+		applied = append(applied, namespace.Name)
+	}
+
+	if reflect.DeepEqual(config.Status.Namespaces, applied) {
+		return ctrl.Result{}, nil
+	}
+
+	logger.Info("Update status...")
+
+	config.Status.Namespaces = applied
+	if err := r.Status().Update(ctx, &config); err != nil {
+		metrics.NewError("ClusterDiskConfig", config.Name, "", "Kube API", "update")
+
+		return ctrl.Result{}, fmt.Errorf("unable to update ClusterDiskConfig status: %w", err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterDiskConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&discoblocksondatiov1.ClusterDiskConfig{}).
+		Owns(&discoblocksondatiov1.DiskConfig{}).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.enqueueAll)).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: 1,
+		}).
+		Complete(r)
+}
+
+// enqueueAll reconciles all ClusterDiskConfigs on namespace change
+func (r *ClusterDiskConfigReconciler) enqueueAll(_ client.Object) []reconcile.Request {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	configs := discoblocksondatiov1.ClusterDiskConfigList{}
+	if err := r.List(ctx, &configs); err != nil {
+		metrics.NewError("ClusterDiskConfig", "", "", "Kube API", "list")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(configs.Items))
+	for i := range configs.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: configs.Items[i].Name}})
+	}
+
+	return requests
+}
@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"github.com/ondat/discoblocks/pkg/metrics"
+	"github.com/ondat/discoblocks/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// deferredPVCRetryPeriod is the wait before next creation attempt of rate limited PVCs
+const deferredPVCRetryPeriod = 5 * time.Second
+
+// DeferredPVCReconciler creates PVCs of rate limited admissions
+type DeferredPVCReconciler struct {
+	EventService utils.EventService
+	Limiter      *utils.ProvisionLimiter
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// Reconcile creates PVCs listed in the deferred PVCs annotation of the Pod, the Pod stays Pending until they exist.
+// Created PVCs are removed from the annotation, the rest is retried until provisioning rate allows them.
+func (r *DeferredPVCReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithName("DeferredPVCReconciler").WithValues("req_name", req.Name, "namespace", req.Namespace)
+
+	logger.Info("Reconciling...")
+	defer logger.Info("Reconciled")
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	pod := corev1.Pod{}
+	if err := r.Get(ctx, req.NamespacedName, &pod); err != nil {
+		if !apierrors.IsNotFound(err) {
+			metrics.NewError("Pod", req.Name, req.Namespace, "Kube API", "get")
+
+			return ctrl.Result{}, fmt.Errorf("unable to fetch Pod: %w", err)
+		}
+
+		logger.Info("Pod not found")
+
+		return ctrl.Result{}, nil
+	} else if pod.DeletionTimestamp != nil {
+		logger.Info("Pod is under deletion")
+		return ctrl.Result{}, nil
+	}
+
+	deferred, err := utils.GetDeferredPVCs(&pod)
+	if err != nil {
+		logger.Error(err, "Invalid deferred PVCs annotation")
+		return ctrl.Result{}, nil
+	} else if len(deferred) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	result := ctrl.Result{}
+	remaining := []utils.DeferredPVC{}
+	for i := range deferred {
+		if result.RequeueAfter != 0 {
+			remaining = append(remaining, deferred[i])
+			continue
+		}
+
+		created, err := r.createDeferredPVC(ctx, &pod, &deferred[i], logger.WithValues("pvc_name", deferred[i].PVC.Name, "dc_name", deferred[i].ConfigName))
+		if err != nil {
+			return ctrl.Result{}, err
+		} else if !created {
+			logger.Info("PVC provisioning rate exceeded, retry later")
+
+			result.RequeueAfter = deferredPVCRetryPeriod
+			remaining = append(remaining, deferred[i])
+		}
+	}
+
+	if len(remaining) == len(deferred) {
+		return result, nil
+	}
+
+	logger.Info("Update deferred PVCs of Pod...", "remaining", len(remaining))
+
+	patched := pod.DeepCopy()
+	if err := utils.SetDeferredPVCs(patched, remaining); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.Client.Patch(ctx, patched, client.MergeFrom(&pod)); err != nil {
+		metrics.NewError("Pod", pod.Name, pod.Namespace, "Kube API", "patch")
+
+		return ctrl.Result{}, fmt.Errorf("unable to update deferred PVCs of Pod: %w", err)
+	}
+
+	return result, nil
+}
+
+// createDeferredPVC creates the PVC and its initial disks, returns false if provisioning rate doesn't allow it yet
+func (r *DeferredPVCReconciler) createDeferredPVC(ctx context.Context, pod *corev1.Pod, deferred *utils.DeferredPVC, logger logr.Logger) (bool, error) {
+	logger.Info("Fetch DiskConfig...")
+
+	config := discoblocksondatiov1.DiskConfig{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: deferred.PVC.Namespace, Name: deferred.ConfigName}, &config); err != nil {
+		if !apierrors.IsNotFound(err) {
+			metrics.NewError("DiskConfig", deferred.ConfigName, deferred.PVC.Namespace, "Kube API", "get")
+
+			return false, fmt.Errorf("unable to fetch DiskConfig: %w", err)
+		}
+
+		logger.Info("DiskConfig not found, drop deferred PVC")
+
+		// Pod stays Pending without the PVC, nothing would tell why
+		if err := r.EventService.SendWarning(pod.Namespace, "Discoblocks", "Deferred PVC", fmt.Sprintf("DiskConfig of %s not found: %s", deferred.PVC.Name, deferred.ConfigName), "PVC dropped, recreate the Pod", pod, nil); err != nil {
+			metrics.NewError("Event", "", "", "Kube API", "create")
+
+			logger.Error(err, "Failed to create event")
+		}
+
+		return true, nil
+	}
+
+	if !r.Limiter.Allow() {
+		return false, nil
+	}
+
+	logger.Info("Create deferred PVC...")
+
+	pvc := deferred.PVC.DeepCopy()
+	existing := corev1.PersistentVolumeClaim{}
+	created, err := utils.CreateOrGetPVC(ctx, r.Client, pvc, &existing, config.Spec.VolumeAttributesClassName)
+	if err != nil {
+		metrics.NewError("PersistentVolumeClaim", pvc.Name, pvc.Namespace, "Kube API", "create")
+
+		return false, fmt.Errorf("unable to create deferred PVC: %w", err)
+	}
+
+	if created {
+		metrics.NewPVCOperation(pvc.Name, pvc.Namespace, "create", config.Spec.Capacity.String())
+	} else {
+		logger.Info("Deferred PVC already exists")
+		pvc = &existing
+	}
+
+	logger.Info("Create deferred initial PVCs...", "number", config.Spec.Policy.InitialNumberOfDisks)
+
+	initialPVCs, err := utils.CreateInitialPVCs(ctx, r.Client, &config, pvc)
+	if err != nil {
+		metrics.NewError("PersistentVolumeClaim", pvc.Name, pvc.Namespace, "Kube API", "create")
+
+		return false, fmt.Errorf("unable to create deferred initial PVCs: %w", err)
+	}
+
+	if created {
+		for name := range initialPVCs {
+			metrics.NewPVCOperation(name, pvc.Namespace, "create", config.Spec.Capacity.String())
+		}
+	}
+
+	return true, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DeferredPVCReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("deferredpvc").
+		For(&corev1.Pod{}).
+		WithEventFilter(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			_, ok := obj.GetAnnotations()[utils.DeferredPVCsAnnotation()]
+			return ok
+		})).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: 1,
+		}).
+		Complete(r)
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"github.com/ondat/discoblocks/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileDeferredPVCs(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+		},
+	}
+
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "default",
+		},
+	}
+	for _, name := range []string{"pvc-a", "pvc-b", "pvc-c"} {
+		configName := config.Name
+		if name == "pvc-b" {
+			configName = "deleted"
+		}
+
+		pvc := corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+		require.Nil(t, utils.AddDeferredPVC(&pod, configName, &pvc), "unable to add deferred PVC")
+	}
+
+	// Single token allows the first PVC only
+	limiter, err := utils.NewProvisionLimiter(1, 1)
+	require.Nil(t, err, "unable to create limiter")
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&config, &pod).Build()
+
+	reconciler := DeferredPVCReconciler{
+		EventService: utils.NewEventService("controller", kubeClient),
+		Limiter:      limiter,
+		Client:       kubeClient,
+		Scheme:       scheme,
+	}
+
+	result, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&pod)})
+	require.Nil(t, err, "reconcile failed")
+	assert.Equal(t, deferredPVCRetryPeriod, result.RequeueAfter, "rate limited PVC not retried")
+
+	assert.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "pvc-a"}, &corev1.PersistentVolumeClaim{}), "PVC not created")
+
+	latestPod := corev1.Pod{}
+	require.Nil(t, kubeClient.Get(context.Background(), client.ObjectKeyFromObject(&pod), &latestPod), "unable to fetch Pod")
+
+	deferred, err := utils.GetDeferredPVCs(&latestPod)
+	require.Nil(t, err, "unable to get deferred PVCs")
+	require.Len(t, deferred, 1, "invalid number of deferred PVCs")
+	assert.Equal(t, "pvc-c", deferred[0].PVC.Name, "invalid deferred PVC")
+
+	// PVC of the deleted DiskConfig is dropped with a warning on the Pod
+	events := eventsv1.EventList{}
+	require.Nil(t, kubeClient.List(context.Background(), &events), "unable to list events")
+	require.Len(t, events.Items, 1, "invalid number of events")
+	assert.Equal(t, "Warning", events.Items[0].Type, "invalid event type")
+	assert.Equal(t, pod.Name, events.Items[0].Regarding.Name, "invalid event target")
+	assert.Contains(t, events.Items[0].Reason, "pvc-b", "invalid event reason")
+
+	// Without limit the rest is created and annotation is removed
+	reconciler.Limiter = nil
+
+	result, err = reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&pod)})
+	require.Nil(t, err, "reconcile failed")
+	assert.Zero(t, result.RequeueAfter, "finished Pod retried")
+
+	assert.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "pvc-c"}, &corev1.PersistentVolumeClaim{}), "PVC not created")
+
+	require.Nil(t, kubeClient.Get(context.Background(), client.ObjectKeyFromObject(&pod), &latestPod), "unable to fetch Pod")
+	assert.NotContains(t, latestPod.Annotations, utils.DeferredPVCsAnnotation(), "annotation kept")
+}
@@ -22,14 +22,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/ondat/discoblocks/pkg/drivers"
 	"github.com/ondat/discoblocks/pkg/metrics"
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/selection"
@@ -48,12 +52,24 @@ import (
 
 const concurrency = 10
 
+// poolResyncPeriod is the period of pool maintenance
+const poolResyncPeriod = time.Minute
+
 var controllerSemaphore = utils.CreateSemaphore(1, time.Second)
 
+// pvcLock serializes writes of the same PVC between reconcilers and volume monitor.
+// Reconcilers hold it for the whole reconcile, monitor only while it updates the PVC,
+// writes are made on a fresh copy of the PVC and retried on conflict, so writers outside of the operator are not clobbered either.
+var pvcLock = utils.NewKeyedLock()
+
 // DiskConfigReconciler reconciles a DiskConfig object
 type DiskConfigReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	// APIReader reads the API server directly, see manager.GetAPIReader
+	APIReader        client.Reader
+	Scheme           *runtime.Scheme
+	ServiceMonitor   bool
+	MetricsNamespace string
 }
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -141,6 +157,10 @@ func (r *DiskConfigReconciler) reconcileDelete(ctx context.Context, configName,
 		}
 	}
 
+	if err := r.deleteServiceMonitors(ctx, configName, configNamespace, logger); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	finalizer := utils.RenderFinalizer(configName)
 
 	logger.Info("Update PVCs...")
@@ -151,7 +171,7 @@ func (r *DiskConfigReconciler) reconcileDelete(ctx context.Context, configName,
 
 	logger.Info("Fetch PVCs...")
 
-	label, err := labels.NewRequirement("discoblocks", selection.Equals, []string{configName})
+	label, err := labels.NewRequirement(utils.ConfigLabel(), selection.Equals, []string{configName})
 	if err != nil {
 		logger.Error(err, "Unable to parse PVC label selector")
 		return ctrl.Result{}, nil
@@ -170,8 +190,8 @@ func (r *DiskConfigReconciler) reconcileDelete(ctx context.Context, configName,
 	}
 
 	for i := range pvcList.Items {
-		if !controllerutil.ContainsFinalizer(&pvcList.Items[i], utils.RenderFinalizer(pvcList.Items[i].Labels["discoblocks"])) {
-			logger.Info("PVC not managed by", "config", pvcList.Items[i].Labels["discoblocks"])
+		if !controllerutil.ContainsFinalizer(&pvcList.Items[i], utils.RenderFinalizer(pvcList.Items[i].Labels[utils.ConfigLabel()])) {
+			logger.Info("PVC not managed by", "config", pvcList.Items[i].Labels[utils.ConfigLabel()])
 			continue
 		}
 
@@ -259,9 +279,708 @@ func (r *DiskConfigReconciler) reconcileUpdate(ctx context.Context, config *disc
 		}
 	}
 
+	if r.ServiceMonitor {
+		if err := r.ensureServiceMonitor(ctx, config, logger); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if config.Spec.VolumeAttributesClassName != "" {
+		if err := r.ensureVolumeAttributesClass(ctx, config, logger); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.ensurePool(ctx, config, &sc, logger); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.ensureEagerPVCs(ctx, config, &sc, logger); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reclaimDetachedPVCs(ctx, config, &sc, logger); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Pool is refilled after claims and expired disks are reclaimed periodically, eager disks follow new Pods,
+	// detached disks are tracked until Pods come back or grace period expires
+	if config.Spec.Policy.PoolSize > 0 || config.Spec.ProvisionMode == discoblocksondatiov1.ProvisionModeEager ||
+		config.Spec.Policy.DetachGracePeriod.Duration > 0 {
+		return ctrl.Result{RequeueAfter: poolResyncPeriod}, nil
+	}
+
 	return ctrl.Result{}, nil
 }
 
+// ensurePool maintains pre-provisioned PVCs of DiskConfig, reclaims expired ones and creates missing ones.
+// On WaitForFirstConsumer StorageClasses pooled PVCs get the topology StorageClass of nodes of the selected Pods
+// like disks created at admission, so volumes are provisioned ahead, and are pre-bound to the nodes for selection.
+func (r *DiskConfigReconciler) ensurePool(ctx context.Context, config *discoblocksondatiov1.DiskConfig, sc *storagev1.StorageClass, logger logr.Logger) error {
+	logger.Info("Fetch pooled PVCs...")
+
+	pvcList := corev1.PersistentVolumeClaimList{}
+	if err := r.Client.List(ctx, &pvcList, client.InNamespace(config.Namespace), client.MatchingLabels{utils.PoolLabel(): config.Name}); err != nil {
+		metrics.NewError("PersistentVolumeClaim", "", config.Namespace, "Kube API", "list")
+
+		return fmt.Errorf("unable to list pooled PVCs: %w", err)
+	}
+
+	create, reclaim := utils.PlanPool(pvcList.Items, int(config.Spec.Policy.PoolSize), config.Spec.Policy.PoolTTL.Duration, time.Now())
+
+	for _, name := range reclaim {
+		logger.Info("Reclaim pooled PVC...", "pvc_name", name)
+
+		pvc := corev1.PersistentVolumeClaim{}
+		pvc.Name = name
+		pvc.Namespace = config.Namespace
+
+		if err := r.Client.Delete(ctx, &pvc); err != nil && !apierrors.IsNotFound(err) {
+			metrics.NewError("PersistentVolumeClaim", name, config.Namespace, "Kube API", "delete")
+
+			return fmt.Errorf("unable to delete pooled PVC: %w", err)
+		}
+
+		metrics.NewPVCOperation(name, config.Namespace, "reclaim", config.Spec.Capacity.String())
+	}
+
+	if create == 0 {
+		return nil
+	}
+
+	driver := drivers.GetDriver(sc.Provisioner)
+	if driver == nil {
+		metrics.NewError("CSI", sc.Provisioner, "", sc.Provisioner, "GetDriver")
+
+		logger.Info("Driver not found")
+		return nil
+	}
+
+	local, err := driver.IsNodeLocal()
+	if err != nil {
+		metrics.NewError("CSI", "", "", sc.Provisioner, "IsNodeLocal")
+
+		return fmt.Errorf("failed to call IsNodeLocal: %w", err)
+	} else if local {
+		logger.Info("Pool is not supported by node local drivers")
+		return nil
+	}
+
+	nodes := []string{}
+	if sc.VolumeBindingMode != nil && *sc.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer {
+		if nodes, err = r.getPoolNodes(ctx, config); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < create; i++ {
+		prefix := utils.GetNamePrefix(config.Spec.AvailabilityMode, string(config.UID), "")
+
+		pvcName, err := utils.RenderResourceName(true, prefix, strconv.Itoa(i), config.Name, config.Namespace)
+		if err != nil {
+			logger.Error(err, "Unable to render pooled PVC name")
+			return nil
+		}
+
+		pvc, err := driver.GetPVCStub(pvcName, config.Namespace, config.Spec.StorageClassName)
+		if err != nil {
+			metrics.NewError("CSI", pvcName, "", sc.Provisioner, "GetPVCStub")
+
+			return fmt.Errorf("failed to call GetPVCStub: %w", err)
+		}
+
+		utils.PooledPVCDecorator(config, prefix, driver, pvc)
+
+		if len(nodes) != 0 {
+			nodeName := nodes[i%len(nodes)]
+
+			if err := r.applyNodeTopology(ctx, driver, sc, nodeName, pvc, logger); err != nil {
+				return err
+			}
+
+			utils.SetSelectedNode(pvc, nodeName)
+		}
+
+		logger.Info("Create pooled PVC...", "pvc_name", pvc.Name, "node", pvc.Annotations[utils.SelectedNodeAnnotation])
+
+		if err := utils.CreatePVC(ctx, r.Client, pvc, config.Spec.VolumeAttributesClassName); err != nil && !apierrors.IsAlreadyExists(err) {
+			metrics.NewError("PersistentVolumeClaim", pvc.Name, pvc.Namespace, "Kube API", "create")
+
+			return fmt.Errorf("unable to create pooled PVC: %w", err)
+		}
+
+		metrics.NewPVCOperation(pvc.Name, pvc.Namespace, "pool", config.Spec.Capacity.String())
+	}
+
+	return nil
+}
+
+// ensureEagerPVCs creates the PVCs of the running Pods which the admission webhook would attach to them,
+// so disks are provisioned ahead of the next admission of the Pods.
+func (r *DiskConfigReconciler) ensureEagerPVCs(ctx context.Context, config *discoblocksondatiov1.DiskConfig, sc *storagev1.StorageClass, logger logr.Logger) error {
+	if config.Spec.ProvisionMode != discoblocksondatiov1.ProvisionModeEager {
+		return nil
+	}
+
+	logger.Info("Fetch Pods...")
+
+	pods := corev1.PodList{}
+	if err := r.Client.List(ctx, &pods, client.InNamespace(config.Namespace)); err != nil {
+		metrics.NewError("Pod", "", config.Namespace, "Kube API", "list")
+
+		return fmt.Errorf("unable to list Pods: %w", err)
+	}
+
+	plan := utils.PlanEagerPVCs(config, pods.Items)
+	if len(plan) == 0 {
+		return nil
+	}
+
+	driver := drivers.GetDriver(sc.Provisioner)
+	if driver == nil {
+		metrics.NewError("CSI", sc.Provisioner, "", sc.Provisioner, "GetDriver")
+
+		logger.Info("Driver not found")
+		return nil
+	}
+
+	local, err := driver.IsNodeLocal()
+	if err != nil {
+		metrics.NewError("CSI", "", "", sc.Provisioner, "IsNodeLocal")
+
+		return fmt.Errorf("failed to call IsNodeLocal: %w", err)
+	}
+
+	prefixes := []string{}
+	for prefix := range plan {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	for _, prefix := range prefixes {
+		pod := plan[prefix]
+
+		nodeName := ""
+		if config.Spec.AvailabilityMode == discoblocksondatiov1.ReadWriteDaemon {
+			nodeName = pod.Spec.NodeName
+		} else if local {
+			logger.Info("Eager provisioning of shared disks is not supported by node local drivers")
+			return nil
+		}
+
+		pvcName, err := utils.RenderResourceName(true, prefix, config.Name, config.Namespace)
+		if err != nil {
+			logger.Error(err, "Unable to render PVC name")
+			return nil
+		}
+
+		logger := logger.WithValues("pvc_name", pvcName, "node_name", nodeName)
+
+		existing := corev1.PersistentVolumeClaim{}
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: pvcName, Namespace: config.Namespace}, &existing); err == nil {
+			continue
+		} else if !apierrors.IsNotFound(err) {
+			metrics.NewError("PersistentVolumeClaim", pvcName, config.Namespace, "Kube API", "get")
+
+			return fmt.Errorf("unable to fetch PVC: %w", err)
+		}
+
+		pvc, err := driver.GetPVCStub(pvcName, config.Namespace, config.Spec.StorageClassName)
+		if err != nil {
+			metrics.NewError("CSI", pvcName, "", sc.Provisioner, "GetPVCStub")
+
+			return fmt.Errorf("failed to call GetPVCStub: %w", err)
+		}
+
+		utils.PVCDecorator(config, prefix, driver, pvc)
+
+		for k, v := range utils.RenderOwnerLabels(pod, false) {
+			pvc.Labels[k] = v
+		}
+
+		if nodeName != "" {
+			if err := r.applyNodeTopology(ctx, driver, sc, nodeName, pvc, logger); err != nil {
+				return err
+			}
+
+			if local {
+				utils.SetSelectedNode(pvc, nodeName)
+			}
+		}
+
+		logger.Info("Create eager PVC...")
+
+		if err := utils.CreatePVC(ctx, r.Client, pvc, config.Spec.VolumeAttributesClassName); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				continue
+			}
+
+			metrics.NewError("PersistentVolumeClaim", pvc.Name, pvc.Namespace, "Kube API", "create")
+
+			return fmt.Errorf("unable to create eager PVC: %w", err)
+		}
+
+		metrics.NewPVCOperation(pvc.Name, pvc.Namespace, "create", config.Spec.Capacity.String())
+
+		logger.Info("Create initial PVCs...", "number", config.Spec.Policy.InitialNumberOfDisks)
+
+		initialPVCs, err := utils.CreateInitialPVCs(ctx, r.Client, config, pvc)
+		if err != nil {
+			metrics.NewError("PersistentVolumeClaim", pvc.Name, pvc.Namespace, "Kube API", "create")
+
+			return fmt.Errorf("unable to create initial PVCs: %w", err)
+		}
+
+		for name := range initialPVCs {
+			metrics.NewPVCOperation(name, pvc.Namespace, "create", config.Spec.Capacity.String())
+		}
+	}
+
+	return nil
+}
+
+// reclaimDetachedPVCs tracks when Pods release disks of DiskConfig and deletes disks detached for longer than grace period.
+// Disks are deleted only if reclaim policy of their volumes is Delete, so a restarting Pod gets its disks back meanwhile.
+// Disks of ReadWriteOnce are unique per Pod, a restarting Pod never gets them back, so they are out of scope.
+func (r *DiskConfigReconciler) reclaimDetachedPVCs(ctx context.Context, config *discoblocksondatiov1.DiskConfig, sc *storagev1.StorageClass, logger logr.Logger) error {
+	if config.Spec.Policy.DetachGracePeriod.Duration == 0 {
+		return nil
+	}
+
+	if config.Spec.AvailabilityMode != discoblocksondatiov1.ReadWriteSame && config.Spec.AvailabilityMode != discoblocksondatiov1.ReadWriteDaemon {
+		logger.V(1).Info("Detach grace period is not supported by availability mode", "availability_mode", config.Spec.AvailabilityMode)
+		return nil
+	}
+
+	logger.Info("Fetch PVCs...")
+
+	pvcList := corev1.PersistentVolumeClaimList{}
+	if err := r.Client.List(ctx, &pvcList, client.InNamespace(config.Namespace), client.MatchingLabels{utils.ConfigLabel(): config.Name}); err != nil {
+		metrics.NewError("PersistentVolumeClaim", "", config.Namespace, "Kube API", "list")
+
+		return fmt.Errorf("unable to list PVCs: %w", err)
+	}
+
+	parents := []corev1.PersistentVolumeClaim{}
+	for i := range pvcList.Items {
+		if _, ok := pvcList.Items[i].Labels[utils.ParentLabel()]; !ok {
+			parents = append(parents, pvcList.Items[i])
+		}
+	}
+
+	if len(parents) == 0 {
+		return nil
+	}
+
+	logger.Info("Fetch Pods...")
+
+	pods := corev1.PodList{}
+	if err := r.Client.List(ctx, &pods, client.InNamespace(config.Namespace)); err != nil {
+		metrics.NewError("Pod", "", config.Namespace, "Kube API", "list")
+
+		return fmt.Errorf("unable to list Pods: %w", err)
+	}
+
+	detach, attach, reclaim := utils.PlanDetachedPVCs(parents, usedPVCs(config, pods.Items), config.Spec.Policy.DetachGracePeriod.Duration, time.Now())
+
+	byName := map[string]*corev1.PersistentVolumeClaim{}
+	for i := range parents {
+		byName[parents[i].Name] = &parents[i]
+	}
+
+	for _, name := range detach {
+		pvc := byName[name]
+		if pvc.Annotations == nil {
+			pvc.Annotations = map[string]string{}
+		}
+		pvc.Annotations[utils.DetachedAtAnnotation()] = time.Now().UTC().Format(time.RFC3339)
+
+		logger.Info("Mark PVC as detached...", "pvc_name", name)
+
+		if err := r.Client.Update(ctx, pvc); err != nil {
+			metrics.NewError("PersistentVolumeClaim", name, config.Namespace, "Kube API", "update")
+
+			return fmt.Errorf("unable to mark PVC as detached: %w", err)
+		}
+	}
+
+	for _, name := range attach {
+		pvc := byName[name]
+		delete(pvc.Annotations, utils.DetachedAtAnnotation())
+
+		logger.Info("Mark PVC as attached...", "pvc_name", name)
+
+		if err := r.Client.Update(ctx, pvc); err != nil {
+			metrics.NewError("PersistentVolumeClaim", name, config.Namespace, "Kube API", "update")
+
+			return fmt.Errorf("unable to mark PVC as attached: %w", err)
+		}
+	}
+
+	for _, name := range reclaim {
+		logger := logger.WithValues("pvc_name", name)
+
+		pvc, err := r.fetchReclaimablePVC(ctx, config, name)
+		if err != nil {
+			return err
+		} else if pvc == nil {
+			logger.Info("Detached PVC is used again, reclaim skipped")
+			continue
+		}
+
+		policy, err := r.getReclaimPolicy(ctx, pvc, sc)
+		if err != nil {
+			return err
+		} else if policy != corev1.PersistentVolumeReclaimDelete {
+			logger.Info("Detached PVC is retained", "reclaim_policy", policy)
+			continue
+		}
+
+		if deleted, err := r.deleteDetachedPVC(ctx, config, pvc, logger); err != nil {
+			return err
+		} else if !deleted {
+			continue
+		}
+
+		metrics.NewPVCOperation(name, config.Namespace, "reclaim", config.Spec.Capacity.String())
+	}
+
+	return nil
+}
+
+// usedPVCs returns names of PVCs mounted by not finished Pods and eager PVCs of running Pods
+func usedPVCs(config *discoblocksondatiov1.DiskConfig, pods []corev1.Pod) map[string]bool {
+	used := map[string]bool{}
+	for i := range pods {
+		if pods[i].Status.Phase == corev1.PodSucceeded || pods[i].Status.Phase == corev1.PodFailed {
+			continue
+		}
+
+		for _, v := range pods[i].Spec.Volumes {
+			if v.PersistentVolumeClaim != nil {
+				used[v.PersistentVolumeClaim.ClaimName] = true
+			}
+		}
+	}
+
+	// Eager disks are waiting for the next admission of running Pods
+	for prefix := range utils.PlanEagerPVCs(config, pods) {
+		if pvcName, err := utils.RenderResourceName(true, prefix, config.Name, config.Namespace); err == nil {
+			used[pvcName] = true
+		}
+	}
+
+	return used
+}
+
+// fetchReclaimablePVC reads the PVC and Pods from the API server, cache may miss a Pod admitted at the end of grace period.
+// Returns nil if the PVC is gone or it isn't reclaimable anymore.
+func (r *DiskConfigReconciler) fetchReclaimablePVC(ctx context.Context, config *discoblocksondatiov1.DiskConfig, name string) (*corev1.PersistentVolumeClaim, error) {
+	pvc := corev1.PersistentVolumeClaim{}
+	if err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: config.Namespace, Name: name}, &pvc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		metrics.NewError("PersistentVolumeClaim", name, config.Namespace, "Kube API", "get")
+
+		return nil, fmt.Errorf("unable to fetch PVC: %w", err)
+	}
+
+	pods := corev1.PodList{}
+	if err := r.APIReader.List(ctx, &pods, client.InNamespace(config.Namespace)); err != nil {
+		metrics.NewError("Pod", "", config.Namespace, "Kube API", "list")
+
+		return nil, fmt.Errorf("unable to list Pods: %w", err)
+	}
+
+	_, _, reclaim := utils.PlanDetachedPVCs([]corev1.PersistentVolumeClaim{pvc}, usedPVCs(config, pods.Items), config.Spec.Policy.DetachGracePeriod.Duration, time.Now())
+	if len(reclaim) == 0 {
+		return nil, nil
+	}
+
+	return &pvc, nil
+}
+
+// getReclaimPolicy returns reclaim policy of the bound volume, or the one of StorageClass if PVC isn't bound yet
+func (r *DiskConfigReconciler) getReclaimPolicy(ctx context.Context, pvc *corev1.PersistentVolumeClaim, sc *storagev1.StorageClass) (corev1.PersistentVolumeReclaimPolicy, error) {
+	if pvc.Spec.VolumeName == "" {
+		if sc.ReclaimPolicy == nil {
+			return corev1.PersistentVolumeReclaimDelete, nil
+		}
+
+		return *sc.ReclaimPolicy, nil
+	}
+
+	pv := corev1.PersistentVolume{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: pvc.Spec.VolumeName}, &pv); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+
+		metrics.NewError("PersistentVolume", pvc.Spec.VolumeName, "", "Kube API", "get")
+
+		return "", fmt.Errorf("unable to fetch PV: %w", err)
+	}
+
+	return pv.Spec.PersistentVolumeReclaimPolicy, nil
+}
+
+// deleteDetachedPVC deletes the parent PVC if it is unchanged since the check, then removes finalizer of the parent and its children,
+// children follow by owner reference. Returns false if the PVC has been changed meanwhile, for example reused by admission.
+func (r *DiskConfigReconciler) deleteDetachedPVC(ctx context.Context, config *discoblocksondatiov1.DiskConfig, parent *corev1.PersistentVolumeClaim, logger logr.Logger) (bool, error) {
+	logger.Info("Delete detached PVC...")
+
+	if err := r.Client.Delete(ctx, parent, client.Preconditions{UID: &parent.UID, ResourceVersion: &parent.ResourceVersion}); err != nil {
+		switch {
+		case apierrors.IsNotFound(err):
+			return false, nil
+		case apierrors.IsConflict(err):
+			logger.Info("Detached PVC has been changed, reclaim skipped")
+			return false, nil
+		}
+
+		metrics.NewError("PersistentVolumeClaim", parent.Name, parent.Namespace, "Kube API", "delete")
+
+		return false, fmt.Errorf("unable to delete detached PVC: %w", err)
+	}
+
+	children := corev1.PersistentVolumeClaimList{}
+	if err := r.Client.List(ctx, &children, client.InNamespace(parent.Namespace), client.MatchingLabels{utils.ParentLabel(): parent.Name}); err != nil {
+		metrics.NewError("PersistentVolumeClaim", "", parent.Namespace, "Kube API", "list")
+
+		return true, fmt.Errorf("unable to list child PVCs: %w", err)
+	}
+
+	finalizer := utils.RenderFinalizer(config.Name)
+
+	pvcs := []*corev1.PersistentVolumeClaim{parent}
+	for i := range children.Items {
+		pvcs = append(pvcs, &children.Items[i])
+	}
+
+	for _, pvc := range pvcs {
+		if !controllerutil.ContainsFinalizer(pvc, finalizer) {
+			continue
+		}
+
+		// Deletion changed the parent, so finalizer is removed without resource version
+		patch := client.MergeFrom(pvc.DeepCopy())
+		controllerutil.RemoveFinalizer(pvc, finalizer)
+
+		logger.Info("Remove PVC finalizer...", "finalizer", finalizer, "name", pvc.Name)
+
+		if err := r.Client.Patch(ctx, pvc, patch); err != nil && !apierrors.IsNotFound(err) {
+			metrics.NewError("PersistentVolumeClaim", pvc.Name, pvc.Namespace, "Kube API", "patch")
+
+			return true, fmt.Errorf("unable to remove finalizer of PVC: %w", err)
+		}
+	}
+
+	return true, nil
+}
+
+// applyNodeTopology sets the topology StorageClass of the node on the PVC like the admission webhook does
+func (r *DiskConfigReconciler) applyNodeTopology(ctx context.Context, driver *drivers.Driver, sc *storagev1.StorageClass, nodeName string, pvc *corev1.PersistentVolumeClaim, logger logr.Logger) error {
+	node := corev1.Node{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: nodeName}, &node); err != nil {
+		metrics.NewError("Node", nodeName, "", "Kube API", "get")
+
+		return fmt.Errorf("unable to fetch Node: %w", err)
+	}
+
+	scAllowedTopology, err := driver.GetStorageClassAllowedTopology(&node)
+	if err != nil {
+		metrics.NewError("CSI", node.Name, "", sc.Provisioner, "GetStorageClassAllowedTopology")
+
+		return fmt.Errorf("failed to call GetStorageClassAllowedTopology: %w", err)
+	} else if len(scAllowedTopology) == 0 {
+		return nil
+	}
+
+	topologySC, err := utils.NewStorageClass(sc, scAllowedTopology)
+	if err != nil {
+		return fmt.Errorf("unable to render topology StorageClass: %w", err)
+	}
+
+	logger.Info("Create StorageClass...", "topology_sc_name", topologySC.Name)
+
+	if err := r.Client.Create(ctx, topologySC); err != nil && !apierrors.IsAlreadyExists(err) {
+		metrics.NewError("StorageClass", topologySC.Name, "", "Kube API", "create")
+
+		return fmt.Errorf("unable to create topology StorageClass: %w", err)
+	}
+
+	pvc.Spec.StorageClassName = &topologySC.Name
+
+	return nil
+}
+
+// getPoolNodes returns nodes of the running Pods of DiskConfig in a stable order
+func (r *DiskConfigReconciler) getPoolNodes(ctx context.Context, config *discoblocksondatiov1.DiskConfig) ([]string, error) {
+	podSelector, err := utils.RenderPodSelector(config)
+	if err != nil {
+		return nil, err
+	}
+
+	pods := corev1.PodList{}
+	if err := r.Client.List(ctx, &pods, &client.ListOptions{
+		Namespace:     config.Namespace,
+		LabelSelector: podSelector,
+	}); err != nil {
+		metrics.NewError("Pod", "", config.Namespace, "Kube API", "list")
+
+		return nil, fmt.Errorf("unable to list Pods: %w", err)
+	}
+
+	found := map[string]bool{}
+	nodes := []string{}
+	for i := range pods.Items {
+		nodeName := pods.Items[i].Spec.NodeName
+		if nodeName == "" || pods.Items[i].Status.Phase != corev1.PodRunning || found[nodeName] {
+			continue
+		}
+
+		found[nodeName] = true
+		nodes = append(nodes, nodeName)
+	}
+
+	sort.Strings(nodes)
+
+	return nodes, nil
+}
+
+// ensureVolumeAttributesClass rolls out VolumeAttributesClass of DiskConfig to the existing PVCs
+func (r *DiskConfigReconciler) ensureVolumeAttributesClass(ctx context.Context, config *discoblocksondatiov1.DiskConfig, logger logr.Logger) error {
+	label, err := labels.NewRequirement(utils.ConfigLabel(), selection.Equals, []string{config.Name})
+	if err != nil {
+		logger.Error(err, "Unable to parse PVC label selector")
+		return nil
+	}
+
+	logger.Info("Fetch PVCs...")
+
+	pvcList := corev1.PersistentVolumeClaimList{}
+	if err := r.Client.List(ctx, &pvcList, &client.ListOptions{
+		Namespace:     config.Namespace,
+		LabelSelector: labels.NewSelector().Add(*label),
+	}); err != nil {
+		metrics.NewError("PersistentVolumeClaim", "", config.Namespace, "Kube API", "list")
+
+		return fmt.Errorf("unable to list PVCs: %w", err)
+	}
+
+	for i := range pvcList.Items {
+		if pvcList.Items[i].DeletionTimestamp != nil {
+			continue
+		}
+
+		if err := applyVolumeAttributesClass(ctx, r.Client, &pvcList.Items[i], config.Spec.VolumeAttributesClassName, logger); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyVolumeAttributesClass sets VolumeAttributesClass of PVC if it differs, it is skipped if cluster doesn't serve the API
+func applyVolumeAttributesClass(ctx context.Context, kubeClient client.Client, pvc *corev1.PersistentVolumeClaim, name string, logger logr.Logger) error {
+	supported, err := utils.IsVolumeAttributesClassSupported(kubeClient.RESTMapper())
+	if err != nil {
+		metrics.NewError("VolumeAttributesClass", name, "", "Kube API", "mapping")
+
+		return err
+	} else if !supported {
+		logger.Info("VolumeAttributesClass not supported by the cluster")
+		return nil
+	}
+
+	current, err := utils.GetVolumeAttributesClassName(ctx, kubeClient, client.ObjectKeyFromObject(pvc))
+	if err != nil {
+		metrics.NewError("PersistentVolumeClaim", pvc.Name, pvc.Namespace, "Kube API", "get")
+
+		return err
+	} else if current == name {
+		return nil
+	}
+
+	patch, err := utils.RenderVolumeAttributesClassPatch(name)
+	if err != nil {
+		logger.Error(err, "Unable to render VolumeAttributesClass patch")
+		return nil
+	}
+
+	logger.Info("Patch PVC VolumeAttributesClass...", "pvc_name", pvc.Name, "vac_name", name)
+
+	if err := kubeClient.Patch(ctx, pvc, patch); err != nil {
+		metrics.NewError("PersistentVolumeClaim", pvc.Name, pvc.Namespace, "Kube API", "patch")
+
+		return fmt.Errorf("unable to patch VolumeAttributesClass of PVC: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DiskConfigReconciler) ensureServiceMonitor(ctx context.Context, config *discoblocksondatiov1.DiskConfig, logger logr.Logger) error {
+	supported, err := utils.IsServiceMonitorSupported(r.RESTMapper())
+	if err != nil {
+		metrics.NewError("ServiceMonitor", "", config.Namespace, "Kube API", "mapping")
+
+		return err
+	} else if !supported {
+		logger.Info("ServiceMonitor CRD not found")
+		return nil
+	}
+
+	sm, err := utils.RenderServiceMonitor(config, r.MetricsNamespace)
+	if err != nil {
+		logger.Error(err, "Unable to render ServiceMonitor")
+		return nil
+	}
+
+	logger = logger.WithValues("sm_name", sm.GetName())
+	logger.Info("Create ServiceMonitor...")
+
+	if err := r.Client.Create(ctx, sm); err != nil && !apierrors.IsAlreadyExists(err) {
+		metrics.NewError("ServiceMonitor", sm.GetName(), sm.GetNamespace(), "Kube API", "create")
+
+		logger.Info("Failed to create ServiceMonitor", "error", err.Error())
+		return fmt.Errorf("unable to create ServiceMonitor: %w", err)
+	}
+
+	return nil
+}
+
+// deleteServiceMonitors removes ServiceMonitors of the deleted DiskConfig. Owner reference cleans them up in general,
+// but objects without owner, like restored ones or created before ServiceMonitor was disabled, would remain.
+func (r *DiskConfigReconciler) deleteServiceMonitors(ctx context.Context, configName, configNamespace string, logger logr.Logger) error {
+	supported, err := utils.IsServiceMonitorSupported(r.RESTMapper())
+	if err != nil {
+		metrics.NewError("ServiceMonitor", "", configNamespace, "Kube API", "mapping")
+
+		return err
+	} else if !supported {
+		return nil
+	}
+
+	sm := unstructured.Unstructured{}
+	sm.SetGroupVersionKind(utils.ServiceMonitorGVK)
+
+	logger.Info("Delete ServiceMonitors...")
+
+	if err := r.Client.DeleteAllOf(ctx, &sm, client.InNamespace(configNamespace), client.MatchingLabels{
+		"app":                       "discoblocks",
+		utils.DiskConfigNameLabel(): configName,
+	}); err != nil && !apierrors.IsNotFound(err) {
+		metrics.NewError("ServiceMonitor", "", configNamespace, "Kube API", "deletecollection")
+
+		logger.Info("Failed to delete ServiceMonitors", "error", err.Error())
+		return fmt.Errorf("unable to delete ServiceMonitors: %w", err)
+	}
+
+	return nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *DiskConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
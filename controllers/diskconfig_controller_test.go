@@ -0,0 +1,689 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"github.com/ondat/discoblocks/mutators"
+	"github.com/ondat/discoblocks/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// deleteAllOfRecorder records kind, namespace and labels of collection deletes
+type deleteAllOfRecorder struct {
+	client.Client
+	deletes []string
+}
+
+func (c *deleteAllOfRecorder) DeleteAllOf(_ context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	options := client.DeleteAllOfOptions{}
+	options.ApplyOptions(opts)
+
+	c.deletes = append(c.deletes, obj.GetObjectKind().GroupVersionKind().Kind+"/"+options.Namespace+"/"+options.LabelSelector.String())
+
+	return nil
+}
+
+func TestReconcileDeleteOrphanServiceMonitors(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	cases := map[string]struct {
+		supported       bool
+		expectedDeletes []string
+	}{
+		"not supported": {},
+		"supported": {
+			supported:       true,
+			expectedDeletes: []string{"ServiceMonitor/default/app=discoblocks,discoblocks/dc-name=config"},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{utils.ServiceMonitorGVK.GroupVersion()})
+			if c.supported {
+				mapper.Add(utils.ServiceMonitorGVK, meta.RESTScopeNamespace)
+			}
+
+			kubeClient := &deleteAllOfRecorder{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).Build(),
+			}
+
+			r := DiskConfigReconciler{
+				Client: kubeClient,
+			}
+
+			_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "config"}})
+			require.Nil(t, err, "unexpected error")
+
+			assert.Equal(t, c.expectedDeletes, kubeClient.deletes, "invalid deletes")
+		})
+	}
+}
+
+func TestEnsurePool(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	newPooledPVC := func(name string, age time.Duration) client.Object {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "default",
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+				Labels: map[string]string{
+					utils.PoolLabel(): "config",
+				},
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		poolSize      uint8
+		expectedNames []string
+	}{
+		"expired reclaimed": {
+			poolSize:      3,
+			expectedNames: []string{"fresh", "old"},
+		},
+		"shrunk": {
+			poolSize:      1,
+			expectedNames: []string{"fresh"},
+		},
+		"disabled": {
+			expectedNames: []string{},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				newPooledPVC("fresh", time.Minute),
+				newPooledPVC("old", 10*time.Minute),
+				newPooledPVC("expired", 2*time.Hour),
+			).Build()
+
+			r := DiskConfigReconciler{
+				Client: kubeClient,
+			}
+
+			config := discoblocksondatiov1.DiskConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "config",
+					Namespace: "default",
+				},
+				Spec: discoblocksondatiov1.DiskConfigSpec{
+					Policy: discoblocksondatiov1.Policy{
+						PoolSize: c.poolSize,
+						PoolTTL:  metav1.Duration{Duration: time.Hour},
+					},
+				},
+			}
+
+			// Driver of unknown provisioner is not found, so missing PVCs aren't created
+			sc := storagev1.StorageClass{Provisioner: "unknown"}
+
+			require.Nil(t, r.ensurePool(context.Background(), &config, &sc, logr.Discard()), "unexpected error")
+
+			pvcs := corev1.PersistentVolumeClaimList{}
+			require.Nil(t, kubeClient.List(context.Background(), &pvcs), "unable to list PVCs")
+
+			names := []string{}
+			for i := range pvcs.Items {
+				names = append(names, pvcs.Items[i].Name)
+			}
+			sort.Strings(names)
+
+			assert.Equal(t, c.expectedNames, names, "invalid pooled PVCs")
+		})
+	}
+}
+
+func TestEnsurePoolTopology(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	bindingMode := storagev1.VolumeBindingWaitForFirstConsumer
+	sc := storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sc",
+			UID:  "sc-uid",
+		},
+		Provisioner:       "ebs.csi.aws.com",
+		VolumeBindingMode: &bindingMode,
+	}
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&sc,
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "node-a",
+				Labels: map[string]string{"topology.kubernetes.io/zone": "zone-a"},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pod",
+				Namespace: "default",
+				Labels:    map[string]string{"app": "nginx"},
+			},
+			Spec: corev1.PodSpec{
+				NodeName: "node-a",
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+			},
+		},
+	).Build()
+
+	r := DiskConfigReconciler{
+		Client: kubeClient,
+	}
+
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+			UID:       "config-uid",
+		},
+		Spec: discoblocksondatiov1.DiskConfigSpec{
+			StorageClassName: sc.Name,
+			Capacity:         resource.MustParse("1Gi"),
+			PodSelector:      map[string]string{"app": "nginx"},
+			Policy: discoblocksondatiov1.Policy{
+				PoolSize: 2,
+				PoolTTL:  metav1.Duration{Duration: time.Hour},
+			},
+		},
+	}
+
+	require.Nil(t, r.ensurePool(context.Background(), &config, &sc, logr.Discard()), "unexpected error")
+
+	pvcs := corev1.PersistentVolumeClaimList{}
+	require.Nil(t, kubeClient.List(context.Background(), &pvcs, client.MatchingLabels{utils.PoolLabel(): config.Name}), "unable to list PVCs")
+	require.Len(t, pvcs.Items, 2, "invalid number of pooled PVCs")
+
+	topologySC := storagev1.StorageClass{}
+	require.NotNil(t, pvcs.Items[0].Spec.StorageClassName, "missing StorageClass")
+	require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Name: *pvcs.Items[0].Spec.StorageClassName}, &topologySC), "unable to fetch topology StorageClass")
+
+	assert.True(t, utils.IsDerivedStorageClass(topologySC.Name, &sc), "invalid topology StorageClass")
+	assert.Equal(t, storagev1.VolumeBindingImmediate, *topologySC.VolumeBindingMode, "invalid binding mode")
+	require.Len(t, topologySC.AllowedTopologies, 1, "invalid topology")
+	assert.Equal(t, []string{"zone-a"}, topologySC.AllowedTopologies[0].MatchLabelExpressions[0].Values, "invalid zone")
+
+	for i := range pvcs.Items {
+		assert.Equal(t, topologySC.Name, *pvcs.Items[i].Spec.StorageClassName, "invalid StorageClass of pooled PVC")
+		assert.Equal(t, "node-a", pvcs.Items[i].Annotations[utils.SelectedNodeAnnotation], "invalid node of pooled PVC")
+		assert.Equal(t, resource.MustParse("1Gi"), pvcs.Items[i].Spec.Resources.Requests[corev1.ResourceStorage], "invalid capacity of pooled PVC")
+	}
+}
+
+func TestEnsureEagerPVCs(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	cases := map[string]struct {
+		provisionMode discoblocksondatiov1.ProvisionMode
+		listFails     bool
+		expectedError bool
+	}{
+		"lazy skips Pods": {
+			provisionMode: discoblocksondatiov1.ProvisionModeLazy,
+			listFails:     true,
+		},
+		"eager lists Pods": {
+			provisionMode: discoblocksondatiov1.ProvisionModeEager,
+			listFails:     true,
+			expectedError: true,
+		},
+		"eager without driver": {
+			provisionMode: discoblocksondatiov1.ProvisionModeEager,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pod",
+					Namespace: "default",
+					Labels:    map[string]string{"app": "nginx"},
+				},
+				Status: corev1.PodStatus{
+					Phase: corev1.PodRunning,
+				},
+			}
+
+			var kubeClient client.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(&pod).Build()
+			if c.listFails {
+				kubeClient = &failingListClient{Client: kubeClient}
+			}
+
+			r := DiskConfigReconciler{
+				Client: kubeClient,
+			}
+
+			config := discoblocksondatiov1.DiskConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "config",
+					Namespace: "default",
+					UID:       "uid",
+				},
+				Spec: discoblocksondatiov1.DiskConfigSpec{
+					AvailabilityMode: discoblocksondatiov1.ReadWriteSame,
+					ProvisionMode:    c.provisionMode,
+					PodSelector:      map[string]string{"app": "nginx"},
+				},
+			}
+
+			// Driver of unknown provisioner is not found, so planned PVCs aren't created
+			sc := storagev1.StorageClass{Provisioner: "unknown"}
+
+			err := r.ensureEagerPVCs(context.Background(), &config, &sc, logr.Discard())
+			assert.Equal(t, c.expectedError, err != nil, "invalid error")
+		})
+	}
+}
+
+func TestEnsureEagerPVCsReusedAtAdmission(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	sc := storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sc",
+		},
+		Provisioner: "ebs.csi.aws.com",
+	}
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+			UID:       "config-uid",
+		},
+		Spec: discoblocksondatiov1.DiskConfigSpec{
+			StorageClassName:  sc.Name,
+			Capacity:          resource.MustParse("1Gi"),
+			AvailabilityMode:  discoblocksondatiov1.ReadWriteSame,
+			ProvisionMode:     discoblocksondatiov1.ProvisionModeEager,
+			MetricsSource:     discoblocksondatiov1.MetricsSourceKubelet,
+			MountPointPattern: "/media/discoblocks/config-%d",
+			PodSelector:       map[string]string{"app": "nginx"},
+			Policy: discoblocksondatiov1.Policy{
+				MaximumNumberOfDisks: 1,
+				InitialNumberOfDisks: 1,
+			},
+		},
+	}
+	newPod := func(name string) *corev1.Pod {
+		return &corev1.Pod{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "v1",
+				Kind:       "Pod",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: config.Namespace,
+				Labels:    map[string]string{"app": "nginx"},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:  "app",
+					Image: "nginx",
+				}},
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+			},
+		}
+	}
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&sc, &config, newPod("running")).Build()
+
+	r := DiskConfigReconciler{
+		Client: kubeClient,
+	}
+
+	require.Nil(t, r.ensureEagerPVCs(context.Background(), &config, &sc, logr.Discard()), "unable to ensure eager PVCs")
+
+	pvcs := corev1.PersistentVolumeClaimList{}
+	require.Nil(t, kubeClient.List(context.Background(), &pvcs), "unable to list PVCs")
+	require.Len(t, pvcs.Items, 1, "eager PVC not created")
+
+	eagerPVC := pvcs.Items[0]
+
+	expectedName, err := utils.RenderResourceName(true, utils.GetNamePrefix(config.Spec.AvailabilityMode, string(config.UID), ""), config.Name, config.Namespace)
+	require.Nil(t, err, "unable to render PVC name")
+	assert.Equal(t, expectedName, eagerPVC.Name, "invalid PVC name")
+	assert.Equal(t, config.Name, eagerPVC.Labels[utils.ConfigLabel()], "invalid config label")
+	assert.Contains(t, eagerPVC.Finalizers, utils.RenderFinalizer(config.Name), "missing finalizer")
+
+	decoder, err := admission.NewDecoder(scheme)
+	require.Nil(t, err, "unable to create decoder")
+
+	mutator := mutators.NewPodMutator(kubeClient, false, false, false, 0, nil, 0, nil, nil)
+	require.Nil(t, mutator.InjectDecoder(decoder), "unable to inject decoder")
+
+	raw, err := json.Marshal(newPod("admitted"))
+	require.Nil(t, err, "unable to marshal Pod")
+
+	resp := mutator.Handle(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Name:      "admitted",
+			Namespace: config.Namespace,
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	})
+	require.True(t, resp.Allowed, "Pod not admitted")
+
+	patches, err := json.Marshal(resp.Patches)
+	require.Nil(t, err, "unable to marshal patches")
+	assert.Contains(t, string(patches), eagerPVC.Name, "eager PVC not attached")
+
+	require.Nil(t, kubeClient.List(context.Background(), &pvcs), "unable to list PVCs")
+	assert.Len(t, pvcs.Items, 1, "eager PVC not reused at admission")
+}
+
+// failingListClient fails every list request
+type failingListClient struct {
+	client.Client
+}
+
+func (c *failingListClient) List(context.Context, client.ObjectList, ...client.ListOption) error {
+	return errors.New("list failed")
+}
+
+func TestReclaimDetachedPVCs(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	finalizer := utils.RenderFinalizer("config")
+
+	newPVC := func(name, volumeName string, detachedFor time.Duration) *corev1.PersistentVolumeClaim {
+		pvc := corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       name,
+				Namespace:  "default",
+				Labels:     map[string]string{utils.ConfigLabel(): "config"},
+				Finalizers: []string{finalizer},
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				VolumeName: volumeName,
+			},
+		}
+
+		if detachedFor != 0 {
+			pvc.Annotations = map[string]string{
+				utils.DetachedAtAnnotation(): time.Now().Add(-detachedFor).UTC().Format(time.RFC3339),
+			}
+		}
+
+		return &pvc
+	}
+
+	newPV := func(name string, policy corev1.PersistentVolumeReclaimPolicy) *corev1.PersistentVolume {
+		return &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Spec: corev1.PersistentVolumeSpec{
+				PersistentVolumeReclaimPolicy: policy,
+			},
+		}
+	}
+
+	child := newPVC("expired-child", "pv-expired-child", 0)
+	child.Labels[utils.ParentLabel()] = "expired"
+
+	restartedPod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "restarted",
+			Namespace: "default",
+		},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{
+				{
+					Name: "disk",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "restarted"},
+					},
+				},
+			},
+		},
+	}
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newPVC("detached", "pv-detached", 0),
+		newPVC("restarted", "pv-restarted", time.Minute),
+		newPVC("expired", "pv-expired", time.Hour),
+		child,
+		newPVC("retained", "pv-retained", time.Hour),
+		newPV("pv-expired", corev1.PersistentVolumeReclaimDelete),
+		newPV("pv-retained", corev1.PersistentVolumeReclaimRetain),
+		&restartedPod,
+	).Build()
+
+	r := DiskConfigReconciler{
+		Client:    kubeClient,
+		APIReader: kubeClient,
+	}
+
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+		},
+		Spec: discoblocksondatiov1.DiskConfigSpec{
+			AvailabilityMode: discoblocksondatiov1.ReadWriteOnce,
+			Policy: discoblocksondatiov1.Policy{
+				DetachGracePeriod: metav1.Duration{Duration: 5 * time.Minute},
+			},
+		},
+	}
+
+	// Disks of ReadWriteOnce are never reused, so they are never reclaimed
+	require.Nil(t, r.reclaimDetachedPVCs(context.Background(), &config, &storagev1.StorageClass{}, logr.Discard()), "unexpected error")
+
+	pvc := corev1.PersistentVolumeClaim{}
+
+	require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Name: "expired", Namespace: "default"}, &pvc), "expired PVC of ReadWriteOnce deleted")
+	require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Name: "detached", Namespace: "default"}, &pvc), "detached PVC of ReadWriteOnce deleted")
+	assert.NotContains(t, pvc.Annotations, utils.DetachedAtAnnotation(), "detached PVC of ReadWriteOnce marked")
+
+	config.Spec.AvailabilityMode = discoblocksondatiov1.ReadWriteSame
+
+	require.Nil(t, r.reclaimDetachedPVCs(context.Background(), &config, &storagev1.StorageClass{}, logr.Discard()), "unexpected error")
+
+	require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Name: "detached", Namespace: "default"}, &pvc), "detached PVC deleted")
+	assert.Contains(t, pvc.Annotations, utils.DetachedAtAnnotation(), "detached PVC not marked")
+
+	require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Name: "restarted", Namespace: "default"}, &pvc), "restarted PVC deleted")
+	assert.NotContains(t, pvc.Annotations, utils.DetachedAtAnnotation(), "restarted PVC still marked")
+
+	require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Name: "retained", Namespace: "default"}, &pvc), "retained PVC deleted")
+
+	assert.True(t, apierrors.IsNotFound(kubeClient.Get(context.Background(), types.NamespacedName{Name: "expired", Namespace: "default"}, &pvc)), "expired PVC not deleted")
+
+	require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Name: "expired-child", Namespace: "default"}, &pvc), "unable to fetch child PVC")
+	assert.Empty(t, pvc.Finalizers, "finalizer of child PVC not removed")
+}
+
+// reusingReader simulates admission reusing the PVC right after reclaim fetched it
+type reusingReader struct {
+	client.Client
+}
+
+func (r *reusingReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if err := r.Client.Get(ctx, key, obj); err != nil {
+		return err
+	}
+
+	pvc := corev1.PersistentVolumeClaim{}
+	if err := r.Client.Get(ctx, key, &pvc); err != nil {
+		return err
+	}
+
+	delete(pvc.Annotations, utils.DetachedAtAnnotation())
+
+	return r.Client.Update(ctx, &pvc)
+}
+
+func TestReclaimDetachedPVCsAtGraceBoundary(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	const grace = 5 * time.Minute
+
+	newPVC := func(detached bool) *corev1.PersistentVolumeClaim {
+		pvc := corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "pvc",
+				Namespace:  "default",
+				Labels:     map[string]string{utils.ConfigLabel(): "config"},
+				Finalizers: []string{utils.RenderFinalizer("config")},
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				VolumeName: "pv",
+			},
+		}
+
+		if detached {
+			pvc.Annotations = map[string]string{
+				utils.DetachedAtAnnotation(): time.Now().Add(-grace - time.Second).UTC().Format(time.RFC3339),
+			}
+		}
+
+		return &pvc
+	}
+
+	pv := corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "pv",
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimDelete,
+		},
+	}
+
+	restartedPod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "restarted",
+			Namespace: "default",
+		},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{
+				{
+					Name: "disk",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "pvc"},
+					},
+				},
+			},
+		},
+	}
+
+	cases := map[string]struct {
+		reader          func(kubeClient client.Client) client.Reader
+		expectedDeleted bool
+	}{
+		"detached": {
+			reader: func(kubeClient client.Client) client.Reader {
+				return kubeClient
+			},
+			expectedDeleted: true,
+		},
+		"restarted Pod missing from cache": {
+			reader: func(client.Client) client.Reader {
+				return fake.NewClientBuilder().WithScheme(scheme).WithObjects(newPVC(true), &restartedPod).Build()
+			},
+		},
+		"reused by admission before check": {
+			reader: func(client.Client) client.Reader {
+				return fake.NewClientBuilder().WithScheme(scheme).WithObjects(newPVC(false)).Build()
+			},
+		},
+		"reused by admission after check": {
+			reader: func(kubeClient client.Client) client.Reader {
+				return &reusingReader{Client: kubeClient}
+			},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			// Cache doesn't see the restarted Pod yet
+			kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(newPVC(true), pv.DeepCopy()).Build()
+
+			r := DiskConfigReconciler{
+				Client:    kubeClient,
+				APIReader: c.reader(kubeClient),
+			}
+
+			config := discoblocksondatiov1.DiskConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "config",
+					Namespace: "default",
+				},
+				Spec: discoblocksondatiov1.DiskConfigSpec{
+					AvailabilityMode: discoblocksondatiov1.ReadWriteSame,
+					Policy: discoblocksondatiov1.Policy{
+						DetachGracePeriod: metav1.Duration{Duration: grace},
+					},
+				},
+			}
+
+			require.Nil(t, r.reclaimDetachedPVCs(context.Background(), &config, &storagev1.StorageClass{}, logr.Discard()), "unexpected error")
+
+			pvc := corev1.PersistentVolumeClaim{}
+			err := kubeClient.Get(context.Background(), types.NamespacedName{Name: "pvc", Namespace: "default"}, &pvc)
+			if c.expectedDeleted {
+				assert.True(t, apierrors.IsNotFound(err), "detached PVC not deleted")
+				return
+			}
+
+			require.Nil(t, err, "used PVC deleted")
+			assert.Nil(t, pvc.DeletionTimestamp, "used PVC is being deleted")
+			assert.Contains(t, pvc.Finalizers, utils.RenderFinalizer("config"), "finalizer of used PVC removed")
+		})
+	}
+}
@@ -0,0 +1,375 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"github.com/ondat/discoblocks/pkg/utils"
+)
+
+// testProvisioner is the driver of the specs, nothing provisions volumes in the test environment,
+// so specs bind PVCs in place of the CSI driver
+const testProvisioner = "ebs.csi.aws.com"
+
+// testCoolDown is the minimum cool down, new Pods are monitored only after it
+const testCoolDown = 10 * time.Second
+
+// testNodeIPs are the host IPs of the nodes of the specs, volume monitor finds nodes of Pods by them
+var testNodeIPs = map[string]string{
+	"node-a": "10.0.0.1",
+	"node-b": "10.0.0.2",
+}
+
+// kubeletVolumeStatsTemplate renders kubelet volume stats of a PVC by namespace, name, capacity and used bytes
+const kubeletVolumeStatsTemplate = `kubelet_volume_stats_capacity_bytes{namespace="%[1]s",persistentvolumeclaim="%[2]s"} %[3]d
+kubelet_volume_stats_used_bytes{namespace="%[1]s",persistentvolumeclaim="%[2]s"} %[4]d
+kubelet_volume_stats_available_bytes{namespace="%[1]s",persistentvolumeclaim="%[2]s"} %[5]d
+`
+
+// renderKubeletVolumeStats returns kubelet metrics of the PVC filled to the given percentage of 1Gi
+func renderKubeletVolumeStats(pvc *corev1.PersistentVolumeClaim, usedPercentage int64) string {
+	const capacity = 1024 * 1024 * 1024
+	used := capacity * usedPercentage / 100
+
+	return fmt.Sprintf(kubeletVolumeStatsTemplate, pvc.Namespace, pvc.Name, capacity, used, capacity-used)
+}
+
+// volumeFixture is a DiskConfig with its own namespace and StorageClass, and a Pod selected by the config
+type volumeFixture struct {
+	namespace string
+	sc        *storagev1.StorageClass
+	config    *discoblocksondatiov1.DiskConfig
+	pod       *corev1.Pod
+}
+
+// newVolumeFixture renders the objects of the fixture, Pod is placed on a node if nodeName is set
+func newVolumeFixture(name, nodeName string) *volumeFixture {
+	expandable := true
+	bindingMode := storagev1.VolumeBindingWaitForFirstConsumer
+
+	return &volumeFixture{
+		namespace: name,
+		sc: &storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Provisioner:          testProvisioner,
+			AllowVolumeExpansion: &expandable,
+			VolumeBindingMode:    &bindingMode,
+		},
+		config: &discoblocksondatiov1.DiskConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: name,
+			},
+			Spec: discoblocksondatiov1.DiskConfigSpec{
+				StorageClassName: name,
+				Capacity:         resource.MustParse("1Gi"),
+				MetricsSource:    discoblocksondatiov1.MetricsSourceKubelet,
+				PodSelector:      map[string]string{"app": name},
+				Policy: discoblocksondatiov1.Policy{
+					UpscaleTriggerPercentage: intstr.FromInt(80),
+					ExtendCapacity:           resource.MustParse("1Gi"),
+					MaximumCapacityOfDisk:    resource.MustParse("10Gi"),
+					CoolDown:                 metav1.Duration{Duration: testCoolDown},
+				},
+			},
+		},
+		pod: &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: name,
+				Labels:    map[string]string{"app": name},
+			},
+			Spec: corev1.PodSpec{
+				NodeName: nodeName,
+				Containers: []corev1.Container{{
+					Name:  "app",
+					Image: "nginx",
+				}},
+			},
+		},
+	}
+}
+
+// create creates the objects of the fixture, admission webhooks validate the config and attach the disk to the Pod
+func (f *volumeFixture) create() {
+	Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: f.namespace}})).To(Succeed())
+	Expect(k8sClient.Create(ctx, f.sc)).To(Succeed())
+	Expect(k8sClient.Create(ctx, f.config)).To(Succeed())
+	Expect(k8sClient.Create(ctx, f.pod)).To(Succeed())
+}
+
+// waitForPVC waits for the first disk of the config
+func (f *volumeFixture) waitForPVC() *corev1.PersistentVolumeClaim {
+	pvcs := corev1.PersistentVolumeClaimList{}
+	Eventually(func() ([]corev1.PersistentVolumeClaim, error) {
+		err := k8sClient.List(ctx, &pvcs, client.InNamespace(f.namespace), client.MatchingLabels{utils.ConfigLabel(): f.config.Name})
+		return pvcs.Items, err
+	}).Should(HaveLen(1))
+
+	return &pvcs.Items[0]
+}
+
+// bindPVC acts as the CSI driver and binds the PVC with the requested capacity
+func bindPVC(pvc *corev1.PersistentVolumeClaim) {
+	pvc.Status.Phase = corev1.ClaimBound
+	pvc.Status.AccessModes = pvc.Spec.AccessModes
+	pvc.Status.Capacity = corev1.ResourceList{corev1.ResourceStorage: pvc.Spec.Resources.Requests[corev1.ResourceStorage]}
+	Expect(k8sClient.Status().Update(ctx, pvc)).To(Succeed())
+}
+
+// runPod acts as kubelet and marks the Pod running, Pods without node run on the first one
+func runPod(pod *corev1.Pod) {
+	Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(pod), pod)).To(Succeed())
+
+	hostIP, ok := testNodeIPs[pod.Spec.NodeName]
+	if !ok {
+		hostIP = testNodeIPs["node-a"]
+	}
+
+	pod.Status.Phase = corev1.PodRunning
+	pod.Status.HostIP = hostIP
+	Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+}
+
+// waitForMonitoring waits until the Pod is old enough to be monitored
+func waitForMonitoring(pod *corev1.Pod) {
+	time.Sleep(time.Until(pod.CreationTimestamp.Add(testCoolDown + time.Second)))
+}
+
+// pvcCapacity returns the requested capacity of the PVC
+func pvcCapacity(pvc *corev1.PersistentVolumeClaim) func() (string, error) {
+	return func() (string, error) {
+		actual := corev1.PersistentVolumeClaim{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(pvc), &actual); err != nil {
+			return "", err
+		}
+
+		capacity := actual.Spec.Resources.Requests[corev1.ResourceStorage]
+
+		return capacity.String(), nil
+	}
+}
+
+var _ = Describe("Volume lifecycle", func() {
+	It("attaches, tracks and resizes the disk of a new Pod", func() {
+		f := newVolumeFixture("happy-path", "node-a")
+		f.create()
+
+		By("attaching the disk at Pod admission")
+		pvc := f.waitForPVC()
+		Expect(pvc.Finalizers).To(ContainElement(utils.RenderFinalizer(f.config.Name)))
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(f.pod), f.pod)).To(Succeed())
+		Expect(f.pod.Spec.Volumes).To(ContainElement(HaveField("VolumeSource.PersistentVolumeClaim.ClaimName", pvc.Name)))
+
+		By("tracking phase of the disk in the config status")
+		bindPVC(pvc)
+
+		Eventually(func() ([]metav1.Condition, error) {
+			config := discoblocksondatiov1.DiskConfig{}
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(f.config), &config)
+			return config.Status.Conditions, err
+		}).Should(ContainElement(And(
+			HaveField("Reason", pvcConditionReason),
+			HaveField("Message", pvc.Name),
+			HaveField("Type", string(corev1.ClaimBound)),
+			HaveField("Status", metav1.ConditionTrue),
+		)))
+
+		By("resizing the disk above the upscale trigger")
+		runPod(f.pod)
+		kubeletMetrics.Set(renderKubeletVolumeStats(pvc, 95))
+		waitForMonitoring(f.pod)
+
+		Eventually(func() (string, error) {
+			pvcReconciler.MonitorVolumes()
+			return pvcCapacity(pvc)()
+		}, 30*time.Second, time.Second).Should(Equal("2Gi"))
+	})
+
+	It("resizes the disk only once the upscale trigger is reached", func() {
+		f := newVolumeFixture("resize-decision", "node-b")
+		f.create()
+
+		pvc := f.waitForPVC()
+		bindPVC(pvc)
+		runPod(f.pod)
+		waitForMonitoring(f.pod)
+
+		By("keeping the disk below the upscale trigger")
+		kubeletMetrics.Set(renderKubeletVolumeStats(pvc, 50))
+
+		Consistently(func() (string, error) {
+			pvcReconciler.MonitorVolumes()
+			return pvcCapacity(pvc)()
+		}, 5*time.Second, time.Second).Should(Equal("1Gi"))
+
+		By("resizing the disk above the upscale trigger")
+		kubeletMetrics.Set(renderKubeletVolumeStats(pvc, 90))
+
+		Eventually(func() (string, error) {
+			pvcReconciler.MonitorVolumes()
+			return pvcCapacity(pvc)()
+		}, 30*time.Second, time.Second).Should(Equal("2Gi"))
+	})
+
+	It("admits every sidecar Pod of a namespace", func() {
+		f := newVolumeFixture("sidecar-pods", "")
+		f.config.Spec.MetricsSource = discoblocksondatiov1.MetricsSourceSidecar
+		f.create()
+
+		By("creating the metrics certificate Secret at the first admission")
+		secret := corev1.Secret{}
+		Eventually(func() error {
+			return k8sClient.Get(ctx, types.NamespacedName{Namespace: f.namespace, Name: "discoblocks-metrics-cert"}, &secret)
+		}).Should(Succeed())
+
+		By("reusing the existing Secret at the next admissions")
+		for i := 0; i < 2; i++ {
+			pod := f.pod.DeepCopy()
+			pod.ObjectMeta = metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-%d", f.pod.Name, i),
+				Namespace: f.namespace,
+				Labels:    map[string]string{"app": f.config.Name},
+			}
+			pod.Spec.Containers = pod.Spec.Containers[:1]
+			pod.Spec.Volumes = nil
+
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			Expect(pod.Spec.Containers).To(ContainElement(HaveField("Name", "discoblocks-metrics")))
+		}
+	})
+
+	It("injects metrics sidecars into a Pod of sidecar metrics source", func() {
+		f := newVolumeFixture("sidecar-source", "")
+		f.config.Spec.MetricsSource = discoblocksondatiov1.MetricsSourceSidecar
+		f.create()
+
+		By("attaching the disk at Pod admission")
+		pvc := f.waitForPVC()
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(f.pod), f.pod)).To(Succeed())
+		Expect(f.pod.Spec.Volumes).To(ContainElement(HaveField("VolumeSource.PersistentVolumeClaim.ClaimName", pvc.Name)))
+
+		By("injecting the metrics and proxy sidecars")
+		Expect(f.pod.Spec.Containers).To(ContainElements(
+			HaveField("Name", "discoblocks-metrics"),
+			HaveField("Name", "discoblocks-metrics-proxy"),
+		))
+		Expect(f.pod.Spec.Volumes).To(ContainElement(HaveField("VolumeSource.Secret.SecretName", "discoblocks-metrics-cert")))
+
+		By("copying the metrics certificates into the namespace of the Pod")
+		secret := corev1.Secret{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: f.namespace, Name: "discoblocks-metrics-cert"}, &secret)).To(Succeed())
+		Expect(secret.Data).To(HaveKeyWithValue("ca.crt", []byte("ca.crt")))
+		Expect(secret.Data).To(HaveKeyWithValue("tls.crt", []byte("tls.crt")))
+		Expect(secret.Data).To(HaveKeyWithValue("tls.key", []byte("tls.key")))
+
+		By("skipping the Pod in volume monitor until its sidecars are ready")
+		bindPVC(pvc)
+		runPod(f.pod)
+		kubeletMetrics.Set(renderKubeletVolumeStats(pvc, 95))
+		waitForMonitoring(f.pod)
+
+		Consistently(func() (string, error) {
+			pvcReconciler.MonitorVolumes()
+			return pvcCapacity(pvc)()
+		}, 5*time.Second, time.Second).Should(Equal("1Gi"))
+	})
+
+	It("releases the finalizer of a disk whose config is gone", func() {
+		f := newVolumeFixture("stuck-finalizer", "")
+		f.config.Annotations = map[string]string{discoblocksondatiov1.ForceDeleteAnnotation: "true"}
+		f.create()
+
+		pvc := f.waitForPVC()
+		Expect(controllerutil.ContainsFinalizer(pvc, utils.RenderFinalizer(f.config.Name))).To(BeTrue())
+
+		By("deleting the config while its disk exists")
+		Expect(k8sClient.Delete(ctx, f.config)).To(Succeed())
+		Eventually(func() bool {
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(f.config), &discoblocksondatiov1.DiskConfig{})
+			return apierrors.IsNotFound(err)
+		}).Should(BeTrue())
+
+		By("deleting the disk of the deleted config")
+		Expect(k8sClient.Delete(ctx, pvc)).To(Succeed())
+
+		Eventually(func() bool {
+			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}, &corev1.PersistentVolumeClaim{})
+			return apierrors.IsNotFound(err)
+		}, 10*time.Second).Should(BeTrue())
+	})
+
+	It("deletes the rendered config of an unselected namespace while its disk is mounted", func() {
+		f := newVolumeFixture("cluster-unselect", "")
+		clusterConfig := &discoblocksondatiov1.ClusterDiskConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: f.config.Name,
+			},
+			Spec: discoblocksondatiov1.ClusterDiskConfigSpec{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"disks": f.namespace}},
+				DiskConfigSpec:    f.config.Spec,
+			},
+		}
+
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: f.namespace, Labels: map[string]string{"disks": f.namespace}}})).To(Succeed())
+		Expect(k8sClient.Create(ctx, f.sc)).To(Succeed())
+		Expect(k8sClient.Create(ctx, clusterConfig)).To(Succeed())
+
+		By("rendering the config into the selected namespace")
+		Eventually(func() error {
+			return k8sClient.Get(ctx, client.ObjectKeyFromObject(f.config), &discoblocksondatiov1.DiskConfig{})
+		}).Should(Succeed())
+
+		By("mounting the disk of the rendered config")
+		Expect(k8sClient.Create(ctx, f.pod)).To(Succeed())
+		pvc := f.waitForPVC()
+
+		pv := &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: f.namespace,
+			},
+			Spec: corev1.PersistentVolumeSpec{
+				Capacity:                      corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+				AccessModes:                   []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimDelete,
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					CSI: &corev1.CSIPersistentVolumeSource{Driver: testProvisioner, VolumeHandle: f.namespace},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, pv)).To(Succeed())
+
+		pvc.Spec.VolumeName = pv.Name
+		Expect(k8sClient.Update(ctx, pvc)).To(Succeed())
+		bindPVC(pvc)
+		runPod(f.pod)
+
+		By("deleting the rendered config once the namespace is unselected")
+		namespace := corev1.Namespace{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: f.namespace}, &namespace)).To(Succeed())
+		namespace.Labels = nil
+		Expect(k8sClient.Update(ctx, &namespace)).To(Succeed())
+
+		Eventually(func() bool {
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(f.config), &discoblocksondatiov1.DiskConfig{})
+			return apierrors.IsNotFound(err)
+		}, 10*time.Second).Should(BeTrue())
+	})
+})
@@ -25,6 +25,8 @@ import (
 // JobReconciler reconciles a Job object
 type JobReconciler struct {
 	EventService utils.EventService
+	// HostJobQueue is notified about finished Jobs to create queued ones
+	HostJobQueue *utils.HostJobQueue
 	client.Client
 	Scheme *runtime.Scheme
 }
@@ -56,9 +58,15 @@ func (r *JobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 	}
 
 	if job.UID != "" {
-		succeeded := job.Status.Succeeded == 1
+		r.HostJobQueue.Notify()
 
-		operation, podName, pvcName := job.Annotations["discoblocks/operation"], job.Annotations["discoblocks/pod"], job.Annotations["discoblocks/pvc"]
+		completions := int32(1)
+		if job.Spec.Completions != nil {
+			completions = *job.Spec.Completions
+		}
+		succeeded := job.Status.Succeeded >= completions
+
+		operation, podName, pvcName := job.Annotations[utils.JobOperationAnnotation()], job.Annotations[utils.JobPodAnnotation()], job.Annotations[utils.JobPVCAnnotation()]
 		if operation != "" && podName != "" && pvcName != "" {
 			pod := corev1.Pod{}
 			if err := r.Client.Get(ctx, types.NamespacedName{Name: podName, Namespace: req.Namespace}, &pod); err != nil {
@@ -131,6 +139,8 @@ func (r *JobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		return ctrl.Result{}, fmt.Errorf("failed to list Jobs: %w", err)
 	}
 
+	// Only finished Pods of host Jobs are deleted here, workload Pods are never evicted by Discoblocks,
+	// so PodDisruptionBudgets can't be violated. Any future eviction must use the Eviction API.
 	for i := range podList.Items {
 		logger.Info("Delete Pod...", "pod_name", podList.Items[i].Name)
 
@@ -18,12 +18,13 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"sort"
 	"strconv"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -42,6 +43,8 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -52,6 +55,35 @@ import (
 
 const monitoringPeriod = time.Minute / 2
 
+// statusSyncPeriod defines how often DiskConfig statuses are rebuilt from actual PVCs
+const statusSyncPeriod = 5 * time.Minute
+
+// sampleHistorySavePeriod is the minimum period between saves of sample history
+const sampleHistorySavePeriod = 5 * time.Minute
+
+// pvcConditionReason is the reason of PVC conditions in DiskConfig status
+const pvcConditionReason = "PvcPhaseHasChanged"
+
+// maxResizeBackoffExponent limits the exponential backoff of failed resizes
+const maxResizeBackoffExponent = 6
+
+// maxConfigFetchBackoffExponent limits the exponential backoff of failed DiskConfig fetches
+const maxConfigFetchBackoffExponent = 6
+
+// hostJobQueueTimeout is the maximum time a host Job waits for a free slot of HostJobQueue
+const hostJobQueueTimeout = 30 * time.Minute
+
+// steadyStateLogRate logs recurring conditions only once per this many passes
+const steadyStateLogRate = 10
+
+var steadyStateSampler = utils.CreateStateSampler(steadyStateLogRate)
+
+// maxReachedAuditSampler passes only changes of maximum number of disks per PVC
+var maxReachedAuditSampler = utils.CreateStateSampler(0)
+
+// planOnlySampler passes only changes of plan only decisions per PVC, usage changes every cycle but decisions rarely
+var planOnlySampler = utils.CreateStateSampler(0)
+
 type nodeCache interface {
 	GetNodesByIP() map[string]string
 }
@@ -59,8 +91,46 @@ type nodeCache interface {
 // PVCReconciler reconciles a PVC object
 type PVCReconciler struct {
 	EventService utils.EventService
+	AuditService utils.AuditService
 	NodeCache    nodeCache
 	InProgress   sync.Map
+	PlanOnly     bool
+	// ResizeRequests creates a VolumeResizeRequest per resize instead of updating the PVC directly
+	ResizeRequests bool
+	// FSSizeMismatchPercentage enables growing only the file-system if it is smaller than the volume by more than this percentage
+	FSSizeMismatchPercentage float64
+	// MetricsStalenessTolerance skips decisions on metrics older than this, zero disables the check
+	MetricsStalenessTolerance time.Duration
+	// MetricsStalenessJitter is added to MetricsStalenessTolerance to absorb scrape intervals and clock skew
+	MetricsStalenessJitter time.Duration
+	// CapacityRecommender decides target capacity instead of the built-in logic if it is set
+	CapacityRecommender utils.CapacityRecommender
+	// CapacityRecommenderTimeout bounds a call of CapacityRecommender, built-in decision is kept on timeout
+	CapacityRecommenderTimeout time.Duration
+	// FillRatePredictor tracks availability of disks to resize fast filling ones early, nil disables prediction
+	FillRatePredictor *utils.FillRatePredictor
+	// PredictionHorizon triggers resize if FillRatePredictor predicts the disk to fill within this duration,
+	// predictionHorizon of DiskConfig policy overrides it
+	PredictionHorizon time.Duration
+	// SampleHistoryStore persists samples of FillRatePredictor across restarts, nil disables persistence
+	SampleHistoryStore    utils.SampleHistoryStore
+	sampleHistoryRestored bool
+	sampleHistorySaved    time.Time
+	// HostJobQueue paces creation of mount and resize Jobs and serializes them per node, nil disables the limit
+	HostJobQueue *utils.HostJobQueue
+	// MetricsImageTracker records pull failures of metrics sidecar images, nil disables tracking
+	MetricsImageTracker *utils.MetricsImageTracker
+	// ScrapeRetryPeriod schedules a pass re-scraping only the Pods failed in the previous pass after this duration,
+	// zero disables it, failed Pods wait for the next full pass
+	ScrapeRetryPeriod time.Duration
+	failedScrapes     map[string]map[string]bool
+	failedScrapesLock sync.Mutex
+	// ConfigFetchBackoff requeues the PVC after this duration, doubled by consecutive failures, if fetch of its DiskConfig fails,
+	// zero leaves backoff to the rate limiter of the controller
+	ConfigFetchBackoff  time.Duration
+	configFetchFailures sync.Map
+	// KubeletClient fetches volume stats of kubelet via API server proxy
+	KubeletClient rest.Interface
 	client.Client
 	Scheme *runtime.Scheme
 }
@@ -84,6 +154,8 @@ func (r *PVCReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 	}
 	defer unlock()
 
+	defer pvcLock.Lock(req.NamespacedName.String())()
+
 	logger.Info("Reconciling...")
 	defer logger.Info("Reconciled")
 
@@ -103,24 +175,42 @@ func (r *PVCReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		return ctrl.Result{}, nil
 	}
 
+	// PVCs labeled by hand or before label prefix change have no config, fetch of an empty name would fail
+	if pvc.Labels[utils.ConfigLabel()] == "" {
+		logger.V(1).Info("Config label not found, skipping", "label", utils.ConfigLabel())
+
+		return ctrl.Result{}, nil
+	}
+
+	// Series of removed PVCs would be exported forever
+	if pvc.DeletionTimestamp != nil {
+		metrics.DeleteReadOnlyFileSystem(pvc.Name, pvc.Namespace)
+	}
+
 	logger.Info("Fetch DiskConfig...")
 
 	config := discoblocksondatiov1.DiskConfig{}
-	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Labels["discoblocks"]}, &config); err != nil {
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Labels[utils.ConfigLabel()]}, &config); err != nil {
 		if apierrors.IsNotFound(err) {
+			r.configFetchFailures.Delete(req.NamespacedName.String())
+
 			logger.Info("DiskConfig not found")
 
+			if pvc.DeletionTimestamp != nil {
+				return r.releasePVC(ctx, &pvc, logger)
+			}
+
 			return ctrl.Result{}, nil
 		}
 
-		metrics.NewError("DiskConfig", pvc.Labels["discoblocks"], pvc.Namespace, "Kube API", "get")
+		metrics.NewError("DiskConfig", pvc.Labels[utils.ConfigLabel()], pvc.Namespace, "Kube API", "get")
 
-		logger.Info("Unable to fetch PVC", "error", err.Error())
-		return ctrl.Result{}, errors.New("unable to fetch PVC")
+		return r.backoffConfigFetch(req.NamespacedName.String(), err, logger)
 	}
+	r.configFetchFailures.Delete(req.NamespacedName.String())
 	logger = logger.WithValues("dc_name", config.Name)
 
-	reason := "PvcPhaseHasChanged"
+	reason := pvcConditionReason
 
 	if pvc.DeletionTimestamp != nil {
 		toDelete := []int{}
@@ -159,19 +249,7 @@ func (r *PVCReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 
 		logger.Info("Add status", "phase", pvc.Status.Phase)
 
-		status := metav1.ConditionFalse
-		if pvc.Status.Phase == corev1.ClaimBound {
-			status = metav1.ConditionTrue
-		}
-
-		condition := metav1.Condition{
-			Status:             status,
-			Type:               string(pvc.Status.Phase),
-			ObservedGeneration: pvc.Generation,
-			LastTransitionTime: metav1.NewTime(time.Now()),
-			Reason:             reason,
-			Message:            pvc.Name,
-		}
+		condition := renderPVCCondition(&pvc)
 
 		if toUpdate == -1 {
 			config.Status.Conditions = append(config.Status.Conditions, condition)
@@ -180,6 +258,12 @@ func (r *PVCReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		}
 	}
 
+	if pvc.DeletionTimestamp == nil && config.Spec.VolumeAttributesClassName != "" {
+		if err := applyVolumeAttributesClass(ctx, r.Client, &pvc, config.Spec.VolumeAttributesClassName, logger); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	logger.Info("Update DiskConfig status...")
 
 	if err := r.Client.Status().Update(ctx, &config); err != nil {
@@ -194,51 +278,368 @@ func (r *PVCReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 	return ctrl.Result{}, nil
 }
 
+// backoffConfigFetch requeues the PVC by exponential backoff of consecutive DiskConfig fetch failures,
+// failures are logged once per steadyStateLogRate to keep logs readable while API server struggles
+func (r *PVCReconciler) backoffConfigFetch(key string, err error, logger logr.Logger) (ctrl.Result, error) {
+	failures := 1
+	if last, ok := r.configFetchFailures.Load(key); ok {
+		failures = last.(int) + 1
+	}
+	r.configFetchFailures.Store(key, failures)
+
+	if (failures-1)%steadyStateLogRate == 0 {
+		logger.Info("Unable to fetch DiskConfig", "error", err.Error(), "failures", failures)
+	}
+
+	if r.ConfigFetchBackoff <= 0 {
+		return ctrl.Result{}, fmt.Errorf("unable to fetch DiskConfig: %w", err)
+	}
+
+	exponent := failures - 1
+	if exponent > maxConfigFetchBackoffExponent {
+		exponent = maxConfigFetchBackoffExponent
+	}
+
+	return ctrl.Result{RequeueAfter: r.ConfigFetchBackoff << exponent}, nil
+}
+
+// releasePVC removes finalizer of terminating PVC, which stucks if DiskConfig was deleted while operator was down.
+// Event filter lets in every managed PVC on start, so stuck PVCs are released on startup too.
+func (r *PVCReconciler) releasePVC(ctx context.Context, pvc *corev1.PersistentVolumeClaim, logger logr.Logger) (ctrl.Result, error) {
+	finalizer := utils.RenderFinalizer(pvc.Labels[utils.ConfigLabel()])
+	if !controllerutil.ContainsFinalizer(pvc, finalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	controllerutil.RemoveFinalizer(pvc, finalizer)
+
+	logger.Info("Remove finalizer of orphan PVC...", "finalizer", finalizer)
+
+	if err := r.Client.Update(ctx, pvc); err != nil {
+		metrics.NewError("PersistentVolumeClaim", pvc.Name, pvc.Namespace, "Kube API", "update")
+
+		return ctrl.Result{}, fmt.Errorf("unable to remove finalizer of PVC: %w", err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SyncStatuses rebuilds PVC conditions of DiskConfigs from actual PVCs, to fix drift caused by missed events
+func (r *PVCReconciler) SyncStatuses() {
+	logger := logf.Log.WithName("StatusSync")
+
+	lock, unlock := controllerSemaphore()
+	if !lock {
+		logger.Info("Another operation is on going, sync needs to be resceduled")
+		return
+	}
+	defer unlock()
+
+	logger.Info("Sync statuses...")
+	defer logger.Info("Sync done")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	diskConfigs := discoblocksondatiov1.DiskConfigList{}
+	if err := r.Client.List(ctx, &diskConfigs); err != nil {
+		metrics.NewError("DiskConfig", "", "", "Kube API", "list")
+
+		logger.Error(err, "Unable to fetch DiskConfigs")
+		return
+	}
+
+	for d := range diskConfigs.Items {
+		config := diskConfigs.Items[d]
+
+		logger := logger.WithValues("dc_name", config.Name, "dc_namespace", config.Namespace)
+
+		pvcs := corev1.PersistentVolumeClaimList{}
+		if err := r.Client.List(ctx, &pvcs, client.InNamespace(config.Namespace), client.MatchingLabels{utils.ConfigLabel(): config.Name}); err != nil {
+			metrics.NewError("PersistentVolumeClaim", "", config.Namespace, "Kube API", "list")
+
+			logger.Error(err, "Unable to fetch PVCs")
+			continue
+		}
+
+		conditions, changed := syncPVCConditions(config.Status.Conditions, pvcs.Items)
+		if pruneResizeStatuses(config.Status.Resizes, pvcs.Items) {
+			changed = true
+		}
+		if syncRollout(&config, time.Now()) {
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+
+		logger.Info("Status has drifted, update DiskConfig status...")
+
+		config.Status.Conditions = conditions
+
+		if err := r.Client.Status().Update(ctx, &config); err != nil {
+			metrics.NewError("DiskConfig", config.Name, config.Namespace, "Kube API", "update")
+
+			logger.Error(err, "Unable to update DiskConfig status")
+		}
+	}
+}
+
+// syncRollout starts a new resize rollout on every change of the config, configs without rollout steps have no rollout
+func syncRollout(config *discoblocksondatiov1.DiskConfig, now time.Time) bool {
+	if config.Spec.Policy.ResizeRolloutStepPercentage == 0 {
+		if config.Status.Rollout == nil {
+			return false
+		}
+
+		config.Status.Rollout = nil
+
+		return true
+	}
+
+	if config.Status.Rollout != nil && config.Status.Rollout.Generation == config.Generation {
+		return false
+	}
+
+	config.Status.Rollout = &discoblocksondatiov1.RolloutStatus{
+		Generation: config.Generation,
+		StartTime:  metav1.NewTime(now),
+	}
+
+	return true
+}
+
+// rolloutPercentage returns the actual resize rollout percentage of the config,
+// steps are counted only if the rollout has started with the current generation of the config
+func rolloutPercentage(config *discoblocksondatiov1.DiskConfig, now time.Time) uint8 {
+	policy := &config.Spec.Policy
+
+	rollout := config.Status.Rollout
+	if rollout == nil || rollout.Generation != config.Generation {
+		return policy.ResizeRolloutPercentage
+	}
+
+	return utils.RolloutPercentage(policy.ResizeRolloutPercentage, policy.ResizeRolloutStepPercentage, policy.ResizeRolloutWindow.Duration, rollout.StartTime.Time, now)
+}
+
+// syncPVCConditions drops conditions of missing or terminating PVCs, updates the phase of existing ones and adds the missing ones
+func syncPVCConditions(conditions []metav1.Condition, pvcs []corev1.PersistentVolumeClaim) ([]metav1.Condition, bool) {
+	activePVCs := map[string]*corev1.PersistentVolumeClaim{}
+	for i := range pvcs {
+		if pvcs[i].DeletionTimestamp == nil {
+			activePVCs[pvcs[i].Name] = &pvcs[i]
+		}
+	}
+
+	changed := false
+	seen := map[string]bool{}
+	synced := []metav1.Condition{}
+
+	for i := range conditions {
+		if conditions[i].Reason != pvcConditionReason {
+			synced = append(synced, conditions[i])
+			continue
+		}
+
+		pvc, ok := activePVCs[conditions[i].Message]
+		if !ok || seen[pvc.Name] {
+			changed = true
+			continue
+		}
+		seen[pvc.Name] = true
+
+		if conditions[i].Type != string(pvc.Status.Phase) {
+			changed = true
+			synced = append(synced, renderPVCCondition(pvc))
+			continue
+		}
+
+		synced = append(synced, conditions[i])
+	}
+
+	missing := []string{}
+	for name := range activePVCs {
+		if !seen[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+
+	for _, name := range missing {
+		changed = true
+		synced = append(synced, renderPVCCondition(activePVCs[name]))
+	}
+
+	return synced, changed
+}
+
+// pruneResizeStatuses drops resize statuses of missing or terminating PVCs
+func pruneResizeStatuses(resizes map[string]discoblocksondatiov1.ResizeStatus, pvcs []corev1.PersistentVolumeClaim) bool {
+	activePVCs := map[string]bool{}
+	for i := range pvcs {
+		if pvcs[i].DeletionTimestamp == nil {
+			activePVCs[pvcs[i].Name] = true
+		}
+	}
+
+	changed := false
+	for name := range resizes {
+		if !activePVCs[name] {
+			delete(resizes, name)
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// renderPVCCondition renders DiskConfig status condition of PVC
+func renderPVCCondition(pvc *corev1.PersistentVolumeClaim) metav1.Condition {
+	status := metav1.ConditionFalse
+	if pvc.Status.Phase == corev1.ClaimBound {
+		status = metav1.ConditionTrue
+	}
+
+	return metav1.Condition{
+		Status:             status,
+		Type:               string(pvc.Status.Phase),
+		ObservedGeneration: pvc.Generation,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Reason:             pvcConditionReason,
+		Message:            pvc.Name,
+	}
+}
+
 // MonitorVolumes monitors volumes periodycally
-//nolint:gocyclo // It is complex we know
 func (r *PVCReconciler) MonitorVolumes() {
-	logger := logf.Log.WithName("VolumeMonitor")
+	r.monitorVolumes(logf.Log.WithName("VolumeMonitor"))
+}
+
+// RetryFailedScrapes monitors volumes of Pods whose scrape failed in the previous pass
+func (r *PVCReconciler) RetryFailedScrapes() {
+	r.retryFailedScrapes(logf.Log.WithName("VolumeMonitor"))
+}
+
+// monitorVolumes runs a full cycle of volume monitor
+func (r *PVCReconciler) monitorVolumes(logger logr.Logger) {
+	r.monitorPods(logger, nil)
+}
 
-	logger.Info("Monitor Volumes...")
-	defer logger.Info("Monitor done")
+// retryFailedScrapes runs a cycle of volume monitor on Pods failed to scrape, it does nothing without failures
+func (r *PVCReconciler) retryFailedScrapes(logger logr.Logger) {
+	r.failedScrapesLock.Lock()
+	retry := r.failedScrapes
+	r.failedScrapesLock.Unlock()
+
+	if len(retry) == 0 {
+		return
+	}
+
+	r.monitorPods(logger.WithValues("retry", true), retry)
+}
+
+// scheduleScrapeRetry returns a channel firing when failed scrapes should be retried,
+// nil channel blocks forever if retry is disabled, nothing failed or the next full pass comes first
+func (r *PVCReconciler) scheduleScrapeRetry() <-chan time.Time {
+	if r.ScrapeRetryPeriod <= 0 || r.ScrapeRetryPeriod >= monitoringPeriod {
+		return nil
+	}
+
+	r.failedScrapesLock.Lock()
+	defer r.failedScrapesLock.Unlock()
+
+	if len(r.failedScrapes) == 0 {
+		return nil
+	}
+
+	return time.After(r.ScrapeRetryPeriod)
+}
+
+// monitorPods runs a cycle of volume monitor, retry limits the cycle to the given Pod names by DiskConfig namespace/name.
+// Actions and failures are logged at Info level, routine decisions of the cycle at V(1), fetches per Pod at V(2).
+//nolint:gocyclo // It is complex we know
+func (r *PVCReconciler) monitorPods(logger logr.Logger, retry map[string]map[string]bool) {
+	logger.V(1).Info("Monitor Volumes...")
+
+	summary := monitorSummary{start: time.Now()}
+	defer summary.log(logger)
 
 	ctx, cancel := context.WithTimeout(context.Background(), monitoringPeriod)
 	defer cancel()
 
-	logger.Info("Fetch DiskConfigs...")
+	if r.SampleHistoryStore != nil && r.FillRatePredictor != nil {
+		if !r.sampleHistoryRestored {
+			r.restoreSampleHistory(ctx, logger)
+		}
+
+		defer r.saveSampleHistory(time.Now(), logger)
+	}
+
+	logger.V(1).Info("Fetch DiskConfigs...")
 
 	diskConfigs := discoblocksondatiov1.DiskConfigList{}
 	if err := r.Client.List(ctx, &diskConfigs); err != nil {
 		metrics.NewError("DiskConfig", "", "", "Kube API", "list")
 
 		logger.Error(err, "Unable to fetch DiskConfigs")
+		atomic.AddInt32(&summary.errors, 1)
 		return
 	}
 
+	// Kubelet metrics contain all volumes of the node, each node is scraped once per cycle
+	kubeletScraper := diskinfo.NewKubeletScraper(r.KubeletClient)
+
+	// Failures of this cycle replace the previous ones, retried Pods are either scraped or failed again
+	failedScrapes := map[string]map[string]bool{}
+	defer func() {
+		r.failedScrapesLock.Lock()
+		r.failedScrapes = failedScrapes
+		r.failedScrapesLock.Unlock()
+	}()
+
 	for d := range diskConfigs.Items {
 		config := diskConfigs.Items[d]
 
+		configKey := config.Namespace + "/" + config.Name
+		if retry != nil && len(retry[configKey]) == 0 {
+			continue
+		}
+
 		if config.Spec.Policy.Pause {
-			logger.Info("Autoscaling paused")
+			if steadyStateSampler(config.Namespace+"/"+config.Name, "paused") {
+				logger.Info("Autoscaling paused", "dc_name", config.Name, "dc_namespace", config.Namespace)
+			}
 			continue
 		}
+		steadyStateSampler(config.Namespace+"/"+config.Name, "active")
 
 		last, loaded := r.InProgress.Load(config.Name)
 		if loaded && last.(time.Time).Add(config.Spec.Policy.CoolDown.Duration).After(time.Now()) {
-			logger.Info("Autoscaling cooldown")
+			logger.V(1).Info("Autoscaling cooldown", "dc_name", config.Name, "dc_namespace", config.Namespace)
 			continue
 		}
 
 		logger := logger.WithValues("dc_name", config.Name, "dc_namespace", config.Namespace)
 
-		configLabel, err := labels.NewRequirement("discoblocks", selection.Equals, []string{config.Name})
+		if _, err := config.Spec.Policy.GetUpscaleTriggerPercentage(); err != nil {
+			logger.Error(err, "Unable to parse upscale trigger")
+			atomic.AddInt32(&summary.errors, 1)
+			continue
+		}
+
+		if !r.isMountPatternValid(&config, logger) {
+			continue
+		}
+
+		configLabel, err := labels.NewRequirement(utils.ConfigLabel(), selection.Equals, []string{config.Name})
 		if err != nil {
 			logger.Error(err, "Unable to parse PVC label selector")
+			atomic.AddInt32(&summary.errors, 1)
 			continue
 		}
 		pvcSelector := labels.NewSelector().Add(*configLabel)
 
-		logger.Info("Fetch PVCs...")
+		logger.V(1).Info("Fetch PVCs...")
 
 		pvcs := corev1.PersistentVolumeClaimList{}
 		if err = r.Client.List(ctx, &pvcs, &client.ListOptions{
@@ -248,6 +649,7 @@ func (r *PVCReconciler) MonitorVolumes() {
 			metrics.NewError("PersistentVolumeClaim", "", config.Namespace, "Kube API", "list")
 
 			logger.Error(err, "Unable to fetch PVCs")
+			atomic.AddInt32(&summary.errors, 1)
 			continue
 		}
 
@@ -261,22 +663,46 @@ func (r *PVCReconciler) MonitorVolumes() {
 
 			activePVCs = append(activePVCs, &pvcs.Items[i])
 
-			logger.Info("Volume found", "pvc_name", pvcs.Items[i].Name)
+			logger.V(2).Info("Volume found", "pvc_name", pvcs.Items[i].Name)
 		}
 
 		if len(activePVCs) == 0 {
-			logger.Info("Unable to find any PVC")
+			logger.V(1).Info("Unable to find any PVC")
+			continue
+		}
+
+		fs := ""
+		sc := storagev1.StorageClass{}
+		if err = r.Client.Get(ctx, types.NamespacedName{Name: config.Spec.StorageClassName}, &sc); err != nil {
+			metrics.NewError("StorageClass", config.Spec.StorageClassName, "", "Kube API", "get")
+
+			logger.Error(err, "Unable to fetch StorageClass, file-system specific usage is disabled", "sc_name", config.Spec.StorageClassName)
+			atomic.AddInt32(&summary.errors, 1)
+		} else if !utils.IsProvisionerManaged(sc.Provisioner) {
+			logger.V(1).Info("Provisioner is not managed", "provisioner", sc.Provisioner)
 			continue
+		} else {
+			fs = utils.GetFileSystem(&sc)
 		}
 
-		podLabel, err := labels.NewRequirement(utils.RenderUniqueLabel(string(config.UID)), selection.Equals, []string{config.Name})
+		// Host perspective reports contain the staging path of the driver
+		stagingPath := ""
+		if driver := drivers.GetDriver(sc.Provisioner); driver != nil && config.Spec.MetricsSource != discoblocksondatiov1.MetricsSourceKubelet {
+			if stagingPath, err = driver.GetStagingPath(); err != nil {
+				metrics.NewError("CSI", "", "", sc.Provisioner, "GetStagingPath")
+
+				logger.Error(err, "Failed to call driver, default staging path is used", "method", "GetStagingPath")
+			}
+		}
+
+		podSelector, err := utils.RenderPodSelector(&config)
 		if err != nil {
 			logger.Error(err, "Unable to parse Pod label selector")
+			atomic.AddInt32(&summary.errors, 1)
 			continue
 		}
-		podSelector := labels.NewSelector().Add(*podLabel)
 
-		logger.Info("Fetch Pods...")
+		logger.V(1).Info("Fetch Pods...")
 
 		pods := corev1.PodList{}
 		if err = r.Client.List(ctx, &pods, &client.ListOptions{
@@ -286,20 +712,47 @@ func (r *PVCReconciler) MonitorVolumes() {
 			metrics.NewError("Pod", "", config.Namespace, "Kube API", "list")
 
 			logger.Error(err, "Unable to fetch Pods")
+			atomic.AddInt32(&summary.errors, 1)
 			continue
 		}
 
 		sem := utils.CreateSemaphore(concurrency, config.Spec.Policy.CoolDown.Duration)
 		wg := sync.WaitGroup{}
+		fetched := sync.Map{}
+		failed := sync.Map{}
 
 		for p := range pods.Items {
 			pod := pods.Items[p]
 
+			if retry != nil && !retry[configKey][pod.Name] {
+				continue
+			}
+
 			// Skip monitoring of new Pods
 			if pod.DeletionTimestamp != nil || pod.CreationTimestamp.Add(config.Spec.Policy.CoolDown.Duration).After(time.Now()) {
 				continue
 			}
 
+			if config.Spec.MetricsSource == discoblocksondatiov1.MetricsSourceKubelet {
+				if pod.Status.Phase != corev1.PodRunning || pod.Spec.NodeName == "" {
+					logger.V(1).Info("Pod is not running", "pod_name", pod.Name)
+					continue
+				}
+			} else if !utils.IsMetricsReady(&pod) {
+				if image := r.MetricsImageTracker.ReportPod(&pod, time.Now()); image != "" {
+					logger.Info("Unable to pull metrics sidecar image, injection is skipped in namespace", "pod_name", pod.Name, "image", image)
+
+					if err := r.EventService.SendWarning(pod.Namespace, "Discoblocks", "PVC Monitor", "Failed to pull metrics sidecar image "+image, "Recreate the Pod to run it without autoscaling", &pod, nil); err != nil {
+						metrics.NewError("Event", "", "", "Kube API", "create")
+
+						logger.Error(err, "Failed to create event")
+					}
+				}
+
+				logger.V(1).Info("Metrics sidecars are not ready", "pod_name", pod.Name)
+				continue
+			}
+
 			wg.Add(1)
 
 			go func() {
@@ -310,19 +763,34 @@ func (r *PVCReconciler) MonitorVolumes() {
 					metrics.NewError("VolumeMonitor", "", "", "DiscoBlocks", "semaphore")
 
 					logger.Info("Context deadline")
+					failed.Store(pod.Name, true)
 					return
 				}
 				defer unlock()
 
 				logger := logger.WithValues("pod_name", pod.Name)
 
-				logger.Info("Fetch DiskInfo...")
+				var diskInfo map[string]diskinfo.DiskUsage
+				if config.Spec.MetricsSource == discoblocksondatiov1.MetricsSourceKubelet {
+					logger.V(2).Info("Fetch kubelet volume stats...", "node_name", pod.Spec.NodeName)
 
-				diskInfo, err := diskinfo.Fetch(pod.Name, pod.Namespace)
-				if err != nil {
-					metrics.NewError("Pod", pod.Name, pod.Namespace, "DiscoBlocks", "metrics")
+					diskInfo, err = kubeletScraper.Fetch(ctx, pod.Spec.NodeName, pod.Namespace)
+				} else {
+					logger.V(2).Info("Fetch DiskInfo...")
 
+					diskInfo, err = diskinfo.Fetch(pod.Name, pod.Namespace)
+				}
+				if err != nil {
 					logger.Error(err, "Unable to fetch disk info")
+					atomic.AddInt32(&summary.errors, 1)
+					failed.Store(pod.Name, true)
+
+					// Failure has been reported by the full pass, retries only try to recover from it
+					if retry != nil {
+						return
+					}
+
+					metrics.NewError("Pod", pod.Name, pod.Namespace, "DiscoBlocks", "metrics")
 
 					if err := r.EventService.SendWarning(pod.Namespace, "Discoblocks", "PVC Monitor", "Failed to fetch disk info", err.Error(), &pod, nil); err != nil {
 						metrics.NewError("Event", "", "", "Kube API", "create")
@@ -333,48 +801,87 @@ func (r *PVCReconciler) MonitorVolumes() {
 					return
 				}
 
-				podPVCsByParent := map[string][]*corev1.PersistentVolumeClaim{}
-				for i := range pod.Spec.Volumes {
-					if pod.Spec.Volumes[i].PersistentVolumeClaim == nil {
-						continue
-					}
+				fetched.Store(pod.Name, diskInfo)
+				atomic.AddInt32(&summary.podsScraped, 1)
+			}()
+		}
 
-					for cp := range activePVCs {
-						if _, ok := activePVCs[cp].Labels["discoblocks-parent"]; !ok &&
-							pod.Spec.Volumes[i].PersistentVolumeClaim.ClaimName == activePVCs[cp].Name {
-							if _, ok := podPVCsByParent[activePVCs[cp].Name]; !ok {
-								podPVCsByParent[activePVCs[cp].Name] = []*corev1.PersistentVolumeClaim{}
-							}
-							podPVCsByParent[activePVCs[cp].Name] = append(podPVCsByParent[activePVCs[cp].Name], activePVCs[cp])
+		wg.Wait()
 
-							for cc := range activePVCs {
-								if parent, ok := activePVCs[cc].Labels["discoblocks-parent"]; ok && parent == activePVCs[cp].Name {
-									podPVCsByParent[activePVCs[cp].Name] = append(podPVCsByParent[activePVCs[cp].Name], activePVCs[cc])
-								}
-							}
-						}
+		failed.Range(func(key, _ interface{}) bool {
+			if failedScrapes[configKey] == nil {
+				failedScrapes[configKey] = map[string]bool{}
+			}
+			failedScrapes[configKey][key.(string)] = true
+			return true
+		})
+
+		podDiskInfos := map[string]map[string]diskinfo.DiskUsage{}
+		fetched.Range(func(key, value interface{}) bool {
+			podDiskInfos[key.(string)] = value.(map[string]diskinfo.DiskUsage)
+			return true
+		})
+
+		podPVCFamilies := map[string]map[string][]*corev1.PersistentVolumeClaim{}
+		for p := range pods.Items {
+			if _, ok := podDiskInfos[pods.Items[p].Name]; ok {
+				podPVCFamilies[pods.Items[p].Name] = renderPodPVCFamilies(&pods.Items[p], activePVCs)
+			}
+		}
+
+		// Pods sharing a PVC report the same file-system, decision is made once per PVC
+		pvcUsages := mergeDiskUsages(&config, stagingPath, podPVCFamilies, podDiskInfos)
+		atomic.AddInt32(&summary.metricsFound, int32(len(pvcUsages)))
+		decided := map[string]bool{}
+
+		for p := range pods.Items {
+			pod := pods.Items[p]
+
+			podPVCsByParent, ok := podPVCFamilies[pod.Name]
+			if !ok {
+				continue
+			}
+
+			logger := logger.WithValues("pod_name", pod.Name)
+
+			if len(podPVCsByParent) == 0 {
+				logger.V(1).Info("Unable to find any PVC for Pod")
+				continue
+			}
+
+			for _, pvcFamily := range podPVCsByParent {
+				// Initial disks are created in the same second, index decides between them
+				sort.Slice(pvcFamily, func(i, j int) bool {
+					if !pvcFamily[i].CreationTimestamp.Equal(&pvcFamily[j].CreationTimestamp) {
+						return pvcFamily[i].CreationTimestamp.UnixNano() < pvcFamily[j].CreationTimestamp.UnixNano()
 					}
-				}
 
-				if len(podPVCsByParent) == 0 {
-					logger.Error(err, "Unable to find any PVC for Pod")
-					return
+					return utils.GetPVCIndex(pvcFamily[i]) < utils.GetPVCIndex(pvcFamily[j])
+				})
+
+				// Disks of the config have own policy, each of them is scaled independently
+				scaledPVCs := pvcFamily[len(pvcFamily)-1:]
+				if len(config.Spec.Disks) != 0 {
+					scaledPVCs = pvcFamily
 				}
 
-				for _, pvcFamily := range podPVCsByParent {
-					sort.Slice(pvcFamily, func(i, j int) bool {
-						return pvcFamily[i].CreationTimestamp.UnixNano() < pvcFamily[j].CreationTimestamp.UnixNano()
-					})
+				for i, lastPVC := range scaledPVCs {
+					logger := logger
 
-					lastPVC := pvcFamily[len(pvcFamily)-1]
+					if decided[lastPVC.Name] {
+						logger.V(1).Info("Shared PVC is already monitored", "pvc_name", lastPVC.Name)
+						continue
+					}
+					decided[lastPVC.Name] = true
 
 					actIndex := 0
-					if lastIndex, ok := lastPVC.Labels["discoblocks-index"]; ok {
+					if lastIndex, ok := lastPVC.Labels[utils.IndexLabel()]; ok {
 						actIndex, err = strconv.Atoi(lastIndex)
 						if err != nil {
 							metrics.NewError("Pod", pod.Name, pod.Namespace, "DiscoBlocks", "lastindex")
 
 							logger.Error(err, "Unable to convert index")
+							atomic.AddInt32(&summary.errors, 1)
 
 							if err := r.EventService.SendWarning(pod.Namespace, "Discoblocks", "PVC Monitor", fmt.Sprintf("Failed to convert last index of %s: %s", lastPVC.Name, lastIndex), err.Error(), &pod, nil); err != nil {
 								metrics.NewError("Event", "", "", "Kube API", "create")
@@ -386,15 +893,27 @@ func (r *PVCReconciler) MonitorVolumes() {
 						}
 					}
 
-					lastMountPoint := utils.RenderMountPoint(config.Spec.MountPointPattern, config.Name, actIndex)
+					policy := config.Spec.GetDiskPolicy(actIndex)
+
+					upscaleTrigger, err := policy.GetUpscaleTriggerPercentage()
+					if err != nil {
+						logger.Error(err, "Unable to parse upscale trigger of disk", "index", actIndex)
+						atomic.AddInt32(&summary.errors, 1)
+						continue
+					}
+
+					diskFS := utils.GetDiskFileSystem(&config, actIndex, fs)
+
+					lastMountPoint := utils.RenderDiskMountPoint(&config, config.Name, actIndex)
 
 					logger = logger.WithValues("last_pvc", lastPVC.Name, "last_pv", lastPVC.Spec.VolumeName, "last_mp", lastMountPoint)
 
-					lastUsed, ok := diskInfo[lastMountPoint]
+					lastUsage, ok := pvcUsages[lastPVC.Name]
 					if !ok {
 						metrics.NewError("Pod", pod.Name, pod.Namespace, "DiscoBlocks", "last_mount_point")
 
-						logger.Error(err, "Unable to find metrics", "disk_info", diskInfo)
+						logger.Error(err, "Unable to find metrics", "disk_info", podDiskInfos[pod.Name])
+						atomic.AddInt32(&summary.errors, 1)
 
 						if err := r.EventService.SendWarning(pod.Namespace, "Discoblocks", "PVC Monitor", fmt.Sprintf("Failed to find metrics of %s: %s", lastPVC.Name, lastMountPoint), "Unable to find metrics", &pod, nil); err != nil {
 							metrics.NewError("Event", "", "", "Kube API", "create")
@@ -405,25 +924,93 @@ func (r *PVCReconciler) MonitorVolumes() {
 						continue
 					}
 
-					logger = logger.WithValues("last_used_%", lastUsed)
+					if r.isStale(lastPVC, lastUsage, time.Now(), logger) {
+						continue
+					}
+
+					if r.isReadOnly(&pod, pvcFamily, pvcUsages, lastPVC, lastMountPoint, logger) {
+						continue
+					}
+
+					lastUsed := lastUsage.UsedPercentage(diskFS)
+
+					logger = logger.WithValues("last_used_%", lastUsed, "fs", diskFS)
+
+					// Only the last disk of the family requests new disks, listed disks are created one by one
+					newDiskRequested := i == len(scaledPVCs)-1 && utils.IsNewDiskRequested(&pod, config.Name)
+					missingDisk := i == len(scaledPVCs)-1 && len(config.Spec.Disks) > len(pvcFamily)
+
+					if !newDiskRequested && !missingDisk && r.isInGracePeriod(&pod, lastPVC, policy.InitialGracePeriod.Duration, time.Now(), logger) {
+						continue
+					}
+
+					lastCapacity := lastPVC.Spec.Resources.Requests[corev1.ResourceStorage]
+
+					newCapacity, action := utils.DecideResize(lastUsed, upscaleTrigger, lastCapacity, &policy, newDiskRequested || missingDisk)
+
+					// Fast filling disks are resized before they cross the threshold
+					predicted := false
+					horizon := r.getPredictionHorizon(&policy)
+					if r.isExhaustionPredicted(lastPVC, lastUsage, horizon, time.Now(), logger) && action == utils.ResizeActionNone {
+						newCapacity, action = utils.DecideResize(upscaleTrigger, upscaleTrigger, lastCapacity, &policy, false)
+						predicted = true
+					}
+
+					// Recommender is asked only if the PVC could be resized now
+					recommended := false
+					if r.CapacityRecommender != nil && !newDiskRequested && !missingDisk && isResizeAllowed(&config, lastPVC, time.Now()) {
+						request := utils.RecommendationRequest{
+							ConfigName:            config.Name,
+							Namespace:             config.Namespace,
+							PodName:               pod.Name,
+							PVCName:               lastPVC.Name,
+							Index:                 actIndex,
+							NumberOfDisks:         len(pvcFamily),
+							Capacity:              lastCapacity.String(),
+							MaximumCapacityOfDisk: policy.MaximumCapacityOfDisk.String(),
+							SizeBytes:             lastUsage.Size * diskinfo.BlockSize,
+							UsedBytes:             lastUsage.Used * diskinfo.BlockSize,
+							AvailableBytes:        lastUsage.Available * diskinfo.BlockSize,
+							UsedPercentage:        lastUsed,
+						}
+						if resize, ok := config.Status.Resizes[lastPVC.Name]; ok {
+							request.LastResizeTime = resize.LastAttemptTime
+							request.LastResizeSucceeded = resize.Succeeded
+						}
+
+						if target, err := r.recommend(ctx, &request, logger); err == nil {
+							newCapacity, action = utils.DecideRecommendedResize(target, lastCapacity, &policy)
+							recommended = true
+							predicted = false
+
+							logger = logger.WithValues("recommended_capacity", target.String())
+						}
+					}
+
+					if action == utils.ResizeActionNone {
+						if steadyStateSampler(lastPVC.Namespace+"/"+lastPVC.Name, "ok") {
+							logger.V(1).Info("Disk size ok")
+						}
 
-					if lastUsed < float64(config.Spec.Policy.UpscaleTriggerPercentage) {
-						logger.Info("Disk size ok")
 						continue
 					}
 
-					newCapacity := config.Spec.Policy.ExtendCapacity
-					newCapacity.Add(lastPVC.Spec.Resources.Requests[corev1.ResourceStorage])
+					// Pinned disks keep their capacity, new disks of the family are still created
+					if action != utils.ResizeActionNewDisk && r.isPinned(lastPVC, logger) {
+						continue
+					}
+					steadyStateSampler(lastPVC.Namespace+"/"+lastPVC.Name, "full")
 
-					logger = logger.WithValues("new_capacity", newCapacity.String(), "max_capacity", config.Spec.Policy.MaximumCapacityOfDisk.String(), "no_disks", len(pvcFamily), "max_disks", config.Spec.Policy.MaximumNumberOfDisks)
+					logger = logger.WithValues("action", action, "new_capacity", newCapacity.String(), "max_capacity", policy.MaximumCapacityOfDisk.String(), "no_disks", len(pvcFamily), "max_disks", config.Spec.Policy.MaximumNumberOfDisks)
 
-					logger.Info("Find Node name")
+					logger.V(2).Info("Find Node name")
 
 					nodeName := r.NodeCache.GetNodesByIP()[pod.Status.HostIP]
 					if nodeName == "" {
 						metrics.NewError("Node", pod.Status.HostIP, "", "DiscoBlocks", "cache")
 
 						logger.Error(errors.New("node not found: "+pod.Status.HostIP), "Node not found", "IP", pod.Status.HostIP)
+						atomic.AddInt32(&summary.errors, 1)
 
 						if err := r.EventService.SendWarning(pod.Namespace, "Discoblocks", "PVC Monitor", fmt.Sprintf("Node not found for %s: %s", lastPVC.Name, pod.Status.HostIP), err.Error(), &pod, nil); err != nil {
 							metrics.NewError("Event", "", "", "Kube API", "create")
@@ -436,46 +1023,459 @@ func (r *PVCReconciler) MonitorVolumes() {
 
 					logger = logger.WithValues("node_name", nodeName)
 
-					if newCapacity.Cmp(config.Spec.Policy.MaximumCapacityOfDisk) == 1 {
+					if !newDiskRequested && !missingDisk && !utils.IsPVCPinned(lastPVC) {
+						volumeCapacity := lastPVC.Status.Capacity[corev1.ResourceStorage]
+						if isFileSystemBehind(lastPVC, lastUsage, r.FSSizeMismatchPercentage) {
+							fsSize := resource.NewQuantity(int64(lastUsage.Size*diskinfo.BlockSize), resource.BinarySI)
+
+							logger.Info("File-system is smaller than volume", "fs_size", fsSize.String(), "volume_capacity", volumeCapacity.String())
+
+							if next := nextResizeTime(config.Status.Resizes[lastPVC.Name], config.Spec.Policy.CoolDown.Duration); next.After(time.Now()) {
+								logger.V(1).Info("Resize backoff", "pvc_name", lastPVC.Name, "next", next)
+								continue
+							}
+
+							if !r.decide(&utils.AuditRecord{
+								Operation:   utils.AuditOperationGrowFS,
+								ConfigName:  config.Name,
+								Namespace:   config.Namespace,
+								PodName:     pod.Name,
+								PVCName:     lastPVC.Name,
+								OldCapacity: fsSize.String(),
+								NewCapacity: volumeCapacity.String(),
+								Reason:      fmt.Sprintf("used %.2f%% >= %g%%, file-system %s is smaller than volume %s", lastUsed, upscaleTrigger, fsSize.String(), volumeCapacity.String()),
+							}, &pod, lastPVC, logger) {
+								continue
+							}
+
+							r.InProgress.Store(config.Name, time.Now())
+
+							atomic.AddInt32(&summary.resizes, 1)
+
+							go r.growFileSystem(&config, &pod, volumeCapacity, lastPVC, nodeName, logger)
+
+							continue
+						}
+					}
+
+					if action == utils.ResizeActionNewDisk {
+						reason := fmt.Sprintf("used %.2f%% >= %g%%, maximum capacity of disk %s reached", lastUsed, upscaleTrigger, policy.MaximumCapacityOfDisk.String())
+						if recommended {
+							reason = fmt.Sprintf("used %.2f%%, recommended capacity is above maximum capacity of disk %s", lastUsed, policy.MaximumCapacityOfDisk.String())
+						} else if predicted {
+							reason = fmt.Sprintf("used %.2f%%, predicted to fill up within %s, maximum capacity of disk %s reached", lastUsed, horizon, policy.MaximumCapacityOfDisk.String())
+						} else if missingDisk {
+							reason = fmt.Sprintf("disk %d of %d is missing", actIndex+1, len(config.Spec.Disks))
+						} else if newDiskRequested {
+							reason = fmt.Sprintf("requested by %s annotation", utils.AddDiskAnnotation())
+
+							logger.Info("New disk requested")
+						}
+
 						if config.Spec.Policy.MaximumNumberOfDisks > 0 && len(pvcFamily) >= int(config.Spec.Policy.MaximumNumberOfDisks) {
 							logger.Info("Already maximum number of disks", "number", config.Spec.Policy.MaximumNumberOfDisks)
+
+							// Rejected request is answered by the event, it would be rejected again on every cycle
+							if newDiskRequested {
+								if err := r.EventService.SendWarning(pod.Namespace, "Discoblocks", "PVC Monitor", fmt.Sprintf("New disk request rejected for %s", lastPVC.Name), "Maximum number of disks reached", &pod, lastPVC); err != nil {
+									metrics.NewError("Event", "", "", "Kube API", "create")
+
+									logger.Error(err, "Failed to create event")
+								}
+
+								if err := r.clearNewDiskRequest(ctx, &pod, config.Name); err != nil {
+									metrics.NewError("Pod", pod.Name, pod.Namespace, "Kube API", "patch")
+
+									logger.Error(err, "Unable to clear new disk request")
+									atomic.AddInt32(&summary.errors, 1)
+								}
+							}
+
+							r.auditMaxReached(&utils.AuditRecord{
+								Operation:   utils.AuditOperationMaxReached,
+								ConfigName:  config.Name,
+								Namespace:   config.Namespace,
+								PodName:     pod.Name,
+								PVCName:     lastPVC.Name,
+								OldCapacity: lastCapacity.String(),
+								Reason:      fmt.Sprintf("%s, maximum number of disks %d reached", reason, config.Spec.Policy.MaximumNumberOfDisks),
+							}, config.Spec.Policy.MaximumNumberOfDisks, newDiskRequested, logger)
+
+							continue
+						}
+
+						if len(config.Spec.Disks) != 0 && !missingDisk {
+							logger.V(1).Info("Next disk is not created yet")
 							continue
 						}
 
 						logger.Info("New disk needed")
 
+						nextCapacity := config.Spec.GetDiskCapacity(actIndex + 1)
+
+						if !r.decide(&utils.AuditRecord{
+							Operation:   utils.AuditOperationNewDisk,
+							ConfigName:  config.Name,
+							Namespace:   config.Namespace,
+							PodName:     pod.Name,
+							PVCName:     lastPVC.Name,
+							OldCapacity: lastCapacity.String(),
+							NewCapacity: nextCapacity.String(),
+							Reason:      reason,
+						}, &pod, lastPVC, logger) {
+							continue
+						}
+
 						nextIndex := actIndex + 1
 
-						logger.Info("Next index", "index", nextIndex)
+						logger.V(1).Info("Next index", "index", nextIndex)
 
 						containerIDs := []string{}
 						for i := range pod.Status.ContainerStatuses {
-							cID := pod.Status.ContainerStatuses[i].ContainerID
-							for _, prefix := range []string{"containerd://", "docker://"} {
-								cID = strings.TrimPrefix(cID, prefix)
-							}
-
-							containerIDs = append(containerIDs, cID)
+							containerIDs = append(containerIDs, pod.Status.ContainerStatuses[i].ContainerID)
 						}
 
 						r.InProgress.Store(config.Name, time.Now())
 
+						atomic.AddInt32(&summary.newDisks, 1)
+
 						go r.createPVC(&config, &pod, pvcFamily[0], containerIDs, nodeName, nextIndex, logger)
 
 						continue
 					}
 
+					if next := nextResizeTime(config.Status.Resizes[lastPVC.Name], config.Spec.Policy.CoolDown.Duration); next.After(time.Now()) {
+						logger.V(1).Info("Resize backoff", "pvc_name", lastPVC.Name, "next", next)
+						continue
+					}
+
+					rolloutKey := lastPVC.Namespace + "/" + lastPVC.Name + "/rollout"
+					rollout := rolloutPercentage(&config, time.Now())
+					inRollout, err := utils.IsInRollout(string(lastPVC.UID), rollout)
+					if err != nil {
+						logger.Error(err, "Unable to decide resize rollout")
+						atomic.AddInt32(&summary.errors, 1)
+						continue
+					} else if !inRollout {
+						if steadyStateSampler(rolloutKey, "out") {
+							logger.Info("PVC is not in resize rollout", "rollout_%", rollout)
+						}
+						continue
+					}
+					steadyStateSampler(rolloutKey, "in")
+
 					logger.Info("Resize needed")
 
+					reason := fmt.Sprintf("used %.2f%% >= %g%%", lastUsed, upscaleTrigger)
+					if recommended {
+						reason = fmt.Sprintf("used %.2f%%, recommended by capacity recommender", lastUsed)
+					} else if predicted {
+						reason = fmt.Sprintf("used %.2f%%, predicted to fill up within %s", lastUsed, horizon)
+					}
+
+					if !r.decide(&utils.AuditRecord{
+						Operation:   utils.AuditOperationResize,
+						ConfigName:  config.Name,
+						Namespace:   config.Namespace,
+						PodName:     pod.Name,
+						PVCName:     lastPVC.Name,
+						OldCapacity: lastCapacity.String(),
+						NewCapacity: newCapacity.String(),
+						Reason:      reason,
+					}, &pod, lastPVC, logger) {
+						continue
+					}
+
 					r.InProgress.Store(config.Name, time.Now())
 
+					atomic.AddInt32(&summary.resizes, 1)
+
+					if r.ResizeRequests {
+						go r.requestResize(&config, &pod, newCapacity, lastPVC, nodeName, reason, logger)
+						continue
+					}
+
 					go r.resizePVC(&config, &pod, newCapacity, lastPVC, nodeName, logger)
 				}
-			}()
+			}
 		}
+	}
+}
 
-		wg.Wait()
+// monitorSummary counts outcomes of a monitor cycle, disk info of Pods is fetched in parallel
+type monitorSummary struct {
+	start        time.Time
+	podsScraped  int32
+	metricsFound int32
+	resizes      int32
+	newDisks     int32
+	errors       int32
+}
+
+// log reports the summary as the heartbeat of the cycle
+func (s *monitorSummary) log(logger logr.Logger) {
+	logger.Info("Monitor done",
+		"pods_scraped", atomic.LoadInt32(&s.podsScraped),
+		"metrics_found", atomic.LoadInt32(&s.metricsFound),
+		"resizes", atomic.LoadInt32(&s.resizes),
+		"new_disks", atomic.LoadInt32(&s.newDisks),
+		"errors", atomic.LoadInt32(&s.errors),
+		"duration", time.Since(s.start).String())
+}
+
+// recommend consults the capacity recommender within timeout, failures are reported and the caller keeps the built-in decision
+func (r *PVCReconciler) recommend(ctx context.Context, request *utils.RecommendationRequest, logger logr.Logger) (resource.Quantity, error) {
+	timeout := r.CapacityRecommenderTimeout
+	if timeout <= 0 {
+		timeout = utils.DefaultRecommenderTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	target, err := r.CapacityRecommender.Recommend(ctx, request)
+	if err != nil {
+		metrics.NewError("PersistentVolumeClaim", request.PVCName, request.Namespace, "Recommender", "recommend")
+
+		logger.Error(err, "Unable to get recommendation, built-in logic decides")
+
+		return resource.Quantity{}, err
 	}
+
+	return target, nil
+}
+
+// isStale reports metrics of the PVC older than staleness tolerance, exporters may get stuck and decisions on old usage are wrong
+func (r *PVCReconciler) isStale(pvc *corev1.PersistentVolumeClaim, usage diskinfo.DiskUsage, now time.Time, logger logr.Logger) bool {
+	if !usage.IsStale(now, r.MetricsStalenessTolerance, r.MetricsStalenessJitter) {
+		return false
+	}
+
+	metrics.NewStaleMetrics(pvc.Name, pvc.Namespace)
+
+	if steadyStateSampler(pvc.Namespace+"/"+pvc.Name, "stale") {
+		logger.V(1).Info("Metrics are stale, decision skipped", "measured", usage.Timestamp, "tolerance", r.MetricsStalenessTolerance, "jitter", r.MetricsStalenessJitter)
+	}
+
+	return true
+}
+
+// isInGracePeriod reports disks within the initial grace period, which starts at the creation of the PVC or at the start of the Pod, whichever is later
+func (r *PVCReconciler) isInGracePeriod(pod *corev1.Pod, pvc *corev1.PersistentVolumeClaim, grace time.Duration, now time.Time, logger logr.Logger) bool {
+	if grace <= 0 {
+		return false
+	}
+
+	start := pvc.CreationTimestamp.Time
+	if pod.Status.StartTime != nil && pod.Status.StartTime.After(start) {
+		start = pod.Status.StartTime.Time
+	}
+
+	end := start.Add(grace)
+	if !now.Before(end) {
+		return false
+	}
+
+	if steadyStateSampler(pvc.Namespace+"/"+pvc.Name, "grace") {
+		logger.V(1).Info("Initial grace period, resize skipped", "until", end)
+	}
+
+	return true
+}
+
+// getPredictionHorizon returns the prediction horizon of the policy, or the default one of the operator if it isn't set
+func (r *PVCReconciler) getPredictionHorizon(policy *discoblocksondatiov1.Policy) time.Duration {
+	if policy.PredictionHorizon.Duration > 0 {
+		return policy.PredictionHorizon.Duration
+	}
+
+	return r.PredictionHorizon
+}
+
+// isExhaustionPredicted records usage of the PVC and reports if it is predicted to fill up within the horizon.
+// Threshold rules decide alone until enough samples are collected.
+func (r *PVCReconciler) isExhaustionPredicted(pvc *corev1.PersistentVolumeClaim, usage diskinfo.DiskUsage, horizon time.Duration, now time.Time, logger logr.Logger) bool {
+	if r.FillRatePredictor == nil || horizon <= 0 {
+		return false
+	}
+
+	measured := usage.Timestamp
+	if measured.IsZero() {
+		measured = now
+	}
+
+	key := pvc.Namespace + "/" + pvc.Name
+	r.FillRatePredictor.Observe(key, measured, usage.Size, usage.Available)
+
+	timeToFull, ok := r.FillRatePredictor.TimeToFull(key)
+	if !ok || timeToFull > horizon {
+		return false
+	}
+
+	logger.Info("Disk is predicted to fill up", "time_to_full", timeToFull, "horizon", horizon)
+
+	return true
+}
+
+// restoreSampleHistory loads persisted samples into the fill rate predictor after restart or leader change.
+// History isn't saved until restore succeeds, so a failed load doesn't overwrite it.
+func (r *PVCReconciler) restoreSampleHistory(ctx context.Context, logger logr.Logger) {
+	history, err := r.SampleHistoryStore.Load(ctx)
+	if err != nil {
+		metrics.NewError("ConfigMap", "", "", "Kube API", "get")
+
+		logger.Error(err, "Unable to load sample history")
+		return
+	}
+
+	r.FillRatePredictor.Restore(history)
+	r.sampleHistoryRestored = true
+
+	logger.Info("Sample history restored", "disks", len(history))
+}
+
+// saveSampleHistory persists samples of the fill rate predictor, saves are throttled to avoid churn of the store
+func (r *PVCReconciler) saveSampleHistory(now time.Time, logger logr.Logger) {
+	if !r.sampleHistoryRestored || now.Sub(r.sampleHistorySaved) < sampleHistorySavePeriod {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), monitoringPeriod)
+	defer cancel()
+
+	if err := r.SampleHistoryStore.Save(ctx, r.FillRatePredictor.Snapshot(utils.MaxSampleHistoryDisks)); err != nil {
+		metrics.NewError("ConfigMap", "", "", "Kube API", "update")
+
+		logger.Error(err, "Unable to save sample history")
+		return
+	}
+
+	r.sampleHistorySaved = now
+}
+
+// isReadOnly reports read-only file-systems of the PVC family, resize of the last PVC is pointless if it is read-only
+func (r *PVCReconciler) isReadOnly(pod *corev1.Pod, pvcFamily []*corev1.PersistentVolumeClaim, pvcUsages map[string]diskinfo.DiskUsage, lastPVC *corev1.PersistentVolumeClaim, lastMountPoint string, logger logr.Logger) bool {
+	for _, pvc := range pvcFamily {
+		if usage, ok := pvcUsages[pvc.Name]; ok && pvc.DeletionTimestamp == nil {
+			metrics.SetReadOnlyFileSystem(pvc.Name, pvc.Namespace, usage.ReadOnly)
+		}
+	}
+
+	if !pvcUsages[lastPVC.Name].ReadOnly {
+		return false
+	}
+
+	if steadyStateSampler(lastPVC.Namespace+"/"+lastPVC.Name, "read-only") {
+		logger.Info("File-system is read-only, resize skipped")
+
+		if err := r.EventService.SendWarning(pod.Namespace, "Discoblocks", "PVC Monitor", fmt.Sprintf("File-system of %s is read-only: %s", lastPVC.Name, lastMountPoint), "Resize skipped, check file-system errors on the node", pod, lastPVC); err != nil {
+			metrics.NewError("Event", "", "", "Kube API", "create")
+
+			logger.Error(err, "Failed to create event")
+		}
+	}
+
+	return true
+}
+
+// isPinned checks whether the PVC is pinned by annotation, capacity of pinned PVCs is never changed regardless of the policy
+func (r *PVCReconciler) isPinned(pvc *corev1.PersistentVolumeClaim, logger logr.Logger) bool {
+	if !utils.IsPVCPinned(pvc) {
+		return false
+	}
+
+	if steadyStateSampler(pvc.Namespace+"/"+pvc.Name, "pinned") {
+		logger.Info("PVC is pinned, resize skipped", "annotation", utils.PinAnnotation())
+	}
+
+	return true
+}
+
+// isMountPatternValid checks mount point pattern of the config, admission is bypassed on webhook outage or by older versions.
+// Mount points of an unrenderable pattern never match metrics, so the config is reported instead of silently not scaling.
+func (r *PVCReconciler) isMountPatternValid(config *discoblocksondatiov1.DiskConfig, logger logr.Logger) bool {
+	key := config.Namespace + "/" + config.Name + "/mount-pattern"
+
+	err := utils.ValidateMountPointPattern(config.Spec.MountPointPattern)
+	if err == nil {
+		steadyStateSampler(key, "valid")
+		return true
+	}
+
+	if !steadyStateSampler(key, err.Error()) {
+		return false
+	}
+
+	metrics.NewError("DiskConfig", config.Name, config.Namespace, "DiscoBlocks", "validate")
+
+	logger.Error(err, "Invalid mount pattern, autoscaling skipped", "pattern", config.Spec.MountPointPattern)
+
+	if err := r.EventService.SendWarning(config.Namespace, "Discoblocks", "PVC Monitor", fmt.Sprintf("Invalid mount pattern of %s: %s", config.Name, config.Spec.MountPointPattern), err.Error(), config, nil); err != nil {
+		metrics.NewError("Event", "", "", "Kube API", "create")
+
+		logger.Error(err, "Failed to create event")
+	}
+
+	return false
+}
+
+// renderPodPVCFamilies groups active PVCs of the Pod by their first PVC
+func renderPodPVCFamilies(pod *corev1.Pod, activePVCs []*corev1.PersistentVolumeClaim) map[string][]*corev1.PersistentVolumeClaim {
+	podPVCsByParent := map[string][]*corev1.PersistentVolumeClaim{}
+	for i := range pod.Spec.Volumes {
+		if pod.Spec.Volumes[i].PersistentVolumeClaim == nil {
+			continue
+		}
+
+		for cp := range activePVCs {
+			if _, ok := activePVCs[cp].Labels[utils.ParentLabel()]; !ok &&
+				pod.Spec.Volumes[i].PersistentVolumeClaim.ClaimName == activePVCs[cp].Name {
+				if _, ok := podPVCsByParent[activePVCs[cp].Name]; !ok {
+					podPVCsByParent[activePVCs[cp].Name] = []*corev1.PersistentVolumeClaim{}
+				}
+				podPVCsByParent[activePVCs[cp].Name] = append(podPVCsByParent[activePVCs[cp].Name], activePVCs[cp])
+
+				for cc := range activePVCs {
+					if parent, ok := activePVCs[cc].Labels[utils.ParentLabel()]; ok && parent == activePVCs[cp].Name {
+						podPVCsByParent[activePVCs[cp].Name] = append(podPVCsByParent[activePVCs[cp].Name], activePVCs[cc])
+					}
+				}
+			}
+		}
+	}
+
+	return podPVCsByParent
+}
+
+// mergeDiskUsages collects metrics of PVCs reported by all Pods, the fullest report wins if a PVC is shared.
+// Kubelet reports usages by PVC names, no mount point matching is needed.
+func mergeDiskUsages(config *discoblocksondatiov1.DiskConfig, stagingPath string, podPVCFamilies map[string]map[string][]*corev1.PersistentVolumeClaim, podDiskInfos map[string]map[string]diskinfo.DiskUsage) map[string]diskinfo.DiskUsage {
+	reports := map[string][]diskinfo.DiskUsage{}
+	for podName, families := range podPVCFamilies {
+		for _, pvcFamily := range families {
+			for _, pvc := range pvcFamily {
+				var usage diskinfo.DiskUsage
+				var ok bool
+				if config.Spec.MetricsSource == discoblocksondatiov1.MetricsSourceKubelet {
+					usage, ok = podDiskInfos[podName][pvc.Name]
+				} else {
+					usage, ok = diskinfo.Lookup(podDiskInfos[podName], utils.RenderDiskMountPoint(config, config.Name, utils.GetPVCIndex(pvc)), pvc.Spec.VolumeName, utils.RenderStagingPath(stagingPath, pvc.Spec.VolumeName))
+				}
+				if !ok {
+					continue
+				}
+
+				reports[pvc.Name] = append(reports[pvc.Name], usage)
+			}
+		}
+	}
+
+	pvcUsages := make(map[string]diskinfo.DiskUsage, len(reports))
+	for pvcName := range reports {
+		pvcUsages[pvcName] = diskinfo.Merge(reports[pvcName])
+	}
+
+	return pvcUsages
 }
 
 //nolint:gocyclo // It is complex we know
@@ -572,6 +1572,31 @@ func (r *PVCReconciler) createPVC(config *discoblocksondatiov1.DiskConfig, pod *
 	logger = logger.WithValues("pvc_name", pvc.Name)
 
 	utils.PVCDecorator(config, prefix, driver, pvc)
+	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = config.Spec.GetDiskCapacity(nextIndex)
+
+	for k, v := range utils.RenderOwnerLabels(pod, config.Spec.AvailabilityMode == discoblocksondatiov1.ReadWriteOnce) {
+		pvc.Labels[k] = v
+	}
+
+	local, err := driver.IsNodeLocal()
+	if err != nil {
+		metrics.NewError("CSI", "", "", sc.Provisioner, "IsNodeLocal")
+
+		logger.Error(err, "Failed to call driver", "method", "IsNodeLocal")
+
+		if err := r.EventService.SendWarning(pod.Namespace, "Discoblocks", "PVC Monitor", fmt.Sprintf("Failed to call driver.IsNodeLocal for %s: %s", config.Name, sc.Provisioner), err.Error(), pod, config); err != nil {
+			metrics.NewError("Event", "", "", "Kube API", "create")
+
+			logger.Error(err, "Failed to create event")
+		}
+
+		return
+	}
+
+	// New disk of a local volume family has to be on the node of the Pod
+	if local {
+		utils.SetSelectedNode(pvc, nodeName)
+	}
 
 	scAllowedTopology, err := driver.GetStorageClassAllowedTopology(node)
 	if err != nil {
@@ -675,8 +1700,8 @@ func (r *PVCReconciler) createPVC(config *discoblocksondatiov1.DiskConfig, pod *
 		pvc.Spec.StorageClassName = &topologySC.Name
 	}
 
-	pvc.Labels["discoblocks-parent"] = parentPVC.Name
-	pvc.Labels["discoblocks-index"] = fmt.Sprintf("%d", nextIndex)
+	pvc.Labels[utils.ParentLabel()] = parentPVC.Name
+	pvc.Labels[utils.IndexLabel()] = fmt.Sprintf("%d", nextIndex)
 
 	pvc.OwnerReferences = []metav1.OwnerReference{
 		{
@@ -689,7 +1714,7 @@ func (r *PVCReconciler) createPVC(config *discoblocksondatiov1.DiskConfig, pod *
 
 	logger.Info("Create PVC...")
 
-	if err = r.Client.Create(ctx, pvc); err != nil {
+	if err = utils.CreatePVC(ctx, r.Client, pvc, config.Spec.VolumeAttributesClassName); err != nil {
 		metrics.NewError("PersistentVolume", pvc.Name, pvc.Namespace, "Kube API", "create")
 
 		logger.Error(err, "Failed to create PVC")
@@ -702,7 +1727,14 @@ func (r *PVCReconciler) createPVC(config *discoblocksondatiov1.DiskConfig, pod *
 
 		return
 	}
-	metrics.NewPVCOperation(pvc.Name, pvc.Namespace, "create", config.Spec.Capacity.String())
+	metrics.NewPVCOperation(pvc.Name, pvc.Namespace, "create", pvc.Spec.Resources.Requests.Storage().String())
+
+	// New disk request is consumed only by the created disk, failed or skipped creation keeps it for the next cycle
+	if err := r.clearNewDiskRequest(ctx, pod, config.Name); err != nil {
+		metrics.NewError("Pod", pod.Name, pod.Namespace, "Kube API", "patch")
+
+		logger.Error(err, "Unable to clear new disk request")
+	}
 
 	waitCtx, cancel := context.WithTimeout(context.Background(), config.Spec.Policy.CoolDown.Duration)
 	defer cancel()
@@ -859,7 +1891,7 @@ WAIT_CSI:
 		}
 	}
 
-	preMountCmd, err := driver.GetPreMountCommand(pv, volumeAttachment)
+	preMountCmd, err := driver.GetPreMountCommand(pv, volumeAttachment, string(config.Spec.DevicePathStrategy))
 	if err != nil {
 		metrics.NewError("CSI", pv.Name, "", sc.Provisioner, "GetPreMountCommand")
 
@@ -874,9 +1906,54 @@ WAIT_CSI:
 		return
 	}
 
-	mountpoint := utils.RenderMountPoint(config.Spec.MountPointPattern, config.Name, nextIndex)
+	isFsManaged, err := driver.IsFileSystemManaged()
+	if err != nil {
+		metrics.NewError("CSI", "", "", sc.Provisioner, "IsFileSystemManaged")
+
+		logger.Error(err, "Failed to call driver", "method", "IsFileSystemManaged")
+
+		if err := r.EventService.SendWarning(pod.Namespace, "Discoblocks", "PVC Monitor", fmt.Sprintf("Failed to call driver.IsFileSystemManaged %s: %s", config.Name, sc.Provisioner), err.Error(), pod, config); err != nil {
+			metrics.NewError("Event", "", "", "Kube API", "create")
+
+			logger.Error(err, "Failed to create event")
+		}
+
+		return
+	}
+
+	hostVolumes, err := driver.GetHostJobVolumes()
+	if err != nil {
+		metrics.NewError("CSI", "", "", sc.Provisioner, "GetHostJobVolumes")
+
+		logger.Error(err, "Failed to call driver", "method", "GetHostJobVolumes")
+
+		if err := r.EventService.SendWarning(pod.Namespace, "Discoblocks", "PVC Monitor", fmt.Sprintf("Failed to call driver.GetHostJobVolumes for %s: %s", config.Name, sc.Provisioner), err.Error(), pod, config); err != nil {
+			metrics.NewError("Event", "", "", "Kube API", "create")
+
+			logger.Error(err, "Failed to create event")
+		}
+
+		return
+	}
+
+	stagingPath, err := driver.GetStagingPath()
+	if err != nil {
+		metrics.NewError("CSI", "", "", sc.Provisioner, "GetStagingPath")
+
+		logger.Error(err, "Failed to call driver", "method", "GetStagingPath")
+
+		if err := r.EventService.SendWarning(pod.Namespace, "Discoblocks", "PVC Monitor", fmt.Sprintf("Failed to call driver.GetStagingPath for %s: %s", config.Name, sc.Provisioner), err.Error(), pod, config); err != nil {
+			metrics.NewError("Event", "", "", "Kube API", "create")
+
+			logger.Error(err, "Failed to create event")
+		}
+
+		return
+	}
+
+	mountpoint := utils.RenderDiskMountPoint(config, config.Name, nextIndex)
 
-	mountJob, err := utils.RenderMountJob(pod.Name, pvc.Name, pvc.Spec.VolumeName, pvc.Namespace, nodeName, pv.Spec.CSI.FSType, mountpoint, containerIDs, preMountCmd, volumeMeta, metav1.OwnerReference{
+	mountJob, err := utils.RenderMountJob(pod.Name, pvc.Name, pvc.Spec.VolumeName, pvc.Namespace, nodeName, utils.GetDiskFileSystem(config, nextIndex, pv.Spec.CSI.FSType), !isFsManaged, mountpoint, containerIDs, preMountCmd, volumeMeta, stagingPath, hostVolumes, config.Spec.MountEnv, metav1.OwnerReference{
 		APIVersion: parentPVC.APIVersion,
 		Kind:       parentPVC.Kind,
 		Name:       pvc.Name,
@@ -887,9 +1964,17 @@ WAIT_CSI:
 		return
 	}
 
+	if err := utils.AddVolumeEnv(mountJob, pv, &sc); err != nil {
+		logger.Error(err, "Unable to render volume env of mount job")
+		return
+	}
+
 	logger.Info("Create mount Job...", "containers", containerIDs, "mountpoint", mountpoint)
 
-	if err := r.Client.Create(ctx, mountJob); err != nil {
+	jobCtx, jobCancel := context.WithTimeout(context.Background(), hostJobQueueTimeout)
+	defer jobCancel()
+
+	if err := r.HostJobQueue.Create(jobCtx, r.Client, mountJob); err != nil {
 		metrics.NewError("Job", mountJob.Name, mountJob.Namespace, "Kube API", "create")
 
 		logger.Error(err, "Failed to create mount job")
@@ -904,17 +1989,22 @@ WAIT_CSI:
 	}
 }
 
+// resizePVC expands the PVC and grows file-system of additional disks, returns whether the resize succeeded
 //nolint:gocyclo // It is complex we know
-func (r *PVCReconciler) resizePVC(config *discoblocksondatiov1.DiskConfig, pod *corev1.Pod, capacity resource.Quantity, pvc *corev1.PersistentVolumeClaim, nodeName string, logger logr.Logger) {
-	logger.Info("Update PVC...", "capacity", capacity.AsApproximateFloat64())
+func (r *PVCReconciler) resizePVC(config *discoblocksondatiov1.DiskConfig, pod *corev1.Pod, capacity resource.Quantity, pvc *corev1.PersistentVolumeClaim, nodeName string, logger logr.Logger) bool {
+	succeeded := false
+	defer func() {
+		r.recordResize(config, pvc.Name, succeeded, logger)
+	}()
 
-	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = capacity
+	logger.Info("Update PVC...", "capacity", capacity.AsApproximateFloat64())
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
 
-	if err := r.Client.Update(ctx, pvc); err != nil {
-		metrics.NewError("PersistentVolumeClaim", pvc.Name, pvc.Namespace, "Kube API", "get")
+	updated, err := r.updatePVCCapacity(ctx, pvc, capacity)
+	if err != nil {
+		metrics.NewError("PersistentVolumeClaim", pvc.Name, pvc.Namespace, "Kube API", "update")
 
 		logger.Error(err, "Failed to update PVC")
 
@@ -924,22 +2014,153 @@ func (r *PVCReconciler) resizePVC(config *discoblocksondatiov1.DiskConfig, pod *
 			logger.Error(err, "Failed to create event")
 		}
 
-		return
+		return false
+	} else if !updated {
+		logger.Info("PVC has been resized in the meantime")
+
+		succeeded = true
+
+		return true
 	}
 	metrics.NewPVCOperation(pvc.Name, pvc.Namespace, "resize", capacity.String())
 
-	if _, ok := pvc.Labels["discoblocks-parent"]; !ok {
+	if _, ok := pvc.Labels[utils.ParentLabel()]; !ok {
 		logger.Info("First PVC is managed by CSI driver")
 
+		succeeded = true
+
 		if err := r.EventService.SendNormal(pod.Namespace, "Discoblocks", "PVC Monitor", fmt.Sprintf("New capacity of %s: %s", pvc.Name, capacity.String()), "Operation finished: disk managed by CSI driver", pod, pvc); err != nil {
 			metrics.NewError("Event", "", "", "Kube API", "create")
 
 			logger.Error(err, "Failed to create event")
 		}
 
+		return true
+	}
+
+	succeeded = r.createResizeJob(ctx, config, pod, capacity, pvc, nodeName, logger)
+
+	return succeeded
+}
+
+// requestResize creates a VolumeResizeRequest of the decided resize, the request is applied by its own controller.
+// Only one open request is kept per PVC.
+func (r *PVCReconciler) requestResize(config *discoblocksondatiov1.DiskConfig, pod *corev1.Pod, capacity resource.Quantity, pvc *corev1.PersistentVolumeClaim, nodeName, reason string, logger logr.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	requests := discoblocksondatiov1.VolumeResizeRequestList{}
+	if err := r.Client.List(ctx, &requests, client.InNamespace(pvc.Namespace), client.MatchingLabels{utils.ConfigLabel(): config.Name}); err != nil {
+		metrics.NewError("VolumeResizeRequest", "", pvc.Namespace, "Kube API", "list")
+
+		logger.Error(err, "Unable to list resize requests")
+		return
+	}
+
+	if open := utils.FindOpenVolumeResizeRequest(requests.Items, pvc.Name); open != nil {
+		logger.Info("PVC has an open resize request", "request_name", open.Name, "phase", open.Status.Phase)
+		return
+	}
+
+	request, err := utils.RenderVolumeResizeRequest(config.Name, pod.Name, nodeName, pvc, capacity, reason, time.Now())
+	if err != nil {
+		logger.Error(err, "Unable to render resize request")
+		return
+	}
+
+	logger.Info("Create resize request...", "request_name", request.Name, "capacity", capacity.String())
+
+	if err := r.Client.Create(ctx, request); err != nil {
+		metrics.NewError("VolumeResizeRequest", request.Name, request.Namespace, "Kube API", "create")
+
+		logger.Error(err, "Failed to create resize request")
+
+		if err := r.EventService.SendWarning(pod.Namespace, "Discoblocks", "PVC Monitor", fmt.Sprintf("Failed to request resize for %s: %s", config.Name, pvc.Name), err.Error(), pod, pvc); err != nil {
+			metrics.NewError("Event", "", "", "Kube API", "create")
+
+			logger.Error(err, "Failed to create event")
+		}
+
 		return
 	}
 
+	if err := r.EventService.SendNormal(pod.Namespace, "Discoblocks", "PVC Monitor", fmt.Sprintf("Resize of %s to %s requested", pvc.Name, capacity.String()), request.Name, pod, pvc); err != nil {
+		metrics.NewError("Event", "", "", "Kube API", "create")
+
+		logger.Error(err, "Failed to create event")
+	}
+}
+
+// updatePVCCapacity sets the requested capacity on the latest version of the PVC under its lock, conflicts are retried.
+// Capacity is never lowered, false means the PVC has been resized to at least the capacity by someone else.
+func (r *PVCReconciler) updatePVCCapacity(ctx context.Context, pvc *corev1.PersistentVolumeClaim, capacity resource.Quantity) (bool, error) {
+	key := types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}
+
+	defer pvcLock.Lock(key.String())()
+
+	updated := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := corev1.PersistentVolumeClaim{}
+		if err := r.Client.Get(ctx, key, &latest); err != nil {
+			return err
+		}
+
+		if current, ok := latest.Spec.Resources.Requests[corev1.ResourceStorage]; ok && current.Cmp(capacity) >= 0 {
+			*pvc = latest
+			return nil
+		}
+
+		if latest.Spec.Resources.Requests == nil {
+			latest.Spec.Resources.Requests = corev1.ResourceList{}
+		}
+		latest.Spec.Resources.Requests[corev1.ResourceStorage] = capacity
+
+		if err := r.Client.Update(ctx, &latest); err != nil {
+			return err
+		}
+
+		*pvc = latest
+		updated = true
+
+		return nil
+	})
+
+	return updated, err
+}
+
+// isFileSystemBehind checks has the file-system of an additional disk not followed the last expansion of its volume.
+// File-system of the first disk is grown by the CSI driver, so it is resized as usual. Zero percentage disables the check.
+func isFileSystemBehind(pvc *corev1.PersistentVolumeClaim, usage diskinfo.DiskUsage, percentage float64) bool {
+	if percentage <= 0 {
+		return false
+	}
+
+	if _, ok := pvc.Labels[utils.ParentLabel()]; !ok {
+		return false
+	}
+
+	volumeCapacity := pvc.Status.Capacity[corev1.ResourceStorage]
+
+	return usage.IsBehind(volumeCapacity.AsApproximateFloat64(), percentage)
+}
+
+// growFileSystem creates resize Job without expanding the volume, file-system has not followed the last expansion
+func (r *PVCReconciler) growFileSystem(config *discoblocksondatiov1.DiskConfig, pod *corev1.Pod, capacity resource.Quantity, pvc *corev1.PersistentVolumeClaim, nodeName string, logger logr.Logger) {
+	succeeded := false
+	defer func() {
+		r.recordResize(config, pvc.Name, succeeded, logger)
+	}()
+
+	logger.Info("Grow file-system...", "capacity", capacity.AsApproximateFloat64())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	succeeded = r.createResizeJob(ctx, config, pod, capacity, pvc, nodeName, logger)
+}
+
+// createResizeJob creates the host Job which grows file-system of PVC
+func (r *PVCReconciler) createResizeJob(ctx context.Context, config *discoblocksondatiov1.DiskConfig, pod *corev1.Pod, capacity resource.Quantity, pvc *corev1.PersistentVolumeClaim, nodeName string, logger logr.Logger) bool {
 	sc := storagev1.StorageClass{}
 	if err := r.Client.Get(ctx, types.NamespacedName{Name: config.Spec.StorageClassName}, &sc); err != nil {
 		metrics.NewError("StorageClass", config.Spec.StorageClassName, "", "Kube API", "get")
@@ -953,7 +2174,7 @@ func (r *PVCReconciler) resizePVC(config *discoblocksondatiov1.DiskConfig, pod *
 				logger.Error(err, "Failed to create event")
 			}
 
-			return
+			return false
 		}
 
 		logger.Error(err, "Unable to fetch StorageClass")
@@ -964,7 +2185,7 @@ func (r *PVCReconciler) resizePVC(config *discoblocksondatiov1.DiskConfig, pod *
 			logger.Error(err, "Failed to create event")
 		}
 
-		return
+		return false
 	}
 	logger = logger.WithValues("provisioner", sc.Provisioner)
 
@@ -982,7 +2203,7 @@ func (r *PVCReconciler) resizePVC(config *discoblocksondatiov1.DiskConfig, pod *
 			logger.Error(err, "Failed to create event")
 		}
 
-		return
+		return false
 	}
 
 	if isFsManaged, err := driver.IsFileSystemManaged(); err != nil {
@@ -996,7 +2217,7 @@ func (r *PVCReconciler) resizePVC(config *discoblocksondatiov1.DiskConfig, pod *
 			logger.Error(err, "Failed to create event")
 		}
 
-		return
+		return false
 	} else if isFsManaged {
 		logger.Info("Filesystem will resized by CSI driver")
 
@@ -1006,7 +2227,7 @@ func (r *PVCReconciler) resizePVC(config *discoblocksondatiov1.DiskConfig, pod *
 			logger.Error(err, "Failed to create event")
 		}
 
-		return
+		return true
 	}
 
 	waitForMeta, err := driver.WaitForVolumeAttachmentMeta()
@@ -1021,7 +2242,7 @@ func (r *PVCReconciler) resizePVC(config *discoblocksondatiov1.DiskConfig, pod *
 			logger.Error(err, "Failed to create event")
 		}
 
-		return
+		return false
 	}
 
 	logger.Info("Find PersistentVolume...")
@@ -1038,7 +2259,7 @@ func (r *PVCReconciler) resizePVC(config *discoblocksondatiov1.DiskConfig, pod *
 			logger.Error(err, "Failed to create event")
 		}
 
-		return
+		return false
 	} else if pv.Spec.CSI == nil {
 		metrics.NewError("PersistentVolume", pv.Name, "", "Kube API", "get")
 
@@ -1050,7 +2271,7 @@ func (r *PVCReconciler) resizePVC(config *discoblocksondatiov1.DiskConfig, pod *
 			logger.Error(err, "Failed to create event")
 		}
 
-		return
+		return false
 	}
 
 	var volumeAttachment *storagev1.VolumeAttachment
@@ -1071,7 +2292,7 @@ func (r *PVCReconciler) resizePVC(config *discoblocksondatiov1.DiskConfig, pod *
 				logger.Error(err, "Failed to create event")
 			}
 
-			return
+			return false
 		}
 
 		volumeMeta = volumeAttachment.Status.AttachmentMetadata[waitForMeta]
@@ -1086,11 +2307,11 @@ func (r *PVCReconciler) resizePVC(config *discoblocksondatiov1.DiskConfig, pod *
 				logger.Error(err, "Failed to create event")
 			}
 
-			return
+			return false
 		}
 	}
 
-	preResizeCmd, err := driver.GetPreResizeCommand(pv, volumeAttachment)
+	preResizeCmd, err := driver.GetPreResizeCommand(pv, volumeAttachment, string(config.Spec.DevicePathStrategy))
 	if err != nil {
 		metrics.NewError("CSI", pv.Name, "", sc.Provisioner, "GetPreResizeCommand")
 
@@ -1102,10 +2323,66 @@ func (r *PVCReconciler) resizePVC(config *discoblocksondatiov1.DiskConfig, pod *
 			logger.Error(err, "Failed to create event")
 		}
 
-		return
+		return false
+	}
+
+	hostVolumes, err := driver.GetHostJobVolumes()
+	if err != nil {
+		metrics.NewError("CSI", "", "", sc.Provisioner, "GetHostJobVolumes")
+
+		logger.Error(err, "Failed to call driver", "method", "GetHostJobVolumes")
+
+		if err := r.EventService.SendWarning(pod.Namespace, "Discoblocks", "PVC Monitor", fmt.Sprintf("Failed to call driver.GetHostJobVolumes for %s: %s", config.Name, sc.Provisioner), err.Error(), pod, config); err != nil {
+			metrics.NewError("Event", "", "", "Kube API", "create")
+
+			logger.Error(err, "Failed to create event")
+		}
+
+		return false
+	}
+
+	stagingPath, err := driver.GetStagingPath()
+	if err != nil {
+		metrics.NewError("CSI", "", "", sc.Provisioner, "GetStagingPath")
+
+		logger.Error(err, "Failed to call driver", "method", "GetStagingPath")
+
+		if err := r.EventService.SendWarning(pod.Namespace, "Discoblocks", "PVC Monitor", fmt.Sprintf("Failed to call driver.GetStagingPath for %s: %s", config.Name, sc.Provisioner), err.Error(), pod, config); err != nil {
+			metrics.NewError("Event", "", "", "Kube API", "create")
+
+			logger.Error(err, "Failed to create event")
+		}
+
+		return false
+	}
+
+	preHook, err := utils.RenderResizeHook(pod, config.Spec.Policy.PreResizeHook)
+	if err != nil {
+		logger.Error(err, "Failed to render pre resize hook")
+
+		if err := r.EventService.SendWarning(pod.Namespace, "Discoblocks", "PVC Monitor", fmt.Sprintf("Failed to render pre resize hook for %s", config.Name), err.Error(), pod, config); err != nil {
+			metrics.NewError("Event", "", "", "Kube API", "create")
+
+			logger.Error(err, "Failed to create event")
+		}
+
+		return false
+	}
+
+	postHook, err := utils.RenderResizeHook(pod, config.Spec.Policy.PostResizeHook)
+	if err != nil {
+		logger.Error(err, "Failed to render post resize hook")
+
+		if err := r.EventService.SendWarning(pod.Namespace, "Discoblocks", "PVC Monitor", fmt.Sprintf("Failed to render post resize hook for %s", config.Name), err.Error(), pod, config); err != nil {
+			metrics.NewError("Event", "", "", "Kube API", "create")
+
+			logger.Error(err, "Failed to create event")
+		}
+
+		return false
 	}
 
-	resizeJob, err := utils.RenderResizeJob(pod.Name, pvc.Name, pvc.Spec.VolumeName, pvc.Namespace, nodeName, pv.Spec.CSI.FSType, preResizeCmd, volumeMeta, metav1.OwnerReference{
+	resizeJob, err := utils.RenderResizeJob(pod.Name, pvc.Name, pvc.Spec.VolumeName, pvc.Namespace, nodeName, utils.GetDiskFileSystem(config, utils.GetPVCIndex(pvc), pv.Spec.CSI.FSType), preResizeCmd, volumeMeta, stagingPath, hostVolumes, config.Spec.MountEnv, preHook, postHook, metav1.OwnerReference{
 		APIVersion: pvc.APIVersion,
 		Kind:       pvc.Kind,
 		Name:       pvc.Name,
@@ -1113,14 +2390,22 @@ func (r *PVCReconciler) resizePVC(config *discoblocksondatiov1.DiskConfig, pod *
 	})
 	if err != nil {
 		logger.Error(err, "Unable to render mount job")
-		return
+		return false
 	} else if resizeJob == nil {
-		return
+		return false
+	}
+
+	if err := utils.AddVolumeEnv(resizeJob, pv, &sc); err != nil {
+		logger.Error(err, "Unable to render volume env of resize job")
+		return false
 	}
 
 	logger.Info("Create resize Job...")
 
-	if err := r.Client.Create(ctx, resizeJob); err != nil {
+	jobCtx, jobCancel := context.WithTimeout(context.Background(), hostJobQueueTimeout)
+	defer jobCancel()
+
+	if err := r.HostJobQueue.Create(jobCtx, r.Client, resizeJob); err != nil {
 		metrics.NewError("Job", resizeJob.Name, resizeJob.Namespace, "Kube API", "create")
 
 		logger.Error(err, "Failed to create resize job")
@@ -1131,8 +2416,140 @@ func (r *PVCReconciler) resizePVC(config *discoblocksondatiov1.DiskConfig, pod *
 			logger.Error(err, "Failed to create event")
 		}
 
+		return false
+	}
+
+	return true
+}
+
+// recordResize stores outcome of resize in DiskConfig status
+func (r *PVCReconciler) recordResize(config *discoblocksondatiov1.DiskConfig, pvcName string, succeeded bool, logger logr.Logger) {
+	status := discoblocksondatiov1.ResizeStatus{
+		Succeeded:       succeeded,
+		LastAttemptTime: metav1.Now(),
+	}
+	if !succeeded {
+		status.Attempts = config.Status.Resizes[pvcName].Attempts + 1
+	}
+
+	rawPatch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"resizes": map[string]discoblocksondatiov1.ResizeStatus{
+				pvcName: status,
+			},
+		},
+	})
+	if err != nil {
+		logger.Error(err, "Unable to render resize status patch")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	target := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      config.Name,
+			Namespace: config.Namespace,
+		},
+	}
+
+	if err := r.Client.Status().Patch(ctx, &target, client.RawPatch(types.MergePatchType, rawPatch)); err != nil {
+		metrics.NewError("DiskConfig", config.Name, config.Namespace, "Kube API", "patch")
+
+		logger.Error(err, "Unable to update resize status")
+	}
+}
+
+// nextResizeTime returns the earliest time of the next resize.
+// Successful resize waits for cool down, failed one backs off exponentially.
+func nextResizeTime(status discoblocksondatiov1.ResizeStatus, coolDown time.Duration) time.Time {
+	if status.LastAttemptTime.IsZero() {
+		return time.Time{}
+	}
+
+	backoff := coolDown
+	if !status.Succeeded {
+		attempts := status.Attempts
+		if attempts > maxResizeBackoffExponent {
+			attempts = maxResizeBackoffExponent
+		}
+
+		backoff = coolDown << attempts
+	}
+
+	return status.LastAttemptTime.Add(backoff)
+}
+
+// isResizeAllowed checks whether the PVC is neither pinned nor within resize backoff
+func isResizeAllowed(config *discoblocksondatiov1.DiskConfig, pvc *corev1.PersistentVolumeClaim, now time.Time) bool {
+	if utils.IsPVCPinned(pvc) {
+		return false
+	}
+
+	return !nextResizeTime(config.Status.Resizes[pvc.Name], config.Spec.Policy.CoolDown.Duration).After(now)
+}
+
+// decide records the decision, in plan only mode it reports the decision and returns false to skip execution
+func (r *PVCReconciler) decide(record *utils.AuditRecord, pod *corev1.Pod, pvc *corev1.PersistentVolumeClaim, logger logr.Logger) bool {
+	if !r.PlanOnly {
+		r.audit(record, logger)
+		return true
+	}
+
+	plan := utils.RenderPlan(record)
+
+	decision := record.Operation + "/" + record.OldCapacity + "/" + record.NewCapacity
+	if !planOnlySampler(pvc.Namespace+"/"+pvc.Name, decision) {
+		logger.V(1).Info("Plan only, decision is unchanged", "plan", plan)
+		return false
+	}
+
+	logger.Info("Plan only, operation skipped", "plan", plan)
+
+	if err := r.EventService.SendNormal(pod.Namespace, "Discoblocks", "PVC Monitor", "Plan only, operation skipped", plan, pod, pvc); err != nil {
+		metrics.NewError("Event", "", "", "Kube API", "create")
+
+		logger.Error(err, "Failed to create event")
+	}
+
+	return false
+}
+
+// clearNewDiskRequest removes the config from the new disk request annotation, so a request is served only once
+func (r *PVCReconciler) clearNewDiskRequest(ctx context.Context, pod *corev1.Pod, configName string) error {
+	patched := pod.DeepCopy()
+	if !utils.RemoveNewDiskRequest(patched, configName) {
+		return nil
+	}
+
+	if err := r.Client.Patch(ctx, patched, client.MergeFrom(pod)); err != nil {
+		return fmt.Errorf("unable to patch Pod: %w", err)
+	}
+
+	return nil
+}
+
+// auditMaxReached records reaching the maximum number of disks only on transition, the monitor hits it every cycle.
+// Rejected new disk requests are recorded every time.
+func (r *PVCReconciler) auditMaxReached(record *utils.AuditRecord, maxDisks int32, requested bool, logger logr.Logger) {
+	if !maxReachedAuditSampler(record.Namespace+"/"+record.PVCName, strconv.Itoa(int(maxDisks))) && !requested {
+		return
+	}
+
+	r.audit(record, logger)
+}
+
+func (r *PVCReconciler) audit(record *utils.AuditRecord, logger logr.Logger) {
+	if r.AuditService == nil {
 		return
 	}
+
+	if err := r.AuditService.Record(record); err != nil {
+		metrics.NewError("Audit", record.PVCName, record.Namespace, "DiscoBlocks", record.Operation)
+
+		logger.Error(err, "Failed to record audit")
+	}
 }
 
 func (r *PVCReconciler) getVolumeAttachment(ctx context.Context, volumeName string) (*storagev1.VolumeAttachment, error) {
@@ -1170,7 +2587,11 @@ func (ef pvcEventFilter) Create(e event.CreateEvent) bool {
 		return false
 	}
 
-	return controllerutil.ContainsFinalizer(newObj, utils.RenderFinalizer(newObj.Labels["discoblocks"]))
+	if newObj.Labels[utils.ConfigLabel()] == "" {
+		return false
+	}
+
+	return controllerutil.ContainsFinalizer(newObj, utils.RenderFinalizer(newObj.Labels[utils.ConfigLabel()]))
 }
 
 func (ef pvcEventFilter) Delete(_ event.DeleteEvent) bool {
@@ -1184,7 +2605,7 @@ func (ef pvcEventFilter) Update(e event.UpdateEvent) bool {
 		return false
 	}
 
-	if !controllerutil.ContainsFinalizer(newObj, utils.RenderFinalizer(newObj.Labels["discoblocks"])) {
+	if newObj.Labels[utils.ConfigLabel()] == "" || !controllerutil.ContainsFinalizer(newObj, utils.RenderFinalizer(newObj.Labels[utils.ConfigLabel()])) {
 		return false
 	}
 
@@ -1209,12 +2630,23 @@ func (r *PVCReconciler) SetupWithManager(mgr ctrl.Manager) (chan<- bool, error)
 		ticker := time.NewTicker(monitoringPeriod)
 		defer ticker.Stop()
 
+		syncTicker := time.NewTicker(statusSyncPeriod)
+		defer syncTicker.Stop()
+
+		var retryChan <-chan time.Time
+
 		for {
 			select {
 			case <-closeChan:
 				return
 			case <-ticker.C:
 				r.MonitorVolumes()
+				retryChan = r.scheduleScrapeRetry()
+			case <-retryChan:
+				r.RetryFailedScrapes()
+				retryChan = r.scheduleScrapeRetry()
+			case <-syncTicker.C:
+				r.SyncStatuses()
 			}
 		}
 	}()
@@ -0,0 +1,2645 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"github.com/ondat/discoblocks/pkg/diskinfo"
+	"github.com/ondat/discoblocks/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	restfake "k8s.io/client-go/rest/fake"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestDecide(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		planOnly         bool
+		expectedExecuted bool
+		expectedEvents   int
+	}{
+		"execute": {
+			expectedExecuted: true,
+		},
+		"plan only": {
+			planOnly:       true,
+			expectedEvents: 2,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pod",
+					Namespace: "default",
+					UID:       "pod",
+				},
+			}
+			pvc := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pvc",
+					Namespace: "default",
+					UID:       "pvc",
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceStorage: resource.MustParse("10Gi"),
+						},
+					},
+				},
+			}
+
+			kubeClient := fake.NewClientBuilder().WithObjects(&pod, &pvc).Build()
+
+			r := PVCReconciler{
+				EventService: utils.NewEventService("controller", kubeClient),
+				PlanOnly:     c.planOnly,
+				Client:       kubeClient,
+			}
+
+			record := func(newCapacity, reason string) *utils.AuditRecord {
+				return &utils.AuditRecord{
+					Operation:   utils.AuditOperationResize,
+					PVCName:     pvc.Name,
+					OldCapacity: "10Gi",
+					NewCapacity: newCapacity,
+					Reason:      reason,
+				}
+			}
+
+			executed := r.decide(record("11Gi", "used 81.00% >= 80%"), &pod, &pvc, logr.Discard())
+			assert.Equal(t, c.expectedExecuted, executed, "invalid execution")
+
+			// Next cycles of the same decision are not reported again, only new decisions
+			executed = r.decide(record("11Gi", "used 82.00% >= 80%"), &pod, &pvc, logr.Discard())
+			assert.Equal(t, c.expectedExecuted, executed, "invalid execution of same decision")
+
+			executed = r.decide(record("12Gi", "used 95.00% >= 80%"), &pod, &pvc, logr.Discard())
+			assert.Equal(t, c.expectedExecuted, executed, "invalid execution of new decision")
+
+			events := eventsv1.EventList{}
+			require.Nil(t, kubeClient.List(context.Background(), &events), "unable to list events")
+			require.Len(t, events.Items, c.expectedEvents, "invalid number of events")
+
+			if c.planOnly {
+				notes := []string{events.Items[0].Note, events.Items[1].Note}
+				assert.ElementsMatch(t, []string{
+					"PVC pvc would grow from 10Gi to 11Gi (used 81.00% >= 80%)",
+					"PVC pvc would grow from 10Gi to 12Gi (used 95.00% >= 80%)",
+				}, notes, "invalid plans")
+			}
+
+			actualPVC := corev1.PersistentVolumeClaim{}
+			require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}, &actualPVC), "unable to fetch PVC")
+
+			capacity := actualPVC.Spec.Resources.Requests[corev1.ResourceStorage]
+			assert.Equal(t, "10Gi", capacity.String(), "PVC updated")
+		})
+	}
+}
+
+// recordingAuditService keeps records in memory
+type recordingAuditService struct {
+	lock    sync.Mutex
+	records []utils.AuditRecord
+}
+
+func (as *recordingAuditService) Record(record *utils.AuditRecord) error {
+	as.lock.Lock()
+	defer as.lock.Unlock()
+
+	as.records = append(as.records, *record)
+
+	return nil
+}
+
+func TestAuditMaxReached(t *testing.T) {
+	t.Parallel()
+
+	auditService := recordingAuditService{}
+
+	r := PVCReconciler{
+		AuditService: &auditService,
+	}
+
+	record := func() *utils.AuditRecord {
+		return &utils.AuditRecord{
+			Operation: utils.AuditOperationMaxReached,
+			Namespace: "default",
+			PVCName:   "max-reached-audit",
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		r.auditMaxReached(record(), 2, false, logr.Discard())
+	}
+	assert.Len(t, auditService.records, 1, "steady state recorded every cycle")
+
+	r.auditMaxReached(record(), 2, true, logr.Discard())
+	assert.Len(t, auditService.records, 2, "rejected request not recorded")
+
+	r.auditMaxReached(record(), 3, false, logr.Discard())
+	assert.Len(t, auditService.records, 3, "new maximum not recorded")
+}
+
+func TestClearNewDiskRequest(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		annotation         string
+		expectedAnnotation string
+	}{
+		"single request": {
+			annotation: "config",
+		},
+		"multiple requests": {
+			annotation:         "config,other",
+			expectedAnnotation: "other",
+		},
+		"other request": {
+			annotation:         "other",
+			expectedAnnotation: "other",
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pod",
+					Namespace: "default",
+					Annotations: map[string]string{
+						utils.AddDiskAnnotation(): c.annotation,
+					},
+				},
+			}
+
+			kubeClient := fake.NewClientBuilder().WithObjects(&pod).Build()
+
+			r := PVCReconciler{
+				Client: kubeClient,
+			}
+
+			require.Nil(t, r.clearNewDiskRequest(context.Background(), &pod, "config"), "unable to clear request")
+
+			actualPod := corev1.Pod{}
+			require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, &actualPod), "unable to fetch Pod")
+
+			assert.Equal(t, c.expectedAnnotation, actualPod.Annotations[utils.AddDiskAnnotation()], "invalid annotation")
+			assert.Equal(t, c.annotation, pod.Annotations[utils.AddDiskAnnotation()], "original Pod modified")
+		})
+	}
+}
+
+// Reconcile tests are serial, controller semaphore allows one reconcile at a time
+func TestReconcileReleasesStuckPVC(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	cases := map[string]struct {
+		terminating       bool
+		configExists      bool
+		expectedFinalizer bool
+	}{
+		"orphan terminating": {
+			terminating: true,
+		},
+		"terminating with config": {
+			terminating:       true,
+			configExists:      true,
+			expectedFinalizer: true,
+		},
+		"orphan active": {
+			expectedFinalizer: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			pvc := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "pvc",
+					Namespace:  "default",
+					Labels:     map[string]string{utils.ConfigLabel(): "config"},
+					Finalizers: []string{utils.RenderFinalizer("config")},
+				},
+			}
+			if c.terminating {
+				now := metav1.Now()
+				pvc.DeletionTimestamp = &now
+			}
+
+			builder := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&pvc)
+			if c.configExists {
+				builder = builder.WithObjects(&discoblocksondatiov1.DiskConfig{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "config",
+						Namespace: "default",
+					},
+				})
+			}
+			kubeClient := builder.Build()
+
+			r := PVCReconciler{
+				Client: kubeClient,
+			}
+
+			_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}})
+			require.Nil(t, err, "unexpected error")
+
+			actualPVC := corev1.PersistentVolumeClaim{}
+			err = kubeClient.Get(context.Background(), types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}, &actualPVC)
+			if apierrors.IsNotFound(err) {
+				assert.False(t, c.expectedFinalizer, "PVC released")
+				return
+			}
+			require.Nil(t, err, "unable to fetch PVC")
+
+			assert.Equal(t, c.expectedFinalizer, controllerutil.ContainsFinalizer(&actualPVC, utils.RenderFinalizer("config")), "invalid finalizer")
+		})
+	}
+}
+
+// configGetRecorder counts fetches of DiskConfigs
+type configGetRecorder struct {
+	client.Client
+	gets int
+}
+
+func (c *configGetRecorder) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if _, ok := obj.(*discoblocksondatiov1.DiskConfig); ok {
+		c.gets++
+	}
+
+	return c.Client.Get(ctx, key, obj)
+}
+
+// failingConfigClient fails fetches of DiskConfigs while failing is set
+type failingConfigClient struct {
+	client.Client
+	failing bool
+}
+
+func (c *failingConfigClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if _, ok := obj.(*discoblocksondatiov1.DiskConfig); ok && c.failing {
+		return apierrors.NewServiceUnavailable("etcdserver: request timed out")
+	}
+
+	return c.Client.Get(ctx, key, obj)
+}
+
+func TestReconcileBacksOffConfigFetch(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	pvc := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "pvc",
+			Namespace:  "default",
+			Labels:     map[string]string{utils.ConfigLabel(): "config"},
+			Finalizers: []string{utils.RenderFinalizer("config")},
+		},
+	}
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+		},
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}}
+
+	t.Run("rate limiter", func(t *testing.T) {
+		kubeClient := failingConfigClient{
+			Client:  fake.NewClientBuilder().WithScheme(scheme).WithObjects(&pvc, &config).Build(),
+			failing: true,
+		}
+
+		r := PVCReconciler{
+			Client: &kubeClient,
+		}
+
+		result, err := r.Reconcile(context.Background(), req)
+		assert.True(t, apierrors.IsServiceUnavailable(err), "fetch error not wrapped")
+		assert.Zero(t, result.RequeueAfter, "requeued without backoff")
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		kubeClient := failingConfigClient{
+			Client:  fake.NewClientBuilder().WithScheme(scheme).WithObjects(&pvc, &config).Build(),
+			failing: true,
+		}
+
+		r := PVCReconciler{
+			Client:             &kubeClient,
+			ConfigFetchBackoff: time.Second,
+		}
+
+		recorder := logRecorder{messages: map[int][]string{}, values: map[string][]interface{}{}}
+		ctx := logf.IntoContext(context.Background(), logr.New(&recorder))
+
+		expected := []time.Duration{1, 2, 4, 8, 16, 32, 64, 64, 64, 64, 64, 64}
+		for i := range expected {
+			result, err := r.Reconcile(ctx, req)
+			require.Nil(t, err, "unexpected error")
+			assert.Equal(t, expected[i]*time.Second, result.RequeueAfter, "invalid backoff of failure %d", i+1)
+		}
+
+		failures := 0
+		for _, msg := range recorder.messages[0] {
+			if msg == "Unable to fetch DiskConfig" {
+				failures++
+			}
+		}
+		assert.Equal(t, 2, failures, "failures are not logged at reduced frequency")
+
+		kubeClient.failing = false
+
+		result, err := r.Reconcile(ctx, req)
+		require.Nil(t, err, "unexpected error")
+		assert.Zero(t, result.RequeueAfter, "requeued after recovery")
+
+		kubeClient.failing = true
+
+		result, err = r.Reconcile(ctx, req)
+		require.Nil(t, err, "unexpected error")
+		assert.Equal(t, time.Second, result.RequeueAfter, "backoff not reset after recovery")
+	})
+}
+
+func TestReconcileSkipsUnlabeledPVC(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	now := metav1.Now()
+	pvc := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "pvc",
+			Namespace:         "default",
+			Labels:            map[string]string{"app": "nginx"},
+			Finalizers:        []string{utils.RenderFinalizer("")},
+			DeletionTimestamp: &now,
+		},
+	}
+
+	kubeClient := &configGetRecorder{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(&pvc).Build()}
+
+	ef := pvcEventFilter{logger: logr.Discard()}
+	assert.False(t, ef.Create(event.CreateEvent{Object: &pvc}), "unlabeled PVC created")
+	assert.False(t, ef.Update(event.UpdateEvent{ObjectOld: &pvc, ObjectNew: &pvc}), "unlabeled PVC updated")
+
+	r := PVCReconciler{
+		Client: kubeClient,
+	}
+
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}})
+	require.Nil(t, err, "unexpected error")
+
+	assert.Zero(t, kubeClient.gets, "DiskConfig fetched")
+
+	actualPVC := corev1.PersistentVolumeClaim{}
+	require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}, &actualPVC), "unable to fetch PVC")
+	assert.True(t, controllerutil.ContainsFinalizer(&actualPVC, utils.RenderFinalizer("")), "unlabeled PVC released")
+}
+
+// patchRecorder records data of patches, PVCs read as unstructured objects have VolumeAttributesClass of className,
+// typed objects of fake client drop the field unknown by PVC type
+type patchRecorder struct {
+	client.Client
+	className string
+	patches   []string
+}
+
+func (c *patchRecorder) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if err := c.Client.Get(ctx, key, obj); err != nil {
+		return err
+	}
+
+	if u, ok := obj.(*unstructured.Unstructured); ok && c.className != "" {
+		return unstructured.SetNestedField(u.Object, c.className, "spec", "volumeAttributesClassName")
+	}
+
+	return nil
+}
+
+func (c *patchRecorder) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+
+	c.patches = append(c.patches, string(data))
+
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func TestReconcileVolumeAttributesClass(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	cases := map[string]struct {
+		className       string
+		currentClass    string
+		supported       bool
+		expectedPatches []string
+	}{
+		"no class": {
+			supported: true,
+		},
+		"not supported": {
+			className: "fast",
+		},
+		"supported": {
+			className:       "fast",
+			supported:       true,
+			expectedPatches: []string{`{"spec":{"volumeAttributesClassName":"fast"}}`},
+		},
+		"already set": {
+			className:    "fast",
+			currentClass: "fast",
+			supported:    true,
+		},
+		"changed": {
+			className:       "fast",
+			currentClass:    "slow",
+			supported:       true,
+			expectedPatches: []string{`{"spec":{"volumeAttributesClassName":"fast"}}`},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			pvc := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "pvc",
+					Namespace:  "default",
+					Labels:     map[string]string{utils.ConfigLabel(): "config"},
+					Finalizers: []string{utils.RenderFinalizer("config")},
+				},
+			}
+			config := discoblocksondatiov1.DiskConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "config",
+					Namespace: "default",
+				},
+				Spec: discoblocksondatiov1.DiskConfigSpec{
+					VolumeAttributesClassName: c.className,
+				},
+			}
+
+			mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Group: utils.VolumeAttributesClassGK.Group, Version: "v1beta1"}})
+			if c.supported {
+				mapper.Add(utils.VolumeAttributesClassGK.WithVersion("v1beta1"), meta.RESTScopeRoot)
+			}
+
+			kubeClient := &patchRecorder{
+				Client:    fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).WithObjects(&pvc, &config).Build(),
+				className: c.currentClass,
+			}
+
+			r := PVCReconciler{
+				Client: kubeClient,
+			}
+
+			_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}})
+			require.Nil(t, err, "unexpected error")
+
+			assert.Equal(t, c.expectedPatches, kubeClient.patches, "invalid patches")
+		})
+	}
+}
+
+func TestSyncStatuses(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	newPVC := func(name string, phase corev1.PersistentVolumeClaimPhase) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{utils.ConfigLabel(): "config"},
+			},
+			Status: corev1.PersistentVolumeClaimStatus{
+				Phase: phase,
+			},
+		}
+	}
+
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+		},
+		Status: discoblocksondatiov1.DiskConfigStatus{
+			Conditions: []metav1.Condition{
+				renderPVCCondition(newPVC("stale", corev1.ClaimBound)),
+				renderPVCCondition(newPVC("pending", corev1.ClaimPending)),
+				renderPVCCondition(newPVC("bound", corev1.ClaimBound)),
+				{Type: "Other", Reason: "Other", Message: "stale"},
+			},
+		},
+	}
+
+	foreign := newPVC("foreign", corev1.ClaimBound)
+	foreign.Labels[utils.ConfigLabel()] = "other"
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&config,
+		newPVC("pending", corev1.ClaimBound),
+		newPVC("bound", corev1.ClaimBound),
+		newPVC("missing", corev1.ClaimPending),
+		foreign,
+	).Build()
+
+	r := PVCReconciler{
+		Client: kubeClient,
+	}
+
+	r.SyncStatuses()
+
+	actual := discoblocksondatiov1.DiskConfig{}
+	require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Namespace: config.Namespace, Name: config.Name}, &actual), "unable to fetch DiskConfig")
+
+	phases := map[string]string{}
+	for _, c := range actual.Status.Conditions {
+		if c.Reason == pvcConditionReason {
+			phases[c.Message] = c.Type
+		}
+	}
+
+	assert.Equal(t, map[string]string{
+		"pending": string(corev1.ClaimBound),
+		"bound":   string(corev1.ClaimBound),
+		"missing": string(corev1.ClaimPending),
+	}, phases, "invalid PVC conditions")
+	assert.Contains(t, actual.Status.Conditions, config.Status.Conditions[3], "foreign condition dropped")
+
+	_, changed := syncPVCConditions(actual.Status.Conditions, []corev1.PersistentVolumeClaim{*newPVC("pending", corev1.ClaimBound), *newPVC("bound", corev1.ClaimBound), *newPVC("missing", corev1.ClaimPending)})
+	assert.False(t, changed, "synced status has changed")
+}
+
+func TestSyncRollout(t *testing.T) {
+	start := time.Now()
+
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "config",
+			Namespace:  "default",
+			Generation: 1,
+		},
+		Spec: discoblocksondatiov1.DiskConfigSpec{
+			Policy: discoblocksondatiov1.Policy{
+				ResizeRolloutPercentage:     10,
+				ResizeRolloutStepPercentage: 20,
+				ResizeRolloutWindow:         metav1.Duration{Duration: time.Hour},
+			},
+		},
+	}
+
+	assert.Equal(t, uint8(10), rolloutPercentage(&config, start.Add(2*time.Hour)), "rollout grown before start")
+
+	require.True(t, syncRollout(&config, start), "rollout not started")
+	assert.Equal(t, int64(1), config.Status.Rollout.Generation, "invalid generation of rollout")
+	assert.False(t, syncRollout(&config, start.Add(time.Hour)), "rollout restarted without change")
+
+	assert.Equal(t, uint8(10), rolloutPercentage(&config, start.Add(time.Minute)), "invalid percentage of first window")
+	assert.Equal(t, uint8(50), rolloutPercentage(&config, start.Add(2*time.Hour)), "invalid percentage of third window")
+
+	// Policy change restarts the rollout from the initial percentage
+	config.Generation = 2
+	assert.Equal(t, uint8(10), rolloutPercentage(&config, start.Add(2*time.Hour)), "stale rollout applied")
+
+	require.True(t, syncRollout(&config, start.Add(2*time.Hour)), "rollout not restarted")
+	assert.Equal(t, int64(2), config.Status.Rollout.Generation, "invalid generation of rollout")
+	assert.Equal(t, uint8(30), rolloutPercentage(&config, start.Add(3*time.Hour)), "invalid percentage of restarted rollout")
+
+	config.Spec.Policy.ResizeRolloutStepPercentage = 0
+	require.True(t, syncRollout(&config, start.Add(3*time.Hour)), "rollout not stopped")
+	assert.Nil(t, config.Status.Rollout, "rollout kept without step")
+	assert.Equal(t, uint8(10), rolloutPercentage(&config, start.Add(3*time.Hour)), "static percentage not applied")
+}
+
+func TestNextResizeTime(t *testing.T) {
+	t.Parallel()
+
+	last := metav1.NewTime(time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC))
+	coolDown := time.Minute
+
+	cases := map[string]struct {
+		status   discoblocksondatiov1.ResizeStatus
+		expected time.Time
+	}{
+		"never resized": {
+			expected: time.Time{},
+		},
+		"succeeded": {
+			status:   discoblocksondatiov1.ResizeStatus{Succeeded: true, LastAttemptTime: last},
+			expected: last.Add(coolDown),
+		},
+		"failed once": {
+			status:   discoblocksondatiov1.ResizeStatus{Attempts: 1, LastAttemptTime: last},
+			expected: last.Add(2 * coolDown),
+		},
+		"failed three times": {
+			status:   discoblocksondatiov1.ResizeStatus{Attempts: 3, LastAttemptTime: last},
+			expected: last.Add(8 * coolDown),
+		},
+		"failed many times": {
+			status:   discoblocksondatiov1.ResizeStatus{Attempts: 100, LastAttemptTime: last},
+			expected: last.Add(64 * coolDown),
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, c.expected, nextResizeTime(c.status, coolDown), "invalid next resize time")
+		})
+	}
+}
+
+func TestResizeAndReconcileConcurrently(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Group: utils.VolumeAttributesClassGK.Group, Version: "v1beta1"}})
+	mapper.Add(utils.VolumeAttributesClassGK.WithVersion("v1beta1"), meta.RESTScopeRoot)
+
+	key := types.NamespacedName{Namespace: "default", Name: "pvc"}
+
+	for i := 0; i < 20; i++ {
+		pvc := corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       key.Name,
+				Namespace:  key.Namespace,
+				Labels:     map[string]string{utils.ConfigLabel(): "config"},
+				Finalizers: []string{utils.RenderFinalizer("config")},
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+				},
+			},
+		}
+		config := discoblocksondatiov1.DiskConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "config",
+				Namespace: "default",
+			},
+			Spec: discoblocksondatiov1.DiskConfigSpec{
+				VolumeAttributesClassName: "fast",
+			},
+		}
+		pod := corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pod",
+				Namespace: "default",
+			},
+		}
+
+		kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).WithObjects(&pvc, &config, &pod).Build()
+
+		r := PVCReconciler{
+			Client:       kubeClient,
+			EventService: utils.NewEventService("controller", kubeClient),
+		}
+
+		// Monitor works on the copy listed at the beginning of the run, reconcile patches the PVC meanwhile
+		stalePVC := corev1.PersistentVolumeClaim{}
+		require.Nil(t, kubeClient.Get(context.Background(), key, &stalePVC), "unable to fetch PVC")
+
+		wg := sync.WaitGroup{}
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			r.resizePVC(&config, &pod, resource.MustParse("2Gi"), &stalePVC, "node", logr.Discard())
+		}()
+
+		var reconcileErr error
+		go func() {
+			defer wg.Done()
+
+			// Failed reconcile is requeued by the manager
+			for j := 0; j < 10; j++ {
+				var result ctrl.Result
+				if result, reconcileErr = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); reconcileErr == nil && !result.Requeue {
+					return
+				}
+			}
+		}()
+
+		wg.Wait()
+
+		require.Nil(t, reconcileErr, "reconcile failed")
+
+		actual := corev1.PersistentVolumeClaim{}
+		require.Nil(t, kubeClient.Get(context.Background(), key, &actual), "unable to fetch PVC")
+
+		capacity := actual.Spec.Resources.Requests[corev1.ResourceStorage]
+		assert.Equal(t, "2Gi", capacity.String(), "resize lost")
+		assert.True(t, controllerutil.ContainsFinalizer(&actual, utils.RenderFinalizer("config")), "finalizer lost")
+
+		actualConfig := discoblocksondatiov1.DiskConfig{}
+		require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Namespace: config.Namespace, Name: config.Name}, &actualConfig), "unable to fetch DiskConfig")
+		require.Len(t, actualConfig.Status.Conditions, 1, "reconcile lost")
+		assert.Equal(t, pvc.Name, actualConfig.Status.Conditions[0].Message, "invalid condition")
+		assert.True(t, actualConfig.Status.Resizes[pvc.Name].Succeeded, "resize not recorded")
+	}
+}
+
+func TestIsResizeAllowed(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	cases := map[string]struct {
+		pinned   bool
+		resize   *discoblocksondatiov1.ResizeStatus
+		expected bool
+	}{
+		"never resized": {
+			expected: true,
+		},
+		"pinned": {
+			pinned: true,
+		},
+		"cool down": {
+			resize: &discoblocksondatiov1.ResizeStatus{Succeeded: true, LastAttemptTime: metav1.NewTime(now.Add(-time.Minute))},
+		},
+		"cool down passed": {
+			resize:   &discoblocksondatiov1.ResizeStatus{Succeeded: true, LastAttemptTime: metav1.NewTime(now.Add(-time.Hour))},
+			expected: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			config := discoblocksondatiov1.DiskConfig{
+				Spec: discoblocksondatiov1.DiskConfigSpec{
+					Policy: discoblocksondatiov1.Policy{
+						CoolDown: metav1.Duration{Duration: 5 * time.Minute},
+					},
+				},
+			}
+			if c.resize != nil {
+				config.Status.Resizes = map[string]discoblocksondatiov1.ResizeStatus{"pvc": *c.resize}
+			}
+
+			pvc := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "pvc",
+				},
+			}
+			if c.pinned {
+				pvc.Annotations = map[string]string{utils.PinAnnotation(): "true"}
+			}
+
+			assert.Equal(t, c.expected, isResizeAllowed(&config, &pvc, now), "invalid decision")
+		})
+	}
+}
+
+func TestUpdatePVCCapacity(t *testing.T) {
+	pvc := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pvc",
+			Namespace: "default",
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("3Gi")},
+			},
+		},
+	}
+
+	kubeClient := fake.NewClientBuilder().WithObjects(&pvc).Build()
+
+	r := PVCReconciler{
+		Client: kubeClient,
+	}
+
+	updated, err := r.updatePVCCapacity(context.Background(), &pvc, resource.MustParse("2Gi"))
+	require.Nil(t, err, "unable to update PVC")
+	assert.False(t, updated, "PVC shrunk")
+
+	updated, err = r.updatePVCCapacity(context.Background(), &pvc, resource.MustParse("4Gi"))
+	require.Nil(t, err, "unable to update PVC")
+	assert.True(t, updated, "PVC not updated")
+
+	capacity := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	assert.Equal(t, "4Gi", capacity.String(), "invalid capacity")
+}
+
+func TestRecordResize(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+		},
+		Spec: discoblocksondatiov1.DiskConfigSpec{
+			Policy: discoblocksondatiov1.Policy{
+				CoolDown: metav1.Duration{Duration: time.Minute},
+			},
+		},
+	}
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&config).Build()
+
+	r := PVCReconciler{
+		Client: kubeClient,
+	}
+
+	fetch := func() discoblocksondatiov1.ResizeStatus {
+		actual := discoblocksondatiov1.DiskConfig{}
+		require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Namespace: config.Namespace, Name: config.Name}, &actual), "unable to fetch DiskConfig")
+
+		config.Status = actual.Status
+
+		return actual.Status.Resizes["pvc"]
+	}
+
+	r.recordResize(&config, "pvc", false, logr.Discard())
+	failed := fetch()
+	assert.Equal(t, uint32(1), failed.Attempts, "invalid attempts")
+
+	r.recordResize(&config, "pvc", false, logr.Discard())
+	failed = fetch()
+	assert.Equal(t, uint32(2), failed.Attempts, "invalid attempts")
+
+	r.recordResize(&config, "other", true, logr.Discard())
+	assert.Equal(t, uint32(2), fetch().Attempts, "resize status of other PVC changed")
+
+	r.recordResize(&config, "pvc", true, logr.Discard())
+	succeeded := fetch()
+	assert.True(t, succeeded.Succeeded, "invalid outcome")
+	assert.Zero(t, succeeded.Attempts, "attempts not reset")
+
+	assert.Greater(t,
+		int64(nextResizeTime(failed, config.Spec.Policy.CoolDown.Duration).Sub(failed.LastAttemptTime.Time)),
+		int64(nextResizeTime(succeeded, config.Spec.Policy.CoolDown.Duration).Sub(succeeded.LastAttemptTime.Time)),
+		"failed resize must back off more than successful one")
+}
+
+func TestMergeDiskUsages(t *testing.T) {
+	t.Parallel()
+
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+		},
+	}
+
+	newPVC := func(name, parent, index string) *corev1.PersistentVolumeClaim {
+		pvc := corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{},
+			},
+		}
+		pvc.Spec.VolumeName = "pv-" + name
+		if parent != "" {
+			pvc.Labels[utils.ParentLabel()] = parent
+			pvc.Labels[utils.IndexLabel()] = index
+		}
+
+		return &pvc
+	}
+
+	newPod := func(name, claimName string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: corev1.PodSpec{
+				Volumes: []corev1.Volume{
+					{
+						Name: "disk",
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	activePVCs := []*corev1.PersistentVolumeClaim{
+		newPVC("shared", "", ""),
+		newPVC("shared-1", "shared", "1"),
+		newPVC("own", "", ""),
+	}
+
+	podPVCFamilies := map[string]map[string][]*corev1.PersistentVolumeClaim{}
+	for _, pod := range []*corev1.Pod{newPod("pod-a", "shared"), newPod("pod-b", "shared"), newPod("pod-c", "own")} {
+		podPVCFamilies[pod.Name] = renderPodPVCFamilies(pod, activePVCs)
+	}
+
+	assert.Equal(t, podPVCFamilies["pod-a"], podPVCFamilies["pod-b"], "invalid shared family")
+	assert.Len(t, podPVCFamilies["pod-a"]["shared"], 2, "invalid number of shared disks")
+	assert.Len(t, podPVCFamilies["pod-c"]["own"], 1, "invalid number of own disks")
+
+	podDiskInfos := map[string]map[string]diskinfo.DiskUsage{
+		"pod-a": {
+			"/media/discoblocks/config-0": {Size: 1000, Used: 700, Available: 300},
+			"/media/discoblocks/config-1": {Size: 1000, Used: 100, Available: 900},
+		},
+		"pod-b": {
+			"/media/discoblocks/config-0": {Size: 1000, Used: 850, Available: 150},
+		},
+		"pod-c": {
+			"/media/discoblocks/config-0": {Size: 1000, Used: 200, Available: 800},
+		},
+	}
+
+	assert.Equal(t, map[string]diskinfo.DiskUsage{
+		"shared":   {Size: 1000, Used: 850, Available: 150},
+		"shared-1": {Size: 1000, Used: 100, Available: 900},
+		"own":      {Size: 1000, Used: 200, Available: 800},
+	}, mergeDiskUsages(&config, "", podPVCFamilies, podDiskInfos), "invalid merged usages")
+
+	hostDiskInfos := map[string]map[string]diskinfo.DiskUsage{
+		"pod-c": {
+			"/var/lib/kubelet/plugins/foo.csi.io/pv-own/staging":               {Size: 1000, Used: 200, Available: 800},
+			"/var/lib/kubelet/plugins/kubernetes.io/csi/pv/pv-own/globalmount": {Size: 1000, Used: 999, Available: 1},
+		},
+	}
+
+	assert.Equal(t, map[string]diskinfo.DiskUsage{
+		"own": {Size: 1000, Used: 200, Available: 800},
+	}, mergeDiskUsages(&config, "/var/lib/kubelet/plugins/foo.csi.io/${PV_NAME}/staging", podPVCFamilies, hostDiskInfos), "invalid host view usages")
+
+	kubeletConfig := config.DeepCopy()
+	kubeletConfig.Spec.MetricsSource = discoblocksondatiov1.MetricsSourceKubelet
+
+	kubeletDiskInfos := map[string]map[string]diskinfo.DiskUsage{
+		"pod-a": {
+			"shared":   {Size: 1000, Used: 850, Available: 150},
+			"shared-1": {Size: 1000, Used: 100, Available: 900},
+			"own":      {Size: 1000, Used: 200, Available: 800},
+			"other":    {Size: 1000, Used: 999, Available: 1},
+		},
+		"pod-c": {
+			"own": {Size: 1000, Used: 200, Available: 800},
+		},
+	}
+
+	assert.Equal(t, map[string]diskinfo.DiskUsage{
+		"shared":   {Size: 1000, Used: 850, Available: 150},
+		"shared-1": {Size: 1000, Used: 100, Available: 900},
+		"own":      {Size: 1000, Used: 200, Available: 800},
+	}, mergeDiskUsages(kubeletConfig, "", podPVCFamilies, kubeletDiskInfos), "invalid kubelet usages")
+}
+
+func TestIsReadOnly(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		pvcUsages        map[string]diskinfo.DiskUsage
+		expectedReadOnly bool
+		expectedEvents   int
+	}{
+		"read-write": {
+			pvcUsages: map[string]diskinfo.DiskUsage{
+				"first": {Size: 1000, Used: 900, Available: 100},
+				"last":  {Size: 1000, Used: 900, Available: 100},
+			},
+		},
+		"last read-only": {
+			pvcUsages: map[string]diskinfo.DiskUsage{
+				"first": {Size: 1000, Used: 900, Available: 100},
+				"last":  {Size: 1000, Used: 900, Available: 100, ReadOnly: true},
+			},
+			expectedReadOnly: true,
+			expectedEvents:   1,
+		},
+		"first read-only": {
+			pvcUsages: map[string]diskinfo.DiskUsage{
+				"first": {Size: 1000, Used: 900, Available: 100, ReadOnly: true},
+				"last":  {Size: 1000, Used: 900, Available: 100},
+			},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pod",
+					Namespace: n,
+					UID:       "pod",
+				},
+			}
+			first := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "first",
+					Namespace: n,
+					UID:       "first",
+				},
+			}
+			last := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "last",
+					Namespace: n,
+					UID:       "last",
+				},
+			}
+
+			kubeClient := fake.NewClientBuilder().Build()
+
+			r := PVCReconciler{
+				EventService: utils.NewEventService("controller", kubeClient),
+				Client:       kubeClient,
+			}
+
+			family := []*corev1.PersistentVolumeClaim{&first, &last}
+
+			// Second pass must not repeat the warning
+			for i := 0; i < 2; i++ {
+				readOnly := r.isReadOnly(&pod, family, c.pvcUsages, &last, "/media/discoblocks/foo-1", logr.Discard())
+				assert.Equal(t, c.expectedReadOnly, readOnly, "invalid read-only detection")
+			}
+
+			events := eventsv1.EventList{}
+			require.Nil(t, kubeClient.List(context.Background(), &events), "unable to list events")
+			require.Len(t, events.Items, c.expectedEvents, "invalid number of events")
+
+			if c.expectedReadOnly {
+				assert.Equal(t, "Warning", events.Items[0].Type, "invalid event type")
+			}
+		})
+	}
+}
+
+func TestIsStale(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		timestamp     time.Time
+		tolerance     time.Duration
+		jitter        time.Duration
+		expectedStale bool
+	}{
+		"disabled": {
+			timestamp: now.Add(-time.Hour),
+		},
+		"fresh": {
+			timestamp: now.Add(-time.Minute),
+			tolerance: 2 * time.Minute,
+		},
+		"stale": {
+			timestamp:     now.Add(-3 * time.Minute),
+			tolerance:     2 * time.Minute,
+			expectedStale: true,
+		},
+		"within jitter": {
+			timestamp: now.Add(-150 * time.Second),
+			tolerance: 2 * time.Minute,
+			jitter:    time.Minute,
+		},
+		"beyond jitter": {
+			timestamp:     now.Add(-4 * time.Minute),
+			tolerance:     2 * time.Minute,
+			jitter:        time.Minute,
+			expectedStale: true,
+		},
+		"unknown timestamp": {
+			tolerance: 2 * time.Minute,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			pvc := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pvc",
+					Namespace: n,
+				},
+			}
+
+			r := PVCReconciler{
+				MetricsStalenessTolerance: c.tolerance,
+				MetricsStalenessJitter:    c.jitter,
+			}
+
+			usage := diskinfo.DiskUsage{Size: 1000, Used: 900, Available: 100, Timestamp: c.timestamp}
+
+			assert.Equal(t, c.expectedStale, r.isStale(&pvc, usage, now, logr.Discard()), "invalid staleness")
+		})
+	}
+}
+
+func TestIsFileSystemBehind(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		parent         string
+		fsSize         float64
+		percentage     float64
+		expectedBehind bool
+	}{
+		"disabled": {
+			parent: "first",
+			fsSize: 1024 * 1024,
+		},
+		"first disk behind": {
+			fsSize:     1024 * 1024,
+			percentage: 10,
+		},
+		"additional disk behind": {
+			parent:         "first",
+			fsSize:         1024 * 1024,
+			percentage:     10,
+			expectedBehind: true,
+		},
+		"additional disk followed": {
+			parent:     "first",
+			fsSize:     2 * 1024 * 1024,
+			percentage: 10,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			pvc := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pvc",
+					Namespace: "default",
+					Labels:    map[string]string{},
+				},
+				Status: corev1.PersistentVolumeClaimStatus{
+					Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("2Gi")},
+				},
+			}
+			if c.parent != "" {
+				pvc.Labels[utils.ParentLabel()] = c.parent
+			}
+
+			usage := diskinfo.DiskUsage{Size: c.fsSize, Used: c.fsSize / 2, Available: c.fsSize / 2}
+
+			assert.Equal(t, c.expectedBehind, isFileSystemBehind(&pvc, usage, c.percentage), "invalid file-system state")
+		})
+	}
+}
+
+func TestIsInGracePeriod(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		pvcCreated    time.Time
+		podStarted    *metav1.Time
+		grace         time.Duration
+		expectedGrace bool
+	}{
+		"disabled": {
+			pvcCreated: now.Add(-time.Second),
+		},
+		"fresh PVC": {
+			pvcCreated:    now.Add(-time.Minute),
+			grace:         5 * time.Minute,
+			expectedGrace: true,
+		},
+		"old PVC": {
+			pvcCreated: now.Add(-10 * time.Minute),
+			grace:      5 * time.Minute,
+		},
+		"old PVC of fresh Pod": {
+			pvcCreated:    now.Add(-10 * time.Minute),
+			podStarted:    &metav1.Time{Time: now.Add(-time.Minute)},
+			grace:         5 * time.Minute,
+			expectedGrace: true,
+		},
+		"old Pod": {
+			pvcCreated: now.Add(-10 * time.Minute),
+			podStarted: &metav1.Time{Time: now.Add(-10 * time.Minute)},
+			grace:      5 * time.Minute,
+		},
+		"end of grace": {
+			pvcCreated: now.Add(-5 * time.Minute),
+			grace:      5 * time.Minute,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			pvc := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "pvc",
+					Namespace:         n,
+					CreationTimestamp: metav1.NewTime(c.pvcCreated),
+				},
+			}
+
+			pod := corev1.Pod{
+				Status: corev1.PodStatus{
+					StartTime: c.podStarted,
+				},
+			}
+
+			r := PVCReconciler{}
+
+			assert.Equal(t, c.expectedGrace, r.isInGracePeriod(&pod, &pvc, c.grace, now, logr.Discard()), "invalid grace period")
+		})
+	}
+}
+
+func TestIsExhaustionPredicted(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		horizon         time.Duration
+		sizes           []float64
+		availables      []float64
+		expectedTrigger bool
+	}{
+		"disabled": {
+			sizes:      []float64{1000, 1000, 1000},
+			availables: []float64{500, 450, 400},
+		},
+		"fast filling": {
+			horizon:         10 * time.Minute,
+			sizes:           []float64{1000, 1000, 1000},
+			availables:      []float64{500, 450, 400},
+			expectedTrigger: true,
+		},
+		"slow filling": {
+			horizon:    10 * time.Minute,
+			sizes:      []float64{1000, 1000, 1000},
+			availables: []float64{500, 499, 498},
+		},
+		"not filling": {
+			horizon:    10 * time.Minute,
+			sizes:      []float64{1000, 1000, 1000},
+			availables: []float64{400, 450, 500},
+		},
+		"resized": {
+			horizon:    10 * time.Minute,
+			sizes:      []float64{1000, 1000, 2000},
+			availables: []float64{500, 450, 1400},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			pvc := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pvc",
+					Namespace: "default",
+				},
+			}
+
+			r := PVCReconciler{
+				FillRatePredictor: utils.NewFillRatePredictor(utils.DefaultFillRateSamples),
+				PredictionHorizon: c.horizon,
+			}
+
+			triggered := false
+			for i := range c.availables {
+				usage := diskinfo.DiskUsage{
+					Size:      c.sizes[i],
+					Used:      c.sizes[i] - c.availables[i],
+					Available: c.availables[i],
+					Timestamp: now.Add(time.Duration(i) * time.Minute),
+				}
+
+				triggered = r.isExhaustionPredicted(&pvc, usage, r.PredictionHorizon, now, logr.Discard())
+
+				// Static threshold is not crossed by any sample, only prediction triggers resize
+				_, action := utils.DecideResize(usage.UsedPercentage("ext4"), 80, resource.MustParse("1Gi"), &discoblocksondatiov1.Policy{}, false)
+				assert.Equal(t, utils.ResizeActionNone, action, "static threshold crossed")
+			}
+
+			assert.Equal(t, c.expectedTrigger, triggered, "invalid prediction")
+		})
+	}
+}
+
+func TestGetPredictionHorizon(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		defaultHorizon  time.Duration
+		policyHorizon   time.Duration
+		expectedHorizon time.Duration
+	}{
+		"disabled": {},
+		"default": {
+			defaultHorizon:  10 * time.Minute,
+			expectedHorizon: 10 * time.Minute,
+		},
+		"policy overrides default": {
+			defaultHorizon:  10 * time.Minute,
+			policyHorizon:   30 * time.Minute,
+			expectedHorizon: 30 * time.Minute,
+		},
+		"policy only": {
+			policyHorizon:   30 * time.Minute,
+			expectedHorizon: 30 * time.Minute,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			r := PVCReconciler{
+				PredictionHorizon: c.defaultHorizon,
+			}
+
+			policy := discoblocksondatiov1.Policy{
+				PredictionHorizon: metav1.Duration{Duration: c.policyHorizon},
+			}
+
+			assert.Equal(t, c.expectedHorizon, r.getPredictionHorizon(&policy), "invalid horizon")
+		})
+	}
+}
+
+func TestSampleHistoryRestart(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	kubeClient := fake.NewClientBuilder().Build()
+
+	store, err := utils.NewSampleHistoryStore("history", "discoblocks", kubeClient, kubeClient)
+	require.Nil(t, err, "unable to create store")
+
+	pvc := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pvc",
+			Namespace: "default",
+		},
+	}
+
+	usage := func(i int) diskinfo.DiskUsage {
+		available := float64(500 - i*50)
+		return diskinfo.DiskUsage{Size: 1000, Used: 1000 - available, Available: available, Timestamp: now.Add(time.Duration(i) * time.Minute)}
+	}
+
+	leader := PVCReconciler{
+		FillRatePredictor:  utils.NewFillRatePredictor(utils.DefaultFillRateSamples),
+		PredictionHorizon:  10 * time.Minute,
+		SampleHistoryStore: store,
+	}
+	leader.restoreSampleHistory(context.Background(), logr.Discard())
+
+	for i := 0; i < 2; i++ {
+		assert.False(t, leader.isExhaustionPredicted(&pvc, usage(i), leader.PredictionHorizon, now, logr.Discard()), "predicted without enough samples")
+	}
+
+	leader.saveSampleHistory(now, logr.Discard())
+
+	// New leader continues with the samples of the old one
+	follower := PVCReconciler{
+		FillRatePredictor:  utils.NewFillRatePredictor(utils.DefaultFillRateSamples),
+		PredictionHorizon:  10 * time.Minute,
+		SampleHistoryStore: store,
+	}
+	follower.restoreSampleHistory(context.Background(), logr.Discard())
+
+	assert.True(t, follower.isExhaustionPredicted(&pvc, usage(2), follower.PredictionHorizon, now, logr.Discard()), "samples of history not restored")
+
+	// Saves are throttled, the next sample isn't saved within the period
+	follower.saveSampleHistory(now, logr.Discard())
+	follower.isExhaustionPredicted(&pvc, usage(3), follower.PredictionHorizon, now, logr.Discard())
+	follower.saveSampleHistory(now.Add(time.Minute), logr.Discard())
+
+	history, err := store.Load(context.Background())
+	require.Nil(t, err, "unable to load history")
+	assert.Len(t, history["default/pvc"], 3, "invalid number of saved samples")
+}
+
+// fakeRecommender returns the target after the delay, or the error
+type fakeRecommender struct {
+	target resource.Quantity
+	delay  time.Duration
+	err    error
+	calls  int32
+}
+
+func (fr *fakeRecommender) Recommend(ctx context.Context, _ *utils.RecommendationRequest) (resource.Quantity, error) {
+	atomic.AddInt32(&fr.calls, 1)
+
+	select {
+	case <-time.After(fr.delay):
+	case <-ctx.Done():
+		return resource.Quantity{}, ctx.Err()
+	}
+
+	return fr.target, fr.err
+}
+
+func TestRecommend(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		recommender      *fakeRecommender
+		expectedCapacity string
+		expectedAction   utils.ResizeAction
+		expectedError    bool
+	}{
+		"grow": {
+			recommender:      &fakeRecommender{target: resource.MustParse("25Gi")},
+			expectedCapacity: "25Gi",
+			expectedAction:   utils.ResizeActionGrow,
+		},
+		"no change": {
+			recommender:      &fakeRecommender{target: resource.MustParse("5Gi")},
+			expectedCapacity: "0",
+			expectedAction:   utils.ResizeActionNone,
+		},
+		"above max": {
+			recommender:      &fakeRecommender{target: resource.MustParse("200Gi")},
+			expectedCapacity: "0",
+			expectedAction:   utils.ResizeActionNewDisk,
+		},
+		"failure": {
+			recommender:   &fakeRecommender{err: errors.New("model is not loaded")},
+			expectedError: true,
+		},
+		"timeout": {
+			recommender:   &fakeRecommender{target: resource.MustParse("25Gi"), delay: time.Minute},
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			r := PVCReconciler{
+				CapacityRecommender:        c.recommender,
+				CapacityRecommenderTimeout: 50 * time.Millisecond,
+			}
+
+			target, err := r.recommend(context.Background(), &utils.RecommendationRequest{PVCName: "pvc", Namespace: n}, logr.Discard())
+			if c.expectedError {
+				assert.NotNil(t, err, "failure of recommender hidden")
+				return
+			}
+			require.Nil(t, err, "unexpected error")
+
+			policy := discoblocksondatiov1.Policy{MaximumCapacityOfDisk: resource.MustParse("100Gi")}
+
+			newCapacity, action := utils.DecideRecommendedResize(target, resource.MustParse("10Gi"), &policy)
+			assert.Equal(t, c.expectedAction, action, "invalid action")
+			assert.Equal(t, c.expectedCapacity, newCapacity.String(), "invalid new capacity")
+		})
+	}
+}
+
+func TestIsMountPatternValid(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		pattern        string
+		expectedValid  bool
+		expectedEvents int
+	}{
+		"default": {
+			expectedValid: true,
+		},
+		"valid": {
+			pattern:       "/media/bar-%d",
+			expectedValid: true,
+		},
+		"unrenderable": {
+			pattern:        "/media/bar-%s",
+			expectedEvents: 1,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			config := discoblocksondatiov1.DiskConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "config",
+					Namespace: n,
+					UID:       "config",
+				},
+				Spec: discoblocksondatiov1.DiskConfigSpec{
+					MountPointPattern: c.pattern,
+				},
+			}
+
+			kubeClient := fake.NewClientBuilder().Build()
+
+			r := PVCReconciler{
+				EventService: utils.NewEventService("controller", kubeClient),
+				Client:       kubeClient,
+			}
+
+			// Second pass must not repeat the warning
+			for i := 0; i < 2; i++ {
+				valid := r.isMountPatternValid(&config, logr.Discard())
+				assert.Equal(t, c.expectedValid, valid, "invalid validation")
+			}
+
+			events := eventsv1.EventList{}
+			require.Nil(t, kubeClient.List(context.Background(), &events), "unable to list events")
+			require.Len(t, events.Items, c.expectedEvents, "invalid number of events")
+
+			if !c.expectedValid {
+				assert.Equal(t, "Warning", events.Items[0].Type, "invalid event type")
+				assert.Equal(t, "config", events.Items[0].Regarding.Name, "invalid regarding object")
+			}
+		})
+	}
+}
+
+// logRecorder collects messages of all levels and values of the last message by text, derived loggers share the records
+type logRecorder struct {
+	lock     sync.Mutex
+	messages map[int][]string
+	values   map[string][]interface{}
+}
+
+func (l *logRecorder) Init(logr.RuntimeInfo) {}
+
+func (l *logRecorder) Enabled(int) bool {
+	return true
+}
+
+func (l *logRecorder) Info(level int, msg string, keysAndValues ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.messages[level] = append(l.messages[level], msg)
+	l.values[msg] = keysAndValues
+}
+
+func (l *logRecorder) Error(_ error, msg string, keysAndValues ...interface{}) {
+	l.Info(-1, msg, keysAndValues...)
+}
+
+// value returns the value of the key of the last message by text
+func (l *logRecorder) value(msg, key string) interface{} {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	values := l.values[msg]
+	for i := 0; i+1 < len(values); i += 2 {
+		if values[i] == key {
+			return values[i+1]
+		}
+	}
+
+	return nil
+}
+
+func (l *logRecorder) WithValues(...interface{}) logr.LogSink {
+	return l
+}
+
+func (l *logRecorder) WithName(string) logr.LogSink {
+	return l
+}
+
+func TestMonitorVolumesLogLevels(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "log-levels",
+			Namespace: "default",
+		},
+		Spec: discoblocksondatiov1.DiskConfigSpec{
+			StorageClassName:  "sc",
+			PodSelector:       map[string]string{"app": "nginx"},
+			MountPointPattern: "/media/discoblocks/foo-%d",
+		},
+	}
+	sc := storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sc",
+		},
+		Provisioner: "ebs.csi.aws.com",
+	}
+	pvc := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "pvc",
+			Namespace:  "default",
+			Labels:     map[string]string{utils.ConfigLabel(): config.Name},
+			Finalizers: []string{utils.RenderFinalizer(config.Name)},
+		},
+	}
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "nginx"},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+		},
+	}
+
+	r := PVCReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(&config, &sc, &pvc, &pod).Build(),
+	}
+
+	recorder := logRecorder{messages: map[int][]string{}, values: map[string][]interface{}{}}
+
+	r.monitorVolumes(logr.New(&recorder))
+
+	assert.Empty(t, recorder.messages[-1], "errors logged")
+	assert.Equal(t, []string{"Monitor done"}, recorder.messages[0], "routine decisions logged at Info level")
+	assert.Equal(t, int32(0), recorder.value("Monitor done", "pods_scraped"), "invalid pods scraped")
+	assert.Contains(t, recorder.messages[1], "Metrics sidecars are not ready", "routine decision not logged")
+	assert.Contains(t, recorder.messages[2], "Volume found", "volume not logged")
+}
+
+// staticNodeCache resolves Node names of Pods by a fixed map
+type staticNodeCache map[string]string
+
+func (c staticNodeCache) GetNodesByIP() map[string]string {
+	return c
+}
+
+func TestMonitorVolumesSummary(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "summary",
+			Namespace: "default",
+		},
+		Spec: discoblocksondatiov1.DiskConfigSpec{
+			StorageClassName: "sc",
+			Capacity:         resource.MustParse("1Gi"),
+			PodSelector:      map[string]string{"app": "nginx"},
+			MetricsSource:    discoblocksondatiov1.MetricsSourceKubelet,
+			Policy: discoblocksondatiov1.Policy{
+				UpscaleTriggerPercentage: intstr.FromInt(80),
+				ExtendCapacity:           resource.MustParse("1Gi"),
+				MaximumCapacityOfDisk:    resource.MustParse("10Gi"),
+			},
+		},
+	}
+	sc := storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sc",
+		},
+		Provisioner: "ebs.csi.aws.com",
+	}
+
+	objects := []client.Object{&config, &sc}
+	for _, name := range []string{"full", "ok", "broken"} {
+		nodeName := "node-a"
+		if name == "broken" {
+			nodeName = "node-b"
+		}
+
+		objects = append(objects,
+			&corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "pvc-" + name,
+					Namespace:  "default",
+					Labels:     map[string]string{utils.ConfigLabel(): config.Name},
+					Finalizers: []string{utils.RenderFinalizer(config.Name)},
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+					},
+				},
+			},
+			&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pod-" + name,
+					Namespace: "default",
+					Labels:    map[string]string{"app": "nginx"},
+				},
+				Spec: corev1.PodSpec{
+					NodeName: nodeName,
+					Volumes: []corev1.Volume{{
+						Name: "disk",
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "pvc-" + name},
+						},
+					}},
+				},
+				Status: corev1.PodStatus{
+					Phase:  corev1.PodRunning,
+					HostIP: "10.0.0.1",
+				},
+			},
+		)
+	}
+
+	const kubeletMetrics = `kubelet_volume_stats_capacity_bytes{namespace="default",persistentvolumeclaim="pvc-full"} 1073741824
+kubelet_volume_stats_used_bytes{namespace="default",persistentvolumeclaim="pvc-full"} 966367641
+kubelet_volume_stats_available_bytes{namespace="default",persistentvolumeclaim="pvc-full"} 107374183
+kubelet_volume_stats_capacity_bytes{namespace="default",persistentvolumeclaim="pvc-ok"} 1073741824
+kubelet_volume_stats_used_bytes{namespace="default",persistentvolumeclaim="pvc-ok"} 107374182
+kubelet_volume_stats_available_bytes{namespace="default",persistentvolumeclaim="pvc-ok"} 966367642
+`
+
+	kubeletClient := &restfake.RESTClient{
+		NegotiatedSerializer: clientgoscheme.Codecs.WithoutConversion(),
+		Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			if !strings.Contains(req.URL.Path, "/nodes/node-a/") {
+				return nil, errors.New("connection refused")
+			}
+
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(kubeletMetrics))}, nil
+		}),
+	}
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+	r := PVCReconciler{
+		Client:        kubeClient,
+		EventService:  utils.NewEventService("controller", kubeClient),
+		KubeletClient: kubeletClient,
+		NodeCache:     staticNodeCache{"10.0.0.1": "node-a"},
+	}
+
+	recorder := logRecorder{messages: map[int][]string{}, values: map[string][]interface{}{}}
+
+	r.monitorVolumes(logr.New(&recorder))
+
+	assert.Equal(t, int32(2), recorder.value("Monitor done", "pods_scraped"), "invalid pods scraped")
+	assert.Equal(t, int32(2), recorder.value("Monitor done", "metrics_found"), "invalid metrics found")
+	assert.Equal(t, int32(1), recorder.value("Monitor done", "resizes"), "invalid resizes")
+	assert.Equal(t, int32(0), recorder.value("Monitor done", "new_disks"), "invalid new disks")
+	assert.Equal(t, int32(1), recorder.value("Monitor done", "errors"), "invalid errors")
+	assert.NotEmpty(t, recorder.value("Monitor done", "duration"), "duration missing")
+
+	// Resize runs in the background
+	assert.Eventually(t, func() bool {
+		pvc := corev1.PersistentVolumeClaim{}
+		if err := kubeClient.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "pvc-full"}, &pvc); err != nil {
+			return false
+		}
+
+		capacity := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+
+		return capacity.String() == "2Gi"
+	}, 5*time.Second, 10*time.Millisecond, "PVC not resized")
+}
+
+func TestMonitorVolumesIgnoresInvalidDownscaleTrigger(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	downscaleTrigger := intstr.FromString("high")
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "downscale",
+			Namespace: "default",
+		},
+		Spec: discoblocksondatiov1.DiskConfigSpec{
+			StorageClassName: "sc",
+			Capacity:         resource.MustParse("1Gi"),
+			PodSelector:      map[string]string{"app": "nginx"},
+			MetricsSource:    discoblocksondatiov1.MetricsSourceKubelet,
+			Policy: discoblocksondatiov1.Policy{
+				UpscaleTriggerPercentage:   intstr.FromInt(80),
+				DownscaleTriggerPercentage: &downscaleTrigger,
+				ExtendCapacity:             resource.MustParse("1Gi"),
+				MaximumCapacityOfDisk:      resource.MustParse("10Gi"),
+			},
+		},
+	}
+	sc := storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sc",
+		},
+		Provisioner: "ebs.csi.aws.com",
+	}
+	pvc := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "pvc-full",
+			Namespace:  "default",
+			Labels:     map[string]string{utils.ConfigLabel(): config.Name},
+			Finalizers: []string{utils.RenderFinalizer(config.Name)},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		},
+	}
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod-full",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "nginx"},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node-a",
+			Volumes: []corev1.Volume{{
+				Name: "disk",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvc.Name},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{
+			Phase:  corev1.PodRunning,
+			HostIP: "10.0.0.1",
+		},
+	}
+
+	const kubeletMetrics = `kubelet_volume_stats_capacity_bytes{namespace="default",persistentvolumeclaim="pvc-full"} 1073741824
+kubelet_volume_stats_used_bytes{namespace="default",persistentvolumeclaim="pvc-full"} 966367641
+kubelet_volume_stats_available_bytes{namespace="default",persistentvolumeclaim="pvc-full"} 107374183
+`
+
+	kubeletClient := &restfake.RESTClient{
+		NegotiatedSerializer: clientgoscheme.Codecs.WithoutConversion(),
+		Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(kubeletMetrics))}, nil
+		}),
+	}
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&config, &sc, &pvc, &pod).Build()
+
+	r := PVCReconciler{
+		Client:        kubeClient,
+		EventService:  utils.NewEventService("controller", kubeClient),
+		KubeletClient: kubeletClient,
+		NodeCache:     staticNodeCache{"10.0.0.1": "node-a"},
+	}
+
+	recorder := logRecorder{messages: map[int][]string{}, values: map[string][]interface{}{}}
+
+	r.monitorVolumes(logr.New(&recorder))
+
+	assert.Equal(t, int32(0), recorder.value("Monitor done", "errors"), "invalid errors")
+	assert.Equal(t, int32(1), recorder.value("Monitor done", "resizes"), "upscale skipped by downscale trigger")
+
+	assert.Eventually(t, func() bool {
+		actualPVC := corev1.PersistentVolumeClaim{}
+		if err := kubeClient.Get(context.Background(), types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}, &actualPVC); err != nil {
+			return false
+		}
+
+		capacity := actualPVC.Spec.Resources.Requests[corev1.ResourceStorage]
+
+		return capacity.String() == "2Gi"
+	}, 5*time.Second, 10*time.Millisecond, "PVC not resized")
+}
+
+func TestMonitorVolumesSkipsPinnedPVC(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pinned",
+			Namespace: "default",
+		},
+		Spec: discoblocksondatiov1.DiskConfigSpec{
+			StorageClassName: "sc",
+			Capacity:         resource.MustParse("1Gi"),
+			PodSelector:      map[string]string{"app": "nginx"},
+			MetricsSource:    discoblocksondatiov1.MetricsSourceKubelet,
+			Policy: discoblocksondatiov1.Policy{
+				UpscaleTriggerPercentage: intstr.FromInt(80),
+				ExtendCapacity:           resource.MustParse("1Gi"),
+				MaximumCapacityOfDisk:    resource.MustParse("10Gi"),
+			},
+		},
+	}
+	sc := storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sc",
+		},
+		Provisioner: "ebs.csi.aws.com",
+	}
+	pvc := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pvc-full",
+			Namespace:   "default",
+			Labels:      map[string]string{utils.ConfigLabel(): config.Name},
+			Annotations: map[string]string{utils.PinAnnotation(): "true"},
+			Finalizers:  []string{utils.RenderFinalizer(config.Name)},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		},
+	}
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod-full",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "nginx"},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node-a",
+			Volumes: []corev1.Volume{{
+				Name: "disk",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvc.Name},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{
+			Phase:  corev1.PodRunning,
+			HostIP: "10.0.0.1",
+		},
+	}
+
+	// Used 99% is far past the upscale trigger
+	const kubeletMetrics = `kubelet_volume_stats_capacity_bytes{namespace="default",persistentvolumeclaim="pvc-full"} 1073741824
+kubelet_volume_stats_used_bytes{namespace="default",persistentvolumeclaim="pvc-full"} 1063004405
+kubelet_volume_stats_available_bytes{namespace="default",persistentvolumeclaim="pvc-full"} 10737419
+`
+
+	kubeletClient := &restfake.RESTClient{
+		NegotiatedSerializer: clientgoscheme.Codecs.WithoutConversion(),
+		Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(kubeletMetrics))}, nil
+		}),
+	}
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&config, &sc, &pvc, &pod).Build()
+
+	recommender := fakeRecommender{target: resource.MustParse("5Gi")}
+
+	r := PVCReconciler{
+		Client:                     kubeClient,
+		EventService:               utils.NewEventService("controller", kubeClient),
+		KubeletClient:              kubeletClient,
+		NodeCache:                  staticNodeCache{"10.0.0.1": "node-a"},
+		CapacityRecommender:        &recommender,
+		CapacityRecommenderTimeout: time.Second,
+	}
+
+	recorder := logRecorder{messages: map[int][]string{}, values: map[string][]interface{}{}}
+
+	r.monitorVolumes(logr.New(&recorder))
+
+	assert.Equal(t, int32(1), recorder.value("Monitor done", "metrics_found"), "invalid metrics found")
+	assert.Equal(t, int32(0), recorder.value("Monitor done", "resizes"), "pinned PVC resized")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&recommender.calls), "recommender asked for pinned PVC")
+	assert.Equal(t, int32(0), recorder.value("Monitor done", "new_disks"), "new disk created")
+	assert.Contains(t, recorder.messages[0], "PVC is pinned, resize skipped", "pin not logged")
+
+	actual := corev1.PersistentVolumeClaim{}
+	require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: pvc.Name}, &actual), "unable to fetch PVC")
+
+	capacity := actual.Spec.Resources.Requests[corev1.ResourceStorage]
+	assert.Equal(t, "1Gi", capacity.String(), "pinned PVC resized")
+}
+
+func TestMonitorVolumesKeepsNewDiskRequest(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	cases := map[string]struct {
+		planOnly           bool
+		maxDisks           uint8
+		expectedAnnotation string
+	}{
+		"plan only keeps request": {
+			planOnly:           true,
+			maxDisks:           2,
+			expectedAnnotation: "request",
+		},
+		"maximum number of disks rejects request": {
+			maxDisks: 1,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			config := discoblocksondatiov1.DiskConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "request",
+					Namespace: "default",
+				},
+				Spec: discoblocksondatiov1.DiskConfigSpec{
+					StorageClassName: "sc",
+					Capacity:         resource.MustParse("1Gi"),
+					PodSelector:      map[string]string{"app": "nginx"},
+					MetricsSource:    discoblocksondatiov1.MetricsSourceKubelet,
+					Policy: discoblocksondatiov1.Policy{
+						UpscaleTriggerPercentage: intstr.FromInt(80),
+						ExtendCapacity:           resource.MustParse("1Gi"),
+						MaximumCapacityOfDisk:    resource.MustParse("10Gi"),
+						MaximumNumberOfDisks:     c.maxDisks,
+					},
+				},
+			}
+			sc := storagev1.StorageClass{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "sc",
+				},
+				Provisioner: "ebs.csi.aws.com",
+			}
+			pvc := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "pvc-request",
+					Namespace:  "default",
+					Labels:     map[string]string{utils.ConfigLabel(): config.Name},
+					Finalizers: []string{utils.RenderFinalizer(config.Name)},
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+					},
+				},
+			}
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "pod-request",
+					Namespace:   "default",
+					Labels:      map[string]string{"app": "nginx"},
+					Annotations: map[string]string{utils.AddDiskAnnotation(): config.Name},
+				},
+				Spec: corev1.PodSpec{
+					NodeName: "node-a",
+					Volumes: []corev1.Volume{{
+						Name: "disk",
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvc.Name},
+						},
+					}},
+				},
+				Status: corev1.PodStatus{
+					Phase:  corev1.PodRunning,
+					HostIP: "10.0.0.1",
+				},
+			}
+
+			// Used 10% is far below the upscale trigger, only the request asks for a new disk
+			const kubeletMetrics = `kubelet_volume_stats_capacity_bytes{namespace="default",persistentvolumeclaim="pvc-request"} 1073741824
+kubelet_volume_stats_used_bytes{namespace="default",persistentvolumeclaim="pvc-request"} 107374182
+kubelet_volume_stats_available_bytes{namespace="default",persistentvolumeclaim="pvc-request"} 966367642
+`
+
+			kubeletClient := &restfake.RESTClient{
+				NegotiatedSerializer: clientgoscheme.Codecs.WithoutConversion(),
+				Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+					return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(kubeletMetrics))}, nil
+				}),
+			}
+
+			kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&config, &sc, &pvc, &pod).Build()
+
+			r := PVCReconciler{
+				Client:        kubeClient,
+				EventService:  utils.NewEventService("controller", kubeClient),
+				KubeletClient: kubeletClient,
+				NodeCache:     staticNodeCache{"10.0.0.1": "node-a"},
+				PlanOnly:      c.planOnly,
+			}
+
+			recorder := logRecorder{messages: map[int][]string{}, values: map[string][]interface{}{}}
+
+			r.monitorVolumes(logr.New(&recorder))
+
+			assert.Equal(t, int32(0), recorder.value("Monitor done", "new_disks"), "new disk created")
+			assert.Equal(t, int32(0), recorder.value("Monitor done", "errors"), "invalid errors")
+
+			actualPod := corev1.Pod{}
+			require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, &actualPod), "unable to fetch Pod")
+
+			assert.Equal(t, c.expectedAnnotation, actualPod.Annotations[utils.AddDiskAnnotation()], "invalid new disk request")
+		})
+	}
+}
+
+func TestMonitorVolumesSkipsStaleMetrics(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stale",
+			Namespace: "default",
+		},
+		Spec: discoblocksondatiov1.DiskConfigSpec{
+			StorageClassName: "sc",
+			Capacity:         resource.MustParse("1Gi"),
+			PodSelector:      map[string]string{"app": "nginx"},
+			MetricsSource:    discoblocksondatiov1.MetricsSourceKubelet,
+			Policy: discoblocksondatiov1.Policy{
+				UpscaleTriggerPercentage: intstr.FromInt(80),
+				ExtendCapacity:           resource.MustParse("1Gi"),
+				MaximumCapacityOfDisk:    resource.MustParse("10Gi"),
+			},
+		},
+	}
+	sc := storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sc",
+		},
+		Provisioner: "ebs.csi.aws.com",
+	}
+	pvc := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "pvc-full",
+			Namespace:  "default",
+			Labels:     map[string]string{utils.ConfigLabel(): config.Name},
+			Finalizers: []string{utils.RenderFinalizer(config.Name)},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		},
+	}
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod-full",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "nginx"},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node-a",
+			Volumes: []corev1.Volume{{
+				Name: "disk",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvc.Name},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{
+			Phase:  corev1.PodRunning,
+			HostIP: "10.0.0.1",
+		},
+	}
+
+	// Used 99% is far past the upscale trigger, but exporter measured it an hour ago
+	measured := time.Now().Add(-time.Hour).UnixMilli()
+	kubeletMetrics := fmt.Sprintf(`kubelet_volume_stats_capacity_bytes{namespace="default",persistentvolumeclaim="pvc-full"} 1073741824 %[1]d
+kubelet_volume_stats_used_bytes{namespace="default",persistentvolumeclaim="pvc-full"} 1063004405 %[1]d
+kubelet_volume_stats_available_bytes{namespace="default",persistentvolumeclaim="pvc-full"} 10737419 %[1]d
+`, measured)
+
+	kubeletClient := &restfake.RESTClient{
+		NegotiatedSerializer: clientgoscheme.Codecs.WithoutConversion(),
+		Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(kubeletMetrics))}, nil
+		}),
+	}
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&config, &sc, &pvc, &pod).Build()
+
+	r := PVCReconciler{
+		Client:                    kubeClient,
+		EventService:              utils.NewEventService("controller", kubeClient),
+		KubeletClient:             kubeletClient,
+		NodeCache:                 staticNodeCache{"10.0.0.1": "node-a"},
+		MetricsStalenessTolerance: time.Minute,
+	}
+
+	recorder := logRecorder{messages: map[int][]string{}, values: map[string][]interface{}{}}
+
+	r.monitorVolumes(logr.New(&recorder))
+
+	assert.Equal(t, int32(1), recorder.value("Monitor done", "metrics_found"), "invalid metrics found")
+	assert.Equal(t, int32(0), recorder.value("Monitor done", "resizes"), "PVC resized on stale metrics")
+	assert.Equal(t, int32(0), recorder.value("Monitor done", "new_disks"), "new disk created on stale metrics")
+
+	actual := corev1.PersistentVolumeClaim{}
+	require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: pvc.Name}, &actual), "unable to fetch PVC")
+
+	capacity := actual.Spec.Resources.Requests[corev1.ResourceStorage]
+	assert.Equal(t, "1Gi", capacity.String(), "PVC resized on stale metrics")
+}
+
+func TestMonitorVolumesScalesDisksIndependently(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	walTrigger := intstr.FromInt(95)
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "disks",
+			Namespace: "default",
+		},
+		Spec: discoblocksondatiov1.DiskConfigSpec{
+			StorageClassName: "sc",
+			Capacity:         resource.MustParse("1Gi"),
+			PodSelector:      map[string]string{"app": "nginx"},
+			MetricsSource:    discoblocksondatiov1.MetricsSourceKubelet,
+			Disks: []discoblocksondatiov1.DiskSpec{
+				{Index: 0},
+				{Index: 1, Policy: &discoblocksondatiov1.DiskPolicy{UpscaleTriggerPercentage: &walTrigger}},
+			},
+			Policy: discoblocksondatiov1.Policy{
+				UpscaleTriggerPercentage: intstr.FromInt(80),
+				ExtendCapacity:           resource.MustParse("1Gi"),
+				MaximumCapacityOfDisk:    resource.MustParse("10Gi"),
+				MaximumNumberOfDisks:     2,
+				InitialNumberOfDisks:     1,
+			},
+		},
+	}
+	sc := storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sc",
+		},
+		Provisioner: "ebs.csi.aws.com",
+	}
+	newPVC := func(name string, labels map[string]string) *corev1.PersistentVolumeClaim {
+		labels[utils.ConfigLabel()] = config.Name
+
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       name,
+				Namespace:  "default",
+				Labels:     labels,
+				Finalizers: []string{utils.RenderFinalizer(config.Name)},
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+				},
+			},
+		}
+	}
+	dataPVC := newPVC("pvc-data", map[string]string{})
+	walPVC := newPVC("pvc-wal", map[string]string{utils.ParentLabel(): dataPVC.Name, utils.IndexLabel(): "1"})
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod-disks",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "nginx"},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node-a",
+			Volumes: []corev1.Volume{{
+				Name: "disk",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: dataPVC.Name},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{
+			Phase:  corev1.PodRunning,
+			HostIP: "10.0.0.1",
+		},
+	}
+
+	// Both disks are used 90%, only the trigger of the first disk is reached
+	kubeletMetrics := ""
+	for _, name := range []string{dataPVC.Name, walPVC.Name} {
+		kubeletMetrics += fmt.Sprintf(`kubelet_volume_stats_capacity_bytes{namespace="default",persistentvolumeclaim="%[1]s"} 1073741824
+kubelet_volume_stats_used_bytes{namespace="default",persistentvolumeclaim="%[1]s"} 966367641
+kubelet_volume_stats_available_bytes{namespace="default",persistentvolumeclaim="%[1]s"} 107374183
+`, name)
+	}
+
+	kubeletClient := &restfake.RESTClient{
+		NegotiatedSerializer: clientgoscheme.Codecs.WithoutConversion(),
+		Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(kubeletMetrics))}, nil
+		}),
+	}
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&config, &sc, dataPVC, walPVC, &pod).Build()
+
+	r := PVCReconciler{
+		Client:        kubeClient,
+		EventService:  utils.NewEventService("controller", kubeClient),
+		KubeletClient: kubeletClient,
+		NodeCache:     staticNodeCache{"10.0.0.1": "node-a"},
+	}
+
+	recorder := logRecorder{messages: map[int][]string{}, values: map[string][]interface{}{}}
+
+	r.monitorVolumes(logr.New(&recorder))
+
+	assert.Equal(t, int32(2), recorder.value("Monitor done", "metrics_found"), "invalid metrics found")
+	assert.Equal(t, int32(1), recorder.value("Monitor done", "resizes"), "invalid resizes")
+	assert.Equal(t, int32(0), recorder.value("Monitor done", "new_disks"), "new disk created")
+
+	capacityOf := func(name string) string {
+		pvc := corev1.PersistentVolumeClaim{}
+		if err := kubeClient.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: name}, &pvc); err != nil {
+			return ""
+		}
+
+		capacity := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+
+		return capacity.String()
+	}
+
+	// Resize runs in the background
+	assert.Eventually(t, func() bool {
+		return capacityOf(dataPVC.Name) == "2Gi"
+	}, 5*time.Second, 10*time.Millisecond, "first disk not resized")
+	assert.Equal(t, "1Gi", capacityOf(walPVC.Name), "second disk resized by policy of the first disk")
+}
+
+func TestRetryFailedScrapes(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "retry",
+			Namespace: "default",
+		},
+		Spec: discoblocksondatiov1.DiskConfigSpec{
+			StorageClassName: "sc",
+			Capacity:         resource.MustParse("1Gi"),
+			PodSelector:      map[string]string{"app": "nginx"},
+			MetricsSource:    discoblocksondatiov1.MetricsSourceKubelet,
+			Policy: discoblocksondatiov1.Policy{
+				UpscaleTriggerPercentage: intstr.FromInt(80),
+				ExtendCapacity:           resource.MustParse("1Gi"),
+				MaximumCapacityOfDisk:    resource.MustParse("10Gi"),
+			},
+		},
+	}
+	sc := storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sc",
+		},
+		Provisioner: "ebs.csi.aws.com",
+	}
+
+	objects := []client.Object{&config, &sc}
+	for name, nodeName := range map[string]string{"ok": "node-a", "flaky": "node-b"} {
+		objects = append(objects,
+			&corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "pvc-" + name,
+					Namespace:  "default",
+					Labels:     map[string]string{utils.ConfigLabel(): config.Name},
+					Finalizers: []string{utils.RenderFinalizer(config.Name)},
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+					},
+				},
+			},
+			&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pod-" + name,
+					Namespace: "default",
+					Labels:    map[string]string{"app": "nginx"},
+				},
+				Spec: corev1.PodSpec{
+					NodeName: nodeName,
+					Volumes: []corev1.Volume{{
+						Name: "disk",
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "pvc-" + name},
+						},
+					}},
+				},
+				Status: corev1.PodStatus{
+					Phase:  corev1.PodRunning,
+					HostIP: "10.0.0.1",
+				},
+			},
+		)
+	}
+
+	const kubeletMetrics = `kubelet_volume_stats_capacity_bytes{namespace="default",persistentvolumeclaim="pvc-ok"} 1073741824
+kubelet_volume_stats_used_bytes{namespace="default",persistentvolumeclaim="pvc-ok"} 107374182
+kubelet_volume_stats_available_bytes{namespace="default",persistentvolumeclaim="pvc-ok"} 966367642
+kubelet_volume_stats_capacity_bytes{namespace="default",persistentvolumeclaim="pvc-flaky"} 1073741824
+kubelet_volume_stats_used_bytes{namespace="default",persistentvolumeclaim="pvc-flaky"} 107374182
+kubelet_volume_stats_available_bytes{namespace="default",persistentvolumeclaim="pvc-flaky"} 966367642
+`
+
+	// node-b recovers after the first pass
+	var nodeBDown int32 = 1
+	scrapes := map[string]int{}
+	scrapesLock := sync.Mutex{}
+
+	kubeletClient := &restfake.RESTClient{
+		NegotiatedSerializer: clientgoscheme.Codecs.WithoutConversion(),
+		Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			scrapesLock.Lock()
+			for _, nodeName := range []string{"node-a", "node-b"} {
+				if strings.Contains(req.URL.Path, "/nodes/"+nodeName+"/") {
+					scrapes[nodeName]++
+				}
+			}
+			scrapesLock.Unlock()
+
+			if strings.Contains(req.URL.Path, "/nodes/node-b/") && atomic.LoadInt32(&nodeBDown) == 1 {
+				scrapesLock.Lock()
+				defer scrapesLock.Unlock()
+
+				// Messages differ per attempt, so events are not deduplicated by content
+				return nil, fmt.Errorf("connection refused: attempt %d", scrapes["node-b"])
+			}
+
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(kubeletMetrics))}, nil
+		}),
+	}
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+	r := PVCReconciler{
+		Client:            kubeClient,
+		EventService:      utils.NewEventService("controller", kubeClient),
+		KubeletClient:     kubeletClient,
+		NodeCache:         staticNodeCache{"10.0.0.1": "node-a"},
+		ScrapeRetryPeriod: 10 * time.Millisecond,
+	}
+
+	recorder := logRecorder{messages: map[int][]string{}, values: map[string][]interface{}{}}
+
+	r.monitorVolumes(logr.New(&recorder))
+
+	assert.Equal(t, int32(1), recorder.value("Monitor done", "pods_scraped"), "invalid pods scraped")
+	assert.Equal(t, int32(1), recorder.value("Monitor done", "errors"), "invalid errors")
+	assert.Equal(t, map[string]map[string]bool{"default/retry": {"pod-flaky": true}}, r.failedScrapes, "invalid failed scrapes")
+
+	retry := r.scheduleScrapeRetry()
+	require.NotNil(t, retry, "retry not scheduled")
+
+	select {
+	case <-retry:
+	case <-time.After(monitoringPeriod):
+		t.Fatal("retry is not sooner than monitoring period")
+	}
+
+	fetchFailedEvents := func() int {
+		events := eventsv1.EventList{}
+		require.Nil(t, kubeClient.List(context.Background(), &events), "unable to list events")
+
+		count := 0
+		for i := range events.Items {
+			if events.Items[i].Reason == "Failed to fetch disk info" {
+				count++
+			}
+		}
+
+		return count
+	}
+	assert.Equal(t, 1, fetchFailedEvents(), "failure of full pass not reported")
+
+	// Node is still down on the first retry, failure is not reported again
+	recorder = logRecorder{messages: map[int][]string{}, values: map[string][]interface{}{}}
+
+	r.retryFailedScrapes(logr.New(&recorder))
+
+	assert.Equal(t, int32(1), recorder.value("Monitor done", "errors"), "invalid errors on failed retry")
+	assert.Equal(t, map[string]map[string]bool{"default/retry": {"pod-flaky": true}}, r.failedScrapes, "failed Pod dropped from retries")
+	assert.Equal(t, 1, fetchFailedEvents(), "failure reported by retry")
+
+	atomic.StoreInt32(&nodeBDown, 0)
+
+	recorder = logRecorder{messages: map[int][]string{}, values: map[string][]interface{}{}}
+
+	r.retryFailedScrapes(logr.New(&recorder))
+
+	assert.Equal(t, int32(1), recorder.value("Monitor done", "pods_scraped"), "invalid pods scraped on retry")
+	assert.Equal(t, int32(1), recorder.value("Monitor done", "metrics_found"), "invalid metrics found on retry")
+	assert.Equal(t, int32(0), recorder.value("Monitor done", "errors"), "invalid errors on retry")
+	assert.Empty(t, r.failedScrapes, "failed scrapes not cleared")
+	assert.Nil(t, r.scheduleScrapeRetry(), "retry scheduled without failures")
+
+	scrapesLock.Lock()
+	assert.Equal(t, 1, scrapes["node-a"], "healthy Pod retried")
+	assert.Equal(t, 3, scrapes["node-b"], "failed Pod not retried")
+	scrapesLock.Unlock()
+
+	recorder = logRecorder{messages: map[int][]string{}, values: map[string][]interface{}{}}
+
+	r.retryFailedScrapes(logr.New(&recorder))
+
+	assert.Empty(t, recorder.values, "retry without failures monitored volumes")
+}
+
+func TestScheduleScrapeRetry(t *testing.T) {
+	failed := map[string]map[string]bool{"default/config": {"pod": true}}
+
+	cases := map[string]struct {
+		period        time.Duration
+		failed        map[string]map[string]bool
+		expectedRetry bool
+	}{
+		"disabled": {
+			failed: failed,
+		},
+		"no failures": {
+			period: time.Second,
+		},
+		"not sooner than monitoring": {
+			period: monitoringPeriod,
+			failed: failed,
+		},
+		"failures": {
+			period:        time.Second,
+			failed:        failed,
+			expectedRetry: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			r := PVCReconciler{
+				ScrapeRetryPeriod: c.period,
+				failedScrapes:     c.failed,
+			}
+
+			assert.Equal(t, c.expectedRetry, r.scheduleScrapeRetry() != nil, "invalid retry")
+		})
+	}
+}
@@ -17,19 +17,34 @@ limitations under the License.
 package controllers
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"k8s.io/client-go/kubernetes/scheme"
+	restfake "k8s.io/client-go/rest/fake"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"github.com/ondat/discoblocks/mutators"
+	"github.com/ondat/discoblocks/pkg/utils"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -40,8 +55,39 @@ import (
 var (
 	k8sClient client.Client
 	testEnv   *envtest.Environment
+	ctx       context.Context
+	cancel    context.CancelFunc
+
+	// pvcReconciler runs volume monitor cycles on demand of the specs
+	pvcReconciler *PVCReconciler
+	closeMonitor  chan<- bool
+	certsDir      string
+	// kubeletMetrics is the mock metrics endpoint of kubelet, specs set volume stats on it
+	kubeletMetrics = mockKubeletMetrics{}
 )
 
+// mockKubeletMetrics serves the configured kubelet metrics to volume monitor
+type mockKubeletMetrics struct {
+	lock    sync.Mutex
+	content string
+}
+
+// Set replaces served metrics
+func (m *mockKubeletMetrics) Set(content string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.content = content
+}
+
+// Get returns served metrics
+func (m *mockKubeletMetrics) Get() string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.content
+}
+
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
 
@@ -53,10 +99,15 @@ func TestAPIs(t *testing.T) {
 var _ = BeforeSuite(func() {
 	logf.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
 
+	ctx, cancel = context.WithCancel(context.Background())
+
 	By("bootstrapping test environment")
 	testEnv = &envtest.Environment{
 		CRDDirectoryPaths:     []string{filepath.Join("..", "config", "crd", "bases")},
 		ErrorIfCRDPathMissing: true,
+		WebhookInstallOptions: envtest.WebhookInstallOptions{
+			Paths: []string{filepath.Join("..", "config", "webhook")},
+		},
 	}
 
 	cfg, err := testEnv.Start()
@@ -71,10 +122,91 @@ var _ = BeforeSuite(func() {
 	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
 	Expect(err).NotTo(HaveOccurred())
 	Expect(k8sClient).NotTo(BeNil())
+
+	By("loading metrics certificates")
+	certsDir, err = os.MkdirTemp("", "discoblocks-metrics-certs")
+	Expect(err).NotTo(HaveOccurred())
+
+	// Certificates are only copied into Secrets, their content is not verified
+	for _, name := range []string{"ca.crt", "tls.crt", "tls.key"} {
+		Expect(os.WriteFile(filepath.Join(certsDir, name), []byte(name), 0o600)).To(Succeed())
+	}
+	Expect(mutators.LoadMetricsCerts(certsDir)).To(Succeed())
+
+	By("starting webhooks and controllers")
+	webhookInstallOptions := &testEnv.WebhookInstallOptions
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:             scheme.Scheme,
+		Host:               webhookInstallOptions.LocalServingHost,
+		Port:               webhookInstallOptions.LocalServingPort,
+		CertDir:            webhookInstallOptions.LocalServingCertDir,
+		LeaderElection:     false,
+		MetricsBindAddress: "0",
+		// Operator has no list and watch permission on them, envtest would hide blocking cached reads
+		ClientDisableCacheFor: utils.UncachedObjects(),
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	discoblocksondatiov1.InitDiskConfigWebhookDeps(mgr.GetClient(), utils.ConfigLabel(), utils.ParentLabel(), utils.ClusterConfigLabel(), []string{testProvisioner}, []string{})
+
+	Expect((&discoblocksondatiov1.DiskConfig{}).SetupWebhookWithManager(mgr)).To(Succeed())
+	Expect((&discoblocksondatiov1.ClusterDiskConfig{}).SetupWebhookWithManager(mgr)).To(Succeed())
+
+	podMutator := mutators.NewPodMutator(mgr.GetClient(), true, false, false, time.Second, nil, 0, nil, utils.NewEventService("controller", mgr.GetClient()))
+	mgr.GetWebhookServer().Register("/mutate-v1-pod", &webhook.Admission{Handler: podMutator})
+
+	// Kubelet of every node serves the metrics of the spec
+	kubeletClient := &restfake.RESTClient{
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(kubeletMetrics.Get()))}, nil
+		}),
+	}
+
+	pvcReconciler = &PVCReconciler{
+		EventService:  utils.NewEventService("controller", mgr.GetClient()),
+		NodeCache:     staticNodeCache{"10.0.0.1": "node-a", "10.0.0.2": "node-b"},
+		KubeletClient: kubeletClient,
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+	}
+	closeMonitor, err = pvcReconciler.SetupWithManager(mgr)
+	Expect(err).NotTo(HaveOccurred())
+
+	Expect((&ClusterDiskConfigReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr)).To(Succeed())
+
+	//+kubebuilder:scaffold:webhook
+
+	go func() {
+		defer GinkgoRecover()
+		err := mgr.Start(ctx)
+		Expect(err).NotTo(HaveOccurred())
+	}()
+
+	// wait for the webhook server to get ready
+	dialer := &net.Dialer{Timeout: time.Second}
+	addrPort := fmt.Sprintf("%s:%d", webhookInstallOptions.LocalServingHost, webhookInstallOptions.LocalServingPort)
+	Eventually(func() error {
+		//nolint:gosec // for test we are ok with InsecureSkipVerify
+		conn, err := tls.DialWithDialer(dialer, "tcp", addrPort, &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			return err
+		}
+		conn.Close()
+		return nil
+	}).Should(Succeed())
 }, 60)
 
 var _ = AfterSuite(func() {
+	if closeMonitor != nil {
+		close(closeMonitor)
+	}
+	cancel()
 	By("tearing down the test environment")
 	err := testEnv.Stop()
 	Expect(err).NotTo(HaveOccurred())
+	Expect(os.RemoveAll(certsDir)).To(Succeed())
 })
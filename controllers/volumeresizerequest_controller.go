@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"github.com/ondat/discoblocks/pkg/metrics"
+	"github.com/ondat/discoblocks/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// resizeRequestCheckPeriod is the period of checking expansion of applied resize requests
+const resizeRequestCheckPeriod = 10 * time.Second
+
+// resizeRequestExpansionDeadline is the maximum time of volume expansion after the request is applied
+const resizeRequestExpansionDeadline = time.Hour
+
+// resizeRequestSchedulingGrace is the maximum time a request waits for its Pod to be created and scheduled,
+// admission webhook requests resizes before the Pod exists
+const resizeRequestSchedulingGrace = 10 * time.Minute
+
+// VolumeResizeRequestReconciler applies resize requests of volume monitor to PVCs and follows them until completion
+type VolumeResizeRequestReconciler struct {
+	// Resizer executes resizes the same way volume monitor does without requests
+	Resizer *PVCReconciler
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// Reconcile moves the request through its phases, pending requests are applied, applied ones are completed once the volume reaches the capacity
+func (r *VolumeResizeRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithName("VolumeResizeRequestReconciler").WithValues("req_name", req.Name, "namespace", req.Namespace)
+
+	logger.Info("Reconciling...")
+	defer logger.Info("Reconciled")
+
+	request := discoblocksondatiov1.VolumeResizeRequest{}
+	if err := r.Get(ctx, req.NamespacedName, &request); err != nil {
+		if !apierrors.IsNotFound(err) {
+			metrics.NewError("VolumeResizeRequest", req.Name, req.Namespace, "Kube API", "get")
+
+			return ctrl.Result{}, fmt.Errorf("unable to fetch VolumeResizeRequest: %w", err)
+		}
+
+		logger.Info("VolumeResizeRequest not found")
+
+		return ctrl.Result{}, nil
+	}
+	logger = logger.WithValues("pvc_name", request.Spec.PVCName, "dc_name", request.Spec.ConfigName)
+
+	switch request.Status.Phase {
+	case "", discoblocksondatiov1.VolumeResizeRequestPending:
+		return r.apply(ctx, &request, logger)
+	case discoblocksondatiov1.VolumeResizeRequestApplied:
+		return r.complete(ctx, &request, logger)
+	default:
+		return ctrl.Result{}, nil
+	}
+}
+
+// apply resizes the PVC of the request
+func (r *VolumeResizeRequestReconciler) apply(ctx context.Context, request *discoblocksondatiov1.VolumeResizeRequest, logger logr.Logger) (ctrl.Result, error) {
+	logger.Info("Fetch DiskConfig...")
+
+	config := discoblocksondatiov1.DiskConfig{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: request.Spec.ConfigName, Namespace: request.Namespace}, &config); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, r.updateStatus(ctx, request, discoblocksondatiov1.VolumeResizeRequestFailed, "DiskConfig not found", logger)
+		}
+
+		metrics.NewError("DiskConfig", request.Spec.ConfigName, request.Namespace, "Kube API", "get")
+
+		return ctrl.Result{}, fmt.Errorf("unable to fetch DiskConfig: %w", err)
+	}
+
+	logger.Info("Fetch PVC...")
+
+	pvc := corev1.PersistentVolumeClaim{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: request.Spec.PVCName, Namespace: request.Namespace}, &pvc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, r.updateStatus(ctx, request, discoblocksondatiov1.VolumeResizeRequestFailed, "PVC not found", logger)
+		}
+
+		metrics.NewError("PersistentVolumeClaim", request.Spec.PVCName, request.Namespace, "Kube API", "get")
+
+		return ctrl.Result{}, fmt.Errorf("unable to fetch PVC: %w", err)
+	}
+
+	logger.Info("Fetch Pod...")
+
+	pod := corev1.Pod{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: request.Spec.PodName, Namespace: request.Namespace}, &pod); err != nil {
+		if apierrors.IsNotFound(err) {
+			if isWithinSchedulingGrace(request, time.Now()) {
+				logger.V(1).Info("Pod not found yet")
+				return ctrl.Result{RequeueAfter: resizeRequestCheckPeriod}, nil
+			}
+
+			return ctrl.Result{}, r.updateStatus(ctx, request, discoblocksondatiov1.VolumeResizeRequestFailed, "Pod not found", logger)
+		}
+
+		metrics.NewError("Pod", request.Spec.PodName, request.Namespace, "Kube API", "get")
+
+		return ctrl.Result{}, fmt.Errorf("unable to fetch Pod: %w", err)
+	}
+
+	// Requests are created by anyone with access to the namespace, they get the same checks as decisions of volume monitor
+	if pvc.Labels[utils.ConfigLabel()] != config.Name {
+		return ctrl.Result{}, r.updateStatus(ctx, request, discoblocksondatiov1.VolumeResizeRequestFailed, "PVC doesn't belong to DiskConfig", logger)
+	} else if utils.IsPVCPinned(&pvc) {
+		return ctrl.Result{}, r.updateStatus(ctx, request, discoblocksondatiov1.VolumeResizeRequestFailed, "PVC is pinned", logger)
+	}
+
+	policy := config.Spec.GetDiskPolicy(utils.GetPVCIndex(&pvc))
+	if !policy.MaximumCapacityOfDisk.IsZero() && request.Spec.Capacity.Cmp(policy.MaximumCapacityOfDisk) > 0 {
+		return ctrl.Result{}, r.updateStatus(ctx, request, discoblocksondatiov1.VolumeResizeRequestFailed, "Capacity is above maximum capacity of disk "+policy.MaximumCapacityOfDisk.String(), logger)
+	}
+
+	// File-system is grown on the node of the Pod, node of the request is informational only
+	if pod.Spec.NodeName == "" {
+		if isWithinSchedulingGrace(request, time.Now()) {
+			logger.V(1).Info("Pod is not scheduled yet")
+			return ctrl.Result{RequeueAfter: resizeRequestCheckPeriod}, nil
+		}
+
+		return ctrl.Result{}, r.updateStatus(ctx, request, discoblocksondatiov1.VolumeResizeRequestFailed, "Pod is not scheduled", logger)
+	}
+	logger = logger.WithValues("node_name", pod.Spec.NodeName)
+
+	if !r.Resizer.resizePVC(&config, &pod, request.Spec.Capacity, &pvc, pod.Spec.NodeName, logger) {
+		return ctrl.Result{}, r.updateStatus(ctx, request, discoblocksondatiov1.VolumeResizeRequestFailed, "Resize failed, see events of the PVC", logger)
+	}
+
+	now := metav1.Now()
+	request.Status.AppliedTime = &now
+
+	if err := r.updateStatus(ctx, request, discoblocksondatiov1.VolumeResizeRequestApplied, "PVC updated", logger); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: resizeRequestCheckPeriod}, nil
+}
+
+// complete checks whether the volume of the PVC has been expanded to the capacity of the request
+func (r *VolumeResizeRequestReconciler) complete(ctx context.Context, request *discoblocksondatiov1.VolumeResizeRequest, logger logr.Logger) (ctrl.Result, error) {
+	pvc := corev1.PersistentVolumeClaim{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: request.Spec.PVCName, Namespace: request.Namespace}, &pvc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, r.updateStatus(ctx, request, discoblocksondatiov1.VolumeResizeRequestFailed, "PVC not found", logger)
+		}
+
+		metrics.NewError("PersistentVolumeClaim", request.Spec.PVCName, request.Namespace, "Kube API", "get")
+
+		return ctrl.Result{}, fmt.Errorf("unable to fetch PVC: %w", err)
+	}
+
+	if capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]; !ok || capacity.Cmp(request.Spec.Capacity) < 0 {
+		if request.Status.AppliedTime != nil && time.Since(request.Status.AppliedTime.Time) > resizeRequestExpansionDeadline {
+			return ctrl.Result{}, r.updateStatus(ctx, request, discoblocksondatiov1.VolumeResizeRequestFailed, "Volume expansion didn't finish in "+resizeRequestExpansionDeadline.String(), logger)
+		}
+
+		logger.V(1).Info("Volume expansion is in progress", "capacity", capacity.String())
+		return ctrl.Result{RequeueAfter: resizeRequestCheckPeriod}, nil
+	}
+
+	now := metav1.Now()
+	request.Status.CompletionTime = &now
+
+	return ctrl.Result{}, r.updateStatus(ctx, request, discoblocksondatiov1.VolumeResizeRequestCompleted, "Volume expanded", logger)
+}
+
+// isWithinSchedulingGrace checks whether the request may still wait for its Pod
+func isWithinSchedulingGrace(request *discoblocksondatiov1.VolumeResizeRequest, now time.Time) bool {
+	return !request.CreationTimestamp.IsZero() && now.Sub(request.CreationTimestamp.Time) < resizeRequestSchedulingGrace
+}
+
+func (r *VolumeResizeRequestReconciler) updateStatus(ctx context.Context, request *discoblocksondatiov1.VolumeResizeRequest, phase discoblocksondatiov1.VolumeResizeRequestPhase, message string, logger logr.Logger) error {
+	request.Status.Phase = phase
+	request.Status.Message = message
+
+	logger.Info("Update VolumeResizeRequest status...", "phase", phase, "message", message)
+
+	if err := r.Client.Status().Update(ctx, request); err != nil {
+		metrics.NewError("VolumeResizeRequest", request.Name, request.Namespace, "Kube API", "update")
+
+		return fmt.Errorf("unable to update VolumeResizeRequest status: %w", err)
+	}
+
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VolumeResizeRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&discoblocksondatiov1.VolumeResizeRequest{}).
+		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: concurrency,
+		}).
+		Complete(r)
+}
@@ -0,0 +1,344 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"github.com/ondat/discoblocks/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newResizeRequestObjects() (*discoblocksondatiov1.DiskConfig, *corev1.PersistentVolumeClaim, *corev1.Pod) {
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+		},
+	}
+
+	pvc := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pvc",
+			Namespace: "default",
+			UID:       "pvc-uid",
+			Labels:    map[string]string{utils.ConfigLabel(): "config"},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+		},
+	}
+
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "default",
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node",
+		},
+	}
+
+	return &config, &pvc, &pod
+}
+
+func TestVolumeResizeRequestLifecycle(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	config, pvc, pod := newResizeRequestObjects()
+
+	request, err := utils.RenderVolumeResizeRequest(config.Name, pod.Name, "node", pvc, resource.MustParse("2Gi"), "used 90.00% >= 80%", time.Now())
+	require.Nil(t, err, "unable to render request")
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(config, pvc, pod, request).Build()
+
+	r := VolumeResizeRequestReconciler{
+		Resizer: &PVCReconciler{
+			EventService: utils.NewEventService("controller", kubeClient),
+			Client:       kubeClient,
+		},
+		Client: kubeClient,
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: request.Name, Namespace: request.Namespace}}
+
+	getRequest := func() *discoblocksondatiov1.VolumeResizeRequest {
+		latest := discoblocksondatiov1.VolumeResizeRequest{}
+		require.Nil(t, kubeClient.Get(context.Background(), req.NamespacedName, &latest), "unable to fetch request")
+
+		return &latest
+	}
+
+	assert.Empty(t, getRequest().Status.Phase, "created request not pending")
+
+	result, err := r.Reconcile(context.Background(), req)
+	require.Nil(t, err, "unable to apply request")
+	assert.Equal(t, resizeRequestCheckPeriod, result.RequeueAfter, "applied request not followed")
+
+	applied := getRequest()
+	assert.Equal(t, discoblocksondatiov1.VolumeResizeRequestApplied, applied.Status.Phase, "request not applied")
+	assert.NotNil(t, applied.Status.AppliedTime, "missing applied time")
+
+	latestPVC := corev1.PersistentVolumeClaim{}
+	require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace}, &latestPVC), "unable to fetch PVC")
+	assert.Equal(t, "2Gi", latestPVC.Spec.Resources.Requests.Storage().String(), "PVC not resized")
+
+	// Volume is still expanding
+	result, err = r.Reconcile(context.Background(), req)
+	require.Nil(t, err, "unable to check request")
+	assert.Equal(t, resizeRequestCheckPeriod, result.RequeueAfter, "expanding request not followed")
+	assert.Equal(t, discoblocksondatiov1.VolumeResizeRequestApplied, getRequest().Status.Phase, "request completed before expansion")
+
+	latestPVC.Status.Capacity = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("2Gi")}
+	require.Nil(t, kubeClient.Status().Update(context.Background(), &latestPVC), "unable to expand PVC")
+
+	result, err = r.Reconcile(context.Background(), req)
+	require.Nil(t, err, "unable to complete request")
+	assert.Zero(t, result.RequeueAfter, "completed request requeued")
+
+	completed := getRequest()
+	assert.Equal(t, discoblocksondatiov1.VolumeResizeRequestCompleted, completed.Status.Phase, "request not completed")
+	assert.NotNil(t, completed.Status.CompletionTime, "missing completion time")
+
+	result, err = r.Reconcile(context.Background(), req)
+	require.Nil(t, err, "completed request failed")
+	assert.Zero(t, result.RequeueAfter, "completed request requeued")
+}
+
+func TestVolumeResizeRequestFailed(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	config, pvc, pod := newResizeRequestObjects()
+
+	request, err := utils.RenderVolumeResizeRequest(config.Name, pod.Name, "node", pvc, resource.MustParse("2Gi"), "", time.Now())
+	require.Nil(t, err, "unable to render request")
+
+	// PVC has been deleted since the decision
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(config, pod, request).Build()
+
+	r := VolumeResizeRequestReconciler{
+		Resizer: &PVCReconciler{
+			EventService: utils.NewEventService("controller", kubeClient),
+			Client:       kubeClient,
+		},
+		Client: kubeClient,
+	}
+
+	_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: request.Name, Namespace: request.Namespace}})
+	require.Nil(t, err, "unable to reconcile request")
+
+	latest := discoblocksondatiov1.VolumeResizeRequest{}
+	require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Name: request.Name, Namespace: request.Namespace}, &latest), "unable to fetch request")
+
+	assert.Equal(t, discoblocksondatiov1.VolumeResizeRequestFailed, latest.Status.Phase, "request not failed")
+	assert.Equal(t, "PVC not found", latest.Status.Message, "invalid message")
+}
+
+func TestVolumeResizeRequestRejected(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	cases := map[string]struct {
+		modify          func(*discoblocksondatiov1.DiskConfig, *corev1.PersistentVolumeClaim, *corev1.Pod, *discoblocksondatiov1.VolumeResizeRequest)
+		expectedMessage string
+	}{
+		"foreign PVC": {
+			modify: func(_ *discoblocksondatiov1.DiskConfig, pvc *corev1.PersistentVolumeClaim, _ *corev1.Pod, _ *discoblocksondatiov1.VolumeResizeRequest) {
+				pvc.Labels[utils.ConfigLabel()] = "other"
+			},
+			expectedMessage: "PVC doesn't belong to DiskConfig",
+		},
+		"pinned PVC": {
+			modify: func(_ *discoblocksondatiov1.DiskConfig, pvc *corev1.PersistentVolumeClaim, _ *corev1.Pod, _ *discoblocksondatiov1.VolumeResizeRequest) {
+				pvc.Annotations = map[string]string{utils.PinAnnotation(): "true"}
+			},
+			expectedMessage: "PVC is pinned",
+		},
+		"above maximum": {
+			modify: func(config *discoblocksondatiov1.DiskConfig, _ *corev1.PersistentVolumeClaim, _ *corev1.Pod, _ *discoblocksondatiov1.VolumeResizeRequest) {
+				config.Spec.Policy.MaximumCapacityOfDisk = resource.MustParse("1500Mi")
+			},
+			expectedMessage: "Capacity is above maximum capacity of disk 1500Mi",
+		},
+		"unscheduled Pod": {
+			modify: func(_ *discoblocksondatiov1.DiskConfig, _ *corev1.PersistentVolumeClaim, pod *corev1.Pod, request *discoblocksondatiov1.VolumeResizeRequest) {
+				pod.Spec.NodeName = ""
+				request.CreationTimestamp = metav1.NewTime(time.Now().Add(-resizeRequestSchedulingGrace - time.Minute))
+			},
+			expectedMessage: "Pod is not scheduled",
+		},
+		"expansion deadline": {
+			modify: func(_ *discoblocksondatiov1.DiskConfig, _ *corev1.PersistentVolumeClaim, _ *corev1.Pod, request *discoblocksondatiov1.VolumeResizeRequest) {
+				appliedTime := metav1.NewTime(time.Now().Add(-resizeRequestExpansionDeadline - time.Minute))
+				request.Status.Phase = discoblocksondatiov1.VolumeResizeRequestApplied
+				request.Status.AppliedTime = &appliedTime
+			},
+			expectedMessage: "Volume expansion didn't finish in 1h0m0s",
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			config, pvc, pod := newResizeRequestObjects()
+
+			request, err := utils.RenderVolumeResizeRequest(config.Name, pod.Name, "other-node", pvc, resource.MustParse("2Gi"), "", time.Now())
+			require.Nil(t, err, "unable to render request")
+
+			c.modify(config, pvc, pod, request)
+
+			kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(config, pvc, pod, request).Build()
+
+			r := VolumeResizeRequestReconciler{
+				Resizer: &PVCReconciler{
+					EventService: utils.NewEventService("controller", kubeClient),
+					Client:       kubeClient,
+				},
+				Client: kubeClient,
+			}
+
+			result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: request.Name, Namespace: request.Namespace}})
+			require.Nil(t, err, "unable to reconcile request")
+			assert.Zero(t, result.RequeueAfter, "failed request requeued")
+
+			latest := discoblocksondatiov1.VolumeResizeRequest{}
+			require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Name: request.Name, Namespace: request.Namespace}, &latest), "unable to fetch request")
+
+			assert.Equal(t, discoblocksondatiov1.VolumeResizeRequestFailed, latest.Status.Phase, "request not failed")
+			assert.Equal(t, c.expectedMessage, latest.Status.Message, "invalid message")
+
+			latestPVC := corev1.PersistentVolumeClaim{}
+			require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace}, &latestPVC), "unable to fetch PVC")
+			assert.Equal(t, "1Gi", latestPVC.Spec.Resources.Requests.Storage().String(), "PVC resized")
+		})
+	}
+}
+
+func TestVolumeResizeRequestWaitsForPod(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	cases := map[string]struct {
+		podCreated bool
+	}{
+		"Pod not created": {},
+		"Pod not scheduled": {
+			podCreated: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			config, pvc, pod := newResizeRequestObjects()
+			pod.Spec.NodeName = ""
+
+			request, err := utils.RenderVolumeResizeRequest(config.Name, pod.Name, "", pvc, resource.MustParse("2Gi"), "", time.Now())
+			require.Nil(t, err, "unable to render request")
+			request.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Minute))
+
+			objects := []client.Object{config, pvc, request}
+			if c.podCreated {
+				objects = append(objects, pod)
+			}
+
+			kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := VolumeResizeRequestReconciler{
+				Resizer: &PVCReconciler{
+					EventService: utils.NewEventService("controller", kubeClient),
+					Client:       kubeClient,
+				},
+				Client: kubeClient,
+			}
+
+			result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: request.Name, Namespace: request.Namespace}})
+			require.Nil(t, err, "unable to reconcile request")
+			assert.Equal(t, resizeRequestCheckPeriod, result.RequeueAfter, "waiting request not requeued")
+
+			latest := discoblocksondatiov1.VolumeResizeRequest{}
+			require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Name: request.Name, Namespace: request.Namespace}, &latest), "unable to fetch request")
+			assert.Empty(t, latest.Status.Phase, "waiting request not pending")
+		})
+	}
+}
+
+func TestRequestResize(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	cases := map[string]struct {
+		existingPhase    discoblocksondatiov1.VolumeResizeRequestPhase
+		expectedRequests int
+	}{
+		"no request": {
+			expectedRequests: 1,
+		},
+		"open request": {
+			existingPhase:    discoblocksondatiov1.VolumeResizeRequestApplied,
+			expectedRequests: 1,
+		},
+		"completed request": {
+			existingPhase:    discoblocksondatiov1.VolumeResizeRequestCompleted,
+			expectedRequests: 2,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			config, pvc, pod := newResizeRequestObjects()
+
+			objects := []client.Object{config, pvc, pod}
+			if c.existingPhase != "" {
+				existing, err := utils.RenderVolumeResizeRequest(config.Name, pod.Name, "node", pvc, resource.MustParse("2Gi"), "", time.Now().Add(-time.Hour))
+				require.Nil(t, err, "unable to render request")
+
+				existing.Status.Phase = c.existingPhase
+				objects = append(objects, existing)
+			}
+
+			kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := PVCReconciler{
+				EventService: utils.NewEventService("controller", kubeClient),
+				Client:       kubeClient,
+			}
+
+			r.requestResize(config, pod, resource.MustParse("3Gi"), pvc, "node", "used 90.00% >= 80%", logr.Discard())
+
+			requests := discoblocksondatiov1.VolumeResizeRequestList{}
+			require.Nil(t, kubeClient.List(context.Background(), &requests), "unable to list requests")
+
+			assert.Len(t, requests.Items, c.expectedRequests, "invalid number of requests")
+
+			latestPVC := corev1.PersistentVolumeClaim{}
+			require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace}, &latestPVC), "unable to fetch PVC")
+			assert.Equal(t, "1Gi", latestPVC.Spec.Resources.Requests.Storage().String(), "PVC resized by monitor")
+		})
+	}
+}
@@ -59,9 +59,14 @@ func GetCSIDriverPodLabels() {
 
 //export GetPreMountCommand
 func GetPreMountCommand() {
+	if strategy := os.Getenv("DEVICE_PATH_STRATEGY"); strategy != "" {
+		fmt.Fprintf(os.Stderr, "unsupported device path strategy: %s", strategy)
+		return
+	}
+
 	fmt.Fprintf(os.Stdout, `VOL=$(chroot /host nsenter --target 1 --mount sh -c "grep ^ /dev/null /var/lib/storageos/state/*" | grep ${PV_NAME} | awk '{split($0,a,":"); print a[1]}' | grep -oe "v\..*\.json$"| awk '{gsub(".json","",$1); print $1}') &&
-chroot /host nsenter --target 1 --mount mkdir -p /var/lib/kubelet/plugins/kubernetes.io/csi/pv/${PV_NAME}/mount &&
-chroot /host nsenter --target 1 --mount mount /var/lib/storageos/volumes/${VOL} /var/lib/kubelet/plugins/kubernetes.io/csi/pv/${PV_NAME}/mount &&
+chroot /host nsenter --target 1 --mount mkdir -p ${STAGING_PATH} &&
+chroot /host nsenter --target 1 --mount mount /var/lib/storageos/volumes/${VOL} ${STAGING_PATH} &&
 DEV=/${PV_NAME}`)
 }
 
@@ -73,5 +78,23 @@ func IsFileSystemManaged() {
 	fmt.Fprint(os.Stdout, true)
 }
 
+//export IsNodeLocal
+func IsNodeLocal() {
+	fmt.Fprint(os.Stdout, false)
+}
+
+//export GetSupportedAccessModes
+func GetSupportedAccessModes() {
+	fmt.Fprint(os.Stdout, `[ "ReadWriteOnce", "ReadWriteMany" ]`)
+}
+
 //export WaitForVolumeAttachmentMeta
 func WaitForVolumeAttachmentMeta() {}
+
+//export GetHostJobVolumes
+func GetHostJobVolumes() {}
+
+//export GetStagingPath
+func GetStagingPath() {
+	fmt.Fprint(os.Stdout, "/var/lib/kubelet/plugins/kubernetes.io/csi/pv/${PV_NAME}/mount")
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -71,31 +72,64 @@ func GetCSIDriverPodLabels() {
 
 //export GetPreMountCommand
 func GetPreMountCommand() {
-	json := []byte(os.Getenv("PERSISTENT_VOLUME_JSON"))
-
-	volumeHandle := strings.ReplaceAll(fastjson.GetString(json, "spec", "csi", "volumeHandle"), "-", "")
-	if volumeHandle == "" {
-		fmt.Fprint(os.Stderr, "spec.csi.volumeHandle not found")
+	devCommand, err := renderDevicePathCommand(os.Getenv("DEVICE_PATH_STRATEGY"), []byte(os.Getenv("PERSISTENT_VOLUME_JSON")), []byte(os.Getenv("VOLUME_ATTACHMENT_JSON")))
+	if err != nil {
+		fmt.Fprint(os.Stderr, err.Error())
 		return
 	}
 
-	fmt.Fprintf(os.Stdout, `DEV=$(nvme list | grep %s | awk '{print $1}') &&
+	fmt.Fprintf(os.Stdout, `%s &&
 (chroot /host nsenter --target 1 --mount mkfs.${FS} ${DEV} ||:)`,
-		volumeHandle)
+		devCommand)
 }
 
 //export GetPreResizeCommand
 func GetPreResizeCommand() {
-	json := []byte(os.Getenv("PERSISTENT_VOLUME_JSON"))
-
-	volumeHandle := strings.ReplaceAll(fastjson.GetString(json, "spec", "csi", "volumeHandle"), "-", "")
-	if volumeHandle == "" {
-		fmt.Fprint(os.Stderr, "spec.csi.volumeHandle not found")
+	devCommand, err := renderDevicePathCommand(os.Getenv("DEVICE_PATH_STRATEGY"), []byte(os.Getenv("PERSISTENT_VOLUME_JSON")), []byte(os.Getenv("VOLUME_ATTACHMENT_JSON")))
+	if err != nil {
+		fmt.Fprint(os.Stderr, err.Error())
 		return
 	}
 
-	fmt.Fprintf(os.Stdout, `DEV=$(nvme list | grep %s | awk '{print $1}')`,
-		volumeHandle)
+	fmt.Fprint(os.Stdout, devCommand)
+}
+
+// Device path resolution strategies, NVMeSerial is the default
+const (
+	strategyNVMeSerial       = "NVMeSerial"
+	strategyByID             = "ByID"
+	strategyVolumeAttachment = "VolumeAttachment"
+)
+
+// renderDevicePathCommand returns the command setting DEV to the device of the volume.
+// EBS on Nitro instances exposes NVMe devices, their names are unrelated to the requested device,
+// but serial of the NVMe controller and by-id symlink contain the volume ID.
+func renderDevicePathCommand(strategy string, pvJSON, vaJSON []byte) (string, error) {
+	volumeHandle := strings.ReplaceAll(fastjson.GetString(pvJSON, "spec", "csi", "volumeHandle"), "-", "")
+
+	switch strategy {
+	case "", strategyNVMeSerial:
+		if volumeHandle == "" {
+			return "", errors.New("spec.csi.volumeHandle not found")
+		}
+
+		return fmt.Sprintf(`DEV=$(nvme list | grep %s | awk '{print $1}')`, volumeHandle), nil
+	case strategyByID:
+		if volumeHandle == "" {
+			return "", errors.New("spec.csi.volumeHandle not found")
+		}
+
+		return fmt.Sprintf(`DEV=$(chroot /host readlink -f /dev/disk/by-id/nvme-Amazon_Elastic_Block_Store_%s)`, volumeHandle), nil
+	case strategyVolumeAttachment:
+		devicePath := fastjson.GetString(vaJSON, "status", "attachmentMetadata", "devicePath")
+		if devicePath == "" {
+			return "", errors.New("status.attachmentMetadata.devicePath not found")
+		}
+
+		return fmt.Sprintf(`DEV=$(chroot /host readlink -f %s)`, devicePath), nil
+	default:
+		return "", fmt.Errorf("unsupported device path strategy: %s", strategy)
+	}
 }
 
 //export IsFileSystemManaged
@@ -103,5 +137,26 @@ func IsFileSystemManaged() {
 	fmt.Fprint(os.Stdout, false)
 }
 
+//export IsNodeLocal
+func IsNodeLocal() {
+	fmt.Fprint(os.Stdout, false)
+}
+
+//export GetSupportedAccessModes
+func GetSupportedAccessModes() {
+	fmt.Fprint(os.Stdout, `[ "ReadWriteOnce" ]`)
+}
+
+//export GetSupportedDevicePathStrategies
+func GetSupportedDevicePathStrategies() {
+	fmt.Fprint(os.Stdout, `[ "NVMeSerial", "ByID", "VolumeAttachment" ]`)
+}
+
 //export WaitForVolumeAttachmentMeta
 func WaitForVolumeAttachmentMeta() {}
+
+//export GetHostJobVolumes
+func GetHostJobVolumes() {}
+
+//export GetStagingPath
+func GetStagingPath() {}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRenderDevicePathCommand(t *testing.T) {
+	t.Parallel()
+
+	pvJSON := []byte(`{"spec":{"csi":{"volumeHandle":"vol-0123456789abcdef0"}}}`)
+	vaJSON := []byte(`{"status":{"attached":true,"attachmentMetadata":{"devicePath":"/dev/xvdba"}}}`)
+
+	cases := map[string]struct {
+		strategy      string
+		pvJSON        []byte
+		vaJSON        []byte
+		expected      string
+		expectedError bool
+	}{
+		"default": {
+			pvJSON:   pvJSON,
+			expected: `DEV=$(nvme list | grep vol0123456789abcdef0 | awk '{print $1}')`,
+		},
+		"nvme serial": {
+			strategy: strategyNVMeSerial,
+			pvJSON:   pvJSON,
+			expected: `DEV=$(nvme list | grep vol0123456789abcdef0 | awk '{print $1}')`,
+		},
+		"by-id": {
+			strategy: strategyByID,
+			pvJSON:   pvJSON,
+			expected: `DEV=$(chroot /host readlink -f /dev/disk/by-id/nvme-Amazon_Elastic_Block_Store_vol0123456789abcdef0)`,
+		},
+		"volume attachment": {
+			strategy: strategyVolumeAttachment,
+			pvJSON:   pvJSON,
+			vaJSON:   vaJSON,
+			expected: `DEV=$(chroot /host readlink -f /dev/xvdba)`,
+		},
+		"missing volume handle": {
+			strategy:      strategyByID,
+			pvJSON:        []byte(`{"spec":{}}`),
+			expectedError: true,
+		},
+		"missing device path": {
+			strategy:      strategyVolumeAttachment,
+			pvJSON:        pvJSON,
+			vaJSON:        []byte(`{"status":{"attached":true}}`),
+			expectedError: true,
+		},
+		"unknown": {
+			strategy:      "ByLabel",
+			pvJSON:        pvJSON,
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			command, err := renderDevicePathCommand(c.strategy, c.pvJSON, c.vaJSON)
+			if (err != nil) != c.expectedError {
+				t.Fatalf("invalid error: %v", err)
+			}
+
+			if command != c.expected {
+				t.Errorf("invalid command: %s", command)
+			}
+		})
+	}
+}
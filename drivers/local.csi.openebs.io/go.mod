@@ -0,0 +1,5 @@
+module local.csi.openebs.io
+
+go 1.18
+
+require github.com/valyala/fastjson v1.6.3
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/valyala/fastjson"
+)
+
+func main() {}
+
+//export IsStorageClassValid
+func IsStorageClassValid() {
+	json := []byte(os.Getenv("STORAGE_CLASS_JSON"))
+
+	if !fastjson.Exists(json, "allowVolumeExpansion") || !fastjson.GetBool(json, "allowVolumeExpansion") {
+		fmt.Fprint(os.Stderr, "only allowVolumeExpansion true is supported")
+		fmt.Fprint(os.Stdout, false)
+		return
+	}
+
+	fmt.Fprint(os.Stdout, true)
+}
+
+//export GetStorageClassAllowedTopology
+func GetStorageClassAllowedTopology() {
+	json := []byte(os.Getenv("NODE_JSON"))
+
+	nodeName := fastjson.GetString(json, "metadata", "name")
+	if nodeName == "" {
+		fmt.Fprint(os.Stderr, "metadata.name not found")
+		return
+	}
+
+	fmt.Fprintf(os.Stdout, `[{
+	"matchLabelExpressions": [
+		{
+			"key": "openebs.io/nodename",
+			"values": [ "%s" ]
+		}
+	]
+}]`, nodeName)
+}
+
+//export GetPVCStub
+func GetPVCStub() {
+	fmt.Fprintf(os.Stdout, `{
+	"apiVersion": "v1",
+	"kind": "PersistentVolumeClaim",
+	"metadata": {
+		"name": "%s",
+		"namespace": "%s"
+	},
+	"spec": {
+		"storageClassName": "%s"
+	}
+}`,
+		os.Getenv("PVC_NAME"), os.Getenv("PVC_NAMESACE"), os.Getenv("STORAGE_CLASS_NAME"))
+}
+
+//export GetCSIDriverNamespace
+func GetCSIDriverNamespace() {
+	fmt.Fprint(os.Stdout, "openebs")
+}
+
+//export GetCSIDriverPodLabels
+func GetCSIDriverPodLabels() {
+	fmt.Fprint(os.Stdout, `{ "app": "openebs-lvm-node" }`)
+}
+
+//export GetPreMountCommand
+func GetPreMountCommand() {
+	devCommand, ok := renderDevicePathCommand()
+	if !ok {
+		return
+	}
+
+	fmt.Fprintf(os.Stdout, `%s &&
+(chroot /host nsenter --target 1 --mount mkfs.${FS} ${DEV} ||:)`,
+		devCommand)
+}
+
+//export GetPreResizeCommand
+func GetPreResizeCommand() {
+	devCommand, ok := renderDevicePathCommand()
+	if !ok {
+		return
+	}
+
+	fmt.Fprint(os.Stdout, devCommand)
+}
+
+// renderDevicePathCommand returns the command setting DEV to the logical volume of the PV, name of the logical volume is the volume handle
+func renderDevicePathCommand() (string, bool) {
+	if strategy := os.Getenv("DEVICE_PATH_STRATEGY"); strategy != "" {
+		fmt.Fprintf(os.Stderr, "unsupported device path strategy: %s", strategy)
+		return "", false
+	}
+
+	volumeHandle := fastjson.GetString([]byte(os.Getenv("PERSISTENT_VOLUME_JSON")), "spec", "csi", "volumeHandle")
+	if volumeHandle == "" {
+		fmt.Fprint(os.Stderr, "spec.csi.volumeHandle not found")
+		return "", false
+	}
+
+	return fmt.Sprintf(`DEV=$(chroot /host nsenter --target 1 --mount lvs --noheadings -o lv_path -S lv_name=%s | tr -d ' ')`, volumeHandle), true
+}
+
+//export IsFileSystemManaged
+func IsFileSystemManaged() {
+	fmt.Fprint(os.Stdout, false)
+}
+
+//export IsNodeLocal
+func IsNodeLocal() {
+	fmt.Fprint(os.Stdout, true)
+}
+
+//export GetSupportedAccessModes
+func GetSupportedAccessModes() {
+	fmt.Fprint(os.Stdout, `[ "ReadWriteOnce" ]`)
+}
+
+//export WaitForVolumeAttachmentMeta
+func WaitForVolumeAttachmentMeta() {}
+
+//export GetHostJobVolumes
+func GetHostJobVolumes() {}
+
+//export GetStagingPath
+func GetStagingPath() {}
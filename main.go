@@ -18,6 +18,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"net/http"
 	"os"
@@ -32,8 +33,10 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/klog/v2"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
@@ -43,6 +46,9 @@ import (
 	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
 	"github.com/ondat/discoblocks/controllers"
 	"github.com/ondat/discoblocks/mutators"
+	"github.com/ondat/discoblocks/pkg/drivers"
+	"github.com/ondat/discoblocks/pkg/metrics"
+	"github.com/ondat/discoblocks/pkg/selftest"
 	"github.com/ondat/discoblocks/pkg/utils"
 	"github.com/ondat/discoblocks/schedulers"
 	//+kubebuilder:scaffold:imports
@@ -50,6 +56,18 @@ import (
 
 const (
 	webhookport = 9443
+
+	// defaultStorageClassRetry must fit into the timeout of admission webhooks
+	defaultStorageClassRetry = 5 * time.Second
+
+	// defaultProvisionBurst is the number of PVCs created at once before pacing
+	defaultProvisionBurst = 10
+
+	// defaultProvisionMaxDelay must fit into the timeout of admission webhooks
+	defaultProvisionMaxDelay = 3 * time.Second
+
+	// defaultConfigFetchBackoff is the first requeue delay of PVCs whose DiskConfig can't be fetched
+	defaultConfigFetchBackoff = 5 * time.Second
 )
 
 var (
@@ -60,16 +78,24 @@ var (
 //+kubebuilder:rbac:groups=discoblocks.ondat.io,resources=diskconfigs,verbs=get;list;watch;create;update;delete
 //+kubebuilder:rbac:groups=discoblocks.ondat.io,resources=diskconfigs/status,verbs=update
 //+kubebuilder:rbac:groups=discoblocks.ondat.io,resources=diskconfigs/finalizers,verbs=update
+//+kubebuilder:rbac:groups=discoblocks.ondat.io,resources=clusterdiskconfigs,verbs=get;list;watch
+//+kubebuilder:rbac:groups=discoblocks.ondat.io,resources=clusterdiskconfigs/status,verbs=update
+//+kubebuilder:rbac:groups=discoblocks.ondat.io,resources=volumeresizerequests,verbs=get;list;watch;create
+//+kubebuilder:rbac:groups=discoblocks.ondat.io,resources=volumeresizerequests/status,verbs=update
 //+kubebuilder:rbac:groups="storage.k8s.io",resources=volumeattachments,verbs=create;list;watch
 //+kubebuilder:rbac:groups="storage.k8s.io",resources=storageclasses,verbs=get;update;create
 //+kubebuilder:rbac:groups="storage.k8s.io",resources=storageclasses/finalizers,verbs=update
 //+kubebuilder:rbac:groups="batch",resources=jobs,verbs=create;list;watch;delete
-//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;update
+//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=persistentvolumeclaims/finalizers,verbs=update
 //+kubebuilder:rbac:groups="",resources=persistentvolumes,verbs=get
-//+kubebuilder:rbac:groups="",resources=secrets,verbs=create
-//+kubebuilder:rbac:groups="",resources=pods,verbs=list;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;update
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=create;get;list;update;delete
+//+kubebuilder:rbac:groups="",resources=pods,verbs=list;patch;delete
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=nodes/proxy,verbs=get
 //+kubebuilder:rbac:groups="events.k8s.io",resources=events,verbs=create
+//+kubebuilder:rbac:groups="monitoring.coreos.com",resources=servicemonitors,verbs=create;deletecollection
 
 // indirect rbac
 //+kubebuilder:rbac:groups="",resources=namespaces;services;pods;persistentvolumes;replicationcontrollers,verbs=list;watch
@@ -91,40 +117,184 @@ func main() {
 	http.DefaultClient.Timeout = time.Minute
 
 	var metricsAddr string
+	var discoblocksMetricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var certDir string
+	var selfTest bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&discoblocksMetricsAddr, "discoblocks-metrics-bind-address", "",
+		"The address the endpoint of discoblocks metrics binds to, empty serves them on the metric endpoint.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	flag.StringVar(&certDir, "webhook-cert-dir", "/tmp/k8s-webhook-server/serving-certs",
+		"The directory of tls.crt and tls.key of webhook server, files are reloaded on change.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&selfTest, "self-test", false,
+		"Run the provision, mount, low-space and resize path against a fake driver in memory and exit.")
 	opts := zap.Options{
 		Development: true,
 	}
 	opts.BindFlags(flag.CommandLine)
+	// Development mode logs every level by default, routine decisions of volume monitor are visible with --zap-log-level=1 or 2
+	if err := flag.CommandLine.Set("zap-log-level", "info"); err != nil {
+		panic(err)
+	}
 	flag.Parse()
 
 	zapLogger := zap.New(zap.UseFlagOptions(&opts))
 	ctrl.SetLogger(zapLogger)
 	klog.SetLogger(zapLogger)
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	if err := utils.SetLabelPrefix(os.Getenv("LABEL_PREFIX")); err != nil {
+		setupLog.Error(err, "unable to parse LABEL_PREFIX")
+		os.Exit(1)
+	}
+
+	if err := drivers.SetPodSelectorOverrides(os.Getenv("CSI_DRIVER_POD_SELECTORS")); err != nil {
+		setupLog.Error(err, "unable to parse CSI_DRIVER_POD_SELECTORS")
+		os.Exit(1)
+	}
+
+	if err := utils.SetMountVerifyCommand(os.Getenv("MOUNT_VERIFY_COMMAND")); err != nil {
+		setupLog.Error(err, "unable to parse MOUNT_VERIFY_COMMAND")
+		os.Exit(1)
+	}
+
+	if err := utils.SetFSIdentityMode(os.Getenv("FS_IDENTITY_MODE")); err != nil {
+		setupLog.Error(err, "unable to parse FS_IDENTITY_MODE")
+		os.Exit(1)
+	}
+
+	hostJobOptions := utils.DefaultHostJobOptions
+	if raw := os.Getenv("HOST_JOB_RESTART_POLICY"); raw != "" {
+		hostJobOptions.RestartPolicy = corev1.RestartPolicy(raw)
+	}
+
+	for key, value := range map[string]*int32{
+		"HOST_JOB_BACKOFF_LIMIT": &hostJobOptions.BackoffLimit,
+		"HOST_JOB_COMPLETIONS":   &hostJobOptions.Completions,
+		"HOST_JOB_PARALLELISM":   &hostJobOptions.Parallelism,
+	} {
+		parsed, err := parseInt32Env(key, *value)
+		if err != nil {
+			setupLog.Error(err, "unable to parse "+key)
+			os.Exit(1)
+		}
+
+		*value = parsed
+	}
+
+	activeDeadline, err := parseDurationEnv("HOST_JOB_ACTIVE_DEADLINE", utils.DefaultHostJobActiveDeadline)
+	if err != nil {
+		setupLog.Error(err, "unable to parse HOST_JOB_ACTIVE_DEADLINE")
+		os.Exit(1)
+	}
+	hostJobOptions.ActiveDeadline = activeDeadline
+
+	if err := utils.SetHostJobOptions(hostJobOptions); err != nil {
+		setupLog.Error(err, "unable to configure host jobs")
+		os.Exit(1)
+	}
+
+	maxHostJobs, err := parseInt32Env("HOST_JOB_MAX_OUTSTANDING", 0)
+	if err != nil {
+		setupLog.Error(err, "unable to parse HOST_JOB_MAX_OUTSTANDING")
+		os.Exit(1)
+	}
+
+	hostJobConcurrency, err := parseInt32Env("HOST_JOB_CREATION_CONCURRENCY", utils.DefaultHostJobCreationConcurrency)
+	if err != nil {
+		setupLog.Error(err, "unable to parse HOST_JOB_CREATION_CONCURRENCY")
+		os.Exit(1)
+	}
+
+	hostJobQueue, err := utils.NewHostJobQueue(maxHostJobs, hostJobConcurrency, utils.DefaultHostJobQueuePeriod)
+	if err != nil {
+		setupLog.Error(err, "unable to create host Job queue")
+		os.Exit(1)
+	}
+
+	if err := utils.SetImagePullSecrets(os.Getenv("IMAGE_PULL_SECRETS")); err != nil {
+		setupLog.Error(err, "unable to parse IMAGE_PULL_SECRETS")
+		os.Exit(1)
+	}
+
+	if selfTest {
+		os.Exit(runSelfTest())
+	}
+
+	restConfig := ctrl.GetConfigOrDie()
+
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
 		Port:                   webhookport,
+		CertDir:                certDir,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       controllerID,
+		ClientDisableCacheFor:  utils.UncachedObjects(),
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	if err := mgr.Add(hostJobQueue); err != nil {
+		setupLog.Error(err, "unable to add host Job queue")
+		os.Exit(1)
+	}
+
 	eventService := utils.NewEventService(controllerID, mgr.GetClient())
 
+	activitySize, err := parseInt32Env("ACTIVITY_STREAM_SIZE", utils.DefaultActivityStreamSize)
+	if err != nil || activitySize <= 0 {
+		setupLog.Error(err, "unable to parse ACTIVITY_STREAM_SIZE, it must be positive", "value", activitySize)
+		os.Exit(1)
+	}
+
+	activityTTL, err := parseDurationEnv("ACTIVITY_STREAM_TTL", utils.DefaultActivityStreamTTL)
+	if err != nil || activityTTL < 0 {
+		setupLog.Error(err, "unable to parse ACTIVITY_STREAM_TTL, it must not be negative", "value", activityTTL)
+		os.Exit(1)
+	}
+
+	activityStream, err := utils.NewActivityStream(os.Getenv("ACTIVITY_STREAM_CONFIGMAP"), os.Getenv("POD_NAMESPACE"), int(activitySize), activityTTL, mgr.GetClient(), mgr.GetAPIReader(), ctrl.Log.WithName("ActivityStream"))
+	if err != nil {
+		setupLog.Error(err, "unable to create activity stream")
+		os.Exit(1)
+	} else if activityStream != nil {
+		if err := mgr.Add(activityStream); err != nil {
+			setupLog.Error(err, "unable to add activity stream")
+			os.Exit(1)
+		}
+
+		eventService = utils.NewActivityEventService(eventService, activityStream)
+	}
+
+	auditSize, err := parseInt32Env("AUDIT_RETENTION_SIZE", utils.DefaultAuditRetentionSize)
+	if err != nil || auditSize < 0 {
+		setupLog.Error(err, "unable to parse AUDIT_RETENTION_SIZE, it must not be negative", "value", auditSize)
+		os.Exit(1)
+	}
+
+	auditTTL, err := parseDurationEnv("AUDIT_RETENTION_TTL", utils.DefaultAuditRetentionTTL)
+	if err != nil || auditTTL < 0 {
+		setupLog.Error(err, "unable to parse AUDIT_RETENTION_TTL, it must not be negative", "value", auditTTL)
+		os.Exit(1)
+	}
+
+	auditService, err := utils.NewAuditService(os.Getenv("AUDIT_SINK"), controllerID, os.Getenv("POD_NAMESPACE"), int(auditSize), auditTTL, mgr.GetClient(), ctrl.Log.WithName("Audit"))
+	if err != nil {
+		setupLog.Error(err, "unable to create audit service")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.JobReconciler{
 		EventService: eventService,
+		HostJobQueue: hostJobQueue,
 		Client:       mgr.GetClient(),
 		Scheme:       mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
@@ -132,6 +302,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err = (&controllers.AdoptionReconciler{
+		EventService: eventService,
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Adoption")
+		os.Exit(1)
+	}
+
 	nodeReconciler := &controllers.NodeReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
@@ -141,34 +320,194 @@ func main() {
 		os.Exit(1)
 	}
 
+	serviceMonitor, err := parseBoolEnv("SERVICE_MONITOR")
+	if err != nil {
+		setupLog.Error(err, "unable to parse SERVICE_MONITOR")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.DiskConfigReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:           mgr.GetClient(),
+		APIReader:        mgr.GetAPIReader(),
+		Scheme:           mgr.GetScheme(),
+		ServiceMonitor:   serviceMonitor,
+		MetricsNamespace: os.Getenv("POD_NAMESPACE"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "DiskConfig")
 		os.Exit(1)
 	}
 
-	if _, err = (&controllers.PVCReconciler{
-		EventService: eventService,
-		NodeCache:    nodeReconciler,
-		InProgress:   sync.Map{},
-		Client:       mgr.GetClient(),
-		Scheme:       mgr.GetScheme(),
+	if err = (&controllers.ClusterDiskConfigReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterDiskConfig")
+		os.Exit(1)
+	}
+
+	planOnly, err := parseBoolEnv("MONITOR_PLAN_ONLY")
+	if err != nil {
+		setupLog.Error(err, "unable to parse MONITOR_PLAN_ONLY")
+		os.Exit(1)
+	}
+
+	resizeRequests, err := parseBoolEnv("RESIZE_REQUESTS")
+	if err != nil {
+		setupLog.Error(err, "unable to parse RESIZE_REQUESTS")
+		os.Exit(1)
+	}
+
+	fsSizeMismatch, err := parseInt32Env("FS_SIZE_MISMATCH_PERCENTAGE", 0)
+	if err != nil || fsSizeMismatch < 0 || fsSizeMismatch >= 100 {
+		setupLog.Error(err, "unable to parse FS_SIZE_MISMATCH_PERCENTAGE, it must be between 0 and 99", "value", fsSizeMismatch)
+		os.Exit(1)
+	}
+
+	stalenessTolerance, err := parseDurationEnv("METRICS_STALENESS_TOLERANCE", 0)
+	if err != nil || stalenessTolerance < 0 {
+		setupLog.Error(err, "unable to parse METRICS_STALENESS_TOLERANCE, it must not be negative", "value", stalenessTolerance)
+		os.Exit(1)
+	}
+
+	stalenessJitter, err := parseDurationEnv("METRICS_STALENESS_JITTER", 0)
+	if err != nil || stalenessJitter < 0 {
+		setupLog.Error(err, "unable to parse METRICS_STALENESS_JITTER, it must not be negative", "value", stalenessJitter)
+		os.Exit(1)
+	}
+
+	recommenderTimeout, err := parseDurationEnv("CAPACITY_RECOMMENDER_TIMEOUT", utils.DefaultRecommenderTimeout)
+	if err != nil || recommenderTimeout <= 0 {
+		setupLog.Error(err, "unable to parse CAPACITY_RECOMMENDER_TIMEOUT, it must be positive", "value", recommenderTimeout)
+		os.Exit(1)
+	}
+
+	predictionHorizon, err := parseDurationEnv("FILL_RATE_PREDICTION_HORIZON", 0)
+	if err != nil || predictionHorizon < 0 {
+		setupLog.Error(err, "unable to parse FILL_RATE_PREDICTION_HORIZON, it must not be negative", "value", predictionHorizon)
+		os.Exit(1)
+	}
+
+	fillRateSamples, err := parseInt32Env("FILL_RATE_SAMPLES", utils.DefaultFillRateSamples)
+	if err != nil || fillRateSamples < 3 {
+		setupLog.Error(err, "unable to parse FILL_RATE_SAMPLES, it must be at least 3", "value", fillRateSamples)
+		os.Exit(1)
+	}
+
+	// Horizon might be set per DiskConfig, samples are recorded only for disks with a horizon
+	fillRatePredictor := utils.NewFillRatePredictor(int(fillRateSamples))
+
+	sampleHistoryStore, err := utils.NewSampleHistoryStore(os.Getenv("SAMPLE_HISTORY_CONFIGMAP"), os.Getenv("POD_NAMESPACE"), mgr.GetClient(), mgr.GetAPIReader())
+	if err != nil {
+		setupLog.Error(err, "unable to create sample history store")
+		os.Exit(1)
+	}
+
+	kubeClientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		setupLog.Error(err, "unable to create clientset")
+		os.Exit(1)
+	}
+
+	scrapeRetryPeriod, err := parseDurationEnv("SCRAPE_RETRY_PERIOD", 0)
+	if err != nil || scrapeRetryPeriod < 0 {
+		setupLog.Error(err, "unable to parse SCRAPE_RETRY_PERIOD, it must not be negative", "value", scrapeRetryPeriod)
+		os.Exit(1)
+	}
+
+	configFetchBackoff, err := parseDurationEnv("CONFIG_FETCH_BACKOFF", defaultConfigFetchBackoff)
+	if err != nil || configFetchBackoff < 0 {
+		setupLog.Error(err, "unable to parse CONFIG_FETCH_BACKOFF, it must not be negative", "value", configFetchBackoff)
+		os.Exit(1)
+	}
+
+	metricsImageSkipPeriod, err := parseDurationEnv("METRICS_IMAGE_FAILURE_SKIP_PERIOD", 0)
+	if err != nil {
+		setupLog.Error(err, "unable to parse METRICS_IMAGE_FAILURE_SKIP_PERIOD")
+		os.Exit(1)
+	}
+
+	metricsImageTracker, err := utils.NewMetricsImageTracker(metricsImageSkipPeriod)
+	if err != nil {
+		setupLog.Error(err, "unable to create metrics image tracker")
+		os.Exit(1)
+	}
+
+	pvcReconciler := &controllers.PVCReconciler{
+		EventService:               eventService,
+		AuditService:               auditService,
+		NodeCache:                  nodeReconciler,
+		InProgress:                 sync.Map{},
+		PlanOnly:                   planOnly,
+		ResizeRequests:             resizeRequests,
+		FSSizeMismatchPercentage:   float64(fsSizeMismatch),
+		MetricsStalenessTolerance:  stalenessTolerance,
+		MetricsStalenessJitter:     stalenessJitter,
+		CapacityRecommender:        utils.NewCapacityRecommender(os.Getenv("CAPACITY_RECOMMENDER_URL")),
+		CapacityRecommenderTimeout: recommenderTimeout,
+		FillRatePredictor:          fillRatePredictor,
+		PredictionHorizon:          predictionHorizon,
+		SampleHistoryStore:         sampleHistoryStore,
+		HostJobQueue:               hostJobQueue,
+		MetricsImageTracker:        metricsImageTracker,
+		ScrapeRetryPeriod:          scrapeRetryPeriod,
+		ConfigFetchBackoff:         configFetchBackoff,
+		KubeletClient:              kubeClientset.CoreV1().RESTClient(),
+		Client:                     mgr.GetClient(),
+		Scheme:                     mgr.GetScheme(),
+	}
+	if _, err = pvcReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PVC")
 		os.Exit(1)
 	}
 
+	if resizeRequests {
+		if err = (&controllers.VolumeResizeRequestReconciler{
+			Resizer: pvcReconciler,
+			Client:  mgr.GetClient(),
+			Scheme:  mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "VolumeResizeRequest")
+			os.Exit(1)
+		}
+	}
+
 	provisioners := strings.Split(strings.ReplaceAll(os.Getenv("SUPPORTED_CSI_DRIVERS"), " ", ""), ",")
 
-	discoblocksondatiov1.InitDiskConfigWebhookDeps(mgr.GetClient(), provisioners)
+	managedProvisioners := []string{}
+	if raw := strings.ReplaceAll(os.Getenv("MANAGED_PROVISIONERS"), " ", ""); raw != "" {
+		managedProvisioners = strings.Split(raw, ",")
+	}
+
+	provisioners, err = utils.SetManagedProvisioners(managedProvisioners, provisioners)
+	if err != nil {
+		setupLog.Error(err, "unable to parse MANAGED_PROVISIONERS")
+		os.Exit(1)
+	}
+
+	mountPointPrefixes := []string{}
+	if raw := strings.ReplaceAll(os.Getenv("MOUNT_POINT_ALLOWED_PREFIXES"), " ", ""); raw != "" {
+		mountPointPrefixes = strings.Split(raw, ",")
+	}
+
+	discoblocksondatiov1.InitDiskConfigWebhookDeps(mgr.GetClient(), utils.ConfigLabel(), utils.ParentLabel(), utils.ClusterConfigLabel(), provisioners, mountPointPrefixes)
+
+	resizeHookNamespaces := []string{}
+	if raw := strings.ReplaceAll(os.Getenv("RESIZE_HOOK_NAMESPACES"), " ", ""); raw != "" {
+		resizeHookNamespaces = strings.Split(raw, ",")
+	}
+
+	discoblocksondatiov1.SetResizeHookNamespaces(resizeHookNamespaces)
 
 	if err = (&discoblocksondatiov1.DiskConfig{}).SetupWebhookWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create validator", "validator", "DiskConfig")
 		os.Exit(1)
 	}
 
+	if err = (&discoblocksondatiov1.ClusterDiskConfig{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create validator", "validator", "ClusterDiskConfig")
+		os.Exit(1)
+	}
+
 	//+kubebuilder:scaffold:builder
 
 	strictMutator, err := parseBoolEnv("MUTATOR_STRICT_MODE")
@@ -177,7 +516,58 @@ func main() {
 		os.Exit(1)
 	}
 
-	podMutator := mutators.NewPodMutator(mgr.GetClient(), strictMutator)
+	storageClassRetry, err := parseDurationEnv("MUTATOR_STORAGECLASS_RETRY", defaultStorageClassRetry)
+	if err != nil {
+		setupLog.Error(err, "unable to parse MUTATOR_STORAGECLASS_RETRY")
+		os.Exit(1)
+	}
+
+	singleNode, err := parseBoolEnv("SINGLE_NODE_MODE")
+	if err != nil {
+		setupLog.Error(err, "unable to parse SINGLE_NODE_MODE")
+		os.Exit(1)
+	}
+
+	provisionRate, err := parseInt32Env("PVC_CREATION_RATE", 0)
+	if err != nil {
+		setupLog.Error(err, "unable to parse PVC_CREATION_RATE")
+		os.Exit(1)
+	}
+
+	provisionBurst, err := parseInt32Env("PVC_CREATION_BURST", defaultProvisionBurst)
+	if err != nil {
+		setupLog.Error(err, "unable to parse PVC_CREATION_BURST")
+		os.Exit(1)
+	}
+
+	provisionLimiter, err := utils.NewProvisionLimiter(provisionRate, provisionBurst)
+	if err != nil {
+		setupLog.Error(err, "unable to create PVC creation limiter")
+		os.Exit(1)
+	}
+
+	provisionMaxDelay, err := parseDurationEnv("PVC_CREATION_MAX_DELAY", defaultProvisionMaxDelay)
+	if err != nil {
+		setupLog.Error(err, "unable to parse PVC_CREATION_MAX_DELAY")
+		os.Exit(1)
+	}
+
+	if err := mutators.LoadMetricsCerts(mutators.DefaultMetricsCertsDir); err != nil {
+		setupLog.Error(err, "unable to load metrics certificates")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.DeferredPVCReconciler{
+		EventService: eventService,
+		Limiter:      provisionLimiter,
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "DeferredPVC")
+		os.Exit(1)
+	}
+
+	podMutator := mutators.NewPodMutator(mgr.GetClient(), strictMutator, singleNode, resizeRequests, storageClassRetry, provisionLimiter, provisionMaxDelay, metricsImageTracker, eventService)
 	mgr.GetWebhookServer().Register("/mutate-v1-pod", &webhook.Admission{Handler: podMutator})
 
 	if err = mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -196,18 +586,73 @@ func main() {
 		os.Exit(1)
 	}
 
-	scheduler := schedulers.NewScheduler(mgr.GetClient(), strictScheduler)
-	schedulerErrChan := scheduler.Start(context.Background())
-	go func() {
-		setupLog.Error(<-schedulerErrChan, "there was an error in scheduler")
+	if singleNode {
+		setupLog.Info("Single node mode, scheduler is disabled")
+	} else {
+		scheduler := schedulers.NewScheduler(mgr.GetClient(), strictScheduler)
+		schedulerErrChan := scheduler.Start(context.Background())
+		go func() {
+			setupLog.Error(<-schedulerErrChan, "there was an error in scheduler")
+			os.Exit(1)
+		}()
+	}
+
+	otelInsecure, err := parseBoolEnv("OTEL_EXPORTER_OTLP_INSECURE")
+	if err != nil {
+		setupLog.Error(err, "unable to parse OTEL_EXPORTER_OTLP_INSECURE")
+		os.Exit(1)
+	}
+
+	stopOTel, err := metrics.StartOTelExporter(context.Background(), os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"), otelInsecure)
+	if err != nil {
+		setupLog.Error(err, "unable to start OpenTelemetry exporter")
 		os.Exit(1)
-	}()
+	}
+
+	stopMetrics, err := metrics.StartMetricsServer(discoblocksMetricsAddr)
+	if err != nil {
+		setupLog.Error(err, "unable to start metrics server of discoblocks")
+		os.Exit(1)
+	}
 
 	setupLog.Info("Start manager")
 	if err = mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+
+	if err := stopOTel(context.Background()); err != nil {
+		setupLog.Error(err, "unable to stop OpenTelemetry exporter")
+	}
+
+	if err := stopMetrics(context.Background()); err != nil {
+		setupLog.Error(err, "unable to stop metrics server of discoblocks")
+	}
+}
+
+func runSelfTest() int {
+	logger := ctrl.Log.WithName("SelfTest")
+
+	if selftest.NewClient == nil {
+		logger.Error(errors.New("self-test is not built in"), "Build the manager with selftest build tag to run self-test")
+		return 1
+	}
+
+	results, err := selftest.Run(context.Background(), selftest.NewClient(), selftest.DefaultConfig())
+	for _, result := range results {
+		if result.Error != nil {
+			logger.Error(result.Error, "Stage failed", "stage", result.Stage)
+			continue
+		}
+
+		logger.Info("Stage passed", "stage", result.Stage)
+	}
+
+	if err != nil {
+		return 1
+	}
+
+	return 0
 }
 
 func parseBoolEnv(key string) (bool, error) {
@@ -218,3 +663,22 @@ func parseBoolEnv(key string) (bool, error) {
 
 	return false, nil
 }
+
+func parseInt32Env(key string, def int32) (int32, error) {
+	raw := os.Getenv(key)
+	if raw != "" {
+		value, err := strconv.ParseInt(raw, 10, 32)
+		return int32(value), err
+	}
+
+	return def, nil
+}
+
+func parseDurationEnv(key string, def time.Duration) (time.Duration, error) {
+	raw := os.Getenv(key)
+	if raw != "" {
+		return time.ParseDuration(raw)
+	}
+
+	return def, nil
+}
@@ -6,19 +6,23 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/moby/moby/pkg/namesgenerator"
 	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
 	"github.com/ondat/discoblocks/pkg/drivers"
 	"github.com/ondat/discoblocks/pkg/metrics"
 	"github.com/ondat/discoblocks/pkg/utils"
+	"github.com/ondat/discoblocks/schedulers"
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/selection"
@@ -29,20 +33,22 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
-var (
-	CACert     = "/tmp/k8s-webhook-server/metrics-certs/ca.crt"
-	ServerCert = "/tmp/k8s-webhook-server/metrics-certs/tls.crt"
-	ServerKey  = "/tmp/k8s-webhook-server/metrics-certs/tls.key"
+// DefaultMetricsCertsDir is the directory of metrics certificates mounted into the operator
+const DefaultMetricsCertsDir = "/tmp/k8s-webhook-server/metrics-certs"
 
-	caCert     []byte
-	serverCert []byte
-	serverKey  []byte
-)
+// metricsCerts reloads certificates on rotation
+var metricsCerts *utils.FileCache
+
+// LoadMetricsCerts loads CA, certificate and key of metrics sidecars from the directory, files are reloaded on rotation
+func LoadMetricsCerts(dir string) error {
+	certs := utils.NewFileCache(filepath.Join(dir, "ca.crt"), filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"))
+	if _, err := certs.Get(); err != nil {
+		return fmt.Errorf("unable to load metrics certificates: %w", err)
+	}
+
+	metricsCerts = certs
 
-func init() {
-	caCert = utils.ReadFileOrDie(CACert)
-	serverCert = utils.ReadFileOrDie(ServerCert)
-	serverKey = utils.ReadFileOrDie(ServerKey)
+	return nil
 }
 
 // log is for logging in this package
@@ -50,10 +56,23 @@ var podMutatorLog = logf.Log.WithName("mutators.PodMutator")
 
 var _ admission.Handler = &PodMutator{}
 
+// storageClassRetryInterval is the wait between StorageClass lookups
+const storageClassRetryInterval = 500 * time.Millisecond
+
 type PodMutator struct {
-	Client  client.Client
-	strict  bool
-	decoder *admission.Decoder
+	Client            client.Client
+	strict            bool
+	singleNode        bool
+	storageClassRetry time.Duration
+	provisionLimiter  *utils.ProvisionLimiter
+	provisionMaxDelay time.Duration
+	// resizeRequests hands capacity drift of existing PVCs over to resize requests, otherwise drift is only reported
+	resizeRequests bool
+	// metricsImageTracker skips injection of metrics sidecars after pull failures of their images, nil always injects
+	metricsImageTracker *utils.MetricsImageTracker
+	// eventService reports rejected configs, nil sends no events
+	eventService utils.EventService
+	decoder      *admission.Decoder
 }
 
 //+kubebuilder:webhook:path=/mutate-v1-pod,mutating=true,sideEffects=NoneOnDryRun,failurePolicy=fail,groups="",resources=pods,verbs=create,versions=v1,admissionReviewVersions=v1,name=mpod.kb.io
@@ -93,6 +112,11 @@ func (a *PodMutator) Handle(ctx context.Context, req admission.Request) admissio
 		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("unable to fetch DiskConfigs: %w", err))
 	}
 
+	if err := a.applyClusterDiskConfigs(ctx, pod.Namespace, req.DryRun != nil && *req.DryRun, &diskConfigs, logger); err != nil {
+		logger.Info("Unable to apply ClusterDiskConfigs", "error", err.Error())
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
 	if len(diskConfigs.Items) == 0 {
 		return admission.Allowed("DiskConfig not found in namespace: " + pod.Namespace)
 	}
@@ -107,15 +131,34 @@ func (a *PodMutator) Handle(ctx context.Context, req admission.Request) admissio
 
 	nodeName := utils.GetTargetNodeByAffinity(pod.Spec.Affinity)
 
+	// Without custom scheduler nobody else selects node of volumes
+	if a.singleNode && nodeName == "" {
+		singleNode, err := utils.GetSingleNode(ctx, a.Client)
+		if err != nil {
+			metrics.NewError("Node", "", "", "Kube API", "list")
+
+			msg := "Unable to find the node of single node cluster"
+			logger.Info(msg, "error", err.Error())
+			return errorMode(http.StatusInternalServerError, msg, fmt.Errorf("unable to find single node: %w", err))
+		}
+
+		nodeName = singleNode
+	}
+
 	logger = logger.WithValues("node_name", nodeName)
 
 	diskConfigTypes := map[discoblocksondatiov1.AvailabilityMode]bool{}
 
 	volumes := map[string]string{}
+	propagations := map[string]*corev1.MountPropagationMode{}
+	warnings := []string{}
+	// Kubelet reports usage of volumes without metrics sidecars
+	sidecarRequired := false
+	privilegedMetrics := false
 	for i := range diskConfigs.Items {
 		if diskConfigs.Items[i].DeletionTimestamp != nil {
 			continue
-		} else if !utils.IsContainsAll(pod.Labels, diskConfigs.Items[i].Spec.PodSelector) {
+		} else if !utils.IsPodSelected(pod.Labels, diskConfigs.Items[i].Spec.PodSelector) {
 			continue
 		}
 
@@ -171,18 +214,38 @@ func (a *PodMutator) Handle(ctx context.Context, req admission.Request) admissio
 		logger.Info("Fetch StorageClass...")
 
 		sc := storagev1.StorageClass{}
-		if err := a.Client.Get(ctx, types.NamespacedName{Name: config.Spec.StorageClassName}, &sc); err != nil {
+		if err := utils.GetWithRetry(ctx, a.Client, types.NamespacedName{Name: config.Spec.StorageClassName}, &sc, a.storageClassRetry, storageClassRetryInterval); err != nil {
 			metrics.NewError("StorageClass", config.Spec.StorageClassName, "", "Kube API", "get")
 
 			if apierrors.IsNotFound(err) {
-				logger.Info("StorageClass not found", "name", config.Spec.StorageClassName)
-				return admission.Errored(http.StatusNotFound, err)
+				msg := fmt.Sprintf("StorageClass not found: %s", config.Spec.StorageClassName)
+				logger.Info(msg)
+				return errorMode(http.StatusNotFound, msg, err)
 			}
 			logger.Info("Unable to fetch StorageClass", "error", err.Error())
 			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("unable to fetch StorageClass: %w", err))
 		}
 		logger = logger.WithValues("provisioner", sc.Provisioner)
 
+		if !utils.IsProvisionerManaged(sc.Provisioner) {
+			msg := fmt.Sprintf("Provisioner %s of StorageClass %s is not managed by Discoblocks", sc.Provisioner, sc.Name)
+			logger.Info(msg)
+			return errorMode(http.StatusBadRequest, msg, errors.New(strings.ToLower(msg)))
+		}
+
+		// Drivers validate the StorageClass only on DiskConfig admission, it might have been replaced since
+		if !utils.IsStorageClassExpandable(&sc) {
+			msg := fmt.Sprintf("StorageClass %s doesn't allow volume expansion, disks of %s can't be resized", sc.Name, config.Name)
+			logger.Info(msg)
+
+			if a.eventService != nil {
+				if err := a.eventService.SendWarning(config.Namespace, "Discoblocks", "Pod Admission", fmt.Sprintf("StorageClass of %s is not expandable: %s", config.Name, sc.Name), "Set allowVolumeExpansion of the StorageClass to enable autoscaling", &config, &sc); err != nil {
+					logger.Error(err, "Failed to create event")
+				}
+			}
+			return errorMode(http.StatusBadRequest, msg, errors.New(msg))
+		}
+
 		driver := drivers.GetDriver(sc.Provisioner)
 		if driver == nil {
 			metrics.NewError("CSI", sc.Provisioner, "", sc.Provisioner, "GetDriver")
@@ -192,6 +255,15 @@ func (a *PodMutator) Handle(ctx context.Context, req admission.Request) admissio
 			return errorMode(http.StatusInternalServerError, msg, fmt.Errorf("driver not found: %s", sc.Provisioner))
 		}
 
+		local, err := driver.IsNodeLocal()
+		if err != nil {
+			metrics.NewError("CSI", "", "", sc.Provisioner, "IsNodeLocal")
+
+			msg := fmt.Sprintf("Failed to call IsNodeLocal: %s", err.Error())
+			logger.Info(msg)
+			return errorMode(http.StatusInternalServerError, msg, fmt.Errorf("failed to call IsNodeLocal: %s", err.Error()))
+		}
+
 		logger.Info("Attach volume to workload...")
 
 		prefix := utils.GetNamePrefix(config.Spec.AvailabilityMode, string(config.UID), nodeName)
@@ -215,75 +287,205 @@ func (a *PodMutator) Handle(ctx context.Context, req admission.Request) admissio
 
 		utils.PVCDecorator(&config, prefix, driver, pvc)
 
+		for k, v := range utils.RenderOwnerLabels(&pod, config.Spec.AvailabilityMode == discoblocksondatiov1.ReadWriteOnce) {
+			pvc.Labels[k] = v
+		}
+
 		pvcNamesWithMount := map[string]string{
-			pvc.Name: utils.RenderMountPoint(config.Spec.MountPointPattern, pvc.Name, 0),
+			pvc.Name: utils.RenderDiskMountPoint(&config, pvc.Name, 0),
 		}
 
 		if req.DryRun == nil || !*req.DryRun {
-			if nodeName != "" {
-				logger.Info("Fetch Node...")
+			logger.Info("Fetch PVC...")
+
+			existingPVC := corev1.PersistentVolumeClaim{}
+			err = a.Client.Get(ctx, types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace}, &existingPVC)
+			if err != nil && !apierrors.IsNotFound(err) {
+				metrics.NewError("PersistentVolumeClaim", pvc.Name, pvc.Namespace, "Kube API", "get")
 
-				node := &corev1.Node{}
-				if err := a.Client.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
-					metrics.NewError("Node", nodeName, "", "Kube API", "get")
+				logger.Error(err, "Unable to fetch PVC", "name", pvc.Name)
+				return admission.Errored(http.StatusInternalServerError, fmt.Errorf("unable to fetch PVC %s: %w", pvc.Name, err))
+			}
+			exists := err == nil
 
-					return admission.Errored(http.StatusInternalServerError, err)
+			// Local volumes can't follow the Pod, so the Pod follows the node recorded on the volume
+			if local {
+				var recordedPVC *corev1.PersistentVolumeClaim
+				if exists {
+					recordedPVC = &existingPVC
 				}
 
-				scAllowedTopology, err := driver.GetStorageClassAllowedTopology(node)
+				localNode, err := utils.ResolveLocalVolumeNode(recordedPVC, nodeName)
 				if err != nil {
-					metrics.NewError("CSI", node.Name, "", sc.Provisioner, "GetStorageClassAllowedTopology")
-
-					msg := fmt.Sprintf("Failed to get GetStorageClassAllowedTopology: %s", err.Error())
+					msg := fmt.Sprintf("Unable to find node of local volume: %s", err.Error())
 					logger.Info(msg)
-					return errorMode(http.StatusInternalServerError, msg, fmt.Errorf("failed to get GetStorageClassAllowedTopology: %s", err.Error()))
+					return errorMode(http.StatusBadRequest, msg, fmt.Errorf("unable to find node of local volume: %w", err))
 				}
 
-				if len(scAllowedTopology) != 0 {
-					topologySC, err := utils.NewStorageClass(&sc, scAllowedTopology)
+				nodeName = localNode
+				utils.SetNodeAffinity(&pod, nodeName)
+			}
+
+			// Pooled PVCs are provisioned ahead, claiming one skips provisioning of the first disk
+			claimed := false
+			if !exists && !local && config.Spec.AvailabilityMode == discoblocksondatiov1.ReadWriteOnce && config.Spec.Policy.PoolSize > 0 {
+				logger.Info("Claim pooled PVC...")
+
+				pooledPVC, err := utils.ClaimFromPool(ctx, a.Client, &config, utils.RenderOwnerLabels(&pod, true), nodeName)
+				if err != nil {
+					metrics.NewError("PersistentVolumeClaim", "", config.Namespace, "Kube API", "update")
+
+					logger.Info("Failed to claim pooled PVC, fallback to provisioning", "error", err.Error())
+				} else if pooledPVC != nil {
+					claimed = true
+
+					delete(pvcNamesWithMount, pvc.Name)
+					pvc = pooledPVC
+					pvcNamesWithMount[pvc.Name] = utils.RenderDiskMountPoint(&config, pvc.Name, 0)
+
+					logger.Info("Pooled PVC claimed", "name", pvc.Name)
+					metrics.NewPVCOperation(pvc.Name, pvc.Namespace, "claim", config.Spec.Capacity.String())
+
+					logger.Info("Create initial PVCs...", "number", config.Spec.Policy.InitialNumberOfDisks)
+
+					initialPVCs, err := utils.CreateInitialPVCs(ctx, a.Client, &config, pvc)
 					if err != nil {
-						msg := fmt.Sprintf("Failed to get NewStorageClass: %s", err.Error())
-						logger.Error(err, msg)
-						return errorMode(http.StatusInternalServerError, msg, fmt.Errorf("failed to get NewStorageClass: %s", err.Error()))
+						metrics.NewError("PersistentVolumeClaim", pvc.Name, pvc.Namespace, "Kube API", "create")
+
+						logger.Info("Failed to create initial PVCs", "error", err.Error())
+						return admission.Errored(http.StatusInternalServerError, fmt.Errorf("unable to create initial PVCs: %w", err))
 					}
 
-					logger.Info("Create StorageClass...")
+					for name, mountPoint := range initialPVCs {
+						metrics.NewPVCOperation(name, pvc.Namespace, "create", config.Spec.Capacity.String())
 
-					if err = a.Client.Create(ctx, topologySC); err != nil && !apierrors.IsAlreadyExists(err) {
-						metrics.NewError("StorageClass", topologySC.Name, "", "Kube API", "create")
+						pvcNamesWithMount[name] = mountPoint
+					}
+				}
+			}
+
+			// Shared PVCs are created by the first Pod only, others skip StorageClass and PVC creation
+			if !exists && !claimed {
+				if nodeName != "" {
+					logger.Info("Fetch Node...")
+
+					node := &corev1.Node{}
+					if err := a.Client.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+						metrics.NewError("Node", nodeName, "", "Kube API", "get")
 
 						return admission.Errored(http.StatusInternalServerError, err)
 					}
 
-					pvc.Spec.StorageClassName = &topologySC.Name
+					scAllowedTopology, err := driver.GetStorageClassAllowedTopology(node)
+					if err != nil {
+						metrics.NewError("CSI", node.Name, "", sc.Provisioner, "GetStorageClassAllowedTopology")
+
+						msg := fmt.Sprintf("Failed to get GetStorageClassAllowedTopology: %s", err.Error())
+						logger.Info(msg)
+						return errorMode(http.StatusInternalServerError, msg, fmt.Errorf("failed to get GetStorageClassAllowedTopology: %s", err.Error()))
+					}
+
+					if len(scAllowedTopology) != 0 {
+						topologySC, err := utils.NewStorageClass(&sc, scAllowedTopology)
+						if err != nil {
+							msg := fmt.Sprintf("Failed to get NewStorageClass: %s", err.Error())
+							logger.Error(err, msg)
+							return errorMode(http.StatusInternalServerError, msg, fmt.Errorf("failed to get NewStorageClass: %s", err.Error()))
+						}
+
+						logger.Info("Create StorageClass...")
+
+						if err = a.Client.Create(ctx, topologySC); err != nil && !apierrors.IsAlreadyExists(err) {
+							metrics.NewError("StorageClass", topologySC.Name, "", "Kube API", "create")
+
+							return admission.Errored(http.StatusInternalServerError, err)
+						}
+
+						pvc.Spec.StorageClassName = &topologySC.Name
+					}
 				}
-			}
 
-			logger.Info("Create PVC...")
+				if a.singleNode || local {
+					utils.SetSelectedNode(pvc, nodeName)
+				}
 
-			if err = a.Client.Create(ctx, pvc); err != nil {
-				if !apierrors.IsAlreadyExists(err) {
-					metrics.NewError("PersistentVolume", pvc.Name, pvc.Namespace, "Kube API", "create")
+				logger.Info("Create PVC...")
 
-					logger.Info("Failed to create PVC", "error", err.Error())
-					return admission.Errored(http.StatusInternalServerError, fmt.Errorf("unable to create PVC: %w", err))
+				deferred := false
+				if !a.provisionLimiter.Allow() {
+					if !a.strict {
+						deferred = true
+					} else if err := a.provisionLimiter.Wait(ctx, a.provisionMaxDelay); err != nil {
+						logger.Info("PVC provisioning rate exceeded", "error", err.Error())
+						return admission.Errored(http.StatusTooManyRequests, fmt.Errorf("unable to create PVC: %w", err))
+					}
 				}
 
-				logger.Info("PVC already exists")
+				if deferred {
+					_, initialPVCs, err := utils.RenderInitialPVCs(&config, pvc)
+					if err != nil {
+						msg := "Failed to render initial PVCs"
+						logger.Error(err, msg)
+						return errorMode(http.StatusInternalServerError, msg, fmt.Errorf("failed to render initial PVCs: %w", err))
+					}
 
-				finalizer := utils.RenderFinalizer(config.Name)
+					for name, mountPoint := range initialPVCs {
+						pvcNamesWithMount[name] = mountPoint
+					}
+
+					logger.Info("PVC provisioning rate exceeded, defer creation of PVCs")
+
+					if err := utils.AddDeferredPVC(&pod, config.Name, pvc); err != nil {
+						msg := "Failed to defer PVC"
+						logger.Error(err, msg)
+						return errorMode(http.StatusInternalServerError, msg, fmt.Errorf("failed to defer PVC: %w", err))
+					}
+				} else {
+					created, err := utils.CreateOrGetPVC(ctx, a.Client, pvc, &existingPVC, config.Spec.VolumeAttributesClassName)
+					if err != nil {
+						metrics.NewError("PersistentVolumeClaim", pvc.Name, pvc.Namespace, "Kube API", "create")
+
+						logger.Info("Failed to create PVC", "error", err.Error())
+						return admission.Errored(http.StatusInternalServerError, fmt.Errorf("unable to create PVC: %w", err))
+					}
+					exists = !created
+
+					if created {
+						metrics.NewPVCOperation(pvc.Name, pvc.Namespace, "create", config.Spec.Capacity.String())
 
-				logger.Info("Fetch PVC...")
+						logger.Info("Create initial PVCs...", "number", config.Spec.Policy.InitialNumberOfDisks)
 
-				if err = a.Client.Get(ctx, types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace}, pvc); err != nil {
-					metrics.NewError("PersistentVolumeClaim", pvc.Name, pvc.Namespace, "Kube API", "get")
+						initialPVCs, err := utils.CreateInitialPVCs(ctx, a.Client, &config, pvc)
+						if err != nil {
+							metrics.NewError("PersistentVolumeClaim", pvc.Name, pvc.Namespace, "Kube API", "create")
 
-					logger.Error(err, "Unable to fetch PVC", "name", pvc.Name)
-					return admission.Errored(http.StatusInternalServerError, fmt.Errorf("unable to fetch PVC %s: %w", pvc.Name, err))
+							logger.Info("Failed to create initial PVCs", "error", err.Error())
+							return admission.Errored(http.StatusInternalServerError, fmt.Errorf("unable to create initial PVCs: %w", err))
+						}
+
+						for name, mountPoint := range initialPVCs {
+							metrics.NewPVCOperation(name, pvc.Namespace, "create", config.Spec.Capacity.String())
+
+							pvcNamesWithMount[name] = mountPoint
+						}
+					}
 				}
+			}
+
+			if exists {
+				logger.Info("PVC already exists")
 
-				if !controllerutil.ContainsFinalizer(pvc, finalizer) {
+				desiredPVC := pvc
+				pvc = &existingPVC
+
+				finalizer := utils.RenderFinalizer(config.Name)
+
+				// Reuse ends the detach grace period, the update also fails the deletion precondition of a concurrent reclaim
+				_, detached := pvc.Annotations[utils.DetachedAtAnnotation()]
+
+				if !controllerutil.ContainsFinalizer(pvc, finalizer) || detached {
 					controllerutil.AddFinalizer(pvc, finalizer)
+					delete(pvc.Annotations, utils.DetachedAtAnnotation())
 
 					logger.Info("Update PVC finalizer...", "name", pvc.Name)
 
@@ -295,10 +497,48 @@ func (a *PodMutator) Handle(ctx context.Context, req admission.Request) admissio
 					}
 				}
 
+				// Config changes don't reach existing disks, drift is reconciled or reported instead of silent reuse
+				storageClassDrift, capacityDrift := utils.DetectPVCDrift(pvc, desiredPVC, &sc)
+				if storageClassDrift {
+					msg := fmt.Sprintf("StorageClass of existing PVC %s differs from DiskConfig %s: %s", pvc.Name, config.Name, sc.Name)
+					logger.Info(msg)
+
+					if a.strict {
+						return admission.Errored(http.StatusConflict, errors.New(msg))
+					}
+
+					warnings = append(warnings, msg)
+				}
+
+				// Resize is applied by the resize request controller once the Pod is scheduled, with the checks of volume monitor
+				if capacityDrift && !a.resizeRequests {
+					capacity := desiredPVC.Spec.Resources.Requests[corev1.ResourceStorage]
+
+					msg := fmt.Sprintf("Capacity of existing PVC %s is below DiskConfig %s: %s, enable resize requests to resize it", pvc.Name, config.Name, capacity.String())
+					logger.Info(msg)
+
+					warnings = append(warnings, msg)
+				} else if capacityDrift {
+					capacity := desiredPVC.Spec.Resources.Requests[corev1.ResourceStorage]
+
+					if err := a.requestCapacity(ctx, &config, &pod, pvc, capacity, logger); err != nil {
+						msg := fmt.Sprintf("Unable to request capacity %s of existing PVC %s", capacity.String(), pvc.Name)
+						logger.Info(msg, "error", err.Error())
+
+						if a.strict {
+							return admission.Errored(http.StatusInternalServerError, fmt.Errorf("unable to request PVC capacity %s: %w", pvc.Name, err))
+						}
+
+						warnings = append(warnings, msg)
+					} else {
+						warnings = append(warnings, fmt.Sprintf("Capacity of existing PVC %s is below DiskConfig %s, resize to %s is requested", pvc.Name, config.Name, capacity.String()))
+					}
+				}
+
 				if config.Spec.AvailabilityMode != discoblocksondatiov1.ReadWriteOnce {
-					label, err := labels.NewRequirement("discoblocks-parent", selection.Equals, []string{pvc.Name})
+					label, err := labels.NewRequirement(utils.ParentLabel(), selection.Equals, []string{pvc.Name})
 					if err != nil {
-						msg := fmt.Sprintf("Unable to parse PVC label selectors: discoblocks-parent=%s", pvc.Name)
+						msg := fmt.Sprintf("Unable to parse PVC label selectors: %s=%s", utils.ParentLabel(), pvc.Name)
 						logger.Error(err, msg)
 						return errorMode(http.StatusInternalServerError, msg, fmt.Errorf("unable to parse PVC label selectors: %w", err))
 					}
@@ -339,17 +579,17 @@ func (a *PodMutator) Handle(ctx context.Context, req admission.Request) admissio
 							}
 						}
 
-						if _, ok := pvcs.Items[i].Labels["discoblocks-index"]; !ok {
+						if _, ok := pvcs.Items[i].Labels[utils.IndexLabel()]; !ok {
 							err = errors.New("volume index not found")
 							logger.Error(err, "Volume index not found")
 							return errorMode(http.StatusInternalServerError, "Volume index not found", err)
 						}
 
-						index, err := strconv.Atoi(pvcs.Items[i].Labels["discoblocks-index"])
+						index, err := strconv.Atoi(pvcs.Items[i].Labels[utils.IndexLabel()])
 						if err != nil {
 							metrics.NewError("PersistentVolumeClaim", pvcs.Items[i].Name, pvcs.Items[i].Namespace, "DiscoBlocks", "")
 
-							msg := fmt.Sprintf("Unable to convert index: %s", pvcs.Items[i].Labels["discoblocks-index"])
+							msg := fmt.Sprintf("Unable to convert index: %s", pvcs.Items[i].Labels[utils.IndexLabel()])
 							logger.Error(err, msg)
 							return errorMode(http.StatusInternalServerError, msg, fmt.Errorf("unable to convert index: %w", err))
 						}
@@ -357,13 +597,12 @@ func (a *PodMutator) Handle(ctx context.Context, req admission.Request) admissio
 						c := pvcs.Items[i].Spec.Resources.Requests[corev1.ResourceStorage]
 						metrics.NewPVCOperation(pvcs.Items[i].Name, pvcs.Items[i].Namespace, "reuse", c.String())
 
-						pvcNamesWithMount[pvcs.Items[i].Name] = utils.RenderMountPoint(config.Spec.MountPointPattern, pvcs.Items[i].Name, index)
+						pvcNamesWithMount[pvcs.Items[i].Name] = utils.RenderDiskMountPoint(&config, pvcs.Items[i].Name, index)
 
 						logger.Info("Volume found", "pvc_name", pvcs.Items[i].Name, "mountpoint", pvcNamesWithMount[pvcs.Items[i].Name])
 					}
 				}
 			}
-			metrics.NewPVCOperation(pvc.Name, pvc.Namespace, "create", config.Spec.Capacity.String())
 		}
 
 		for pvcName, mountpoint := range pvcNamesWithMount {
@@ -375,6 +614,7 @@ func (a *PodMutator) Handle(ctx context.Context, req admission.Request) admissio
 			}
 
 			volumes[pvcName] = mountpoint
+			propagations[pvcName] = config.Spec.MountPropagation
 
 			pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
 				Name: pvcName,
@@ -385,56 +625,107 @@ func (a *PodMutator) Handle(ctx context.Context, req admission.Request) admissio
 				},
 			})
 		}
+
+		volumeNames := make([]string, 0, len(pvcNamesWithMount))
+		for pvcName := range pvcNamesWithMount {
+			volumeNames = append(volumeNames, pvcName)
+		}
+		sort.Strings(volumeNames)
+
+		utils.AppendPodVolumesAnnotations(&pod, config.Spec.PodVolumesAnnotations, volumeNames)
+
+		if config.Spec.MetricsSource != discoblocksondatiov1.MetricsSourceKubelet {
+			sidecarRequired = true
+			privilegedMetrics = privilegedMetrics || config.Spec.PrivilegedMetrics
+		}
 	}
 
 	if len(volumes) == 0 {
 		return admission.Allowed("No sidecar injection")
 	}
 
-	pod.Spec.SchedulerName = "discoblocks-scheduler"
+	if !a.singleNode {
+		pod.Spec.SchedulerName = schedulers.SchedulerName
+	}
 
-	logger.Info("Attach sidecar...")
+	f := false
 
-	metricsSideCar, err := utils.RenderMetricsSidecar()
-	if err != nil {
-		logger.Error(err, "Metrics sidecar template invalid")
-		return admission.Allowed("Metrics sidecar template invalid")
+	var metricsSideCar, metricsProxySideCar *corev1.Container
+	var metricsPort int32
+	var err error
+
+	if sidecarRequired {
+		metricsPort, err = utils.SelectMetricsPort(&pod)
+		if err != nil {
+			logger.Error(err, "Metrics port not available")
+			return admission.Allowed("Metrics port not available")
+		}
+
+		metricsSideCar, err = utils.RenderMetricsSidecar(metricsPort, privilegedMetrics)
+		if err != nil {
+			logger.Error(err, "Metrics sidecar template invalid")
+			return admission.Allowed("Metrics sidecar template invalid")
+		}
+
+		metricsProxySideCar, err = utils.RenderMetricsProxySidecar(pod.Name, pod.Namespace, metricsPort)
+		if err != nil {
+			logger.Error(err, "Metrics Proxy sidecar template invalid")
+			return admission.Allowed("Metrics Proxy sidecar template invalid")
+		}
+
+		if !a.metricsImageTracker.IsAvailable(pod.Namespace, []string{metricsSideCar.Image, metricsProxySideCar.Image}, time.Now()) {
+			msg := "Metrics sidecar image is unavailable, Pod is admitted without autoscaling"
+			logger.Info(msg)
+			warnings = append(warnings, msg)
+
+			sidecarRequired = false
+		}
 	}
-	pod.Spec.Containers = append(pod.Spec.Containers, *metricsSideCar)
-
-	for _, vm := range metricsSideCar.VolumeMounts {
-		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
-			Name: vm.Name,
-			VolumeSource: corev1.VolumeSource{
-				HostPath: &corev1.HostPathVolumeSource{
-					Path: vm.MountPath,
+
+	if sidecarRequired {
+		logger.Info("Attach sidecar...")
+
+		if metricsPort != utils.DefaultMetricsPort {
+			logger.Info("Metrics port is in use, alternative port selected", "port", metricsPort)
+		}
+
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[utils.MetricsPortAnnotation()] = strconv.Itoa(int(metricsPort))
+
+		pod.Spec.Containers = append(pod.Spec.Containers, *metricsSideCar)
+
+		for _, vm := range metricsSideCar.VolumeMounts {
+			pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+				Name: vm.Name,
+				VolumeSource: corev1.VolumeSource{
+					HostPath: &corev1.HostPathVolumeSource{
+						Path: vm.MountPath,
+					},
 				},
-			},
-		})
-	}
+			})
+		}
 
-	metricsProxySideCar, err := utils.RenderMetricsProxySidecar(pod.Name, pod.Namespace)
-	if err != nil {
-		logger.Error(err, "Metrics Proxy sidecar template invalid")
-		return admission.Allowed("Metrics Proxy sidecar template invalid")
-	}
-	pod.Spec.Containers = append(pod.Spec.Containers, *metricsProxySideCar)
+		pod.Spec.Containers = append(pod.Spec.Containers, *metricsProxySideCar)
 
-	const fht = 420
-	var m int32 = fht
-	f := false
+		utils.AddImagePullSecrets(&pod.Spec)
 
-	for _, vm := range metricsProxySideCar.VolumeMounts {
-		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
-			Name: vm.Name,
-			VolumeSource: corev1.VolumeSource{
-				Secret: &corev1.SecretVolumeSource{
-					SecretName:  vm.Name,
-					DefaultMode: &m,
-					Optional:    &f,
+		const fht = 420
+		var m int32 = fht
+
+		for _, vm := range metricsProxySideCar.VolumeMounts {
+			pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+				Name: vm.Name,
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{
+						SecretName:  vm.Name,
+						DefaultMode: &m,
+						Optional:    &f,
+					},
 				},
-			},
-		})
+			})
+		}
 	}
 
 	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
@@ -458,35 +749,62 @@ func (a *PodMutator) Handle(ctx context.Context, req admission.Request) admissio
 		}
 
 		for name, mp := range volumes {
-			pod.Spec.Containers[i].VolumeMounts = append(pod.Spec.Containers[i].VolumeMounts, corev1.VolumeMount{
-				Name:      name,
-				MountPath: mp,
-			})
+			propagation := propagations[name]
+			if pod.Spec.Containers[i].Name == "discoblocks-metrics" {
+				propagation = nil
+			}
+
+			volumeMount, err := utils.RenderVolumeMount(&pod.Spec.Containers[i], name, mp, propagation)
+			if err != nil {
+				msg := fmt.Sprintf("Invalid mount propagation of %s", name)
+				logger.Info(msg, "error", err.Error())
+				return errorMode(http.StatusBadRequest, msg, err)
+			}
+
+			pod.Spec.Containers[i].VolumeMounts = append(pod.Spec.Containers[i].VolumeMounts, volumeMount)
 		}
 	}
 
-	metricsCert := corev1.Secret{
-		ObjectMeta: v1.ObjectMeta{
-			Name:      "discoblocks-metrics-cert",
-			Namespace: pod.Namespace,
-		},
-		Type: corev1.SecretTypeOpaque,
-		Data: map[string][]byte{
-			"ca.crt":  caCert,
-			"tls.crt": serverCert,
-			"tls.key": serverKey,
-		},
-		Immutable: &f,
+	volumesAnnotation, err := utils.RenderVolumesAnnotation(volumes)
+	if err != nil {
+		logger.Info("Unable to render volumes annotation", "error", err.Error())
+	} else {
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[utils.VolumesAnnotation()] = volumesAnnotation
 	}
 
-	logger.Info("Create certificate secret...")
+	if sidecarRequired {
+		certs, err := metricsCerts.Get()
+		if err != nil {
+			metrics.NewError("Secret", "discoblocks-metrics-cert", pod.Namespace, "DiscoBlocks", "read")
+
+			logger.Info("Failed to read metrics certificates", "error", err.Error())
+			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("unable to read metrics certificates: %w", err))
+		}
+
+		metricsCert := corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "discoblocks-metrics-cert",
+				Namespace: pod.Namespace,
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{
+				"ca.crt":  certs[0],
+				"tls.crt": certs[1],
+				"tls.key": certs[2],
+			},
+			Immutable: &f,
+		}
+
+		logger.Info("Apply certificate secret...")
 
-	if err := a.Client.Create(ctx, &metricsCert); err != nil {
-		if !apierrors.IsAlreadyExists(err) {
-			metrics.NewError("Secret", metricsCert.Name, metricsCert.Namespace, "Kube API", "create")
+		if err := utils.ApplySecret(ctx, a.Client, &metricsCert); err != nil {
+			metrics.NewError("Secret", metricsCert.Name, metricsCert.Namespace, "Kube API", "apply")
 
-			logger.Info("Failed to create Secret", "error", err.Error())
-			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("unable to create Secret: %w", err))
+			logger.Info("Failed to apply Secret", "error", err.Error())
+			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("unable to apply Secret: %w", err))
 		}
 	}
 
@@ -498,7 +816,129 @@ func (a *PodMutator) Handle(ctx context.Context, req admission.Request) admissio
 		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("unable to marshal pod: %w", err))
 	}
 
-	return admission.PatchResponseFromRaw(req.Object.Raw, marshaledPod)
+	resp := admission.PatchResponseFromRaw(req.Object.Raw, marshaledPod)
+	if len(warnings) != 0 {
+		resp.Warnings = warnings
+	}
+
+	return resp
+}
+
+// applyClusterDiskConfigs renders missing DiskConfigs of selecting ClusterDiskConfigs, namespaced ones take precedence.
+// Dry run renders them in memory only, webhook has no side effects on dry run.
+func (a *PodMutator) applyClusterDiskConfigs(ctx context.Context, namespace string, dryRun bool, diskConfigs *discoblocksondatiov1.DiskConfigList, logger logr.Logger) error {
+	logger.Info("Fetch ClusterDiskConfigs...")
+
+	clusterConfigs := discoblocksondatiov1.ClusterDiskConfigList{}
+	if err := a.Client.List(ctx, &clusterConfigs); err != nil {
+		metrics.NewError("ClusterDiskConfig", "", "", "Kube API", "list")
+
+		return fmt.Errorf("unable to fetch ClusterDiskConfigs: %w", err)
+	}
+
+	if len(clusterConfigs.Items) == 0 {
+		return nil
+	}
+
+	logger.Info("Fetch Namespace...")
+
+	ns := corev1.Namespace{}
+	if err := a.Client.Get(ctx, types.NamespacedName{Name: namespace}, &ns); err != nil {
+		metrics.NewError("Namespace", namespace, "", "Kube API", "get")
+
+		return fmt.Errorf("unable to fetch Namespace: %w", err)
+	}
+
+	existing := map[string]bool{}
+	for i := range diskConfigs.Items {
+		existing[diskConfigs.Items[i].Name] = true
+	}
+
+	for i := range clusterConfigs.Items {
+		clusterConfig := clusterConfigs.Items[i]
+
+		logger := logger.WithValues("cdc_name", clusterConfig.Name)
+
+		if clusterConfig.DeletionTimestamp != nil || existing[clusterConfig.Name] {
+			continue
+		}
+
+		// A broken selector must not block Pod creation of the whole cluster, the config is skipped and reported
+		selected, err := utils.IsNamespaceSelected(&clusterConfig, &ns)
+		if err != nil {
+			metrics.NewError("ClusterDiskConfig", clusterConfig.Name, "", "DiscoBlocks", "select")
+
+			logger.Error(err, "Unable to select namespace")
+
+			if a.eventService != nil {
+				if err := a.eventService.SendWarning(namespace, "Discoblocks", "Pod Admission", fmt.Sprintf("Namespace selector of %s is invalid", clusterConfig.Name), err.Error(), &clusterConfig, nil); err != nil {
+					logger.Error(err, "Failed to create event")
+				}
+			}
+			continue
+		} else if !selected {
+			continue
+		}
+
+		config := utils.RenderClusterDiskConfig(&clusterConfig, namespace)
+
+		if dryRun {
+			diskConfigs.Items = append(diskConfigs.Items, *config)
+			continue
+		}
+
+		logger.Info("Create DiskConfig...")
+
+		if err := a.Client.Create(ctx, config); err != nil {
+			if !apierrors.IsAlreadyExists(err) {
+				metrics.NewError("DiskConfig", config.Name, namespace, "Kube API", "create")
+
+				return fmt.Errorf("unable to create DiskConfig: %w", err)
+			}
+
+			if err := a.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: config.Name}, config); err != nil {
+				metrics.NewError("DiskConfig", config.Name, namespace, "Kube API", "get")
+
+				return fmt.Errorf("unable to fetch DiskConfig: %w", err)
+			}
+		}
+
+		diskConfigs.Items = append(diskConfigs.Items, *config)
+	}
+
+	return nil
+}
+
+// requestCapacity hands the capacity of DiskConfig over to a VolumeResizeRequest of the existing PVC, an open request of the PVC is kept
+func (a *PodMutator) requestCapacity(ctx context.Context, config *discoblocksondatiov1.DiskConfig, pod *corev1.Pod, pvc *corev1.PersistentVolumeClaim, capacity resource.Quantity, logger logr.Logger) error {
+	logger.Info("Fetch resize requests...")
+
+	requests := discoblocksondatiov1.VolumeResizeRequestList{}
+	if err := a.Client.List(ctx, &requests, client.InNamespace(pvc.Namespace), client.MatchingLabels{utils.ConfigLabel(): config.Name}); err != nil {
+		metrics.NewError("VolumeResizeRequest", "", pvc.Namespace, "Kube API", "list")
+
+		return fmt.Errorf("unable to list resize requests: %w", err)
+	}
+
+	if open := utils.FindOpenVolumeResizeRequest(requests.Items, pvc.Name); open != nil {
+		logger.Info("PVC has an open resize request", "request_name", open.Name, "phase", open.Status.Phase)
+		return nil
+	}
+
+	request, err := utils.RenderVolumeResizeRequest(config.Name, pod.Name, "", pvc, capacity, fmt.Sprintf("capacity of DiskConfig %s is above the PVC", config.Name), time.Now())
+	if err != nil {
+		return fmt.Errorf("unable to render resize request: %w", err)
+	}
+
+	logger.Info("Create resize request...", "request_name", request.Name, "capacity", capacity.String())
+
+	if err := a.Client.Create(ctx, request); err != nil {
+		metrics.NewError("VolumeResizeRequest", request.Name, request.Namespace, "Kube API", "create")
+
+		return fmt.Errorf("unable to create resize request: %w", err)
+	}
+
+	return nil
 }
 
 // InjectDecoder sets decoder
@@ -508,9 +948,16 @@ func (a *PodMutator) InjectDecoder(d *admission.Decoder) error {
 }
 
 // NewPodMutator creates a new pod mutator
-func NewPodMutator(kubeClient client.Client, strict bool) *PodMutator {
+func NewPodMutator(kubeClient client.Client, strict, singleNode, resizeRequests bool, storageClassRetry time.Duration, provisionLimiter *utils.ProvisionLimiter, provisionMaxDelay time.Duration, metricsImageTracker *utils.MetricsImageTracker, eventService utils.EventService) *PodMutator {
 	return &PodMutator{
-		Client: kubeClient,
-		strict: strict,
+		Client:              kubeClient,
+		strict:              strict,
+		singleNode:          singleNode,
+		resizeRequests:      resizeRequests,
+		storageClassRetry:   storageClassRetry,
+		provisionLimiter:    provisionLimiter,
+		provisionMaxDelay:   provisionMaxDelay,
+		metricsImageTracker: metricsImageTracker,
+		eventService:        eventService,
 	}
 }
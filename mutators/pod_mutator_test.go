@@ -0,0 +1,932 @@
+package mutators
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"github.com/ondat/discoblocks/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// newTestMutator returns a non-strict mutator of the objects with decoder
+func newTestMutator(t *testing.T, objects ...client.Object) (*PodMutator, client.Client) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, clientgoscheme.AddToScheme(scheme), "unable to add core types")
+	require.Nil(t, discoblocksondatiov1.AddToScheme(scheme), "unable to add discoblocks types")
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+	decoder, err := admission.NewDecoder(scheme)
+	require.Nil(t, err, "unable to create decoder")
+
+	mutator := NewPodMutator(kubeClient, false, false, false, 0, nil, 0, nil, utils.NewEventService("controller", kubeClient))
+	require.Nil(t, mutator.InjectDecoder(decoder), "unable to inject decoder")
+
+	return mutator, kubeClient
+}
+
+// admitPod calls the mutator with the creation of the Pod
+func admitPod(t *testing.T, mutator *PodMutator, pod *corev1.Pod) admission.Response {
+	raw, err := json.Marshal(pod)
+	require.Nil(t, err, "unable to marshal Pod")
+
+	return mutator.Handle(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	})
+}
+
+func TestHandleRequestsCapacityOfExistingPVC(t *testing.T) {
+	expandable := true
+	sc := storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sc",
+		},
+		Provisioner:          "ebs.csi.aws.com",
+		AllowVolumeExpansion: &expandable,
+	}
+
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+			UID:       "config-uid",
+		},
+		Spec: discoblocksondatiov1.DiskConfigSpec{
+			StorageClassName:  sc.Name,
+			Capacity:          resource.MustParse("2Gi"),
+			AvailabilityMode:  discoblocksondatiov1.ReadWriteSame,
+			MetricsSource:     discoblocksondatiov1.MetricsSourceKubelet,
+			MountPointPattern: "/media/discoblocks/config-%d",
+			PodSelector:       map[string]string{"app": "nginx"},
+		},
+	}
+
+	pvcName, err := utils.RenderResourceName(true, string(config.UID), config.Name, config.Namespace)
+	require.Nil(t, err, "unable to render PVC name")
+
+	// PVC has been created before capacity of the config was raised
+	existingPVC := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       pvcName,
+			Namespace:  config.Namespace,
+			UID:        "pvc-uid",
+			Labels:     map[string]string{utils.ConfigLabel(): config.Name},
+			Finalizers: []string{utils.RenderFinalizer(config.Name)},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: &sc.Name,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		},
+	}
+
+	mutator, kubeClient := newTestMutator(t, &sc, &config, &existingPVC)
+	mutator.resizeRequests = true
+
+	newPod := func(name string) *corev1.Pod {
+		return &corev1.Pod{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "v1",
+				Kind:       "Pod",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: config.Namespace,
+				Labels:    map[string]string{"app": "nginx"},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:  "app",
+					Image: "nginx",
+				}},
+			},
+		}
+	}
+
+	resp := admitPod(t, mutator, newPod("pod-a"))
+	require.True(t, resp.Allowed, "Pod not admitted")
+	assert.Contains(t, resp.Warnings, "Capacity of existing PVC "+pvcName+" is below DiskConfig config, resize to 2Gi is requested", "missing warning")
+
+	latestPVC := corev1.PersistentVolumeClaim{}
+	require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Name: pvcName, Namespace: config.Namespace}, &latestPVC), "unable to fetch PVC")
+	assert.Equal(t, "1Gi", latestPVC.Spec.Resources.Requests.Storage().String(), "PVC resized at admission")
+
+	requests := discoblocksondatiov1.VolumeResizeRequestList{}
+	require.Nil(t, kubeClient.List(context.Background(), &requests), "unable to list requests")
+	require.Len(t, requests.Items, 1, "invalid number of requests")
+
+	assert.Equal(t, pvcName, requests.Items[0].Spec.PVCName, "invalid PVC of request")
+	assert.Equal(t, config.Name, requests.Items[0].Spec.ConfigName, "invalid config of request")
+	assert.Equal(t, "pod-a", requests.Items[0].Spec.PodName, "invalid Pod of request")
+	assert.Equal(t, "2Gi", requests.Items[0].Spec.Capacity.String(), "invalid capacity of request")
+
+	// Open request of the PVC is kept on next admissions
+	resp = admitPod(t, mutator, newPod("pod-b"))
+	require.True(t, resp.Allowed, "Pod not admitted")
+
+	require.Nil(t, kubeClient.List(context.Background(), &requests), "unable to list requests")
+	assert.Len(t, requests.Items, 1, "open request duplicated")
+}
+
+func TestHandleReportsCapacityOfExistingPVCWithoutResizeRequests(t *testing.T) {
+	expandable := true
+	sc := storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sc",
+		},
+		Provisioner:          "ebs.csi.aws.com",
+		AllowVolumeExpansion: &expandable,
+	}
+
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+			UID:       "config-uid",
+		},
+		Spec: discoblocksondatiov1.DiskConfigSpec{
+			StorageClassName:  sc.Name,
+			Capacity:          resource.MustParse("2Gi"),
+			AvailabilityMode:  discoblocksondatiov1.ReadWriteSame,
+			MetricsSource:     discoblocksondatiov1.MetricsSourceKubelet,
+			MountPointPattern: "/media/discoblocks/config-%d",
+			PodSelector:       map[string]string{"app": "nginx"},
+		},
+	}
+
+	pvcName, err := utils.RenderResourceName(true, string(config.UID), config.Name, config.Namespace)
+	require.Nil(t, err, "unable to render PVC name")
+
+	existingPVC := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       pvcName,
+			Namespace:  config.Namespace,
+			UID:        "pvc-uid",
+			Labels:     map[string]string{utils.ConfigLabel(): config.Name},
+			Finalizers: []string{utils.RenderFinalizer(config.Name)},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: &sc.Name,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		},
+	}
+
+	// RESIZE_REQUESTS is unset, the request controller is not running
+	mutator, kubeClient := newTestMutator(t, &sc, &config, &existingPVC)
+
+	resp := admitPod(t, mutator, &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: config.Namespace,
+			Labels:    map[string]string{"app": "nginx"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "app",
+				Image: "nginx",
+			}},
+		},
+	})
+	require.True(t, resp.Allowed, "Pod not admitted")
+	assert.Contains(t, resp.Warnings, "Capacity of existing PVC "+pvcName+" is below DiskConfig config: 2Gi, enable resize requests to resize it", "missing warning")
+
+	requests := discoblocksondatiov1.VolumeResizeRequestList{}
+	require.Nil(t, kubeClient.List(context.Background(), &requests), "unable to list requests")
+	assert.Empty(t, requests.Items, "request created without request controller")
+}
+
+func TestHandleClearsDetachedAtOfReusedPVC(t *testing.T) {
+	expandable := true
+	sc := storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sc",
+		},
+		Provisioner:          "ebs.csi.aws.com",
+		AllowVolumeExpansion: &expandable,
+	}
+
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+			UID:       "config-uid",
+		},
+		Spec: discoblocksondatiov1.DiskConfigSpec{
+			StorageClassName:  sc.Name,
+			Capacity:          resource.MustParse("1Gi"),
+			AvailabilityMode:  discoblocksondatiov1.ReadWriteSame,
+			MetricsSource:     discoblocksondatiov1.MetricsSourceKubelet,
+			MountPointPattern: "/media/discoblocks/config-%d",
+			PodSelector:       map[string]string{"app": "nginx"},
+			Policy: discoblocksondatiov1.Policy{
+				DetachGracePeriod: metav1.Duration{Duration: 5 * time.Minute},
+			},
+		},
+	}
+
+	pvcName, err := utils.RenderResourceName(true, string(config.UID), config.Name, config.Namespace)
+	require.Nil(t, err, "unable to render PVC name")
+
+	// Grace period is over, but reclaim hasn't run yet
+	existingPVC := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        pvcName,
+			Namespace:   config.Namespace,
+			Labels:      map[string]string{utils.ConfigLabel(): config.Name},
+			Annotations: map[string]string{utils.DetachedAtAnnotation(): time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)},
+			Finalizers:  []string{utils.RenderFinalizer(config.Name)},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: &sc.Name,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		},
+	}
+
+	mutator, kubeClient := newTestMutator(t, &sc, &config, &existingPVC)
+
+	resp := admitPod(t, mutator, &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: config.Namespace,
+			Labels:    map[string]string{"app": "nginx"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "app",
+				Image: "nginx",
+			}},
+		},
+	})
+	require.True(t, resp.Allowed, "Pod not admitted")
+
+	pvc := corev1.PersistentVolumeClaim{}
+	require.Nil(t, kubeClient.Get(context.Background(), types.NamespacedName{Namespace: config.Namespace, Name: pvcName}, &pvc), "unable to fetch PVC")
+	assert.NotContains(t, pvc.Annotations, utils.DetachedAtAnnotation(), "reused PVC still marked as detached")
+}
+
+func TestHandleVerifiesVolumeExpansion(t *testing.T) {
+	allow, deny := true, false
+
+	cases := map[string]struct {
+		allowVolumeExpansion *bool
+		expectedDisk         bool
+	}{
+		"not set": {},
+		"not expandable": {
+			allowVolumeExpansion: &deny,
+		},
+		"expandable": {
+			allowVolumeExpansion: &allow,
+			expectedDisk:         true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			sc := storagev1.StorageClass{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "sc",
+				},
+				Provisioner:          "ebs.csi.aws.com",
+				AllowVolumeExpansion: c.allowVolumeExpansion,
+			}
+
+			config := discoblocksondatiov1.DiskConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "config",
+					Namespace: "default",
+					UID:       "config-uid",
+				},
+				Spec: discoblocksondatiov1.DiskConfigSpec{
+					StorageClassName:  sc.Name,
+					Capacity:          resource.MustParse("1Gi"),
+					AvailabilityMode:  discoblocksondatiov1.ReadWriteSame,
+					MetricsSource:     discoblocksondatiov1.MetricsSourceKubelet,
+					MountPointPattern: "/media/discoblocks/config-%d",
+					PodSelector:       map[string]string{"app": "nginx"},
+				},
+			}
+
+			mutator, kubeClient := newTestMutator(t, &sc, &config)
+
+			resp := admitPod(t, mutator, &corev1.Pod{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Pod",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pod",
+					Namespace: config.Namespace,
+					Labels:    map[string]string{"app": "nginx"},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "app",
+						Image: "nginx",
+					}},
+				},
+			})
+			require.True(t, resp.Allowed, "Pod not admitted")
+
+			patches, err := json.Marshal(resp.Patches)
+			require.Nil(t, err, "unable to marshal patches")
+
+			events := eventsv1.EventList{}
+			require.Nil(t, kubeClient.List(context.Background(), &events), "unable to list events")
+
+			if c.expectedDisk {
+				assert.Contains(t, string(patches), "/media/discoblocks/config-0", "disk not attached")
+				assert.Empty(t, events.Items, "unexpected events")
+				return
+			}
+
+			assert.NotContains(t, string(patches), "/media/discoblocks/config-0", "disk attached")
+			assert.Equal(t, "StorageClass sc doesn't allow volume expansion, disks of config can't be resized", string(resp.Result.Reason), "invalid reason")
+
+			if assert.Len(t, events.Items, 1, "invalid number of events") {
+				assert.Equal(t, "Warning", events.Items[0].Type, "invalid event type")
+				assert.Equal(t, "StorageClass of config is not expandable: sc", events.Items[0].Reason, "invalid event reason")
+				assert.Equal(t, config.Name, events.Items[0].Regarding.Name, "invalid event object")
+			}
+		})
+	}
+}
+
+func TestHandleSkipsUnavailableMetricsImage(t *testing.T) {
+	certsDir := t.TempDir()
+	for _, name := range []string{"ca.crt", "tls.crt", "tls.key"} {
+		require.Nil(t, os.WriteFile(filepath.Join(certsDir, name), []byte(name), 0o600), "unable to write certificate")
+	}
+	require.Nil(t, LoadMetricsCerts(certsDir), "unable to load certificates")
+
+	expandable := true
+	sc := storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sc",
+		},
+		Provisioner:          "ebs.csi.aws.com",
+		AllowVolumeExpansion: &expandable,
+	}
+
+	objects := []client.Object{&sc}
+	for _, namespace := range []string{"degraded", "healthy"} {
+		objects = append(objects, &discoblocksondatiov1.DiskConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "config",
+				Namespace: namespace,
+				UID:       types.UID(namespace + "-uid"),
+			},
+			Spec: discoblocksondatiov1.DiskConfigSpec{
+				StorageClassName:  sc.Name,
+				Capacity:          resource.MustParse("1Gi"),
+				AvailabilityMode:  discoblocksondatiov1.ReadWriteSame,
+				MetricsSource:     discoblocksondatiov1.MetricsSourceSidecar,
+				MountPointPattern: "/media/discoblocks/config-%d",
+				PodSelector:       map[string]string{"app": "nginx"},
+			},
+		})
+	}
+
+	mutator, _ := newTestMutator(t, objects...)
+
+	tracker, err := utils.NewMetricsImageTracker(time.Minute)
+	require.Nil(t, err, "unable to create tracker")
+	mutator.metricsImageTracker = tracker
+
+	metricsSidecar, err := utils.RenderMetricsSidecar(utils.DefaultMetricsPort, false)
+	require.Nil(t, err, "unable to render sidecar")
+
+	// Pod of the degraded namespace is stuck on pulling the metrics image
+	stuckPod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stuck",
+			Namespace: "degraded",
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{*metricsSidecar},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: metricsSidecar.Name,
+				State: corev1.ContainerState{
+					Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"},
+				},
+			}},
+		},
+	}
+	require.Equal(t, metricsSidecar.Image, tracker.ReportPod(&stuckPod, time.Now()), "failure not reported")
+
+	newPod := func(namespace string) *corev1.Pod {
+		return &corev1.Pod{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "v1",
+				Kind:       "Pod",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pod",
+				Namespace: namespace,
+				Labels:    map[string]string{"app": "nginx"},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:  "app",
+					Image: "nginx",
+				}},
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		namespace       string
+		expectedSidecar bool
+	}{
+		"degraded namespace": {
+			namespace: "degraded",
+		},
+		"healthy namespace": {
+			namespace:       "healthy",
+			expectedSidecar: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			resp := admitPod(t, mutator, newPod(c.namespace))
+			require.True(t, resp.Allowed, "Pod not admitted")
+
+			patches, err := json.Marshal(resp.Patches)
+			require.Nil(t, err, "unable to marshal patches")
+
+			if c.expectedSidecar {
+				assert.Contains(t, string(patches), `"name":"discoblocks-metrics"`, "sidecar not injected")
+				assert.Empty(t, resp.Warnings, "unexpected warnings")
+			} else {
+				assert.NotContains(t, string(patches), `"name":"discoblocks-metrics"`, "sidecar injected")
+				assert.Contains(t, resp.Warnings, "Metrics sidecar image is unavailable, Pod is admitted without autoscaling", "missing warning")
+			}
+
+			assert.Contains(t, string(patches), "/media/discoblocks/config-0", "disk not attached")
+		})
+	}
+}
+
+func TestHandleSkipsInvalidNamespaceSelector(t *testing.T) {
+	namespace := corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "default",
+			Labels: map[string]string{"team": "storage"},
+		},
+	}
+
+	clusterConfig := discoblocksondatiov1.ClusterDiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster",
+			UID:  "cluster-uid",
+		},
+		Spec: discoblocksondatiov1.ClusterDiskConfigSpec{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      "team",
+					Operator: "Matches",
+					Values:   []string{"storage"},
+				}},
+			},
+			DiskConfigSpec: discoblocksondatiov1.DiskConfigSpec{
+				StorageClassName: "sc",
+				Capacity:         resource.MustParse("1Gi"),
+				PodSelector:      map[string]string{"app": "nginx"},
+			},
+		},
+	}
+
+	mutator, kubeClient := newTestMutator(t, &namespace, &clusterConfig)
+
+	resp := admitPod(t, mutator, &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: namespace.Name,
+			Labels:    map[string]string{"app": "nginx"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "app",
+				Image: "nginx",
+			}},
+		},
+	})
+	assert.True(t, resp.Allowed, "Pod rejected by invalid selector")
+
+	configs := discoblocksondatiov1.DiskConfigList{}
+	require.Nil(t, kubeClient.List(context.Background(), &configs), "unable to list configs")
+	assert.Empty(t, configs.Items, "config rendered by invalid selector")
+
+	events := eventsv1.EventList{}
+	require.Nil(t, kubeClient.List(context.Background(), &events), "unable to list events")
+	if assert.Len(t, events.Items, 1, "invalid number of events") {
+		assert.Equal(t, "Warning", events.Items[0].Type, "invalid event type")
+		assert.Equal(t, "Namespace selector of cluster is invalid", events.Items[0].Reason, "invalid event reason")
+		assert.Equal(t, clusterConfig.Name, events.Items[0].Regarding.Name, "invalid event object")
+	}
+}
+
+func TestHandleDryRunRendersClusterDiskConfigInMemory(t *testing.T) {
+	expandable := true
+	sc := storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sc",
+		},
+		Provisioner:          "ebs.csi.aws.com",
+		AllowVolumeExpansion: &expandable,
+	}
+
+	namespace := corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	clusterConfig := discoblocksondatiov1.ClusterDiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster",
+			UID:  "cluster-uid",
+		},
+		Spec: discoblocksondatiov1.ClusterDiskConfigSpec{
+			DiskConfigSpec: discoblocksondatiov1.DiskConfigSpec{
+				StorageClassName:  "sc",
+				Capacity:          resource.MustParse("1Gi"),
+				AvailabilityMode:  discoblocksondatiov1.ReadWriteSame,
+				MetricsSource:     discoblocksondatiov1.MetricsSourceKubelet,
+				MountPointPattern: "/media/discoblocks/cluster-%d",
+				PodSelector:       map[string]string{"app": "nginx"},
+			},
+		},
+	}
+
+	mutator, kubeClient := newTestMutator(t, &sc, &namespace, &clusterConfig)
+
+	pod := corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: namespace.Name,
+			Labels:    map[string]string{"app": "nginx"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "app",
+				Image: "nginx",
+			}},
+		},
+	}
+	raw, err := json.Marshal(&pod)
+	require.Nil(t, err, "unable to marshal Pod")
+
+	dryRun := true
+	resp := mutator.Handle(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+			DryRun:    &dryRun,
+		},
+	})
+	require.True(t, resp.Allowed, "Pod not admitted")
+
+	patches, err := json.Marshal(resp.Patches)
+	require.Nil(t, err, "unable to marshal patches")
+	assert.Contains(t, string(patches), "/media/discoblocks/cluster-0", "rendered config not applied")
+
+	configs := discoblocksondatiov1.DiskConfigList{}
+	require.Nil(t, kubeClient.List(context.Background(), &configs), "unable to list configs")
+	assert.Empty(t, configs.Items, "config created on dry run")
+}
+
+func TestHandleSingleNodeMode(t *testing.T) {
+	node := func(name string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+
+	cases := map[string]struct {
+		singleNode           bool
+		nodes                []client.Object
+		expectedDisk         bool
+		expectedScheduler    bool
+		expectedSelectedNode string
+	}{
+		"custom scheduler": {
+			nodes:             []client.Object{node("edge")},
+			expectedDisk:      true,
+			expectedScheduler: true,
+		},
+		"single node": {
+			singleNode:           true,
+			nodes:                []client.Object{node("edge")},
+			expectedDisk:         true,
+			expectedSelectedNode: "edge",
+		},
+		"multiple nodes": {
+			singleNode: true,
+			nodes:      []client.Object{node("edge"), node("other")},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			expandable := true
+			sc := storagev1.StorageClass{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "sc",
+				},
+				Provisioner:          "ebs.csi.aws.com",
+				AllowVolumeExpansion: &expandable,
+			}
+
+			config := discoblocksondatiov1.DiskConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "config",
+					Namespace: "default",
+					UID:       "config-uid",
+				},
+				Spec: discoblocksondatiov1.DiskConfigSpec{
+					StorageClassName:  sc.Name,
+					Capacity:          resource.MustParse("1Gi"),
+					AvailabilityMode:  discoblocksondatiov1.ReadWriteSame,
+					MetricsSource:     discoblocksondatiov1.MetricsSourceKubelet,
+					MountPointPattern: "/media/discoblocks/config-%d",
+					PodSelector:       map[string]string{"app": "nginx"},
+				},
+			}
+
+			mutator, kubeClient := newTestMutator(t, append([]client.Object{&sc, &config}, c.nodes...)...)
+			mutator.singleNode = c.singleNode
+
+			resp := admitPod(t, mutator, &corev1.Pod{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Pod",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pod",
+					Namespace: config.Namespace,
+					Labels:    map[string]string{"app": "nginx"},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "app",
+						Image: "nginx",
+					}},
+				},
+			})
+			require.True(t, resp.Allowed, "Pod not admitted")
+
+			patches, err := json.Marshal(resp.Patches)
+			require.Nil(t, err, "unable to marshal patches")
+
+			pvcs := corev1.PersistentVolumeClaimList{}
+			require.Nil(t, kubeClient.List(context.Background(), &pvcs), "unable to list PVCs")
+
+			if !c.expectedDisk {
+				assert.NotContains(t, string(patches), "/media/discoblocks/config-0", "disk attached without node")
+				assert.Empty(t, pvcs.Items, "PVC created without node")
+				return
+			}
+
+			assert.Contains(t, string(patches), "/media/discoblocks/config-0", "disk not attached")
+			assert.Equal(t, c.expectedScheduler, strings.Contains(string(patches), `"path":"/spec/schedulerName"`), "invalid scheduler")
+
+			require.Len(t, pvcs.Items, 1, "invalid number of PVCs")
+			assert.Equal(t, c.expectedSelectedNode, pvcs.Items[0].Annotations[utils.SelectedNodeAnnotation], "invalid selected node")
+		})
+	}
+}
+
+func TestHandleAnnotatesVolumes(t *testing.T) {
+	expandable := true
+	sc := storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sc",
+		},
+		Provisioner:          "ebs.csi.aws.com",
+		AllowVolumeExpansion: &expandable,
+	}
+
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+			UID:       "config-uid",
+		},
+		Spec: discoblocksondatiov1.DiskConfigSpec{
+			StorageClassName:  sc.Name,
+			Capacity:          resource.MustParse("1Gi"),
+			AvailabilityMode:  discoblocksondatiov1.ReadWriteSame,
+			MetricsSource:     discoblocksondatiov1.MetricsSourceKubelet,
+			MountPointPattern: "/media/discoblocks/config-%d",
+			PodSelector:       map[string]string{"app": "nginx"},
+			Policy: discoblocksondatiov1.Policy{
+				InitialNumberOfDisks: 2,
+			},
+		},
+	}
+
+	mutator, kubeClient := newTestMutator(t, &sc, &config)
+
+	resp := admitPod(t, mutator, &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: config.Namespace,
+			Labels:    map[string]string{"app": "nginx"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "app",
+				Image: "nginx",
+			}},
+		},
+	})
+	require.True(t, resp.Allowed, "Pod not admitted")
+
+	var annotations map[string]interface{}
+	var volumeMounts []interface{}
+	for _, patch := range resp.Patches {
+		switch patch.Path {
+		case "/metadata/annotations":
+			annotations, _ = patch.Value.(map[string]interface{})
+		case "/spec/containers/0/volumeMounts":
+			volumeMounts, _ = patch.Value.([]interface{})
+		}
+	}
+
+	rawVolumes, ok := annotations[utils.VolumesAnnotation()].(string)
+	require.True(t, ok, "volumes annotation not found")
+
+	annotated := []utils.AnnotatedVolume{}
+	require.Nil(t, json.Unmarshal([]byte(rawVolumes), &annotated), "unable to parse volumes annotation")
+
+	pvcs := corev1.PersistentVolumeClaimList{}
+	require.Nil(t, kubeClient.List(context.Background(), &pvcs), "unable to list PVCs")
+
+	pvcNames := []string{}
+	for i := range pvcs.Items {
+		pvcNames = append(pvcNames, pvcs.Items[i].Name)
+	}
+
+	mountPaths := []string{}
+	for _, rawMount := range volumeMounts {
+		if mount, ok := rawMount.(map[string]interface{}); ok {
+			mountPaths = append(mountPaths, fmt.Sprint(mount["mountPath"]))
+		}
+	}
+
+	require.Len(t, annotated, 2, "invalid number of annotated volumes")
+	assert.Equal(t, "/media/discoblocks/config-0", annotated[0].MountPath, "invalid order of annotated volumes")
+	assert.Equal(t, "/media/discoblocks/config-1", annotated[1].MountPath, "invalid order of annotated volumes")
+
+	for _, volume := range annotated {
+		assert.Contains(t, pvcNames, volume.PVC, "annotated PVC not created")
+		assert.Contains(t, mountPaths, volume.MountPath, "annotated mount path not injected")
+	}
+	assert.NotEqual(t, annotated[0].PVC, annotated[1].PVC, "same PVC annotated twice")
+}
+
+func TestHandleLabelsPodOfMultipleConfigs(t *testing.T) {
+	expandable := true
+	sc := storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sc",
+		},
+		Provisioner:          "ebs.csi.aws.com",
+		AllowVolumeExpansion: &expandable,
+	}
+
+	objects := []client.Object{&sc}
+	configs := []*discoblocksondatiov1.DiskConfig{}
+	for _, name := range []string{"first", "second"} {
+		config := discoblocksondatiov1.DiskConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				UID:       types.UID(name + "-uid"),
+			},
+			Spec: discoblocksondatiov1.DiskConfigSpec{
+				StorageClassName:  sc.Name,
+				Capacity:          resource.MustParse("1Gi"),
+				AvailabilityMode:  discoblocksondatiov1.ReadWriteSame,
+				MetricsSource:     discoblocksondatiov1.MetricsSourceKubelet,
+				MountPointPattern: "/media/discoblocks/" + name + "-%d",
+				PodSelector:       map[string]string{"app": "nginx"},
+			},
+		}
+
+		configs = append(configs, &config)
+		objects = append(objects, &config)
+	}
+
+	mutator, kubeClient := newTestMutator(t, objects...)
+
+	pod := corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "nginx"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "app",
+				Image: "nginx",
+			}},
+		},
+	}
+
+	resp := admitPod(t, mutator, &pod)
+	require.True(t, resp.Allowed, "Pod not admitted")
+
+	patches, err := json.Marshal(resp.Patches)
+	require.Nil(t, err, "unable to marshal patches")
+
+	// Labels of the admitted Pod, keys of JSON patch paths are escaped
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+	for _, patch := range resp.Patches {
+		if key := strings.TrimPrefix(patch.Path, "/metadata/labels/"); key != patch.Path {
+			pod.Labels[unescape.Replace(key)] = fmt.Sprint(patch.Value)
+		}
+	}
+
+	require.Nil(t, kubeClient.Create(context.Background(), &pod), "unable to create admitted Pod")
+
+	for _, config := range configs {
+		assert.Equal(t, config.Name, pod.Labels[utils.RenderUniqueLabel(string(config.UID))], "label of %s overwritten", config.Name)
+		assert.Contains(t, string(patches), "/media/discoblocks/"+config.Name+"-0", "disk of %s not attached", config.Name)
+
+		// Volume monitor finds Pods of the config by this selector
+		selector, err := utils.RenderPodSelector(config)
+		require.Nil(t, err, "unable to render selector of %s", config.Name)
+
+		pods := corev1.PodList{}
+		require.Nil(t, kubeClient.List(context.Background(), &pods, client.InNamespace(pod.Namespace), client.MatchingLabelsSelector{Selector: selector}), "unable to list Pods of %s", config.Name)
+		if assert.Len(t, pods.Items, 1, "Pod not selected by %s", config.Name) {
+			assert.Equal(t, pod.Name, pods.Items[0].Name, "invalid Pod selected by %s", config.Name)
+		}
+	}
+}
@@ -5,10 +5,114 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// DiskUsage contains block usage of a mount point
+type DiskUsage struct {
+	Size      float64
+	Used      float64
+	Available float64
+	ReadOnly  bool
+	// Timestamp is the time of measurement given by the exporter, zero if the exporter measures at scrape
+	Timestamp time.Time
+}
+
+// UsedPercentage returns used space in percentage of usable space of the file-system.
+// Ext file-systems reserve blocks for root which are missing from available,
+// so their usage is counted against the full size to match other file-systems.
+func (d DiskUsage) UsedPercentage(fs string) float64 {
+	usable := d.Used + d.Available
+
+	switch fs {
+	case "ext2", "ext3", "ext4":
+		if d.Size > usable {
+			usable = d.Size
+		}
+	}
+
+	if usable <= 0 {
+		return 0
+	}
+
+	const hundred = 100
+	return d.Used / usable * hundred
+}
+
+// BlockSize is the block size of 'df -P' output
+const BlockSize = 1024
+
+// IsBehind returns true if the file-system is smaller than capacity of the volume in bytes by more than the given percentage,
+// it happens when the volume has been expanded but the file-system has not been grown
+func (d DiskUsage) IsBehind(capacity, percentage float64) bool {
+	if d.Size <= 0 || capacity <= 0 {
+		return false
+	}
+
+	const hundred = 100
+	return d.Size*BlockSize < capacity*(1-percentage/hundred)
+}
+
+// IsStale returns true if the usage is older than tolerance, jitter is added to tolerance to absorb scrape intervals and clock skew.
+// Zero tolerance disables the check, usage without timestamp of the exporter is measured at scrape, so it is never stale.
+func (d DiskUsage) IsStale(now time.Time, tolerance, jitter time.Duration) bool {
+	if tolerance <= 0 || d.Timestamp.IsZero() {
+		return false
+	}
+
+	return now.Sub(d.Timestamp) > tolerance+jitter
+}
+
+// Merge returns the report with the least available space, reports of a shared file-system may differ by timing.
+// Merged report is read-only if any of the reports is read-only.
+func Merge(reports []DiskUsage) DiskUsage {
+	merged := DiskUsage{}
+	readOnly := false
+	for i, report := range reports {
+		readOnly = readOnly || report.ReadOnly
+
+		if i == 0 || report.Available < merged.Available ||
+			report.Available == merged.Available && report.Used > merged.Used {
+			merged = report
+		}
+	}
+	merged.ReadOnly = readOnly
+
+	return merged
+}
+
+// podVolumePattern is the kubelet mount path of CSI PersistentVolumes in Pods, reported if metrics are made from host perspective
+const podVolumePattern = "/volumes/kubernetes.io~csi/%s/mount"
+
+// Lookup finds usage of a disk by its mount point in the container, or by the kubelet mount path
+// or the staging path of its PersistentVolume if the report is made from host perspective
+func Lookup(diskInfo map[string]DiskUsage, mountPoint, pvName, stagingPath string) (DiskUsage, bool) {
+	if usage, ok := diskInfo[mountPoint]; ok {
+		return usage, true
+	}
+
+	if pvName == "" {
+		return DiskUsage{}, false
+	}
+
+	suffixes := []string{fmt.Sprintf(podVolumePattern, pvName)}
+	if stagingPath != "" {
+		suffixes = append(suffixes, stagingPath)
+	}
+
+	for _, suffix := range suffixes {
+		for mp, usage := range diskInfo {
+			if strings.HasSuffix(mp, suffix) {
+				return usage, true
+			}
+		}
+	}
+
+	return DiskUsage{}, false
+}
+
 // Fetch calls 'df' on the remote address across a tunnel
-func Fetch(name, namespace string) (map[string]float64, error) {
+func Fetch(name, namespace string) (map[string]DiskUsage, error) {
 	addr, err := getProxy(name, namespace)
 	if err != nil {
 		return nil, fmt.Errorf("unable to find proxy: %w", err)
@@ -19,13 +123,30 @@ func Fetch(name, namespace string) (map[string]float64, error) {
 		return nil, fmt.Errorf("unable to call endpoint %s: %w", addr, err)
 	}
 
+	return parse(content)
+}
+
+// MountsSeparator separates output of 'df -P' and content of '/proc/mounts' in metrics
+const MountsSeparator = "# mounts"
+
+// parse processes output of 'df -P' optionally followed by mounts, mount options mark read-only file-systems
+func parse(content []string) (map[string]DiskUsage, error) {
+	mounts := []string{}
+	for i := range content {
+		if content[i] == MountsSeparator {
+			mounts = content[i+1:]
+			content = content[:i]
+			break
+		}
+	}
+
 	if len(content) <= 1 {
 		return nil, errors.New("empty content")
 	}
 
 	content = content[1:]
 
-	diskInfo := map[string]float64{}
+	diskInfo := map[string]DiskUsage{}
 	for _, line := range content {
 		parts := strings.Fields(line)
 
@@ -34,13 +155,43 @@ func Fetch(name, namespace string) (map[string]float64, error) {
 			return nil, fmt.Errorf("unable to find valid disk info: %s", line)
 		}
 
-		const tt = 32
-		used, err := strconv.ParseFloat(parts[4][:len(parts[4])-1], tt)
-		if err != nil {
-			return nil, fmt.Errorf("unable to parse float by %s: %w", parts[4][:len(parts[4])-1], err)
+		blocks := [3]float64{}
+		for i := range blocks {
+			const tt = 64
+			value, err := strconv.ParseFloat(parts[i+1], tt)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse float by %s: %w", parts[i+1], err)
+			}
+
+			blocks[i] = value
 		}
 
-		diskInfo[parts[5]] = used
+		diskInfo[parts[5]] = DiskUsage{
+			Size:      blocks[0],
+			Used:      blocks[1],
+			Available: blocks[2],
+		}
+	}
+
+	for _, line := range mounts {
+		const options = 3
+		parts := strings.Fields(line)
+		if len(parts) <= options {
+			continue
+		}
+
+		usage, ok := diskInfo[parts[1]]
+		if !ok {
+			continue
+		}
+
+		for _, option := range strings.Split(parts[options], ",") {
+			if option == "ro" {
+				usage.ReadOnly = true
+				diskInfo[parts[1]] = usage
+				break
+			}
+		}
 	}
 
 	return diskInfo, nil
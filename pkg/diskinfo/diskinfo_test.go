@@ -0,0 +1,654 @@
+package diskinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fatedier/frp/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/scheme"
+	restfake "k8s.io/client-go/rest/fake"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	diskInfo, err := parse([]string{
+		"Filesystem           1024-blocks    Used Available Capacity Mounted on",
+		"/dev/nvme1n1            1000000  800000    150000      85% /media/discoblocks/foo-0",
+	})
+	require.Nil(t, err, "unable to parse disk info")
+
+	assert.Equal(t, map[string]DiskUsage{
+		"/media/discoblocks/foo-0": {Size: 1000000, Used: 800000, Available: 150000},
+	}, diskInfo, "invalid disk info")
+
+	_, err = parse([]string{"header", "invalid line"})
+	assert.NotNil(t, err, "invalid line parsed")
+}
+
+func TestParseReadOnly(t *testing.T) {
+	t.Parallel()
+
+	diskInfo, err := parse([]string{
+		"Filesystem           1024-blocks    Used Available Capacity Mounted on",
+		"/dev/nvme1n1            1000000  800000    150000      85% /media/discoblocks/foo-0",
+		"/dev/nvme2n1            1000000  100000    850000      11% /media/discoblocks/foo-1",
+		MountsSeparator,
+		"overlay / overlay ro,relatime,lowerdir=/var/lib/a 0 0",
+		"/dev/nvme1n1 /media/discoblocks/foo-0 ext4 ro,relatime 0 0",
+		"/dev/nvme2n1 /media/discoblocks/foo-1 ext4 rw,relatime,errors=remount-ro 0 0",
+	})
+	require.Nil(t, err, "unable to parse disk info")
+
+	assert.Equal(t, map[string]DiskUsage{
+		"/media/discoblocks/foo-0": {Size: 1000000, Used: 800000, Available: 150000, ReadOnly: true},
+		"/media/discoblocks/foo-1": {Size: 1000000, Used: 100000, Available: 850000},
+	}, diskInfo, "invalid read-only state")
+
+	_, err = parse([]string{"header", MountsSeparator, "/dev/nvme1n1 /media/discoblocks/foo-0 ext4 ro 0 0"})
+	assert.NotNil(t, err, "mounts without disk info parsed")
+}
+
+func TestUsedPercentage(t *testing.T) {
+	t.Parallel()
+
+	const trigger = 80
+
+	cases := map[string]struct {
+		usage       DiskUsage
+		fs          string
+		expected    float64
+		triggerHits bool
+	}{
+		"xfs": {
+			usage:       DiskUsage{Size: 1000, Used: 790, Available: 210},
+			fs:          "xfs",
+			expected:    79,
+			triggerHits: false,
+		},
+		"ext4 same fill level": {
+			usage:       DiskUsage{Size: 1000, Used: 790, Available: 160},
+			fs:          "ext4",
+			expected:    79,
+			triggerHits: false,
+		},
+		"ext4 without reserve accounting": {
+			usage:       DiskUsage{Size: 1000, Used: 790, Available: 160},
+			fs:          "",
+			expected:    790.0 / 950.0 * 100,
+			triggerHits: true,
+		},
+		"xfs full": {
+			usage:       DiskUsage{Size: 1000, Used: 800, Available: 200},
+			fs:          "xfs",
+			expected:    80,
+			triggerHits: true,
+		},
+		"ext4 full": {
+			usage:       DiskUsage{Size: 1000, Used: 800, Available: 150},
+			fs:          "ext4",
+			expected:    80,
+			triggerHits: true,
+		},
+		"empty": {
+			fs: "ext4",
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			used := c.usage.UsedPercentage(c.fs)
+
+			assert.InDelta(t, c.expected, used, 0.001, "invalid used percentage")
+			assert.Equal(t, c.triggerHits, used >= trigger, "invalid trigger")
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		reports  []DiskUsage
+		expected DiskUsage
+	}{
+		"no reports": {},
+		"single report": {
+			reports:  []DiskUsage{{Size: 1000, Used: 500, Available: 500}},
+			expected: DiskUsage{Size: 1000, Used: 500, Available: 500},
+		},
+		"least available wins": {
+			reports: []DiskUsage{
+				{Size: 1000, Used: 700, Available: 300},
+				{Size: 1000, Used: 850, Available: 150},
+				{Size: 1000, Used: 800, Available: 200},
+			},
+			expected: DiskUsage{Size: 1000, Used: 850, Available: 150},
+		},
+		"most used wins on tie": {
+			reports: []DiskUsage{
+				{Size: 1000, Used: 800, Available: 150},
+				{Size: 1000, Used: 810, Available: 150},
+			},
+			expected: DiskUsage{Size: 1000, Used: 810, Available: 150},
+		},
+		"any read-only wins": {
+			reports: []DiskUsage{
+				{Size: 1000, Used: 800, Available: 200, ReadOnly: true},
+				{Size: 1000, Used: 850, Available: 150},
+			},
+			expected: DiskUsage{Size: 1000, Used: 850, Available: 150, ReadOnly: true},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, c.expected, Merge(c.reports), "invalid merged usage")
+		})
+	}
+}
+
+func TestIsBehind(t *testing.T) {
+	t.Parallel()
+
+	const gi = 1024 * 1024 * 1024
+
+	cases := map[string]struct {
+		usage      DiskUsage
+		capacity   float64
+		percentage float64
+		expected   bool
+	}{
+		"grown": {
+			usage:      DiskUsage{Size: 9.8 * 1024 * 1024},
+			capacity:   10 * gi,
+			percentage: 10,
+		},
+		"not grown": {
+			usage:      DiskUsage{Size: 5 * 1024 * 1024},
+			capacity:   10 * gi,
+			percentage: 10,
+			expected:   true,
+		},
+		"within tolerance": {
+			usage:      DiskUsage{Size: 9 * 1024 * 1024},
+			capacity:   10 * gi,
+			percentage: 15,
+		},
+		"unknown capacity": {
+			usage:      DiskUsage{Size: 5 * 1024 * 1024},
+			percentage: 10,
+		},
+		"unknown size": {
+			capacity:   10 * gi,
+			percentage: 10,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, c.expected, c.usage.IsBehind(c.capacity, c.percentage), "invalid mismatch")
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		diskInfo      map[string]DiskUsage
+		pvName        string
+		stagingPath   string
+		expectedFound bool
+	}{
+		"container view": {
+			diskInfo: map[string]DiskUsage{
+				"/media/discoblocks/foo-0": {Size: 1000, Used: 100, Available: 900},
+			},
+			pvName:        "pvc-1234",
+			expectedFound: true,
+		},
+		"pod volume of host view": {
+			diskInfo: map[string]DiskUsage{
+				"/var/lib/kubelet/pods/uid/volumes/kubernetes.io~csi/pvc-1234/mount": {Size: 1000, Used: 100, Available: 900},
+			},
+			pvName:        "pvc-1234",
+			expectedFound: true,
+		},
+		"global mount of host view": {
+			diskInfo: map[string]DiskUsage{
+				"/var/lib/kubelet/plugins/kubernetes.io/csi/pv/pvc-1234/globalmount": {Size: 1000, Used: 100, Available: 900},
+			},
+			pvName:        "pvc-1234",
+			stagingPath:   "/var/lib/kubelet/plugins/kubernetes.io/csi/pv/pvc-1234/globalmount",
+			expectedFound: true,
+		},
+		"staging path of driver": {
+			diskInfo: map[string]DiskUsage{
+				"/var/lib/kubelet/plugins/kubernetes.io/csi/pv/pvc-1234/mount": {Size: 1000, Used: 100, Available: 900},
+			},
+			pvName:        "pvc-1234",
+			stagingPath:   "/var/lib/kubelet/plugins/kubernetes.io/csi/pv/pvc-1234/mount",
+			expectedFound: true,
+		},
+		"default staging path of custom driver": {
+			diskInfo: map[string]DiskUsage{
+				"/var/lib/kubelet/plugins/kubernetes.io/csi/pv/pvc-1234/globalmount": {Size: 1000, Used: 100, Available: 900},
+			},
+			pvName:      "pvc-1234",
+			stagingPath: "/var/lib/kubelet/plugins/kubernetes.io/csi/pv/pvc-1234/mount",
+		},
+		"other volume of host view": {
+			diskInfo: map[string]DiskUsage{
+				"/var/lib/kubelet/pods/uid/volumes/kubernetes.io~csi/pvc-12345/mount": {Size: 1000, Used: 100, Available: 900},
+			},
+			pvName: "pvc-1234",
+		},
+		"unknown volume": {
+			diskInfo: map[string]DiskUsage{
+				"/var/lib/kubelet/pods/uid/volumes/kubernetes.io~csi/pvc-1234/mount": {Size: 1000, Used: 100, Available: 900},
+			},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			usage, found := Lookup(c.diskInfo, "/media/discoblocks/foo-0", c.pvName, c.stagingPath)
+			assert.Equal(t, c.expectedFound, found, "invalid lookup")
+
+			if c.expectedFound {
+				assert.Equal(t, DiskUsage{Size: 1000, Used: 100, Available: 900}, usage, "invalid usage")
+			}
+		})
+	}
+}
+
+func TestParseKubelet(t *testing.T) {
+	t.Parallel()
+
+	diskInfo, err := parseKubelet([]byte(`# HELP kubelet_volume_stats_available_bytes [ALPHA] Number of available bytes in the volume
+# TYPE kubelet_volume_stats_available_bytes gauge
+kubelet_volume_stats_available_bytes{namespace="default",persistentvolumeclaim="foo"} 1.048576e+08
+kubelet_volume_stats_available_bytes{namespace="default",persistentvolumeclaim="bar"} 9.437184e+08
+kubelet_volume_stats_available_bytes{namespace="other",persistentvolumeclaim="foo"} 1.073741824e+09
+# HELP kubelet_volume_stats_capacity_bytes [ALPHA] Capacity in bytes of the volume
+# TYPE kubelet_volume_stats_capacity_bytes gauge
+kubelet_volume_stats_capacity_bytes{namespace="default",persistentvolumeclaim="foo"} 1.073741824e+09
+kubelet_volume_stats_capacity_bytes{namespace="default",persistentvolumeclaim="bar"} 1.073741824e+09
+kubelet_volume_stats_used_bytes{namespace="default",persistentvolumeclaim="foo"} 9.6468992e+08
+kubelet_volume_stats_used_bytes{namespace="default",persistentvolumeclaim="bar"} 1.048576e+08
+kubelet_volume_stats_inodes{namespace="default",persistentvolumeclaim="foo"} 65536
+kubelet_running_pods 12
+`), "default")
+	require.Nil(t, err, "unable to parse kubelet metrics")
+
+	assert.Equal(t, map[string]DiskUsage{
+		"foo": {Size: 1048576, Used: 942080, Available: 102400},
+		"bar": {Size: 1048576, Used: 102400, Available: 921600},
+	}, diskInfo, "invalid disk info")
+
+	const upscaleTrigger = 80
+	assert.GreaterOrEqual(t, diskInfo["foo"].UsedPercentage("ext4"), float64(upscaleTrigger), "full disk not resized")
+	assert.Less(t, diskInfo["bar"].UsedPercentage("ext4"), float64(upscaleTrigger), "empty disk resized")
+
+	_, err = parseKubelet([]byte(`kubelet_volume_stats_used_bytes{namespace="default",persistentvolumeclaim="foo"} many`), "default")
+	assert.NotNil(t, err, "invalid value parsed")
+}
+
+func TestParseKubeletTimestamp(t *testing.T) {
+	t.Parallel()
+
+	diskInfo, err := parseKubelet([]byte(`kubelet_volume_stats_available_bytes{namespace="default",persistentvolumeclaim="foo"} 1.048576e+08 1654084800000
+kubelet_volume_stats_capacity_bytes{namespace="default",persistentvolumeclaim="foo"} 1.073741824e+09 1654084740000
+kubelet_volume_stats_used_bytes{namespace="default",persistentvolumeclaim="foo"} 9.6468992e+08 1654084800000
+kubelet_volume_stats_used_bytes{namespace="default",persistentvolumeclaim="bar"} 1.048576e+08
+`), "default")
+	require.Nil(t, err, "unable to parse kubelet metrics")
+
+	assert.Equal(t, time.UnixMilli(1654084740000), diskInfo["foo"].Timestamp, "oldest sample is not the time of measurement")
+	assert.True(t, diskInfo["bar"].Timestamp.IsZero(), "timestamp without sample timestamp")
+
+	scraped := time.UnixMilli(1654084860000)
+
+	const tolerance = time.Minute
+	assert.True(t, diskInfo["foo"].IsStale(scraped, tolerance, 0), "stale metrics accepted")
+	assert.False(t, diskInfo["foo"].IsStale(scraped, tolerance, time.Minute), "metrics within jitter rejected")
+	assert.False(t, diskInfo["bar"].IsStale(scraped, tolerance, 0), "metrics measured at scrape rejected")
+
+	_, err = parseKubelet([]byte(`kubelet_volume_stats_used_bytes{namespace="default",persistentvolumeclaim="foo"} 1 yesterday`), "default")
+	assert.NotNil(t, err, "invalid timestamp parsed")
+}
+
+func TestKubeletScraper(t *testing.T) {
+	t.Parallel()
+
+	const metrics = `kubelet_volume_stats_capacity_bytes{namespace="default",persistentvolumeclaim="foo"} 1.073741824e+09
+kubelet_volume_stats_capacity_bytes{namespace="other",persistentvolumeclaim="bar"} 1.073741824e+09
+`
+
+	requests := map[string]int{}
+	lock := sync.Mutex{}
+
+	restClient := &restfake.RESTClient{
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			node := "node-a"
+			if strings.Contains(req.URL.Path, "/nodes/node-b/") {
+				node = "node-b"
+			}
+
+			lock.Lock()
+			requests[node]++
+			lock.Unlock()
+
+			if node == "node-b" {
+				return nil, errors.New("connection refused")
+			}
+
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(metrics))}, nil
+		}),
+	}
+
+	scraper := NewKubeletScraper(restClient)
+
+	wg := sync.WaitGroup{}
+	for _, namespace := range []string{"default", "other", "default", "other"} {
+		namespace := namespace
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+
+			diskInfo, err := scraper.Fetch(context.Background(), "node-a", namespace)
+			assert.Nil(t, err, "unable to fetch node-a")
+			assert.Len(t, diskInfo, 1, "invalid disk info of namespace")
+		}()
+		go func() {
+			defer wg.Done()
+
+			_, err := scraper.Fetch(context.Background(), "node-b", namespace)
+			assert.NotNil(t, err, "failed node fetched")
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, map[string]int{
+		"node-a": 1,
+		"node-b": 1,
+	}, requests, "nodes scraped more than once")
+}
+
+func TestScrapeClient(t *testing.T) {
+	t.Parallel()
+
+	assert.NotSame(t, http.DefaultClient, scrapeClient, "default client shared")
+	assert.Equal(t, scrapeTimeout, scrapeClient.Timeout, "invalid timeout")
+
+	transport, ok := scrapeClient.Transport.(*http.Transport)
+	require.True(t, ok, "invalid transport")
+
+	assert.NotSame(t, http.DefaultTransport, transport, "default transport shared")
+	assert.Nil(t, transport.Proxy, "proxy enabled")
+	assert.False(t, transport.DisableKeepAlives, "keep-alives disabled")
+	assert.Equal(t, scrapeMaxIdleConns, transport.MaxIdleConns, "invalid idle connections")
+	assert.Equal(t, scrapeMaxIdleConns, transport.MaxIdleConnsPerHost, "invalid idle connections per host")
+	assert.Equal(t, scrapeIdleConnTimeout, transport.IdleConnTimeout, "invalid idle connection timeout")
+	assert.Equal(t, scrapeTimeout, transport.ResponseHeaderTimeout, "invalid response header timeout")
+}
+
+func TestPollProxies(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		status          int
+		body            string
+		delay           time.Duration
+		expectedCount   int
+		expectedProxy   bool
+		expectedError   error
+		expectedFailure bool
+	}{
+		"ok": {
+			status:        http.StatusOK,
+			body:          `{"proxies":[{"name":"ok-pod","status":"online"}]}`,
+			expectedCount: 1,
+			expectedProxy: true,
+		},
+		"unavailable": {
+			status:        http.StatusServiceUnavailable,
+			body:          `{"proxies":[{"name":"unavailable-pod","status":"online"}]}`,
+			expectedError: errUnexpectedStatus,
+		},
+		"internal error": {
+			status:        http.StatusInternalServerError,
+			body:          "<html>internal error</html>",
+			expectedError: errUnexpectedStatus,
+		},
+		"timeout": {
+			status:        http.StatusOK,
+			body:          `{"proxies":[{"name":"timeout-pod","status":"online"}]}`,
+			delay:         time.Second,
+			expectedError: context.DeadlineExceeded,
+		},
+		"malformed body": {
+			status:          http.StatusOK,
+			body:            `{"proxies":[{"name":"malformed-body-pod"`,
+			expectedFailure: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(c.delay):
+				case <-r.Context().Done():
+					return
+				}
+
+				w.WriteHeader(c.status)
+				_, _ = w.Write([]byte(c.body))
+			}))
+			defer srv.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+
+			count, err := pollProxies(ctx, scrapeClient, srv.URL)
+			if c.expectedError != nil {
+				assert.ErrorIs(t, err, c.expectedError, "invalid error")
+			} else if c.expectedFailure {
+				assert.NotNil(t, err, "invalid response accepted")
+			} else {
+				require.Nil(t, err, "unexpected error")
+			}
+			assert.Equal(t, c.expectedCount, count, "invalid number of proxies")
+
+			_, ok := proxies.Load(strings.ReplaceAll(n, " ", "-") + "-pod")
+			assert.Equal(t, c.expectedProxy, ok, "invalid proxy cache")
+		})
+	}
+}
+
+// trackingBody fails reading and records closing
+type trackingBody struct {
+	closed bool
+}
+
+func (b *trackingBody) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestPollProxiesClosesBody(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		status int
+	}{
+		"read error": {
+			status: http.StatusOK,
+		},
+		"unexpected status": {
+			status: http.StatusServiceUnavailable,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			body := trackingBody{}
+			httpClient := &http.Client{
+				Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					return &http.Response{StatusCode: c.status, Body: &body, Request: req}, nil
+				}),
+			}
+
+			_, err := pollProxies(context.Background(), httpClient, "http://127.0.0.1:8000/api/proxy/tcp")
+
+			assert.NotNil(t, err, "error not returned")
+			assert.True(t, body.closed, "body not closed")
+		})
+	}
+}
+
+// serveOnce answers the first connection with the given lines, returns port of the listener
+func serveOnce(t *testing.T, lines []string) int {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err, "unable to listen")
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for _, line := range lines {
+			_, _ = conn.Write([]byte(line + "\n"))
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestFetch(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		status        string
+		lines         []string
+		closed        bool
+		missing       bool
+		expectedUsage map[string]DiskUsage
+	}{
+		"ok": {
+			status: "online",
+			lines: []string{
+				"Filesystem           1024-blocks    Used Available Capacity Mounted on",
+				"/dev/nvme1n1            1000000  800000    150000      85% /media/discoblocks/foo-0",
+				MountsSeparator,
+				"/dev/nvme1n1 /media/discoblocks/foo-0 ext4 ro,relatime 0 0",
+			},
+			expectedUsage: map[string]DiskUsage{
+				"/media/discoblocks/foo-0": {Size: 1000000, Used: 800000, Available: 150000, ReadOnly: true},
+			},
+		},
+		"offline": {
+			status: "offline",
+			lines: []string{
+				"Filesystem           1024-blocks    Used Available Capacity Mounted on",
+				"/dev/nvme1n1            1000000  800000    150000      85% /media/discoblocks/foo-0",
+			},
+		},
+		"missing": {
+			missing: true,
+		},
+		"closed": {
+			status: "online",
+			closed: true,
+		},
+		"empty": {
+			status: "online",
+			lines: []string{
+				"Filesystem           1024-blocks    Used Available Capacity Mounted on",
+			},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		n := n
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			port := serveOnce(t, c.lines)
+			if c.closed {
+				listener, err := net.Listen("tcp", "127.0.0.1:0")
+				require.Nil(t, err, "unable to listen")
+				port = listener.Addr().(*net.TCPAddr).Port
+				require.Nil(t, listener.Close(), "unable to close listener")
+			}
+
+			if !c.missing {
+				proxies.Store(fmt.Sprintf("fetch-%s", n), &server.ProxyStatsInfo{
+					Name:   fmt.Sprintf("fetch-%s", n),
+					Status: c.status,
+					Conf:   map[string]interface{}{"remote_port": float64(port)},
+				})
+			}
+
+			diskInfo, err := Fetch(n, "fetch")
+			if c.expectedUsage == nil {
+				assert.NotNil(t, err, "error not returned")
+				return
+			}
+
+			require.Nil(t, err, "unable to fetch disk info")
+			assert.Equal(t, c.expectedUsage, diskInfo, "invalid disk info")
+		})
+	}
+}
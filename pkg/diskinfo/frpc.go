@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/fatedier/frp/server"
+	"github.com/ondat/discoblocks/pkg/metrics"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -20,50 +21,71 @@ var proxies = sync.Map{}
 
 var proxyPollLog = logf.Log.WithName("pkg.diskinfo.ProxyPoll")
 
+const dashboardURL = "http://127.0.0.1:8000/api/proxy/tcp"
+
+// errUnexpectedStatus is returned on non-2xx responses, body of them is an error page instead of proxies
+var errUnexpectedStatus = errors.New("unexpected status code")
+
+// pollProxies fetches proxies of the dashboard into the cache, returns the number of registered proxies
+func pollProxies(ctx context.Context, httpClient *http.Client, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call dashboard: %w", err)
+	}
+	// Body has to be closed on every path, otherwise the connection leaks
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			proxyPollLog.Error(err, "failed to close body")
+		}
+	}()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return 0, fmt.Errorf("%w: %d", errUnexpectedStatus, resp.StatusCode)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	proxyInfo := server.GetProxyInfoResp{}
+	if err := json.Unmarshal(content, &proxyInfo); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal content: %w", err)
+	}
+
+	for i := range proxyInfo.Proxies {
+		// u:rite's !!!!!!!!!!!!!!
+		proxies.Store(proxyInfo.Proxies[i].Name, proxyInfo.Proxies[i])
+	}
+
+	return len(proxyInfo.Proxies), nil
+}
+
 func getProxy(name, namespace string) (string, error) {
 	startPoll.Do(func() {
 		go func() {
-			httpClient := http.Client{}
-
 			for {
 				func() {
 					ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 					defer cancel()
 
-					req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://127.0.0.1:8000/api/proxy/tcp", http.NoBody)
-					if err != nil {
-						panic(err)
-					}
+					count, err := pollProxies(ctx, scrapeClient, dashboardURL)
+					if errors.Is(err, errUnexpectedStatus) {
+						metrics.NewError("Proxy", "", "", "frp", "poll")
 
-					resp, err := httpClient.Do(req)
-					if err != nil {
-						proxyPollLog.Error(err, "failed to call dashboard")
+						proxyPollLog.Info("Dashboard is not ready, proxies are not updated", "error", err.Error())
 						return
-					}
-					defer func() {
-						if err := resp.Body.Close(); err != nil {
-							proxyPollLog.Error(err, "failed to close body")
-						}
-					}()
-
-					content, err := io.ReadAll(resp.Body)
-					if err != nil {
-						proxyPollLog.Error(err, "failed to read body")
+					} else if err != nil {
+						proxyPollLog.Error(err, "failed to poll proxies")
 						return
 					}
 
-					proxyInfo := server.GetProxyInfoResp{}
-					if err := json.Unmarshal(content, &proxyInfo); err != nil {
-						proxyPollLog.Error(err, "failed to unmarshal content")
-						return
-					}
-
-					proxyPollLog.Info("Registered proxies", "count", len(proxyInfo.Proxies))
-
-					for i := range proxyInfo.Proxies {
-						// u:rite's !!!!!!!!!!!!!!
-						proxies.Store(proxyInfo.Proxies[i].Name, proxyInfo.Proxies[i])
-					}
+					proxyPollLog.Info("Registered proxies", "count", count)
 				}()
 
 				const five = 5
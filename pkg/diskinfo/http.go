@@ -0,0 +1,36 @@
+package diskinfo
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	scrapeTimeout         = 5 * time.Second
+	scrapeDialTimeout     = time.Second
+	scrapeKeepAlive       = 30 * time.Second
+	scrapeMaxIdleConns    = 10
+	scrapeIdleConnTimeout = 90 * time.Second
+)
+
+// scrapeClient is used only for scraping, it keeps connections alive and doesn't share settings with http.DefaultClient.
+// Scrape targets are local, so proxy settings of the environment are ignored.
+var scrapeClient = newScrapeClient()
+
+func newScrapeClient() *http.Client {
+	return &http.Client{
+		Timeout: scrapeTimeout,
+		Transport: &http.Transport{
+			Proxy: nil,
+			DialContext: (&net.Dialer{
+				Timeout:   scrapeDialTimeout,
+				KeepAlive: scrapeKeepAlive,
+			}).DialContext,
+			MaxIdleConns:          scrapeMaxIdleConns,
+			MaxIdleConnsPerHost:   scrapeMaxIdleConns,
+			IdleConnTimeout:       scrapeIdleConnTimeout,
+			ResponseHeaderTimeout: scrapeTimeout,
+		},
+	}
+}
@@ -0,0 +1,155 @@
+package diskinfo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// Volume stats of kubelet, collected by NodeGetVolumeStats of CSI drivers
+const (
+	kubeletCapacityMetric  = "kubelet_volume_stats_capacity_bytes"
+	kubeletUsedMetric      = "kubelet_volume_stats_used_bytes"
+	kubeletAvailableMetric = "kubelet_volume_stats_available_bytes"
+)
+
+// KubeletScraper reads volume stats of nodes via API server proxy, metrics of a node are fetched only once,
+// so Pods and configs of the same node share the response. A scraper is meant for a single monitor cycle.
+type KubeletScraper struct {
+	restClient rest.Interface
+	lock       sync.Mutex
+	nodes      map[string]*kubeletScrape
+}
+
+// kubeletScrape is the response of a node
+type kubeletScrape struct {
+	once    sync.Once
+	content []byte
+	err     error
+}
+
+// NewKubeletScraper creates a new scraper of a monitor cycle
+func NewKubeletScraper(restClient rest.Interface) *KubeletScraper {
+	return &KubeletScraper{
+		restClient: restClient,
+		nodes:      map[string]*kubeletScrape{},
+	}
+}
+
+// Fetch returns usages of the namespace by PVC names on the node
+func (s *KubeletScraper) Fetch(ctx context.Context, nodeName, namespace string) (map[string]DiskUsage, error) {
+	s.lock.Lock()
+	scrape, ok := s.nodes[nodeName]
+	if !ok {
+		scrape = &kubeletScrape{}
+		s.nodes[nodeName] = scrape
+	}
+	s.lock.Unlock()
+
+	scrape.once.Do(func() {
+		scrape.content, scrape.err = s.restClient.Get().AbsPath("/api/v1/nodes", nodeName, "proxy", "metrics").DoRaw(ctx)
+	})
+	if scrape.err != nil {
+		return nil, fmt.Errorf("unable to fetch kubelet metrics of %s: %w", nodeName, scrape.err)
+	}
+
+	return parseKubelet(scrape.content, namespace)
+}
+
+// parseKubelet processes kubelet metrics in Prometheus text format, sizes are converted to blocks of 'df -P'.
+// Optional sample timestamps are kept, the oldest one of the volume is its time of measurement.
+func parseKubelet(content []byte, namespace string) (map[string]DiskUsage, error) {
+	diskInfo := map[string]DiskUsage{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		open := strings.Index(line, "{")
+		if open == -1 {
+			continue
+		}
+
+		name := line[:open]
+		if name != kubeletCapacityMetric && name != kubeletUsedMetric && name != kubeletAvailableMetric {
+			continue
+		}
+
+		closing := strings.LastIndex(line, "}")
+		if closing < open {
+			return nil, fmt.Errorf("invalid line: %s", line)
+		}
+
+		labels := parseLabels(line[open+1 : closing])
+		if labels["namespace"] != namespace || labels["persistentvolumeclaim"] == "" {
+			continue
+		}
+
+		fields := strings.Fields(line[closing+1:])
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("value not found: %s", line)
+		}
+
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %s: %w", fields[0], err)
+		}
+		value /= BlockSize
+
+		usage := diskInfo[labels["persistentvolumeclaim"]]
+
+		const withTimestamp = 2
+		if len(fields) >= withTimestamp {
+			millis, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp %s: %w", fields[1], err)
+			}
+
+			if measured := time.UnixMilli(millis); usage.Timestamp.IsZero() || measured.Before(usage.Timestamp) {
+				usage.Timestamp = measured
+			}
+		}
+
+		switch name {
+		case kubeletCapacityMetric:
+			usage.Size = value
+		case kubeletUsedMetric:
+			usage.Used = value
+		case kubeletAvailableMetric:
+			usage.Available = value
+		}
+		diskInfo[labels["persistentvolumeclaim"]] = usage
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read metrics: %w", err)
+	}
+
+	return diskInfo, nil
+}
+
+// parseLabels processes labels of a metric, values of Kubernetes object names don't contain escaped characters
+func parseLabels(raw string) map[string]string {
+	labels := map[string]string{}
+
+	for _, pair := range strings.Split(raw, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		labels[strings.TrimSpace(parts[0])] = strings.Trim(strings.TrimSpace(parts[1]), `"`)
+	}
+
+	return labels
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/wasmerio/wasmer-go/wasmer"
 	corev1 "k8s.io/api/core/v1"
@@ -47,6 +48,7 @@ func init() {
 		}
 
 		drivers[file.Name()] = &Driver{
+			name:   file.Name(),
 			store:  store,
 			module: module,
 		}
@@ -62,6 +64,7 @@ func GetDriver(name string) *Driver {
 
 // Driver is the bridge to WASI modules
 type Driver struct {
+	name   string
 	store  *wasmer.Store
 	module *wasmer.Module
 }
@@ -180,8 +183,49 @@ func (d *Driver) GetPVCStub(name, namespace, storageClassName string) (*corev1.P
 	return &pvc, nil
 }
 
-// GetCSIDriverDetails returns the labels of CSI driver Pod
+// PodSelector locates Pods of CSI driver
+type PodSelector struct {
+	Namespace string            `json:"namespace"`
+	Labels    map[string]string `json:"labels"`
+}
+
+// podSelectorOverrides replaces Pod selectors of drivers by provisioner, installs may customize labels
+var podSelectorOverrides = map[string]PodSelector{}
+
+// SetPodSelectorOverrides configures Pod selectors of drivers,
+// for example {"ebs.csi.aws.com":{"namespace":"kube-system","labels":{"app":"ebs-csi-node"}}}
+func SetPodSelectorOverrides(raw string) error {
+	overrides := map[string]PodSelector{}
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+			return fmt.Errorf("unable to parse Pod selectors: %w", err)
+		}
+	}
+
+	for provisioner, selector := range overrides {
+		if selector.Namespace == "" {
+			return fmt.Errorf("namespace of Pod selector is missing: %s", provisioner)
+		} else if len(selector.Labels) == 0 {
+			return fmt.Errorf("labels of Pod selector are missing: %s", provisioner)
+		}
+	}
+
+	podSelectorOverrides = overrides
+
+	return nil
+}
+
+// GetCSIDriverDetails returns the labels of CSI driver Pod, overridden selector wins over the driver
 func (d *Driver) GetCSIDriverDetails() (string, map[string]string, error) {
+	if selector, ok := podSelectorOverrides[d.name]; ok {
+		labels := make(map[string]string, len(selector.Labels))
+		for k, v := range selector.Labels {
+			labels[k] = v
+		}
+
+		return selector.Namespace, labels, nil
+	}
+
 	wasiEnv, instance, err := d.init(nil)
 	if err != nil {
 		return "", nil, fmt.Errorf("unable to init instance: %w", err)
@@ -228,8 +272,8 @@ func (d *Driver) GetCSIDriverDetails() (string, map[string]string, error) {
 	return string(namespace), labels, nil
 }
 
-// GetPreMountCommand returns pre mount command
-func (d *Driver) GetPreMountCommand(pv *corev1.PersistentVolume, va *storagev1.VolumeAttachment) (string, error) {
+// GetPreMountCommand returns pre mount command, empty device path strategy selects the default of driver
+func (d *Driver) GetPreMountCommand(pv *corev1.PersistentVolume, va *storagev1.VolumeAttachment, devicePathStrategy string) (string, error) {
 	rawPV, err := json.Marshal(pv)
 	if err != nil {
 		return "", fmt.Errorf("unable to parse PersistentVolume: %w", err)
@@ -243,6 +287,7 @@ func (d *Driver) GetPreMountCommand(pv *corev1.PersistentVolume, va *storagev1.V
 	wasiEnv, instance, err := d.init(map[string]string{
 		"PERSISTENT_VOLUME_JSON": string(rawPV),
 		"VOLUME_ATTACHMENT_JSON": string(rawVA),
+		"DEVICE_PATH_STRATEGY":   devicePathStrategy,
 	})
 	if err != nil {
 		return "", fmt.Errorf("unable to init instance: %w", err)
@@ -266,8 +311,8 @@ func (d *Driver) GetPreMountCommand(pv *corev1.PersistentVolume, va *storagev1.V
 	return string(wasiEnv.ReadStdout()), nil
 }
 
-// GetPreResizeCommand returns pre resize command
-func (d *Driver) GetPreResizeCommand(pv *corev1.PersistentVolume, va *storagev1.VolumeAttachment) (string, error) {
+// GetPreResizeCommand returns pre resize command, empty device path strategy selects the default of driver
+func (d *Driver) GetPreResizeCommand(pv *corev1.PersistentVolume, va *storagev1.VolumeAttachment, devicePathStrategy string) (string, error) {
 	rawPV, err := json.Marshal(pv)
 	if err != nil {
 		return "", fmt.Errorf("unable to parse PersistentVolume: %w", err)
@@ -284,6 +329,7 @@ func (d *Driver) GetPreResizeCommand(pv *corev1.PersistentVolume, va *storagev1.
 	wasiEnv, instance, err := d.init(map[string]string{
 		"PERSISTENT_VOLUME_JSON": string(rawPV),
 		"VOLUME_ATTACHMENT_JSON": string(rawVA),
+		"DEVICE_PATH_STRATEGY":   devicePathStrategy,
 	})
 	if err != nil {
 		return "", fmt.Errorf("unable to init instance: %w", err)
@@ -337,6 +383,40 @@ func (d *Driver) IsFileSystemManaged() (bool, error) {
 	return resp, nil
 }
 
+// IsNodeLocal determines are volumes of driver bound to the node of creation, missing function means not node local
+func (d *Driver) IsNodeLocal() (bool, error) {
+	if !d.hasFunction("IsNodeLocal") {
+		return false, nil
+	}
+
+	wasiEnv, instance, err := d.init(nil)
+	if err != nil {
+		return false, fmt.Errorf("unable to init instance: %w", err)
+	}
+
+	isNodeLocal, err := instance.Exports.GetRawFunction("IsNodeLocal")
+	if err != nil {
+		return false, fmt.Errorf("unable to find IsNodeLocal: %w", err)
+	}
+
+	_, err = isNodeLocal.Native()()
+	if err != nil {
+		return false, fmt.Errorf("unable to call IsNodeLocal: %w", err)
+	}
+
+	errOut := string(wasiEnv.ReadStderr())
+	if errOut != "" {
+		return false, fmt.Errorf("function error IsNodeLocal: %s", errOut)
+	}
+
+	resp, err := strconv.ParseBool(string(wasiEnv.ReadStdout()))
+	if err != nil {
+		return false, fmt.Errorf("unable to parse output: %w", err)
+	}
+
+	return resp, nil
+}
+
 // WaitForVolumeAttachmentMeta defines wait for device info of plugin
 func (d *Driver) WaitForVolumeAttachmentMeta() (string, error) {
 	wasiEnv, instance, err := d.init(nil)
@@ -362,6 +442,156 @@ func (d *Driver) WaitForVolumeAttachmentMeta() (string, error) {
 	return string(wasiEnv.ReadStdout()), nil
 }
 
+// GetSupportedAccessModes returns the access modes supported by driver
+func (d *Driver) GetSupportedAccessModes() ([]corev1.PersistentVolumeAccessMode, error) {
+	wasiEnv, instance, err := d.init(nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to init instance: %w", err)
+	}
+
+	getSupportedAccessModes, err := instance.Exports.GetRawFunction("GetSupportedAccessModes")
+	if err != nil {
+		return nil, fmt.Errorf("unable to find GetSupportedAccessModes: %w", err)
+	}
+
+	_, err = getSupportedAccessModes.Native()()
+	if err != nil {
+		return nil, fmt.Errorf("unable to call GetSupportedAccessModes: %w", err)
+	}
+
+	errOut := string(wasiEnv.ReadStderr())
+	if errOut != "" {
+		return nil, fmt.Errorf("function error GetSupportedAccessModes: %s", errOut)
+	}
+
+	modes := []corev1.PersistentVolumeAccessMode{}
+	if err := json.Unmarshal(wasiEnv.ReadStdout(), &modes); err != nil {
+		return nil, fmt.Errorf("unable to parse output: %w", err)
+	}
+
+	return modes, nil
+}
+
+// GetSupportedDevicePathStrategies returns device path strategies of the driver, missing function means only the default is supported
+func (d *Driver) GetSupportedDevicePathStrategies() ([]string, error) {
+	if !d.hasFunction("GetSupportedDevicePathStrategies") {
+		return []string{}, nil
+	}
+
+	wasiEnv, instance, err := d.init(nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to init instance: %w", err)
+	}
+
+	getSupportedDevicePathStrategies, err := instance.Exports.GetRawFunction("GetSupportedDevicePathStrategies")
+	if err != nil {
+		return nil, fmt.Errorf("unable to find GetSupportedDevicePathStrategies: %w", err)
+	}
+
+	_, err = getSupportedDevicePathStrategies.Native()()
+	if err != nil {
+		return nil, fmt.Errorf("unable to call GetSupportedDevicePathStrategies: %w", err)
+	}
+
+	errOut := string(wasiEnv.ReadStderr())
+	if errOut != "" {
+		return nil, fmt.Errorf("function error GetSupportedDevicePathStrategies: %s", errOut)
+	}
+
+	strategies := []string{}
+	if err := json.Unmarshal(wasiEnv.ReadStdout(), &strategies); err != nil {
+		return nil, fmt.Errorf("unable to parse output: %w", err)
+	}
+
+	return strategies, nil
+}
+
+// HostJobVolumes extra volumes of host jobs requested by driver
+type HostJobVolumes struct {
+	Volumes      []corev1.Volume      `json:"volumes,omitempty"`
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+}
+
+// GetHostJobVolumes returns the extra volumes and mounts of host jobs, missing function means no extra volumes
+func (d *Driver) GetHostJobVolumes() (*HostJobVolumes, error) {
+	if !d.hasFunction("GetHostJobVolumes") {
+		return &HostJobVolumes{}, nil
+	}
+
+	wasiEnv, instance, err := d.init(nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to init instance: %w", err)
+	}
+
+	getHostJobVolumes, err := instance.Exports.GetRawFunction("GetHostJobVolumes")
+	if err != nil {
+		return nil, fmt.Errorf("unable to find GetHostJobVolumes: %w", err)
+	}
+
+	_, err = getHostJobVolumes.Native()()
+	if err != nil {
+		return nil, fmt.Errorf("unable to call GetHostJobVolumes: %w", err)
+	}
+
+	errOut := string(wasiEnv.ReadStderr())
+	if errOut != "" {
+		return nil, fmt.Errorf("function error GetHostJobVolumes: %s", errOut)
+	}
+
+	volumes := HostJobVolumes{}
+
+	out := wasiEnv.ReadStdout()
+	if len(out) == 0 {
+		return &volumes, nil
+	}
+
+	if err := json.Unmarshal(out, &volumes); err != nil {
+		return nil, fmt.Errorf("unable to parse output: %w", err)
+	}
+
+	return &volumes, nil
+}
+
+// GetStagingPath returns the staging path template of the driver, empty or missing function means the kubelet default
+func (d *Driver) GetStagingPath() (string, error) {
+	if !d.hasFunction("GetStagingPath") {
+		return "", nil
+	}
+
+	wasiEnv, instance, err := d.init(nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to init instance: %w", err)
+	}
+
+	getStagingPath, err := instance.Exports.GetRawFunction("GetStagingPath")
+	if err != nil {
+		return "", fmt.Errorf("unable to find GetStagingPath: %w", err)
+	}
+
+	_, err = getStagingPath.Native()()
+	if err != nil {
+		return "", fmt.Errorf("unable to call GetStagingPath: %w", err)
+	}
+
+	errOut := string(wasiEnv.ReadStderr())
+	if errOut != "" {
+		return "", fmt.Errorf("function error GetStagingPath: %s", errOut)
+	}
+
+	return strings.TrimSpace(string(wasiEnv.ReadStdout())), nil
+}
+
+// hasFunction checks is the function exported by driver, optional functions may be missing from older drivers
+func (d *Driver) hasFunction(name string) bool {
+	for _, export := range d.module.Exports() {
+		if export.Name() == name {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (d *Driver) init(envs map[string]string) (*wasmer.WasiEnvironment, *wasmer.Instance, error) {
 	builder := wasmer.NewWasiStateBuilder("wasi-program").
 		CaptureStdout().CaptureStderr()
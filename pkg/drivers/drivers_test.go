@@ -0,0 +1,130 @@
+package drivers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wasmerio/wasmer-go/wasmer"
+)
+
+// emptyDriverWat is a WASI module without any driver function
+const emptyDriverWat = `(module
+	(memory (export "memory") 1)
+	(func (export "_start")))`
+
+// newTestDriver compiles the given WebAssembly text into a driver
+func newTestDriver(t *testing.T, wat string) *Driver {
+	wasmBytes, err := wasmer.Wat2Wasm(wat)
+	require.Nil(t, err, "unable to convert module")
+
+	store := wasmer.NewStore(wasmer.NewEngine())
+	module, err := wasmer.NewModule(store, wasmBytes)
+	require.Nil(t, err, "unable to compile module")
+
+	return &Driver{
+		name:   "test",
+		store:  store,
+		module: module,
+	}
+}
+
+func TestSetPodSelectorOverrides(t *testing.T) {
+	cases := map[string]struct {
+		raw               string
+		expectedOverrides map[string]PodSelector
+		expectedError     bool
+	}{
+		"empty": {
+			raw:               "",
+			expectedOverrides: map[string]PodSelector{},
+		},
+		"valid": {
+			raw: `{"ebs.csi.aws.com":{"namespace":"storage","labels":{"app":"custom-ebs-node"}}}`,
+			expectedOverrides: map[string]PodSelector{
+				"ebs.csi.aws.com": {Namespace: "storage", Labels: map[string]string{"app": "custom-ebs-node"}},
+			},
+		},
+		"invalid json": {
+			raw:           `{"ebs.csi.aws.com":`,
+			expectedError: true,
+		},
+		"missing namespace": {
+			raw:           `{"ebs.csi.aws.com":{"labels":{"app":"custom-ebs-node"}}}`,
+			expectedError: true,
+		},
+		"missing labels": {
+			raw:           `{"ebs.csi.aws.com":{"namespace":"storage"}}`,
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			defer func() {
+				podSelectorOverrides = map[string]PodSelector{}
+			}()
+
+			err := SetPodSelectorOverrides(c.raw)
+			if c.expectedError {
+				assert.NotNil(t, err, "invalid error")
+				assert.Empty(t, podSelectorOverrides, "overrides changed on error")
+				return
+			}
+
+			require.Nil(t, err, "unexpected error")
+			assert.Equal(t, c.expectedOverrides, podSelectorOverrides, "invalid overrides")
+		})
+	}
+}
+
+func TestGetCSIDriverDetailsOverride(t *testing.T) {
+	require.Nil(t, SetPodSelectorOverrides(`{"ebs.csi.aws.com":{"namespace":"storage","labels":{"app":"custom-ebs-node"}}}`), "unable to set overrides")
+	defer func() {
+		podSelectorOverrides = map[string]PodSelector{}
+	}()
+
+	// Overridden driver doesn't need the module
+	driver := Driver{name: "ebs.csi.aws.com"}
+
+	namespace, labels, err := driver.GetCSIDriverDetails()
+	require.Nil(t, err, "unable to get driver details")
+
+	assert.Equal(t, "storage", namespace, "invalid namespace")
+	assert.Equal(t, map[string]string{"app": "custom-ebs-node"}, labels, "invalid labels")
+
+	labels["app"] = "changed"
+	assert.Equal(t, "custom-ebs-node", podSelectorOverrides["ebs.csi.aws.com"].Labels["app"], "override changed by caller")
+}
+
+func TestIsNodeLocalMissingFunction(t *testing.T) {
+	driver := newTestDriver(t, emptyDriverWat)
+
+	nodeLocal, err := driver.IsNodeLocal()
+	require.Nil(t, err, "unable to call IsNodeLocal")
+	assert.False(t, nodeLocal, "missing function is node local")
+}
+
+func TestGetStagingPathMissingFunction(t *testing.T) {
+	driver := newTestDriver(t, emptyDriverWat)
+
+	stagingPath, err := driver.GetStagingPath()
+	require.Nil(t, err, "unable to call GetStagingPath")
+	assert.Empty(t, stagingPath, "missing function has staging path")
+}
+
+func TestGetHostJobVolumesMissingFunction(t *testing.T) {
+	driver := newTestDriver(t, emptyDriverWat)
+
+	volumes, err := driver.GetHostJobVolumes()
+	require.Nil(t, err, "unable to call GetHostJobVolumes")
+	assert.Equal(t, &HostJobVolumes{}, volumes, "missing function has volumes")
+}
+
+func TestGetSupportedDevicePathStrategiesMissingFunction(t *testing.T) {
+	driver := newTestDriver(t, emptyDriverWat)
+
+	strategies, err := driver.GetSupportedDevicePathStrategies()
+	require.Nil(t, err, "unable to call GetSupportedDevicePathStrategies")
+	assert.Empty(t, strategies, "missing function has strategies")
+}
@@ -2,7 +2,6 @@ package metrics
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
-	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
 var (
@@ -27,19 +26,72 @@ var (
 			"resourceName", "resourceNamespace", "operation", "size",
 		},
 	)
+
+	staleMetricsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "discoblocks_stale_metrics_counter",
+			Subsystem: "operator",
+			Help:      "Counts decisions skipped on stale metrics",
+		},
+		[]string{
+			"resourceName", "resourceNamespace",
+		},
+	)
+
+	readOnlyFileSystemGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "discoblocks_readonly_filesystem",
+			Subsystem: "operator",
+			Help:      "Shows managed file-systems mounted read-only",
+		},
+		[]string{
+			"resourceName", "resourceNamespace",
+		},
+	)
 )
 
-func init() {
-	metrics.Registry.MustRegister(errorCounter)
-	metrics.Registry.MustRegister(pvcOperationCounter)
+// collectors are the custom metrics of discoblocks, see StartMetricsServer for registration
+var collectors = []prometheus.Collector{
+	errorCounter,
+	pvcOperationCounter,
+	staleMetricsCounter,
+	readOnlyFileSystemGauge,
 }
 
 // NewError increases error counter
 func NewError(resourceType, resourceName, resourceNamespace, errorType, operation string) {
 	errorCounter.WithLabelValues(resourceType, resourceName, resourceNamespace, errorType, operation).Inc()
+
+	if instruments := getOTelInstruments(); instruments != nil {
+		instruments.newError(resourceType, resourceName, resourceNamespace, errorType, operation)
+	}
 }
 
 // NewPVCOperation increases PVC operation counter
 func NewPVCOperation(resourceName, resourceNamespace, operation, size string) {
 	pvcOperationCounter.WithLabelValues(resourceName, resourceNamespace, operation, size).Inc()
+
+	if instruments := getOTelInstruments(); instruments != nil {
+		instruments.newPVCOperation(resourceName, resourceNamespace, operation, size)
+	}
+}
+
+// NewStaleMetrics increases stale metrics counter
+func NewStaleMetrics(resourceName, resourceNamespace string) {
+	staleMetricsCounter.WithLabelValues(resourceName, resourceNamespace).Inc()
+}
+
+// SetReadOnlyFileSystem sets read-only state of the file-system of PVC
+func SetReadOnlyFileSystem(resourceName, resourceNamespace string, readOnly bool) {
+	value := 0.0
+	if readOnly {
+		value = 1
+	}
+
+	readOnlyFileSystemGauge.WithLabelValues(resourceName, resourceNamespace).Set(value)
+}
+
+// DeleteReadOnlyFileSystem removes read-only state of the file-system of a removed PVC
+func DeleteReadOnlyFileSystem(resourceName, resourceNamespace string) {
+	readOnlyFileSystemGauge.DeleteLabelValues(resourceName, resourceNamespace)
 }
@@ -0,0 +1,21 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteReadOnlyFileSystem(t *testing.T) {
+	SetReadOnlyFileSystem("removed", "readonly", true)
+	SetReadOnlyFileSystem("kept", "readonly", false)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(readOnlyFileSystemGauge.WithLabelValues("removed", "readonly")), "invalid read-only state")
+
+	DeleteReadOnlyFileSystem("removed", "readonly")
+	DeleteReadOnlyFileSystem("missing", "readonly")
+
+	assert.False(t, readOnlyFileSystemGauge.DeleteLabelValues("removed", "readonly"), "series of removed PVC not deleted")
+	assert.True(t, readOnlyFileSystemGauge.DeleteLabelValues("kept", "readonly"), "series of other PVC deleted")
+}
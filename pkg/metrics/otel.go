@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlphttp"
+	"go.opentelemetry.io/otel/metric"
+	controller "go.opentelemetry.io/otel/sdk/metric/controller/basic"
+	processor "go.opentelemetry.io/otel/sdk/metric/processor/basic"
+	"go.opentelemetry.io/otel/sdk/metric/selector/simple"
+)
+
+// otelCollectPeriod defines how often metrics are pushed to OTLP endpoint
+const otelCollectPeriod = 30 * time.Second
+
+const instrumentationName = "github.com/ondat/discoblocks"
+
+type otelInstruments struct {
+	errorCounter        metric.Int64Counter
+	pvcOperationCounter metric.Int64Counter
+}
+
+var (
+	otelLock   = sync.RWMutex{}
+	activeOTel *otelInstruments
+)
+
+// StartOTelExporter pushes metrics to OTLP endpoint next to Prometheus, no-op if endpoint is empty.
+// Supported protocols are grpc (default) and http/protobuf. Returned function stops the exporter.
+func StartOTelExporter(ctx context.Context, endpoint, protocol string, insecure bool) (func(context.Context) error, error) {
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var driver otlp.ProtocolDriver
+	switch protocol {
+	case "", "grpc":
+		opts := []otlpgrpc.Option{otlpgrpc.WithEndpoint(endpoint)}
+		if insecure {
+			opts = append(opts, otlpgrpc.WithInsecure())
+		}
+
+		driver = otlpgrpc.NewDriver(opts...)
+	case "http/protobuf":
+		opts := []otlphttp.Option{otlphttp.WithEndpoint(endpoint)}
+		if insecure {
+			opts = append(opts, otlphttp.WithInsecure())
+		}
+
+		driver = otlphttp.NewDriver(opts...)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol: %s", protocol)
+	}
+
+	exporter, err := otlp.NewExporter(ctx, driver)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create OTLP exporter: %w", err)
+	}
+
+	pusher := controller.New(
+		processor.New(simple.NewWithInexpensiveDistribution(), exporter),
+		controller.WithExporter(exporter),
+		controller.WithCollectPeriod(otelCollectPeriod),
+	)
+
+	if err := setMeterProvider(pusher.MeterProvider()); err != nil {
+		return nil, fmt.Errorf("unable to create instruments: %w", err)
+	}
+
+	if err := pusher.Start(ctx); err != nil {
+		return nil, fmt.Errorf("unable to start OTLP exporter: %w", err)
+	}
+
+	return func(ctx context.Context) error {
+		if err := pusher.Stop(ctx); err != nil {
+			return fmt.Errorf("unable to stop OTLP exporter: %w", err)
+		}
+
+		return exporter.Shutdown(ctx)
+	}, nil
+}
+
+// setMeterProvider creates instruments of the provider, nil provider disables OTel
+func setMeterProvider(provider metric.MeterProvider) error {
+	var instruments *otelInstruments
+
+	if provider != nil {
+		meter := provider.Meter(instrumentationName)
+
+		errorCounter, err := meter.NewInt64Counter("discoblocks_error_counter", metric.WithDescription("Counts all errors by type"))
+		if err != nil {
+			return fmt.Errorf("unable to create error counter: %w", err)
+		}
+
+		pvcOperationCounter, err := meter.NewInt64Counter("discoblocks_pvc_operation_counter", metric.WithDescription("Counts all operations by type"))
+		if err != nil {
+			return fmt.Errorf("unable to create PVC operation counter: %w", err)
+		}
+
+		instruments = &otelInstruments{
+			errorCounter:        errorCounter,
+			pvcOperationCounter: pvcOperationCounter,
+		}
+	}
+
+	otelLock.Lock()
+	defer otelLock.Unlock()
+
+	activeOTel = instruments
+
+	return nil
+}
+
+func getOTelInstruments() *otelInstruments {
+	otelLock.RLock()
+	defer otelLock.RUnlock()
+
+	return activeOTel
+}
+
+func (i *otelInstruments) newError(resourceType, resourceName, resourceNamespace, errorType, operation string) {
+	i.errorCounter.Add(context.Background(), 1,
+		attribute.String("resourceType", resourceType),
+		attribute.String("resourceName", resourceName),
+		attribute.String("resourceNamespace", resourceNamespace),
+		attribute.String("errorType", errorType),
+		attribute.String("operation", operation),
+	)
+}
+
+func (i *otelInstruments) newPVCOperation(resourceName, resourceNamespace, operation, size string) {
+	i.pvcOperationCounter.Add(context.Background(), 1,
+		attribute.String("resourceName", resourceName),
+		attribute.String("resourceNamespace", resourceNamespace),
+		attribute.String("operation", operation),
+		attribute.String("size", size),
+	)
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/metrictest"
+)
+
+func TestOTelMetrics(t *testing.T) {
+	meterImpl, provider := metrictest.NewMeterProvider()
+	require.Nil(t, setMeterProvider(provider), "unable to set meter provider")
+	defer func() {
+		require.Nil(t, setMeterProvider(nil), "unable to disable OTel")
+	}()
+
+	NewError("PersistentVolumeClaim", "pvc", "default", "Kube API", "update")
+	NewPVCOperation("pvc", "default", "resize", "2Gi")
+
+	measured := metrictest.AsStructs(meterImpl.MeasurementBatches)
+	require.Len(t, measured, 2, "invalid number of measurements")
+
+	assert.Equal(t, "discoblocks_error_counter", measured[0].Name, "invalid error counter")
+	assert.Equal(t, instrumentationName, measured[0].InstrumentationName, "invalid instrumentation")
+	assert.Equal(t, int64(1), measured[0].Number.AsInt64(), "invalid error count")
+	assert.Equal(t, attribute.StringValue("update"), measured[0].Labels["operation"], "invalid operation")
+
+	assert.Equal(t, "discoblocks_pvc_operation_counter", measured[1].Name, "invalid PVC operation counter")
+	assert.Equal(t, int64(1), measured[1].Number.AsInt64(), "invalid PVC operation count")
+	assert.Equal(t, attribute.StringValue("2Gi"), measured[1].Labels["size"], "invalid size")
+}
+
+func TestOTelDisabled(t *testing.T) {
+	stop, err := StartOTelExporter(context.Background(), "", "", false)
+	require.Nil(t, err, "unable to start no-op exporter")
+	require.Nil(t, stop(context.Background()), "unable to stop no-op exporter")
+
+	assert.Nil(t, getOTelInstruments(), "OTel enabled")
+
+	_, err = StartOTelExporter(context.Background(), "localhost:4317", "thrift", false)
+	assert.NotNil(t, err, "invalid protocol accepted")
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// metricsReadHeaderTimeout bounds reading request headers of the dedicated metrics server
+const metricsReadHeaderTimeout = 10 * time.Second
+
+// StartMetricsServer exposes custom metrics of discoblocks.
+// Empty address registers them on the metrics endpoint of controller-runtime,
+// otherwise they are served on /metrics of a dedicated server at the address only.
+// Returned function stops the server or unregisters the metrics.
+func StartMetricsServer(addr string) (func(context.Context) error, error) {
+	if addr == "" {
+		for i, c := range collectors {
+			if err := metrics.Registry.Register(c); err != nil {
+				unregister(metrics.Registry, collectors[:i])
+
+				return nil, fmt.Errorf("unable to register metrics: %w", err)
+			}
+		}
+
+		return func(context.Context) error {
+			unregister(metrics.Registry, collectors)
+			return nil
+		}, nil
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on %s: %w", addr, err)
+	}
+
+	return serveMetrics(listener)
+}
+
+// serveMetrics serves custom metrics on the listener until the returned function is called
+func serveMetrics(listener net.Listener) (func(context.Context) error, error) {
+	registry := prometheus.NewRegistry()
+	for _, c := range collectors {
+		if err := registry.Register(c); err != nil {
+			return nil, fmt.Errorf("unable to register metrics: %w", err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: metricsReadHeaderTimeout,
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			NewError("Metrics", listener.Addr().String(), "", "HTTP", "serve")
+		}
+	}()
+
+	return server.Shutdown, nil
+}
+
+func unregister(registerer prometheus.Registerer, cs []prometheus.Collector) {
+	for _, c := range cs {
+		registerer.Unregister(c)
+	}
+}
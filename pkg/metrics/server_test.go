@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+func TestServeMetrics(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err, "unable to listen")
+
+	stop, err := serveMetrics(listener)
+	require.Nil(t, err, "unable to serve metrics")
+	defer func() {
+		require.Nil(t, stop(context.Background()), "unable to stop server")
+	}()
+
+	NewPVCOperation("served", "default", "resize", "2Gi")
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/metrics")
+	require.Nil(t, err, "unable to fetch metrics")
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.Nil(t, err, "unable to read metrics")
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "invalid status")
+	assert.Contains(t, string(body), `operator_discoblocks_pvc_operation_counter{operation="resize",resourceName="served",resourceNamespace="default",size="2Gi"} 1`, "custom metric not served")
+
+	assert.False(t, isGathered(t, "operator_discoblocks_pvc_operation_counter"), "custom metric served by controller-runtime")
+}
+
+func TestStartMetricsServerDefault(t *testing.T) {
+	stop, err := StartMetricsServer("")
+	require.Nil(t, err, "unable to register metrics")
+
+	NewError("PersistentVolumeClaim", "pvc", "default", "Kube API", "update")
+
+	assert.True(t, isGathered(t, "operator_discoblocks_error_counter"), "custom metric not registered on controller-runtime")
+
+	require.Nil(t, stop(context.Background()), "unable to unregister metrics")
+
+	assert.False(t, isGathered(t, "operator_discoblocks_error_counter"), "custom metric not unregistered")
+}
+
+func TestStartMetricsServerInvalidAddress(t *testing.T) {
+	_, err := StartMetricsServer("invalid:address:0")
+	assert.NotNil(t, err, "invalid address accepted")
+}
+
+func isGathered(t *testing.T, name string) bool {
+	families, err := metrics.Registry.Gather()
+	require.Nil(t, err, "unable to gather metrics")
+
+	for _, f := range families {
+		if f.GetName() == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,15 @@
+//go:build selftest
+// +build selftest
+
+package selftest
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func init() {
+	NewClient = func() client.Client {
+		return fake.NewClientBuilder().Build()
+	}
+}
@@ -0,0 +1,362 @@
+package selftest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"github.com/ondat/discoblocks/pkg/diskinfo"
+	"github.com/ondat/discoblocks/pkg/utils"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Stage is a step of the self-test
+type Stage string
+
+const (
+	// StageProvision creates the PVCs of a Pod
+	StageProvision Stage = "provision"
+	// StageMount renders and executes the mount Job
+	StageMount Stage = "mount"
+	// StageMetric detects low-space of a synthetic disk usage
+	StageMetric Stage = "metric"
+	// StageResize extends the PVC and executes the resize Job
+	StageResize Stage = "resize"
+	// StageCleanup removes every created object
+	StageCleanup Stage = "cleanup"
+)
+
+const (
+	namespace  = "discoblocks-self-test"
+	nodeName   = "self-test-node"
+	fileSystem = "ext4"
+
+	// lowSpacePercentage is the used space of the synthetic disk usage
+	lowSpacePercentage = 95
+)
+
+// Result is the outcome of a stage
+type Result struct {
+	Stage Stage
+	Error error
+}
+
+// NewClient returns an in-memory client for the self-test, it is set only in binaries built with selftest build tag,
+// so the in-memory Kubernetes API stays out of the production binary
+var NewClient func() client.Client
+
+// DefaultConfig returns the DiskConfig exercised by the self-test
+func DefaultConfig() *discoblocksondatiov1.DiskConfig {
+	return &discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "self-test",
+			Namespace: namespace,
+			UID:       "self-test",
+		},
+		Spec: discoblocksondatiov1.DiskConfigSpec{
+			StorageClassName:  "self-test",
+			Capacity:          resource.MustParse("1Gi"),
+			MountPointPattern: "/media/discoblocks/self-test-%d",
+			AvailabilityMode:  discoblocksondatiov1.ReadWriteOnce,
+			Policy: discoblocksondatiov1.Policy{
+				UpscaleTriggerPercentage: intstr.FromInt(80),
+				MaximumCapacityOfDisk:    resource.MustParse("10Gi"),
+				MaximumNumberOfDisks:     1,
+				InitialNumberOfDisks:     1,
+				ExtendCapacity:           resource.MustParse("1Gi"),
+			},
+		},
+	}
+}
+
+// fakeDriver stands for the CSI driver, it provides PVC stubs and provisions volumes at once
+type fakeDriver struct{}
+
+func (fakeDriver) getPVCStub(name, namespace, storageClassName string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: &storageClassName,
+		},
+	}
+}
+
+func (fakeDriver) provision(pvc *corev1.PersistentVolumeClaim) {
+	pvc.Spec.VolumeName = "pv-" + pvc.Name
+	pvc.Status.Phase = corev1.ClaimBound
+	pvc.Status.Capacity = corev1.ResourceList{
+		corev1.ResourceStorage: pvc.Spec.Resources.Requests[corev1.ResourceStorage],
+	}
+}
+
+// harness keeps the state between stages
+type harness struct {
+	client  client.Client
+	config  *discoblocksondatiov1.DiskConfig
+	driver  fakeDriver
+	pod     *corev1.Pod
+	pvc     *corev1.PersistentVolumeClaim
+	created []client.Object
+}
+
+// Run executes the stages in order, stops at the first failing one, and cleans up in any case
+func Run(ctx context.Context, kubeClient client.Client, config *discoblocksondatiov1.DiskConfig) ([]Result, error) {
+	h := harness{
+		client: kubeClient,
+		config: config,
+	}
+
+	results := []Result{}
+	var failed error
+	for _, stage := range []struct {
+		name Stage
+		run  func(context.Context) error
+	}{
+		{name: StageProvision, run: h.provision},
+		{name: StageMount, run: h.mount},
+		{name: StageMetric, run: h.metric},
+		{name: StageResize, run: h.resize},
+	} {
+		err := stage.run(ctx)
+		results = append(results, Result{Stage: stage.name, Error: err})
+
+		if err != nil {
+			failed = fmt.Errorf("stage %s failed: %w", stage.name, err)
+			break
+		}
+	}
+
+	err := h.cleanup(ctx)
+	results = append(results, Result{Stage: StageCleanup, Error: err})
+
+	if failed != nil {
+		return results, failed
+	} else if err != nil {
+		return results, fmt.Errorf("stage %s failed: %w", StageCleanup, err)
+	}
+
+	return results, nil
+}
+
+func (h *harness) create(ctx context.Context, obj client.Object) error {
+	if err := h.client.Create(ctx, obj); err != nil {
+		return fmt.Errorf("unable to create %s: %w", obj.GetName(), err)
+	}
+
+	h.created = append(h.created, obj)
+
+	return nil
+}
+
+func (h *harness) provision(ctx context.Context) error {
+	h.pod = &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "self-test",
+			Namespace: namespace,
+		},
+		Spec: corev1.PodSpec{
+			NodeName: nodeName,
+			Containers: []corev1.Container{
+				{Name: "self-test", Image: "self-test"},
+			},
+		},
+	}
+
+	if err := h.create(ctx, h.pod); err != nil {
+		return err
+	}
+
+	prefix := utils.GetNamePrefix(h.config.Spec.AvailabilityMode, string(h.config.UID), nodeName)
+
+	pvcName, err := utils.RenderResourceName(true, prefix, h.config.Name, h.config.Namespace)
+	if err != nil {
+		return fmt.Errorf("unable to render PVC name: %w", err)
+	}
+
+	h.pvc = h.driver.getPVCStub(pvcName, h.config.Namespace, h.config.Spec.StorageClassName)
+
+	utils.PVCDecorator(h.config, prefix, nil, h.pvc)
+
+	for k, v := range utils.RenderOwnerLabels(h.pod, true) {
+		h.pvc.Labels[k] = v
+	}
+
+	if err := h.create(ctx, h.pvc); err != nil {
+		return err
+	}
+
+	children, _, err := utils.RenderInitialPVCs(h.config, h.pvc)
+	if err != nil {
+		return fmt.Errorf("unable to render initial PVCs: %w", err)
+	}
+
+	for i := range children {
+		if err := h.create(ctx, children[i]); err != nil {
+			return err
+		}
+	}
+
+	existing := corev1.PersistentVolumeClaim{}
+	if err := h.client.Get(ctx, client.ObjectKeyFromObject(h.pvc), &existing); err != nil {
+		return fmt.Errorf("unable to fetch PVC: %w", err)
+	}
+
+	capacity := existing.Spec.Resources.Requests[corev1.ResourceStorage]
+	if capacity.Cmp(h.config.Spec.Capacity) != 0 {
+		return fmt.Errorf("invalid PVC capacity: %s", capacity.String())
+	}
+
+	h.driver.provision(&existing)
+
+	if err := h.client.Update(ctx, &existing); err != nil {
+		return fmt.Errorf("unable to bind PVC: %w", err)
+	}
+
+	h.pvc = &existing
+
+	return nil
+}
+
+func (h *harness) mount(ctx context.Context) error {
+	mountPoint := utils.RenderMountPoint(h.config.Spec.MountPointPattern, h.pvc.Name, 0)
+
+	job, err := utils.RenderMountJob(h.pod.Name, h.pvc.Name, h.pvc.Spec.VolumeName, h.pvc.Namespace, nodeName, fileSystem, true, mountPoint, []string{"self-test"}, "", "", "", nil, h.config.Spec.MountEnv, h.owner())
+	if err != nil {
+		return fmt.Errorf("unable to render mount job: %w", err)
+	}
+
+	return h.execute(ctx, job)
+}
+
+func (h *harness) metric(_ context.Context) error {
+	trigger, err := h.config.Spec.Policy.GetUpscaleTriggerPercentage()
+	if err != nil {
+		return err
+	}
+
+	capacity := h.pvc.Status.Capacity[corev1.ResourceStorage]
+	size := capacity.AsApproximateFloat64() / diskinfo.BlockSize
+
+	const hundred = 100
+	usage := diskinfo.DiskUsage{
+		Size:      size,
+		Used:      size * lowSpacePercentage / hundred,
+		Available: size * (hundred - lowSpacePercentage) / hundred,
+	}
+
+	if used := usage.UsedPercentage(fileSystem); used < trigger {
+		return fmt.Errorf("low-space not detected: %.2f%% used, trigger is %.2f%%", used, trigger)
+	}
+
+	return nil
+}
+
+func (h *harness) resize(ctx context.Context) error {
+	actual := h.pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+
+	resizeStep := utils.RenderResizeStep(h.config.Spec.Policy.ExtendCapacity, h.config.Spec.Policy.MaximumStepSize)
+	if utils.IsCapacityAtMax(actual, resizeStep, h.config.Spec.Policy.MaximumCapacityOfDisk) {
+		return fmt.Errorf("disk is at maximum capacity: %s", actual.String())
+	}
+
+	newCapacity := resizeStep.DeepCopy()
+	newCapacity.Add(actual)
+
+	h.pvc.Spec.Resources.Requests[corev1.ResourceStorage] = newCapacity
+
+	if err := h.client.Update(ctx, h.pvc); err != nil {
+		return fmt.Errorf("unable to resize PVC: %w", err)
+	}
+
+	job, err := utils.RenderResizeJob(h.pod.Name, h.pvc.Name, h.pvc.Spec.VolumeName, h.pvc.Namespace, nodeName, fileSystem, "", "", "", nil, h.config.Spec.MountEnv, nil, nil, h.owner())
+	if err != nil {
+		return fmt.Errorf("unable to render resize job: %w", err)
+	}
+
+	if err := h.execute(ctx, job); err != nil {
+		return err
+	}
+
+	existing := corev1.PersistentVolumeClaim{}
+	if err := h.client.Get(ctx, client.ObjectKeyFromObject(h.pvc), &existing); err != nil {
+		return fmt.Errorf("unable to fetch PVC: %w", err)
+	}
+
+	capacity := existing.Spec.Resources.Requests[corev1.ResourceStorage]
+	if capacity.Cmp(newCapacity) != 0 {
+		return fmt.Errorf("PVC not resized: %s", capacity.String())
+	}
+
+	return nil
+}
+
+// execute creates the Job and simulates its execution on the host
+func (h *harness) execute(ctx context.Context, job *batchv1.Job) error {
+	if job.Spec.Template.Spec.NodeName != nodeName {
+		return fmt.Errorf("job %s is not scheduled to node: %s", job.Name, job.Spec.Template.Spec.NodeName)
+	}
+
+	if err := h.create(ctx, job); err != nil {
+		return err
+	}
+
+	job.Status.Succeeded = 1
+	if job.Spec.Completions != nil {
+		job.Status.Succeeded = *job.Spec.Completions
+	}
+
+	if err := h.client.Status().Update(ctx, job); err != nil {
+		return fmt.Errorf("unable to update job status %s: %w", job.Name, err)
+	}
+
+	existing := batchv1.Job{}
+	if err := h.client.Get(ctx, client.ObjectKeyFromObject(job), &existing); err != nil {
+		return fmt.Errorf("unable to fetch job %s: %w", job.Name, err)
+	}
+
+	if existing.Status.Succeeded == 0 {
+		return fmt.Errorf("job %s not succeeded", job.Name)
+	}
+
+	return nil
+}
+
+func (h *harness) owner() metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: "v1",
+		Kind:       "PersistentVolumeClaim",
+		Name:       h.pvc.Name,
+		UID:        h.pvc.UID,
+	}
+}
+
+func (h *harness) cleanup(ctx context.Context) error {
+	errs := []string{}
+	for i := len(h.created) - 1; i >= 0; i-- {
+		if err := h.client.Delete(ctx, h.created[i]); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, fmt.Sprintf("unable to delete %s: %s", h.created[i].GetName(), err.Error()))
+			continue
+		}
+
+		if err := h.client.Get(ctx, client.ObjectKeyFromObject(h.created[i]), h.created[i]); !apierrors.IsNotFound(err) {
+			errs = append(errs, "object not deleted: "+h.created[i].GetName())
+		}
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("unable to clean up: %s", strings.Join(errs, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package selftest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// failingClient fails the given operation of objects of the given type
+type failingClient struct {
+	client.Client
+	create client.Object
+	delete client.Object
+}
+
+func isSameType(a, b client.Object) bool {
+	if a == nil || b == nil {
+		return false
+	}
+
+	switch a.(type) {
+	case *corev1.PersistentVolumeClaim:
+		_, ok := b.(*corev1.PersistentVolumeClaim)
+		return ok
+	case *batchv1.Job:
+		_, ok := b.(*batchv1.Job)
+		return ok
+	case *corev1.Pod:
+		_, ok := b.(*corev1.Pod)
+		return ok
+	}
+
+	return false
+}
+
+func (c *failingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if isSameType(c.create, obj) {
+		return errors.New("create failed")
+	}
+
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *failingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if isSameType(c.delete, obj) {
+		return errors.New("delete failed")
+	}
+
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func TestRun(t *testing.T) {
+	cases := map[string]struct {
+		create         client.Object
+		delete         client.Object
+		mutate         func(*discoblocksondatiov1.DiskConfig)
+		expectedFailed Stage
+	}{
+		"pass": {},
+		"provision failed": {
+			create:         &corev1.PersistentVolumeClaim{},
+			expectedFailed: StageProvision,
+		},
+		"mount failed": {
+			create:         &batchv1.Job{},
+			expectedFailed: StageMount,
+		},
+		"low-space not detected": {
+			mutate: func(config *discoblocksondatiov1.DiskConfig) {
+				config.Spec.Policy.UpscaleTriggerPercentage = intstr.FromInt(100)
+			},
+			expectedFailed: StageMetric,
+		},
+		"resize failed": {
+			mutate: func(config *discoblocksondatiov1.DiskConfig) {
+				config.Spec.Policy.MaximumCapacityOfDisk = resource.MustParse("1Gi")
+			},
+			expectedFailed: StageResize,
+		},
+		"cleanup failed": {
+			delete:         &corev1.Pod{},
+			expectedFailed: StageCleanup,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			kubeClient := fake.NewClientBuilder().Build()
+
+			config := DefaultConfig()
+			if c.mutate != nil {
+				c.mutate(config)
+			}
+
+			results, err := Run(context.Background(), &failingClient{
+				Client: kubeClient,
+				create: c.create,
+				delete: c.delete,
+			}, config)
+
+			assert.Equal(t, c.expectedFailed != "", err != nil, "invalid error")
+
+			failed := Stage("")
+			for _, result := range results {
+				if result.Error != nil {
+					failed = result.Stage
+					break
+				}
+			}
+			assert.Equal(t, c.expectedFailed, failed, "invalid failed stage")
+
+			assert.Equal(t, StageCleanup, results[len(results)-1].Stage, "cleanup not executed")
+
+			if c.expectedFailed != StageCleanup {
+				pvcs := corev1.PersistentVolumeClaimList{}
+				assert.Nil(t, kubeClient.List(context.Background(), &pvcs), "unable to list PVCs")
+				assert.Empty(t, pvcs.Items, "PVCs not cleaned up")
+
+				jobs := batchv1.JobList{}
+				assert.Nil(t, kubeClient.List(context.Background(), &jobs), "unable to list Jobs")
+				assert.Empty(t, jobs.Items, "Jobs not cleaned up")
+
+				pods := corev1.PodList{}
+				assert.Nil(t, kubeClient.List(context.Background(), &pods), "unable to list Pods")
+				assert.Empty(t, pods.Items, "Pods not cleaned up")
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Defaults of the activity stream
+const (
+	DefaultActivityStreamSize = 100
+	DefaultActivityStreamTTL  = 24 * time.Hour
+)
+
+// ActivityStreamFlushPeriod is the period of writing batched entries to the activity stream
+const ActivityStreamFlushPeriod = 10 * time.Second
+
+const activityStreamKey = "activity.json"
+
+// ActivityEntry is an action of the operator in the activity stream
+type ActivityEntry struct {
+	Time      metav1.Time `json:"time"`
+	Type      string      `json:"type"`
+	Action    string      `json:"action"`
+	Reason    string      `json:"reason"`
+	Note      string      `json:"note,omitempty"`
+	Kind      string      `json:"kind,omitempty"`
+	Namespace string      `json:"namespace,omitempty"`
+	Name      string      `json:"name"`
+}
+
+// ActivityStream aggregates actions of all objects in one place
+type ActivityStream interface {
+	Append(ctx context.Context, entry *ActivityEntry) error
+	// Start writes appended entries periodically until the context is done
+	Start(ctx context.Context) error
+}
+
+// configMapActivityStream keeps the latest entries as a JSON list in a single ConfigMap, oldest first.
+// Entries are batched in memory and written every FlushPeriod, reads go through Reader
+// because the operator doesn't watch ConfigMaps
+type configMapActivityStream struct {
+	Name        string
+	Namespace   string
+	Size        int
+	TTL         time.Duration
+	FlushPeriod time.Duration
+	Client      client.Client
+	Reader      client.Reader
+	Logger      logr.Logger
+
+	lock    sync.Mutex
+	pending []ActivityEntry
+}
+
+// Append queues the entry for the next flush, queue holds at most Size entries
+func (as *configMapActivityStream) Append(_ context.Context, entry *ActivityEntry) error {
+	as.lock.Lock()
+	defer as.lock.Unlock()
+
+	as.pending = append(as.pending, *entry)
+	if as.Size > 0 && len(as.pending) > as.Size {
+		as.pending = as.pending[len(as.pending)-as.Size:]
+	}
+
+	return nil
+}
+
+// Start flushes the stream every FlushPeriod and once more on stop
+func (as *configMapActivityStream) Start(ctx context.Context) error {
+	ticker := time.NewTicker(as.FlushPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+			defer cancel()
+
+			if err := as.Flush(flushCtx); err != nil {
+				as.Logger.Error(err, "Unable to flush activity stream")
+			}
+
+			return nil
+		case <-ticker.C:
+			flushCtx, cancel := context.WithTimeout(ctx, eventTimeout)
+			if err := as.Flush(flushCtx); err != nil {
+				as.Logger.Error(err, "Unable to flush activity stream")
+			}
+			cancel()
+		}
+	}
+}
+
+// NeedLeaderElection returns false, webhooks of every replica append to the stream
+func (as *configMapActivityStream) NeedLeaderElection() bool {
+	return false
+}
+
+// Flush writes queued entries to the stream and prunes old entries, concurrent updates are retried.
+// Entries are requeued if the write fails
+func (as *configMapActivityStream) Flush(ctx context.Context) error {
+	as.lock.Lock()
+	pending := as.pending
+	as.pending = nil
+	as.lock.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm := corev1.ConfigMap{}
+		if err := as.Reader.Get(ctx, client.ObjectKey{Namespace: as.Namespace, Name: as.Name}, &cm); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return fmt.Errorf("unable to fetch activity stream: %w", err)
+			}
+
+			cm = corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      as.Name,
+					Namespace: as.Namespace,
+					Labels: map[string]string{
+						"app": "discoblocks",
+					},
+				},
+			}
+		}
+
+		entries := []ActivityEntry{}
+		if raw, ok := cm.Data[activityStreamKey]; ok {
+			if err := json.Unmarshal([]byte(raw), &entries); err != nil {
+				// Broken stream would block all actions, it starts over
+				entries = []ActivityEntry{}
+			}
+		}
+
+		entries = PruneActivity(append(entries, pending...), as.Size, as.TTL, pending[len(pending)-1].Time.Time)
+
+		content, err := json.Marshal(entries)
+		if err != nil {
+			return fmt.Errorf("unable to marshal activity stream: %w", err)
+		}
+
+		cm.Data = map[string]string{
+			activityStreamKey: string(content),
+		}
+
+		if cm.ResourceVersion == "" {
+			if err := as.Client.Create(ctx, &cm); err != nil {
+				if apierrors.IsAlreadyExists(err) {
+					return apierrors.NewConflict(corev1.Resource("configmaps"), as.Name, err)
+				}
+
+				return fmt.Errorf("unable to create activity stream: %w", err)
+			}
+
+			return nil
+		}
+
+		return as.Client.Update(ctx, &cm)
+	})
+	if err != nil {
+		as.lock.Lock()
+		as.pending = append(pending, as.pending...)
+		if as.Size > 0 && len(as.pending) > as.Size {
+			as.pending = as.pending[len(as.pending)-as.Size:]
+		}
+		as.lock.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+// PruneActivity drops entries older than TTL, then the oldest ones above size
+func PruneActivity(entries []ActivityEntry, size int, ttl time.Duration, now time.Time) []ActivityEntry {
+	pruned := []ActivityEntry{}
+	for i := range entries {
+		if ttl > 0 && now.Sub(entries[i].Time.Time) > ttl {
+			continue
+		}
+
+		pruned = append(pruned, entries[i])
+	}
+
+	if size > 0 && len(pruned) > size {
+		pruned = pruned[len(pruned)-size:]
+	}
+
+	return pruned
+}
+
+// NewActivityStream creates a new ConfigMap activity stream, empty name disables the stream.
+// apiReader should read the API server directly, see manager.GetAPIReader
+func NewActivityStream(name, namespace string, size int, ttl time.Duration, k8sClient client.Client, apiReader client.Reader, logger logr.Logger) (ActivityStream, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	if namespace == "" {
+		return nil, errors.New("namespace is required for activity stream")
+	}
+
+	return &configMapActivityStream{
+		Name:        name,
+		Namespace:   namespace,
+		Size:        size,
+		TTL:         ttl,
+		FlushPeriod: ActivityStreamFlushPeriod,
+		Client:      k8sClient,
+		Reader:      apiReader,
+		Logger:      logger,
+	}, nil
+}
+
+// activityEventService sends events and appends them to the activity stream too
+type activityEventService struct {
+	EventService
+	Activity ActivityStream
+}
+
+// SendWarning sends a warning event and appends it to the activity stream
+func (es *activityEventService) SendWarning(namespace, instance, action, reason, note string, regarding, related client.Object) error {
+	if err := es.EventService.SendWarning(namespace, instance, action, reason, note, regarding, related); err != nil {
+		return err
+	}
+
+	return es.append("Warning", action, reason, note, regarding)
+}
+
+// SendNormal sends a normal event and appends it to the activity stream
+func (es *activityEventService) SendNormal(namespace, instance, action, reason, note string, regarding, related client.Object) error {
+	if err := es.EventService.SendNormal(namespace, instance, action, reason, note, regarding, related); err != nil {
+		return err
+	}
+
+	return es.append("Normal", action, reason, note, regarding)
+}
+
+func (es *activityEventService) append(eventType, action, reason, note string, regarding client.Object) error {
+	ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+	defer cancel()
+
+	if err := es.Activity.Append(ctx, &ActivityEntry{
+		Time:      metav1.NewTime(time.Now()),
+		Type:      eventType,
+		Action:    action,
+		Reason:    reason,
+		Note:      note,
+		Kind:      regarding.GetObjectKind().GroupVersionKind().Kind,
+		Namespace: regarding.GetNamespace(),
+		Name:      regarding.GetName(),
+	}); err != nil {
+		return fmt.Errorf("unable to append activity: %w", err)
+	}
+
+	return nil
+}
+
+// NewActivityEventService wraps the event service to append every sent event to the activity stream
+func NewActivityEventService(events EventService, activity ActivityStream) EventService {
+	return &activityEventService{
+		EventService: events,
+		Activity:     activity,
+	}
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPruneActivity(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	entry := func(name string, age time.Duration) ActivityEntry {
+		return ActivityEntry{Time: metav1.NewTime(now.Add(-age)), Name: name}
+	}
+
+	cases := map[string]struct {
+		entries       []ActivityEntry
+		size          int
+		ttl           time.Duration
+		expectedNames []string
+	}{
+		"empty": {
+			size:          2,
+			ttl:           time.Hour,
+			expectedNames: []string{},
+		},
+		"within bounds": {
+			entries:       []ActivityEntry{entry("a", time.Minute), entry("b", 0)},
+			size:          2,
+			ttl:           time.Hour,
+			expectedNames: []string{"a", "b"},
+		},
+		"above size": {
+			entries:       []ActivityEntry{entry("a", 2*time.Minute), entry("b", time.Minute), entry("c", 0)},
+			size:          2,
+			ttl:           time.Hour,
+			expectedNames: []string{"b", "c"},
+		},
+		"expired": {
+			entries:       []ActivityEntry{entry("a", 2*time.Hour), entry("b", time.Minute)},
+			size:          2,
+			ttl:           time.Hour,
+			expectedNames: []string{"b"},
+		},
+		"no TTL": {
+			entries:       []ActivityEntry{entry("a", 2*time.Hour), entry("b", time.Minute)},
+			size:          2,
+			expectedNames: []string{"a", "b"},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			names := []string{}
+			for _, e := range PruneActivity(c.entries, c.size, c.ttl, now) {
+				names = append(names, e.Name)
+			}
+
+			assert.Equal(t, c.expectedNames, names, "invalid entries")
+		})
+	}
+}
+
+func TestActivityEventService(t *testing.T) {
+	t.Parallel()
+
+	kubeClient := fake.NewClientBuilder().Build()
+
+	activity, err := NewActivityStream("activity", "discoblocks", 2, time.Hour, unsyncedCacheClient{Client: kubeClient}, kubeClient, logr.Discard())
+	require.Nil(t, err, "unable to create activity stream")
+
+	events := NewActivityEventService(NewEventService("controller", kubeClient), activity)
+
+	pod := corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Pod",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "default",
+			UID:       "pod-uid",
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		require.Nil(t, events.SendNormal("default", "Discoblocks", "PVC Monitor", "Resize", fmt.Sprintf("note %d", i), &pod, nil), "unable to send event")
+	}
+	require.Nil(t, events.SendWarning("default", "Discoblocks", "PVC Monitor", "Failed", "failure", &pod, nil), "unable to send event")
+
+	eventList := eventsv1.EventList{}
+	require.Nil(t, kubeClient.List(context.Background(), &eventList, client.InNamespace("default")), "unable to list events")
+	assert.Len(t, eventList.Items, 4, "per-object events missing")
+
+	cm := corev1.ConfigMap{}
+	err = kubeClient.Get(context.Background(), client.ObjectKey{Namespace: "discoblocks", Name: "activity"}, &cm)
+	require.True(t, apierrors.IsNotFound(err), "activity stream written before flush")
+
+	require.Nil(t, activity.(*configMapActivityStream).Flush(context.Background()), "unable to flush activity stream")
+	require.Nil(t, kubeClient.Get(context.Background(), client.ObjectKey{Namespace: "discoblocks", Name: "activity"}, &cm), "unable to fetch activity stream")
+
+	entries := []ActivityEntry{}
+	require.Nil(t, json.Unmarshal([]byte(cm.Data[activityStreamKey]), &entries), "unable to unmarshal activity stream")
+
+	require.Len(t, entries, 2, "activity stream not pruned")
+	assert.Equal(t, "note 2", entries[0].Note, "invalid first entry")
+	assert.Equal(t, "Warning", entries[1].Type, "invalid type of last entry")
+	assert.Equal(t, "Failed", entries[1].Reason, "invalid reason of last entry")
+	assert.Equal(t, "Pod", entries[1].Kind, "invalid kind of last entry")
+	assert.Equal(t, "default", entries[1].Namespace, "invalid namespace of last entry")
+	assert.Equal(t, "pod", entries[1].Name, "invalid name of last entry")
+}
+
+func TestActivityStreamFlushRequeues(t *testing.T) {
+	t.Parallel()
+
+	kubeClient := fake.NewClientBuilder().Build()
+
+	activity, err := NewActivityStream("activity", "discoblocks", 10, time.Hour, kubeClient, unsyncedCacheClient{Client: kubeClient}, logr.Discard())
+	require.Nil(t, err, "unable to create activity stream")
+
+	stream := activity.(*configMapActivityStream)
+
+	require.Nil(t, stream.Append(context.Background(), &ActivityEntry{Time: metav1.Now(), Name: "first"}), "unable to append entry")
+	require.NotNil(t, stream.Flush(context.Background()), "flush succeeded without API server")
+
+	require.Nil(t, stream.Append(context.Background(), &ActivityEntry{Time: metav1.Now(), Name: "second"}), "unable to append entry")
+
+	names := []string{}
+	for _, e := range stream.pending {
+		names = append(names, e.Name)
+	}
+
+	assert.Equal(t, []string{"first", "second"}, names, "failed entries not requeued")
+}
@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const auditTimeout = time.Minute
+
+// Defaults of audit record retention of ConfigMap sink
+const (
+	DefaultAuditRetentionSize = 1000
+	DefaultAuditRetentionTTL  = 30 * 24 * time.Hour
+)
+
+const auditRecordKey = "record.json"
+
+// Supported audit sinks
+const (
+	AuditSinkNone      = ""
+	AuditSinkLog       = "log"
+	AuditSinkConfigMap = "configmap"
+)
+
+// Audited operations
+const (
+	AuditOperationResize     = "resize"
+	AuditOperationNewDisk    = "new-disk"
+	AuditOperationMaxReached = "max-reached"
+	AuditOperationGrowFS     = "grow-filesystem"
+)
+
+// AuditRecord describes a capacity decision
+type AuditRecord struct {
+	Time        metav1.Time `json:"time"`
+	Actor       string      `json:"actor"`
+	Operation   string      `json:"operation"`
+	ConfigName  string      `json:"configName"`
+	Namespace   string      `json:"namespace"`
+	PodName     string      `json:"podName,omitempty"`
+	PVCName     string      `json:"pvcName,omitempty"`
+	OldCapacity string      `json:"oldCapacity,omitempty"`
+	NewCapacity string      `json:"newCapacity,omitempty"`
+	Reason      string      `json:"reason,omitempty"`
+}
+
+// AuditService main interface of audit service
+type AuditService interface {
+	Record(*AuditRecord) error
+}
+
+// noopAuditService drops all records
+type noopAuditService struct{}
+
+// Record does nothing
+func (noopAuditService) Record(_ *AuditRecord) error {
+	return nil
+}
+
+// logAuditService writes records as structured log lines
+type logAuditService struct {
+	ControllerInstance string
+	Logger             logr.Logger
+}
+
+// Record writes the record to the log
+func (as *logAuditService) Record(record *AuditRecord) error {
+	fillAuditRecord(as.ControllerInstance, record)
+
+	as.Logger.Info("Audit", "time", record.Time.UTC().Format(time.RFC3339), "actor", record.Actor, "operation", record.Operation,
+		"dc_name", record.ConfigName, "namespace", record.Namespace, "pod_name", record.PodName, "pvc_name", record.PVCName,
+		"old_capacity", record.OldCapacity, "new_capacity", record.NewCapacity, "reason", record.Reason)
+
+	return nil
+}
+
+// configMapAuditService persists every record as an immutable ConfigMap,
+// records older than TTL and the oldest ones above size are deleted
+type configMapAuditService struct {
+	ControllerInstance string
+	Namespace          string
+	Size               int
+	TTL                time.Duration
+	Client             client.Client
+	Logger             logr.Logger
+}
+
+// Record creates a new immutable ConfigMap for the record and prunes the old ones
+func (as *configMapAuditService) Record(record *AuditRecord) error {
+	fillAuditRecord(as.ControllerInstance, record)
+
+	content, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("unable to marshal audit record: %w", err)
+	}
+
+	name, err := RenderResourceName(true, "audit", record.Namespace, record.ConfigName, record.PVCName, record.Time.String())
+	if err != nil {
+		return fmt.Errorf("unable to render audit name: %w", err)
+	}
+
+	immutable := true
+
+	cm := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: as.Namespace,
+			Labels: map[string]string{
+				"app":                 "discoblocks",
+				AuditLabel():          record.Operation,
+				DiskConfigNameLabel(): record.ConfigName,
+			},
+		},
+		Data: map[string]string{
+			auditRecordKey: string(content),
+		},
+		Immutable: &immutable,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), auditTimeout)
+	defer cancel()
+
+	if err := as.Client.Create(ctx, &cm); err != nil {
+		return fmt.Errorf("unable to create audit record: %w", err)
+	}
+
+	// Record is persisted, failed pruning is retried at the next record
+	if err := as.prune(ctx, record.Time.Time); err != nil {
+		as.Logger.Error(err, "Unable to prune audit records")
+	}
+
+	return nil
+}
+
+// prune deletes the audit records dropped by PruneAuditRecords
+func (as *configMapAuditService) prune(ctx context.Context, now time.Time) error {
+	cms := corev1.ConfigMapList{}
+	if err := as.Client.List(ctx, &cms, client.InNamespace(as.Namespace), client.HasLabels{AuditLabel()}); err != nil {
+		return fmt.Errorf("unable to list audit records: %w", err)
+	}
+
+	for _, cm := range PruneAuditRecords(cms.Items, as.Size, as.TTL, now) {
+		if err := as.Client.Delete(ctx, cm); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("unable to delete audit record %s: %w", cm.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// PruneAuditRecords returns the audit records older than TTL, then the oldest ones above size.
+// Records are ordered by their time, creation time is used if the record is unreadable.
+func PruneAuditRecords(cms []corev1.ConfigMap, size int, ttl time.Duration, now time.Time) []*corev1.ConfigMap {
+	times := make(map[*corev1.ConfigMap]time.Time, len(cms))
+	records := make([]*corev1.ConfigMap, 0, len(cms))
+	for i := range cms {
+		record := AuditRecord{}
+		if err := json.Unmarshal([]byte(cms[i].Data[auditRecordKey]), &record); err != nil || record.Time.IsZero() {
+			record.Time = cms[i].CreationTimestamp
+		}
+
+		times[&cms[i]] = record.Time.Time
+		records = append(records, &cms[i])
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return times[records[i]].After(times[records[j]])
+	})
+
+	pruned := []*corev1.ConfigMap{}
+	kept := 0
+	for _, cm := range records {
+		if ttl > 0 && now.Sub(times[cm]) > ttl || size > 0 && kept >= size {
+			pruned = append(pruned, cm)
+			continue
+		}
+
+		kept++
+	}
+
+	return pruned
+}
+
+// RenderPlan renders human readable description of a decision
+func RenderPlan(record *AuditRecord) string {
+	switch record.Operation {
+	case AuditOperationResize:
+		return fmt.Sprintf("PVC %s would grow from %s to %s (%s)", record.PVCName, record.OldCapacity, record.NewCapacity, record.Reason)
+	case AuditOperationNewDisk:
+		return fmt.Sprintf("PVC %s would get a new disk of %s (%s)", record.PVCName, record.NewCapacity, record.Reason)
+	case AuditOperationGrowFS:
+		return fmt.Sprintf("PVC %s would grow file-system from %s to %s (%s)", record.PVCName, record.OldCapacity, record.NewCapacity, record.Reason)
+	default:
+		return fmt.Sprintf("PVC %s %s (%s)", record.PVCName, record.Operation, record.Reason)
+	}
+}
+
+func fillAuditRecord(actor string, record *AuditRecord) {
+	if record.Time.IsZero() {
+		record.Time = metav1.NewTime(time.Now())
+	}
+
+	if record.Actor == "" {
+		record.Actor = actor
+	}
+}
+
+// NewAuditService creates a new audit service by sink type, size and TTL limit the records of ConfigMap sink, zero means no limit
+func NewAuditService(sink, controllerID, namespace string, size int, ttl time.Duration, k8sClient client.Client, logger logr.Logger) (AuditService, error) {
+	switch sink {
+	case AuditSinkNone:
+		return noopAuditService{}, nil
+	case AuditSinkLog:
+		return &logAuditService{
+			ControllerInstance: controllerID,
+			Logger:             logger,
+		}, nil
+	case AuditSinkConfigMap:
+		if namespace == "" {
+			return nil, fmt.Errorf("namespace is required for audit sink: %s", sink)
+		}
+
+		return &configMapAuditService{
+			ControllerInstance: controllerID,
+			Namespace:          namespace,
+			Size:               size,
+			TTL:                ttl,
+			Client:             k8sClient,
+			Logger:             logger,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported audit sink: %s", sink)
+	}
+}
@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNewAuditService(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		sink          string
+		namespace     string
+		expectedError bool
+	}{
+		"none": {
+			sink: AuditSinkNone,
+		},
+		"log": {
+			sink: AuditSinkLog,
+		},
+		"configmap": {
+			sink:      AuditSinkConfigMap,
+			namespace: "kube-system",
+		},
+		"configmap without namespace": {
+			sink:          AuditSinkConfigMap,
+			expectedError: true,
+		},
+		"unknown": {
+			sink:          "foo",
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			service, err := NewAuditService(c.sink, "controller", c.namespace, 0, 0, fake.NewClientBuilder().Build(), logr.Discard())
+
+			if c.expectedError {
+				assert.NotNil(t, err, "error missing")
+				return
+			}
+
+			require.Nil(t, err, "unexpected error")
+			assert.Nil(t, service.Record(&AuditRecord{Operation: AuditOperationResize}), "unable to record")
+		})
+	}
+}
+
+func TestConfigMapAuditServiceRecord(t *testing.T) {
+	kubeClient := fake.NewClientBuilder().Build()
+
+	service, err := NewAuditService(AuditSinkConfigMap, "controller", "kube-system", DefaultAuditRetentionSize, DefaultAuditRetentionTTL, kubeClient, logr.Discard())
+	require.Nil(t, err, "unable to create service")
+
+	err = service.Record(&AuditRecord{
+		Operation:   AuditOperationResize,
+		ConfigName:  "config",
+		Namespace:   "default",
+		PodName:     "pod",
+		PVCName:     "pvc",
+		OldCapacity: "1Gi",
+		NewCapacity: "2Gi",
+		Reason:      "used 81.00% >= 80%",
+	})
+	require.Nil(t, err, "unable to record")
+
+	cms := corev1.ConfigMapList{}
+	require.Nil(t, kubeClient.List(context.Background(), &cms, client.InNamespace("kube-system")), "unable to list")
+	require.Len(t, cms.Items, 1, "invalid number of records")
+
+	cm := cms.Items[0]
+	assert.Equal(t, AuditOperationResize, cm.Labels["discoblocks/audit"], "invalid operation label")
+	assert.Equal(t, "config", cm.Labels["discoblocks/dc-name"], "invalid config label")
+	require.NotNil(t, cm.Immutable, "immutable missing")
+	assert.True(t, *cm.Immutable, "record is mutable")
+
+	record := AuditRecord{}
+	require.Nil(t, json.Unmarshal([]byte(cm.Data["record.json"]), &record), "invalid record")
+
+	assert.Equal(t, "controller", record.Actor, "invalid actor")
+	assert.Equal(t, AuditOperationResize, record.Operation, "invalid operation")
+	assert.Equal(t, "config", record.ConfigName, "invalid config name")
+	assert.Equal(t, "default", record.Namespace, "invalid namespace")
+	assert.Equal(t, "pod", record.PodName, "invalid pod name")
+	assert.Equal(t, "pvc", record.PVCName, "invalid PVC name")
+	assert.Equal(t, "1Gi", record.OldCapacity, "invalid old capacity")
+	assert.Equal(t, "2Gi", record.NewCapacity, "invalid new capacity")
+	assert.Equal(t, "used 81.00% >= 80%", record.Reason, "invalid reason")
+	assert.False(t, record.Time.IsZero(), "time missing")
+}
+
+func TestPruneAuditRecords(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	record := func(name string, age time.Duration) corev1.ConfigMap {
+		content, err := json.Marshal(AuditRecord{Time: metav1.NewTime(now.Add(-age))})
+		require.Nil(t, err, "unable to marshal record")
+
+		return corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Data:       map[string]string{auditRecordKey: string(content)},
+		}
+	}
+
+	unreadable := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "unreadable", CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour))},
+		Data:       map[string]string{auditRecordKey: "{"},
+	}
+
+	cases := map[string]struct {
+		records       []corev1.ConfigMap
+		size          int
+		ttl           time.Duration
+		expectedNames []string
+	}{
+		"empty": {
+			size:          2,
+			ttl:           time.Hour,
+			expectedNames: []string{},
+		},
+		"within bounds": {
+			records:       []corev1.ConfigMap{record("a", time.Minute), record("b", 0)},
+			size:          2,
+			ttl:           time.Hour,
+			expectedNames: []string{},
+		},
+		"above size": {
+			records:       []corev1.ConfigMap{record("c", 0), record("a", 2*time.Minute), record("b", time.Minute)},
+			size:          2,
+			ttl:           time.Hour,
+			expectedNames: []string{"a"},
+		},
+		"expired": {
+			records:       []corev1.ConfigMap{record("a", 2*time.Hour), record("b", time.Minute)},
+			size:          2,
+			ttl:           time.Hour,
+			expectedNames: []string{"a"},
+		},
+		"unreadable expired by creation time": {
+			records:       []corev1.ConfigMap{unreadable, record("b", time.Minute)},
+			size:          2,
+			ttl:           time.Hour,
+			expectedNames: []string{"unreadable"},
+		},
+		"no limits": {
+			records:       []corev1.ConfigMap{record("a", 2*time.Hour), record("b", time.Minute), record("c", 0)},
+			expectedNames: []string{},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			names := []string{}
+			for _, cm := range PruneAuditRecords(c.records, c.size, c.ttl, now) {
+				names = append(names, cm.Name)
+			}
+
+			assert.Equal(t, c.expectedNames, names, "invalid pruned records")
+		})
+	}
+}
+
+func TestConfigMapAuditServicePrunesRecords(t *testing.T) {
+	now := time.Now()
+
+	existing := func(name string, age time.Duration, labels map[string]string) *corev1.ConfigMap {
+		content, err := json.Marshal(AuditRecord{Time: metav1.NewTime(now.Add(-age))})
+		require.Nil(t, err, "unable to marshal record")
+
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kube-system", Labels: labels},
+			Data:       map[string]string{auditRecordKey: string(content)},
+		}
+	}
+
+	auditLabels := map[string]string{"app": "discoblocks", AuditLabel(): AuditOperationResize}
+
+	kubeClient := fake.NewClientBuilder().WithObjects(
+		existing("expired", 2*time.Hour, auditLabels),
+		existing("oldest", 3*time.Minute, auditLabels),
+		existing("newest", time.Minute, auditLabels),
+		existing("other", 3*time.Hour, map[string]string{"app": "discoblocks"}),
+	).Build()
+
+	service, err := NewAuditService(AuditSinkConfigMap, "controller", "kube-system", 2, time.Hour, kubeClient, logr.Discard())
+	require.Nil(t, err, "unable to create service")
+
+	require.Nil(t, service.Record(&AuditRecord{Operation: AuditOperationNewDisk, ConfigName: "config", Namespace: "default", PVCName: "pvc"}), "unable to record")
+
+	cms := corev1.ConfigMapList{}
+	require.Nil(t, kubeClient.List(context.Background(), &cms, client.InNamespace("kube-system")), "unable to list")
+
+	names := []string{}
+	for i := range cms.Items {
+		if cms.Items[i].Labels[AuditLabel()] == AuditOperationNewDisk {
+			names = append(names, "new")
+			continue
+		}
+
+		names = append(names, cms.Items[i].Name)
+	}
+	sort.Strings(names)
+
+	assert.Equal(t, []string{"new", "newest", "other"}, names, "invalid retained records")
+}
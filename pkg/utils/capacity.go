@@ -2,8 +2,13 @@ package utils
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
 	"strconv"
+	"time"
+
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 var (
@@ -27,3 +32,99 @@ func ParseCapacity(capacity string) (uint16, string, error) {
 
 	return uint16(sizeInt), string(parts[0][2]), nil
 }
+
+// RenderResizeStep returns the capacity to extend with, capped by maximum step size if it is set
+func RenderResizeStep(extend, maxStep resource.Quantity) resource.Quantity {
+	if maxStep.Sign() > 0 && extend.Cmp(maxStep) == 1 {
+		return maxStep.DeepCopy()
+	}
+
+	return extend.DeepCopy()
+}
+
+// IsCapacityAtMax checks disk can't be extended anymore, actual capacity above maximum is treated as maximum
+func IsCapacityAtMax(actual, extend, max resource.Quantity) bool {
+	if actual.Cmp(max) >= 0 {
+		return true
+	}
+
+	newCapacity := extend.DeepCopy()
+	newCapacity.Add(actual)
+
+	return newCapacity.Cmp(max) == 1
+}
+
+// ResizeAction is the scale action of a disk
+type ResizeAction string
+
+const (
+	// ResizeActionNone means the disk has enough space
+	ResizeActionNone ResizeAction = "none"
+	// ResizeActionGrow means the disk has to be extended to the new capacity
+	ResizeActionGrow ResizeAction = "grow"
+	// ResizeActionNewDisk means the disk can't be extended, a new disk is needed
+	ResizeActionNewDisk ResizeAction = "newdisk"
+)
+
+// DecideResize decides scale action of a disk by its used percentage, actual capacity and policy.
+// New disk is forced by request, new capacity is returned only on grow, it is never below minimum capacity of disk.
+func DecideResize(used, upscaleTrigger float64, actual resource.Quantity, policy *discoblocksondatiov1.Policy, newDiskRequested bool) (resource.Quantity, ResizeAction) {
+	if newDiskRequested {
+		return resource.Quantity{}, ResizeActionNewDisk
+	}
+
+	if used < upscaleTrigger {
+		return resource.Quantity{}, ResizeActionNone
+	}
+
+	resizeStep := RenderResizeStep(policy.ExtendCapacity, policy.MaximumStepSize)
+	if resizeStep.Sign() <= 0 || IsCapacityAtMax(actual, resizeStep, policy.MaximumCapacityOfDisk) {
+		return resource.Quantity{}, ResizeActionNewDisk
+	}
+
+	newCapacity := resizeStep.DeepCopy()
+	newCapacity.Add(actual)
+
+	return ClampMinimumCapacity(newCapacity, policy), ResizeActionGrow
+}
+
+// ClampMinimumCapacity raises the capacity to minimum capacity of disk of the policy
+func ClampMinimumCapacity(capacity resource.Quantity, policy *discoblocksondatiov1.Policy) resource.Quantity {
+	if capacity.Cmp(policy.MinimumCapacityOfDisk) == -1 {
+		return policy.MinimumCapacityOfDisk.DeepCopy()
+	}
+
+	return capacity
+}
+
+// IsInRollout decides whether the object of the given ID takes part in a rollout of the percentage,
+// membership is stable and the set only grows with the percentage. Zero percentage means unset.
+func IsInRollout(id string, percentage uint8) (bool, error) {
+	const hundred = 100
+	if percentage == 0 || percentage >= hundred {
+		return true, nil
+	}
+
+	hash, err := Hash(id)
+	if err != nil {
+		return false, fmt.Errorf("unable to calculate hash: %w", err)
+	}
+
+	return hash%hundred < uint32(percentage), nil
+}
+
+// RolloutPercentage returns the percentage of a rollout started at start, raised by step in every passed window.
+// Zero step keeps the percentage static, zero percentage means unset.
+func RolloutPercentage(percentage, step uint8, window time.Duration, start, now time.Time) uint8 {
+	const hundred = 100
+	if percentage == 0 || step == 0 || window <= 0 || !now.After(start) {
+		return percentage
+	}
+
+	grown := uint64(percentage) + uint64(step)*uint64(now.Sub(start)/window)
+	if grown >= hundred {
+		return hundred
+	}
+
+	return uint8(grown)
+}
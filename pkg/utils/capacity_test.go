@@ -1,9 +1,13 @@
 package utils
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
 	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 func TestParseCapacity(t *testing.T) {
@@ -50,3 +54,377 @@ func TestParseCapacity(t *testing.T) {
 		})
 	}
 }
+
+func TestIsCapacityAtMax(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		actual   string
+		extend   string
+		max      string
+		expected bool
+	}{
+		"room to grow": {
+			actual: "1Gi",
+			extend: "1Gi",
+			max:    "3Gi",
+		},
+		"grow to max": {
+			actual: "2Gi",
+			extend: "1Gi",
+			max:    "3Gi",
+		},
+		"grow above max": {
+			actual:   "2Gi",
+			extend:   "2Gi",
+			max:      "3Gi",
+			expected: true,
+		},
+		"actual is max": {
+			actual:   "3Gi",
+			extend:   "0",
+			max:      "3Gi",
+			expected: true,
+		},
+		"actual above max": {
+			actual:   "5Gi",
+			extend:   "1Gi",
+			max:      "3Gi",
+			expected: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			atMax := IsCapacityAtMax(resource.MustParse(c.actual), resource.MustParse(c.extend), resource.MustParse(c.max))
+
+			assert.Equal(t, c.expected, atMax, "invalid max detection")
+		})
+	}
+}
+
+func TestRenderResizeStep(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		extend   string
+		maxStep  string
+		expected string
+	}{
+		"no cap": {
+			extend:   "500Gi",
+			maxStep:  "0",
+			expected: "500Gi",
+		},
+		"below cap": {
+			extend:   "10Gi",
+			maxStep:  "50Gi",
+			expected: "10Gi",
+		},
+		"above cap": {
+			extend:   "500Gi",
+			maxStep:  "50Gi",
+			expected: "50Gi",
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			step := RenderResizeStep(resource.MustParse(c.extend), resource.MustParse(c.maxStep))
+
+			assert.Zero(t, step.Cmp(resource.MustParse(c.expected)), "invalid resize step: %s", step.String())
+		})
+	}
+}
+
+func TestIsInRollout(t *testing.T) {
+	t.Parallel()
+
+	const pvcs = 1000
+
+	cases := map[string]struct {
+		percentage uint8
+		min        int
+		max        int
+	}{
+		"unset": {
+			percentage: 0,
+			min:        pvcs,
+			max:        pvcs,
+		},
+		"canary": {
+			percentage: 10,
+			min:        pvcs / 20,
+			max:        pvcs * 3 / 20,
+		},
+		"half": {
+			percentage: 50,
+			min:        pvcs * 2 / 5,
+			max:        pvcs * 3 / 5,
+		},
+		"full": {
+			percentage: 100,
+			min:        pvcs,
+			max:        pvcs,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			resized := 0
+			for i := 0; i < pvcs; i++ {
+				id := fmt.Sprintf("uid-%d", i)
+
+				in, err := IsInRollout(id, c.percentage)
+				assert.Nil(t, err, "unexpected error")
+
+				if in {
+					resized++
+
+					wider, err := IsInRollout(id, c.percentage+1)
+					assert.Nil(t, err, "unexpected error")
+					assert.True(t, c.percentage == 0 || wider, "rollout shrunk by percentage")
+				}
+
+				again, err := IsInRollout(id, c.percentage)
+				assert.Nil(t, err, "unexpected error")
+				assert.Equal(t, in, again, "unstable rollout")
+			}
+
+			assert.GreaterOrEqual(t, resized, c.min, "too few resizes")
+			assert.LessOrEqual(t, resized, c.max, "too many resizes")
+		})
+	}
+}
+
+func TestDecideResize(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		used             float64
+		actual           string
+		extend           string
+		maxStep          string
+		max              string
+		min              string
+		newDiskRequested bool
+		expectedCapacity string
+		expectedAction   ResizeAction
+	}{
+		"below trigger": {
+			used:             79.9,
+			actual:           "1Gi",
+			extend:           "1Gi",
+			max:              "10Gi",
+			expectedCapacity: "0",
+			expectedAction:   ResizeActionNone,
+		},
+		"at trigger": {
+			used:             80,
+			actual:           "1Gi",
+			extend:           "1Gi",
+			max:              "10Gi",
+			expectedCapacity: "2Gi",
+			expectedAction:   ResizeActionGrow,
+		},
+		"zero available": {
+			used:             100,
+			actual:           "1Gi",
+			extend:           "2Gi",
+			max:              "10Gi",
+			expectedCapacity: "3Gi",
+			expectedAction:   ResizeActionGrow,
+		},
+		"step capped": {
+			used:             90,
+			actual:           "1Gi",
+			extend:           "5Gi",
+			maxStep:          "1Gi",
+			max:              "10Gi",
+			expectedCapacity: "2Gi",
+			expectedAction:   ResizeActionGrow,
+		},
+		"grow to max": {
+			used:             90,
+			actual:           "9Gi",
+			extend:           "1Gi",
+			max:              "10Gi",
+			expectedCapacity: "10Gi",
+			expectedAction:   ResizeActionGrow,
+		},
+		"grow above max": {
+			used:             90,
+			actual:           "9Gi",
+			extend:           "2Gi",
+			max:              "10Gi",
+			expectedCapacity: "0",
+			expectedAction:   ResizeActionNewDisk,
+		},
+		"capped step fits max": {
+			used:             90,
+			actual:           "9Gi",
+			extend:           "2Gi",
+			maxStep:          "1Gi",
+			max:              "10Gi",
+			expectedCapacity: "10Gi",
+			expectedAction:   ResizeActionGrow,
+		},
+		"at max": {
+			used:             90,
+			actual:           "10Gi",
+			extend:           "1Gi",
+			max:              "10Gi",
+			expectedCapacity: "0",
+			expectedAction:   ResizeActionNewDisk,
+		},
+		"over max": {
+			used:             90,
+			actual:           "20Gi",
+			extend:           "1Gi",
+			max:              "10Gi",
+			expectedCapacity: "0",
+			expectedAction:   ResizeActionNewDisk,
+		},
+		"zero step": {
+			used:             90,
+			actual:           "1Gi",
+			extend:           "0",
+			max:              "10Gi",
+			expectedCapacity: "0",
+			expectedAction:   ResizeActionNewDisk,
+		},
+		"growth from small to floor": {
+			used:             90,
+			actual:           "1Gi",
+			extend:           "1Gi",
+			max:              "10Gi",
+			min:              "5Gi",
+			expectedCapacity: "5Gi",
+			expectedAction:   ResizeActionGrow,
+		},
+		"growth above floor": {
+			used:             90,
+			actual:           "5Gi",
+			extend:           "1Gi",
+			max:              "10Gi",
+			min:              "5Gi",
+			expectedCapacity: "6Gi",
+			expectedAction:   ResizeActionGrow,
+		},
+		"below trigger under floor": {
+			used:             10,
+			actual:           "1Gi",
+			extend:           "1Gi",
+			max:              "10Gi",
+			min:              "5Gi",
+			expectedCapacity: "0",
+			expectedAction:   ResizeActionNone,
+		},
+		"requested below trigger": {
+			used:             10,
+			actual:           "1Gi",
+			extend:           "1Gi",
+			max:              "10Gi",
+			newDiskRequested: true,
+			expectedCapacity: "0",
+			expectedAction:   ResizeActionNewDisk,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			policy := discoblocksondatiov1.Policy{
+				ExtendCapacity:        resource.MustParse(c.extend),
+				MaximumCapacityOfDisk: resource.MustParse(c.max),
+			}
+			if c.maxStep != "" {
+				policy.MaximumStepSize = resource.MustParse(c.maxStep)
+			}
+			if c.min != "" {
+				policy.MinimumCapacityOfDisk = resource.MustParse(c.min)
+			}
+
+			const trigger = 80
+			newCapacity, action := DecideResize(c.used, trigger, resource.MustParse(c.actual), &policy, c.newDiskRequested)
+
+			assert.Equal(t, c.expectedAction, action, "invalid action")
+			assert.Equal(t, c.expectedCapacity, newCapacity.String(), "invalid new capacity")
+		})
+	}
+}
+
+func TestRolloutPercentage(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+
+	cases := map[string]struct {
+		percentage uint8
+		step       uint8
+		window     time.Duration
+		elapsed    time.Duration
+		expected   uint8
+	}{
+		"unset": {
+			step:     10,
+			window:   time.Hour,
+			elapsed:  5 * time.Hour,
+			expected: 0,
+		},
+		"static": {
+			percentage: 10,
+			window:     time.Hour,
+			elapsed:    5 * time.Hour,
+			expected:   10,
+		},
+		"not started": {
+			percentage: 10,
+			step:       20,
+			window:     time.Hour,
+			elapsed:    -time.Hour,
+			expected:   10,
+		},
+		"first window": {
+			percentage: 10,
+			step:       20,
+			window:     time.Hour,
+			elapsed:    59 * time.Minute,
+			expected:   10,
+		},
+		"third window": {
+			percentage: 10,
+			step:       20,
+			window:     time.Hour,
+			elapsed:    2*time.Hour + time.Minute,
+			expected:   50,
+		},
+		"finished": {
+			percentage: 10,
+			step:       20,
+			window:     time.Hour,
+			elapsed:    100 * time.Hour,
+			expected:   100,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, c.expected, RolloutPercentage(c.percentage, c.step, c.window, start, start.Add(c.elapsed)), "invalid rollout percentage")
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileCache keeps content of files and reloads all of them if any has changed, for example on certificate rotation
+type FileCache struct {
+	lock     sync.Mutex
+	paths    []string
+	modTimes []time.Time
+	sizes    []int64
+	contents [][]byte
+}
+
+// NewFileCache creates a new cache of the given files
+func NewFileCache(paths ...string) *FileCache {
+	return &FileCache{
+		paths: paths,
+	}
+}
+
+// Get returns content of files in the order of paths
+func (f *FileCache) Get() ([][]byte, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	modTimes := make([]time.Time, len(f.paths))
+	sizes := make([]int64, len(f.paths))
+	changed := f.contents == nil
+
+	for i, path := range f.paths {
+		info, err := os.Stat(filepath.Clean(path))
+		if err != nil {
+			return nil, fmt.Errorf("unable to stat file %s: %w", path, err)
+		}
+
+		modTimes[i] = info.ModTime()
+		sizes[i] = info.Size()
+
+		if !changed && (!modTimes[i].Equal(f.modTimes[i]) || sizes[i] != f.sizes[i]) {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return f.contents, nil
+	}
+
+	contents := make([][]byte, len(f.paths))
+	for i, path := range f.paths {
+		content, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read file %s: %w", path, err)
+		}
+
+		contents[i] = content
+	}
+
+	f.modTimes = modTimes
+	f.sizes = sizes
+	f.contents = contents
+
+	return contents, nil
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileCache(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cert := filepath.Join(dir, "tls.crt")
+	key := filepath.Join(dir, "tls.key")
+
+	require.Nil(t, os.WriteFile(cert, []byte("cert-1"), 0o600), "unable to write cert")
+	require.Nil(t, os.WriteFile(key, []byte("key-1"), 0o600), "unable to write key")
+
+	cache := NewFileCache(cert, key)
+
+	contents, err := cache.Get()
+	require.Nil(t, err, "unable to read files")
+	assert.Equal(t, [][]byte{[]byte("cert-1"), []byte("key-1")}, contents, "invalid content")
+
+	require.Nil(t, os.WriteFile(cert, []byte("cert-2"), 0o600), "unable to rotate cert")
+	require.Nil(t, os.WriteFile(key, []byte("key-2"), 0o600), "unable to rotate key")
+	later := time.Now().Add(time.Minute)
+	require.Nil(t, os.Chtimes(cert, later, later), "unable to touch cert")
+
+	contents, err = cache.Get()
+	require.Nil(t, err, "unable to reload files")
+	assert.Equal(t, [][]byte{[]byte("cert-2"), []byte("key-2")}, contents, "rotated content not loaded")
+
+	require.Nil(t, os.Remove(key), "unable to remove key")
+
+	_, err = cache.Get()
+	assert.NotNil(t, err, "missing file not detected")
+}
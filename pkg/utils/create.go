@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// UncachedObjects returns kinds the operator reads directly from the API server,
+// it has no list and watch permission on them, so their informers would never sync and reads would block
+func UncachedObjects() []client.Object {
+	return []client.Object{&corev1.Secret{}, &corev1.ConfigMap{}}
+}
+
+// CreateOrGet creates the object, if a concurrent request created it first fetches the existing one into existing
+func CreateOrGet(ctx context.Context, kubeClient client.Client, obj, existing client.Object) (bool, error) {
+	return getExistingOnConflict(ctx, kubeClient, kubeClient.Create(ctx, obj), obj, existing)
+}
+
+// CreateOrGetPVC creates the PVC by CreatePVC, if a concurrent request created it first fetches the existing one into existing
+func CreateOrGetPVC(ctx context.Context, kubeClient client.Client, pvc, existing *corev1.PersistentVolumeClaim, volumeAttributesClassName string) (bool, error) {
+	return getExistingOnConflict(ctx, kubeClient, CreatePVC(ctx, kubeClient, pvc, volumeAttributesClassName), pvc, existing)
+}
+
+// getExistingOnConflict fetches the existing object into existing if creation failed because it exists
+func getExistingOnConflict(ctx context.Context, kubeClient client.Client, err error, obj, existing client.Object) (bool, error) {
+	if err == nil {
+		return true, nil
+	} else if !apierrors.IsAlreadyExists(err) {
+		return false, fmt.Errorf("unable to create object: %w", err)
+	}
+
+	if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+		return false, fmt.Errorf("unable to fetch existing object: %w", err)
+	}
+
+	return false, nil
+}
+
+// CreatePVC creates the PVC with VolumeAttributesClass of the given name if the cluster serves the API,
+// PVC type of client doesn't know the field yet, so PVC is created as unstructured object
+func CreatePVC(ctx context.Context, kubeClient client.Client, pvc *corev1.PersistentVolumeClaim, volumeAttributesClassName string) error {
+	if volumeAttributesClassName == "" {
+		return kubeClient.Create(ctx, pvc)
+	}
+
+	supported, err := IsVolumeAttributesClassSupported(kubeClient.RESTMapper())
+	if err != nil {
+		return err
+	} else if !supported {
+		return kubeClient.Create(ctx, pvc)
+	}
+
+	raw, err := runtime.DefaultUnstructuredConverter.ToUnstructured(pvc)
+	if err != nil {
+		return fmt.Errorf("unable to convert PVC: %w", err)
+	}
+
+	obj := unstructured.Unstructured{Object: raw}
+	obj.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"))
+
+	if err := unstructured.SetNestedField(obj.Object, volumeAttributesClassName, "spec", "volumeAttributesClassName"); err != nil {
+		return fmt.Errorf("unable to set VolumeAttributesClass: %w", err)
+	}
+
+	if err := kubeClient.Create(ctx, &obj); err != nil {
+		return err
+	}
+
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, pvc); err != nil {
+		return fmt.Errorf("unable to convert created PVC: %w", err)
+	}
+
+	return nil
+}
+
+// ApplySecret creates the secret or updates the existing one if its data has changed
+func ApplySecret(ctx context.Context, kubeClient client.Client, secret *corev1.Secret) error {
+	existing := corev1.Secret{}
+
+	created, err := CreateOrGet(ctx, kubeClient, secret, &existing)
+	if err != nil {
+		return err
+	} else if created || reflect.DeepEqual(existing.Data, secret.Data) {
+		return nil
+	}
+
+	existing.Data = secret.Data
+
+	if err := kubeClient.Update(ctx, &existing); err != nil {
+		return fmt.Errorf("unable to update secret: %w", err)
+	}
+
+	return nil
+}
+
+// RenderInitialPVCs renders additional disks of the parent PVC up to initial number of disks, returns mount points by PVC names
+func RenderInitialPVCs(config *discoblocksondatiov1.DiskConfig, parent *corev1.PersistentVolumeClaim) ([]*corev1.PersistentVolumeClaim, map[string]string, error) {
+	children := []*corev1.PersistentVolumeClaim{}
+	mountPoints := map[string]string{}
+
+	for index := 1; index < int(config.Spec.Policy.InitialNumberOfDisks); index++ {
+		child, err := RenderChildPVC(parent, index)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to render PVC of index %d: %w", index, err)
+		}
+
+		children = append(children, child)
+		mountPoints[child.Name] = RenderDiskMountPoint(config, child.Name, index)
+	}
+
+	return children, mountPoints, nil
+}
+
+// CreateInitialPVCs creates additional disks of the parent PVC up to initial number of disks, returns mount points by PVC names
+func CreateInitialPVCs(ctx context.Context, kubeClient client.Client, config *discoblocksondatiov1.DiskConfig, parent *corev1.PersistentVolumeClaim) (map[string]string, error) {
+	children, mountPoints, err := RenderInitialPVCs(config, parent)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range children {
+		if _, err := CreateOrGetPVC(ctx, kubeClient, children[i], &corev1.PersistentVolumeClaim{}, config.Spec.VolumeAttributesClassName); err != nil {
+			return nil, fmt.Errorf("unable to create PVC of index %d: %w", i+1, err)
+		}
+	}
+
+	return mountPoints, nil
+}
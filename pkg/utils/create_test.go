@@ -0,0 +1,528 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCreateOrGetConcurrently(t *testing.T) {
+	t.Parallel()
+
+	const pods = 50
+
+	cases := map[string]struct {
+		shared       bool
+		expectedPVCs int
+	}{
+		"shared PVC": {
+			shared:       true,
+			expectedPVCs: 1,
+		},
+		"PVC per Pod": {
+			expectedPVCs: pods,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			kubeClient := fake.NewClientBuilder().Build()
+
+			created := int32(0)
+			errs := make(chan error, pods)
+			wg := sync.WaitGroup{}
+
+			for i := 0; i < pods; i++ {
+				name := "pvc"
+				if !c.shared {
+					name = fmt.Sprintf("pvc-%d", i)
+				}
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					pvc := corev1.PersistentVolumeClaim{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      name,
+							Namespace: "default",
+						},
+					}
+					existing := corev1.PersistentVolumeClaim{}
+
+					ok, err := CreateOrGet(context.Background(), kubeClient, &pvc, &existing)
+					if err != nil {
+						errs <- err
+						return
+					}
+
+					if ok {
+						atomic.AddInt32(&created, 1)
+					} else if existing.Name != name {
+						errs <- fmt.Errorf("invalid existing PVC: %s", existing.Name)
+					}
+				}()
+			}
+
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				assert.Nil(t, err, "unexpected error")
+			}
+
+			pvcs := corev1.PersistentVolumeClaimList{}
+			require.Nil(t, kubeClient.List(context.Background(), &pvcs), "unable to list PVCs")
+
+			assert.Len(t, pvcs.Items, c.expectedPVCs, "invalid number of PVCs")
+			assert.Equal(t, int32(c.expectedPVCs), created, "invalid number of creations")
+		})
+	}
+}
+
+func TestCreateInitialPVCs(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		initial      uint8
+		expectedPVCs int
+	}{
+		"default": {
+			expectedPVCs: 1,
+		},
+		"single disk": {
+			initial:      1,
+			expectedPVCs: 1,
+		},
+		"multiple disks": {
+			initial:      3,
+			expectedPVCs: 3,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			config := discoblocksondatiov1.DiskConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "config",
+					Namespace: "default",
+				},
+				Spec: discoblocksondatiov1.DiskConfigSpec{
+					MountPointPattern: "/media/discoblocks/data-%d",
+					Policy: discoblocksondatiov1.Policy{
+						InitialNumberOfDisks: c.initial,
+					},
+				},
+			}
+
+			parent := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "parent",
+					Namespace: "default",
+					UID:       "parent-uid",
+				},
+			}
+			PVCDecorator(&config, "", nil, &parent)
+
+			kubeClient := fake.NewClientBuilder().WithObjects(&parent).Build()
+
+			mountPoints, err := CreateInitialPVCs(context.Background(), kubeClient, &config, &parent)
+			require.Nil(t, err, "unexpected error")
+
+			pvcs := corev1.PersistentVolumeClaimList{}
+			require.Nil(t, kubeClient.List(context.Background(), &pvcs), "unable to list PVCs")
+			require.Len(t, pvcs.Items, c.expectedPVCs, "invalid number of PVCs")
+			require.Len(t, mountPoints, c.expectedPVCs-1, "invalid number of mount points")
+
+			indexes := map[int]bool{}
+			mounts := map[string]bool{RenderMountPoint(config.Spec.MountPointPattern, parent.Name, 0): true}
+			for i := range pvcs.Items {
+				pvc := pvcs.Items[i]
+				if pvc.Name == parent.Name {
+					continue
+				}
+
+				assert.Equal(t, parent.Name, pvc.Labels[ParentLabel()], "invalid parent label")
+				assert.Equal(t, config.Name, pvc.Labels[ConfigLabel()], "invalid config label")
+				assert.Equal(t, []string{RenderFinalizer(config.Name)}, pvc.Finalizers, "invalid finalizer")
+				assert.Equal(t, parent.UID, pvc.OwnerReferences[0].UID, "invalid owner")
+
+				indexes[GetPVCIndex(&pvc)] = true
+				mounts[mountPoints[pvc.Name]] = true
+			}
+
+			assert.Len(t, indexes, c.expectedPVCs-1, "duplicated index")
+			assert.NotContains(t, indexes, 0, "invalid index")
+			assert.Len(t, mounts, c.expectedPVCs, "duplicated mount point")
+		})
+	}
+}
+
+func TestSingleNodeProvisioning(t *testing.T) {
+	t.Parallel()
+
+	node := func(name string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+
+	cases := map[string]struct {
+		nodes         []client.Object
+		expectedError bool
+	}{
+		"no nodes": {
+			expectedError: true,
+		},
+		"single node": {
+			nodes: []client.Object{node("edge")},
+		},
+		"multiple nodes": {
+			nodes:         []client.Object{node("edge"), node("other")},
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			kubeClient := fake.NewClientBuilder().WithObjects(c.nodes...).Build()
+
+			nodeName, err := GetSingleNode(context.Background(), kubeClient)
+			if c.expectedError {
+				assert.NotNil(t, err, "invalid node selection")
+				return
+			}
+			require.Nil(t, err, "unexpected error")
+			require.Equal(t, "edge", nodeName, "invalid node")
+
+			config := discoblocksondatiov1.DiskConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "config",
+					Namespace: "default",
+				},
+				Spec: discoblocksondatiov1.DiskConfigSpec{
+					MountPointPattern: "/media/discoblocks/data-%d",
+					Policy: discoblocksondatiov1.Policy{
+						InitialNumberOfDisks: 2,
+					},
+				},
+			}
+
+			parent := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "parent",
+					Namespace: "default",
+				},
+			}
+			PVCDecorator(&config, "", nil, &parent)
+			SetSelectedNode(&parent, nodeName)
+
+			created, err := CreateOrGet(context.Background(), kubeClient, &parent, &corev1.PersistentVolumeClaim{})
+			require.Nil(t, err, "unable to create PVC")
+			require.True(t, created, "PVC not created")
+
+			_, err = CreateInitialPVCs(context.Background(), kubeClient, &config, &parent)
+			require.Nil(t, err, "unable to create initial PVCs")
+
+			pvcs := corev1.PersistentVolumeClaimList{}
+			require.Nil(t, kubeClient.List(context.Background(), &pvcs), "unable to list PVCs")
+			require.Len(t, pvcs.Items, 2, "invalid number of PVCs")
+
+			for i := range pvcs.Items {
+				assert.Equal(t, nodeName, pvcs.Items[i].Annotations[SelectedNodeAnnotation], "invalid selected node of %s", pvcs.Items[i].Name)
+			}
+		})
+	}
+}
+
+func TestLocalVolumeNodeAffinity(t *testing.T) {
+	t.Parallel()
+
+	nodeAffinity := func(nodeName string) *corev1.Affinity {
+		pod := corev1.Pod{}
+		SetNodeAffinity(&pod, nodeName)
+		return pod.Spec.Affinity
+	}
+
+	labelAffinity := &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{
+					{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "disk", Operator: corev1.NodeSelectorOpIn, Values: []string{"local"}}}},
+					{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "disk", Operator: corev1.NodeSelectorOpIn, Values: []string{"nvme"}}}},
+				},
+			},
+		},
+	}
+
+	cases := map[string]struct {
+		affinity      *corev1.Affinity
+		recordedNode  string
+		expectedNode  string
+		expectedError bool
+	}{
+		"new volume without node": {
+			expectedError: true,
+		},
+		"new volume": {
+			affinity:     nodeAffinity("edge"),
+			expectedNode: "edge",
+		},
+		"rescheduled": {
+			recordedNode: "edge",
+			expectedNode: "edge",
+		},
+		"rescheduled with label affinity": {
+			affinity:     labelAffinity,
+			recordedNode: "edge",
+			expectedNode: "edge",
+		},
+		"rescheduled to the same node": {
+			affinity:     nodeAffinity("edge"),
+			recordedNode: "edge",
+			expectedNode: "edge",
+		},
+		"rescheduled to another node": {
+			affinity:      nodeAffinity("other"),
+			recordedNode:  "edge",
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			pod := corev1.Pod{
+				Spec: corev1.PodSpec{
+					Affinity: c.affinity.DeepCopy(),
+				},
+			}
+
+			var existing *corev1.PersistentVolumeClaim
+			if c.recordedNode != "" {
+				existing = &corev1.PersistentVolumeClaim{}
+				SetSelectedNode(existing, c.recordedNode)
+			}
+
+			nodeName, err := ResolveLocalVolumeNode(existing, GetTargetNodeByAffinity(pod.Spec.Affinity))
+			if c.expectedError {
+				assert.NotNil(t, err, "invalid node resolution")
+				return
+			}
+			require.Nil(t, err, "unexpected error")
+			require.Equal(t, c.expectedNode, nodeName, "invalid node")
+
+			SetNodeAffinity(&pod, nodeName)
+			SetNodeAffinity(&pod, nodeName)
+
+			assert.Equal(t, c.expectedNode, GetTargetNodeByAffinity(pod.Spec.Affinity), "invalid node affinity")
+
+			terms := pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+			if c.affinity != nil {
+				require.Len(t, terms, len(c.affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms), "invalid number of terms")
+			}
+			for i := range terms {
+				require.Len(t, terms[i].MatchFields, 1, "invalid node requirement of term %d", i)
+				assert.Equal(t, []string{c.expectedNode}, terms[i].MatchFields[0].Values, "invalid node of term %d", i)
+			}
+
+			kubeClient := fake.NewClientBuilder().Build()
+
+			config := discoblocksondatiov1.DiskConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "config",
+					Namespace: "default",
+				},
+				Spec: discoblocksondatiov1.DiskConfigSpec{
+					Policy: discoblocksondatiov1.Policy{
+						InitialNumberOfDisks: 2,
+					},
+				},
+			}
+
+			parent := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "parent",
+					Namespace: "default",
+				},
+			}
+			PVCDecorator(&config, "", nil, &parent)
+			SetSelectedNode(&parent, nodeName)
+
+			_, err = CreateOrGet(context.Background(), kubeClient, &parent, &corev1.PersistentVolumeClaim{})
+			require.Nil(t, err, "unable to create PVC")
+
+			_, err = CreateInitialPVCs(context.Background(), kubeClient, &config, &parent)
+			require.Nil(t, err, "unable to create initial PVCs")
+
+			pvcs := corev1.PersistentVolumeClaimList{}
+			require.Nil(t, kubeClient.List(context.Background(), &pvcs), "unable to list PVCs")
+			require.Len(t, pvcs.Items, 2, "invalid number of PVCs")
+
+			for i := range pvcs.Items {
+				assert.Equal(t, GetTargetNodeByAffinity(pod.Spec.Affinity), pvcs.Items[i].Annotations[SelectedNodeAnnotation], "invalid selected node of %s", pvcs.Items[i].Name)
+			}
+		})
+	}
+}
+
+func TestApplySecret(t *testing.T) {
+	t.Parallel()
+
+	secret := func(data string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "discoblocks-metrics-cert",
+				Namespace: "default",
+			},
+			Data: map[string][]byte{
+				"tls.crt": []byte(data),
+			},
+		}
+	}
+
+	kubeClient := fake.NewClientBuilder().Build()
+
+	for _, data := range []string{"cert-1", "cert-1", "cert-2"} {
+		require.Nil(t, ApplySecret(context.Background(), kubeClient, secret(data)), "unable to apply secret")
+
+		actual := corev1.Secret{}
+		require.Nil(t, kubeClient.Get(context.Background(), client.ObjectKeyFromObject(secret(data)), &actual), "unable to fetch secret")
+		assert.Equal(t, data, string(actual.Data["tls.crt"]), "invalid secret data")
+	}
+}
+
+func TestCreateInitialPVCsAnnotations(t *testing.T) {
+	t.Parallel()
+
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+		},
+		Spec: discoblocksondatiov1.DiskConfigSpec{
+			PVCAnnotations: map[string]string{"backup.kanister.io/enabled": "true"},
+			Policy: discoblocksondatiov1.Policy{
+				InitialNumberOfDisks: 3,
+			},
+		},
+	}
+
+	parent := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "parent",
+			Namespace:   "default",
+			UID:         "parent-uid",
+			Annotations: map[string]string{"driver": "stub"},
+		},
+	}
+	PVCDecorator(&config, "", nil, &parent)
+
+	kubeClient := fake.NewClientBuilder().WithObjects(&parent).Build()
+
+	_, err := CreateInitialPVCs(context.Background(), kubeClient, &config, &parent)
+	require.Nil(t, err, "unexpected error")
+
+	pvcs := corev1.PersistentVolumeClaimList{}
+	require.Nil(t, kubeClient.List(context.Background(), &pvcs), "unable to list PVCs")
+	require.Len(t, pvcs.Items, 3, "invalid number of PVCs")
+
+	for i := range pvcs.Items {
+		assert.Equal(t, "true", pvcs.Items[i].Annotations["backup.kanister.io/enabled"], "backup annotation missing: %s", pvcs.Items[i].Name)
+		assert.Equal(t, "stub", pvcs.Items[i].Annotations["driver"], "annotation of driver removed: %s", pvcs.Items[i].Name)
+	}
+}
+
+// createRecorder records objects of creations
+type createRecorder struct {
+	client.Client
+	created []client.Object
+}
+
+func (c *createRecorder) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.created = append(c.created, obj.DeepCopyObject().(client.Object))
+
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func TestCreatePVC(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		className     string
+		supported     bool
+		expectedClass string
+	}{
+		"no class": {
+			supported: true,
+		},
+		"not supported": {
+			className: "fast",
+		},
+		"supported": {
+			className:     "fast",
+			supported:     true,
+			expectedClass: "fast",
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Group: VolumeAttributesClassGK.Group, Version: "v1beta1"}})
+			if c.supported {
+				mapper.Add(VolumeAttributesClassGK.WithVersion("v1beta1"), meta.RESTScopeRoot)
+			}
+
+			kubeClient := &createRecorder{
+				Client: fake.NewClientBuilder().WithRESTMapper(mapper).Build(),
+			}
+
+			pvc := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pvc",
+					Namespace: "default",
+				},
+			}
+
+			require.Nil(t, CreatePVC(context.Background(), kubeClient, &pvc, c.className), "unable to create PVC")
+			assert.NotEmpty(t, pvc.ResourceVersion, "created PVC not returned")
+
+			require.Len(t, kubeClient.created, 1, "invalid number of creations")
+
+			className := ""
+			if u, ok := kubeClient.created[0].(*unstructured.Unstructured); ok {
+				className, _, _ = unstructured.NestedString(u.Object, "spec", "volumeAttributesClassName")
+			}
+			assert.Equal(t, c.expectedClass, className, "invalid VolumeAttributesClass")
+
+			err := CreatePVC(context.Background(), kubeClient, pvc.DeepCopy(), c.className)
+			assert.True(t, apierrors.IsAlreadyExists(err), "invalid error of existing PVC")
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DeferredPVCsAnnotationName is the name of the Pod annotation listing PVCs of rate limited admissions
+const DeferredPVCsAnnotationName = "deferred-pvcs"
+
+// DeferredPVCsAnnotation returns the key of the Pod annotation listing PVCs of rate limited admissions
+func DeferredPVCsAnnotation() string {
+	prefix := labelPrefix
+	if prefix == "" {
+		prefix = defaultOwnerLabelPrefix
+	}
+
+	return prefix + DeferredPVCsAnnotationName
+}
+
+// DeferredPVC is an item of the deferred PVCs annotation
+type DeferredPVC struct {
+	ConfigName string                       `json:"configName"`
+	PVC        corev1.PersistentVolumeClaim `json:"pvc"`
+}
+
+// GetDeferredPVCs returns the deferred PVCs of the Pod
+func GetDeferredPVCs(pod *corev1.Pod) ([]DeferredPVC, error) {
+	value, ok := pod.Annotations[DeferredPVCsAnnotation()]
+	if !ok {
+		return nil, nil
+	}
+
+	deferred := []DeferredPVC{}
+	if err := json.Unmarshal([]byte(value), &deferred); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal deferred PVCs: %w", err)
+	}
+
+	return deferred, nil
+}
+
+// SetDeferredPVCs sets the deferred PVCs of the Pod, removes the annotation if none left
+func SetDeferredPVCs(pod *corev1.Pod, deferred []DeferredPVC) error {
+	if len(deferred) == 0 {
+		delete(pod.Annotations, DeferredPVCsAnnotation())
+		return nil
+	}
+
+	raw, err := json.Marshal(deferred)
+	if err != nil {
+		return fmt.Errorf("unable to marshal deferred PVCs: %w", err)
+	}
+
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[DeferredPVCsAnnotation()] = string(raw)
+
+	return nil
+}
+
+// AddDeferredPVC appends the PVC of the config to the deferred PVCs of the Pod
+func AddDeferredPVC(pod *corev1.Pod, configName string, pvc *corev1.PersistentVolumeClaim) error {
+	deferred, err := GetDeferredPVCs(pod)
+	if err != nil {
+		return err
+	}
+
+	return SetDeferredPVCs(pod, append(deferred, DeferredPVC{ConfigName: configName, PVC: *pvc}))
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDeferredPVCs(t *testing.T) {
+	pod := corev1.Pod{}
+
+	deferred, err := GetDeferredPVCs(&pod)
+	require.Nil(t, err, "unable to get deferred PVCs")
+	assert.Empty(t, deferred, "deferred PVCs of new Pod")
+
+	for _, name := range []string{"pvc-a", "pvc-b"} {
+		pvc := corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+		require.Nil(t, AddDeferredPVC(&pod, "config", &pvc), "unable to add deferred PVC")
+	}
+
+	deferred, err = GetDeferredPVCs(&pod)
+	require.Nil(t, err, "unable to get deferred PVCs")
+	require.Len(t, deferred, 2, "invalid number of deferred PVCs")
+	assert.Equal(t, "config", deferred[0].ConfigName, "invalid config of deferred PVC")
+	assert.Equal(t, "pvc-a", deferred[0].PVC.Name, "invalid deferred PVC")
+	assert.Equal(t, "pvc-b", deferred[1].PVC.Name, "invalid deferred PVC")
+
+	require.Nil(t, SetDeferredPVCs(&pod, nil), "unable to clear deferred PVCs")
+	assert.NotContains(t, pod.Annotations, DeferredPVCsAnnotation(), "annotation kept without deferred PVCs")
+
+	pod.Annotations[DeferredPVCsAnnotation()] = "invalid"
+	_, err = GetDeferredPVCs(&pod)
+	assert.NotNil(t, err, "invalid annotation parsed")
+}
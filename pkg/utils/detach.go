@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DetachedAtAnnotationName is the name of the PVC annotation tracking when the last Pod released the disk
+const DetachedAtAnnotationName = "detached-at"
+
+// DetachedAtAnnotation returns the key of the PVC annotation tracking when the last Pod released the disk
+func DetachedAtAnnotation() string {
+	prefix := labelPrefix
+	if prefix == "" {
+		prefix = defaultOwnerLabelPrefix
+	}
+
+	return prefix + DetachedAtAnnotationName
+}
+
+// PlanDetachedPVCs decides garbage collection of parent PVCs, returns names of PVCs to mark as detached,
+// names of PVCs used again and names of PVCs detached for longer than grace period.
+// PVCs with invalid detach timestamp are marked again.
+func PlanDetachedPVCs(pvcs []corev1.PersistentVolumeClaim, used map[string]bool, grace time.Duration, now time.Time) (detach, attach, reclaim []string) {
+	for i := range pvcs {
+		pvc := &pvcs[i]
+
+		if pvc.DeletionTimestamp != nil {
+			continue
+		}
+
+		value, ok := pvc.Annotations[DetachedAtAnnotation()]
+
+		if used[pvc.Name] {
+			if ok {
+				attach = append(attach, pvc.Name)
+			}
+			continue
+		}
+
+		detachedAt, err := time.Parse(time.RFC3339, value)
+		switch {
+		case !ok || err != nil:
+			detach = append(detach, pvc.Name)
+		case now.Sub(detachedAt) >= grace:
+			reclaim = append(reclaim, pvc.Name)
+		}
+	}
+
+	return detach, attach, reclaim
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPlanDetachedPVCs(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	newPVC := func(name, detachedAt string) corev1.PersistentVolumeClaim {
+		pvc := corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+		}
+
+		if detachedAt != "" {
+			pvc.Annotations = map[string]string{DetachedAtAnnotation(): detachedAt}
+		}
+
+		return pvc
+	}
+
+	deleted := newPVC("deleted", now.Add(-time.Hour).Format(time.RFC3339))
+	deleted.DeletionTimestamp = &metav1.Time{Time: now}
+
+	cases := map[string]struct {
+		pvcs            []corev1.PersistentVolumeClaim
+		used            map[string]bool
+		expectedDetach  []string
+		expectedAttach  []string
+		expectedReclaim []string
+	}{
+		"used": {
+			pvcs: []corev1.PersistentVolumeClaim{newPVC("pvc", "")},
+			used: map[string]bool{"pvc": true},
+		},
+		"newly detached": {
+			pvcs:           []corev1.PersistentVolumeClaim{newPVC("pvc", "")},
+			expectedDetach: []string{"pvc"},
+		},
+		"invalid timestamp": {
+			pvcs:           []corev1.PersistentVolumeClaim{newPVC("pvc", "yesterday")},
+			expectedDetach: []string{"pvc"},
+		},
+		"restarted within grace period": {
+			pvcs:           []corev1.PersistentVolumeClaim{newPVC("pvc", now.Add(-time.Minute).Format(time.RFC3339))},
+			used:           map[string]bool{"pvc": true},
+			expectedAttach: []string{"pvc"},
+		},
+		"within grace period": {
+			pvcs: []corev1.PersistentVolumeClaim{newPVC("pvc", now.Add(-time.Minute).Format(time.RFC3339))},
+		},
+		"grace period expired": {
+			pvcs:            []corev1.PersistentVolumeClaim{newPVC("pvc", now.Add(-10*time.Minute).Format(time.RFC3339))},
+			expectedReclaim: []string{"pvc"},
+		},
+		"terminating": {
+			pvcs: []corev1.PersistentVolumeClaim{deleted},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			detach, attach, reclaim := PlanDetachedPVCs(c.pvcs, c.used, 5*time.Minute, now)
+
+			assert.Equal(t, c.expectedDetach, detach, "invalid detached PVCs")
+			assert.Equal(t, c.expectedAttach, attach, "invalid attached PVCs")
+			assert.Equal(t, c.expectedReclaim, reclaim, "invalid reclaimed PVCs")
+		})
+	}
+}
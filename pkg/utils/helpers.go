@@ -3,12 +3,13 @@ package utils
 import (
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 const maxName = 253
@@ -32,9 +33,157 @@ func RenderMountPoint(pattern, name string, index int) string {
 	return fmt.Sprintf(pattern, index)
 }
 
+// RenderDiskMountPoint calculates mount point of the disk of the index, mount point of the disk wins over the pattern
+func RenderDiskMountPoint(config *discoblocksondatiov1.DiskConfig, name string, index int) string {
+	if disk := config.Spec.GetDisk(index); disk != nil && disk.MountPoint != "" {
+		return disk.MountPoint
+	}
+
+	return RenderMountPoint(config.Spec.MountPointPattern, name, index)
+}
+
+// GetDiskFileSystem returns file-system of the disk of the index, falls back to the given one
+func GetDiskFileSystem(config *discoblocksondatiov1.DiskConfig, index int, fallback string) string {
+	if disk := config.Spec.GetDisk(index); disk != nil && disk.FileSystem != "" {
+		return disk.FileSystem
+	}
+
+	return fallback
+}
+
+// ValidateMountPointPattern checks the pattern renders distinct absolute paths for disks
+func ValidateMountPointPattern(pattern string) error {
+	if pattern == "" {
+		return nil
+	}
+
+	first, second := RenderMountPoint(pattern, "", 0), RenderMountPoint(pattern, "", 1)
+	for _, mountPoint := range []string{first, second} {
+		if strings.Contains(mountPoint, "%!") {
+			return fmt.Errorf("invalid mount pattern, unable to render: %s", mountPoint)
+		} else if !strings.HasPrefix(mountPoint, "/") {
+			return fmt.Errorf("invalid mount pattern, path is not absolute: %s", mountPoint)
+		}
+	}
+
+	if first == second {
+		return fmt.Errorf("invalid mount pattern, disks share mount point: %s", first)
+	}
+
+	return nil
+}
+
+const defaultFinalizerPrefix = "discoblocks.io/"
+
+// labelPrefix is the domain prefix of labels and finalizers, empty means legacy keys
+var labelPrefix = ""
+
+// SetLabelPrefix configures domain prefix of labels and finalizers, for example discoblocks.ondat.io/
+func SetLabelPrefix(prefix string) error {
+	if prefix != "" {
+		if !strings.HasSuffix(prefix, "/") {
+			return fmt.Errorf("label prefix must end with /: %s", prefix)
+		}
+
+		if errs := validation.IsQualifiedName(prefix + ConfigLabelName); len(errs) != 0 {
+			return fmt.Errorf("invalid label prefix %s: %s", prefix, strings.Join(errs, ", "))
+		}
+	}
+
+	labelPrefix = prefix
+
+	return nil
+}
+
+// managedProvisioners is the allowlist of provisioners Discoblocks acts on, empty means all
+var managedProvisioners = map[string]bool{}
+
+// SetManagedProvisioners configures the allowlist of provisioners, for example ebs.csi.aws.com.
+// Each managed provisioner must be supported, empty list means all supported ones. Returns the effective list.
+func SetManagedProvisioners(managed, supported []string) ([]string, error) {
+	supportedSet := map[string]bool{}
+	for _, p := range supported {
+		if p != "" {
+			supportedSet[p] = true
+		}
+	}
+
+	effective := []string{}
+	for _, p := range managed {
+		if p == "" {
+			continue
+		}
+
+		if !supportedSet[p] {
+			return nil, fmt.Errorf("managed provisioner is not supported: %s", p)
+		}
+
+		effective = append(effective, p)
+	}
+
+	if len(effective) == 0 {
+		for _, p := range supported {
+			if p != "" {
+				effective = append(effective, p)
+			}
+		}
+	}
+
+	managedProvisioners = map[string]bool{}
+	for _, p := range effective {
+		managedProvisioners[p] = true
+	}
+
+	return effective, nil
+}
+
+// IsProvisionerManaged checks provisioner against the allowlist
+func IsProvisionerManaged(provisioner string) bool {
+	return len(managedProvisioners) == 0 || managedProvisioners[provisioner]
+}
+
+// Label names of PVCs
+const (
+	ConfigLabelName = "discoblocks"
+	ParentLabelName = "discoblocks-parent"
+	IndexLabelName  = "discoblocks-index"
+	AdoptLabelName  = "discoblocks-adopt"
+	PoolLabelName   = "discoblocks-pool"
+)
+
+// ConfigLabel returns label key of DiskConfig name
+func ConfigLabel() string {
+	return labelPrefix + ConfigLabelName
+}
+
+// ParentLabel returns label key of parent PVC name
+func ParentLabel() string {
+	return labelPrefix + ParentLabelName
+}
+
+// IndexLabel returns label key of PVC index
+func IndexLabel() string {
+	return labelPrefix + IndexLabelName
+}
+
+// AdoptLabel returns label key of DiskConfig name to adopt an existing PVC
+func AdoptLabel() string {
+	return labelPrefix + AdoptLabelName
+}
+
+// PoolLabel returns label key of DiskConfig name of pre-provisioned PVCs
+func PoolLabel() string {
+	return labelPrefix + PoolLabelName
+}
+
 // RenderFinalizer calculates finalizer name
 func RenderFinalizer(name string, extras ...string) string {
-	finalizer := fmt.Sprintf("discoblocks.io/%s", name)
+	prefix := labelPrefix
+	if prefix == "" {
+		prefix = defaultFinalizerPrefix
+	}
+
+	finalizer := prefix + name
 
 	for _, e := range extras {
 		finalizer = finalizer + "-" + e
@@ -74,21 +223,84 @@ func RenderResourceName(prefix bool, elems ...string) (string, error) {
 	return builder.String()[:l], nil
 }
 
-// RenderUniqueLabel renders DiskConfig label
+// legacyKeyPrefix is the prefix of label and annotation keys of Discoblocks objects without label prefix
+const legacyKeyPrefix = "discoblocks/"
+
+// prefixedKey renders label or annotation key of Discoblocks objects, falls back to legacy prefix
+func prefixedKey(name string) string {
+	prefix := labelPrefix
+	if prefix == "" {
+		prefix = legacyKeyPrefix
+	}
+
+	return prefix + name
+}
+
+// Label and annotation names of Discoblocks objects
+const (
+	ClusterConfigLabelName     = "cluster-config"
+	DiskConfigNameLabelName    = "dc-name"
+	AuditLabelName             = "audit"
+	JobOperationAnnotationName = "operation"
+	JobPodAnnotationName       = "pod"
+	JobPVCAnnotationName       = "pvc"
+)
+
+// ClusterConfigLabel returns label key of ClusterDiskConfig name of rendered DiskConfigs
+func ClusterConfigLabel() string {
+	return prefixedKey(ClusterConfigLabelName)
+}
+
+// DiskConfigNameLabel returns label key of DiskConfig name of ServiceMonitors and audit records
+func DiskConfigNameLabel() string {
+	return prefixedKey(DiskConfigNameLabelName)
+}
+
+// AuditLabel returns label key of operation of audit records
+func AuditLabel() string {
+	return prefixedKey(AuditLabelName)
+}
+
+// JobOperationAnnotation returns annotation key of operation of host Jobs
+func JobOperationAnnotation() string {
+	return prefixedKey(JobOperationAnnotationName)
+}
+
+// JobPodAnnotation returns annotation key of Pod name of host Jobs
+func JobPodAnnotation() string {
+	return prefixedKey(JobPodAnnotationName)
+}
+
+// JobPVCAnnotation returns annotation key of PVC name of host Jobs
+func JobPVCAnnotation() string {
+	return prefixedKey(JobPVCAnnotationName)
+}
+
+// RenderUniqueLabel renders DiskConfig label, every config has its own key so a Pod could be managed by many configs
 func RenderUniqueLabel(id string) string {
 	hash, err := Hash(id)
 	if err != nil {
 		panic("Unable to calculate hash, better to say good bye!")
 	}
 
-	return fmt.Sprintf("discoblocks/%d", hash)
+	return prefixedKey(fmt.Sprintf("%d", hash))
 }
 
-// IsContainsAll finds for a contains all b
+// RenderPodSelector renders selector of Pods managed by the DiskConfig
+func RenderPodSelector(config *discoblocksondatiov1.DiskConfig) (labels.Selector, error) {
+	podLabel, err := labels.NewRequirement(RenderUniqueLabel(string(config.UID)), selection.Equals, []string{config.Name})
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse Pod label selector: %w", err)
+	}
+
+	return labels.NewSelector().Add(*podLabel), nil
+}
+
+// IsContainsAll finds for a contains all b, nil a contains only empty b
 func IsContainsAll(a, b map[string]string) bool {
 	match := 0
 	for key, value := range b {
-		if a[key] == value {
+		if actual, ok := a[key]; ok && actual == value {
 			match++
 		}
 	}
@@ -96,6 +308,11 @@ func IsContainsAll(a, b map[string]string) bool {
 	return match == len(b)
 }
 
+// IsPodSelected finds for Pod labels contains all of the DiskConfig Pod selector, empty selector matches nothing
+func IsPodSelected(podLabels, podSelector map[string]string) bool {
+	return len(podSelector) != 0 && IsContainsAll(podLabels, podSelector)
+}
+
 // GetNamePrefix returns the prefix by availability type
 func GetNamePrefix(am discoblocksondatiov1.AvailabilityMode, configUID, nodeName string) string {
 	switch am {
@@ -109,13 +326,3 @@ func GetNamePrefix(am discoblocksondatiov1.AvailabilityMode, configUID, nodeName
 		panic("Missing availability mode implementation: " + string(am))
 	}
 }
-
-// ReadFileOrDie reads the file or die
-func ReadFileOrDie(path string) []byte {
-	content, err := os.ReadFile(filepath.Clean(path))
-	if err != nil {
-		panic(err)
-	}
-
-	return content
-}
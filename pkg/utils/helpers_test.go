@@ -1,9 +1,15 @@
 package utils
 
 import (
+	"strings"
 	"testing"
 
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 func TestRenderMountPoint(t *testing.T) {
@@ -52,3 +58,357 @@ func TestRenderMountPoint(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderDiskMountPoint(t *testing.T) {
+	t.Parallel()
+
+	config := discoblocksondatiov1.DiskConfig{
+		Spec: discoblocksondatiov1.DiskConfigSpec{
+			MountPointPattern: "/data-%d",
+			Disks: []discoblocksondatiov1.DiskSpec{
+				{Index: 0, FileSystem: "xfs"},
+				{Index: 1, FileSystem: "ext4", MountPoint: "/logs"},
+			},
+		},
+	}
+
+	cases := map[string]struct {
+		index              int
+		expectedMountPoint string
+		expectedFileSystem string
+	}{
+		"pattern": {
+			index:              0,
+			expectedMountPoint: "/data-0",
+			expectedFileSystem: "xfs",
+		},
+		"disk mount point": {
+			index:              1,
+			expectedMountPoint: "/logs",
+			expectedFileSystem: "ext4",
+		},
+		"not listed": {
+			index:              2,
+			expectedMountPoint: "/data-2",
+			expectedFileSystem: "btrfs",
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, c.expectedMountPoint, RenderDiskMountPoint(&config, "foo", c.index), "invalid mount point")
+			assert.Equal(t, c.expectedFileSystem, GetDiskFileSystem(&config, c.index, "btrfs"), "invalid file-system")
+		})
+	}
+}
+
+func TestValidateMountPointPattern(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		pattern       string
+		expectedError bool
+	}{
+		"default": {
+			pattern: "",
+		},
+		"given-with-order": {
+			pattern: "/bar-%d",
+		},
+		"given-without-order": {
+			pattern: "/bar",
+		},
+		"invalid verb": {
+			pattern:       "/bar-%s",
+			expectedError: true,
+		},
+		"missing argument": {
+			pattern:       "/bar-%d-%d",
+			expectedError: true,
+		},
+		"escaped order": {
+			pattern:       "/bar-%%d",
+			expectedError: true,
+		},
+		"relative": {
+			pattern:       "bar-%d",
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidateMountPointPattern(c.pattern)
+
+			assert.Equal(t, c.expectedError, err != nil, "invalid error")
+		})
+	}
+}
+
+func TestSetLabelPrefix(t *testing.T) {
+	cases := map[string]struct {
+		prefix            string
+		expectedError     bool
+		expectedConfig    string
+		expectedParent    string
+		expectedIndex     string
+		expectedFinalizer string
+		expectedKeyPrefix string
+	}{
+		"default": {
+			prefix:            "",
+			expectedConfig:    "discoblocks",
+			expectedParent:    "discoblocks-parent",
+			expectedIndex:     "discoblocks-index",
+			expectedFinalizer: "discoblocks.io/foo-bar",
+			expectedKeyPrefix: "discoblocks/",
+		},
+		"custom": {
+			prefix:            "discoblocks.ondat.io/",
+			expectedConfig:    "discoblocks.ondat.io/discoblocks",
+			expectedParent:    "discoblocks.ondat.io/discoblocks-parent",
+			expectedIndex:     "discoblocks.ondat.io/discoblocks-index",
+			expectedFinalizer: "discoblocks.ondat.io/foo-bar",
+			expectedKeyPrefix: "discoblocks.ondat.io/",
+		},
+		"missing-slash": {
+			prefix:        "discoblocks.ondat.io",
+			expectedError: true,
+		},
+		"invalid-domain": {
+			prefix:        "-foo_/",
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Cleanup(func() {
+				labelPrefix = ""
+			})
+
+			err := SetLabelPrefix(c.prefix)
+			if c.expectedError {
+				assert.NotNil(t, err, "error missing")
+				return
+			}
+
+			require.Nil(t, err, "unexpected error")
+
+			assert.Equal(t, c.expectedConfig, ConfigLabel(), "invalid config label")
+			assert.Equal(t, c.expectedParent, ParentLabel(), "invalid parent label")
+			assert.Equal(t, c.expectedIndex, IndexLabel(), "invalid index label")
+			assert.Equal(t, c.expectedFinalizer, RenderFinalizer("foo", "bar"), "invalid finalizer")
+			assert.Equal(t, c.expectedKeyPrefix+"cluster-config", ClusterConfigLabel(), "invalid cluster config label")
+			assert.Equal(t, c.expectedKeyPrefix+"dc-name", DiskConfigNameLabel(), "invalid config name label")
+			assert.Equal(t, c.expectedKeyPrefix+"audit", AuditLabel(), "invalid audit label")
+			assert.True(t, strings.HasPrefix(RenderUniqueLabel("foo"), c.expectedKeyPrefix), "invalid unique label")
+
+			job, err := RenderResizeJob("pod", "pvc", "pv", "default", "node", "ext4", "", "", "", nil, nil, nil, nil, metav1.OwnerReference{})
+			require.Nil(t, err, "unable to render resize job")
+
+			assert.Equal(t, "resize", job.Annotations[c.expectedKeyPrefix+"operation"], "invalid job operation annotation")
+			assert.Equal(t, "pod", job.Annotations[c.expectedKeyPrefix+"pod"], "invalid job Pod annotation")
+			assert.Equal(t, "pvc", job.Annotations[c.expectedKeyPrefix+"pvc"], "invalid job PVC annotation")
+
+			pvc := corev1.PersistentVolumeClaim{}
+			PVCDecorator(&discoblocksondatiov1.DiskConfig{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}, "", nil, &pvc)
+
+			assert.Equal(t, "foo", pvc.Labels[c.expectedConfig], "invalid PVC label")
+			assert.Equal(t, []string{RenderFinalizer("foo")}, pvc.Finalizers, "invalid PVC finalizer")
+		})
+	}
+}
+
+func TestSetManagedProvisioners(t *testing.T) {
+	supported := []string{"ebs.csi.aws.com", "csi.storageos.com"}
+
+	cases := map[string]struct {
+		managed           []string
+		expectedError     bool
+		expectedEffective []string
+		allowed           []string
+		disallowed        []string
+	}{
+		"all supported": {
+			managed:           []string{},
+			expectedEffective: []string{"ebs.csi.aws.com", "csi.storageos.com"},
+			allowed:           []string{"ebs.csi.aws.com", "csi.storageos.com"},
+			disallowed:        []string{"pd.csi.storage.gke.io"},
+		},
+		"subset": {
+			managed:           []string{"ebs.csi.aws.com"},
+			expectedEffective: []string{"ebs.csi.aws.com"},
+			allowed:           []string{"ebs.csi.aws.com"},
+			disallowed:        []string{"csi.storageos.com", "pd.csi.storage.gke.io"},
+		},
+		"not supported": {
+			managed:       []string{"ebs.csi.aws.com", "pd.csi.storage.gke.io"},
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Cleanup(func() {
+				managedProvisioners = map[string]bool{}
+			})
+
+			effective, err := SetManagedProvisioners(c.managed, supported)
+			if c.expectedError {
+				assert.NotNil(t, err, "error missing")
+				assert.True(t, IsProvisionerManaged("csi.storageos.com"), "allowlist changed on error")
+				return
+			}
+
+			require.Nil(t, err, "unexpected error")
+
+			assert.Equal(t, c.expectedEffective, effective, "invalid effective provisioners")
+			for _, p := range c.allowed {
+				assert.True(t, IsProvisionerManaged(p), "provisioner not allowed: "+p)
+			}
+			for _, p := range c.disallowed {
+				assert.False(t, IsProvisionerManaged(p), "provisioner allowed: "+p)
+			}
+		})
+	}
+}
+
+func TestRenderPodSelector(t *testing.T) {
+	t.Parallel()
+
+	first := discoblocksondatiov1.DiskConfig{ObjectMeta: metav1.ObjectMeta{Name: "first", UID: "first-uid"}}
+	second := discoblocksondatiov1.DiskConfig{ObjectMeta: metav1.ObjectMeta{Name: "second", UID: "second-uid"}}
+
+	cases := map[string]struct {
+		configs        []*discoblocksondatiov1.DiskConfig
+		expectedFirst  bool
+		expectedSecond bool
+	}{
+		"first only": {
+			configs:       []*discoblocksondatiov1.DiskConfig{&first},
+			expectedFirst: true,
+		},
+		"both configs": {
+			configs:        []*discoblocksondatiov1.DiskConfig{&first, &second},
+			expectedFirst:  true,
+			expectedSecond: true,
+		},
+		"both configs reversed": {
+			configs:        []*discoblocksondatiov1.DiskConfig{&second, &first},
+			expectedFirst:  true,
+			expectedSecond: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			podLabels := labels.Set{}
+			for _, config := range c.configs {
+				podLabels[RenderUniqueLabel(string(config.UID))] = config.Name
+			}
+
+			assert.Len(t, podLabels, len(c.configs), "label keys collide")
+
+			firstSelector, err := RenderPodSelector(&first)
+			require.Nil(t, err, "invalid first selector")
+
+			secondSelector, err := RenderPodSelector(&second)
+			require.Nil(t, err, "invalid second selector")
+
+			assert.Equal(t, c.expectedFirst, firstSelector.Matches(podLabels), "invalid first selection")
+			assert.Equal(t, c.expectedSecond, secondSelector.Matches(podLabels), "invalid second selection")
+		})
+	}
+}
+
+func TestIsContainsAll(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		labels   map[string]string
+		selector map[string]string
+		expected bool
+	}{
+		"nil labels": {
+			selector: map[string]string{"app": "nginx"},
+		},
+		"nil labels empty value": {
+			selector: map[string]string{"app": ""},
+		},
+		"missing key empty value": {
+			labels:   map[string]string{"foo": "bar"},
+			selector: map[string]string{"app": ""},
+		},
+		"empty value": {
+			labels:   map[string]string{"app": ""},
+			selector: map[string]string{"app": ""},
+			expected: true,
+		},
+		"different value": {
+			labels:   map[string]string{"app": "redis"},
+			selector: map[string]string{"app": "nginx"},
+		},
+		"match": {
+			labels:   map[string]string{"app": "nginx", "tier": "web"},
+			selector: map[string]string{"app": "nginx"},
+			expected: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, c.expected, IsContainsAll(c.labels, c.selector), "invalid selection")
+		})
+	}
+}
+
+func TestIsPodSelected(t *testing.T) {
+	t.Parallel()
+
+	podLabels := map[string]string{"app": "nginx", "tier": "web"}
+
+	cases := map[string]struct {
+		selector map[string]string
+		expected bool
+	}{
+		"nil selector": {},
+		"empty selector": {
+			selector: map[string]string{},
+		},
+		"partial match": {
+			selector: map[string]string{"app": "nginx", "tier": "db"},
+		},
+		"subset match": {
+			selector: map[string]string{"app": "nginx"},
+			expected: true,
+		},
+		"full match": {
+			selector: map[string]string{"app": "nginx", "tier": "web"},
+			expected: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, c.expected, IsPodSelected(podLabels, c.selector), "invalid selection")
+		})
+	}
+}
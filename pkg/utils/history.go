@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// MaxSampleHistoryDisks bounds the number of disks in the persisted sample history, ConfigMaps are limited to 1MiB
+const MaxSampleHistoryDisks = 1000
+
+const sampleHistoryKey = "history.json"
+
+// SampleHistoryStore persists availability samples of disks across restarts and leader changes
+type SampleHistoryStore interface {
+	Load(ctx context.Context) (map[string][]FillRateSample, error)
+	Save(ctx context.Context, history map[string][]FillRateSample) error
+}
+
+// configMapSampleHistoryStore keeps the history as JSON in a single ConfigMap,
+// reads go through Reader because the operator doesn't watch ConfigMaps
+type configMapSampleHistoryStore struct {
+	Name      string
+	Namespace string
+	Client    client.Client
+	Reader    client.Reader
+}
+
+// Load reads the history, missing ConfigMap means empty history
+func (hs *configMapSampleHistoryStore) Load(ctx context.Context) (map[string][]FillRateSample, error) {
+	cm := corev1.ConfigMap{}
+	if err := hs.Reader.Get(ctx, client.ObjectKey{Namespace: hs.Namespace, Name: hs.Name}, &cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return map[string][]FillRateSample{}, nil
+		}
+
+		return nil, fmt.Errorf("unable to fetch sample history: %w", err)
+	}
+
+	history := map[string][]FillRateSample{}
+	if raw, ok := cm.Data[sampleHistoryKey]; ok {
+		if err := json.Unmarshal([]byte(raw), &history); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal sample history: %w", err)
+		}
+	}
+
+	return history, nil
+}
+
+// Save writes the history, ConfigMap is created on first save and updated only on change
+func (hs *configMapSampleHistoryStore) Save(ctx context.Context, history map[string][]FillRateSample) error {
+	content, err := json.Marshal(history)
+	if err != nil {
+		return fmt.Errorf("unable to marshal sample history: %w", err)
+	}
+
+	cm := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      hs.Name,
+			Namespace: hs.Namespace,
+			Labels: map[string]string{
+				"app": "discoblocks",
+			},
+		},
+		Data: map[string]string{
+			sampleHistoryKey: string(content),
+		},
+	}
+
+	existing := corev1.ConfigMap{}
+	if err := hs.Reader.Get(ctx, client.ObjectKeyFromObject(&cm), &existing); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("unable to fetch sample history: %w", err)
+		}
+
+		if err := hs.Client.Create(ctx, &cm); err != nil {
+			return fmt.Errorf("unable to create sample history: %w", err)
+		}
+
+		return nil
+	} else if existing.Data[sampleHistoryKey] == string(content) {
+		return nil
+	}
+
+	existing.Data = cm.Data
+
+	if err := hs.Client.Update(ctx, &existing); err != nil {
+		return fmt.Errorf("unable to update sample history: %w", err)
+	}
+
+	return nil
+}
+
+// NewSampleHistoryStore creates a new ConfigMap store of sample history, empty name disables persistence.
+// apiReader should read the API server directly, see manager.GetAPIReader
+func NewSampleHistoryStore(name, namespace string, k8sClient client.Client, apiReader client.Reader) (SampleHistoryStore, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	if namespace == "" {
+		return nil, errors.New("namespace is required for sample history")
+	}
+
+	return &configMapSampleHistoryStore{
+		Name:      name,
+		Namespace: namespace,
+		Client:    k8sClient,
+		Reader:    apiReader,
+	}, nil
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// unsyncedCacheClient fails every read like a cached client without list/watch access
+type unsyncedCacheClient struct {
+	client.Client
+}
+
+func (unsyncedCacheClient) Get(context.Context, client.ObjectKey, client.Object) error {
+	return errors.New("timed out waiting for cache to be synced")
+}
+
+func (unsyncedCacheClient) List(context.Context, client.ObjectList, ...client.ListOption) error {
+	return errors.New("timed out waiting for cache to be synced")
+}
+
+func TestSampleHistoryStore(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		existing []client.Object
+		expected map[string][]FillRateSample
+	}{
+		"empty": {
+			expected: map[string][]FillRateSample{},
+		},
+		"saved": {
+			expected: map[string][]FillRateSample{
+				"default/pvc": {
+					{Time: start, Size: 1000, Available: 500},
+					{Time: start.Add(time.Minute), Size: 1000, Available: 400},
+				},
+			},
+		},
+		"overwritten": {
+			existing: []client.Object{
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "history",
+						Namespace: "discoblocks",
+					},
+					Data: map[string]string{
+						sampleHistoryKey: `{"default/old":[{"time":"2022-06-01T11:00:00Z","size":1000,"available":900}]}`,
+					},
+				},
+			},
+			expected: map[string][]FillRateSample{
+				"default/pvc": {
+					{Time: start, Size: 1000, Available: 500},
+				},
+			},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			kubeClient := fake.NewClientBuilder().WithObjects(c.existing...).Build()
+
+			store, err := NewSampleHistoryStore("history", "discoblocks", unsyncedCacheClient{Client: kubeClient}, kubeClient)
+			require.Nil(t, err, "unable to create store")
+
+			require.Nil(t, store.Save(context.Background(), c.expected), "unable to save history")
+
+			history, err := store.Load(context.Background())
+			require.Nil(t, err, "unable to load history")
+
+			assert.Equal(t, c.expected, history, "invalid history")
+		})
+	}
+}
+
+func TestNewSampleHistoryStore(t *testing.T) {
+	t.Parallel()
+
+	store, err := NewSampleHistoryStore("", "", nil, nil)
+	assert.Nil(t, err, "unexpected error of disabled store")
+	assert.Nil(t, store, "disabled store created")
+
+	_, err = NewSampleHistoryStore("history", "", nil, nil)
+	assert.NotNil(t, err, "store without namespace created")
+}
+
+func TestFillRatePredictorSnapshotRestore(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	predictor := NewFillRatePredictor(3)
+	for i := 0; i < 5; i++ {
+		predictor.Observe("default/new", start.Add(time.Duration(i)*time.Minute), 1000, float64(500-i*50))
+	}
+	predictor.Observe("default/old", start.Add(-time.Hour), 1000, 900)
+
+	history := predictor.Snapshot(1)
+
+	assert.Equal(t, map[string][]FillRateSample{
+		"default/new": {
+			{Time: start.Add(2 * time.Minute), Size: 1000, Available: 400},
+			{Time: start.Add(3 * time.Minute), Size: 1000, Available: 350},
+			{Time: start.Add(4 * time.Minute), Size: 1000, Available: 300},
+		},
+	}, history, "invalid snapshot")
+
+	restored := NewFillRatePredictor(3)
+	restored.Restore(history)
+
+	expected, expectedOK := predictor.TimeToFull("default/new")
+	timeToFull, ok := restored.TimeToFull("default/new")
+
+	assert.Equal(t, expectedOK, ok, "invalid restored prediction")
+	assert.Equal(t, expected, timeToFull, "invalid restored time to full")
+}
@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultHostJobQueuePeriod is the period of counting outstanding host Jobs while the queue is full
+const DefaultHostJobQueuePeriod = 10 * time.Second
+
+// hostJobListGrace is the maximum time a created host Job is counted until it shows up in the list of Jobs
+const hostJobListGrace = time.Minute
+
+// hostJobStaleGrace is the time a host Job is counted after its active deadline, Job controller needs time to fail it
+const hostJobStaleGrace = time.Minute
+
+// DefaultHostJobCreationConcurrency is the default number of host Jobs created in parallel
+const DefaultHostJobCreationConcurrency = 4
+
+// HostJobQueue paces creation of host Jobs, nil queue doesn't limit.
+// Jobs of the same node are serialized, a Job is created once the previous one of the node has finished,
+// so host operations of mount and resize Jobs never run concurrently on a node.
+type HostJobQueue struct {
+	max     int
+	period  time.Duration
+	workers chan struct{}
+	lock    sync.Mutex
+	// creating counts Jobs under creation per node, they might be missing from the list of Jobs
+	creating map[string]int
+	// created tracks Jobs until they show up in the list of Jobs, cache of the client might lag behind
+	created  map[types.NamespacedName]createdHostJob
+	finished chan struct{}
+	// stopped is closed on stop of the manager, queued Jobs give up waiting
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+type createdHostJob struct {
+	nodeName string
+	time     time.Time
+}
+
+// NewHostJobQueue creates a new queue of max outstanding Jobs cluster-wide and concurrency parallel creations.
+// Zero max doesn't limit the number of outstanding Jobs.
+func NewHostJobQueue(max, concurrency int32, period time.Duration) (*HostJobQueue, error) {
+	if max < 0 {
+		return nil, fmt.Errorf("invalid maximum of outstanding host Jobs: %d", max)
+	} else if concurrency < 1 {
+		return nil, fmt.Errorf("invalid concurrency: %d", concurrency)
+	} else if period <= 0 {
+		return nil, fmt.Errorf("invalid period: %s", period)
+	}
+
+	return &HostJobQueue{
+		max:      int(max),
+		period:   period,
+		workers:  make(chan struct{}, concurrency),
+		creating: map[string]int{},
+		created:  map[types.NamespacedName]createdHostJob{},
+		finished: make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}, nil
+}
+
+// Create creates the Job once its node has no outstanding host Job and the number of outstanding host Jobs is below the maximum
+func (q *HostJobQueue) Create(ctx context.Context, kubeClient client.Client, job *batchv1.Job) error {
+	if q == nil {
+		return kubeClient.Create(ctx, job)
+	}
+
+	nodeName := job.Spec.Template.Spec.NodeName
+
+	ticker := time.NewTicker(q.period)
+	defer ticker.Stop()
+
+	for {
+		ok, finished, err := q.reserve(ctx, kubeClient, nodeName)
+		if err != nil {
+			return fmt.Errorf("unable to count outstanding host Jobs: %w", err)
+		} else if ok {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("host Job is still queued: %w", ctx.Err())
+		case <-q.stopped:
+			return errors.New("host Job queue is stopped")
+		case <-finished:
+		case <-ticker.C:
+		}
+	}
+
+	created := false
+	defer func() {
+		q.release(job, created)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("host Job is still queued: %w", ctx.Err())
+	case q.workers <- struct{}{}:
+	}
+	defer func() {
+		<-q.workers
+	}()
+
+	if err := kubeClient.Create(ctx, job); err != nil {
+		return err
+	}
+	created = true
+
+	return nil
+}
+
+// Start blocks until the context is done, then releases all queued Jobs
+func (q *HostJobQueue) Start(ctx context.Context) error {
+	<-ctx.Done()
+
+	q.stopOnce.Do(func() {
+		close(q.stopped)
+	})
+
+	return nil
+}
+
+// NeedLeaderElection returns false, volume monitor creates host Jobs on every replica
+func (q *HostJobQueue) NeedLeaderElection() bool {
+	return false
+}
+
+// Notify wakes up the queue to count outstanding host Jobs again
+func (q *HostJobQueue) Notify() {
+	if q == nil {
+		return
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	close(q.finished)
+	q.finished = make(chan struct{})
+}
+
+// reserve takes a slot for the node if available, otherwise returns the channel closed on next notification.
+// Jobs are listed without holding the lock, so waiting for the API never blocks other callers beyond their context.
+func (q *HostJobQueue) reserve(ctx context.Context, kubeClient client.Client, nodeName string) (bool, <-chan struct{}, error) {
+	perNode, listed, err := countOutstandingHostJobs(ctx, kubeClient)
+	if err != nil {
+		return false, nil, err
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for key, job := range q.created {
+		if listed[key] || time.Since(job.time) > hostJobListGrace {
+			delete(q.created, key)
+			continue
+		}
+
+		perNode[job.nodeName]++
+	}
+
+	outstanding := 0
+	for node, creating := range q.creating {
+		perNode[node] += creating
+	}
+	for _, count := range perNode {
+		outstanding += count
+	}
+
+	if perNode[nodeName] > 0 || (q.max > 0 && outstanding >= q.max) {
+		return false, q.finished, nil
+	}
+
+	q.creating[nodeName]++
+
+	return true, nil, nil
+}
+
+func (q *HostJobQueue) release(job *batchv1.Job, created bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	nodeName := job.Spec.Template.Spec.NodeName
+
+	if created {
+		q.created[types.NamespacedName{Name: job.Name, Namespace: job.Namespace}] = createdHostJob{
+			nodeName: nodeName,
+			time:     time.Now(),
+		}
+	}
+
+	q.creating[nodeName]--
+	if q.creating[nodeName] == 0 {
+		delete(q.creating, nodeName)
+	}
+}
+
+// countOutstandingHostJobs counts host Jobs which are neither complete nor failed nor stale per node, returns all listed Jobs too
+func countOutstandingHostJobs(ctx context.Context, kubeClient client.Client) (map[string]int, map[types.NamespacedName]bool, error) {
+	jobs := batchv1.JobList{}
+	if err := kubeClient.List(ctx, &jobs, client.MatchingLabels{"app": "discoblocks"}); err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	outstanding := map[string]int{}
+	listed := map[types.NamespacedName]bool{}
+	for i := range jobs.Items {
+		listed[types.NamespacedName{Name: jobs.Items[i].Name, Namespace: jobs.Items[i].Namespace}] = true
+
+		if !isJobFinished(&jobs.Items[i]) && !isJobStale(&jobs.Items[i], now) {
+			outstanding[jobs.Items[i].Spec.Template.Spec.NodeName]++
+		}
+	}
+
+	return outstanding, listed, nil
+}
+
+func isJobFinished(job *batchv1.Job) bool {
+	for _, c := range job.Status.Conditions {
+		if (c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed) && c.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isJobStale checks whether the Job is running longer than its active deadline, Job controller should have failed it,
+// so it must not hold the slot of its node forever
+func isJobStale(job *batchv1.Job, now time.Time) bool {
+	if job.Spec.ActiveDeadlineSeconds == nil || job.CreationTimestamp.IsZero() {
+		return false
+	}
+
+	deadline := time.Duration(*job.Spec.ActiveDeadlineSeconds)*time.Second + hostJobStaleGrace
+
+	return now.Sub(job.CreationTimestamp.Time) > deadline
+}
@@ -0,0 +1,326 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNewHostJobQueue(t *testing.T) {
+	cases := map[string]struct {
+		max           int32
+		concurrency   int32
+		period        time.Duration
+		expectedError bool
+	}{
+		"unlimited": {
+			max:         0,
+			concurrency: 1,
+			period:      time.Second,
+		},
+		"negative max": {
+			max:           -1,
+			concurrency:   1,
+			period:        time.Second,
+			expectedError: true,
+		},
+		"zero concurrency": {
+			max:           1,
+			period:        time.Second,
+			expectedError: true,
+		},
+		"zero period": {
+			max:           1,
+			concurrency:   1,
+			expectedError: true,
+		},
+		"valid": {
+			max:         1,
+			concurrency: 1,
+			period:      time.Second,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			queue, err := NewHostJobQueue(c.max, c.concurrency, c.period)
+
+			assert.Equal(t, c.expectedError, err != nil, "invalid error")
+			assert.Equal(t, c.expectedError, queue == nil, "invalid queue")
+		})
+	}
+}
+
+func TestHostJobQueueNil(t *testing.T) {
+	t.Parallel()
+
+	var queue *HostJobQueue
+
+	kubeClient := fake.NewClientBuilder().Build()
+
+	require.Nil(t, queue.Create(context.Background(), kubeClient, newHostJob("job", "node-a")), "nil queue failed")
+	queue.Notify()
+
+	assert.Nil(t, kubeClient.Get(context.Background(), client.ObjectKey{Name: "job", Namespace: "default"}, &batchv1.Job{}), "job not created")
+}
+
+func TestHostJobQueueDefersCreation(t *testing.T) {
+	t.Parallel()
+
+	running := newHostJob("running", "node-a")
+	failed := newHostJob("failed", "node-b")
+	failed.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}
+	other := newHostJob("other", "node-b")
+	other.Labels = map[string]string{"app": "other"}
+
+	kubeClient := fake.NewClientBuilder().WithObjects(running, failed, other).Build()
+
+	queue, err := NewHostJobQueue(1, 1, time.Hour)
+	require.Nil(t, err, "unable to create queue")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.NotNil(t, queue.Create(ctx, kubeClient, newHostJob("timeout", "node-b")), "job created over maximum")
+	assert.NotNil(t, kubeClient.Get(context.Background(), client.ObjectKey{Name: "timeout", Namespace: "default"}, &batchv1.Job{}), "deferred job created")
+
+	created := make(chan error)
+	go func() {
+		created <- queue.Create(context.Background(), kubeClient, newHostJob("queued", "node-b"))
+	}()
+
+	select {
+	case <-created:
+		t.Fatal("job created over maximum")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	running.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+	require.Nil(t, kubeClient.Status().Update(context.Background(), running), "unable to complete job")
+
+	queue.Notify()
+
+	select {
+	case err := <-created:
+		require.Nil(t, err, "unable to create queued job")
+	case <-time.After(time.Second):
+		t.Fatal("queued job not created")
+	}
+
+	assert.Nil(t, kubeClient.Get(context.Background(), client.ObjectKey{Name: "queued", Namespace: "default"}, &batchv1.Job{}), "queued job not created")
+}
+
+func TestHostJobQueueStop(t *testing.T) {
+	t.Parallel()
+
+	kubeClient := fake.NewClientBuilder().WithObjects(newHostJob("running", "node-a")).Build()
+
+	queue, err := NewHostJobQueue(0, 1, time.Hour)
+	require.Nil(t, err, "unable to create queue")
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stopped := make(chan error)
+	go func() {
+		stopped <- queue.Start(ctx)
+	}()
+
+	created := make(chan error)
+	go func() {
+		created <- queue.Create(context.Background(), kubeClient, newHostJob("queued", "node-a"))
+	}()
+
+	select {
+	case <-created:
+		t.Fatal("job created while node has outstanding job")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+
+	select {
+	case err := <-created:
+		assert.NotNil(t, err, "queued job created after stop")
+	case <-time.After(time.Second):
+		t.Fatal("queued job still waiting after stop")
+	}
+
+	assert.Nil(t, <-stopped, "unable to stop queue")
+	assert.NotNil(t, kubeClient.Get(context.Background(), client.ObjectKey{Name: "queued", Namespace: "default"}, &batchv1.Job{}), "queued job created")
+}
+
+func TestHostJobQueueSerializesNode(t *testing.T) {
+	t.Parallel()
+
+	kubeClient := fake.NewClientBuilder().Build()
+
+	queue, err := NewHostJobQueue(0, 1, time.Hour)
+	require.Nil(t, err, "unable to create queue")
+
+	first := newHostJob("first", "node-a")
+	require.Nil(t, queue.Create(context.Background(), kubeClient, first), "unable to create first job")
+
+	created := make(chan error)
+	go func() {
+		created <- queue.Create(context.Background(), kubeClient, newHostJob("second", "node-a"))
+	}()
+
+	require.Nil(t, queue.Create(context.Background(), kubeClient, newHostJob("other", "node-b")), "job of other node deferred")
+
+	select {
+	case <-created:
+		t.Fatal("job created while node has outstanding job")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	first.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+	require.Nil(t, kubeClient.Status().Update(context.Background(), first), "unable to complete job")
+
+	queue.Notify()
+
+	select {
+	case err := <-created:
+		require.Nil(t, err, "unable to create queued job")
+	case <-time.After(time.Second):
+		t.Fatal("queued job not created")
+	}
+}
+
+func TestHostJobQueueSkipsStaleJobs(t *testing.T) {
+	t.Parallel()
+
+	activeDeadlineSeconds := int64(60)
+
+	stale := newHostJob("stale", "node-a")
+	stale.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	stale.Spec.ActiveDeadlineSeconds = &activeDeadlineSeconds
+
+	running := newHostJob("running", "node-b")
+	running.CreationTimestamp = metav1.NewTime(time.Now())
+	running.Spec.ActiveDeadlineSeconds = &activeDeadlineSeconds
+
+	kubeClient := fake.NewClientBuilder().WithObjects(stale, running).Build()
+
+	queue, err := NewHostJobQueue(2, 1, time.Hour)
+	require.Nil(t, err, "unable to create queue")
+
+	require.Nil(t, queue.Create(context.Background(), kubeClient, newHostJob("next", "node-a")), "stale job holds slot of node")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.NotNil(t, queue.Create(ctx, kubeClient, newHostJob("deferred", "node-b")), "running job doesn't hold slot of node")
+}
+
+func TestHostJobQueueSerializesNodeWithLaggingCache(t *testing.T) {
+	t.Parallel()
+
+	kubeClient := &laggingClient{
+		Client: fake.NewClientBuilder().Build(),
+	}
+
+	queue, err := NewHostJobQueue(0, 2, time.Hour)
+	require.Nil(t, err, "unable to create queue")
+
+	require.Nil(t, queue.Create(context.Background(), kubeClient, newHostJob("resize", "node-a")), "unable to create resize job")
+	require.Nil(t, queue.Create(context.Background(), kubeClient, newHostJob("other", "node-b")), "job of other node deferred")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	assert.NotNil(t, queue.Create(ctx, kubeClient, newHostJob("mount", "node-a")), "job created while node has outstanding job")
+	assert.NotNil(t, kubeClient.Get(context.Background(), client.ObjectKey{Name: "mount", Namespace: "default"}, &batchv1.Job{}), "deferred job created")
+}
+
+// laggingClient lists no Jobs as a cache which hasn't received new ones yet
+type laggingClient struct {
+	client.Client
+}
+
+func (c *laggingClient) List(_ context.Context, _ client.ObjectList, _ ...client.ListOption) error {
+	return nil
+}
+
+func TestHostJobQueueConcurrency(t *testing.T) {
+	t.Parallel()
+
+	const concurrency = 2
+
+	kubeClient := &concurrencyClient{
+		Client: fake.NewClientBuilder().Build(),
+	}
+
+	queue, err := NewHostJobQueue(0, concurrency, time.Hour)
+	require.Nil(t, err, "unable to create queue")
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			assert.Nil(t, queue.Create(context.Background(), kubeClient, newHostJob(fmt.Sprintf("job-%d", i), fmt.Sprintf("node-%d", i))), "unable to create job")
+		}(i)
+	}
+	wg.Wait()
+
+	jobs := batchv1.JobList{}
+	require.Nil(t, kubeClient.List(context.Background(), &jobs), "unable to list jobs")
+
+	assert.Len(t, jobs.Items, 10, "invalid number of jobs")
+	assert.LessOrEqual(t, atomic.LoadInt32(&kubeClient.max), int32(concurrency), "concurrency exceeded")
+}
+
+// concurrencyClient records the maximum number of parallel creations
+type concurrencyClient struct {
+	client.Client
+	current int32
+	max     int32
+}
+
+func (c *concurrencyClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	current := atomic.AddInt32(&c.current, 1)
+	defer atomic.AddInt32(&c.current, -1)
+
+	for {
+		max := atomic.LoadInt32(&c.max)
+		if current <= max || atomic.CompareAndSwapInt32(&c.max, max, current) {
+			break
+		}
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func newHostJob(name, nodeName string) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{"app": "discoblocks"},
+		},
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					NodeName: nodeName,
+				},
+			},
+		},
+	}
+}
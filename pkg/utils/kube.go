@@ -1,8 +1,14 @@
 package utils
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"path"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,7 +18,15 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
 )
 
@@ -32,7 +46,7 @@ command:
   cp -r /lib /opt/discoblocks &&
   patchelf --set-interpreter /opt/discoblocks/lib/ld-musl-x86_64.so.1 /opt/discoblocks/busybox &&
   trap exit SIGTERM ;
-  while true; do tcpserver -v -c 1 -D -P -R -H -t 3 -l 0 127.0.0.1 59100 df -P & c=$! wait $c; done
+  while true; do tcpserver -v -c 1 -D -P -R -H -t 3 -l 0 127.0.0.1 %d sh -c 'df -P && echo "# mounts" && cat /proc/mounts' & c=$! wait $c; done
 securityContext:
   privileged: false
 `
@@ -62,7 +76,7 @@ command:
   [%s-%s]
   type = tcp
   local_ip = 127.0.0.1
-  local_port = 59100
+  local_port = %d
   remote_port = 0
   EOF
   trap exit SIGTERM ;
@@ -83,9 +97,9 @@ metadata:
   labels:
     app: discoblocks
   annotations:
-    discoblocks/operation: "%s"
-    discoblocks/pod: "%s"
-    discoblocks/pvc: "%s"
+    %s: "%s"
+    %s: "%s"
+    %s: "%s"
 spec:
   template:
     spec:
@@ -113,24 +127,12 @@ spec:
         - |
           %s
         volumeMounts:
-        - mountPath: /run/containerd/containerd.sock
-          name: containerd-socket
-          readOnly: true
-        - mountPath: /var/run/docker.sock
-          name: docker-socket
-          readOnly: true
         - mountPath: /host
           name: host
         securityContext:
           privileged: true
       restartPolicy: Never
       volumes:
-       - hostPath:
-          path: /run/containerd/containerd.sock
-         name: containerd-socket
-       - hostPath:
-          path: /var/run/docker.sock
-         name: docker-socket
        - hostPath:
           path: /
          name: host
@@ -144,20 +146,132 @@ DEV_MAJOR=$(chroot /host nsenter --target 1 --mount lsblk -lp | grep ${DEV} | aw
 DEV_MINOR=$(chroot /host nsenter --target 1 --mount lsblk -lp | grep ${DEV} | awk '{print $2}'  | awk '{split($0,a,":"); print a[2]}') &&
 export LD_LIBRARY_PATH=/opt/discoblocks/lib &&
 for CONTAINER_ID in ${CONTAINER_IDS}; do
-	PID=$(docker inspect -f '{{.State.Pid}}' ${CONTAINER_ID} || nerdctl -n k8s.io inspect -f '{{.State.Pid}}' ${CONTAINER_ID} || crictl --runtime-endpoint unix:///run/containerd/containerd.sock inspect --output go-template --template '{{.info.pid}}' ${CONTAINER_ID}) &&
+	PID=$(container_pid ${CONTAINER_ID}) &&
 	chroot /host nsenter --target ${PID} --mount /opt/discoblocks/busybox mount | grep "${DEV} on ${MOUNT_POINT}" || (
 		chroot /host nsenter --target ${PID} --mount /opt/discoblocks/busybox mkdir -p $(dirname ${DEV}) ${MOUNT_POINT} &&
 		(chroot /host nsenter --target ${PID} --pid --mount /opt/discoblocks/busybox mknod ${DEV} b ${DEV_MAJOR} ${DEV_MINOR} ||:) &&
 		chroot /host nsenter --target ${PID} --mount /opt/discoblocks/busybox mount ${DEV} ${MOUNT_POINT}
-	)
+	) &&
+	timeout %d chroot /host nsenter --target ${PID} --mount /opt/discoblocks/busybox %s
 done`
 )
 
+// runtimeDetectTemplate selects PID resolution of containers by the runtime of the Pod if known,
+// otherwise by the runtime socket found under the host root, containerd is preferred over docker sharing the node
+const runtimeDetectTemplate = `CONTAINER_RUNTIME=%[2]s
+RUNTIME_SOCKET=
+for RUNTIME in ${CONTAINER_RUNTIME:-containerd cri-o docker}; do
+	case ${RUNTIME} in
+	containerd) SOCKET=%[1]s/run/containerd/containerd.sock ;;
+	cri-o) SOCKET=%[1]s/run/crio/crio.sock ;;
+	docker) SOCKET=%[1]s/run/docker.sock ;;
+	esac
+	if [ -S ${SOCKET} ]; then
+		CONTAINER_RUNTIME=${RUNTIME}
+		RUNTIME_SOCKET=${SOCKET}
+		break
+	fi
+done
+if [ -z "${RUNTIME_SOCKET}" ]; then
+	echo "no known container runtime socket found in %[1]s/run" >&2
+	exit 1
+elif [ "${CONTAINER_RUNTIME}" = docker ]; then
+	container_pid() { docker -H unix://${RUNTIME_SOCKET} inspect -f '{{.State.Pid}}' "$1" ; }
+else
+	container_pid() { crictl --runtime-endpoint unix://${RUNTIME_SOCKET} inspect --output go-template --template '{{.info.pid}}' "$1" ; }
+fi
+echo "container runtime: ${CONTAINER_RUNTIME}"`
+
+// hostRoot is the mount point of host file-system in host jobs
+const hostRoot = "/host"
+
+// containerRuntimes maps container ID schemes of Pod status to runtimes known by host jobs
+var containerRuntimes = map[string]string{
+	"containerd": "containerd",
+	"docker":     "docker",
+	"cri-o":      "cri-o",
+}
+
+// TrimContainerIDPrefix removes the runtime prefix of container ID
+func TrimContainerIDPrefix(containerID string) string {
+	if scheme, id, found := strings.Cut(containerID, "://"); found && containerRuntimes[scheme] != "" {
+		return id
+	}
+
+	return containerID
+}
+
+// GetContainerRuntime returns the runtime of container ID by its scheme, empty if unknown
+func GetContainerRuntime(containerID string) string {
+	scheme, _, found := strings.Cut(containerID, "://")
+	if !found {
+		return ""
+	}
+
+	return containerRuntimes[scheme]
+}
+
+// DefaultStagingPath is the kubelet global mount path of CSI PersistentVolumes, drivers may report their own template
+const DefaultStagingPath = "/var/lib/kubelet/plugins/kubernetes.io/csi/pv/${PV_NAME}/globalmount"
+
+// DefaultMountVerifyCommand is the default busybox command verifying the new mount in the container
+const DefaultMountVerifyCommand = "ls ${MOUNT_POINT}"
+
+// mountVerifyTimeout is the time limit of mount verification in seconds
+const mountVerifyTimeout = 30
+
+// readOnlyVerifyApplets are the busybox applets allowed to verify mount
+var readOnlyVerifyApplets = map[string]bool{
+	"ls":         true,
+	"stat":       true,
+	"df":         true,
+	"mountpoint": true,
+	"test":       true,
+}
+
+// mountVerifyCommand is the busybox command verifying the new mount in the container
+var mountVerifyCommand = DefaultMountVerifyCommand
+
+// SetMountVerifyCommand configures the read-only busybox command verifying the new mount in the container, for example stat ${MOUNT_POINT}
+func SetMountVerifyCommand(command string) error {
+	if command == "" {
+		mountVerifyCommand = DefaultMountVerifyCommand
+		return nil
+	}
+
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return errors.New("mount verify command is blank")
+	} else if !readOnlyVerifyApplets[fields[0]] {
+		return fmt.Errorf("mount verify command is not allowed: %s", fields[0])
+	}
+
+	if strings.ContainsAny(strings.ReplaceAll(command, "${MOUNT_POINT}", ""), ";&|<>()$`\\\n\"'") {
+		return fmt.Errorf("mount verify command contains shell special characters: %s", command)
+	}
+
+	mountVerifyCommand = command
+
+	return nil
+}
+
+// formatCommand creates file-system only if device has no signature, blkid returns 2 only in that case
+const formatCommand = `if [ -n "${FS}" ]; then
+	BLKID_RC=0 ;
+	chroot /host nsenter --target 1 --mount blkid -p ${DEV} || BLKID_RC=$? ;
+	if [ "${BLKID_RC}" = "2" ]; then
+		chroot /host nsenter --target 1 --mount mkfs.${FS} ${DEV} ;
+	fi
+fi && `
+
 const resizeCommandTemplate = `%s
 chroot /host nsenter --target 1 --mount mkdir -p /tmp/discoblocks${DEV} &&
 chroot /host nsenter --target 1 --mount mount ${DEV} /tmp/discoblocks${DEV} &&
 trap "chroot /host nsenter --target 1 --mount umount /tmp/discoblocks${DEV}" EXIT &&
-(
+%s`
+
+// growCommand grows the mounted file-system, resize2fs, xfs_growfs and btrfs keep UUID and label of the file-system
+const growCommand = `(
 	([ "${FS}" = "ext3" ] && chroot /host nsenter --target 1 --mount resize2fs ${DEV}) ||
 	([ "${FS}" = "ext4" ] && chroot /host nsenter --target 1 --mount resize2fs ${DEV}) ||
 	([ "${FS}" = "xfs" ] && chroot /host nsenter --target 1 --mount xfs_growfs -d ${DEV}) ||
@@ -165,33 +279,318 @@ trap "chroot /host nsenter --target 1 --mount umount /tmp/discoblocks${DEV}" EXI
 	echo unsupported file-system $FS
 )`
 
-// RenderMetricsSidecar returns the metrics sidecar
-func RenderMetricsSidecar() (*corev1.Container, error) {
+// fsIdentityTemplate compares UUID and label of the file-system before and after the resize command,
+// a custom tool might change them and break references of the application. Identity is captured after
+// the pre-resize command of the driver, which resolves the device, so its own changes are not covered.
+const fsIdentityTemplate = `fs_uuid() { chroot /host nsenter --target 1 --mount blkid -p -o value -s UUID ${DEV} ||: ; } &&
+fs_label() { chroot /host nsenter --target 1 --mount blkid -p -o value -s LABEL ${DEV} ||: ; } &&
+FS_UUID=$(fs_uuid) &&
+FS_LABEL=$(fs_label) &&
+%s &&
+if [ "$(fs_uuid)" != "${FS_UUID}" ] || [ "$(fs_label)" != "${FS_LABEL}" ]; then
+	echo "file-system identity changed, UUID: ${FS_UUID} -> $(fs_uuid), label: ${FS_LABEL} -> $(fs_label)" >&2 &&
+	%s
+fi`
+
+// fsIdentityRestoreCommand sets UUID and label back by the tool of the file-system, it fails if the identity still differs.
+// UUID of mounted xfs and btrfs can't be changed.
+const fsIdentityRestoreCommand = `(
+		([ "${FS}" = "ext3" ] && chroot /host nsenter --target 1 --mount tune2fs -U "${FS_UUID}" -L "${FS_LABEL}" ${DEV}) ||
+		([ "${FS}" = "ext4" ] && chroot /host nsenter --target 1 --mount tune2fs -U "${FS_UUID}" -L "${FS_LABEL}" ${DEV}) ||
+		([ "${FS}" = "xfs" ] && chroot /host nsenter --target 1 --mount xfs_admin -U "${FS_UUID}" -L "${FS_LABEL:---}" ${DEV}) ||
+		([ "${FS}" = "btrfs" ] && chroot /host nsenter --target 1 --mount btrfs filesystem label /tmp/discoblocks${DEV} "${FS_LABEL}") ||:
+	) &&
+	[ "$(fs_uuid)" = "${FS_UUID}" ] && [ "$(fs_label)" = "${FS_LABEL}" ]`
+
+// File-system identity modes of resize
+const (
+	// FSIdentityModeOff doesn't check UUID and label of the file-system
+	FSIdentityModeOff = "off"
+	// FSIdentityModeVerify fails the resize if UUID or label of the file-system has changed
+	FSIdentityModeVerify = "verify"
+	// FSIdentityModeRestore restores changed UUID and label of the file-system, fails the resize if it isn't possible
+	FSIdentityModeRestore = "restore"
+)
+
+// fsIdentityMode is the handling of UUID and label of the file-system on resize
+var fsIdentityMode = FSIdentityModeRestore
+
+// SetFSIdentityMode configures preservation of UUID and label of the file-system on resize, empty mode means restore
+func SetFSIdentityMode(mode string) error {
+	switch mode {
+	case "":
+		fsIdentityMode = FSIdentityModeRestore
+	case FSIdentityModeOff, FSIdentityModeVerify, FSIdentityModeRestore:
+		fsIdentityMode = mode
+	default:
+		return fmt.Errorf("unsupported file-system identity mode: %s", mode)
+	}
+
+	return nil
+}
+
+// renderFSIdentityCommand wraps the resize command by the file-system identity check of the mode
+func renderFSIdentityCommand(mode, command string) string {
+	switch mode {
+	case FSIdentityModeVerify:
+		return fmt.Sprintf(fsIdentityTemplate, command, "false")
+	case FSIdentityModeRestore:
+		return fmt.Sprintf(fsIdentityTemplate, command, fsIdentityRestoreCommand)
+	default:
+		return command
+	}
+}
+
+// resizeHooksTemplate runs post hook even if resize has failed, but not if pre hook has failed
+const resizeHooksTemplate = `%s || exit $?
+RESIZE_RC=0
+(
+%s
+) || RESIZE_RC=$?
+%s || exit $?
+exit ${RESIZE_RC}`
+
+const resizeHookCommandTemplate = `%[1]s_PID=$(container_pid ${%[1]s_CONTAINER_ID}) &&
+timeout %[2]d chroot /host nsenter --target ${%[1]s_PID} --mount --uts --ipc --net --pid sh -c "${%[1]s}"`
+
+// Environment variable names of resize hooks
+const (
+	preResizeHookName  = "PRE_RESIZE_HOOK"
+	postResizeHookName = "POST_RESIZE_HOOK"
+)
+
+// defaultResizeHookTimeout is the time limit of resize hooks in seconds
+const defaultResizeHookTimeout = 60
+
+// ResizeHook is a command executed in a running container around file-system resize
+type ResizeHook struct {
+	ContainerID string
+	Runtime     string
+	Command     string
+	Timeout     uint32
+}
+
+// RenderResizeHook finds the container of the hook in the Pod, nil hook means no hook, hooks of denied namespaces are errors
+func RenderResizeHook(pod *corev1.Pod, hook *discoblocksondatiov1.ResizeHook) (*ResizeHook, error) {
+	if hook == nil {
+		return nil, nil
+	}
+
+	// Hooks run as root in namespaces of the container, webhook lets in hooks of configs created before the namespace was denied
+	if !discoblocksondatiov1.IsResizeHookAllowed(pod.Namespace) {
+		return nil, fmt.Errorf("resize hooks are not allowed in namespace %s", pod.Namespace)
+	}
+
+	containerName := hook.Container
+	if containerName == "" {
+		if len(pod.Spec.Containers) == 0 {
+			return nil, errors.New("pod has no containers")
+		}
+
+		containerName = pod.Spec.Containers[0].Name
+	}
+
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name != containerName {
+			continue
+		}
+
+		rawContainerID := pod.Status.ContainerStatuses[i].ContainerID
+		containerID := TrimContainerIDPrefix(rawContainerID)
+
+		if containerID == "" || pod.Status.ContainerStatuses[i].State.Running == nil {
+			return nil, fmt.Errorf("container is not running: %s", containerName)
+		}
+
+		timeout := hook.TimeoutSeconds
+		if timeout == 0 {
+			timeout = defaultResizeHookTimeout
+		}
+
+		return &ResizeHook{
+			ContainerID: containerID,
+			Runtime:     GetContainerRuntime(rawContainerID),
+			Command:     hook.Command,
+			Timeout:     timeout,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("container not found: %s", containerName)
+}
+
+// renderResizeHookCommand returns the command of the hook, no-op if hook is nil
+func renderResizeHookCommand(name string, hook *ResizeHook) string {
+	if hook == nil {
+		return ":"
+	}
+
+	return fmt.Sprintf(resizeHookCommandTemplate, name, hook.Timeout)
+}
+
+// RenderMetricsSidecar returns the metrics sidecar listening on the given local port,
+// privileged sidecar mounts /var/lib/kubelet of the host read-only to report global mounts of volumes
+func RenderMetricsSidecar(port int32, privileged bool) (*corev1.Container, error) {
 	sidecar := corev1.Container{}
-	if err := yaml.Unmarshal([]byte(metricsTeamplate), &sidecar); err != nil {
+	if err := yaml.Unmarshal([]byte(fmt.Sprintf(metricsTeamplate, port)), &sidecar); err != nil {
 		return nil, fmt.Errorf("unable to unmarshal container: %w", err)
 	}
 
+	if privileged {
+		sidecar.SecurityContext.Privileged = &privileged
+
+		propagation := corev1.MountPropagationHostToContainer
+		sidecar.VolumeMounts = append(sidecar.VolumeMounts, corev1.VolumeMount{
+			Name:             "discoblocks-kubelet",
+			MountPath:        kubeletRootDir,
+			ReadOnly:         true,
+			MountPropagation: &propagation,
+		})
+	}
+
 	return &sidecar, nil
 }
 
-// RenderMetricsProxySidecar returns the metrics sidecar
-func RenderMetricsProxySidecar(name, namespace string) (*corev1.Container, error) {
+// kubeletRootDir is the root directory of kubelet on the host
+const kubeletRootDir = "/var/lib/kubelet"
+
+// RenderMetricsProxySidecar returns the metrics sidecar forwarding the given local port
+func RenderMetricsProxySidecar(name, namespace string, port int32) (*corev1.Container, error) {
 	sidecar := corev1.Container{}
-	if err := yaml.Unmarshal([]byte(fmt.Sprintf(metricsProxyTeamplate, namespace, name)), &sidecar); err != nil {
+	if err := yaml.Unmarshal([]byte(fmt.Sprintf(metricsProxyTeamplate, namespace, name, port)), &sidecar); err != nil {
 		return nil, fmt.Errorf("unable to unmarshal container: %w", err)
 	}
 
 	return &sidecar, nil
 }
 
-// RenderMountJob returns the mount job executed on host
-func RenderMountJob(podName, pvcName, pvName, namespace, nodeName, fs, mountPoint string, containerIDs []string, preMountCommand, volumeMeta string, owner metav1.OwnerReference) (*batchv1.Job, error) {
+// DefaultHostJobActiveDeadline is the default maximum run time of host Jobs
+const DefaultHostJobActiveDeadline = 15 * time.Minute
+
+// HostJobOptions tunes retries of host Jobs
+type HostJobOptions struct {
+	RestartPolicy  corev1.RestartPolicy
+	BackoffLimit   int32
+	Completions    int32
+	Parallelism    int32
+	ActiveDeadline time.Duration
+}
+
+// DefaultHostJobOptions runs host Jobs once without retry
+var DefaultHostJobOptions = HostJobOptions{
+	RestartPolicy:  corev1.RestartPolicyNever,
+	BackoffLimit:   0,
+	Completions:    1,
+	Parallelism:    1,
+	ActiveDeadline: DefaultHostJobActiveDeadline,
+}
+
+// hostJobOptions are applied on every host Job
+var hostJobOptions = DefaultHostJobOptions
+
+// SetHostJobOptions configures restart policy, backoff limit, completions, parallelism and active deadline of host Jobs.
+// Host Jobs operate a single device, so parallelism must be 1.
+func SetHostJobOptions(options HostJobOptions) error {
+	switch options.RestartPolicy {
+	case corev1.RestartPolicyNever, corev1.RestartPolicyOnFailure:
+	default:
+		return fmt.Errorf("restart policy of host job is not supported: %s", options.RestartPolicy)
+	}
+
+	if options.BackoffLimit < 0 {
+		return fmt.Errorf("backoff limit of host job must not be negative: %d", options.BackoffLimit)
+	}
+
+	if options.Completions < 1 {
+		return fmt.Errorf("completions of host job must be positive: %d", options.Completions)
+	}
+
+	if options.Parallelism != 1 {
+		return fmt.Errorf("parallelism of host job must be 1, a single device can't be operated in parallel: %d", options.Parallelism)
+	}
+
+	if options.ActiveDeadline < time.Second {
+		return fmt.Errorf("active deadline of host job must be at least 1s: %s", options.ActiveDeadline)
+	}
+
+	hostJobOptions = options
+
+	return nil
+}
+
+// imagePullSecrets are attached to Pods of metrics sidecars and host Jobs
+var imagePullSecrets = []corev1.LocalObjectReference{}
+
+// SetImagePullSecrets configures comma separated names of image pull Secrets, Secrets must exist in the namespace of the workloads
+func SetImagePullSecrets(names string) error {
+	secrets := []corev1.LocalObjectReference{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) != 0 {
+			return fmt.Errorf("invalid image pull secret %s: %s", name, strings.Join(errs, ", "))
+		}
+
+		secrets = append(secrets, corev1.LocalObjectReference{Name: name})
+	}
+
+	imagePullSecrets = secrets
+
+	return nil
+}
+
+// AddImagePullSecrets appends configured image pull Secrets to the Pod, existing ones are kept
+func AddImagePullSecrets(spec *corev1.PodSpec) {
+	for _, secret := range imagePullSecrets {
+		found := false
+		for _, existing := range spec.ImagePullSecrets {
+			if existing.Name == secret.Name {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			spec.ImagePullSecrets = append(spec.ImagePullSecrets, secret)
+		}
+	}
+}
+
+// applyHostJobOptions sets retries and deadline of host Job
+func applyHostJobOptions(job *batchv1.Job) {
+	backoffLimit, completions, parallelism := hostJobOptions.BackoffLimit, hostJobOptions.Completions, hostJobOptions.Parallelism
+	activeDeadlineSeconds := int64(hostJobOptions.ActiveDeadline / time.Second)
+
+	job.Spec.Template.Spec.RestartPolicy = hostJobOptions.RestartPolicy
+	job.Spec.BackoffLimit = &backoffLimit
+	job.Spec.Completions = &completions
+	job.Spec.Parallelism = &parallelism
+	job.Spec.ActiveDeadlineSeconds = &activeDeadlineSeconds
+}
+
+// RenderMountJob returns the mount job executed on host, container IDs of Pod status select the container runtime by their scheme
+func RenderMountJob(podName, pvcName, pvName, namespace, nodeName, fs string, formatFS bool, mountPoint string, containerIDs []string, preMountCommand, volumeMeta, stagingPath string, hostVolumes *drivers.HostJobVolumes, env []corev1.EnvVar, owner metav1.OwnerReference) (*batchv1.Job, error) {
 	if preMountCommand != "" {
 		preMountCommand += " && "
 	}
 
-	mountCommand := fmt.Sprintf(mountCommandTemplate, preMountCommand)
+	if formatFS {
+		preMountCommand += formatCommand
+	}
+
+	runtime := ""
+	trimmedIDs := make([]string, 0, len(containerIDs))
+	for _, containerID := range containerIDs {
+		if runtime == "" {
+			runtime = GetContainerRuntime(containerID)
+		}
+
+		trimmedIDs = append(trimmedIDs, TrimContainerIDPrefix(containerID))
+	}
+
+	mountCommand := fmt.Sprintf(runtimeDetectTemplate, hostRoot, runtime) + "\n" + fmt.Sprintf(mountCommandTemplate, preMountCommand, mountVerifyTimeout, mountVerifyCommand)
 	mountCommand = string(hostCommandReplacePattern.ReplaceAll([]byte(mountCommand), []byte(hostCommandPrefix)))
 
 	jobName, err := RenderResourceName(true, fmt.Sprintf("%d", time.Now().UnixNano()), pvcName, namespace)
@@ -199,7 +598,7 @@ func RenderMountJob(podName, pvcName, pvName, namespace, nodeName, fs, mountPoin
 		return nil, fmt.Errorf("unable to render resource name: %w", err)
 	}
 
-	template := fmt.Sprintf(hostJobTemplate, jobName, namespace, "mount", podName, pvcName, nodeName, mountPoint, strings.Join(containerIDs, " "), pvcName, pvName, fs, volumeMeta, mountCommand)
+	template := fmt.Sprintf(hostJobTemplate, jobName, namespace, JobOperationAnnotation(), "mount", JobPodAnnotation(), podName, JobPVCAnnotation(), pvcName, nodeName, mountPoint, strings.Join(trimmedIDs, " "), pvcName, pvName, fs, volumeMeta, mountCommand)
 
 	job := batchv1.Job{}
 	if err := yaml.Unmarshal([]byte(template), &job); err != nil {
@@ -207,6 +606,20 @@ func RenderMountJob(podName, pvcName, pvName, namespace, nodeName, fs, mountPoin
 		return nil, fmt.Errorf("unable to unmarshal job: %w", err)
 	}
 
+	if err := addHostJobVolumes(&job, hostVolumes); err != nil {
+		return nil, fmt.Errorf("invalid host job volumes: %w", err)
+	}
+
+	applyHostJobOptions(&job)
+
+	AddImagePullSecrets(&job.Spec.Template.Spec)
+
+	addStagingPathEnv(&job, stagingPath, pvName)
+
+	if err := addHostJobEnv(&job, env); err != nil {
+		return nil, err
+	}
+
 	job.OwnerReferences = []metav1.OwnerReference{
 		owner,
 	}
@@ -215,12 +628,22 @@ func RenderMountJob(podName, pvcName, pvName, namespace, nodeName, fs, mountPoin
 }
 
 // RenderResizeJob returns the resize job executed on host
-func RenderResizeJob(podName, pvcName, pvName, namespace, nodeName, fs, preResizeCommand, volumeMeta string, owner metav1.OwnerReference) (*batchv1.Job, error) {
+func RenderResizeJob(podName, pvcName, pvName, namespace, nodeName, fs, preResizeCommand, volumeMeta, stagingPath string, hostVolumes *drivers.HostJobVolumes, env []corev1.EnvVar, preHook, postHook *ResizeHook, owner metav1.OwnerReference) (*batchv1.Job, error) {
 	if preResizeCommand != "" {
 		preResizeCommand += " && "
 	}
 
-	resizeCommand := fmt.Sprintf(resizeCommandTemplate, preResizeCommand)
+	resizeCommand := fmt.Sprintf(resizeCommandTemplate, preResizeCommand, renderFSIdentityCommand(fsIdentityMode, growCommand))
+	if preHook != nil || postHook != nil {
+		runtime := ""
+		for _, hook := range []*ResizeHook{preHook, postHook} {
+			if hook != nil && runtime == "" {
+				runtime = hook.Runtime
+			}
+		}
+
+		resizeCommand = fmt.Sprintf(runtimeDetectTemplate, hostRoot, runtime) + "\n" + fmt.Sprintf(resizeHooksTemplate, renderResizeHookCommand(preResizeHookName, preHook), resizeCommand, renderResizeHookCommand(postResizeHookName, postHook))
+	}
 	resizeCommand = string(hostCommandReplacePattern.ReplaceAll([]byte(resizeCommand), []byte(hostCommandPrefix)))
 
 	jobName, err := RenderResourceName(true, fmt.Sprintf("%d", time.Now().UnixNano()), pvcName, namespace)
@@ -228,7 +651,7 @@ func RenderResizeJob(podName, pvcName, pvName, namespace, nodeName, fs, preResiz
 		return nil, fmt.Errorf("unable to render resource name: %w", err)
 	}
 
-	template := fmt.Sprintf(hostJobTemplate, jobName, namespace, "resize", podName, pvcName, nodeName, "", "", pvcName, pvName, fs, volumeMeta, resizeCommand)
+	template := fmt.Sprintf(hostJobTemplate, jobName, namespace, JobOperationAnnotation(), "resize", JobPodAnnotation(), podName, JobPVCAnnotation(), pvcName, nodeName, "", "", pvcName, pvName, fs, volumeMeta, resizeCommand)
 
 	job := batchv1.Job{}
 	if err := yaml.Unmarshal([]byte(template), &job); err != nil {
@@ -236,6 +659,23 @@ func RenderResizeJob(podName, pvcName, pvName, namespace, nodeName, fs, preResiz
 		return nil, fmt.Errorf("unable to unmarshal job: %w", err)
 	}
 
+	if err := addHostJobVolumes(&job, hostVolumes); err != nil {
+		return nil, fmt.Errorf("invalid host job volumes: %w", err)
+	}
+
+	applyHostJobOptions(&job)
+
+	AddImagePullSecrets(&job.Spec.Template.Spec)
+
+	addStagingPathEnv(&job, stagingPath, pvName)
+
+	addResizeHookEnv(&job, preResizeHookName, preHook)
+	addResizeHookEnv(&job, postResizeHookName, postHook)
+
+	if err := addHostJobEnv(&job, env); err != nil {
+		return nil, err
+	}
+
 	job.OwnerReferences = []metav1.OwnerReference{
 		owner,
 	}
@@ -243,17 +683,219 @@ func RenderResizeJob(podName, pvcName, pvName, namespace, nodeName, fs, preResiz
 	return &job, nil
 }
 
+// RenderStagingPath returns the staging path of the PersistentVolume by the template of the driver, empty template means the kubelet default
+func RenderStagingPath(template, pvName string) string {
+	if template == "" {
+		template = DefaultStagingPath
+	}
+
+	return strings.ReplaceAll(template, "${PV_NAME}", pvName)
+}
+
+// addStagingPathEnv passes staging path of the PersistentVolume to pre-mount and pre-resize commands
+func addStagingPathEnv(job *batchv1.Job, stagingPath, pvName string) {
+	container := &job.Spec.Template.Spec.Containers[0]
+	container.Env = append(container.Env, corev1.EnvVar{Name: "STAGING_PATH", Value: RenderStagingPath(stagingPath, pvName)})
+}
+
+// AddVolumeEnv passes details of the PersistentVolume and its StorageClass to pre-mount and pre-resize commands of drivers.
+// Parameters of the StorageClass and attributes of the volume are JSON objects, topology is empty if the volume isn't bound to a single zone or region.
+func AddVolumeEnv(job *batchv1.Job, pv *corev1.PersistentVolume, sc *storagev1.StorageClass) error {
+	driver, volumeHandle, volumeAttributes := "", "", map[string]string{}
+	if pv.Spec.CSI != nil {
+		driver, volumeHandle = pv.Spec.CSI.Driver, pv.Spec.CSI.VolumeHandle
+		if pv.Spec.CSI.VolumeAttributes != nil {
+			volumeAttributes = pv.Spec.CSI.VolumeAttributes
+		}
+	}
+
+	scParameters := map[string]string{}
+	if sc.Parameters != nil {
+		scParameters = sc.Parameters
+	}
+
+	rawAttributes, err := json.Marshal(volumeAttributes)
+	if err != nil {
+		return fmt.Errorf("unable to marshal volume attributes: %w", err)
+	}
+
+	rawParameters, err := json.Marshal(scParameters)
+	if err != nil {
+		return fmt.Errorf("unable to marshal StorageClass parameters: %w", err)
+	}
+
+	container := &job.Spec.Template.Spec.Containers[0]
+	container.Env = append(container.Env,
+		corev1.EnvVar{Name: "PVC_NAMESPACE", Value: job.Namespace},
+		corev1.EnvVar{Name: "CSI_DRIVER", Value: driver},
+		corev1.EnvVar{Name: "VOLUME_HANDLE", Value: volumeHandle},
+		corev1.EnvVar{Name: "VOLUME_ATTRIBUTES", Value: string(rawAttributes)},
+		corev1.EnvVar{Name: "STORAGE_CLASS_NAME", Value: sc.Name},
+		corev1.EnvVar{Name: "STORAGE_CLASS_PARAMETERS", Value: string(rawParameters)},
+		corev1.EnvVar{Name: "TOPOLOGY_ZONE", Value: getVolumeTopology(pv, corev1.LabelTopologyZone)},
+		corev1.EnvVar{Name: "TOPOLOGY_REGION", Value: getVolumeTopology(pv, corev1.LabelTopologyRegion)},
+	)
+
+	return nil
+}
+
+// getVolumeTopology returns the value of the topology key from node affinity of the volume if it is unambiguous
+func getVolumeTopology(pv *corev1.PersistentVolume, key string) string {
+	if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+		return ""
+	}
+
+	value := ""
+	for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+		for _, expr := range term.MatchExpressions {
+			if expr.Key != key || expr.Operator != corev1.NodeSelectorOpIn || len(expr.Values) != 1 {
+				continue
+			}
+
+			if value != "" && value != expr.Values[0] {
+				return ""
+			}
+			value = expr.Values[0]
+		}
+	}
+
+	return value
+}
+
+// addResizeHookEnv passes command of the hook as environment variable to avoid escaping
+func addResizeHookEnv(job *batchv1.Job, name string, hook *ResizeHook) {
+	if hook == nil {
+		return
+	}
+
+	container := &job.Spec.Template.Spec.Containers[0]
+	container.Env = append(container.Env,
+		corev1.EnvVar{Name: name, Value: hook.Command},
+		corev1.EnvVar{Name: name + "_CONTAINER_ID", Value: hook.ContainerID},
+	)
+}
+
+// addHostJobEnv validates and appends extra environment variables to host job
+func addHostJobEnv(job *batchv1.Job, env []corev1.EnvVar) error {
+	if err := discoblocksondatiov1.ValidateMountEnv(env); err != nil {
+		return fmt.Errorf("invalid host job env: %w", err)
+	}
+
+	container := &job.Spec.Template.Spec.Containers[0]
+	for i := range env {
+		container.Env = append(container.Env, *env[i].DeepCopy())
+	}
+
+	return nil
+}
+
+// addHostJobVolumes validates and appends driver volumes to host job
+func addHostJobVolumes(job *batchv1.Job, hostVolumes *drivers.HostJobVolumes) error {
+	if hostVolumes == nil {
+		return nil
+	}
+
+	podSpec := &job.Spec.Template.Spec
+	container := &podSpec.Containers[0]
+
+	volumeNames := map[string]bool{}
+	for i := range podSpec.Volumes {
+		volumeNames[podSpec.Volumes[i].Name] = false
+	}
+
+	for i := range hostVolumes.Volumes {
+		volume := hostVolumes.Volumes[i]
+
+		if errs := validation.IsDNS1123Label(volume.Name); len(errs) != 0 {
+			return fmt.Errorf("invalid volume name %s: %s", volume.Name, strings.Join(errs, ", "))
+		}
+		if _, ok := volumeNames[volume.Name]; ok {
+			return fmt.Errorf("duplicated volume name: %s", volume.Name)
+		}
+
+		if volume.HostPath == nil || volume.VolumeSource != (corev1.VolumeSource{HostPath: volume.HostPath}) {
+			return fmt.Errorf("only host path is supported: %s", volume.Name)
+		}
+		if err := validateHostJobPath(volume.HostPath.Path); err != nil {
+			return fmt.Errorf("invalid host path of %s: %w", volume.Name, err)
+		}
+
+		volumeNames[volume.Name] = true
+
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: volume.Name,
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{
+					Path: volume.HostPath.Path,
+					Type: volume.HostPath.Type,
+				},
+			},
+		})
+	}
+
+	mountPaths := map[string]bool{}
+	for i := range container.VolumeMounts {
+		mountPaths[container.VolumeMounts[i].MountPath] = true
+	}
+
+	for i := range hostVolumes.VolumeMounts {
+		mount := hostVolumes.VolumeMounts[i]
+
+		if own, ok := volumeNames[mount.Name]; !ok || !own {
+			return fmt.Errorf("volume not found for mount: %s", mount.Name)
+		}
+
+		if err := validateHostJobPath(mount.MountPath); err != nil {
+			return fmt.Errorf("invalid mount path of %s: %w", mount.Name, err)
+		}
+		if mountPaths[mount.MountPath] || mount.MountPath == "/host" || strings.HasPrefix(mount.MountPath, "/host/") {
+			return fmt.Errorf("mount path already in use: %s", mount.MountPath)
+		}
+
+		mountPaths[mount.MountPath] = true
+
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      mount.Name,
+			MountPath: mount.MountPath,
+			ReadOnly:  mount.ReadOnly,
+		})
+	}
+
+	return nil
+}
+
+// validateHostJobPath checks path is absolute, clean and not the root
+func validateHostJobPath(p string) error {
+	switch {
+	case !path.IsAbs(p):
+		return errors.New("path must be absolute")
+	case path.Clean(p) != p || strings.Contains(p, ".."):
+		return errors.New("path must be clean")
+	case p == "/":
+		return errors.New("root path is not allowed")
+	}
+
+	return nil
+}
+
 // PVCDecorator decorates new PVC instance
 func PVCDecorator(config *discoblocksondatiov1.DiskConfig, prefix string, driver *drivers.Driver, pvc *corev1.PersistentVolumeClaim) {
 	pvc.Finalizers = []string{RenderFinalizer(config.Name)}
 
 	pvc.Labels = map[string]string{
-		"discoblocks": config.Name,
+		ConfigLabel(): config.Name,
+	}
+
+	if len(config.Spec.PVCAnnotations) != 0 && pvc.Annotations == nil {
+		pvc.Annotations = map[string]string{}
+	}
+	for k, v := range config.Spec.PVCAnnotations {
+		pvc.Annotations[k] = v
 	}
 
 	pvc.Spec.Resources = corev1.ResourceRequirements{
 		Requests: corev1.ResourceList{
-			corev1.ResourceStorage: config.Spec.Capacity,
+			corev1.ResourceStorage: config.Spec.GetDiskCapacity(0),
 		},
 	}
 
@@ -263,6 +905,300 @@ func PVCDecorator(config *discoblocksondatiov1.DiskConfig, prefix string, driver
 	}
 }
 
+// RenderChildPVC renders additional disk of the parent PVC with the given index
+func RenderChildPVC(parent *corev1.PersistentVolumeClaim, index int) (*corev1.PersistentVolumeClaim, error) {
+	name, err := RenderResourceName(true, parent.Name, strconv.Itoa(index))
+	if err != nil {
+		return nil, fmt.Errorf("unable to render resource name: %w", err)
+	}
+
+	child := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   parent.Namespace,
+			Labels:      map[string]string{},
+			Annotations: map[string]string{},
+			Finalizers:  append([]string{}, parent.Finalizers...),
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "v1",
+					Kind:       "PersistentVolumeClaim",
+					Name:       parent.Name,
+					UID:        parent.UID,
+				},
+			},
+		},
+		Spec: *parent.Spec.DeepCopy(),
+	}
+	child.Spec.VolumeName = ""
+
+	for k, v := range parent.Labels {
+		child.Labels[k] = v
+	}
+	for k, v := range parent.Annotations {
+		child.Annotations[k] = v
+	}
+
+	child.Labels[ParentLabel()] = parent.Name
+	child.Labels[IndexLabel()] = strconv.Itoa(index)
+
+	return &child, nil
+}
+
+// GetPVCIndex returns index of the PVC in its group, parent and invalid labels are 0
+func GetPVCIndex(pvc *corev1.PersistentVolumeClaim) int {
+	index, err := strconv.Atoi(pvc.Labels[IndexLabel()])
+	if err != nil {
+		return 0
+	}
+
+	return index
+}
+
+// ValidateAdoption checks existing PVC is compatible with DiskConfig
+func ValidateAdoption(pvc *corev1.PersistentVolumeClaim, config *discoblocksondatiov1.DiskConfig, sc *storagev1.StorageClass) error {
+	switch {
+	case pvc.DeletionTimestamp != nil:
+		return errors.New("PVC is terminating")
+	case pvc.Status.Phase != corev1.ClaimBound:
+		return fmt.Errorf("PVC is not bound: %s", pvc.Status.Phase)
+	case pvc.Labels[ConfigLabel()] != "" && pvc.Labels[ConfigLabel()] != config.Name:
+		return fmt.Errorf("PVC is managed by another DiskConfig: %s", pvc.Labels[ConfigLabel()])
+	case pvc.Labels[ParentLabel()] != "":
+		return errors.New("PVC is an additional disk of a group")
+	case pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName != config.Spec.StorageClassName:
+		return fmt.Errorf("StorageClass of PVC differs from %s", config.Spec.StorageClassName)
+	case !IsStorageClassExpandable(sc):
+		return fmt.Errorf("StorageClass doesn't allow volume expansion: %s", sc.Name)
+	case !IsProvisionerManaged(sc.Provisioner):
+		return fmt.Errorf("provisioner is not managed: %s", sc.Provisioner)
+	}
+
+	return nil
+}
+
+const defaultOwnerLabelPrefix = "discoblocks.ondat.io/"
+
+// Owner label names of PVCs
+const (
+	PodLabelName          = "pod"
+	WorkloadLabelName     = "workload"
+	WorkloadKindLabelName = "workload-kind"
+)
+
+// RenderOwnerLabels renders traceability labels of PVC, invalid label values are skipped
+func RenderOwnerLabels(pod *corev1.Pod, withPod bool) map[string]string {
+	prefix := labelPrefix
+	if prefix == "" {
+		prefix = defaultOwnerLabelPrefix
+	}
+
+	ownerLabels := map[string]string{}
+
+	set := func(name, value string) {
+		if value != "" && len(validation.IsValidLabelValue(value)) == 0 {
+			ownerLabels[prefix+name] = value
+		}
+	}
+
+	if withPod {
+		set(PodLabelName, pod.Name)
+	}
+
+	for i := range pod.OwnerReferences {
+		owner := pod.OwnerReferences[i]
+		if owner.Controller == nil || !*owner.Controller {
+			continue
+		}
+
+		kind, name := owner.Kind, owner.Name
+		if hash, ok := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; ok && kind == "ReplicaSet" && strings.HasSuffix(name, "-"+hash) {
+			kind, name = "Deployment", strings.TrimSuffix(name, "-"+hash)
+		}
+
+		set(WorkloadKindLabelName, kind)
+		set(WorkloadLabelName, name)
+
+		break
+	}
+
+	return ownerLabels
+}
+
+// PinAnnotationName is the name of the PVC annotation freezing capacity of the disk
+const PinAnnotationName = "pin"
+
+// PinAnnotation returns the key of the PVC annotation freezing capacity of the disk
+func PinAnnotation() string {
+	prefix := labelPrefix
+	if prefix == "" {
+		prefix = defaultOwnerLabelPrefix
+	}
+
+	return prefix + PinAnnotationName
+}
+
+// IsPVCPinned checks whether the PVC is pinned at its actual capacity
+func IsPVCPinned(pvc *corev1.PersistentVolumeClaim) bool {
+	pinned, err := strconv.ParseBool(pvc.Annotations[PinAnnotation()])
+
+	return err == nil && pinned
+}
+
+// AddDiskAnnotationName is the name of the Pod annotation requesting a new disk of the given config
+const AddDiskAnnotationName = "add-disk"
+
+// AddDiskAnnotation returns the key of the Pod annotation requesting a new disk
+func AddDiskAnnotation() string {
+	prefix := labelPrefix
+	if prefix == "" {
+		prefix = defaultOwnerLabelPrefix
+	}
+
+	return prefix + AddDiskAnnotationName
+}
+
+// RenderVolumeMount returns the mount of the disk in the container, Bidirectional propagation is allowed only in privileged containers
+func RenderVolumeMount(container *corev1.Container, name, mountPath string, propagation *corev1.MountPropagationMode) (corev1.VolumeMount, error) {
+	volumeMount := corev1.VolumeMount{
+		Name:      name,
+		MountPath: mountPath,
+	}
+
+	if propagation == nil {
+		return volumeMount, nil
+	}
+
+	switch *propagation {
+	case corev1.MountPropagationNone, corev1.MountPropagationHostToContainer:
+	case corev1.MountPropagationBidirectional:
+		if container.SecurityContext == nil || container.SecurityContext.Privileged == nil || !*container.SecurityContext.Privileged {
+			return corev1.VolumeMount{}, fmt.Errorf("bidirectional mount propagation requires privileged container: %s", container.Name)
+		}
+	default:
+		return corev1.VolumeMount{}, fmt.Errorf("mount propagation not supported: %s", *propagation)
+	}
+
+	mode := *propagation
+	volumeMount.MountPropagation = &mode
+
+	return volumeMount, nil
+}
+
+// AppendPodVolumesAnnotations appends volume names to the comma separated value of the given Pod annotations
+func AppendPodVolumesAnnotations(pod *corev1.Pod, keys, volumeNames []string) {
+	if len(keys) == 0 || len(volumeNames) == 0 {
+		return
+	}
+
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+
+	for _, key := range keys {
+		names := []string{}
+		existing := map[string]bool{}
+		for _, name := range strings.Split(pod.Annotations[key], ",") {
+			if name = strings.TrimSpace(name); name != "" && !existing[name] {
+				names = append(names, name)
+				existing[name] = true
+			}
+		}
+
+		for _, name := range volumeNames {
+			if !existing[name] {
+				names = append(names, name)
+				existing[name] = true
+			}
+		}
+
+		pod.Annotations[key] = strings.Join(names, ",")
+	}
+}
+
+// VolumesAnnotationName is the name of the Pod annotation listing the volumes attached by Discoblocks
+const VolumesAnnotationName = "volumes"
+
+// maxVolumesAnnotationSize keeps the annotation far below the total size limit of annotations
+const maxVolumesAnnotationSize = 64 * 1024
+
+// VolumesAnnotation returns the key of the Pod annotation listing the attached volumes
+func VolumesAnnotation() string {
+	prefix := labelPrefix
+	if prefix == "" {
+		prefix = defaultOwnerLabelPrefix
+	}
+
+	return prefix + VolumesAnnotationName
+}
+
+// AnnotatedVolume is an item of the volumes annotation
+type AnnotatedVolume struct {
+	PVC       string `json:"pvc"`
+	MountPath string `json:"mountPath"`
+}
+
+// RenderVolumesAnnotation returns the value of volumes annotation ordered by mount path, volumes maps PVC names to mount points
+func RenderVolumesAnnotation(volumes map[string]string) (string, error) {
+	annotated := make([]AnnotatedVolume, 0, len(volumes))
+	for pvcName, mountPath := range volumes {
+		annotated = append(annotated, AnnotatedVolume{PVC: pvcName, MountPath: mountPath})
+	}
+
+	sort.Slice(annotated, func(i, j int) bool {
+		return annotated[i].MountPath < annotated[j].MountPath
+	})
+
+	raw, err := json.Marshal(annotated)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal volumes: %w", err)
+	}
+
+	if len(raw) > maxVolumesAnnotationSize {
+		return "", fmt.Errorf("volumes annotation is too large: %d > %d bytes", len(raw), maxVolumesAnnotationSize)
+	}
+
+	return string(raw), nil
+}
+
+// IsNewDiskRequested returns true if new disk of the config is requested on the Pod
+func IsNewDiskRequested(pod *corev1.Pod, configName string) bool {
+	value, ok := pod.Annotations[AddDiskAnnotation()]
+	if !ok {
+		return false
+	}
+
+	for _, name := range strings.Split(value, ",") {
+		if strings.TrimSpace(name) == configName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RemoveNewDiskRequest removes the config from new disk request of the Pod, returns false if request not found
+func RemoveNewDiskRequest(pod *corev1.Pod, configName string) bool {
+	if !IsNewDiskRequested(pod, configName) {
+		return false
+	}
+
+	remaining := []string{}
+	for _, name := range strings.Split(pod.Annotations[AddDiskAnnotation()], ",") {
+		if name = strings.TrimSpace(name); name != "" && name != configName {
+			remaining = append(remaining, name)
+		}
+	}
+
+	if len(remaining) == 0 {
+		delete(pod.Annotations, AddDiskAnnotation())
+	} else {
+		pod.Annotations[AddDiskAnnotation()] = strings.Join(remaining, ",")
+	}
+
+	return true
+}
+
 // NewStorageClass constructs a new StorageClass
 func NewStorageClass(sc *storagev1.StorageClass, scAllowedTopology []corev1.TopologySelectorTerm) (*storagev1.StorageClass, error) {
 	topologyItems := ""
@@ -279,6 +1215,9 @@ func NewStorageClass(sc *storagev1.StorageClass, scAllowedTopology []corev1.Topo
 	topologySC.UID = ""
 	topologySC.ResourceVersion = ""
 	topologySC.Name = tmpScName
+	// Topology StorageClass must not become default of the cluster
+	delete(topologySC.Annotations, discoblocksondatiov1.DefaultStorageClassAnnotation)
+	delete(topologySC.Annotations, discoblocksondatiov1.BetaDefaultStorageClassAnnotation)
 	bm := storagev1.VolumeBindingImmediate
 	topologySC.VolumeBindingMode = &bm
 	topologySC.AllowedTopologies = scAllowedTopology
@@ -286,6 +1225,147 @@ func NewStorageClass(sc *storagev1.StorageClass, scAllowedTopology []corev1.Topo
 	return topologySC, nil
 }
 
+// IsDerivedStorageClass checks whether the StorageClass name belongs to a topology StorageClass of sc
+func IsDerivedStorageClass(name string, sc *storagev1.StorageClass) bool {
+	prefix, err := RenderResourceName(true, string(sc.UID), sc.Name)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(name, prefix+"-")
+}
+
+// IsStorageClassExpandable checks whether volumes of the StorageClass can be resized
+func IsStorageClassExpandable(sc *storagev1.StorageClass) bool {
+	return sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion
+}
+
+// DetectPVCDrift compares the existing PVC to the one rendered by DiskConfig.
+// Topology StorageClasses of sc are not drift, neither bigger capacity because disks grow by usage.
+func DetectPVCDrift(existing, desired *corev1.PersistentVolumeClaim, sc *storagev1.StorageClass) (storageClassDrift, capacityDrift bool) {
+	existingSC := ""
+	if existing.Spec.StorageClassName != nil {
+		existingSC = *existing.Spec.StorageClassName
+	}
+	storageClassDrift = existingSC != sc.Name && !IsDerivedStorageClass(existingSC, sc)
+
+	existingCapacity := existing.Spec.Resources.Requests[corev1.ResourceStorage]
+	desiredCapacity := desired.Spec.Resources.Requests[corev1.ResourceStorage]
+	capacityDrift = existingCapacity.Cmp(desiredCapacity) == -1
+
+	return storageClassDrift, capacityDrift
+}
+
+// SelectedNodeAnnotation tells provisioner the node of the volume, normally set by scheduler
+const SelectedNodeAnnotation = "volume.kubernetes.io/selected-node"
+
+// GetSingleNode returns name of the only node of cluster
+func GetSingleNode(ctx context.Context, kubeClient client.Client) (string, error) {
+	nodes := corev1.NodeList{}
+	if err := kubeClient.List(ctx, &nodes); err != nil {
+		return "", fmt.Errorf("unable to list nodes: %w", err)
+	}
+
+	if len(nodes.Items) != 1 {
+		return "", fmt.Errorf("single node expected, found %d", len(nodes.Items))
+	}
+
+	return nodes.Items[0].Name, nil
+}
+
+// SetSelectedNode sets target node of PVC for delayed binding without scheduler
+func SetSelectedNode(pvc *corev1.PersistentVolumeClaim, nodeName string) {
+	if pvc.Annotations == nil {
+		pvc.Annotations = map[string]string{}
+	}
+
+	pvc.Annotations[SelectedNodeAnnotation] = nodeName
+}
+
+// ResolveLocalVolumeNode returns the node of a local volume, the node recorded on the existing PVC wins over target node of the Pod
+func ResolveLocalVolumeNode(existingPVC *corev1.PersistentVolumeClaim, nodeName string) (string, error) {
+	recordedNode := ""
+	if existingPVC != nil {
+		recordedNode = existingPVC.Annotations[SelectedNodeAnnotation]
+	}
+
+	switch {
+	case recordedNode == "" && nodeName == "":
+		return "", errors.New("node of local volume not found, node affinity of Pod is required")
+	case recordedNode == "":
+		return nodeName, nil
+	case nodeName != "" && nodeName != recordedNode:
+		return "", fmt.Errorf("local volume is bound to node %s, Pod targets %s", recordedNode, nodeName)
+	}
+
+	return recordedNode, nil
+}
+
+// SetNodeAffinity pins Pod to the node with the same required term DaemonSet controller uses
+func SetNodeAffinity(pod *corev1.Pod, nodeName string) {
+	if GetTargetNodeByAffinity(pod.Spec.Affinity) == nodeName {
+		return
+	}
+
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &corev1.Affinity{}
+	}
+	if pod.Spec.Affinity.NodeAffinity == nil {
+		pod.Spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	if pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{}
+	}
+
+	required := pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if len(required.NodeSelectorTerms) == 0 {
+		required.NodeSelectorTerms = []corev1.NodeSelectorTerm{{}}
+	}
+
+	// Terms are ORed, so the node has to be required by each of them
+	for i := range required.NodeSelectorTerms {
+		required.NodeSelectorTerms[i].MatchFields = append(required.NodeSelectorTerms[i].MatchFields, corev1.NodeSelectorRequirement{
+			Key:      "metadata.name",
+			Operator: corev1.NodeSelectorOpIn,
+			Values:   []string{nodeName},
+		})
+	}
+}
+
+// FSTypeParameter is the StorageClass parameter of file-system type
+const FSTypeParameter = "csi.storage.k8s.io/fstype"
+
+// GetFileSystem returns file-system type of StorageClass, empty if it is not set
+func GetFileSystem(sc *storagev1.StorageClass) string {
+	return strings.ToLower(sc.Parameters[FSTypeParameter])
+}
+
+// IsMetricsReady checks pod is running and metrics sidecars are ready
+func IsMetricsReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+
+	ready := map[string]bool{
+		"discoblocks-metrics":       false,
+		"discoblocks-metrics-proxy": false,
+	}
+
+	for i := range pod.Status.ContainerStatuses {
+		if _, ok := ready[pod.Status.ContainerStatuses[i].Name]; ok {
+			ready[pod.Status.ContainerStatuses[i].Name] = pod.Status.ContainerStatuses[i].Ready
+		}
+	}
+
+	for _, r := range ready {
+		if !r {
+			return false
+		}
+	}
+
+	return true
+}
+
 // IsOwnedByDaemonSet detects is parent DaemonSet
 func IsOwnedByDaemonSet(pod *corev1.Pod) bool {
 	for i := range pod.OwnerReferences {
@@ -316,3 +1396,272 @@ func GetTargetNodeByAffinity(affinit *corev1.Affinity) string {
 
 	return ""
 }
+
+// IsNamespaceSelected checks namespace is selected by ClusterDiskConfig
+func IsNamespaceSelected(config *discoblocksondatiov1.ClusterDiskConfig, namespace *corev1.Namespace) (bool, error) {
+	selector, err := metav1.LabelSelectorAsSelector(config.Spec.NamespaceSelector)
+	if err != nil {
+		return false, fmt.Errorf("unable to parse namespace selector: %w", err)
+	}
+
+	return selector.Matches(labels.Set(namespace.Labels)), nil
+}
+
+// RenderClusterDiskConfig renders namespaced DiskConfig of ClusterDiskConfig
+func RenderClusterDiskConfig(config *discoblocksondatiov1.ClusterDiskConfig, namespace string) *discoblocksondatiov1.DiskConfig {
+	controller := true
+
+	return &discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      config.Name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				ClusterConfigLabel(): config.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         discoblocksondatiov1.GroupVersion.String(),
+					Kind:               "ClusterDiskConfig",
+					Name:               config.Name,
+					UID:                config.UID,
+					Controller:         &controller,
+					BlockOwnerDeletion: &controller,
+				},
+			},
+		},
+		Spec: *config.Spec.DiskConfigSpec.DeepCopy(),
+	}
+}
+
+// IsRenderedByClusterConfig checks DiskConfig is managed by the given ClusterDiskConfig
+func IsRenderedByClusterConfig(config *discoblocksondatiov1.DiskConfig, clusterConfigName string) bool {
+	return config.Labels[ClusterConfigLabel()] == clusterConfigName
+}
+
+// ServiceMonitorGVK is the kind of Prometheus Operator ServiceMonitor
+var ServiceMonitorGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "ServiceMonitor"}
+
+// IsServiceMonitorSupported checks ServiceMonitor CRD is installed
+func IsServiceMonitorSupported(mapper meta.RESTMapper) (bool, error) {
+	if _, err := mapper.RESTMapping(ServiceMonitorGVK.GroupKind(), ServiceMonitorGVK.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("unable to find ServiceMonitor mapping: %w", err)
+	}
+
+	return true, nil
+}
+
+// VolumeAttributesClassGK is the kind of VolumeAttributesClass, version depends on Kubernetes version
+var VolumeAttributesClassGK = schema.GroupKind{Group: "storage.k8s.io", Kind: "VolumeAttributesClass"}
+
+// IsVolumeAttributesClassSupported checks VolumeAttributesClass API is served in any version
+func IsVolumeAttributesClassSupported(mapper meta.RESTMapper) (bool, error) {
+	if _, err := mapper.RESTMapping(VolumeAttributesClassGK); err != nil {
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("unable to find VolumeAttributesClass mapping: %w", err)
+	}
+
+	return true, nil
+}
+
+// RenderVolumeAttributesClassPatch renders PVC patch of VolumeAttributesClass, PVC type of client doesn't know the field yet
+func RenderVolumeAttributesClassPatch(name string) (client.Patch, error) {
+	data, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"volumeAttributesClassName": name,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal patch: %w", err)
+	}
+
+	return client.RawPatch(types.MergePatchType, data), nil
+}
+
+// GetVolumeAttributesClassName returns VolumeAttributesClass of the PVC, PVC is read as unstructured object,
+// because PVC type of client doesn't know the field yet
+func GetVolumeAttributesClassName(ctx context.Context, kubeClient client.Client, key client.ObjectKey) (string, error) {
+	pvc := unstructured.Unstructured{}
+	pvc.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"))
+
+	if err := kubeClient.Get(ctx, key, &pvc); err != nil {
+		return "", fmt.Errorf("unable to fetch PVC: %w", err)
+	}
+
+	name, _, err := unstructured.NestedString(pvc.Object, "spec", "volumeAttributesClassName")
+	if err != nil {
+		return "", fmt.Errorf("invalid VolumeAttributesClass of PVC: %w", err)
+	}
+
+	return name, nil
+}
+
+// RenderServiceMonitor renders ServiceMonitor of DiskConfig, it keeps only metrics of the DiskConfig namespace
+func RenderServiceMonitor(config *discoblocksondatiov1.DiskConfig, metricsNamespace string) (*unstructured.Unstructured, error) {
+	name, err := RenderResourceName(true, config.Name, config.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("unable to render ServiceMonitor name: %w", err)
+	}
+
+	controller := true
+
+	sm := unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"endpoints": []interface{}{
+					map[string]interface{}{
+						"path":            "/metrics",
+						"port":            "https",
+						"scheme":          "https",
+						"bearerTokenFile": "/var/run/secrets/kubernetes.io/serviceaccount/token",
+						"tlsConfig": map[string]interface{}{
+							"insecureSkipVerify": true,
+						},
+						"metricRelabelings": []interface{}{
+							map[string]interface{}{
+								"action":       "keep",
+								"sourceLabels": []interface{}{"resourceNamespace"},
+								"regex":        config.Namespace,
+							},
+						},
+					},
+				},
+				"namespaceSelector": map[string]interface{}{
+					"matchNames": []interface{}{metricsNamespace},
+				},
+				"selector": map[string]interface{}{
+					"matchLabels": map[string]interface{}{
+						"app":                         "discoblocks",
+						"app.kubernetes.io/component": "discoblocks",
+					},
+				},
+			},
+		},
+	}
+	sm.SetGroupVersionKind(ServiceMonitorGVK)
+	sm.SetName(name)
+	sm.SetNamespace(config.Namespace)
+	sm.SetLabels(map[string]string{
+		"app":                 "discoblocks",
+		DiskConfigNameLabel(): config.Name,
+	})
+	sm.SetOwnerReferences([]metav1.OwnerReference{
+		{
+			APIVersion:         discoblocksondatiov1.GroupVersion.String(),
+			Kind:               "DiskConfig",
+			Name:               config.Name,
+			UID:                config.UID,
+			Controller:         &controller,
+			BlockOwnerDeletion: &controller,
+		},
+	})
+
+	return &sm, nil
+}
+
+// RenderVolumeResizeRequest renders the request of resizing the PVC to the capacity, the request is owned by the PVC
+func RenderVolumeResizeRequest(configName, podName, nodeName string, pvc *corev1.PersistentVolumeClaim, capacity resource.Quantity, reason string, now time.Time) (*discoblocksondatiov1.VolumeResizeRequest, error) {
+	name, err := RenderResourceName(true, pvc.Name, strconv.FormatInt(now.UnixNano(), 10))
+	if err != nil {
+		return nil, fmt.Errorf("unable to render name: %w", err)
+	}
+
+	return &discoblocksondatiov1.VolumeResizeRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: pvc.Namespace,
+			Labels: map[string]string{
+				ConfigLabel(): configName,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "v1",
+					Kind:       "PersistentVolumeClaim",
+					Name:       pvc.Name,
+					UID:        pvc.UID,
+				},
+			},
+		},
+		Spec: discoblocksondatiov1.VolumeResizeRequestSpec{
+			PVCName:     pvc.Name,
+			ConfigName:  configName,
+			PodName:     podName,
+			NodeName:    nodeName,
+			OldCapacity: pvc.Spec.Resources.Requests[corev1.ResourceStorage],
+			Capacity:    capacity,
+			Reason:      reason,
+		},
+	}, nil
+}
+
+// IsVolumeResizeRequestOpen checks whether the request is neither completed nor failed
+func IsVolumeResizeRequestOpen(request *discoblocksondatiov1.VolumeResizeRequest) bool {
+	return request.Status.Phase != discoblocksondatiov1.VolumeResizeRequestCompleted &&
+		request.Status.Phase != discoblocksondatiov1.VolumeResizeRequestFailed
+}
+
+// FindOpenVolumeResizeRequest returns the open request of the PVC, nil if there is none
+func FindOpenVolumeResizeRequest(requests []discoblocksondatiov1.VolumeResizeRequest, pvcName string) *discoblocksondatiov1.VolumeResizeRequest {
+	for i := range requests {
+		if requests[i].Spec.PVCName == pvcName && IsVolumeResizeRequestOpen(&requests[i]) {
+			return &requests[i]
+		}
+	}
+
+	return nil
+}
+
+// DefaultMetricsPort is the local port of the metrics sidecar if the Pod doesn't use it
+const DefaultMetricsPort int32 = 59100
+
+// maxMetricsPort is the last port tried for the metrics sidecar
+const maxMetricsPort int32 = 59199
+
+// prometheusPortAnnotation is the well-known annotation of Prometheus scrape port
+const prometheusPortAnnotation = "prometheus.io/port"
+
+// MetricsPortAnnotationName is the name of the Pod annotation recording the local port of the metrics sidecar
+const MetricsPortAnnotationName = "metrics-port"
+
+// MetricsPortAnnotation returns the key of the Pod annotation recording the local port of the metrics sidecar
+func MetricsPortAnnotation() string {
+	prefix := labelPrefix
+	if prefix == "" {
+		prefix = defaultOwnerLabelPrefix
+	}
+
+	return prefix + MetricsPortAnnotationName
+}
+
+// SelectMetricsPort returns the first port for the metrics sidecar not used by containers or Prometheus annotation of the Pod
+func SelectMetricsPort(pod *corev1.Pod) (int32, error) {
+	used := map[int32]bool{}
+
+	if raw, ok := pod.Annotations[prometheusPortAnnotation]; ok {
+		if port, err := strconv.ParseInt(raw, 10, 32); err == nil {
+			used[int32(port)] = true
+		}
+	}
+
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for i := range containers {
+			for _, port := range containers[i].Ports {
+				used[port.ContainerPort] = true
+			}
+		}
+	}
+
+	for port := DefaultMetricsPort; port <= maxMetricsPort; port++ {
+		if !used[port] {
+			return port, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no free metrics port between %d and %d", DefaultMetricsPort, maxMetricsPort)
+}
@@ -1,13 +1,1877 @@
 package utils
 
 import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"github.com/ondat/discoblocks/pkg/drivers"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 func TestRenderMetricsSidecar(t *testing.T) {
-	_, err := RenderMetricsSidecar()
+	t.Parallel()
 
-	assert.Nil(t, err, "invalid sidecar template")
+	propagation := corev1.MountPropagationHostToContainer
+
+	cases := map[string]struct {
+		privileged           bool
+		expectedVolumeMounts []corev1.VolumeMount
+	}{
+		"unprivileged": {},
+		"privileged": {
+			privileged: true,
+			expectedVolumeMounts: []corev1.VolumeMount{
+				{
+					Name:             "discoblocks-kubelet",
+					MountPath:        "/var/lib/kubelet",
+					ReadOnly:         true,
+					MountPropagation: &propagation,
+				},
+			},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			sidecar, err := RenderMetricsSidecar(59101, c.privileged)
+			require.Nil(t, err, "invalid sidecar template")
+
+			assert.Equal(t, "discoblocks-metrics", sidecar.Name, "invalid sidecar name")
+			assert.Contains(t, sidecar.Command[len(sidecar.Command)-1], "127.0.0.1 59101 ", "invalid sidecar port")
+			require.NotNil(t, sidecar.SecurityContext.Privileged, "privileged is unset")
+			assert.Equal(t, c.privileged, *sidecar.SecurityContext.Privileged, "invalid privileged")
+			assert.Equal(t, c.expectedVolumeMounts, sidecar.VolumeMounts, "invalid volume mounts")
+		})
+	}
+}
+
+func TestRenderMetricsProxySidecar(t *testing.T) {
+	t.Parallel()
+
+	sidecar, err := RenderMetricsProxySidecar("pod", "default", 59101)
+	require.Nil(t, err, "invalid proxy sidecar template")
+
+	command := sidecar.Command[len(sidecar.Command)-1]
+	assert.Contains(t, command, "[default-pod]\n", "invalid proxy name")
+	assert.Contains(t, command, "local_port = 59101\n", "invalid proxy sidecar port")
+}
+
+func TestSelectMetricsPort(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		annotations   map[string]string
+		ports         []int32
+		expectedPort  int32
+		expectedError bool
+	}{
+		"default": {
+			ports:        []int32{8080},
+			expectedPort: DefaultMetricsPort,
+		},
+		"container port in use": {
+			ports:        []int32{DefaultMetricsPort},
+			expectedPort: DefaultMetricsPort + 1,
+		},
+		"prometheus annotation in use": {
+			annotations:  map[string]string{"prometheus.io/port": "59100"},
+			ports:        []int32{59101},
+			expectedPort: DefaultMetricsPort + 2,
+		},
+		"invalid prometheus annotation": {
+			annotations:  map[string]string{"prometheus.io/port": "metrics"},
+			expectedPort: DefaultMetricsPort,
+		},
+		"all ports in use": {
+			ports: func() []int32 {
+				ports := []int32{}
+				for port := DefaultMetricsPort; port <= maxMetricsPort; port++ {
+					ports = append(ports, port)
+				}
+				return ports
+			}(),
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: c.annotations,
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "app"}},
+				},
+			}
+			for _, port := range c.ports {
+				pod.Spec.Containers[0].Ports = append(pod.Spec.Containers[0].Ports, corev1.ContainerPort{ContainerPort: port})
+			}
+
+			port, err := SelectMetricsPort(&pod)
+			assert.Equal(t, c.expectedError, err != nil, "invalid error")
+			assert.Equal(t, c.expectedPort, port, "invalid port")
+		})
+	}
+}
+
+func TestIsNamespaceSelected(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		selector      *metav1.LabelSelector
+		labels        map[string]string
+		expected      bool
+		expectedError bool
+	}{
+		"missing selector": {
+			labels: map[string]string{"team": "a"},
+		},
+		"empty selector": {
+			selector: &metav1.LabelSelector{},
+			labels:   map[string]string{"team": "a"},
+			expected: true,
+		},
+		"matching labels": {
+			selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+			labels:   map[string]string{"team": "a", "env": "prod"},
+			expected: true,
+		},
+		"not matching labels": {
+			selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+			labels:   map[string]string{"team": "b"},
+		},
+		"matching expression": {
+			selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "team", Operator: metav1.LabelSelectorOpIn, Values: []string{"a", "b"}},
+			}},
+			labels:   map[string]string{"team": "b"},
+			expected: true,
+		},
+		"invalid expression": {
+			selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "team", Operator: "foo"},
+			}},
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			config := discoblocksondatiov1.ClusterDiskConfig{
+				Spec: discoblocksondatiov1.ClusterDiskConfigSpec{
+					NamespaceSelector: c.selector,
+				},
+			}
+			namespace := corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: c.labels,
+				},
+			}
+
+			selected, err := IsNamespaceSelected(&config, &namespace)
+			if c.expectedError {
+				assert.NotNil(t, err, "error missing")
+				return
+			}
+
+			require.Nil(t, err, "unexpected error")
+			assert.Equal(t, c.expected, selected, "invalid selection")
+		})
+	}
+}
+
+func TestRenderClusterDiskConfig(t *testing.T) {
+	config := discoblocksondatiov1.ClusterDiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "shared",
+			UID:  "uid",
+		},
+		Spec: discoblocksondatiov1.ClusterDiskConfigSpec{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+			DiskConfigSpec: discoblocksondatiov1.DiskConfigSpec{
+				StorageClassName: "sc",
+				PodSelector:      map[string]string{"app": "nginx"},
+			},
+		},
+	}
+
+	namespaces := []corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "first", Labels: map[string]string{"team": "a"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "second", Labels: map[string]string{"team": "a"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "third", Labels: map[string]string{"team": "b"}}},
+	}
+
+	rendered := []string{}
+	for i := range namespaces {
+		selected, err := IsNamespaceSelected(&config, &namespaces[i])
+		require.Nil(t, err, "unexpected error")
+
+		if !selected {
+			continue
+		}
+
+		dc := RenderClusterDiskConfig(&config, namespaces[i].Name)
+
+		assert.Equal(t, config.Name, dc.Name, "invalid name")
+		assert.Equal(t, namespaces[i].Name, dc.Namespace, "invalid namespace")
+		assert.True(t, IsRenderedByClusterConfig(dc, config.Name), "invalid label")
+		assert.Equal(t, config.Spec.DiskConfigSpec, dc.Spec, "invalid spec")
+		require.Len(t, dc.OwnerReferences, 1, "invalid owner")
+		assert.Equal(t, config.UID, dc.OwnerReferences[0].UID, "invalid owner")
+
+		rendered = append(rendered, dc.Namespace)
+	}
+
+	assert.Equal(t, []string{"first", "second"}, rendered, "invalid namespaces")
+
+	userConfig := discoblocksondatiov1.DiskConfig{ObjectMeta: metav1.ObjectMeta{Name: config.Name, Namespace: "first"}}
+	assert.False(t, IsRenderedByClusterConfig(&userConfig, config.Name), "namespaced config is not rendered")
+}
+
+func TestIsServiceMonitorSupported(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		kinds    []schema.GroupVersionKind
+		expected bool
+	}{
+		"crd-missing": {},
+		"crd-installed": {
+			kinds:    []schema.GroupVersionKind{ServiceMonitorGVK},
+			expected: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			mapper := meta.NewDefaultRESTMapper(nil)
+			for _, k := range c.kinds {
+				mapper.Add(k, meta.RESTScopeNamespace)
+			}
+
+			supported, err := IsServiceMonitorSupported(mapper)
+
+			require.Nil(t, err, "unexpected error")
+			assert.Equal(t, c.expected, supported, "invalid support detection")
+		})
+	}
+}
+
+func TestIsVolumeAttributesClassSupported(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		version  string
+		expected bool
+	}{
+		"api-missing": {},
+		"alpha": {
+			version:  "v1alpha1",
+			expected: true,
+		},
+		"beta": {
+			version:  "v1beta1",
+			expected: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Group: "storage.k8s.io", Version: "v1"}})
+			mapper.Add(schema.GroupVersionKind{Group: "storage.k8s.io", Version: "v1", Kind: "StorageClass"}, meta.RESTScopeRoot)
+			if c.version != "" {
+				mapper = meta.NewDefaultRESTMapper([]schema.GroupVersion{{Group: VolumeAttributesClassGK.Group, Version: c.version}})
+				mapper.Add(VolumeAttributesClassGK.WithVersion(c.version), meta.RESTScopeRoot)
+			}
+
+			supported, err := IsVolumeAttributesClassSupported(mapper)
+
+			require.Nil(t, err, "unexpected error")
+			assert.Equal(t, c.expected, supported, "invalid support detection")
+		})
+	}
+}
+
+func TestRenderVolumeAttributesClassPatch(t *testing.T) {
+	t.Parallel()
+
+	patch, err := RenderVolumeAttributesClassPatch("fast")
+	require.Nil(t, err, "invalid patch")
+
+	data, err := patch.Data(&corev1.PersistentVolumeClaim{})
+	require.Nil(t, err, "invalid patch data")
+
+	assert.Equal(t, types.MergePatchType, patch.Type(), "invalid patch type")
+	assert.JSONEq(t, `{"spec":{"volumeAttributesClassName":"fast"}}`, string(data), "invalid patch content")
+}
+
+func TestRenderServiceMonitor(t *testing.T) {
+	config := discoblocksondatiov1.DiskConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+			UID:       "uid",
+		},
+	}
+
+	sm, err := RenderServiceMonitor(&config, "kube-system")
+	require.Nil(t, err, "unable to render")
+
+	assert.Equal(t, ServiceMonitorGVK, sm.GroupVersionKind(), "invalid kind")
+	assert.Equal(t, "default", sm.GetNamespace(), "invalid namespace")
+	require.Len(t, sm.GetOwnerReferences(), 1, "invalid owner")
+	assert.Equal(t, "DiskConfig", sm.GetOwnerReferences()[0].Kind, "invalid owner kind")
+	assert.Equal(t, config.UID, sm.GetOwnerReferences()[0].UID, "invalid owner")
+
+	namespaces, found, err := unstructured.NestedStringSlice(sm.Object, "spec", "namespaceSelector", "matchNames")
+	require.Nil(t, err, "invalid namespace selector")
+	assert.True(t, found, "namespace selector missing")
+	assert.Equal(t, []string{"kube-system"}, namespaces, "invalid namespace selector")
+
+	endpoints, found, err := unstructured.NestedSlice(sm.Object, "spec", "endpoints")
+	require.Nil(t, err, "invalid endpoints")
+	assert.True(t, found, "endpoints missing")
+	require.Len(t, endpoints, 1, "invalid endpoints")
+
+	relabelings, found, err := unstructured.NestedSlice(endpoints[0].(map[string]interface{}), "metricRelabelings")
+	require.Nil(t, err, "invalid relabelings")
+	assert.True(t, found, "relabelings missing")
+	require.Len(t, relabelings, 1, "invalid relabelings")
+	assert.Equal(t, "keep", relabelings[0].(map[string]interface{})["action"], "invalid relabeling action")
+	assert.Equal(t, "default", relabelings[0].(map[string]interface{})["regex"], "invalid relabeling regex")
+}
+
+func TestIsMetricsReady(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		phase    corev1.PodPhase
+		statuses []corev1.ContainerStatus
+		expected bool
+	}{
+		"ready": {
+			phase: corev1.PodRunning,
+			statuses: []corev1.ContainerStatus{
+				{Name: "app", Ready: true},
+				{Name: "discoblocks-metrics", Ready: true},
+				{Name: "discoblocks-metrics-proxy", Ready: true},
+			},
+			expected: true,
+		},
+		"pending": {
+			phase: corev1.PodPending,
+			statuses: []corev1.ContainerStatus{
+				{Name: "discoblocks-metrics", Ready: true},
+				{Name: "discoblocks-metrics-proxy", Ready: true},
+			},
+		},
+		"sidecar-not-ready": {
+			phase: corev1.PodRunning,
+			statuses: []corev1.ContainerStatus{
+				{Name: "app", Ready: true},
+				{Name: "discoblocks-metrics", Ready: false},
+				{Name: "discoblocks-metrics-proxy", Ready: true},
+			},
+		},
+		"sidecar-missing": {
+			phase: corev1.PodRunning,
+			statuses: []corev1.ContainerStatus{
+				{Name: "app", Ready: true},
+				{Name: "discoblocks-metrics", Ready: true},
+			},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			pod := corev1.Pod{
+				Status: corev1.PodStatus{
+					Phase:             c.phase,
+					ContainerStatuses: c.statuses,
+				},
+			}
+
+			assert.Equal(t, c.expected, IsMetricsReady(&pod), "invalid readiness")
+		})
+	}
+}
+
+func TestRenderMountJob(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		formatFS bool
+	}{
+		"fs-managed": {
+			formatFS: false,
+		},
+		"host-managed": {
+			formatFS: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			job, err := RenderMountJob("pod", "pvc", "pv", "default", "node", "ext4", c.formatFS, "/media/discoblocks/foo-1", []string{"containerd://container"}, "DEV=/dev/foo", "", "", nil, nil, metav1.OwnerReference{})
+			require.Nil(t, err, "invalid job template")
+
+			container := job.Spec.Template.Spec.Containers[0]
+			command := container.Command[len(container.Command)-1]
+
+			assert.Contains(t, container.Env, corev1.EnvVar{Name: "FS", Value: "ext4"}, "invalid file-system")
+			assert.Contains(t, container.Env, corev1.EnvVar{Name: "CONTAINER_IDS", Value: "container"}, "invalid container IDs")
+			assert.Contains(t, command, "CONTAINER_RUNTIME=containerd\n", "runtime of Pod not preferred")
+			assert.Equal(t, c.formatFS, strings.Contains(command, "mkfs.${FS} ${DEV}"), "invalid mkfs")
+			assert.Equal(t, c.formatFS, strings.Contains(command, `if [ "${BLKID_RC}" = "2" ]; then`), "mkfs without guard")
+			assert.Less(t, strings.Index(command, "DEV=/dev/foo"), strings.Index(command, "DEV_MAJOR"), "invalid order of pre mount command")
+			assert.Less(t, strings.Index(command, "CONTAINER_RUNTIME="), strings.Index(command, "DEV=/dev/foo"), "invalid order of runtime detection")
+			assert.Contains(t, command, "PID=$(container_pid ${CONTAINER_ID})", "detected runtime not used")
+
+			if c.formatFS {
+				assert.Less(t, strings.Index(command, "mkfs"), strings.Index(command, "DEV_MAJOR"), "invalid order of mkfs")
+			}
+		})
+	}
+}
+
+func TestRenderStagingPath(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		stagingPath  string
+		expectedPath string
+	}{
+		"default": {
+			expectedPath: "/var/lib/kubelet/plugins/kubernetes.io/csi/pv/pv/globalmount",
+		},
+		"custom driver path": {
+			stagingPath:  "/var/lib/kubelet/plugins/kubernetes.io/csi/foo.csi.io/${PV_NAME}/staging",
+			expectedPath: "/var/lib/kubelet/plugins/kubernetes.io/csi/foo.csi.io/pv/staging",
+		},
+		"static driver path": {
+			stagingPath:  "/mnt/foo",
+			expectedPath: "/mnt/foo",
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			mountJob, err := RenderMountJob("pod", "pvc", "pv", "default", "node", "ext4", false, "/media/discoblocks/foo-1", []string{"container"}, "", "", c.stagingPath, nil, nil, metav1.OwnerReference{})
+			require.Nil(t, err, "invalid mount job template")
+
+			resizeJob, err := RenderResizeJob("pod", "pvc", "pv", "default", "node", "ext4", "", "", c.stagingPath, nil, nil, nil, nil, metav1.OwnerReference{})
+			require.Nil(t, err, "invalid resize job template")
+
+			for _, job := range []*batchv1.Job{mountJob, resizeJob} {
+				assert.Contains(t, job.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{Name: "STAGING_PATH", Value: c.expectedPath}, "invalid staging path")
+			}
+		})
+	}
+}
+
+func TestAddVolumeEnv(t *testing.T) {
+	t.Parallel()
+
+	zonal := &corev1.VolumeNodeAffinity{
+		Required: &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+				MatchExpressions: []corev1.NodeSelectorRequirement{
+					{Key: corev1.LabelTopologyZone, Operator: corev1.NodeSelectorOpIn, Values: []string{"eu-west-1a"}},
+					{Key: corev1.LabelTopologyRegion, Operator: corev1.NodeSelectorOpIn, Values: []string{"eu-west-1"}},
+				},
+			}},
+		},
+	}
+	multiZone := &corev1.VolumeNodeAffinity{
+		Required: &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+				MatchExpressions: []corev1.NodeSelectorRequirement{
+					{Key: corev1.LabelTopologyZone, Operator: corev1.NodeSelectorOpIn, Values: []string{"eu-west-1a", "eu-west-1b"}},
+				},
+			}},
+		},
+	}
+
+	cases := map[string]struct {
+		csi          *corev1.CSIPersistentVolumeSource
+		nodeAffinity *corev1.VolumeNodeAffinity
+		parameters   map[string]string
+		expected     map[string]string
+	}{
+		"not CSI": {
+			expected: map[string]string{
+				"PVC_NAMESPACE":            "default",
+				"CSI_DRIVER":               "",
+				"VOLUME_HANDLE":            "",
+				"VOLUME_ATTRIBUTES":        "{}",
+				"STORAGE_CLASS_NAME":       "sc",
+				"STORAGE_CLASS_PARAMETERS": "{}",
+				"TOPOLOGY_ZONE":            "",
+				"TOPOLOGY_REGION":          "",
+			},
+		},
+		"zonal": {
+			csi: &corev1.CSIPersistentVolumeSource{
+				Driver:           "ebs.csi.aws.com",
+				VolumeHandle:     "vol-123",
+				VolumeAttributes: map[string]string{"foo": "bar"},
+			},
+			nodeAffinity: zonal,
+			parameters:   map[string]string{"type": "gp3"},
+			expected: map[string]string{
+				"PVC_NAMESPACE":            "default",
+				"CSI_DRIVER":               "ebs.csi.aws.com",
+				"VOLUME_HANDLE":            "vol-123",
+				"VOLUME_ATTRIBUTES":        `{"foo":"bar"}`,
+				"STORAGE_CLASS_NAME":       "sc",
+				"STORAGE_CLASS_PARAMETERS": `{"type":"gp3"}`,
+				"TOPOLOGY_ZONE":            "eu-west-1a",
+				"TOPOLOGY_REGION":          "eu-west-1",
+			},
+		},
+		"multiple zones": {
+			csi: &corev1.CSIPersistentVolumeSource{
+				Driver:       "ebs.csi.aws.com",
+				VolumeHandle: "vol-123",
+			},
+			nodeAffinity: multiZone,
+			expected: map[string]string{
+				"PVC_NAMESPACE":            "default",
+				"CSI_DRIVER":               "ebs.csi.aws.com",
+				"VOLUME_HANDLE":            "vol-123",
+				"VOLUME_ATTRIBUTES":        "{}",
+				"STORAGE_CLASS_NAME":       "sc",
+				"STORAGE_CLASS_PARAMETERS": "{}",
+				"TOPOLOGY_ZONE":            "",
+				"TOPOLOGY_REGION":          "",
+			},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			pv := corev1.PersistentVolume{
+				Spec: corev1.PersistentVolumeSpec{
+					PersistentVolumeSource: corev1.PersistentVolumeSource{
+						CSI: c.csi,
+					},
+					NodeAffinity: c.nodeAffinity,
+				},
+			}
+			sc := storagev1.StorageClass{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "sc",
+				},
+				Parameters: c.parameters,
+			}
+
+			mountJob, err := RenderMountJob("pod", "pvc", "pv", "default", "node", "ext4", false, "/media/discoblocks/foo-1", []string{"container"}, "", "", "", nil, nil, metav1.OwnerReference{})
+			require.Nil(t, err, "invalid mount job template")
+
+			resizeJob, err := RenderResizeJob("pod", "pvc", "pv", "default", "node", "ext4", "", "", "", nil, nil, nil, nil, metav1.OwnerReference{})
+			require.Nil(t, err, "invalid resize job template")
+
+			for _, job := range []*batchv1.Job{mountJob, resizeJob} {
+				require.Nil(t, AddVolumeEnv(job, &pv, &sc), "unable to add volume env")
+
+				for name, value := range c.expected {
+					assert.Contains(t, job.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{Name: name, Value: value}, "invalid env: "+name)
+					assert.NotNil(t, discoblocksondatiov1.ValidateMountEnv([]corev1.EnvVar{{Name: name}}), "env isn't reserved: "+name)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderHostJobVolumes(t *testing.T) {
+	t.Parallel()
+
+	hostPath := func(name, path string) corev1.Volume {
+		return corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: path}}}
+	}
+
+	cases := map[string]struct {
+		volumes *drivers.HostJobVolumes
+		valid   bool
+	}{
+		"valid": {
+			volumes: &drivers.HostJobVolumes{
+				Volumes:      []corev1.Volume{hostPath("plugin", "/var/lib/plugin")},
+				VolumeMounts: []corev1.VolumeMount{{Name: "plugin", MountPath: "/plugin", ReadOnly: true}},
+			},
+			valid: true,
+		},
+		"relative path": {
+			volumes: &drivers.HostJobVolumes{
+				Volumes: []corev1.Volume{hostPath("plugin", "var/lib/plugin")},
+			},
+		},
+		"traversal": {
+			volumes: &drivers.HostJobVolumes{
+				Volumes: []corev1.Volume{hostPath("plugin", "/var/lib/../../etc")},
+			},
+		},
+		"root": {
+			volumes: &drivers.HostJobVolumes{
+				Volumes: []corev1.Volume{hostPath("plugin", "/")},
+			},
+		},
+		"duplicated name": {
+			volumes: &drivers.HostJobVolumes{
+				Volumes: []corev1.Volume{hostPath("host", "/var/lib/plugin")},
+			},
+		},
+		"not host path": {
+			volumes: &drivers.HostJobVolumes{
+				Volumes: []corev1.Volume{{Name: "plugin", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}},
+			},
+		},
+		"unknown volume": {
+			volumes: &drivers.HostJobVolumes{
+				VolumeMounts: []corev1.VolumeMount{{Name: "host", MountPath: "/plugin"}},
+			},
+		},
+		"mount conflict": {
+			volumes: &drivers.HostJobVolumes{
+				Volumes:      []corev1.Volume{hostPath("plugin", "/var/lib/plugin")},
+				VolumeMounts: []corev1.VolumeMount{{Name: "plugin", MountPath: "/host/etc"}},
+			},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			mountJob, mountErr := RenderMountJob("pod", "pvc", "pv", "default", "node", "ext4", false, "/media/discoblocks/foo-1", []string{"container"}, "", "", "", c.volumes, nil, metav1.OwnerReference{})
+			resizeJob, resizeErr := RenderResizeJob("pod", "pvc", "pv", "default", "node", "ext4", "", "", "", c.volumes, nil, nil, nil, metav1.OwnerReference{})
+
+			if !c.valid {
+				assert.NotNil(t, mountErr, "invalid mount job volumes")
+				assert.NotNil(t, resizeErr, "invalid resize job volumes")
+				return
+			}
+
+			require.Nil(t, mountErr, "invalid mount job template")
+			require.Nil(t, resizeErr, "invalid resize job template")
+
+			for _, job := range []*batchv1.Job{mountJob, resizeJob} {
+				spec := job.Spec.Template.Spec
+				assert.Contains(t, spec.Volumes, c.volumes.Volumes[0], "volume not found")
+				assert.Contains(t, spec.Containers[0].VolumeMounts, c.volumes.VolumeMounts[0], "volume mount not found")
+			}
+		})
+	}
+}
+
+func TestRenderResizeHook(t *testing.T) {
+	t.Parallel()
+
+	discoblocksondatiov1.SetResizeHookNamespaces([]string{"hooks"})
+
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "hooks",
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app"}, {Name: "db"}, {Name: "sidecar"}},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "app", ContainerID: "containerd://app-id", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+				{Name: "db", ContainerID: "docker://db-id", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+				{Name: "sidecar", ContainerID: "containerd://sidecar-id", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}},
+			},
+		},
+	}
+
+	cases := map[string]struct {
+		namespace     string
+		hook          *discoblocksondatiov1.ResizeHook
+		expectedError bool
+		expected      *ResizeHook
+	}{
+		"no hook": {},
+		"first container": {
+			hook:     &discoblocksondatiov1.ResizeHook{Command: "sync"},
+			expected: &ResizeHook{ContainerID: "app-id", Command: "sync", Timeout: defaultResizeHookTimeout},
+		},
+		"named container": {
+			hook:     &discoblocksondatiov1.ResizeHook{Container: "db", Command: "pg_ctl stop", TimeoutSeconds: 10},
+			expected: &ResizeHook{ContainerID: "db-id", Command: "pg_ctl stop", Timeout: 10},
+		},
+		"not running": {
+			hook:          &discoblocksondatiov1.ResizeHook{Container: "sidecar", Command: "sync"},
+			expectedError: true,
+		},
+		"missing container": {
+			hook:          &discoblocksondatiov1.ResizeHook{Container: "foo", Command: "sync"},
+			expectedError: true,
+		},
+		"denied namespace": {
+			namespace:     "default",
+			hook:          &discoblocksondatiov1.ResizeHook{Command: "sync"},
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			pod := pod.DeepCopy()
+			if c.namespace != "" {
+				pod.Namespace = c.namespace
+			}
+
+			hook, err := RenderResizeHook(pod, c.hook)
+			if c.expectedError {
+				assert.NotNil(t, err, "error missing")
+				return
+			}
+
+			require.Nil(t, err, "unexpected error")
+			assert.Equal(t, c.expected, hook, "invalid hook")
+		})
+	}
+}
+
+func TestRenderResizeJobHooks(t *testing.T) {
+	t.Parallel()
+
+	preHook := &ResizeHook{ContainerID: "app-id", Command: "fsfreeze", Timeout: 30}
+	postHook := &ResizeHook{ContainerID: "db-id", Command: "unfreeze", Timeout: 60}
+
+	job, err := RenderResizeJob("pod", "pvc", "pv", "default", "node", "ext4", "DEV=/dev/foo", "", "", nil, nil, preHook, postHook, metav1.OwnerReference{})
+	require.Nil(t, err, "invalid job template")
+
+	container := job.Spec.Template.Spec.Containers[0]
+	command := container.Command[len(container.Command)-1]
+
+	assert.Contains(t, container.Env, corev1.EnvVar{Name: "PRE_RESIZE_HOOK", Value: "fsfreeze"}, "pre hook command not found")
+	assert.Contains(t, container.Env, corev1.EnvVar{Name: "PRE_RESIZE_HOOK_CONTAINER_ID", Value: "app-id"}, "pre hook container not found")
+	assert.Contains(t, container.Env, corev1.EnvVar{Name: "POST_RESIZE_HOOK", Value: "unfreeze"}, "post hook command not found")
+	assert.Contains(t, container.Env, corev1.EnvVar{Name: "POST_RESIZE_HOOK_CONTAINER_ID", Value: "db-id"}, "post hook container not found")
+
+	assert.Contains(t, command, `timeout 30 chroot /host nsenter --target ${PRE_RESIZE_HOOK_PID}`, "invalid pre hook timeout")
+	assert.Contains(t, command, `timeout 60 chroot /host nsenter --target ${POST_RESIZE_HOOK_PID}`, "invalid post hook timeout")
+	assert.Less(t, strings.Index(command, `sh -c "${PRE_RESIZE_HOOK}"`), strings.Index(command, "DEV=/dev/foo"), "invalid order of pre hook")
+	assert.Less(t, strings.Index(command, "resize2fs"), strings.Index(command, `sh -c "${POST_RESIZE_HOOK}"`), "invalid order of post hook")
+
+	job, err = RenderResizeJob("pod", "pvc", "pv", "default", "node", "ext4", "", "", "", nil, nil, nil, nil, metav1.OwnerReference{})
+	require.Nil(t, err, "invalid job template")
+
+	container = job.Spec.Template.Spec.Containers[0]
+	assert.NotContains(t, container.Command[len(container.Command)-1], "RESIZE_HOOK", "hook without config")
+}
+
+func TestResizeHooksTemplate(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found")
+	}
+
+	cases := map[string]struct {
+		pre            string
+		resize         string
+		post           string
+		expectedError  bool
+		expectedOutput string
+	}{
+		"success": {
+			pre:            "echo pre",
+			resize:         "echo resize",
+			post:           "echo post",
+			expectedOutput: "pre\nresize\npost\n",
+		},
+		"pre hook failure aborts resize": {
+			pre:            "echo pre && false",
+			resize:         "echo resize",
+			post:           "echo post",
+			expectedError:  true,
+			expectedOutput: "pre\n",
+		},
+		"pre hook timeout aborts resize": {
+			pre:            "timeout 1 sleep 5",
+			resize:         "echo resize",
+			post:           "echo post",
+			expectedError:  true,
+			expectedOutput: "",
+		},
+		"resize failure runs post hook": {
+			pre:            ":",
+			resize:         "echo resize && false",
+			post:           "echo post",
+			expectedError:  true,
+			expectedOutput: "resize\npost\n",
+		},
+		"post hook failure": {
+			pre:            ":",
+			resize:         "echo resize",
+			post:           "echo post && false",
+			expectedError:  true,
+			expectedOutput: "resize\npost\n",
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			script := fmt.Sprintf(resizeHooksTemplate, c.pre, c.resize, c.post)
+
+			output, err := exec.Command("bash", "-ec", script).Output()
+			assert.Equal(t, c.expectedError, err != nil, "invalid exit code")
+			assert.Equal(t, c.expectedOutput, string(output), "invalid order of hooks")
+		})
+	}
+}
+
+func TestSetFSIdentityMode(t *testing.T) {
+	cases := map[string]struct {
+		mode          string
+		expectedError bool
+		expectedMode  string
+	}{
+		"default": {
+			mode:         "",
+			expectedMode: FSIdentityModeRestore,
+		},
+		"off": {
+			mode:         FSIdentityModeOff,
+			expectedMode: FSIdentityModeOff,
+		},
+		"verify": {
+			mode:         FSIdentityModeVerify,
+			expectedMode: FSIdentityModeVerify,
+		},
+		"unsupported": {
+			mode:          "foo",
+			expectedError: true,
+			expectedMode:  FSIdentityModeRestore,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Cleanup(func() {
+				fsIdentityMode = FSIdentityModeRestore
+			})
+
+			err := SetFSIdentityMode(c.mode)
+			assert.Equal(t, c.expectedError, err != nil, "invalid error")
+			assert.Equal(t, c.expectedMode, fsIdentityMode, "invalid mode")
+
+			job, err := RenderResizeJob("pod", "pvc", "pv", "default", "node", "ext4", "DEV=/dev/foo", "", "", nil, nil, nil, nil, metav1.OwnerReference{})
+			require.Nil(t, err, "invalid job template")
+
+			container := job.Spec.Template.Spec.Containers[0]
+			command := container.Command[len(container.Command)-1]
+
+			assert.Contains(t, command, "resize2fs ${DEV}", "grow command missing")
+			assert.Equal(t, fsIdentityMode != FSIdentityModeOff, strings.Contains(command, "FS_UUID=$(fs_uuid)"), "invalid identity check")
+			assert.Equal(t, fsIdentityMode == FSIdentityModeRestore, strings.Contains(command, "tune2fs"), "invalid identity restore")
+		})
+	}
+}
+
+func TestFSIdentityTemplate(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found")
+	}
+
+	// stubs replace host tools, blkid reads and tune2fs writes the identity files
+	const stubs = `chroot() { shift 5; "$@"; }
+blkid() { case "$5" in UUID) cat "${STATE}/uuid" ;; LABEL) cat "${STATE}/label" ;; esac; }
+tune2fs() { echo "$2" > "${STATE}/uuid" && echo "$4" > "${STATE}/label"; }
+xfs_admin() { false; }
+`
+
+	cases := map[string]struct {
+		mode          string
+		fs            string
+		resize        string
+		expectedError bool
+		expectedUUID  string
+		expectedLabel string
+	}{
+		"verify unchanged": {
+			mode:          FSIdentityModeVerify,
+			fs:            "ext4",
+			resize:        ":",
+			expectedUUID:  "1234",
+			expectedLabel: "data",
+		},
+		"verify changed": {
+			mode:          FSIdentityModeVerify,
+			fs:            "ext4",
+			resize:        `echo 5678 > "${STATE}/uuid"`,
+			expectedError: true,
+			expectedUUID:  "5678",
+			expectedLabel: "data",
+		},
+		"restore unchanged": {
+			mode:          FSIdentityModeRestore,
+			fs:            "ext4",
+			resize:        ":",
+			expectedUUID:  "1234",
+			expectedLabel: "data",
+		},
+		"restore changed": {
+			mode:          FSIdentityModeRestore,
+			fs:            "ext4",
+			resize:        `echo 5678 > "${STATE}/uuid" && echo foo > "${STATE}/label"`,
+			expectedUUID:  "1234",
+			expectedLabel: "data",
+		},
+		"restore not possible": {
+			mode:          FSIdentityModeRestore,
+			fs:            "xfs",
+			resize:        `echo 5678 > "${STATE}/uuid"`,
+			expectedError: true,
+			expectedUUID:  "5678",
+			expectedLabel: "data",
+		},
+		"resize failure": {
+			mode:          FSIdentityModeRestore,
+			fs:            "ext4",
+			resize:        "false",
+			expectedError: true,
+			expectedUUID:  "1234",
+			expectedLabel: "data",
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			state := t.TempDir()
+			require.Nil(t, os.WriteFile(filepath.Join(state, "uuid"), []byte("1234\n"), 0o600), "unable to write uuid")
+			require.Nil(t, os.WriteFile(filepath.Join(state, "label"), []byte("data\n"), 0o600), "unable to write label")
+
+			script := stubs + renderFSIdentityCommand(c.mode, c.resize)
+
+			cmd := exec.Command("bash", "-ec", script)
+			cmd.Env = append(os.Environ(), "STATE="+state, "FS="+c.fs, "DEV=/dev/foo")
+
+			err := cmd.Run()
+			assert.Equal(t, c.expectedError, err != nil, "invalid exit code")
+
+			uuid, err := os.ReadFile(filepath.Join(state, "uuid"))
+			require.Nil(t, err, "unable to read uuid")
+			assert.Equal(t, c.expectedUUID, strings.TrimSpace(string(uuid)), "invalid uuid")
+
+			label, err := os.ReadFile(filepath.Join(state, "label"))
+			require.Nil(t, err, "unable to read label")
+			assert.Equal(t, c.expectedLabel, strings.TrimSpace(string(label)), "invalid label")
+		})
+	}
+}
+
+func TestRuntimeDetectTemplate(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found")
+	}
+
+	cases := map[string]struct {
+		sockets         []string
+		podRuntime      string
+		expectedError   bool
+		expectedRuntime string
+	}{
+		"docker": {
+			sockets:         []string{"run/docker.sock"},
+			expectedRuntime: "docker",
+		},
+		"containerd": {
+			sockets:         []string{"run/containerd/containerd.sock"},
+			expectedRuntime: "containerd",
+		},
+		"cri-o": {
+			sockets:         []string{"run/crio/crio.sock"},
+			expectedRuntime: "cri-o",
+		},
+		"containerd with docker installed": {
+			sockets:         []string{"run/containerd/containerd.sock", "run/docker.sock"},
+			expectedRuntime: "containerd",
+		},
+		"docker of Pod": {
+			sockets:         []string{"run/containerd/containerd.sock", "run/docker.sock"},
+			podRuntime:      "docker",
+			expectedRuntime: "docker",
+		},
+		"missing runtime of Pod": {
+			sockets:       []string{"run/docker.sock"},
+			podRuntime:    "containerd",
+			expectedError: true,
+		},
+		"unknown": {
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			// Short path, unix socket path length is limited
+			root, err := os.MkdirTemp("", "rt")
+			require.Nil(t, err, "unable to create root")
+			t.Cleanup(func() {
+				os.RemoveAll(root)
+			})
+
+			for _, socket := range c.sockets {
+				path := filepath.Join(root, socket)
+				require.Nil(t, os.MkdirAll(filepath.Dir(path), 0o755), "unable to create socket dir")
+
+				listener, err := net.Listen("unix", path)
+				require.Nil(t, err, "unable to create socket")
+				t.Cleanup(func() {
+					listener.Close()
+				})
+			}
+
+			script := fmt.Sprintf(runtimeDetectTemplate, root, c.podRuntime) + "\ntype -t container_pid"
+
+			output, err := exec.Command("bash", "-ec", script).Output()
+			if c.expectedError {
+				assert.NotNil(t, err, "runtime detected")
+				return
+			}
+
+			require.Nil(t, err, "unable to detect runtime")
+			assert.Equal(t, "container runtime: "+c.expectedRuntime+"\nfunction\n", string(output), "invalid runtime")
+		})
+	}
+}
+
+func TestTrimContainerIDPrefix(t *testing.T) {
+	t.Parallel()
+
+	for _, id := range []string{"containerd://id", "docker://id", "cri-o://id", "id"} {
+		assert.Equal(t, "id", TrimContainerIDPrefix(id), "invalid container ID")
+	}
+}
+
+func TestGetContainerRuntime(t *testing.T) {
+	t.Parallel()
+
+	for id, runtime := range map[string]string{
+		"containerd://id": "containerd",
+		"docker://id":     "docker",
+		"cri-o://id":      "cri-o",
+		"unknown://id":    "",
+		"id":              "",
+	} {
+		assert.Equal(t, runtime, GetContainerRuntime(id), "invalid runtime of "+id)
+	}
+}
+
+func TestRenderOwnerLabels(t *testing.T) {
+	t.Parallel()
+
+	controller := true
+
+	cases := map[string]struct {
+		pod      corev1.Pod
+		withPod  bool
+		expected map[string]string
+	}{
+		"deployment": {
+			pod: corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "nginx-5d8f9c7b6-abcde",
+					Labels: map[string]string{"pod-template-hash": "5d8f9c7b6"},
+					OwnerReferences: []metav1.OwnerReference{
+						{Kind: "ReplicaSet", Name: "nginx-5d8f9c7b6", Controller: &controller},
+					},
+				},
+			},
+			withPod: true,
+			expected: map[string]string{
+				"discoblocks.ondat.io/pod":           "nginx-5d8f9c7b6-abcde",
+				"discoblocks.ondat.io/workload-kind": "Deployment",
+				"discoblocks.ondat.io/workload":      "nginx",
+			},
+		},
+		"statefulset": {
+			pod: corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "db-0",
+					OwnerReferences: []metav1.OwnerReference{
+						{Kind: "StatefulSet", Name: "db", Controller: &controller},
+					},
+				},
+			},
+			withPod: true,
+			expected: map[string]string{
+				"discoblocks.ondat.io/pod":           "db-0",
+				"discoblocks.ondat.io/workload-kind": "StatefulSet",
+				"discoblocks.ondat.io/workload":      "db",
+			},
+		},
+		"shared volume": {
+			pod: corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "db-0",
+					OwnerReferences: []metav1.OwnerReference{
+						{Kind: "StatefulSet", Name: "db", Controller: &controller},
+					},
+				},
+			},
+			expected: map[string]string{
+				"discoblocks.ondat.io/workload-kind": "StatefulSet",
+				"discoblocks.ondat.io/workload":      "db",
+			},
+		},
+		"generated name": {
+			pod: corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "nginx-",
+					OwnerReferences: []metav1.OwnerReference{
+						{Kind: "ReplicaSet", Name: "nginx", Controller: &controller},
+						{Kind: "Foo", Name: "bar"},
+					},
+				},
+			},
+			withPod: true,
+			expected: map[string]string{
+				"discoblocks.ondat.io/workload-kind": "ReplicaSet",
+				"discoblocks.ondat.io/workload":      "nginx",
+			},
+		},
+		"too long name": {
+			pod: corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: strings.Repeat("a", 64),
+				},
+			},
+			withPod:  true,
+			expected: map[string]string{},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			ownerLabels := RenderOwnerLabels(&c.pod, c.withPod)
+
+			assert.Equal(t, c.expected, ownerLabels, "invalid owner labels")
+			assert.NotContains(t, ownerLabels, ConfigLabel(), "owner labels overwrite selector")
+		})
+	}
+}
+
+func TestNewDiskRequest(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		annotations         map[string]string
+		expectedRequested   bool
+		expectedAnnotations map[string]string
+	}{
+		"no annotation": {
+			annotations:         map[string]string{},
+			expectedAnnotations: map[string]string{},
+		},
+		"other config": {
+			annotations:         map[string]string{"discoblocks.ondat.io/add-disk": "other"},
+			expectedAnnotations: map[string]string{"discoblocks.ondat.io/add-disk": "other"},
+		},
+		"requested": {
+			annotations:         map[string]string{"discoblocks.ondat.io/add-disk": "config", "foo": "bar"},
+			expectedRequested:   true,
+			expectedAnnotations: map[string]string{"foo": "bar"},
+		},
+		"requested with others": {
+			annotations:         map[string]string{"discoblocks.ondat.io/add-disk": "first, config,last"},
+			expectedRequested:   true,
+			expectedAnnotations: map[string]string{"discoblocks.ondat.io/add-disk": "first,last"},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: c.annotations,
+				},
+			}
+
+			assert.Equal(t, c.expectedRequested, IsNewDiskRequested(&pod, "config"), "invalid request detection")
+			assert.Equal(t, c.expectedRequested, RemoveNewDiskRequest(&pod, "config"), "invalid request removal")
+			assert.Equal(t, c.expectedAnnotations, pod.Annotations, "invalid annotations")
+			assert.False(t, IsNewDiskRequested(&pod, "config"), "request not removed")
+		})
+	}
+}
+
+func TestIsPVCPinned(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		annotations map[string]string
+		expected    bool
+	}{
+		"no annotation": {
+			annotations: map[string]string{},
+		},
+		"pinned": {
+			annotations: map[string]string{"discoblocks.ondat.io/pin": "true"},
+			expected:    true,
+		},
+		"unpinned": {
+			annotations: map[string]string{"discoblocks.ondat.io/pin": "false"},
+		},
+		"invalid": {
+			annotations: map[string]string{"discoblocks.ondat.io/pin": "yes"},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			pvc := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: c.annotations,
+				},
+			}
+
+			assert.Equal(t, c.expected, IsPVCPinned(&pvc), "invalid pin detection")
+		})
+	}
+}
+
+func TestSetMountVerifyCommand(t *testing.T) {
+	cases := map[string]struct {
+		command         string
+		expectedError   bool
+		expectedCommand string
+	}{
+		"default": {
+			command:         "",
+			expectedCommand: "/opt/discoblocks/busybox ls ${MOUNT_POINT}",
+		},
+		"custom": {
+			command:         "stat -f ${MOUNT_POINT}",
+			expectedCommand: "/opt/discoblocks/busybox stat -f ${MOUNT_POINT}",
+		},
+		"blank": {
+			command:       " \t ",
+			expectedError: true,
+		},
+		"not allowed": {
+			command:       "rm -rf ${MOUNT_POINT}",
+			expectedError: true,
+		},
+		"chained": {
+			command:       "ls ${MOUNT_POINT} && rm -rf /",
+			expectedError: true,
+		},
+		"substitution": {
+			command:       "ls $(rm -rf /)",
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Cleanup(func() {
+				mountVerifyCommand = DefaultMountVerifyCommand
+			})
+
+			err := SetMountVerifyCommand(c.command)
+			if c.expectedError {
+				assert.NotNil(t, err, "error missing")
+				assert.Equal(t, DefaultMountVerifyCommand, mountVerifyCommand, "command changed on error")
+				return
+			}
+
+			require.Nil(t, err, "unexpected error")
+
+			job, err := RenderMountJob("pod", "pvc", "pv", "default", "node", "ext4", false, "/media/discoblocks/foo-1", []string{"container"}, "DEV=/dev/foo", "", "", nil, nil, metav1.OwnerReference{})
+			require.Nil(t, err, "invalid job template")
+
+			container := job.Spec.Template.Spec.Containers[0]
+			command := container.Command[len(container.Command)-1]
+
+			assert.Contains(t, command, "timeout 30 chroot /host nsenter --target ${PID} --mount "+c.expectedCommand+"\n", "invalid verify command")
+			assert.Less(t, strings.Index(command, "mount ${DEV} ${MOUNT_POINT}"), strings.Index(command, c.expectedCommand), "invalid order of verify command")
+		})
+	}
+}
+
+func TestSetHostJobOptions(t *testing.T) {
+	cases := map[string]struct {
+		options       HostJobOptions
+		expectedError bool
+	}{
+		"default": {
+			options: DefaultHostJobOptions,
+		},
+		"retry on failure": {
+			options: HostJobOptions{RestartPolicy: corev1.RestartPolicyOnFailure, BackoffLimit: 3, Completions: 1, Parallelism: 1, ActiveDeadline: time.Minute},
+		},
+		"multiple completions": {
+			options: HostJobOptions{RestartPolicy: corev1.RestartPolicyNever, BackoffLimit: 0, Completions: 2, Parallelism: 1, ActiveDeadline: time.Minute},
+		},
+		"restart always": {
+			options:       HostJobOptions{RestartPolicy: corev1.RestartPolicyAlways, BackoffLimit: 0, Completions: 1, Parallelism: 1, ActiveDeadline: time.Minute},
+			expectedError: true,
+		},
+		"negative backoff limit": {
+			options:       HostJobOptions{RestartPolicy: corev1.RestartPolicyNever, BackoffLimit: -1, Completions: 1, Parallelism: 1, ActiveDeadline: time.Minute},
+			expectedError: true,
+		},
+		"zero completions": {
+			options:       HostJobOptions{RestartPolicy: corev1.RestartPolicyNever, BackoffLimit: 0, Completions: 0, Parallelism: 1, ActiveDeadline: time.Minute},
+			expectedError: true,
+		},
+		"parallel": {
+			options:       HostJobOptions{RestartPolicy: corev1.RestartPolicyNever, BackoffLimit: 0, Completions: 2, Parallelism: 2, ActiveDeadline: time.Minute},
+			expectedError: true,
+		},
+		"no active deadline": {
+			options:       HostJobOptions{RestartPolicy: corev1.RestartPolicyNever, BackoffLimit: 0, Completions: 1, Parallelism: 1},
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Cleanup(func() {
+				hostJobOptions = DefaultHostJobOptions
+			})
+
+			err := SetHostJobOptions(c.options)
+			if c.expectedError {
+				assert.NotNil(t, err, "error missing")
+				assert.Equal(t, DefaultHostJobOptions, hostJobOptions, "options changed on error")
+				return
+			}
+
+			require.Nil(t, err, "unexpected error")
+
+			mountJob, err := RenderMountJob("pod", "pvc", "pv", "default", "node", "ext4", false, "/media/discoblocks/foo-1", []string{"container"}, "DEV=/dev/foo", "", "", nil, nil, metav1.OwnerReference{})
+			require.Nil(t, err, "invalid mount job template")
+
+			resizeJob, err := RenderResizeJob("pod", "pvc", "pv", "default", "node", "ext4", "DEV=/dev/foo", "", "", nil, nil, nil, nil, metav1.OwnerReference{})
+			require.Nil(t, err, "invalid resize job template")
+
+			for _, job := range []*batchv1.Job{mountJob, resizeJob} {
+				assert.Equal(t, c.options.RestartPolicy, job.Spec.Template.Spec.RestartPolicy, "invalid restart policy")
+				require.NotNil(t, job.Spec.BackoffLimit, "backoff limit missing")
+				assert.Equal(t, c.options.BackoffLimit, *job.Spec.BackoffLimit, "invalid backoff limit")
+				require.NotNil(t, job.Spec.Completions, "completions missing")
+				assert.Equal(t, c.options.Completions, *job.Spec.Completions, "invalid completions")
+				require.NotNil(t, job.Spec.Parallelism, "parallelism missing")
+				assert.Equal(t, c.options.Parallelism, *job.Spec.Parallelism, "invalid parallelism")
+				require.NotNil(t, job.Spec.ActiveDeadlineSeconds, "active deadline missing")
+				assert.Equal(t, int64(c.options.ActiveDeadline/time.Second), *job.Spec.ActiveDeadlineSeconds, "invalid active deadline")
+				require.NotNil(t, job.Spec.TTLSecondsAfterFinished, "ttl after finished missing")
+			}
+		})
+	}
+}
+
+func TestSetImagePullSecrets(t *testing.T) {
+	cases := map[string]struct {
+		names           string
+		existing        []corev1.LocalObjectReference
+		expectedSecrets []corev1.LocalObjectReference
+		expectedError   bool
+	}{
+		"none": {},
+		"single": {
+			names:           "registry",
+			expectedSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+		},
+		"multiple": {
+			names:           "registry, mirror",
+			expectedSecrets: []corev1.LocalObjectReference{{Name: "registry"}, {Name: "mirror"}},
+		},
+		"existing kept": {
+			names:           "registry,mirror",
+			existing:        []corev1.LocalObjectReference{{Name: "mirror"}},
+			expectedSecrets: []corev1.LocalObjectReference{{Name: "mirror"}, {Name: "registry"}},
+		},
+		"invalid": {
+			names:         "Registry_Secret",
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Cleanup(func() {
+				imagePullSecrets = []corev1.LocalObjectReference{}
+			})
+
+			err := SetImagePullSecrets(c.names)
+			if c.expectedError {
+				assert.NotNil(t, err, "error missing")
+				assert.Empty(t, imagePullSecrets, "secrets changed on error")
+				return
+			}
+
+			require.Nil(t, err, "unexpected error")
+
+			pod := corev1.Pod{
+				Spec: corev1.PodSpec{
+					ImagePullSecrets: append([]corev1.LocalObjectReference{}, c.existing...),
+				},
+			}
+			AddImagePullSecrets(&pod.Spec)
+
+			mountJob, err := RenderMountJob("pod", "pvc", "pv", "default", "node", "ext4", false, "/media/discoblocks/foo-1", []string{"container"}, "DEV=/dev/foo", "", "", nil, nil, metav1.OwnerReference{})
+			require.Nil(t, err, "invalid mount job template")
+
+			resizeJob, err := RenderResizeJob("pod", "pvc", "pv", "default", "node", "ext4", "DEV=/dev/foo", "", "", nil, nil, nil, nil, metav1.OwnerReference{})
+			require.Nil(t, err, "invalid resize job template")
+
+			if len(c.expectedSecrets) == 0 {
+				assert.Empty(t, pod.Spec.ImagePullSecrets, "invalid pod secrets")
+				assert.Empty(t, mountJob.Spec.Template.Spec.ImagePullSecrets, "invalid mount job secrets")
+				assert.Empty(t, resizeJob.Spec.Template.Spec.ImagePullSecrets, "invalid resize job secrets")
+				return
+			}
+
+			assert.Equal(t, c.expectedSecrets, pod.Spec.ImagePullSecrets, "invalid pod secrets")
+
+			if len(c.existing) == 0 {
+				assert.Equal(t, c.expectedSecrets, mountJob.Spec.Template.Spec.ImagePullSecrets, "invalid mount job secrets")
+				assert.Equal(t, c.expectedSecrets, resizeJob.Spec.Template.Spec.ImagePullSecrets, "invalid resize job secrets")
+			}
+		})
+	}
+}
+
+func TestRenderHostJobEnv(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		env           []corev1.EnvVar
+		expectedError bool
+	}{
+		"value": {
+			env: []corev1.EnvVar{{Name: "DISCOBLOCKS_USER_REGION", Value: "eu-west-1"}},
+		},
+		"secret": {
+			env: []corev1.EnvVar{{Name: "DISCOBLOCKS_USER_TOKEN", ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "creds"}, Key: "token"},
+			}}},
+		},
+		"reserved": {
+			env:           []corev1.EnvVar{{Name: "PV_NAME", Value: "other"}},
+			expectedError: true,
+		},
+		"reserved shell variable": {
+			env:           []corev1.EnvVar{{Name: "DEV", Value: "/dev/other"}},
+			expectedError: true,
+		},
+		"shell startup file": {
+			env:           []corev1.EnvVar{{Name: "BASH_ENV", Value: "/tmp/evil"}},
+			expectedError: true,
+		},
+		"preloaded library": {
+			env:           []corev1.EnvVar{{Name: "LD_PRELOAD", Value: "/tmp/evil.so"}},
+			expectedError: true,
+		},
+		"prefix only": {
+			env:           []corev1.EnvVar{{Name: "DISCOBLOCKS_USER_", Value: "a"}},
+			expectedError: true,
+		},
+		"duplicated": {
+			env:           []corev1.EnvVar{{Name: "DISCOBLOCKS_USER_REGION", Value: "a"}, {Name: "DISCOBLOCKS_USER_REGION", Value: "b"}},
+			expectedError: true,
+		},
+		"invalid name": {
+			env:           []corev1.EnvVar{{Name: "DISCOBLOCKS_USER_RE=GION", Value: "a"}},
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			mountJob, mountErr := RenderMountJob("pod", "pvc", "pv", "default", "node", "ext4", false, "/media/discoblocks/foo-1", []string{"container"}, "", "", "", nil, c.env, metav1.OwnerReference{})
+			resizeJob, resizeErr := RenderResizeJob("pod", "pvc", "pv", "default", "node", "ext4", "", "", "", nil, c.env, nil, nil, metav1.OwnerReference{})
+			if c.expectedError {
+				assert.NotNil(t, mountErr, "invalid mount env accepted")
+				assert.NotNil(t, resizeErr, "invalid resize env accepted")
+				return
+			}
+
+			require.Nil(t, mountErr, "invalid mount job template")
+			require.Nil(t, resizeErr, "invalid resize job template")
+
+			for _, job := range []*batchv1.Job{mountJob, resizeJob} {
+				env := job.Spec.Template.Spec.Containers[0].Env
+				for i := range c.env {
+					assert.Contains(t, env, c.env[i], "env not found")
+				}
+				assert.Contains(t, env, corev1.EnvVar{Name: "PV_NAME", Value: "pv"}, "reserved env changed")
+			}
+		})
+	}
+}
+
+func TestRenderVolumesAnnotation(t *testing.T) {
+	t.Parallel()
+
+	volumes := map[string]string{
+		"pvc-b": "/media/discoblocks/foo-1",
+		"pvc-a": "/media/discoblocks/foo-0",
+	}
+
+	annotation, err := RenderVolumesAnnotation(volumes)
+	require.Nil(t, err, "unable to render annotation")
+
+	annotated := []AnnotatedVolume{}
+	require.Nil(t, json.Unmarshal([]byte(annotation), &annotated), "invalid annotation")
+
+	assert.Equal(t, []AnnotatedVolume{
+		{PVC: "pvc-a", MountPath: "/media/discoblocks/foo-0"},
+		{PVC: "pvc-b", MountPath: "/media/discoblocks/foo-1"},
+	}, annotated, "invalid volumes")
+
+	tooMany := map[string]string{}
+	for i := 0; i < 1000; i++ {
+		tooMany[fmt.Sprintf("pvc-%d", i)] = fmt.Sprintf("/media/discoblocks/with-a-long-mount-point-to-reach-the-limit-%d", i)
+	}
+
+	_, err = RenderVolumesAnnotation(tooMany)
+	assert.NotNil(t, err, "too large annotation rendered")
+}
+
+func TestRenderVolumeMount(t *testing.T) {
+	t.Parallel()
+
+	privileged := true
+	unprivileged := false
+
+	none := corev1.MountPropagationNone
+	hostToContainer := corev1.MountPropagationHostToContainer
+	bidirectional := corev1.MountPropagationBidirectional
+	unknown := corev1.MountPropagationMode("Unknown")
+
+	cases := map[string]struct {
+		propagation   *corev1.MountPropagationMode
+		privileged    *bool
+		expectedError bool
+	}{
+		"default": {},
+		"none": {
+			propagation: &none,
+		},
+		"host to container": {
+			propagation: &hostToContainer,
+		},
+		"bidirectional": {
+			propagation: &bidirectional,
+			privileged:  &privileged,
+		},
+		"bidirectional unprivileged": {
+			propagation:   &bidirectional,
+			privileged:    &unprivileged,
+			expectedError: true,
+		},
+		"bidirectional without security context": {
+			propagation:   &bidirectional,
+			expectedError: true,
+		},
+		"unknown": {
+			propagation:   &unknown,
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			container := corev1.Container{Name: "app"}
+			if c.privileged != nil {
+				container.SecurityContext = &corev1.SecurityContext{Privileged: c.privileged}
+			}
+
+			volumeMount, err := RenderVolumeMount(&container, "pvc", "/media/discoblocks/foo-0", c.propagation)
+			if c.expectedError {
+				assert.NotNil(t, err, "invalid propagation accepted")
+				return
+			}
+
+			require.Nil(t, err, "unexpected error")
+			assert.Equal(t, "pvc", volumeMount.Name, "invalid name")
+			assert.Equal(t, "/media/discoblocks/foo-0", volumeMount.MountPath, "invalid mount path")
+			assert.Equal(t, c.propagation, volumeMount.MountPropagation, "invalid propagation")
+		})
+	}
+}
+
+func TestAppendPodVolumesAnnotations(t *testing.T) {
+	t.Parallel()
+
+	const velero = "backup.velero.io/backup-volumes"
+
+	cases := map[string]struct {
+		annotations map[string]string
+		keys        []string
+		expected    map[string]string
+	}{
+		"no keys": {},
+		"new annotation": {
+			keys:     []string{velero},
+			expected: map[string]string{velero: "disk-0,disk-1"},
+		},
+		"existing volumes": {
+			annotations: map[string]string{velero: "data, disk-0"},
+			keys:        []string{velero},
+			expected:    map[string]string{velero: "data,disk-0,disk-1"},
+		},
+		"multiple keys": {
+			keys:     []string{velero, "backup.example.com/volumes"},
+			expected: map[string]string{velero: "disk-0,disk-1", "backup.example.com/volumes": "disk-0,disk-1"},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: c.annotations,
+				},
+			}
+
+			AppendPodVolumesAnnotations(&pod, c.keys, []string{"disk-0", "disk-1"})
+
+			assert.Equal(t, c.expected, pod.Annotations, "invalid annotations")
+		})
+	}
+}
+
+func TestNewStorageClass(t *testing.T) {
+	t.Parallel()
+
+	sc := storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sc",
+			UID:  "sc-uid",
+			Annotations: map[string]string{
+				discoblocksondatiov1.DefaultStorageClassAnnotation:     "true",
+				discoblocksondatiov1.BetaDefaultStorageClassAnnotation: "true",
+				"foo": "bar",
+			},
+		},
+	}
+
+	topologySC, err := NewStorageClass(&sc, nil)
+	require.Nil(t, err, "unable to render topology StorageClass")
+
+	assert.Equal(t, map[string]string{"foo": "bar"}, topologySC.Annotations, "invalid annotations")
+	assert.Equal(t, storagev1.VolumeBindingImmediate, *topologySC.VolumeBindingMode, "invalid binding mode")
+	assert.True(t, discoblocksondatiov1.IsDefaultStorageClass(&sc), "original StorageClass changed")
+}
+
+func TestDetectPVCDrift(t *testing.T) {
+	t.Parallel()
+
+	sc := storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sc",
+			UID:  "sc-uid",
+		},
+	}
+
+	topologySC, err := NewStorageClass(&sc, []corev1.TopologySelectorTerm{
+		{
+			MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{
+				{Key: "zone", Values: []string{"a"}},
+			},
+		},
+	})
+	require.Nil(t, err, "unable to render topology StorageClass")
+
+	cases := map[string]struct {
+		scName                    string
+		capacity                  string
+		expectedStorageClassDrift bool
+		expectedCapacityDrift     bool
+	}{
+		"same": {
+			scName:   "sc",
+			capacity: "1Gi",
+		},
+		"grown": {
+			scName:   "sc",
+			capacity: "5Gi",
+		},
+		"topology StorageClass": {
+			scName:   topologySC.Name,
+			capacity: "1Gi",
+		},
+		"smaller": {
+			scName:                "sc",
+			capacity:              "500Mi",
+			expectedCapacityDrift: true,
+		},
+		"other StorageClass": {
+			scName:                    "other",
+			capacity:                  "1Gi",
+			expectedStorageClassDrift: true,
+		},
+		"all changed": {
+			scName:                    "other",
+			capacity:                  "500Mi",
+			expectedStorageClassDrift: true,
+			expectedCapacityDrift:     true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			existing := corev1.PersistentVolumeClaim{
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: &c.scName,
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceStorage: resource.MustParse(c.capacity),
+						},
+					},
+				},
+			}
+
+			desired := corev1.PersistentVolumeClaim{
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: &sc.Name,
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceStorage: resource.MustParse("1Gi"),
+						},
+					},
+				},
+			}
+
+			storageClassDrift, capacityDrift := DetectPVCDrift(&existing, &desired, &sc)
+
+			assert.Equal(t, c.expectedStorageClassDrift, storageClassDrift, "invalid StorageClass drift")
+			assert.Equal(t, c.expectedCapacityDrift, capacityDrift, "invalid capacity drift")
+		})
+	}
+}
+
+func TestIsStorageClassExpandable(t *testing.T) {
+	allow, deny := true, false
+
+	cases := map[string]struct {
+		allowVolumeExpansion *bool
+		expected             bool
+	}{
+		"not set": {
+			expected: false,
+		},
+		"not expandable": {
+			allowVolumeExpansion: &deny,
+			expected:             false,
+		},
+		"expandable": {
+			allowVolumeExpansion: &allow,
+			expected:             true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			sc := storagev1.StorageClass{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "sc",
+				},
+				Provisioner:          "ebs.csi.aws.com",
+				AllowVolumeExpansion: c.allowVolumeExpansion,
+			}
+
+			assert.Equal(t, c.expected, IsStorageClassExpandable(&sc), "invalid expandable")
+		})
+	}
 }
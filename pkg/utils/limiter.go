@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ProvisionLimiter paces creation of volumes with a token bucket, nil limiter doesn't limit
+type ProvisionLimiter struct {
+	limiter *rate.Limiter
+}
+
+// NewProvisionLimiter creates a new limiter of perMinute creations with burst, returns nil on zero rate
+func NewProvisionLimiter(perMinute, burst int32) (*ProvisionLimiter, error) {
+	if perMinute < 0 {
+		return nil, fmt.Errorf("invalid rate: %d", perMinute)
+	} else if perMinute == 0 {
+		return nil, nil
+	} else if burst < 1 {
+		return nil, fmt.Errorf("invalid burst: %d", burst)
+	}
+
+	return &ProvisionLimiter{
+		limiter: rate.NewLimiter(rate.Limit(float64(perMinute)/time.Minute.Seconds()), int(burst)),
+	}, nil
+}
+
+// Allow takes a token if available
+func (l *ProvisionLimiter) Allow() bool {
+	if l == nil {
+		return true
+	}
+
+	return l.limiter.Allow()
+}
+
+// Wait waits for a token at most maxDelay, doesn't take the token if it isn't available in time
+func (l *ProvisionLimiter) Wait(ctx context.Context, maxDelay time.Duration) error {
+	if l == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, maxDelay)
+	defer cancel()
+
+	if err := l.limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("provisioning rate exceeded: %w", err)
+	}
+
+	return nil
+}
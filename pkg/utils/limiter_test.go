@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProvisionLimiter(t *testing.T) {
+	cases := map[string]struct {
+		perMinute     int32
+		burst         int32
+		expectedNil   bool
+		expectedError bool
+	}{
+		"disabled": {
+			perMinute:   0,
+			expectedNil: true,
+		},
+		"negative rate": {
+			perMinute:     -1,
+			burst:         1,
+			expectedNil:   true,
+			expectedError: true,
+		},
+		"zero burst": {
+			perMinute:     60,
+			burst:         0,
+			expectedNil:   true,
+			expectedError: true,
+		},
+		"valid": {
+			perMinute: 60,
+			burst:     1,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			limiter, err := NewProvisionLimiter(c.perMinute, c.burst)
+
+			assert.Equal(t, c.expectedError, err != nil, "invalid error")
+			assert.Equal(t, c.expectedNil, limiter == nil, "invalid limiter")
+		})
+	}
+}
+
+func TestProvisionLimiterNil(t *testing.T) {
+	var limiter *ProvisionLimiter
+
+	assert.True(t, limiter.Allow(), "nil limiter denied")
+	assert.Nil(t, limiter.Wait(context.Background(), 0), "nil limiter waited")
+}
+
+func TestProvisionLimiterPacing(t *testing.T) {
+	limiter, err := NewProvisionLimiter(600, 2)
+	require.Nil(t, err, "unable to create limiter")
+
+	assert.True(t, limiter.Allow(), "burst denied")
+	assert.True(t, limiter.Allow(), "burst denied")
+	assert.False(t, limiter.Allow(), "exhausted bucket allowed")
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.Nil(t, limiter.Wait(context.Background(), time.Second), "unable to wait for token")
+	}
+	elapsed := time.Since(start)
+
+	assert.GreaterOrEqual(t, elapsed, 250*time.Millisecond, "creation not paced")
+	assert.Less(t, elapsed, time.Second, "creation paced too slow")
+
+	assert.NotNil(t, limiter.Wait(context.Background(), time.Millisecond), "token received over max delay")
+}
@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"github.com/ondat/discoblocks/pkg/drivers"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PlanPool decides maintenance of pre-provisioned PVCs, returns the number of PVCs to create and names of PVCs to reclaim.
+// Pooled PVCs older than TTL are reclaimed, the oldest ones above pool size are reclaimed too.
+func PlanPool(pooled []corev1.PersistentVolumeClaim, size int, ttl time.Duration, now time.Time) (int, []string) {
+	alive := []*corev1.PersistentVolumeClaim{}
+	reclaim := []string{}
+
+	for i := range pooled {
+		switch {
+		case pooled[i].DeletionTimestamp != nil:
+		case ttl > 0 && now.Sub(pooled[i].CreationTimestamp.Time) >= ttl:
+			reclaim = append(reclaim, pooled[i].Name)
+		default:
+			alive = append(alive, &pooled[i])
+		}
+	}
+
+	sort.Slice(alive, func(i, j int) bool {
+		return alive[i].CreationTimestamp.Before(&alive[j].CreationTimestamp)
+	})
+
+	for len(alive) > size {
+		reclaim = append(reclaim, alive[0].Name)
+		alive = alive[1:]
+	}
+
+	return size - len(alive), reclaim
+}
+
+// PooledPVCDecorator decorates a pre-provisioned PVC, it is owned by the DiskConfig until a Pod claims it
+func PooledPVCDecorator(config *discoblocksondatiov1.DiskConfig, prefix string, driver *drivers.Driver, pvc *corev1.PersistentVolumeClaim) {
+	PVCDecorator(config, prefix, driver, pvc)
+
+	pvc.Finalizers = nil
+
+	pvc.Labels = map[string]string{
+		PoolLabel(): config.Name,
+	}
+
+	pvc.OwnerReferences = []metav1.OwnerReference{
+		*metav1.NewControllerRef(config, discoblocksondatiov1.GroupVersion.WithKind("DiskConfig")),
+	}
+}
+
+// SelectPooledPVC chooses a pooled PVC for a Pod on the node, bound ones first then the oldest.
+// PVCs pre-bound to an other node are skipped, empty node name accepts any.
+func SelectPooledPVC(pooled []corev1.PersistentVolumeClaim, nodeName string) *corev1.PersistentVolumeClaim {
+	var selected *corev1.PersistentVolumeClaim
+
+	for i := range pooled {
+		pvc := &pooled[i]
+
+		if pvc.DeletionTimestamp != nil {
+			continue
+		}
+
+		if selectedNode := pvc.Annotations[SelectedNodeAnnotation]; nodeName != "" && selectedNode != "" && selectedNode != nodeName {
+			continue
+		}
+
+		switch {
+		case selected == nil:
+			selected = pvc
+		case (pvc.Status.Phase == corev1.ClaimBound) != (selected.Status.Phase == corev1.ClaimBound):
+			if pvc.Status.Phase == corev1.ClaimBound {
+				selected = pvc
+			}
+		case pvc.CreationTimestamp.Before(&selected.CreationTimestamp):
+			selected = pvc
+		}
+	}
+
+	return selected
+}
+
+// ClaimPooledPVC hands over the pooled PVC to the DiskConfig and to the owners of the Pod
+func ClaimPooledPVC(config *discoblocksondatiov1.DiskConfig, ownerLabels map[string]string, pvc *corev1.PersistentVolumeClaim) {
+	delete(pvc.Labels, PoolLabel())
+
+	if pvc.Labels == nil {
+		pvc.Labels = map[string]string{}
+	}
+	pvc.Labels[ConfigLabel()] = config.Name
+
+	for k, v := range ownerLabels {
+		pvc.Labels[k] = v
+	}
+
+	pvc.Finalizers = append(pvc.Finalizers, RenderFinalizer(config.Name))
+
+	ownerRefs := []metav1.OwnerReference{}
+	for i := range pvc.OwnerReferences {
+		if pvc.OwnerReferences[i].UID != config.UID {
+			ownerRefs = append(ownerRefs, pvc.OwnerReferences[i])
+		}
+	}
+	pvc.OwnerReferences = ownerRefs
+}
+
+// ClaimFromPool claims a pooled PVC of the DiskConfig for a Pod on the node, returns nil if the pool is empty.
+// Update conflicts mean a concurrent admission claimed the PVC first, so the next one is tried.
+func ClaimFromPool(ctx context.Context, kubeClient client.Client, config *discoblocksondatiov1.DiskConfig, ownerLabels map[string]string, nodeName string) (*corev1.PersistentVolumeClaim, error) {
+	pvcs := corev1.PersistentVolumeClaimList{}
+	if err := kubeClient.List(ctx, &pvcs, client.InNamespace(config.Namespace), client.MatchingLabels{PoolLabel(): config.Name}); err != nil {
+		return nil, fmt.Errorf("unable to list pooled PVCs: %w", err)
+	}
+
+	candidates := pvcs.Items
+
+	for {
+		pvc := SelectPooledPVC(candidates, nodeName)
+		if pvc == nil {
+			return nil, nil
+		}
+
+		claimed := pvc.DeepCopy()
+		ClaimPooledPVC(config, ownerLabels, claimed)
+
+		err := kubeClient.Update(ctx, claimed)
+		if err == nil {
+			return claimed, nil
+		} else if !apierrors.IsConflict(err) && !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("unable to update pooled PVC %s: %w", pvc.Name, err)
+		}
+
+		remaining := []corev1.PersistentVolumeClaim{}
+		for i := range candidates {
+			if candidates[i].Name != pvc.Name {
+				remaining = append(remaining, candidates[i])
+			}
+		}
+		candidates = remaining
+	}
+}
+
+// PlanEagerPVCs returns running Pods of the DiskConfig by name prefixes of the PVCs the admission webhook would attach to them.
+// ReadWriteSame Pods share a single PVC, ReadWriteDaemon Pods share a PVC per node, ReadWriteOnce PVCs are unique per admission.
+func PlanEagerPVCs(config *discoblocksondatiov1.DiskConfig, pods []corev1.Pod) map[string]*corev1.Pod {
+	plan := map[string]*corev1.Pod{}
+
+	if config.Spec.ProvisionMode != discoblocksondatiov1.ProvisionModeEager {
+		return plan
+	}
+
+	for i := range pods {
+		pod := &pods[i]
+
+		if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning || !IsPodSelected(pod.Labels, config.Spec.PodSelector) {
+			continue
+		}
+
+		prefix := ""
+		switch config.Spec.AvailabilityMode {
+		case discoblocksondatiov1.ReadWriteSame:
+			prefix = GetNamePrefix(config.Spec.AvailabilityMode, string(config.UID), "")
+		case discoblocksondatiov1.ReadWriteDaemon:
+			if pod.Spec.NodeName == "" {
+				continue
+			}
+			prefix = GetNamePrefix(config.Spec.AvailabilityMode, string(config.UID), pod.Spec.NodeName)
+		default:
+			return plan
+		}
+
+		if _, ok := plan[prefix]; !ok {
+			plan[prefix] = pod
+		}
+	}
+
+	return plan
+}
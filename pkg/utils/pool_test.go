@@ -0,0 +1,385 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newPooledPVC(name string, age time.Duration, phase corev1.PersistentVolumeClaimPhase, node string) corev1.PersistentVolumeClaim {
+	pvc := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age).Truncate(time.Second)),
+			Labels: map[string]string{
+				PoolLabel(): "config",
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "discoblocks.ondat.io/v1",
+					Kind:       "DiskConfig",
+					Name:       "config",
+					UID:        "config-uid",
+				},
+			},
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase: phase,
+		},
+	}
+
+	if node != "" {
+		SetSelectedNode(&pvc, node)
+	}
+
+	return pvc
+}
+
+func TestPlanPool(t *testing.T) {
+	t.Parallel()
+
+	deleted := newPooledPVC("deleted", time.Minute, corev1.ClaimBound, "")
+	deleted.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+	cases := map[string]struct {
+		pooled          []corev1.PersistentVolumeClaim
+		size            int
+		ttl             time.Duration
+		expectedCreate  int
+		expectedReclaim []string
+	}{
+		"empty pool": {
+			size:            3,
+			ttl:             time.Hour,
+			expectedCreate:  3,
+			expectedReclaim: []string{},
+		},
+		"full pool": {
+			pooled: []corev1.PersistentVolumeClaim{
+				newPooledPVC("a", time.Minute, corev1.ClaimBound, ""),
+				newPooledPVC("b", time.Minute, corev1.ClaimPending, ""),
+			},
+			size:            2,
+			ttl:             time.Hour,
+			expectedReclaim: []string{},
+		},
+		"expired": {
+			pooled: []corev1.PersistentVolumeClaim{
+				newPooledPVC("a", 2*time.Hour, corev1.ClaimBound, ""),
+				newPooledPVC("b", time.Minute, corev1.ClaimBound, ""),
+			},
+			size:            2,
+			ttl:             time.Hour,
+			expectedCreate:  1,
+			expectedReclaim: []string{"a"},
+		},
+		"no TTL": {
+			pooled: []corev1.PersistentVolumeClaim{
+				newPooledPVC("a", 2*time.Hour, corev1.ClaimBound, ""),
+			},
+			size:            1,
+			expectedReclaim: []string{},
+		},
+		"shrunk": {
+			pooled: []corev1.PersistentVolumeClaim{
+				newPooledPVC("new", time.Minute, corev1.ClaimBound, ""),
+				newPooledPVC("old", 3*time.Minute, corev1.ClaimBound, ""),
+				newPooledPVC("middle", 2*time.Minute, corev1.ClaimBound, ""),
+			},
+			size:            1,
+			ttl:             time.Hour,
+			expectedReclaim: []string{"old", "middle"},
+		},
+		"disabled": {
+			pooled: []corev1.PersistentVolumeClaim{
+				newPooledPVC("a", time.Minute, corev1.ClaimBound, ""),
+			},
+			ttl:             time.Hour,
+			expectedReclaim: []string{"a"},
+		},
+		"deleted ignored": {
+			pooled: []corev1.PersistentVolumeClaim{
+				deleted,
+			},
+			size:            1,
+			ttl:             time.Hour,
+			expectedCreate:  1,
+			expectedReclaim: []string{},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			create, reclaim := PlanPool(c.pooled, c.size, c.ttl, time.Now())
+
+			assert.Equal(t, c.expectedCreate, create, "invalid number of PVCs to create")
+			assert.Equal(t, c.expectedReclaim, reclaim, "invalid PVCs to reclaim")
+		})
+	}
+}
+
+func TestSelectPooledPVC(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		pooled       []corev1.PersistentVolumeClaim
+		nodeName     string
+		expectedName string
+	}{
+		"empty pool": {},
+		"bound first": {
+			pooled: []corev1.PersistentVolumeClaim{
+				newPooledPVC("pending", 2*time.Minute, corev1.ClaimPending, ""),
+				newPooledPVC("bound", time.Minute, corev1.ClaimBound, ""),
+			},
+			expectedName: "bound",
+		},
+		"oldest first": {
+			pooled: []corev1.PersistentVolumeClaim{
+				newPooledPVC("new", time.Minute, corev1.ClaimBound, ""),
+				newPooledPVC("old", 2*time.Minute, corev1.ClaimBound, ""),
+			},
+			expectedName: "old",
+		},
+		"node of Pod": {
+			pooled: []corev1.PersistentVolumeClaim{
+				newPooledPVC("other", 2*time.Minute, corev1.ClaimBound, "node-2"),
+				newPooledPVC("same", time.Minute, corev1.ClaimBound, "node-1"),
+			},
+			nodeName:     "node-1",
+			expectedName: "same",
+		},
+		"other node only": {
+			pooled: []corev1.PersistentVolumeClaim{
+				newPooledPVC("other", time.Minute, corev1.ClaimBound, "node-2"),
+			},
+			nodeName: "node-1",
+		},
+		"unscheduled Pod": {
+			pooled: []corev1.PersistentVolumeClaim{
+				newPooledPVC("other", time.Minute, corev1.ClaimBound, "node-2"),
+			},
+			expectedName: "other",
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			selected := SelectPooledPVC(c.pooled, c.nodeName)
+			if c.expectedName == "" {
+				assert.Nil(t, selected, "unexpected PVC selected")
+				return
+			}
+
+			require.NotNil(t, selected, "PVC not selected")
+			assert.Equal(t, c.expectedName, selected.Name, "invalid PVC selected")
+		})
+	}
+}
+
+// conflictingClient fails the first updates with conflict like a concurrent claim
+type conflictingClient struct {
+	client.Client
+	conflicts int
+}
+
+func (c *conflictingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if c.conflicts > 0 {
+		c.conflicts--
+		return apierrors.NewConflict(schema.GroupResource{Resource: "persistentvolumeclaims"}, obj.GetName(), nil)
+	}
+
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func TestClaimFromPool(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		pooled       []corev1.PersistentVolumeClaim
+		conflicts    int
+		expectedName string
+	}{
+		"empty pool": {},
+		"claimed": {
+			pooled: []corev1.PersistentVolumeClaim{
+				newPooledPVC("a", time.Minute, corev1.ClaimBound, ""),
+			},
+			expectedName: "a",
+		},
+		"concurrent claim": {
+			pooled: []corev1.PersistentVolumeClaim{
+				newPooledPVC("a", 2*time.Minute, corev1.ClaimBound, ""),
+				newPooledPVC("b", time.Minute, corev1.ClaimBound, ""),
+			},
+			conflicts:    1,
+			expectedName: "b",
+		},
+		"all claimed concurrently": {
+			pooled: []corev1.PersistentVolumeClaim{
+				newPooledPVC("a", time.Minute, corev1.ClaimBound, ""),
+			},
+			conflicts: 1,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			objects := []client.Object{}
+			for i := range c.pooled {
+				objects = append(objects, &c.pooled[i])
+			}
+
+			kubeClient := &conflictingClient{
+				Client:    fake.NewClientBuilder().WithObjects(objects...).Build(),
+				conflicts: c.conflicts,
+			}
+
+			config := discoblocksondatiov1.DiskConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "config",
+					Namespace: "default",
+					UID:       "config-uid",
+				},
+			}
+
+			pvc, err := ClaimFromPool(context.Background(), kubeClient, &config, map[string]string{"owner": "pod"}, "")
+			require.Nil(t, err, "unexpected error")
+
+			if c.expectedName == "" {
+				assert.Nil(t, pvc, "unexpected PVC claimed")
+				return
+			}
+
+			require.NotNil(t, pvc, "PVC not claimed")
+			assert.Equal(t, c.expectedName, pvc.Name, "invalid PVC claimed")
+
+			claimed := corev1.PersistentVolumeClaim{}
+			require.Nil(t, kubeClient.Get(context.Background(), client.ObjectKeyFromObject(pvc), &claimed), "unable to fetch claimed PVC")
+
+			assert.Equal(t, map[string]string{ConfigLabel(): "config", "owner": "pod"}, claimed.Labels, "invalid labels")
+			assert.Equal(t, []string{RenderFinalizer("config")}, claimed.Finalizers, "invalid finalizers")
+			assert.Empty(t, claimed.OwnerReferences, "owner reference of DiskConfig not removed")
+		})
+	}
+}
+
+func TestPlanEagerPVCs(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(name, nodeName string, phase corev1.PodPhase, podLabels map[string]string) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: podLabels,
+			},
+			Spec: corev1.PodSpec{
+				NodeName: nodeName,
+			},
+			Status: corev1.PodStatus{
+				Phase: phase,
+			},
+		}
+	}
+
+	selected := map[string]string{"app": "nginx"}
+
+	terminating := newPod("terminating", "node-c", corev1.PodRunning, selected)
+	terminating.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+	pods := []corev1.Pod{
+		newPod("a-1", "node-a", corev1.PodRunning, selected),
+		newPod("a-2", "node-a", corev1.PodRunning, selected),
+		newPod("b", "node-b", corev1.PodRunning, selected),
+		newPod("pending", "node-d", corev1.PodPending, selected),
+		newPod("other", "node-e", corev1.PodRunning, map[string]string{"app": "redis"}),
+		terminating,
+	}
+
+	cases := map[string]struct {
+		provisionMode    discoblocksondatiov1.ProvisionMode
+		availabilityMode discoblocksondatiov1.AvailabilityMode
+		pods             []corev1.Pod
+		expectedPrefixes []string
+	}{
+		"lazy": {
+			provisionMode:    discoblocksondatiov1.ProvisionModeLazy,
+			availabilityMode: discoblocksondatiov1.ReadWriteSame,
+			pods:             pods,
+			expectedPrefixes: []string{},
+		},
+		"ReadWriteSame": {
+			provisionMode:    discoblocksondatiov1.ProvisionModeEager,
+			availabilityMode: discoblocksondatiov1.ReadWriteSame,
+			pods:             pods,
+			expectedPrefixes: []string{"uid"},
+		},
+		"ReadWriteSame without Pods": {
+			provisionMode:    discoblocksondatiov1.ProvisionModeEager,
+			availabilityMode: discoblocksondatiov1.ReadWriteSame,
+			expectedPrefixes: []string{},
+		},
+		"ReadWriteDaemon": {
+			provisionMode:    discoblocksondatiov1.ProvisionModeEager,
+			availabilityMode: discoblocksondatiov1.ReadWriteDaemon,
+			pods:             pods,
+			expectedPrefixes: []string{"node-a", "node-b"},
+		},
+		"ReadWriteOnce": {
+			provisionMode:    discoblocksondatiov1.ProvisionModeEager,
+			availabilityMode: discoblocksondatiov1.ReadWriteOnce,
+			pods:             pods,
+			expectedPrefixes: []string{},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			config := discoblocksondatiov1.DiskConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "config",
+					UID:  "uid",
+				},
+				Spec: discoblocksondatiov1.DiskConfigSpec{
+					ProvisionMode:    c.provisionMode,
+					AvailabilityMode: c.availabilityMode,
+					PodSelector:      selected,
+				},
+			}
+
+			plan := PlanEagerPVCs(&config, c.pods)
+
+			prefixes := []string{}
+			for prefix, pod := range plan {
+				prefixes = append(prefixes, prefix)
+
+				assert.True(t, IsPodSelected(pod.Labels, selected), "invalid Pod planned")
+			}
+
+			assert.ElementsMatch(t, c.expectedPrefixes, prefixes, "invalid eager PVCs")
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultFillRateSamples is the number of availability samples kept per disk
+const DefaultFillRateSamples = 10
+
+// minFillRateSamples is the minimum number of samples of a fill rate
+const minFillRateSamples = 3
+
+type fillSample struct {
+	at        time.Time
+	size      float64
+	available float64
+}
+
+// fillRing is a fixed size ring buffer of samples, oldest sample is overwritten first
+type fillRing struct {
+	samples []fillSample
+	next    int
+	count   int
+}
+
+func (r *fillRing) add(sample fillSample) {
+	r.samples[r.next] = sample
+	r.next = (r.next + 1) % len(r.samples)
+
+	if r.count < len(r.samples) {
+		r.count++
+	}
+}
+
+func (r *fillRing) last() fillSample {
+	return r.samples[(r.next-1+len(r.samples))%len(r.samples)]
+}
+
+// fillRateRetention is the age of the last sample after which a disk is evicted on overflow,
+// disks of deleted PVCs are never observed again
+const fillRateRetention = 24 * time.Hour
+
+// FillRatePredictor tracks recent availability of disks in memory and predicts exhaustion by linear fill rate
+type FillRatePredictor struct {
+	lock     sync.Mutex
+	size     int
+	maxDisks int
+	rings    map[string]*fillRing
+}
+
+// Observe records size and available space of the disk at the time.
+// Changed size means resize, samples of the old size are dropped.
+func (p *FillRatePredictor) Observe(key string, at time.Time, size, available float64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	ring, ok := p.rings[key]
+	if ok && ring.count != 0 {
+		last := ring.last()
+		if !at.After(last.at) {
+			// Metrics were not refreshed since the last sample
+			return
+		}
+
+		ok = last.size == size
+	}
+
+	if !ok {
+		if _, exists := p.rings[key]; !exists && len(p.rings) >= p.maxDisks {
+			p.evict(at)
+		}
+
+		ring = &fillRing{samples: make([]fillSample, p.size)}
+		p.rings[key] = ring
+	}
+
+	ring.add(fillSample{at: at, size: size, available: available})
+}
+
+// evict removes disks not observed within the retention, or the least recently observed one if all of them are recent
+func (p *FillRatePredictor) evict(now time.Time) {
+	oldestKey := ""
+	var oldest time.Time
+	for key, ring := range p.rings {
+		if ring.count == 0 || now.Sub(ring.last().at) > fillRateRetention {
+			delete(p.rings, key)
+			continue
+		}
+
+		if oldestKey == "" || ring.last().at.Before(oldest) {
+			oldestKey = key
+			oldest = ring.last().at
+		}
+	}
+
+	if len(p.rings) >= p.maxDisks {
+		delete(p.rings, oldestKey)
+	}
+}
+
+// TimeToFull predicts remaining time of the disk until it fills, by least squares fill rate of the samples.
+// False means not enough samples or the disk isn't filling.
+func (p *FillRatePredictor) TimeToFull(key string) (time.Duration, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	ring, ok := p.rings[key]
+	if !ok || ring.count < minFillRateSamples {
+		return 0, false
+	}
+
+	origin := ring.last().at
+
+	var sumX, sumY, sumXY, sumXX float64
+	for i := 0; i < ring.count; i++ {
+		sample := ring.samples[i]
+		x := sample.at.Sub(origin).Seconds()
+
+		sumX += x
+		sumY += sample.available
+		sumXY += x * sample.available
+		sumXX += x * x
+	}
+
+	n := float64(ring.count)
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, false
+	}
+
+	// Change of available space per second, negative while the disk fills
+	rate := (n*sumXY - sumX*sumY) / denominator
+	if rate >= 0 {
+		return 0, false
+	}
+
+	return time.Duration(ring.last().available / -rate * float64(time.Second)), true
+}
+
+// FillRateSample is an availability sample of a disk in persisted history
+type FillRateSample struct {
+	Time      time.Time `json:"time"`
+	Size      float64   `json:"size"`
+	Available float64   `json:"available"`
+}
+
+// Snapshot returns samples of disks oldest first, only the given number of most recently observed disks are kept
+func (p *FillRatePredictor) Snapshot(maxDisks int) map[string][]FillRateSample {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	keys := make([]string, 0, len(p.rings))
+	for key, ring := range p.rings {
+		if ring.count != 0 {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return p.rings[keys[i]].last().at.After(p.rings[keys[j]].last().at)
+	})
+
+	if len(keys) > maxDisks {
+		keys = keys[:maxDisks]
+	}
+
+	history := make(map[string][]FillRateSample, len(keys))
+	for _, key := range keys {
+		ring := p.rings[key]
+
+		samples := make([]FillRateSample, 0, ring.count)
+		for i := ring.count; i > 0; i-- {
+			sample := ring.samples[(ring.next-i+len(ring.samples))%len(ring.samples)]
+			samples = append(samples, FillRateSample{Time: sample.at, Size: sample.size, Available: sample.available})
+		}
+
+		history[key] = samples
+	}
+
+	return history
+}
+
+// Restore loads samples of the history, samples beyond the capacity of the predictor are dropped oldest first
+func (p *FillRatePredictor) Restore(history map[string][]FillRateSample) {
+	for key, samples := range history {
+		for _, sample := range samples {
+			p.Observe(key, sample.Time, sample.Size, sample.Available)
+		}
+	}
+}
+
+// NewFillRatePredictor creates a new predictor keeping the given number of samples per disk
+func NewFillRatePredictor(samples int) *FillRatePredictor {
+	if samples < minFillRateSamples {
+		samples = minFillRateSamples
+	}
+
+	return &FillRatePredictor{
+		size:     samples,
+		maxDisks: math.MaxUint16,
+		rings:    map[string]*fillRing{},
+	}
+}
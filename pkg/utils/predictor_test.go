@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFillRatePredictor(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	type sample struct {
+		offset    time.Duration
+		size      float64
+		available float64
+	}
+
+	cases := map[string]struct {
+		samples            []sample
+		expectedOK         bool
+		expectedTimeToFull time.Duration
+	}{
+		"no samples": {},
+		"too few samples": {
+			samples: []sample{
+				{0, 1000, 500},
+				{time.Minute, 1000, 400},
+			},
+		},
+		"linear fill": {
+			samples: []sample{
+				{0, 1000, 500},
+				{time.Minute, 1000, 400},
+				{2 * time.Minute, 1000, 300},
+			},
+			expectedOK:         true,
+			expectedTimeToFull: 3 * time.Minute,
+		},
+		"repeated sample ignored": {
+			samples: []sample{
+				{0, 1000, 500},
+				{time.Minute, 1000, 400},
+				{time.Minute, 1000, 400},
+			},
+		},
+		"freeing up": {
+			samples: []sample{
+				{0, 1000, 300},
+				{time.Minute, 1000, 400},
+				{2 * time.Minute, 1000, 500},
+			},
+		},
+		"flat": {
+			samples: []sample{
+				{0, 1000, 500},
+				{time.Minute, 1000, 500},
+				{2 * time.Minute, 1000, 500},
+			},
+		},
+		"resize starts over": {
+			samples: []sample{
+				{0, 1000, 500},
+				{time.Minute, 1000, 400},
+				{2 * time.Minute, 1000, 300},
+				{3 * time.Minute, 2000, 1200},
+			},
+		},
+		"steady growth": {
+			samples: []sample{
+				{0, 10000, 9000},
+				{5 * time.Minute, 10000, 8800},
+				{10 * time.Minute, 10000, 8600},
+				{15 * time.Minute, 10000, 8400},
+			},
+			expectedOK:         true,
+			expectedTimeToFull: 210 * time.Minute,
+		},
+		"oldest samples overwritten": {
+			samples: []sample{
+				{0, 1000, 900},
+				{time.Minute, 1000, 500},
+				{2 * time.Minute, 1000, 490},
+				{3 * time.Minute, 1000, 480},
+				{4 * time.Minute, 1000, 470},
+			},
+			expectedOK:         true,
+			expectedTimeToFull: 47 * time.Minute,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			predictor := NewFillRatePredictor(4)
+
+			for _, s := range c.samples {
+				predictor.Observe("pvc", start.Add(s.offset), s.size, s.available)
+			}
+
+			timeToFull, ok := predictor.TimeToFull("pvc")
+
+			assert.Equal(t, c.expectedOK, ok, "invalid prediction")
+			assert.Equal(t, c.expectedTimeToFull, timeToFull.Round(time.Second), "invalid time to full")
+		})
+	}
+}
+
+func TestFillRatePredictorEviction(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		observed     map[string]time.Duration
+		now          time.Duration
+		expectedKeys []string
+	}{
+		"least recently observed": {
+			observed:     map[string]time.Duration{"old": 0, "recent": time.Hour},
+			now:          2 * time.Hour,
+			expectedKeys: []string{"new", "recent"},
+		},
+		"beyond retention": {
+			observed:     map[string]time.Duration{"old": 0, "older": -time.Hour},
+			now:          fillRateRetention + time.Hour,
+			expectedKeys: []string{"new"},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			predictor := NewFillRatePredictor(DefaultFillRateSamples)
+			predictor.maxDisks = 2
+
+			for key, offset := range c.observed {
+				predictor.Observe(key, start.Add(offset), 1000, 500)
+			}
+
+			// Known disk doesn't evict
+			predictor.Observe("old", start.Add(time.Minute), 1000, 500)
+			assert.Len(t, predictor.rings, 2, "disk evicted by known disk")
+
+			predictor.Observe("new", start.Add(c.now), 1000, 500)
+
+			keys := []string{}
+			for key := range predictor.rings {
+				keys = append(keys, key)
+			}
+
+			assert.ElementsMatch(t, c.expectedKeys, keys, "invalid disks")
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultRecommenderTimeout bounds a call of the capacity recommender
+const DefaultRecommenderTimeout = 2 * time.Second
+
+// RecommendationRequest describes usage and history of a disk for the capacity recommender
+type RecommendationRequest struct {
+	ConfigName            string      `json:"configName"`
+	Namespace             string      `json:"namespace"`
+	PodName               string      `json:"podName"`
+	PVCName               string      `json:"pvcName"`
+	Index                 int         `json:"index"`
+	NumberOfDisks         int         `json:"numberOfDisks"`
+	Capacity              string      `json:"capacity"`
+	MaximumCapacityOfDisk string      `json:"maximumCapacityOfDisk"`
+	SizeBytes             float64     `json:"sizeBytes"`
+	UsedBytes             float64     `json:"usedBytes"`
+	AvailableBytes        float64     `json:"availableBytes"`
+	UsedPercentage        float64     `json:"usedPercentage"`
+	LastResizeTime        metav1.Time `json:"lastResizeTime,omitempty"`
+	LastResizeSucceeded   bool        `json:"lastResizeSucceeded,omitempty"`
+}
+
+// Recommendation is the answer of the capacity recommender, empty target capacity means no change
+type Recommendation struct {
+	TargetCapacity string `json:"targetCapacity,omitempty"`
+}
+
+// CapacityRecommender main interface of external capacity recommenders
+type CapacityRecommender interface {
+	Recommend(ctx context.Context, request *RecommendationRequest) (resource.Quantity, error)
+}
+
+// httpCapacityRecommender posts requests to a webhook as JSON
+type httpCapacityRecommender struct {
+	URL    string
+	Client *http.Client
+}
+
+// Recommend calls the webhook and parses target capacity of the answer
+func (cr *httpCapacityRecommender) Recommend(ctx context.Context, request *RecommendationRequest) (resource.Quantity, error) {
+	content, err := json.Marshal(request)
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("unable to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cr.URL, bytes.NewReader(content))
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("unable to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := cr.Client.Do(req)
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("unable to call recommender: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resource.Quantity{}, fmt.Errorf("unexpected status code of recommender: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("unable to read answer: %w", err)
+	}
+
+	recommendation := Recommendation{}
+	if err := json.Unmarshal(body, &recommendation); err != nil {
+		return resource.Quantity{}, fmt.Errorf("unable to unmarshal answer: %w", err)
+	}
+
+	if recommendation.TargetCapacity == "" {
+		return resource.Quantity{}, nil
+	}
+
+	target, err := resource.ParseQuantity(recommendation.TargetCapacity)
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("invalid target capacity %s: %w", recommendation.TargetCapacity, err)
+	}
+
+	return target, nil
+}
+
+// NewCapacityRecommender creates a new webhook recommender, empty URL disables it and the built-in logic decides.
+// Calls are bounded by the context of the caller.
+func NewCapacityRecommender(url string) CapacityRecommender {
+	if url == "" {
+		return nil
+	}
+
+	return &httpCapacityRecommender{
+		URL:    url,
+		Client: &http.Client{},
+	}
+}
+
+// DecideRecommendedResize decides scale action of a disk by the target capacity of the recommender.
+// Target is raised to minimum capacity of disk, then smaller or equal target than the actual capacity means no change,
+// target above maximum capacity of disk needs a new disk.
+func DecideRecommendedResize(target, actual resource.Quantity, policy *discoblocksondatiov1.Policy) (resource.Quantity, ResizeAction) {
+	target = ClampMinimumCapacity(target, policy)
+
+	if target.Cmp(actual) <= 0 {
+		return resource.Quantity{}, ResizeActionNone
+	}
+
+	if target.Cmp(policy.MaximumCapacityOfDisk) == 1 {
+		return resource.Quantity{}, ResizeActionNewDisk
+	}
+
+	return target.DeepCopy(), ResizeActionGrow
+}
@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	discoblocksondatiov1 "github.com/ondat/discoblocks/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestRecommend(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		status         int
+		body           string
+		expectedTarget string
+		expectedError  bool
+	}{
+		"target": {
+			status:         http.StatusOK,
+			body:           `{"targetCapacity":"20Gi"}`,
+			expectedTarget: "20Gi",
+		},
+		"no change": {
+			status:         http.StatusOK,
+			body:           `{}`,
+			expectedTarget: "0",
+		},
+		"invalid target": {
+			status:        http.StatusOK,
+			body:          `{"targetCapacity":"huge"}`,
+			expectedError: true,
+		},
+		"malformed body": {
+			status:        http.StatusOK,
+			body:          `{"targetCapacity":`,
+			expectedError: true,
+		},
+		"server error": {
+			status:        http.StatusInternalServerError,
+			body:          `{"targetCapacity":"20Gi"}`,
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				request := RecommendationRequest{}
+				if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.PVCName != "pvc" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				w.WriteHeader(c.status)
+				_, _ = w.Write([]byte(c.body))
+			}))
+			defer srv.Close()
+
+			recommender := NewCapacityRecommender(srv.URL)
+			require.NotNil(t, recommender, "recommender not created")
+
+			target, err := recommender.Recommend(context.Background(), &RecommendationRequest{PVCName: "pvc", Capacity: "10Gi"})
+			if c.expectedError {
+				assert.NotNil(t, err, "invalid answer accepted")
+				return
+			}
+
+			require.Nil(t, err, "valid answer rejected")
+			assert.Equal(t, c.expectedTarget, target.String(), "invalid target capacity")
+		})
+	}
+
+	assert.Nil(t, NewCapacityRecommender(""), "recommender created without URL")
+}
+
+func TestDecideRecommendedResize(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		target           string
+		actual           string
+		min              string
+		expectedCapacity string
+		expectedAction   ResizeAction
+	}{
+		"no recommendation": {
+			target:           "0",
+			actual:           "10Gi",
+			expectedCapacity: "0",
+			expectedAction:   ResizeActionNone,
+		},
+		"smaller": {
+			target:           "5Gi",
+			actual:           "10Gi",
+			expectedCapacity: "0",
+			expectedAction:   ResizeActionNone,
+		},
+		"same": {
+			target:           "10Gi",
+			actual:           "10Gi",
+			expectedCapacity: "0",
+			expectedAction:   ResizeActionNone,
+		},
+		"grow": {
+			target:           "25Gi",
+			actual:           "10Gi",
+			expectedCapacity: "25Gi",
+			expectedAction:   ResizeActionGrow,
+		},
+		"grow to max": {
+			target:           "100Gi",
+			actual:           "10Gi",
+			expectedCapacity: "100Gi",
+			expectedAction:   ResizeActionGrow,
+		},
+		"downscale kept above floor": {
+			target:           "2Gi",
+			actual:           "10Gi",
+			min:              "5Gi",
+			expectedCapacity: "0",
+			expectedAction:   ResizeActionNone,
+		},
+		"downscale raised to floor": {
+			target:           "1Gi",
+			actual:           "2Gi",
+			min:              "5Gi",
+			expectedCapacity: "5Gi",
+			expectedAction:   ResizeActionGrow,
+		},
+		"growth from small to floor": {
+			target:           "3Gi",
+			actual:           "2Gi",
+			min:              "5Gi",
+			expectedCapacity: "5Gi",
+			expectedAction:   ResizeActionGrow,
+		},
+		"above max": {
+			target:           "200Gi",
+			actual:           "10Gi",
+			expectedCapacity: "0",
+			expectedAction:   ResizeActionNewDisk,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			policy := discoblocksondatiov1.Policy{
+				MaximumCapacityOfDisk: resource.MustParse("100Gi"),
+			}
+			if c.min != "" {
+				policy.MinimumCapacityOfDisk = resource.MustParse(c.min)
+			}
+
+			newCapacity, action := DecideRecommendedResize(resource.MustParse(c.target), resource.MustParse(c.actual), &policy)
+
+			assert.Equal(t, c.expectedAction, action, "invalid action")
+			assert.Equal(t, c.expectedCapacity, newCapacity.String(), "invalid new capacity")
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetWithRetry fetches the object, not found errors are retried until the budget or context expires
+func GetWithRetry(ctx context.Context, kubeClient client.Client, key client.ObjectKey, obj client.Object, budget, interval time.Duration) error {
+	deadline := time.Now().Add(budget)
+
+	for {
+		err := kubeClient.Get(ctx, key, obj)
+		if err == nil || !apierrors.IsNotFound(err) || time.Now().Add(interval).After(deadline) {
+			return err
+		}
+
+		timer := time.NewTimer(interval)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
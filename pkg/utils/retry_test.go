@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// lateClient creates the object after the given number of get calls
+type lateClient struct {
+	client.Client
+	obj     client.Object
+	after   int32
+	attempt int32
+}
+
+func (c *lateClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if atomic.AddInt32(&c.attempt, 1) == c.after {
+		if err := c.Client.Create(ctx, c.obj); err != nil {
+			return err
+		}
+	}
+
+	return c.Client.Get(ctx, key, obj)
+}
+
+func TestGetWithRetry(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		after            int32
+		budget           time.Duration
+		expectedAttempts int32
+		expectedNotFound bool
+	}{
+		"exists": {
+			after:            1,
+			budget:           time.Second,
+			expectedAttempts: 1,
+		},
+		"appears on second attempt": {
+			after:            2,
+			budget:           time.Second,
+			expectedAttempts: 2,
+		},
+		"no retry budget": {
+			after:            2,
+			expectedAttempts: 1,
+			expectedNotFound: true,
+		},
+		"never appears": {
+			budget:           50 * time.Millisecond,
+			expectedNotFound: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			kubeClient := &lateClient{
+				Client: fake.NewClientBuilder().Build(),
+				obj:    &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "sc"}},
+				after:  c.after,
+			}
+
+			sc := storagev1.StorageClass{}
+			err := GetWithRetry(context.Background(), kubeClient, client.ObjectKey{Name: "sc"}, &sc, c.budget, 10*time.Millisecond)
+
+			if c.expectedNotFound {
+				assert.True(t, apierrors.IsNotFound(err), "not found error missing")
+			} else {
+				require.Nil(t, err, "unexpected error")
+				assert.Equal(t, "sc", sc.Name, "invalid object")
+			}
+
+			if c.expectedAttempts != 0 {
+				assert.Equal(t, c.expectedAttempts, atomic.LoadInt32(&kubeClient.attempt), "invalid number of attempts")
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"math"
+	"sync"
+)
+
+// StateSampler reports state changes and every n-th occurrence of the same state
+type StateSampler func(key, state string) bool
+
+type sampledState struct {
+	state string
+	count uint
+}
+
+// CreateStateSampler creates a new sampler to rate limit recurring conditions
+func CreateStateSampler(every uint) StateSampler {
+	lock := sync.Mutex{}
+	states := map[string]*sampledState{}
+
+	return func(key, state string) bool {
+		lock.Lock()
+		defer lock.Unlock()
+
+		last, ok := states[key]
+		if !ok || last.state != state {
+			// Keys of deleted objects are never removed, start over on overflow
+			if len(states) >= math.MaxUint16 {
+				states = map[string]*sampledState{}
+			}
+
+			states[key] = &sampledState{state: state, count: 1}
+
+			return true
+		}
+
+		last.count++
+
+		return every != 0 && (last.count-1)%every == 0
+	}
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateStateSampler(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		every    uint
+		states   []string
+		expected []bool
+	}{
+		"repeated ok": {
+			every:    3,
+			states:   []string{"ok", "ok", "ok", "ok", "ok", "ok", "ok"},
+			expected: []bool{true, false, false, true, false, false, true},
+		},
+		"state change": {
+			every:    3,
+			states:   []string{"ok", "ok", "resize", "ok", "ok"},
+			expected: []bool{true, false, true, true, false},
+		},
+		"every pass": {
+			every:    1,
+			states:   []string{"ok", "ok", "ok"},
+			expected: []bool{true, true, true},
+		},
+		"only changes": {
+			every:    0,
+			states:   []string{"ok", "ok", "ok", "ok"},
+			expected: []bool{true, false, false, false},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			sample := CreateStateSampler(c.every)
+
+			actual := []bool{}
+			for _, s := range c.states {
+				actual = append(actual, sample("default/pvc", s))
+			}
+
+			assert.Equal(t, c.expected, actual, "invalid sampling")
+			assert.True(t, sample("default/other", "ok"), "keys are not independent")
+		})
+	}
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 )
 
@@ -51,3 +52,48 @@ LOCK:
 		unlock()
 	}, nil
 }
+
+// KeyedLock serializes operations on the same key, operations on different keys run in parallel
+type KeyedLock struct {
+	lock sync.Mutex
+	keys map[string]*keyedLockEntry
+}
+
+type keyedLockEntry struct {
+	sync.Mutex
+	refs int
+}
+
+// Lock waits for the lock of the key and returns its unlock function
+func (l *KeyedLock) Lock(key string) func() {
+	l.lock.Lock()
+	entry, ok := l.keys[key]
+	if !ok {
+		entry = &keyedLockEntry{}
+		l.keys[key] = entry
+	}
+	entry.refs++
+	l.lock.Unlock()
+
+	entry.Lock()
+
+	return func() {
+		entry.Unlock()
+
+		l.lock.Lock()
+		defer l.lock.Unlock()
+
+		// Lock of the key is released once nobody waits for it
+		entry.refs--
+		if entry.refs == 0 {
+			delete(l.keys, key)
+		}
+	}
+}
+
+// NewKeyedLock creates a new lock per key
+func NewKeyedLock() *KeyedLock {
+	return &KeyedLock{
+		keys: map[string]*keyedLockEntry{},
+	}
+}
@@ -67,3 +67,41 @@ func TestCreateSemaphoreParallel(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestKeyedLock(t *testing.T) {
+	l := NewKeyedLock()
+
+	unlock := l.Lock("pvc")
+
+	otherDone := make(chan bool)
+	go func() {
+		l.Lock("other")()
+		close(otherDone)
+	}()
+
+	select {
+	case <-otherDone:
+	case <-time.After(time.Second):
+		t.Fatal("lock of other key blocked")
+	}
+
+	sameDone := make(chan bool)
+	go func() {
+		l.Lock("pvc")()
+		close(sameDone)
+	}()
+
+	select {
+	case <-sameDone:
+		t.Fatal("lock of same key not blocked")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+	<-sameDone
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	assert.Empty(t, l.keys, "released keys kept")
+}
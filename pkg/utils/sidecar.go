@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// metricsSidecarNames are the names of the containers injected for metrics
+var metricsSidecarNames = map[string]bool{
+	"discoblocks-metrics":       true,
+	"discoblocks-metrics-proxy": true,
+}
+
+// imagePullFailureReasons are the waiting reasons of containers whose image can't be pulled
+var imagePullFailureReasons = map[string]bool{
+	"ErrImagePull":     true,
+	"ImagePullBackOff": true,
+	"InvalidImageName": true,
+}
+
+// MetricsImageTracker remembers pull failures of metrics sidecar images by namespace, so admission can skip injection
+// and keep Pods running without autoscaling. Pull secrets are namespaced, so a failure in one namespace doesn't affect others.
+// Nil tracker reports images always available.
+type MetricsImageTracker struct {
+	period      time.Duration
+	lock        sync.RWMutex
+	failedUntil map[string]time.Time
+}
+
+// NewMetricsImageTracker creates a new tracker skipping injection for period after a pull failure, returns nil on zero period
+func NewMetricsImageTracker(period time.Duration) (*MetricsImageTracker, error) {
+	if period < 0 {
+		return nil, fmt.Errorf("invalid period: %s", period)
+	} else if period == 0 {
+		return nil, nil
+	}
+
+	return &MetricsImageTracker{
+		period:      period,
+		failedUntil: map[string]time.Time{},
+	}, nil
+}
+
+// ReportPod checks metrics sidecars of the Pod, returns the name of the image failed to pull if the failure starts a new period.
+// Failures reported within the period don't extend it, so a single stuck Pod doesn't skip injection forever.
+func (t *MetricsImageTracker) ReportPod(pod *corev1.Pod, now time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	for i := range pod.Status.ContainerStatuses {
+		status := pod.Status.ContainerStatuses[i]
+		if !metricsSidecarNames[status.Name] || status.State.Waiting == nil || !imagePullFailureReasons[status.State.Waiting.Reason] {
+			continue
+		}
+
+		image := status.Image
+		for c := range pod.Spec.Containers {
+			if pod.Spec.Containers[c].Name == status.Name {
+				image = pod.Spec.Containers[c].Image
+				break
+			}
+		}
+
+		key := renderMetricsImageKey(pod.Namespace, image)
+
+		t.lock.Lock()
+		defer t.lock.Unlock()
+
+		for k, until := range t.failedUntil {
+			if !now.Before(until) {
+				delete(t.failedUntil, k)
+			}
+		}
+
+		if _, ok := t.failedUntil[key]; ok {
+			return ""
+		}
+		t.failedUntil[key] = now.Add(t.period)
+
+		return image
+	}
+
+	return ""
+}
+
+// IsAvailable checks whether no pull failure of the images has been reported in the namespace within the period
+func (t *MetricsImageTracker) IsAvailable(namespace string, images []string, now time.Time) bool {
+	if t == nil {
+		return true
+	}
+
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	for _, image := range images {
+		if now.Before(t.failedUntil[renderMetricsImageKey(namespace, image)]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// renderMetricsImageKey renders the key of an image pulled in a namespace
+func renderMetricsImageKey(namespace, image string) string {
+	return namespace + "/" + image
+}
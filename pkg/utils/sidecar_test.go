@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewMetricsImageTracker(t *testing.T) {
+	cases := map[string]struct {
+		period        time.Duration
+		expectedNil   bool
+		expectedError bool
+	}{
+		"disabled": {
+			expectedNil: true,
+		},
+		"negative period": {
+			period:        -time.Minute,
+			expectedNil:   true,
+			expectedError: true,
+		},
+		"valid": {
+			period: time.Minute,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			tracker, err := NewMetricsImageTracker(c.period)
+
+			assert.Equal(t, c.expectedError, err != nil, "invalid error")
+			assert.Equal(t, c.expectedNil, tracker == nil, "invalid tracker")
+		})
+	}
+}
+
+func TestMetricsImageTracker(t *testing.T) {
+	t.Parallel()
+
+	pod := func(name, reason string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name:  name,
+						Image: "alpine:3.16",
+						State: corev1.ContainerState{
+							Waiting: &corev1.ContainerStateWaiting{Reason: reason},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		pod               *corev1.Pod
+		expectedImage     string
+		expectedAvailable bool
+	}{
+		"running": {
+			pod:               &corev1.Pod{},
+			expectedAvailable: true,
+		},
+		"metrics image pull back-off": {
+			pod:           pod("discoblocks-metrics", "ImagePullBackOff"),
+			expectedImage: "alpine:3.16",
+		},
+		"proxy image pull error": {
+			pod:           pod("discoblocks-metrics-proxy", "ErrImagePull"),
+			expectedImage: "alpine:3.16",
+		},
+		"metrics container crash": {
+			pod:               pod("discoblocks-metrics", "CrashLoopBackOff"),
+			expectedAvailable: true,
+		},
+		"application image pull back-off": {
+			pod:               pod("app", "ImagePullBackOff"),
+			expectedAvailable: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			tracker, err := NewMetricsImageTracker(time.Minute)
+			require.Nil(t, err, "unable to create tracker")
+
+			now := time.Now()
+
+			images := []string{"alpine:3.16"}
+
+			assert.Equal(t, c.expectedImage, tracker.ReportPod(c.pod, now), "invalid failed image")
+			assert.Equal(t, c.expectedAvailable, tracker.IsAvailable("default", images, now), "invalid availability")
+			assert.True(t, tracker.IsAvailable("other", images, now), "not available in other namespace")
+			assert.True(t, tracker.IsAvailable("default", images, now.Add(time.Minute)), "not available after period")
+		})
+	}
+}
+
+func TestMetricsImageTrackerPeriod(t *testing.T) {
+	tracker, err := NewMetricsImageTracker(time.Minute)
+	require.Nil(t, err, "unable to create tracker")
+
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "discoblocks-metrics", Image: "alpine:3.16"}},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "discoblocks-metrics",
+				Image: "docker.io/library/alpine:3.16",
+				State: corev1.ContainerState{
+					Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"},
+				},
+			}},
+		},
+	}
+	images := []string{"alpine:3.16"}
+
+	now := time.Now()
+
+	assert.Equal(t, "alpine:3.16", tracker.ReportPod(&pod, now), "failure not reported by image of spec")
+
+	// Stuck Pod doesn't extend the period on every report
+	assert.Empty(t, tracker.ReportPod(&pod, now.Add(30*time.Second)), "failure reported again within period")
+	assert.True(t, tracker.IsAvailable("default", images, now.Add(time.Minute)), "period extended")
+
+	assert.Equal(t, "alpine:3.16", tracker.ReportPod(&pod, now.Add(time.Minute)), "failure not reported after period")
+	assert.False(t, tracker.IsAvailable("default", images, now.Add(time.Minute)), "new period not started")
+}
+
+func TestMetricsImageTrackerNil(t *testing.T) {
+	var tracker *MetricsImageTracker
+
+	assert.Empty(t, tracker.ReportPod(&corev1.Pod{}, time.Now()), "nil tracker reported failure")
+	assert.True(t, tracker.IsAvailable("default", []string{"alpine:3.16"}, time.Now()), "nil tracker is unavailable")
+}
@@ -62,7 +62,7 @@ func (s *podSCheduler) Filter(ctx context.Context, state *framework.CycleState,
 	for i := range diskConfigs.Items {
 		config := diskConfigs.Items[i]
 
-		if config.DeletionTimestamp != nil || !utils.IsContainsAll(pod.Labels, config.Spec.PodSelector) {
+		if config.DeletionTimestamp != nil || !utils.IsPodSelected(pod.Labels, config.Spec.PodSelector) {
 			continue
 		}
 
@@ -129,8 +129,8 @@ func (s *podSCheduler) Filter(ctx context.Context, state *framework.CycleState,
 		}
 
 		if !found {
-			logger.Info("CSI Driver not found")
-			return framework.NewStatus(framework.Unschedulable, "CSI Driver not found for: "+sc.Provisioner)
+			logger.Info("CSI Driver not found", "csi_namespace", namespace, "csi_labels", podLabels)
+			return framework.NewStatus(framework.Unschedulable, fmt.Sprintf("CSI Driver not found for: %s, no Pod in %s with labels %v, check CSI_DRIVER_POD_SELECTORS", sc.Provisioner, namespace, podLabels))
 		}
 	}
 
@@ -1,15 +1,24 @@
 package schedulers
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/go-logr/logr"
 	"golang.org/x/net/context"
 	scheduler "k8s.io/kubernetes/cmd/kube-scheduler/app"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
 )
 
+// SchedulerName is the scheduler of Pods mutated by Discoblocks
+const SchedulerName = "discoblocks-scheduler"
+
+// configPath is the scheduler configuration mounted from ConfigMap
+const configPath = "/etc/kubernetes/discoblocks-scheduler/scheduler-config.yaml"
+
 // log is for logging in this package
 var schedulerLog = logf.Log.WithName("schedulers.Scheduler")
 
@@ -24,6 +33,12 @@ type Scheduler struct {
 func (s *Scheduler) Start(ctx context.Context) <-chan error {
 	s.logger.Info("Plugin start...")
 
+	if err := checkSchedulerProfile(configPath); err != nil {
+		s.logger.Error(err, "Scheduler profile not found, Pods mutated by Discoblocks stay Pending", "scheduler_name", SchedulerName)
+	} else {
+		s.logger.Info("Scheduler profile found", "scheduler_name", SchedulerName)
+	}
+
 	errChan := make(chan error)
 
 	go func() {
@@ -39,7 +54,7 @@ func (s *Scheduler) Start(ctx context.Context) <-chan error {
 		command := scheduler.NewSchedulerCommand(scheduler.WithPlugin(podSchedulerPlugin.Name(), podSchedulerPlugin.Factory))
 		command.SetOut(&logWriter{s.logger})
 		command.SetErr(os.Stderr)
-		command.SetArgs([]string{"--config=/etc/kubernetes/discoblocks-scheduler/scheduler-config.yaml"})
+		command.SetArgs([]string{"--config=" + configPath})
 		if err := command.ExecuteContext(ctx); err != nil {
 			s.logger.Error(err, "Scheduler plugin crashed")
 			errChan <- err
@@ -59,6 +74,31 @@ func NewScheduler(kubeClient client.Client, strict bool) *Scheduler {
 	}
 }
 
+// checkSchedulerProfile checks the scheduler configuration serves SchedulerName
+func checkSchedulerProfile(path string) error {
+	raw, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("unable to read scheduler config: %w", err)
+	}
+
+	config := struct {
+		Profiles []struct {
+			SchedulerName string `json:"schedulerName"`
+		} `json:"profiles"`
+	}{}
+	if err := yaml.Unmarshal(raw, &config); err != nil {
+		return fmt.Errorf("unable to parse scheduler config: %w", err)
+	}
+
+	for _, p := range config.Profiles {
+		if p.SchedulerName == SchedulerName {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("profile not found in scheduler config: %s", SchedulerName)
+}
+
 type logWriter struct {
 	logr.Logger
 }
@@ -0,0 +1,55 @@
+package schedulers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSchedulerProfile(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		config        string
+		expectedError bool
+	}{
+		"profile found": {
+			config: `apiVersion: kubescheduler.config.k8s.io/v1beta2
+kind: KubeSchedulerConfiguration
+profiles:
+- schedulerName: default-scheduler
+- schedulerName: discoblocks-scheduler
+`,
+		},
+		"profile missing": {
+			config: `apiVersion: kubescheduler.config.k8s.io/v1beta2
+kind: KubeSchedulerConfiguration
+profiles:
+- schedulerName: default-scheduler
+`,
+			expectedError: true,
+		},
+		"invalid config": {
+			config:        "profiles: foo",
+			expectedError: true,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "scheduler-config.yaml")
+			require.Nil(t, os.WriteFile(path, []byte(c.config), 0o600), "unable to write config")
+
+			err := checkSchedulerProfile(path)
+			assert.Equal(t, c.expectedError, err != nil, "invalid profile check")
+		})
+	}
+
+	assert.NotNil(t, checkSchedulerProfile(filepath.Join(t.TempDir(), "missing.yaml")), "missing config accepted")
+}